@@ -1,10 +1,13 @@
 package api
 
+import "time"
+
 const (
 	// MsgDaemonOK is the OK response upon successfully reaching daemon
 	MsgDaemonOK = "I'm a little Webhook, short and stout!"
 
-	// Container is a constant used in HTTP GET query strings
+	// Container is a constant used in HTTP GET query strings - for logs, it
+	// may be a comma-separated list of containers whose logs are merged
 	Container = "container"
 
 	// Stream is a constant used in HTTP GET query strings
@@ -12,6 +15,71 @@ const (
 
 	// Entries is a constant used in HTTP GET query strings
 	Entries = "entries"
+
+	// Offset is a constant used in HTTP GET query strings
+	Offset = "offset"
+
+	// Cursor is a constant used in HTTP GET query strings
+	Cursor = "cursor"
+
+	// Since is the query parameter for a timestamp (RFC3339) or a duration
+	// before now, such as "10m", from which to fetch logs. All logs since then
+	// are fetched unless entries is set, in which case only the last entries
+	// of them are.
+	Since = "since"
+
+	// Download is the query parameter for whether to send logs as a file to
+	// download rather than to display
+	Download = "download"
+
+	// Plain is the query parameter for whether to remove ANSI escape
+	// sequences, such as color codes, from logs - they are kept by default
+	Plain = "plain"
+
+	// Previous is a constant used in HTTP GET query strings
+	Previous = "previous"
+
+	// LogStreams is a constant used in HTTP GET query strings
+	LogStreams = "streams"
+
+	// Timezone is the query parameter for the tz database name of the
+	// timezone to display log timestamps in - the default is UTC
+	Timezone = "tz"
+
+	// MaxLineLength is the query parameter for the length, in bytes, beyond
+	// which log lines are truncated - lines are not truncated by default
+	MaxLineLength = "max_line_length"
+
+	// LogStreamsStdout, LogStreamsStderr, and LogStreamsBoth are the accepted
+	// values of the LogStreams query parameter
+	LogStreamsStdout = "stdout"
+	LogStreamsStderr = "stderr"
+	LogStreamsBoth   = "both"
+
+	// MsgLogStreamContainerStopped and MsgLogStreamLimitReached are sent as
+	// the reason when a log stream websocket is closed by the daemon
+	MsgLogStreamContainerStopped = "stream ended: container stopped"
+	MsgLogStreamLimitReached     = "stream ended: limit reached"
+
+	// EventTypeDeploy and EventTypeContainer are the types of events
+	// reported by the events feed
+	EventTypeDeploy    = "deploy"
+	EventTypeContainer = "container"
+	EventTypeSpot      = "spot"
+
+	// EventDeployStarted, EventDeploySucceeded, and EventDeployFailed are the
+	// actions of deploy events
+	EventDeployStarted   = "started"
+	EventDeploySucceeded = "succeeded"
+	EventDeployFailed    = "failed"
+
+	// EventSpotInterruption is the action of spot events, reported when the
+	// remote's spot instance is scheduled to be interrupted
+	EventSpotInterruption = "interruption"
+
+	// HeaderLogCursor is the response header containing the cursor to use to
+	// retrieve only logs written after those in the response
+	HeaderLogCursor = "X-Inertia-Log-Cursor"
 )
 
 // UpRequest is the configurable body of a UP request to the daemon.
@@ -22,12 +90,282 @@ type UpRequest struct {
 	BuildFilePath string     `json:"build_file_path"`
 	GitOptions    GitOptions `json:"git_options"`
 	WebHookSecret string     `json:"webhook_secret"`
+
+	// Release is a name for the deployed commit, such as "v1.2.3", that it
+	// can be referred to by in the deployment history and when rolling back
+	Release string `json:"release,omitempty"`
+
+	ContainerUser      string `json:"container_user,omitempty"`
+	EnforceNonRootUser bool   `json:"enforce_non_root_user,omitempty"`
+
+	// Platform is the platform to build the project for, such as
+	// "linux/arm64" - the host's platform is used if it is empty
+	Platform string `json:"platform,omitempty"`
+
+	// BuildTarget is the stage of a multi-stage Dockerfile to build - the
+	// last stage is built if it is empty
+	BuildTarget string `json:"build_target,omitempty"`
+
+	// BuildArgs are values, keyed by name, for ARG instructions in the
+	// project's Dockerfile. Cached image layers are reused as long as the
+	// values of the arguments they depend on are unchanged - changing a value
+	// rebuilds the layers from the first instruction that uses it onwards.
+	// They do not apply to docker-compose projects, which should set 'args' in
+	// the build configuration of each service instead.
+	BuildArgs map[string]string `json:"build_args,omitempty"`
+
+	WatchPaths []string `json:"watch_paths,omitempty"`
+
+	// Env are environment variables, keyed by name, that project containers
+	// are started with in addition to those saved on the remote - these take
+	// precedence if a variable is set in both
+	Env map[string]string `json:"env,omitempty"`
+
+	// BaseImageCheckHours is how often, in hours, to check for updates to the
+	// project's base images and redeploy if there are any - updates are not
+	// checked for if it is zero
+	BaseImageCheckHours int `json:"base_image_check_hours,omitempty"`
+
+	// PollIntervalMinutes is how often, in minutes, to fetch the deployed
+	// branch and deploy new commits - the branch is not polled if it is zero
+	PollIntervalMinutes int `json:"poll_interval_minutes,omitempty"`
+
+	InitJob *InitJob `json:"init_job,omitempty"`
+
+	// Labels are additional container labels, keyed by service name
+	Labels map[string]map[string]string `json:"labels,omitempty"`
+
+	// Resources are container resource reservations, keyed by service name
+	Resources map[string]Resources `json:"resources,omitempty"`
+
+	// Networking is container host name resolution, keyed by service name
+	Networking map[string]Networking `json:"networking,omitempty"`
+
+	// Healthchecks override container image healthchecks, keyed by service
+	// name
+	Healthchecks map[string]Healthcheck `json:"healthchecks,omitempty"`
+
+	// Commands override container image entrypoints and commands, keyed by
+	// service name
+	Commands map[string]CommandOverride `json:"commands,omitempty"`
+
+	// Readiness configures checks that containers must pass after they are
+	// started, keyed by service name
+	Readiness map[string]Readiness `json:"readiness,omitempty"`
+
+	// Buildx builds Dockerfile projects with buildx instead of the classic
+	// builder, if set
+	Buildx *Buildx `json:"buildx,omitempty"`
+
+	// DependsOn declares containers that Dockerfile projects wait for before
+	// they are started
+	DependsOn *DependsOn `json:"depends_on,omitempty"`
+
+	// Static configures how static projects are built and served, if set
+	Static *Static `json:"static,omitempty"`
+
+	// CleanBuild removes untracked files, except those matching CleanExclude,
+	// from the project directory before building
+	CleanBuild   bool     `json:"clean_build,omitempty"`
+	CleanExclude []string `json:"clean_exclude,omitempty"`
+
+	// StopTimeout is how many seconds project containers are given to stop
+	// gracefully before they are killed when the project is redeployed or
+	// shut down - the daemon's default is used if it is 0
+	StopTimeout int `json:"stop_timeout,omitempty"`
+
+	// Alerts configures resource usage alerts, if set
+	Alerts *Alerts `json:"alerts,omitempty"`
+
+	// VerifyImagesKey is a cosign public key that the images the project is
+	// built from must be signed with - signatures are not verified if it is
+	// empty
+	VerifyImagesKey string `json:"verify_images_key,omitempty"`
+
+	// RetainedDeploys is the number of recent deployments whose images are
+	// kept for rollback
+	RetainedDeploys int `json:"retained_deploys,omitempty"`
+
+	// DryRun requests a preview of the changes the deployment would make,
+	// without deploying
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// RollbackRequest is the body of a request to roll back to a retained
+// deployment
+type RollbackRequest struct {
+	Stream bool `json:"stream"`
+
+	// Commit is the release name or commit of the retained deployment to
+	// roll back to - commits may be abbreviated, and the previous deployment
+	// is used if it is empty
+	Commit string `json:"commit,omitempty"`
+}
+
+// RecreateRequest is the body of a request to recreate a single service
+type RecreateRequest struct {
+	Stream bool `json:"stream"`
+
+	// Service is the docker-compose service, or for Dockerfile projects the
+	// project name, to recreate
+	Service string `json:"service"`
+}
+
+// InitJob is a one-shot job, such as a database migration, that is run to
+// completion before the project's services are started
+type InitJob struct {
+	// Service is the docker-compose service to run as the job
+	Service string `json:"service,omitempty"`
+
+	// Command is the command to run - for Dockerfile projects, it is run in
+	// the project image, and for docker-compose projects, it overrides the
+	// service's command if provided
+	Command []string `json:"command,omitempty"`
+}
+
+// Resources configures the resources reserved for a service's containers
+type Resources struct {
+	// MemoryReservation is a soft memory limit, such as "256m", that the
+	// container is guaranteed when memory is scarce
+	MemoryReservation string `json:"memory_reservation,omitempty"`
+
+	// CPUShares is the container's weight relative to other containers when
+	// CPU time is scarce - Docker's default is 1024
+	CPUShares int64 `json:"cpu_shares,omitempty"`
+}
+
+// Networking configures how a service's containers resolve host names
+type Networking struct {
+	// ExtraHosts are additional entries, such as "db.internal:10.0.0.5", for
+	// the container's hosts file
+	ExtraHosts []string `json:"extra_hosts,omitempty"`
+
+	// DNS is a list of DNS servers for the container to use instead of the
+	// host's
+	DNS []string `json:"dns,omitempty"`
+
+	// Aliases are additional host names the container can be reached by from
+	// other containers on the project's network
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Healthcheck overrides the healthcheck of a service's containers
+type Healthcheck struct {
+	// Test is the healthcheck command in Docker's exec form, such as
+	// ["CMD", "curl", "-f", "http://localhost"], a single shell command, or
+	// ["NONE"] to disable the image's healthcheck
+	Test []string `json:"test,omitempty"`
+
+	// Interval, Timeout, and StartPeriod are durations such as "30s"
+	Interval    string `json:"interval,omitempty"`
+	Timeout     string `json:"timeout,omitempty"`
+	StartPeriod string `json:"start_period,omitempty"`
+
+	Retries int `json:"retries,omitempty"`
+}
+
+// CommandOverride overrides the entrypoint and command of a service's
+// containers
+type CommandOverride struct {
+	// Entrypoint replaces the image's entrypoint, in exec form - the image's
+	// command is also cleared if it is set
+	Entrypoint []string `json:"entrypoint,omitempty"`
+
+	// Command replaces the image's command, in exec form
+	Command []string `json:"command,omitempty"`
+}
+
+// Readiness configures how to check that a service's container is ready -
+// exactly one of Port or Command must be set
+type Readiness struct {
+	// Port is a port that must accept connections in the container
+	Port int `json:"port,omitempty"`
+
+	// Command is a command, in exec form, that must exit successfully when
+	// run in the container
+	Command []string `json:"command,omitempty"`
+
+	// Timeout is how many seconds to wait for the container to be ready - the
+	// daemon's default is used if it is 0
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Static configures static site projects
+type Static struct {
+	// Image is the image BuildCommand is run in
+	Image string `json:"image,omitempty"`
+
+	// BuildCommand is run in the project directory to build the site
+	BuildCommand string `json:"build_command,omitempty"`
+
+	// OutputDirectory is the project directory that is served once the site
+	// is built
+	OutputDirectory string `json:"output_directory,omitempty"`
+}
+
+// Buildx configures Dockerfile builds with buildx
+type Buildx struct {
+	// Cache is where the build cache is kept - one of "local" (the default),
+	// "registry", or "none"
+	Cache string `json:"cache,omitempty"`
+
+	// CacheRef is the image reference the cache is kept at if Cache is
+	// "registry"
+	CacheRef string `json:"cache_ref,omitempty"`
+}
+
+// DependsOn declares containers that must be running before a project is
+// started
+type DependsOn struct {
+	// Containers are the names of the containers depended on
+	Containers []string `json:"containers"`
+
+	// WaitHealthy requires dependencies with healthchecks to be healthy
+	WaitHealthy bool `json:"wait_healthy,omitempty"`
+
+	// Timeout is how many seconds to wait for dependencies - the daemon's
+	// default is used if it is 0
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Alerts configures resource usage alerts sent by the daemon
+type Alerts struct {
+	// WebhookURL is where alerts are posted to
+	WebhookURL string `json:"webhook_url"`
+
+	// MemoryPercent and CPUPercent are thresholds on the usage of each
+	// project container, and DiskPercent on the usage of the host's disk -
+	// thresholds that are 0 are not checked
+	MemoryPercent float64 `json:"memory_percent,omitempty"`
+	CPUPercent    float64 `json:"cpu_percent,omitempty"`
+	DiskPercent   float64 `json:"disk_percent,omitempty"`
+
+	// CPUMinutes is how many minutes CPU usage must stay above CPUPercent
+	// before an alert is sent - the daemon's default is used if it is 0
+	CPUMinutes int `json:"cpu_minutes,omitempty"`
+}
+
+// Alert is posted to the alerts webhook when resource usage crosses a
+// threshold, and again with Resolved set once it falls back below it. Text
+// describes the alert, so that it can be posted to chat services directly.
+type Alert struct {
+	Text      string    `json:"text"`
+	Resource  string    `json:"resource"`
+	Container string    `json:"container,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Resolved  bool      `json:"resolved"`
+	Time      time.Time `json:"time"`
 }
 
 // GitOptions represents GitHub-related deployment options
 type GitOptions struct {
 	RemoteURL string `json:"remote"`
 	Branch    string `json:"branch"`
+
+	// Commit, if set, is deployed instead of the head of Branch - the
+	// deployment fails if the commit cannot be checked out
+	Commit string `json:"commit,omitempty"`
 }
 
 // UserRequest is used for logging in or modifying users
@@ -47,9 +385,11 @@ type TotpResponse struct {
 
 // EnvRequest represents a request to manage environment variables
 type EnvRequest struct {
-	Name    string `json:"name,omitempty"`
-	Value   string `json:"value,omitempty"`
-	Encrypt bool   `json:"encrypt,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// Encrypt is deprecated - values are always encrypted at rest
+	Encrypt bool `json:"encrypt,omitempty"`
 
 	Remove bool `json:"remove,omitempty"`
 }
@@ -60,7 +400,81 @@ type DeploymentStatus struct {
 	Branch               string   `json:"branch"`
 	CommitHash           string   `json:"commit_hash"`
 	CommitMessage        string   `json:"commit_message"`
+	Release              string   `json:"release,omitempty"`
 	BuildType            string   `json:"build_type"`
 	Containers           []string `json:"containers"`
 	BuildContainerActive bool     `json:"build_active"`
+
+	// Health is the health status, such as "healthy", of each active project
+	// container that has a healthcheck, keyed by container name
+	Health map[string]string `json:"health,omitempty"`
+
+	// SitePublished is set if the site of a static project is being served by
+	// the daemon - static projects have no containers
+	SitePublished bool `json:"site_published,omitempty"`
+
+	// ActiveBuilds and QueuedBuilds are the number of deployments currently
+	// building, and waiting for a build slot
+	ActiveBuilds int `json:"active_builds"`
+	QueuedBuilds int `json:"queued_builds"`
+
+	// SpotInterruption is when the remote's spot instance is scheduled to be
+	// interrupted, if an interruption notice has been received
+	SpotInterruption *time.Time `json:"spot_interruption,omitempty"`
+}
+
+// DeploymentPreview summarizes what a deployment would change
+type DeploymentPreview struct {
+	Branch        string   `json:"branch"`
+	CurrentCommit string   `json:"current_commit"`
+	TargetCommit  string   `json:"target_commit"`
+	NewCommits    []string `json:"new_commits"`
+	ConfigChanges []string `json:"config_changes"`
+
+	// Containers lists the active project containers that would be stopped
+	// and recreated
+	Containers []string `json:"containers"`
+}
+
+// DeploymentRecord describes a single deployment attempt
+type DeploymentRecord struct {
+	Initiator  string        `json:"initiator"`
+	Branch     string        `json:"branch"`
+	CommitHash string        `json:"commit_hash"`
+	Release    string        `json:"release,omitempty"`
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Event is a change in the state of the deployment or its containers, as
+// streamed by the events feed. Container events use Docker's actions, such as
+// "start", "die", or "health_status: healthy".
+type Event struct {
+	Type       string    `json:"type"`
+	Action     string    `json:"action"`
+	Container  string    `json:"container,omitempty"`
+	Initiator  string    `json:"initiator,omitempty"`
+	CommitHash string    `json:"commit_hash,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// ConfigReload reports the outcome of reloading the daemon's configuration
+type ConfigReload struct {
+	// Applied lists the settings that changed and were applied
+	Applied []string `json:"applied"`
+
+	// RequiresRestart lists the settings that changed but only take effect
+	// once the daemon is restarted
+	RequiresRestart []string `json:"requires_restart"`
+}
+
+// DaemonInfo describes the daemon and the host it runs on
+type DaemonInfo struct {
+	InertiaVersion   string   `json:"version"`
+	DockerVersion    string   `json:"docker_version"`
+	DockerAPIVersion string   `json:"docker_api_version"`
+	BuildTypes       []string `json:"build_types"`
 }
@@ -21,9 +21,208 @@ type Config struct {
 	BuildType     string `toml:"build-type"`
 	BuildFilePath string `toml:"build-file-path"`
 
+	// ContainerUser is the user (name or UID[:GID]) that Dockerfile project
+	// containers run as. If unset and EnforceNonRootUser is enabled, images
+	// that do not declare a USER run as an unprivileged user instead of root.
+	ContainerUser      string `toml:"container-user,omitempty"`
+	EnforceNonRootUser bool   `toml:"enforce-non-root-user,omitempty"`
+
+	// Platform is the platform, such as "linux/arm64", that Dockerfile
+	// projects are built for. The remote's platform is used if unset.
+	Platform string `toml:"platform,omitempty"`
+
+	// BuildTarget is the stage of a multi-stage Dockerfile to build, such as
+	// "production". The last stage is built if unset.
+	BuildTarget string `toml:"build-target,omitempty"`
+
+	// WatchPaths restricts webhook-triggered deployments to pushes that change
+	// files matching at least one of the given paths or glob patterns. Pushes
+	// to any path trigger a deployment if unset.
+	WatchPaths []string `toml:"watch-paths,omitempty"`
+
+	// BaseImageCheckHours, if set, makes the daemon check for updates to the
+	// images the project is built from or runs every this many hours, and
+	// redeploy the project if any have been updated
+	BaseImageCheckHours int `toml:"base-image-check-hours,omitempty"`
+
+	// PollIntervalMinutes, if set, makes the daemon fetch the deployed branch
+	// every this many minutes, and deploy it if there are new commits
+	PollIntervalMinutes int `toml:"poll-interval-minutes,omitempty"`
+
+	// InitJob is a one-shot job, such as a database migration, that must run
+	// to completion before the project is started
+	InitJob *InitJob `toml:"init-job,omitempty"`
+
+	// Labels are additional labels to apply to project containers, keyed by
+	// docker-compose service name, or by project name for Dockerfile projects
+	Labels map[string]map[string]string `toml:"labels,omitempty"`
+
+	// Resources configures resource reservations for project containers,
+	// keyed like Labels
+	Resources map[string]Resources `toml:"resources,omitempty"`
+
+	// Networking configures host name resolution for project containers,
+	// keyed like Labels
+	Networking map[string]Networking `toml:"networking,omitempty"`
+
+	// Healthchecks override the healthchecks of project containers' images,
+	// keyed like Labels
+	Healthchecks map[string]Healthcheck `toml:"healthcheck,omitempty"`
+
+	// Commands override the entrypoints and commands of project containers'
+	// images, keyed like Labels, so that one image can serve several roles
+	Commands map[string]CommandOverride `toml:"command,omitempty"`
+
+	// Readiness configures checks that project containers must pass after
+	// they are started for a deployment to succeed, keyed like Labels
+	Readiness map[string]Readiness `toml:"readiness,omitempty"`
+
+	// Buildx builds Dockerfile projects with buildx and a persistent build
+	// cache instead of Docker's classic builder
+	Buildx *Buildx `toml:"buildx,omitempty"`
+
+	// DependsOn declares containers on the remote that Dockerfile projects
+	// depend on, which are waited for before the project is started
+	DependsOn *DependsOn `toml:"depends-on,omitempty"`
+
+	// Static configures how projects with the "static" build type are built,
+	// and which of their directories the daemon serves
+	Static *Static `toml:"static,omitempty"`
+
+	// CleanBuild removes all untracked and ignored files from the project
+	// directory before each build, like 'git clean -fdx'. Files matching
+	// CleanExclude, such as '.env' or data directories, are kept.
+	CleanBuild   bool     `toml:"clean-build,omitempty"`
+	CleanExclude []string `toml:"clean-exclude,omitempty"`
+
+	// RetainedDeploys is the number of recent deployments whose images are
+	// kept, tagged by commit, so that they can be rolled back to without
+	// rebuilding. Images are not retained if unset.
+	RetainedDeploys int `toml:"retained-deploys,omitempty"`
+
+	// StopTimeout is how many seconds project containers are given to stop
+	// gracefully, for example to drain connections, before they are killed
+	// when the project is redeployed or shut down. Defaults to 10 seconds.
+	StopTimeout int `toml:"stop-timeout,omitempty"`
+
+	// VerifyImagesKey is the path to a cosign public key. If set, deployments
+	// are refused unless the images the project is built from are signed by
+	// the owner of this key.
+	VerifyImagesKey string `toml:"verify-images-key,omitempty"`
+
+	// Alerts configures notifications sent by the daemon when the resource
+	// usage of the project or its remote crosses a threshold
+	Alerts *Alerts `toml:"alerts,omitempty"`
+
 	Remotes map[string]*RemoteVPS `toml:"remotes"`
 }
 
+// InitJob configures a one-shot job run before the project is started. For
+// docker-compose projects, Service is the service to run, optionally with
+// Command overriding its command. For Dockerfile projects, Command is run in
+// the project image.
+type InitJob struct {
+	Service string   `toml:"service,omitempty"`
+	Command []string `toml:"command,omitempty"`
+}
+
+// Resources configures the resources reserved for a service's containers.
+// MemoryReservation is a soft memory limit such as "256m", and CPUShares is
+// the container's relative weight when CPU time is scarce.
+type Resources struct {
+	MemoryReservation string `toml:"memory-reservation,omitempty"`
+	CPUShares         int64  `toml:"cpu-shares,omitempty"`
+}
+
+// Networking configures how a service's containers resolve host names.
+// ExtraHosts are additional "hostname:IP" entries for the containers' hosts
+// files, and DNS is a list of DNS servers to use instead of the host's.
+// Aliases are additional host names, such as "db", that other containers on
+// the project's network can reach the service's containers by.
+type Networking struct {
+	ExtraHosts []string `toml:"extra-hosts,omitempty"`
+	DNS        []string `toml:"dns,omitempty"`
+	Aliases    []string `toml:"aliases,omitempty"`
+}
+
+// Healthcheck overrides the healthcheck of a service's containers. Test is
+// the command to run, either in Docker's exec form such as ["CMD", "curl",
+// "-f", "http://localhost"] or as a single shell command, or ["NONE"] to
+// disable the image's healthcheck. Interval, Timeout, and StartPeriod are
+// durations such as "30s" - the image's or Docker's defaults are used for
+// options that are not set.
+type Healthcheck struct {
+	Test        []string `toml:"test,omitempty"`
+	Interval    string   `toml:"interval,omitempty"`
+	Timeout     string   `toml:"timeout,omitempty"`
+	StartPeriod string   `toml:"start-period,omitempty"`
+	Retries     int      `toml:"retries,omitempty"`
+}
+
+// CommandOverride overrides the entrypoint and command of a service's
+// containers, both in Docker's exec form such as ["bundle", "exec", "sidekiq"].
+// Overriding the entrypoint also clears the image's command, so set both if
+// the image's entrypoint expects arguments.
+type CommandOverride struct {
+	Entrypoint []string `toml:"entrypoint,omitempty"`
+	Command    []string `toml:"command,omitempty"`
+}
+
+// Readiness configures how to check that a service's container is ready,
+// which is useful for services such as databases that don't serve HTTP. Port
+// is a port that must accept connections, or Command is a command, such as
+// ["pg_isready"], that must succeed when run in the container. Timeout is how
+// many seconds to wait, and defaults to 60.
+type Readiness struct {
+	Port    int      `toml:"port,omitempty"`
+	Command []string `toml:"command,omitempty"`
+	Timeout int      `toml:"timeout,omitempty"`
+}
+
+// Buildx configures Dockerfile builds with buildx. Cache is where the build
+// cache is kept - "local" (the default) keeps it on the remote, "registry"
+// imports and exports it to the image reference CacheRef, and "none" disables
+// it.
+type Buildx struct {
+	Cache    string `toml:"cache,omitempty"`
+	CacheRef string `toml:"cache-ref,omitempty"`
+}
+
+// Static configures static site projects. BuildCommand, if set, is run in the
+// project directory in a container from Image, or "node:lts-alpine" if it is not
+// set. OutputDirectory is the project directory that is served once the build
+// completes, or "public" if it is not set.
+type Static struct {
+	Image           string `toml:"image,omitempty"`
+	BuildCommand    string `toml:"build-command,omitempty"`
+	OutputDirectory string `toml:"output-directory,omitempty"`
+}
+
+// DependsOn declares containers that must be running before a Dockerfile
+// project is started, such as a database run outside of Inertia. If
+// WaitHealthy is set, dependencies with healthchecks must also be healthy.
+// Dependencies are waited for for up to Timeout seconds, or 60 seconds if it
+// is not set.
+type DependsOn struct {
+	Containers  []string `toml:"containers"`
+	WaitHealthy bool     `toml:"wait-healthy,omitempty"`
+	Timeout     int      `toml:"timeout,omitempty"`
+}
+
+// Alerts configures resource usage alerts, which are posted to WebhookURL
+// when usage crosses a threshold and when it recovers. Thresholds are
+// percentages - MemoryPercent and CPUPercent apply to each project container,
+// and DiskPercent to the remote's disk. CPU usage must stay above CPUPercent
+// for CPUMinutes, 5 minutes if unset, before an alert is sent. Thresholds
+// that are not set are not checked.
+type Alerts struct {
+	WebhookURL    string  `toml:"webhook-url"`
+	MemoryPercent float64 `toml:"memory-percent,omitempty"`
+	CPUPercent    float64 `toml:"cpu-percent,omitempty"`
+	CPUMinutes    int     `toml:"cpu-minutes,omitempty"`
+	DiskPercent   float64 `toml:"disk-percent,omitempty"`
+}
+
 // NewConfig sets up Inertia configuration with given properties
 func NewConfig(version, project, buildType, buildFilePath string) *Config {
 	cfg := &Config{
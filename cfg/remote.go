@@ -9,6 +9,89 @@ type RemoteVPS struct {
 	Branch  string        `toml:"branch"`
 	SSHPort string        `toml:"ssh-port"`
 	Daemon  *DaemonConfig `toml:"daemon"`
+
+	// SSH configures SSH connections to the remote - defaults are used if it
+	// is not set
+	SSH *SSHOptions `toml:"ssh,omitempty"`
+
+	// Resources identifies the cloud resources created for this remote, if it
+	// was created with 'inertia provision'
+	Resources *ProvisionedResources `toml:"resources,omitempty"`
+}
+
+// ProvisionedResources identifies the cloud resources created when
+// provisioning a remote, so that they can be brought under the management of
+// other tools such as Terraform
+type ProvisionedResources struct {
+	Provider string `toml:"provider"`
+	Region   string `toml:"region"`
+	Account  string `toml:"account"`
+
+	InstanceID   string `toml:"instance-id"`
+	InstanceARN  string `toml:"instance-arn"`
+	ImageID      string `toml:"image-id"`
+	InstanceType string `toml:"instance-type"`
+
+	SecurityGroupID  string `toml:"security-group-id"`
+	SecurityGroupARN string `toml:"security-group-arn"`
+
+	// SubnetID is the subnet the instance was launched into, if one was
+	// requested instead of the default subnet
+	SubnetID string `toml:"subnet-id,omitempty"`
+
+	// KeyPairName is the name of the generated key pair, which identifies it
+	// since key pair IDs are not returned when key pairs are created
+	KeyPairName string `toml:"key-pair-name"`
+
+	// ExistingKeyPair is set if the key pair was supplied instead of generated
+	// for the remote, in which case it is not removed along with the instance
+	ExistingKeyPair bool `toml:"existing-key-pair,omitempty"`
+
+	// Spot is set if the instance runs on spot capacity, and SpotPrice is the
+	// maximum hourly price it was requested with, if one was set
+	Spot      bool   `toml:"spot,omitempty"`
+	SpotPrice string `toml:"spot-price,omitempty"`
+
+	// RecoveryAlarmName is the name of the CloudWatch alarm that recovers the
+	// instance on system failure, if automatic recovery was enabled
+	RecoveryAlarmName string `toml:"recovery-alarm-name,omitempty"`
+
+	// ElasticIPAllocationID is the allocation ID of the instance's Elastic IP
+	// address, if one was assigned
+	ElasticIPAllocationID string `toml:"elastic-ip-allocation-id,omitempty"`
+}
+
+// Values for SSHOptions.HostKeyChecking, which match those of OpenSSH's
+// StrictHostKeyChecking option
+const (
+	// HostKeyCheckingStrict only connects to remotes whose host keys are
+	// already in the known hosts file
+	HostKeyCheckingStrict = "yes"
+
+	// HostKeyCheckingAcceptNew adds the host keys of remotes that are not in
+	// the known hosts file, but refuses to connect if a known key changes
+	HostKeyCheckingAcceptNew = "accept-new"
+
+	// HostKeyCheckingOff does not verify host keys at all
+	HostKeyCheckingOff = "no"
+)
+
+// SSHOptions contains parameters for SSH connections to a remote
+type SSHOptions struct {
+	// Timeout is how long to wait for a connection to be established, in
+	// seconds
+	Timeout int `toml:"timeout,omitempty"`
+
+	// Retries is how many more times to try connecting if a connection fails
+	Retries int `toml:"retries,omitempty"`
+
+	// HostKeyChecking is how the remote's host key is verified -
+	// HostKeyCheckingAcceptNew if unset
+	HostKeyChecking string `toml:"host-key-checking,omitempty"`
+
+	// KnownHostsFile is where known host keys are kept - ~/.ssh/known_hosts
+	// if unset
+	KnownHostsFile string `toml:"known-hosts-file,omitempty"`
 }
 
 // DaemonConfig contains parameters for the Daemon
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
@@ -27,6 +28,36 @@ type Client struct {
 	buildType     string
 	buildFilePath string
 
+	containerUser      string
+	enforceNonRootUser bool
+	platform           string
+	buildTarget        string
+	watchPaths         []string
+	baseImageCheck     int
+	pollInterval       int
+	initJob            *cfg.InitJob
+	labels             map[string]map[string]string
+	resources          map[string]cfg.Resources
+	networking         map[string]cfg.Networking
+	healthchecks       map[string]cfg.Healthcheck
+	commands           map[string]cfg.CommandOverride
+	readiness          map[string]cfg.Readiness
+	buildx             *cfg.Buildx
+	dependsOn          *cfg.DependsOn
+	static             *cfg.Static
+	alerts             *cfg.Alerts
+	cleanBuild         bool
+	cleanExclude       []string
+	retainedDeploys    int
+	stopTimeout        int
+	verifyImagesKey    string
+
+	// deployEnv are environment variables to deploy the project with
+	deployEnv map[string]string
+
+	// buildArgs are values for ARG instructions in the project's Dockerfile
+	buildArgs map[string]string
+
 	out io.Writer
 
 	SSH       SSHSession
@@ -57,6 +88,30 @@ func NewClient(remoteName, keyPassphrase string, config *cfg.Config, out ...io.W
 		buildType:     config.BuildType,
 		buildFilePath: config.BuildFilePath,
 
+		containerUser:      config.ContainerUser,
+		enforceNonRootUser: config.EnforceNonRootUser,
+		platform:           config.Platform,
+		buildTarget:        config.BuildTarget,
+		watchPaths:         config.WatchPaths,
+		baseImageCheck:     config.BaseImageCheckHours,
+		pollInterval:       config.PollIntervalMinutes,
+		initJob:            config.InitJob,
+		labels:             config.Labels,
+		resources:          config.Resources,
+		networking:         config.Networking,
+		healthchecks:       config.Healthchecks,
+		commands:           config.Commands,
+		readiness:          config.Readiness,
+		buildx:             config.Buildx,
+		dependsOn:          config.DependsOn,
+		static:             config.Static,
+		alerts:             config.Alerts,
+		cleanBuild:         config.CleanBuild,
+		cleanExclude:       config.CleanExclude,
+		retainedDeploys:    config.RetainedDeploys,
+		stopTimeout:        config.StopTimeout,
+		verifyImagesKey:    config.VerifyImagesKey,
+
 		out: writer,
 	}, true
 }
@@ -67,6 +122,20 @@ func (c *Client) SetSSLVerification(verify bool) {
 	c.verifySSL = verify
 }
 
+// SetDeployEnv sets environment variables, keyed by name, that project
+// containers are started with when the project is deployed by Up. These take
+// precedence over variables of the same name saved with UpdateEnv.
+func (c *Client) SetDeployEnv(env map[string]string) {
+	c.deployEnv = env
+}
+
+// SetBuildArgs sets values, keyed by name, for ARG instructions in the
+// project's Dockerfile when the project is built by Up. Cached layers that
+// depend on an argument are rebuilt when its value changes.
+func (c *Client) SetBuildArgs(args map[string]string) {
+	c.buildArgs = args
+}
+
 // BootstrapRemote configures a remote vps for continuous deployment
 // by installing docker, starting the daemon and building a
 // public-private key-pair. It outputs configuration information
@@ -229,11 +298,163 @@ func (c *Client) getDaemonAPIToken(session SSHSession, daemonVersion string) (st
 // Up brings the project up on the remote VPS instance specified
 // in the deployment object.
 func (c *Client) Up(gitRemoteURL, buildType string, stream bool) (*http.Response, error) {
+	return c.UpRelease(gitRemoteURL, buildType, "", stream)
+}
+
+// UpRelease brings the project up like Up, and once it is deployed gives the
+// deployed commit the given release name, such as "v1.2.3", which it can be
+// rolled back to by
+func (c *Client) UpRelease(gitRemoteURL, buildType, release string, stream bool) (*http.Response, error) {
+	req, err := c.upRequest(gitRemoteURL, buildType, stream)
+	if err != nil {
+		return nil, err
+	}
+	req.Release = release
+	return c.post("/up", req)
+}
+
+// UpCommit brings the project up like Up, but deploys the given commit
+// instead of the head of the remote's branch, for example to move a
+// deployment to another remote without picking up new commits
+func (c *Client) UpCommit(gitRemoteURL, commit string, stream bool) (*http.Response, error) {
+	req, err := c.upRequest(gitRemoteURL, "", stream)
+	if err != nil {
+		return nil, err
+	}
+	req.GitOptions.Commit = commit
+	return c.post("/up", req)
+}
+
+// Preview requests a summary of the changes running Up with the given
+// parameters would make, without deploying
+func (c *Client) Preview(gitRemoteURL, buildType string) (*http.Response, error) {
+	req, err := c.upRequest(gitRemoteURL, buildType, false)
+	if err != nil {
+		return nil, err
+	}
+	req.DryRun = true
+	return c.post("/up", req)
+}
+
+// upRequest builds a request to deploy the project
+func (c *Client) upRequest(gitRemoteURL, buildType string, stream bool) (*api.UpRequest, error) {
 	if buildType == "" {
 		buildType = c.buildType
 	}
 
-	return c.post("/up", &api.UpRequest{
+	// Send the contents of the key used to verify image signatures, since
+	// the key file is only available locally
+	var verifyImagesKey string
+	if c.verifyImagesKey != "" {
+		key, err := ioutil.ReadFile(c.verifyImagesKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image verification key: %s", err.Error())
+		}
+		verifyImagesKey = string(key)
+	}
+
+	var initJob *api.InitJob
+	if c.initJob != nil {
+		initJob = &api.InitJob{Service: c.initJob.Service, Command: c.initJob.Command}
+	}
+
+	var resources map[string]api.Resources
+	if len(c.resources) > 0 {
+		resources = make(map[string]api.Resources, len(c.resources))
+		for service, r := range c.resources {
+			resources[service] = api.Resources{
+				MemoryReservation: r.MemoryReservation,
+				CPUShares:         r.CPUShares,
+			}
+		}
+	}
+
+	var networking map[string]api.Networking
+	if len(c.networking) > 0 {
+		networking = make(map[string]api.Networking, len(c.networking))
+		for service, n := range c.networking {
+			networking[service] = api.Networking{
+				ExtraHosts: n.ExtraHosts,
+				DNS:        n.DNS,
+				Aliases:    n.Aliases,
+			}
+		}
+	}
+
+	var healthchecks map[string]api.Healthcheck
+	if len(c.healthchecks) > 0 {
+		healthchecks = make(map[string]api.Healthcheck, len(c.healthchecks))
+		for service, h := range c.healthchecks {
+			healthchecks[service] = api.Healthcheck{
+				Test:        h.Test,
+				Interval:    h.Interval,
+				Timeout:     h.Timeout,
+				StartPeriod: h.StartPeriod,
+				Retries:     h.Retries,
+			}
+		}
+	}
+
+	var commands map[string]api.CommandOverride
+	if len(c.commands) > 0 {
+		commands = make(map[string]api.CommandOverride, len(c.commands))
+		for service, o := range c.commands {
+			commands[service] = api.CommandOverride{
+				Entrypoint: o.Entrypoint,
+				Command:    o.Command,
+			}
+		}
+	}
+
+	var readiness map[string]api.Readiness
+	if len(c.readiness) > 0 {
+		readiness = make(map[string]api.Readiness, len(c.readiness))
+		for service, r := range c.readiness {
+			readiness[service] = api.Readiness{
+				Port:    r.Port,
+				Command: r.Command,
+				Timeout: r.Timeout,
+			}
+		}
+	}
+
+	var buildx *api.Buildx
+	if c.buildx != nil {
+		buildx = &api.Buildx{
+			Cache:    c.buildx.Cache,
+			CacheRef: c.buildx.CacheRef,
+		}
+	}
+
+	var dependsOn *api.DependsOn
+	if c.dependsOn != nil {
+		dependsOn = &api.DependsOn{
+			Containers:  c.dependsOn.Containers,
+			WaitHealthy: c.dependsOn.WaitHealthy,
+			Timeout:     c.dependsOn.Timeout,
+		}
+	}
+	var static *api.Static
+	if c.static != nil {
+		static = &api.Static{
+			Image:           c.static.Image,
+			BuildCommand:    c.static.BuildCommand,
+			OutputDirectory: c.static.OutputDirectory,
+		}
+	}
+
+	var alerts *api.Alerts
+	if c.alerts != nil {
+		alerts = &api.Alerts{
+			WebhookURL:    c.alerts.WebhookURL,
+			MemoryPercent: c.alerts.MemoryPercent,
+			CPUPercent:    c.alerts.CPUPercent,
+			CPUMinutes:    c.alerts.CPUMinutes,
+			DiskPercent:   c.alerts.DiskPercent,
+		}
+	}
+
+	return &api.UpRequest{
 		Stream:        stream,
 		Project:       c.project,
 		BuildType:     buildType,
@@ -243,7 +464,32 @@ func (c *Client) Up(gitRemoteURL, buildType string, stream bool) (*http.Response
 			RemoteURL: common.GetSSHRemoteURL(gitRemoteURL),
 			Branch:    c.Branch,
 		},
-	})
+		ContainerUser:       c.containerUser,
+		EnforceNonRootUser:  c.enforceNonRootUser,
+		Platform:            c.platform,
+		BuildTarget:         c.buildTarget,
+		BuildArgs:           c.buildArgs,
+		WatchPaths:          c.watchPaths,
+		Env:                 c.deployEnv,
+		BaseImageCheckHours: c.baseImageCheck,
+		PollIntervalMinutes: c.pollInterval,
+		InitJob:             initJob,
+		Labels:              c.labels,
+		Resources:           resources,
+		Networking:          networking,
+		Healthchecks:        healthchecks,
+		Commands:            commands,
+		Readiness:           readiness,
+		Buildx:              buildx,
+		DependsOn:           dependsOn,
+		Static:              static,
+		CleanBuild:          c.cleanBuild,
+		CleanExclude:        c.cleanExclude,
+		RetainedDeploys:     c.retainedDeploys,
+		StopTimeout:         c.stopTimeout,
+		VerifyImagesKey:     verifyImagesKey,
+		Alerts:              alerts,
+	}, nil
 }
 
 // LogIn gets an access token for the user with the given credentials. Use ""
@@ -266,6 +512,42 @@ func (c *Client) Prune() (*http.Response, error) {
 	return c.post("/prune", nil)
 }
 
+// ReloadConfig asks the daemon to re-read its configuration and apply the
+// settings that can be changed without restarting it
+func (c *Client) ReloadConfig() (*http.Response, error) {
+	return c.post("/config/reload", nil)
+}
+
+// Fetch retrieves the latest commits on the deployed branch without deploying
+// them, and reports how they differ from the deployed commit
+func (c *Client) Fetch() (*http.Response, error) {
+	return c.post("/fetch", nil)
+}
+
+// Cancel aborts deployments that are in progress or waiting to be built, and
+// restores the previous deployment
+func (c *Client) Cancel() (*http.Response, error) {
+	return c.post("/cancel", nil)
+}
+
+// Rollback redeploys the retained deployment of the given commit without
+// rebuilding it. The previous deployment is used if commit is empty.
+func (c *Client) Rollback(commit string, stream bool) (*http.Response, error) {
+	return c.post("/rollback", &api.RollbackRequest{
+		Stream: stream,
+		Commit: commit,
+	})
+}
+
+// Recreate gracefully stops a single service of the deployed project and
+// starts a fresh container for it with the current configuration
+func (c *Client) Recreate(service string, stream bool) (*http.Response, error) {
+	return c.post("/recreate", &api.RecreateRequest{
+		Stream:  stream,
+		Service: service,
+	})
+}
+
 // Down brings the project down on the remote VPS instance specified
 // in the configuration object.
 func (c *Client) Down() (*http.Response, error) {
@@ -282,24 +564,128 @@ func (c *Client) Status() (*http.Response, error) {
 	return resp, err
 }
 
+// Info retrieves the versions of the daemon and Docker on the remote VPS
+// instance, as well as the build types the daemon supports
+func (c *Client) Info() (*http.Response, error) {
+	return c.get("/info", nil)
+}
+
 // Reset shuts down deployment and deletes the contents of the deployment's
 // project directory
 func (c *Client) Reset() (*http.Response, error) {
 	return c.post("/reset", nil)
 }
 
+// LogOptions denotes options for retrieving container logs
+type LogOptions struct {
+	// Container is the name of the container to fetch logs of, or a
+	// comma-separated list of containers whose logs are merged
+	Container string
+	Entries   int
+
+	// Cursor, if set, fetches only logs written after the cursor
+	Cursor string
+
+	// Since, if set, fetches only logs written since the given timestamp
+	// (RFC3339) or duration before now, such as "10m"
+	Since string
+
+	// Streams, if set, fetches only the given output streams (one of
+	// api.LogStreamsStdout, api.LogStreamsStderr, or api.LogStreamsBoth) and
+	// labels each line with the stream it came from
+	Streams string
+
+	// Previous, if set, fetches the saved logs of the container from before
+	// it was last replaced or stopped
+	Previous bool
+
+	// Download, if set, fetches logs as a plain text file to save
+	Download bool
+
+	// Plain, if set, removes ANSI escape sequences, such as color codes, from
+	// fetched logs
+	Plain bool
+
+	// Timezone, if set, is the tz database name of the timezone to display
+	// log timestamps in, such as "America/Vancouver"
+	Timezone string
+
+	// MaxLineLength, if set, is the length in bytes beyond which log lines
+	// are truncated
+	MaxLineLength int
+}
+
+// params builds the query parameters for a logs request
+func (o LogOptions) params() map[string]string {
+	params := map[string]string{api.Container: o.Container}
+	if o.Entries > 0 {
+		params[api.Entries] = strconv.Itoa(o.Entries)
+	}
+	if o.Cursor != "" {
+		params[api.Cursor] = o.Cursor
+	}
+	if o.Since != "" {
+		params[api.Since] = o.Since
+	}
+	if o.Streams != "" {
+		params[api.LogStreams] = o.Streams
+	}
+	if o.Previous {
+		params[api.Previous] = "true"
+	}
+	if o.Download {
+		params[api.Download] = "true"
+	}
+	if o.Plain {
+		params[api.Plain] = "true"
+	}
+	if o.Timezone != "" {
+		params[api.Timezone] = o.Timezone
+	}
+	if o.MaxLineLength > 0 {
+		params[api.MaxLineLength] = strconv.Itoa(o.MaxLineLength)
+	}
+	return params
+}
+
 // Logs get logs of given container
 func (c *Client) Logs(container string, entries int) (*http.Response, error) {
-	reqContent := map[string]string{api.Container: container}
+	return c.LogsWithOptions(LogOptions{Container: container, Entries: entries})
+}
+
+// LogsSince gets logs of given container written after the given cursor. The
+// cursor for the next request is provided in the api.HeaderLogCursor header
+// of the response.
+func (c *Client) LogsSince(container string, entries int, cursor string) (*http.Response, error) {
+	return c.LogsWithOptions(LogOptions{Container: container, Entries: entries, Cursor: cursor})
+}
+
+// LogsWithOptions gets logs of a container using the given options
+func (c *Client) LogsWithOptions(opts LogOptions) (*http.Response, error) {
+	return c.get("/logs", opts.params())
+}
+
+// History retrieves past deployment attempts on this remote, most recent first
+func (c *Client) History(entries, offset int) (*http.Response, error) {
+	reqContent := map[string]string{}
 	if entries > 0 {
 		reqContent[api.Entries] = strconv.Itoa(entries)
 	}
+	if offset > 0 {
+		reqContent[api.Offset] = strconv.Itoa(offset)
+	}
 
-	return c.get("/logs", reqContent)
+	return c.get("/history", reqContent)
 }
 
 // LogsWebSocket opens a websocket connection to given container's logs
 func (c *Client) LogsWebSocket(container string, entries int) (SocketReader, error) {
+	return c.LogsWebSocketWithOptions(LogOptions{Container: container, Entries: entries})
+}
+
+// LogsWebSocketWithOptions opens a websocket connection to a container's logs
+// using the given options
+func (c *Client) LogsWebSocketWithOptions(opts LogOptions) (SocketReader, error) {
 	host, err := url.Parse("https://" + c.RemoteVPS.GetIPAndPort())
 	if err != nil {
 		return nil, err
@@ -307,13 +693,8 @@ func (c *Client) LogsWebSocket(container string, entries int) (SocketReader, err
 
 	// Set up request
 	url := &url.URL{Scheme: "wss", Host: host.Host, Path: "/logs"}
-	params := map[string]string{
-		api.Container: container,
-		api.Stream:    "true",
-	}
-	if entries > 0 {
-		params[api.Entries] = strconv.Itoa(entries)
-	}
+	params := opts.params()
+	params[api.Stream] = "true"
 	encodeQuery(url, params)
 
 	// Set up authorization
@@ -328,6 +709,111 @@ func (c *Client) LogsWebSocket(container string, entries int) (SocketReader, err
 	return socket, nil
 }
 
+// EventsWebSocket opens a websocket connection to the remote's events feed,
+// which streams deployment events and state changes of project containers as
+// JSON-encoded api.Event messages
+func (c *Client) EventsWebSocket() (SocketReader, error) {
+	host, err := url.Parse("https://" + c.RemoteVPS.GetIPAndPort())
+	if err != nil {
+		return nil, err
+	}
+	url := &url.URL{Scheme: "wss", Host: host.Host, Path: "/events"}
+
+	// Set up authorization
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.Daemon.Token)
+
+	socket, resp, err := buildWebSocketDialer(c.verifySSL).Dial(url.String(), header)
+	if err == websocket.ErrBadHandshake {
+		return nil, fmt.Errorf("websocket handshake failed with status %d", resp.StatusCode)
+	} else if err != nil {
+		return nil, err
+	}
+	return socket, nil
+}
+
+// Backup downloads a snapshot of the remote's deployment database
+func (c *Client) Backup() (*http.Response, error) {
+	return c.get("/backup", nil)
+}
+
+// Restore replaces the remote's deployment database with the database backup
+// read from backup, as retrieved by Backup
+func (c *Client) Restore(backup io.Reader) (*http.Response, error) {
+	req, err := c.buildRequest("POST", "/backup", backup)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	client := buildHTTPSClient(c.verifySSL)
+	return client.Do(req)
+}
+
+// hostKeyFiles are the files on a remote, relative to its user's home
+// directory, that hold its GitHub deploy key and the key its deployment's
+// secrets are encrypted with, and the permissions they are written with
+var hostKeyFiles = []struct{ path, permissions string }{
+	{".ssh/id_rsa_inertia_deploy", "0600"},
+	{".ssh/id_rsa_inertia_deploy.pub", "0644"},
+	{".inertia/db.key", "0600"},
+}
+
+// HostKeys are the contents of a remote's deploy key and the key its
+// deployment's secrets are encrypted with, keyed by path, as read by
+// ReadHostKeys
+type HostKeys map[string][]byte
+
+// ReadHostKeys reads this remote's deploy key and the key its deployment's
+// secrets are encrypted with, so that they can be written to another remote
+// with WriteHostKeys even if this remote is no longer reachable by then
+func (c *Client) ReadHostKeys() (HostKeys, error) {
+	var keys = HostKeys{}
+	for _, file := range hostKeyFiles {
+		stdout, stderr, err := c.SSH.Run("cat " + file.path)
+		if err != nil {
+			if stderr != nil && stderr.Len() > 0 {
+				return nil, fmt.Errorf("failed to read %s: %s", file.path, stderr.String())
+			}
+			return nil, fmt.Errorf("failed to read %s: %s", file.path, err.Error())
+		}
+		var contents []byte
+		if stdout != nil {
+			contents = stdout.Bytes()
+		}
+		keys[file.path] = contents
+	}
+	return keys, nil
+}
+
+// WriteHostKeys writes keys read from another remote with ReadHostKeys to this
+// remote, so that it can take over the other remote's deployment without a new
+// deploy key being added to the repository. It must be run before this remote
+// is bootstrapped, which reuses existing keys - the deployment database can
+// then be copied with Backup and Restore.
+func (c *Client) WriteHostKeys(keys HostKeys) error {
+	for _, file := range hostKeyFiles {
+		contents, found := keys[file.path]
+		if !found {
+			return fmt.Errorf("missing %s", file.path)
+		}
+		if err := c.SSH.CopyFile(bytes.NewReader(contents), file.path, file.permissions); err != nil {
+			return fmt.Errorf("failed to copy %s: %s", file.path, err.Error())
+		}
+	}
+	return nil
+}
+
+// CopyHostKeys copies this remote's deploy key and the key its deployment's
+// secrets are encrypted with to the remote of dst - see WriteHostKeys
+func (c *Client) CopyHostKeys(dst *Client) error {
+	keys, err := c.ReadHostKeys()
+	if err != nil {
+		return err
+	}
+	return dst.WriteHostKeys(keys)
+}
+
 // UpdateEnv updates environment variable
 func (c *Client) UpdateEnv(name, value string, encrypt, remove bool) (*http.Response, error) {
 	return c.post("/env", api.EnvRequest{
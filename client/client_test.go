@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -172,6 +173,27 @@ func TestBootstrap(t *testing.T) {
 	assert.Equal(t, tokenScript, session.Calls[3])
 }
 
+func TestCopyHostKeys(t *testing.T) {
+	oldSession := &mockSSHRunner{}
+	newSession := &mockSSHRunner{}
+	oldClient := newMockSSHClient(oldSession)
+	newClient := newMockSSHClient(newSession)
+
+	err := oldClient.CopyHostKeys(newClient)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"cat .ssh/id_rsa_inertia_deploy",
+		"cat .ssh/id_rsa_inertia_deploy.pub",
+		"cat .inertia/db.key",
+	}, oldSession.Calls)
+	assert.Equal(t, []string{
+		".ssh/id_rsa_inertia_deploy 0600",
+		".ssh/id_rsa_inertia_deploy.pub 0644",
+		".inertia/db.key 0600",
+	}, newSession.Copies)
+	assert.Empty(t, newSession.Calls)
+}
+
 func TestUp(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -207,6 +229,81 @@ func TestUp(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestPreview(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request body
+		body, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		defer req.Body.Close()
+		var upReq api.UpRequest
+		err = json.Unmarshal(body, &upReq)
+		assert.Nil(t, err)
+		assert.True(t, upReq.DryRun)
+		assert.False(t, upReq.Stream)
+		assert.Equal(t, "myremote.git", upReq.GitOptions.RemoteURL)
+
+		// Check correct endpoint called
+		assert.Equal(t, "/up", req.URL.Path)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Preview("myremote.git", "docker-compose")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestUpRelease(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request body
+		var upReq api.UpRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&upReq))
+		defer req.Body.Close()
+		assert.Equal(t, "v1.2.3", upReq.Release)
+		assert.True(t, upReq.Stream)
+		assert.Equal(t, map[string]string{"DATABASE_URL": "postgres://db"}, upReq.Env)
+		assert.Equal(t, map[string]string{"VERSION": "1.2.3"}, upReq.BuildArgs)
+
+		// Check correct endpoint called
+		assert.Equal(t, "/up", req.URL.Path)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	d.SetDeployEnv(map[string]string{"DATABASE_URL": "postgres://db"})
+	d.SetBuildArgs(map[string]string{"VERSION": "1.2.3"})
+	resp, err := d.UpRelease("myremote.git", "docker-compose", "v1.2.3", true)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestUpCommit(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request body
+		var upReq api.UpRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&upReq))
+		defer req.Body.Close()
+		assert.Equal(t, "abcdefg", upReq.GitOptions.Commit)
+		assert.Equal(t, "myremote.git", upReq.GitOptions.RemoteURL)
+		assert.True(t, upReq.Stream)
+
+		// Check correct endpoint called
+		assert.Equal(t, "/up", req.URL.Path)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.UpCommit("myremote.git", "abcdefg", true)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestPrune(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -229,6 +326,106 @@ func TestPrune(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestCancel(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/cancel", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Cancel()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestReloadConfig(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/config/reload", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.ReloadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRollback(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/rollback", endpoint)
+
+		// Check request body
+		var rollbackReq api.RollbackRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&rollbackReq))
+		assert.Equal(t, "abcdefg", rollbackReq.Commit)
+		assert.True(t, rollbackReq.Stream)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Rollback("abcdefg", true)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestRecreate(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/recreate", endpoint)
+
+		// Check request body
+		var recreateReq api.RecreateRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&recreateReq))
+		assert.Equal(t, "web", recreateReq.Service)
+		assert.True(t, recreateReq.Stream)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Recreate("web", true)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
 func TestDown(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -251,6 +448,28 @@ func TestDown(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestFetch(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/fetch", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Fetch()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestStatus(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -273,6 +492,28 @@ func TestStatus(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestInfo(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "GET", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/info", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestStatusFail(t *testing.T) {
 	d := newMockClient(nil)
 	_, err := d.Status()
@@ -329,6 +570,64 @@ func TestLogs(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestLogsSince(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/logs", endpoint)
+
+		// Check body
+		defer req.Body.Close()
+		q := req.URL.Query()
+		assert.Equal(t, "docker-compose", q.Get(api.Container))
+		assert.Equal(t, "", q.Get(api.Entries))
+		assert.Equal(t, "2019-01-02T15:04:05Z", q.Get(api.Cursor))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.LogsSince("docker-compose", 0, "2019-01-02T15:04:05Z")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestLogsWithOptions(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check body
+		defer req.Body.Close()
+		q := req.URL.Query()
+		assert.Equal(t, "docker-compose", q.Get(api.Container))
+		assert.Equal(t, "5", q.Get(api.Entries))
+		assert.Equal(t, api.LogStreamsStderr, q.Get(api.LogStreams))
+		assert.Equal(t, "true", q.Get(api.Previous))
+		assert.Equal(t, "America/Vancouver", q.Get(api.Timezone))
+		assert.Equal(t, "200", q.Get(api.MaxLineLength))
+		assert.Equal(t, "10m", q.Get(api.Since))
+		assert.Equal(t, "true", q.Get(api.Download))
+		assert.Equal(t, "true", q.Get(api.Plain))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.LogsWithOptions(LogOptions{
+		Container:     "docker-compose",
+		Entries:       5,
+		Streams:       api.LogStreamsStderr,
+		Previous:      true,
+		Timezone:      "America/Vancouver",
+		MaxLineLength: 200,
+		Since:         "10m",
+		Download:      true,
+		Plain:         true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestLogsWebsocket(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		// Check request method
@@ -367,6 +666,38 @@ func TestLogsWebsocket(t *testing.T) {
 	assert.Equal(t, []byte("hello world"), m)
 }
 
+func TestEventsWebSocket(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Check request method
+		assert.Equal(t, "GET", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/events", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+
+		socketUpgrader := websocket.Upgrader{}
+		socket, err := socketUpgrader.Upgrade(rw, req, nil)
+		assert.Nil(t, err)
+
+		err = socket.WriteJSON(api.Event{Type: api.EventTypeContainer, Action: "die"})
+		assert.Nil(t, err)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.EventsWebSocket()
+	assert.Nil(t, err)
+
+	_, m, err := resp.ReadMessage()
+	assert.Nil(t, err)
+	var event api.Event
+	assert.Nil(t, json.Unmarshal(m, &event))
+	assert.Equal(t, "die", event.Action)
+}
+
 func TestUpdateEnv(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -550,3 +881,33 @@ func TestLogIn(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestBackupAndRestore(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/backup", endpoint)
+
+		if req.Method == "POST" {
+			defer req.Body.Close()
+			body, err := ioutil.ReadAll(req.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, "backup", string(body))
+			assert.Equal(t, "application/octet-stream", req.Header.Get("Content-Type"))
+		}
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Backup()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = d.Restore(bytes.NewBufferString("backup"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -23,6 +23,8 @@ func newIntegrationClient() *Client {
 		Daemon: &cfg.DaemonConfig{
 			Port: "4303",
 		},
+		// The test remote's host key changes whenever it is recreated
+		SSH: &cfg.SSHOptions{HostKeyChecking: cfg.HostKeyCheckingOff},
 	}
 	return &Client{
 		version:   "test",
@@ -49,6 +49,10 @@ else
     sudo docker load -i /daemon-image > /dev/null 2>&1
 fi
 
+# Docker's storage directory is mounted read-only so that the daemon can
+# check how much disk space is left for builds.
+DOCKER_ROOT=$(sudo docker info -f '{{.DockerRootDir}}')
+
 # Run container with access to the host docker socket and 
 # relevant host directories to allow for container control.
 # See the README for more details on how this works:
@@ -59,6 +63,7 @@ sudo docker run -d \
     -p "$DAEMON_PORT":"$CONTAINER_PORT" \
     -v /var/run/docker.sock:/var/run/docker.sock \
     -v "$HOME":/app/host \
+    -v "$DOCKER_ROOT":"$DOCKER_ROOT":ro \
     -e HOME="$HOME" \
     -e SSH_KNOWN_HOSTS='/app/host/.ssh/known_hosts' \
     --name "$DAEMON_NAME" \
@@ -5,14 +5,21 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/local"
 	"golang.org/x/crypto/ssh"
 )
 
+// sshRetryDelay is how long to wait between SSH connection attempts
+const sshRetryDelay = 2 * time.Second
+
 // SSHSession can run remote commands over SSH
 type SSHSession interface {
 	Run(cmd string) (*bytes.Buffer, *bytes.Buffer, error)
@@ -29,26 +36,40 @@ type SSHRunner struct {
 
 	pemPath       string
 	pemPassphrase string
+
+	timeout         time.Duration
+	retries         int
+	hostKeyChecking string
+	knownHostsFile  string
 }
 
 // NewSSHRunner returns a new SSHRunner
 func NewSSHRunner(r *cfg.RemoteVPS, keyPassphrase string) *SSHRunner {
-	if r != nil {
-		return &SSHRunner{
-			user:    r.User,
-			ip:      r.IP,
-			sshPort: r.SSHPort,
-
-			pemPath:       r.PEM,
-			pemPassphrase: keyPassphrase,
-		}
+	var runner = &SSHRunner{
+		timeout:         local.GetSSHTimeout(nil),
+		hostKeyChecking: local.GetHostKeyChecking(nil),
+		knownHostsFile:  local.GetKnownHostsFile(nil),
 	}
-	return &SSHRunner{}
+	if r == nil {
+		return runner
+	}
+	runner.user = r.User
+	runner.ip = r.IP
+	runner.sshPort = r.SSHPort
+	runner.pemPath = r.PEM
+	runner.pemPassphrase = keyPassphrase
+	runner.timeout = local.GetSSHTimeout(r.SSH)
+	runner.hostKeyChecking = local.GetHostKeyChecking(r.SSH)
+	runner.knownHostsFile = local.GetKnownHostsFile(r.SSH)
+	if r.SSH != nil && r.SSH.Retries > 0 {
+		runner.retries = r.SSH.Retries
+	}
+	return runner
 }
 
 // Run runs a command remotely.
 func (r *SSHRunner) Run(cmd string) (cmdout *bytes.Buffer, cmderr *bytes.Buffer, err error) {
-	session, err := getSSHSession(r.pemPath, r.ip, r.sshPort, r.user, r.pemPassphrase)
+	session, err := r.getSession()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -67,7 +88,7 @@ func (r *SSHRunner) Run(cmd string) (cmdout *bytes.Buffer, cmderr *bytes.Buffer,
 // RunStream remotely executes given command, streaming its output
 // and opening up an optionally interactive session
 func (r *SSHRunner) RunStream(cmd string, interactive bool) error {
-	session, err := getSSHSession(r.pemPath, r.ip, r.sshPort, r.user, r.pemPassphrase)
+	session, err := r.getSession()
 	if err != nil {
 		return err
 	}
@@ -91,6 +112,10 @@ func (r *SSHRunner) RunSession(commands ...string) error {
 		args   = append([]string{
 			"-p", r.sshPort,
 			"-i", r.pemPath,
+			"-o", "ConnectTimeout=" + strconv.Itoa(int(r.timeout.Seconds())),
+			"-o", "ConnectionAttempts=" + strconv.Itoa(r.retries+1),
+			"-o", "StrictHostKeyChecking=" + r.hostKeyChecking,
+			"-o", "UserKnownHostsFile=" + r.knownHostsFile,
 			target},
 			commands...)
 		cmd = exec.Command("ssh", args...)
@@ -113,7 +138,7 @@ func (r *SSHRunner) CopyFile(file io.Reader, remotePath string, permissions stri
 	// Set up
 	filename := filepath.Base(remotePath)
 	directory := filepath.Dir(remotePath)
-	session, err := getSSHSession(r.pemPath, r.ip, r.sshPort, r.user, r.pemPassphrase)
+	session, err := r.getSession()
 	if err != nil {
 		return err
 	}
@@ -131,24 +156,40 @@ func (r *SSHRunner) CopyFile(file io.Reader, remotePath string, permissions stri
 	return nil
 }
 
-// Stubbed out for testing.
-func getSSHSession(PEM, IP, sshPort, user, passphrase string) (*ssh.Session, error) {
-	privateKey, err := ioutil.ReadFile(PEM)
+// getSession connects to the remote, retrying failed connections as
+// configured, and creates a session. It is one session per command.
+func (r *SSHRunner) getSession() (*ssh.Session, error) {
+	privateKey, err := ioutil.ReadFile(r.pemPath)
 	if err != nil {
 		return nil, err
 	}
-
-	cfg, err := getSSHConfig(privateKey, user, passphrase)
+	cfg, err := getSSHConfig(privateKey, r.user, r.pemPassphrase)
 	if err != nil {
 		return nil, err
 	}
-
-	client, err := ssh.Dial("tcp", IP+":"+sshPort, cfg)
+	cfg.Timeout = r.timeout
+	verify, err := local.HostKeyCallback(r.hostKeyChecking, r.knownHostsFile)
 	if err != nil {
 		return nil, err
 	}
+	var keyErr error
+	cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		keyErr = verify(hostname, remote, key)
+		return keyErr
+	}
 
-	// Create a session. It is one session per command.
+	var client *ssh.Client
+	for attempt := 0; ; attempt++ {
+		client, err = ssh.Dial("tcp", net.JoinHostPort(r.ip, r.sshPort), cfg)
+		if err == nil {
+			break
+		}
+		// Rejected host keys will not be accepted on later attempts
+		if keyErr != nil || attempt >= r.retries {
+			return nil, err
+		}
+		time.Sleep(sshRetryDelay)
+	}
 	return client.NewSession()
 }
 
@@ -172,13 +213,8 @@ func getSSHConfig(privateKey []byte, user, passphrase string) (*ssh.ClientConfig
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(key),
 		},
-		// TODO: We need to replace this with a callback
-		// to verify the host key. A security vulnerability
-		// comes from the fact that we receive a public key
-		// from the server and we add it to our GitHub.
-		// This gives the server readonly access to our
-		// GitHub account. We need to know who we're
-		// connecting to.
+		// Host keys are verified by callers, since verification depends on
+		// the remote's configuration
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}, nil
 }
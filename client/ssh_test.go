@@ -3,14 +3,21 @@ package client
 import (
 	"bytes"
 	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/local"
 )
 
 // mockSSHRunner is a mocked out implementation of SSHSession
 type mockSSHRunner struct {
-	r     *cfg.RemoteVPS
-	Calls []string
+	r      *cfg.RemoteVPS
+	Calls  []string
+	Copies []string
 }
 
 func (runner *mockSSHRunner) Run(cmd string) (*bytes.Buffer, *bytes.Buffer, error) {
@@ -28,5 +35,25 @@ func (runner *mockSSHRunner) RunSession(commands ...string) error {
 }
 
 func (runner *mockSSHRunner) CopyFile(f io.Reader, remotePath string, permissions string) error {
+	runner.Copies = append(runner.Copies, remotePath+" "+permissions)
 	return nil
 }
+
+func TestNewSSHRunner(t *testing.T) {
+	var runner = NewSSHRunner(&cfg.RemoteVPS{IP: "127.0.0.1", SSHPort: "22"}, "")
+	assert.Equal(t, local.DefaultSSHTimeout, runner.timeout)
+	assert.Equal(t, 0, runner.retries)
+	assert.Equal(t, cfg.HostKeyCheckingAcceptNew, runner.hostKeyChecking)
+	assert.True(t, strings.HasSuffix(runner.knownHostsFile, filepath.Join(".ssh", "known_hosts")))
+
+	runner = NewSSHRunner(&cfg.RemoteVPS{SSH: &cfg.SSHOptions{
+		Timeout:         30,
+		Retries:         2,
+		HostKeyChecking: cfg.HostKeyCheckingStrict,
+		KnownHostsFile:  "/tmp/known_hosts",
+	}}, "")
+	assert.Equal(t, 30*time.Second, runner.timeout)
+	assert.Equal(t, 2, runner.retries)
+	assert.Equal(t, cfg.HostKeyCheckingStrict, runner.hostKeyChecking)
+	assert.Equal(t, "/tmp/known_hosts", runner.knownHostsFile)
+}
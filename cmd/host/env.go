@@ -22,8 +22,8 @@ func AttachEnvCmd(host *HostCmd) {
 			Short: "Manage environment variables on your remote",
 			Long: `Manages environment variables on your remote through Inertia. 
 			
-Configured variables can be encrypted or stored in plain text. They are applied
-as follows:
+Configured variables are encrypted when stored, and their values are never sent
+back from your remote. They are applied as follows:
 
 - for docker-compose projects, variables are set for the docker-compose process
 - for Dockerfile projects, variables are set in the deployed container
@@ -47,7 +47,7 @@ func (root *EnvCmd) attachSetCmd() {
 		Use:   "set [name] [value]",
 		Short: "Set an environment variable on your remote",
 		Long: `Sets a persistent environment variable on your remote. Set environment
-variables are applied to all deployed containers.`,
+variables are encrypted when stored and applied to all deployed containers.`,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			var encrypt, _ = cmd.Flags().GetBool(flagEncrypt)
@@ -64,6 +64,7 @@ variables are applied to all deployed containers.`,
 		},
 	}
 	set.Flags().BoolP(flagEncrypt, "e", false, "encrypt variable when stored")
+	set.Flags().MarkDeprecated(flagEncrypt, "variables are now always encrypted")
 	root.AddCommand(set)
 }
 
@@ -95,8 +96,7 @@ func (root *EnvCmd) attachListCmd() {
 	var list = &cobra.Command{
 		Use:   "ls",
 		Short: "List currently set and saved environment variables",
-		Long: `Lists currently set and saved environment variables. The values of encrypted
-variables are not be decrypted.`,
+		Long:  `Lists currently set and saved environment variables. Values are redacted.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			resp, err := root.host.client.ListEnv()
 			if err != nil {
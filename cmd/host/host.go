@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/ubclaunchpad/inertia/client"
 	inertiacmd "github.com/ubclaunchpad/inertia/cmd/cmd"
@@ -18,6 +20,7 @@ import (
 	"github.com/ubclaunchpad/inertia/common"
 	"github.com/ubclaunchpad/inertia/local"
 
+	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
 )
 
@@ -100,14 +103,23 @@ Run 'inertia [remote] init' to gather this information.`,
 	// attach children
 	host.attachInitCmd()
 	host.attachUpCmd()
+	host.attachCancelCmd()
+	host.attachRollbackCmd()
+	host.attachRecreateCmd()
 	host.attachDownCmd()
 	host.attachStatusCmd()
 	host.attachLogsCmd()
+	host.attachHistoryCmd()
+	host.attachEventsCmd()
+	host.attachFetchCmd()
 	AttachUserCmd(host)
 	AttachEnvCmd(host)
 	host.attachSendFileCmd()
 	host.attachSSHCmd()
 	host.attachPruneCmd()
+	host.attachReloadConfigCmd()
+	host.attachBackupCmd()
+	host.attachRestoreCmd()
 	host.attachTokenCmd()
 	host.attachUpgradeCmd()
 	host.attachUninstallCmd()
@@ -117,17 +129,56 @@ Run 'inertia [remote] init' to gather this information.`,
 }
 
 func (root *HostCmd) attachUpCmd() {
-	const flagBuildType = "type"
+	const (
+		flagBuildType = "type"
+		flagDryRun    = "dry-run"
+		flagRelease   = "release"
+		flagEnv       = "env"
+		flagBuildArg  = "build-arg"
+	)
 	var up = &cobra.Command{
 		Use:   "up",
 		Short: "Bring project online on remote",
 		Long: `Builds and deploy your project on your remote.
 
-This requires an Inertia daemon to be active on your remote - do this by running 'inertia [remote] init'`,
+This requires an Inertia daemon to be active on your remote - do this by running 'inertia [remote] init'
+
+Use the '--dry-run' flag to preview what a deployment would change without
+deploying.
+
+Use the '--release' flag to name the deployed commit, for example with a version
+such as 'v1.2.3'. Release names are shown in the remote's status and deployment
+history, and can be rolled back to with 'inertia [remote] rollback [release]'.
+
+Use the '--env' flag to start your project with an environment variable, for
+example '--env DATABASE_URL=postgres://db'. The flag can be repeated. These
+variables take precedence over those saved with 'inertia [remote] env set',
+and apply until the next time the project is brought up.
+
+Use the '--build-arg' flag to set a value for an ARG instruction in your
+project's Dockerfile, for example '--build-arg VERSION=1.2.3'. The flag can be
+repeated. Cached image layers are reused until the value of an argument they
+depend on changes, so changing a value rebuilds your image from the first
+instruction that uses it. docker-compose projects should set 'args' in their
+docker-compose file instead.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Get flags
 			var short, _ = cmd.Flags().GetBool(flagShort)
 			var buildType, _ = cmd.Flags().GetString(flagBuildType)
+			var dryRun, _ = cmd.Flags().GetBool(flagDryRun)
+			var release, _ = cmd.Flags().GetString(flagRelease)
+			var envVars, _ = cmd.Flags().GetStringArray(flagEnv)
+			env, err := parseNameValueFlags("environment variable", envVars)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			root.client.SetDeployEnv(env)
+			var buildArgVars, _ = cmd.Flags().GetStringArray(flagBuildArg)
+			buildArgs, err := parseNameValueFlags("build argument", buildArgVars)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			root.client.SetBuildArgs(buildArgs)
 
 			// TODO: support other remotes
 			url, err := local.GetRepoRemote("origin")
@@ -135,7 +186,15 @@ This requires an Inertia daemon to be active on your remote - do this by running
 				printutil.Fatal(err)
 			}
 
-			resp, err := root.client.Up(url, buildType, !short)
+			if dryRun {
+				root.previewUp(url, buildType)
+				return
+			}
+
+			// Warn about incompatibilities with the daemon before deploying
+			root.checkDaemonCompatibility(buildType)
+
+			resp, err := root.client.UpRelease(url, buildType, release, !short)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -153,6 +212,8 @@ This requires an Inertia daemon to be active on your remote - do this by running
 					fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
 				case http.StatusPreconditionFailed:
 					fmt.Printf("(Status code %d) Problem with deployment setup:\n%s\n", resp.StatusCode, body)
+				case http.StatusInsufficientStorage:
+					fmt.Printf("(Status code %d) Not enough disk space on remote:\n%s\n", resp.StatusCode, body)
 				default:
 					fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
 						resp.StatusCode, body)
@@ -170,9 +231,234 @@ This requires an Inertia daemon to be active on your remote - do this by running
 		},
 	}
 	up.Flags().String(flagBuildType, "", "override configured build method for your project")
+	up.Flags().Bool(flagDryRun, false, "preview changes without deploying")
+	up.Flags().String(flagRelease, "", "name for the deployed commit, such as a version")
+	up.Flags().StringArray(flagEnv, nil,
+		"environment variable to start your project with, as NAME=value (can be repeated)")
+	up.Flags().StringArray(flagBuildArg, nil,
+		"value for an ARG instruction in your Dockerfile, as NAME=value (can be repeated)")
 	root.AddCommand(up)
 }
 
+// parseNameValueFlags parses values of the given kind, such as environment
+// variables, in the form NAME=value
+func parseNameValueFlags(kind string, vars []string) (map[string]string, error) {
+	var env = make(map[string]string, len(vars))
+	for _, v := range vars {
+		var parts = strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid %s '%s' - must be NAME=value", kind, v)
+		}
+		env[parts[0]] = parts[1]
+	}
+	return env, nil
+}
+
+// previewUp prints a summary of the changes a deployment would make
+func (root *HostCmd) previewUp(url, buildType string) {
+	resp, err := root.client.Preview(url, buildType)
+	if err != nil {
+		printutil.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		printutil.Fatal(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var preview api.DeploymentPreview
+		if err := json.Unmarshal(body, &preview); err != nil {
+			printutil.Fatal(err)
+		}
+		fmt.Print(printutil.FormatDeploymentPreview(preview))
+	case http.StatusUnauthorized:
+		fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+	default:
+		fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+			resp.StatusCode, body)
+	}
+}
+
+// checkDaemonCompatibility warns if the daemon on this remote does not match
+// the configured Inertia version or does not support the given build type
+func (root *HostCmd) checkDaemonCompatibility(buildType string) {
+	resp, err := root.client.Info()
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Older daemons do not provide this information
+		return
+	}
+	var info = &api.DaemonInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return
+	}
+
+	if info.InertiaVersion != root.config.Version {
+		fmt.Printf("[WARNING] Daemon version '%s' does not match configured version '%s' - "+
+			"run 'inertia %s upgrade' to update your daemon\n",
+			info.InertiaVersion, root.config.Version, root.remote)
+	}
+	if buildType == "" {
+		buildType = root.config.BuildType
+	}
+	for _, t := range info.BuildTypes {
+		if strings.EqualFold(t, buildType) {
+			return
+		}
+	}
+	fmt.Printf("[WARNING] Daemon (Docker %s) does not support build type '%s' - supported types: %s\n",
+		info.DockerVersion, buildType, strings.Join(info.BuildTypes, ", "))
+}
+
+func (root *HostCmd) attachCancelCmd() {
+	var cancel = &cobra.Command{
+		Use:   "cancel",
+		Short: "Cancel an in-progress deployment on remote",
+		Long: `Cancels deployments of your project that are building or waiting to be
+built, for example if the wrong commit was pushed. Deployments can no longer be
+cancelled once the project is being started.
+
+If the project's containers were already stopped, the previous deployment is
+restarted from its images - this requires 'retained-deploys' to be set in your
+Inertia configuration. Otherwise, deploy again to bring the project back online.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.Cancel()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			switch resp.StatusCode {
+			case http.StatusOK:
+				fmt.Printf("(Status code %d) %s", resp.StatusCode, body)
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+			case http.StatusPreconditionFailed:
+				fmt.Printf("(Status code %d) Nothing to cancel: %s", resp.StatusCode, body)
+			default:
+				fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+					resp.StatusCode, body)
+			}
+		},
+	}
+	root.AddCommand(cancel)
+}
+
+func (root *HostCmd) attachRollbackCmd() {
+	var rollback = &cobra.Command{
+		Use:   "rollback [release|commit]",
+		Short: "Roll project back to a previous deployment on remote",
+		Long: `Redeploys a previous deployment of your project from its images, without
+rebuilding it. Deployments can be identified by release name, as given to
+'inertia [remote] up --release', or by commit. If neither is given, the
+deployment before the current one is used.
+
+This requires 'retained-deploys' to be set in your Inertia configuration - only
+that many of the most recent deployments can be rolled back to.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var short, _ = cmd.Flags().GetBool(flagShort)
+			var commit string
+			if len(args) > 0 {
+				commit = args[0]
+			}
+
+			resp, err := root.client.Rollback(commit, !short)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if short {
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				switch resp.StatusCode {
+				case http.StatusCreated:
+					fmt.Printf("(Status code %d) Project rolled back!\n", resp.StatusCode)
+				case http.StatusUnauthorized:
+					fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+				case http.StatusPreconditionFailed:
+					fmt.Printf("(Status code %d) Unable to roll back:\n%s\n", resp.StatusCode, body)
+				default:
+					fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+						resp.StatusCode, body)
+				}
+			} else {
+				reader := bufio.NewReader(resp.Body)
+				for {
+					line, err := reader.ReadBytes('\n')
+					if err != nil {
+						break
+					}
+					fmt.Print(string(line))
+				}
+			}
+		},
+	}
+	root.AddCommand(rollback)
+}
+
+func (root *HostCmd) attachRecreateCmd() {
+	var recreate = &cobra.Command{
+		Use:   "recreate [service]",
+		Short: "Recreate a single service's container on remote",
+		Long: `Gracefully stops the container of a single service of your deployed project and
+starts a fresh one with the current configuration, without rebuilding or
+redeploying the rest of the project. This is useful to pick up changed
+environment variables, or to unstick a wedged service.
+
+For docker-compose projects, the service is the docker-compose service name -
+for Dockerfile projects, it is the project name.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var short, _ = cmd.Flags().GetBool(flagShort)
+			resp, err := root.client.Recreate(args[0], !short)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if short {
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				switch resp.StatusCode {
+				case http.StatusCreated:
+					fmt.Printf("(Status code %d) Service recreated!\n", resp.StatusCode)
+				case http.StatusUnauthorized:
+					fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+				case http.StatusBadRequest, http.StatusPreconditionFailed:
+					fmt.Printf("(Status code %d) Unable to recreate service:\n%s\n", resp.StatusCode, body)
+				default:
+					fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+						resp.StatusCode, body)
+				}
+			} else {
+				reader := bufio.NewReader(resp.Body)
+				for {
+					line, err := reader.ReadBytes('\n')
+					if err != nil {
+						break
+					}
+					fmt.Print(string(line))
+				}
+			}
+		},
+	}
+	root.AddCommand(recreate)
+}
+
 func (root *HostCmd) attachDownCmd() {
 	var down = &cobra.Command{
 		Use:   "down",
@@ -252,28 +538,76 @@ Requires the Inertia daemon to be active on your remote - do this by running 'in
 }
 
 func (root *HostCmd) attachLogsCmd() {
-	const flagEntries = "entries"
+	const (
+		flagEntries  = "entries"
+		flagStreams  = "streams"
+		flagPrevious = "previous"
+		flagTimezone = "tz"
+		flagMaxLine  = "max-line-length"
+		flagSince    = "since"
+		flagPlain    = "plain"
+	)
 	var log = &cobra.Command{
-		Use:   "logs [container]",
+		Use:   "logs [containers...]",
 		Short: "Access logs of containers on your remote host",
 		Long: `Accesses logs of containers on your remote host.
 	
 By default, this command retrieves Inertia daemon logs, but you can provide an
 argument that specifies the name of the container you wish to retrieve logs for.
-Use 'inertia [remote] status' to see which containers are active.`,
+Use 'inertia [remote] status' to see which containers are active. If several
+containers are provided, their logs are merged and each line is prefixed with
+the name of the container it came from.
+
+Unless '--short' is set, logs are streamed. If the container crashes and is
+restarted by its restart policy, the stream notes the restart and continues
+with the logs of the restarted container.
+
+Use the '--streams' flag to retrieve only stdout or stderr, or 'both' to label
+each line with the stream it was written to.
+
+Use the '--previous' flag to retrieve the logs a container wrote before it was
+last replaced by a deployment or stopped.
+
+Use the '--tz' flag to display timestamps in a timezone other than UTC, for
+example '--tz America/Vancouver'.
+
+Use the '--max-line-length' flag to truncate lines longer than the given
+number of bytes, for example to keep minified JSON or encoded blobs from
+flooding your terminal.
+
+Use the '--since' flag to retrieve only logs written since a timestamp or a
+duration ago, for example '--since 10m' or '--since 2019-01-02T15:04:05Z'. If
+'--entries' is also set, only the last entries of those logs are retrieved.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			var short, _ = cmd.Flags().GetBool(flagShort)
 			var entries, _ = cmd.Flags().GetInt(flagEntries)
+			var streams, _ = cmd.Flags().GetString(flagStreams)
+			var previous, _ = cmd.Flags().GetBool(flagPrevious)
+			var timezone, _ = cmd.Flags().GetString(flagTimezone)
+			var maxLine, _ = cmd.Flags().GetInt(flagMaxLine)
+			var since, _ = cmd.Flags().GetString(flagSince)
+			var plain, _ = cmd.Flags().GetBool(flagPlain)
 
 			// get daemon logs by default
 			var container = "/inertia-daemon"
 			if len(args) > 0 {
-				container = args[0]
+				container = strings.Join(args, ",")
+			}
+			var opts = client.LogOptions{
+				Container:     container,
+				Entries:       entries,
+				Streams:       streams,
+				Previous:      previous,
+				Timezone:      timezone,
+				MaxLineLength: maxLine,
+				Since:         since,
+				Plain:         plain,
 			}
 
-			if short {
+			// logs of previous containers can't be streamed
+			if short || previous {
 				// if short, just grab the last x log entries
-				resp, err := root.client.Logs(container, entries)
+				resp, err := root.client.LogsWithOptions(opts)
 				if err != nil {
 					printutil.Fatal(err)
 				}
@@ -296,7 +630,7 @@ Use 'inertia [remote] status' to see which containers are active.`,
 				}
 			} else {
 				// if not short, open a websocket to stream logs
-				socket, err := root.client.LogsWebSocket(container, entries)
+				socket, err := root.client.LogsWebSocketWithOptions(opts)
 				if err != nil {
 					printutil.Fatal(err)
 				}
@@ -305,6 +639,12 @@ Use 'inertia [remote] status' to see which containers are active.`,
 				for {
 					_, line, err := socket.ReadMessage()
 					if err != nil {
+						// The daemon reports why it ended the stream
+						if closeErr, ok := err.(*websocket.CloseError); ok &&
+							closeErr.Code != websocket.CloseAbnormalClosure {
+							fmt.Println(closeErr.Text)
+							return
+						}
 						printutil.Fatal(err)
 					}
 					fmt.Print(string(line))
@@ -313,9 +653,205 @@ Use 'inertia [remote] status' to see which containers are active.`,
 		},
 	}
 	log.Flags().Int(flagEntries, 0, "Number of log entries to fetch")
+	log.Flags().String(flagStreams, "",
+		"Output streams to fetch and label (one of 'stdout', 'stderr', or 'both')")
+	log.Flags().Bool(flagPrevious, false,
+		"Fetch logs from before the container was last replaced or stopped")
+	log.Flags().String(flagTimezone, "",
+		"Timezone to display timestamps in, from the tz database (default UTC)")
+	log.Flags().Int(flagMaxLine, 0,
+		"Truncate log lines longer than this many bytes (default no truncation)")
+	log.Flags().Bool(flagPlain, false,
+		"Remove ANSI escape sequences, such as colors, from logs")
+	log.Flags().String(flagSince, "",
+		"Fetch only logs since a timestamp (RFC3339) or a duration ago, such as 10m")
 	root.AddCommand(log)
 }
 
+func (root *HostCmd) attachHistoryCmd() {
+	const (
+		flagEntries = "entries"
+		flagOffset  = "offset"
+	)
+	var history = &cobra.Command{
+		Use:   "history",
+		Short: "Print past deployments on your remote",
+		Long: `Prints a history of deployment attempts on your remote, most recent first,
+including the deployed commit, the outcome, and when the deployment happened.
+
+Use the '--entries' and '--offset' flags to page through older deployments.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var entries, _ = cmd.Flags().GetInt(flagEntries)
+			var offset, _ = cmd.Flags().GetInt(flagOffset)
+
+			resp, err := root.client.History(entries, offset)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				var records = []api.DeploymentRecord{}
+				if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Print(printutil.FormatDeploymentHistory(records))
+			case http.StatusUnauthorized:
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+			default:
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+					resp.StatusCode, body)
+			}
+		},
+	}
+	history.Flags().Int(flagEntries, 10, "number of deployments to show")
+	history.Flags().Int(flagOffset, 0, "number of most recent deployments to skip")
+	root.AddCommand(history)
+}
+
+func (root *HostCmd) attachEventsCmd() {
+	const flagJSON = "json"
+	var events = &cobra.Command{
+		Use:   "events",
+		Short: "Stream deployment and container events from your remote",
+		Long: `Streams events from your remote as they happen, such as deployments starting
+and finishing, and project containers starting, stopping, dying, or changing
+health.
+
+Use the '--json' flag to print each event as JSON instead.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var printJSON, _ = cmd.Flags().GetBool(flagJSON)
+			socket, err := root.client.EventsWebSocket()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer socket.Close()
+
+			for {
+				_, message, err := socket.ReadMessage()
+				if err != nil {
+					// The daemon reports why it ended the stream
+					if closeErr, ok := err.(*websocket.CloseError); ok &&
+						closeErr.Code != websocket.CloseAbnormalClosure {
+						fmt.Println(closeErr.Text)
+						return
+					}
+					printutil.Fatal(err)
+				}
+				if printJSON {
+					fmt.Println(string(message))
+					continue
+				}
+				var event api.Event
+				if err := json.Unmarshal(message, &event); err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Print(printutil.FormatEvent(event))
+			}
+		},
+	}
+	events.Flags().Bool(flagJSON, false, "print events as JSON")
+	root.AddCommand(events)
+}
+
+func (root *HostCmd) attachReloadConfigCmd() {
+	var reload = &cobra.Command{
+		Use:   "reload-config",
+		Short: "Reload the daemon's configuration on your remote",
+		Long: `Makes the daemon on your remote re-read its configuration and apply the
+settings that can be changed while it is running, such as limits, without
+restarting it. Active deployments are completed first.
+
+Daemon settings can be changed by editing the 'daemon.env' file in the daemon's
+data directory on your remote, which contains KEY=VALUE lines such as
+'INERTIA_MAX_CONCURRENT_BUILDS=2'. Settings that only take effect after a
+restart are reported.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.ReloadConfig()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				var result api.ConfigReload
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					printutil.Fatal(err)
+				}
+				if len(result.Applied) == 0 && len(result.RequiresRestart) == 0 {
+					fmt.Println("Configuration reloaded - no settings changed")
+					return
+				}
+				fmt.Println("Configuration reloaded")
+				for _, setting := range result.Applied {
+					fmt.Printf("  applied: %s\n", setting)
+				}
+				for _, setting := range result.RequiresRestart {
+					fmt.Printf("  requires restart: %s\n", setting)
+				}
+			case http.StatusUnauthorized:
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+			default:
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+					resp.StatusCode, body)
+			}
+		},
+	}
+	root.AddCommand(reload)
+}
+
+func (root *HostCmd) attachFetchCmd() {
+	var fetch = &cobra.Command{
+		Use:   "fetch",
+		Short: "Check for new commits to deploy",
+		Long: `Fetches the latest commits on your deployed branch without building or
+deploying them, and lists the commits that have not been deployed yet.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.Fetch()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				var preview api.DeploymentPreview
+				if err := json.Unmarshal(body, &preview); err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Print(printutil.FormatFetchResult(preview))
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+			default:
+				fmt.Printf("(Status code %d) %s\n", resp.StatusCode, body)
+			}
+		},
+	}
+	root.AddCommand(fetch)
+}
+
 func (root *HostCmd) attachPruneCmd() {
 	var prune = &cobra.Command{
 		Use:   "prune",
@@ -337,6 +873,79 @@ func (root *HostCmd) attachPruneCmd() {
 	root.AddCommand(prune)
 }
 
+func (root *HostCmd) attachBackupCmd() {
+	var backup = &cobra.Command{
+		Use:   "backup [file]",
+		Short: "Download a backup of your remote's deployment database",
+		Long: `Downloads a snapshot of your remote's deployment database, which contains
+deployment history and environment variables, to the given file.
+
+The backup includes the key your remote encrypts environment variables with,
+so that they can be recovered by restoring the backup to any remote - keep it
+as secret as the variables themselves. Use 'inertia [remote] restore' to
+restore a backup.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.Backup()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				printutil.Fatalf("(Status code %d) Failed to back up database:\n%s\n",
+					resp.StatusCode, body)
+			}
+
+			file, err := os.OpenFile(args[0], os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer file.Close()
+			written, err := io.Copy(file, resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Printf("Database backup (%d bytes) saved to %s\n", written, args[0])
+		},
+	}
+	root.AddCommand(backup)
+}
+
+func (root *HostCmd) attachRestoreCmd() {
+	var restore = &cobra.Command{
+		Use:   "restore [file]",
+		Short: "Restore your remote's deployment database from a backup",
+		Long: `Replaces the contents of your remote's deployment database with a backup
+created by 'inertia [remote] backup', which can be from any remote, such as
+one that has been lost. Backups larger than the remote's payload size limit
+(INERTIA_MAX_PAYLOAD_SIZE_MB) are rejected.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			file, err := os.Open(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer file.Close()
+
+			resp, err := root.client.Restore(file)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Printf("(Status code %d) %s\n", resp.StatusCode, body)
+		},
+	}
+	root.AddCommand(restore)
+}
+
 func (root *HostCmd) attachSSHCmd() {
 	var ssh = &cobra.Command{
 		Use:   "ssh",
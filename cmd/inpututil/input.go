@@ -100,6 +100,7 @@ func AddProjectWalkthrough(in io.Reader) (
 	println("Please enter the build type of your project - this could be one of:")
 	println("  - docker-compose")
 	println("  - dockerfile")
+	println("  - static")
 
 	var response string
 	_, err := fmt.Fscanln(in, &response)
@@ -2,6 +2,7 @@ package printutil
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/cfg"
@@ -11,6 +12,8 @@ const (
 	msgBuildInProgress    = "It appears that your build is still in progress."
 	msgNoContainersActive = "No containers are active."
 	msgNoDeployment       = "No deployment found - try running 'inertia [remote] up'"
+	msgNoHistory          = "No deployments have been recorded yet."
+	msgSitePublished      = "Site is published."
 )
 
 // FormatStatus prints the given deployment status
@@ -26,6 +29,13 @@ func FormatStatus(s *api.DeploymentStatus) string {
 	// If no branch/commit, then it's likely the deployment has not
 	// been instantiated on the remote yet
 	var statusString = inertiaStatus + branchStatus + commitStatus + commitMessage + buildTypeStatus
+	if s.Release != "" {
+		statusString += " - Release:    " + s.Release + "\n"
+	}
+	if s.QueuedBuilds > 0 {
+		statusString += fmt.Sprintf(" - Builds:     %d active, %d queued\n",
+			s.ActiveBuilds, s.QueuedBuilds)
+	}
 	if s.Branch == "" && s.CommitHash == "" && s.CommitMessage == "" {
 		return statusString + msgNoDeployment
 	}
@@ -33,6 +43,9 @@ func FormatStatus(s *api.DeploymentStatus) string {
 	// If build container is active, that means that a build
 	// attempt was made but only the daemon and docker-compose
 	// are active, indicating a build failure or build-in-progress
+	if len(s.Containers) == 0 && s.SitePublished {
+		return statusString + msgSitePublished
+	}
 	if len(s.Containers) == 0 {
 		errorString := statusString + msgNoContainersActive
 		if s.BuildContainerActive {
@@ -49,6 +62,110 @@ func FormatStatus(s *api.DeploymentStatus) string {
 	return statusString
 }
 
+// FormatDeploymentHistory prints the given deployment records
+func FormatDeploymentHistory(records []api.DeploymentRecord) string {
+	if len(records) == 0 {
+		return msgNoHistory
+	}
+
+	historyString := "Deployment history:\n"
+	for _, r := range records {
+		var status = "SUCCESS"
+		if !r.Success {
+			status = "FAILED"
+		}
+		var commit = shortHash(r.CommitHash)
+		if r.Release != "" {
+			commit += " " + r.Release
+		}
+		historyString += fmt.Sprintf(" - [%s] %s %s (%s) by %s in %s\n",
+			status, r.StartedAt.Format("2006-01-02 15:04:05"), commit, r.Branch,
+			r.Initiator, r.Duration.Round(time.Second))
+		if r.Error != "" {
+			historyString += fmt.Sprintf("   %s\n", r.Error)
+		}
+	}
+	return historyString
+}
+
+// FormatEvent prints the given event from the events feed on a single line
+func FormatEvent(e api.Event) string {
+	var eventString = fmt.Sprintf("%s [%s] ", e.Time.Format("2006-01-02 15:04:05"), e.Type)
+	switch e.Type {
+	case api.EventTypeDeploy:
+		eventString += fmt.Sprintf("deployment by %s %s", e.Initiator, e.Action)
+		if e.CommitHash != "" {
+			eventString += fmt.Sprintf(" at %s", shortHash(e.CommitHash))
+		}
+		if e.Error != "" {
+			eventString += fmt.Sprintf(": %s", e.Error)
+		}
+	default:
+		eventString += fmt.Sprintf("%s: %s", e.Container, e.Action)
+	}
+	return eventString + "\n"
+}
+
+// FormatDeploymentPreview prints the given deployment preview
+func FormatDeploymentPreview(preview api.DeploymentPreview) string {
+	previewString := fmt.Sprintf("Deployment preview for branch %s:\n", preview.Branch)
+	switch {
+	case preview.CurrentCommit == "":
+		previewString += " - Project will be cloned and deployed for the first time\n"
+	case preview.TargetCommit == preview.CurrentCommit:
+		previewString += fmt.Sprintf(" - No new commits - %s is already deployed\n",
+			shortHash(preview.CurrentCommit))
+	default:
+		previewString += fmt.Sprintf(" - Commit will change from %s to %s:\n",
+			shortHash(preview.CurrentCommit), shortHash(preview.TargetCommit))
+		for _, c := range preview.NewCommits {
+			previewString += fmt.Sprintf("     %s\n", c)
+		}
+	}
+
+	if len(preview.ConfigChanges) == 0 {
+		previewString += " - No configuration changes\n"
+	} else {
+		previewString += " - Configuration changes:\n"
+		for _, c := range preview.ConfigChanges {
+			previewString += fmt.Sprintf("     %s\n", c)
+		}
+	}
+
+	previewString += " - Project images will be rebuilt\n"
+	if len(preview.Containers) > 0 {
+		previewString += " - Containers that will be recreated:\n"
+		for _, c := range preview.Containers {
+			previewString += fmt.Sprintf("     %s\n", c)
+		}
+	}
+	return previewString
+}
+
+// FormatFetchResult prints the commits on the deployed branch that have not
+// been deployed yet
+func FormatFetchResult(preview api.DeploymentPreview) string {
+	if preview.TargetCommit == "" || preview.TargetCommit == preview.CurrentCommit {
+		return fmt.Sprintf("Branch %s is up to date - %s is deployed\n",
+			preview.Branch, shortHash(preview.CurrentCommit))
+	}
+	fetchString := fmt.Sprintf("Branch %s has new commits since %s was deployed:\n",
+		preview.Branch, shortHash(preview.CurrentCommit))
+	for _, c := range preview.NewCommits {
+		fetchString += fmt.Sprintf("     %s\n", c)
+	}
+	fetchString += "Run 'inertia [remote] up' to deploy them\n"
+	return fetchString
+}
+
+// shortHash abbreviates the given commit hash
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
 // FormatRemoteDetails prints the given remote configuration
 func FormatRemoteDetails(remote *cfg.RemoteVPS) string {
 	remoteString := fmt.Sprintf("Remote %s: \n", remote.Name)
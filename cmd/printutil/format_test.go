@@ -2,6 +2,7 @@ package printutil
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/ubclaunchpad/inertia/api"
@@ -14,10 +15,12 @@ func TestFormatStatus(t *testing.T) {
 		Branch:               "call",
 		CommitHash:           "me",
 		CommitMessage:        "maybe",
+		Release:              "v1.2.3",
 		BuildContainerActive: true,
 		Containers:           []string{"wow"},
 	})
 	assert.Contains(t, output, "inertia daemon 9000")
+	assert.Contains(t, output, "Release:    v1.2.3")
 	assert.Contains(t, output, "Active containers")
 }
 
@@ -34,6 +37,19 @@ func TestFormatStatusBuildActive(t *testing.T) {
 	assert.Contains(t, output, msgBuildInProgress)
 }
 
+func TestFormatStatusQueuedBuilds(t *testing.T) {
+	output := FormatStatus(&api.DeploymentStatus{
+		InertiaVersion: "9000",
+		Branch:         "call",
+		CommitHash:     "me",
+		CommitMessage:  "maybe",
+		Containers:     []string{"wow"},
+		ActiveBuilds:   1,
+		QueuedBuilds:   2,
+	})
+	assert.Contains(t, output, "1 active, 2 queued")
+}
+
 func TestFormatStatusNoDeployment(t *testing.T) {
 	output := FormatStatus(&api.DeploymentStatus{
 		InertiaVersion:       "9000",
@@ -47,6 +63,100 @@ func TestFormatStatusNoDeployment(t *testing.T) {
 	assert.Contains(t, output, msgNoDeployment)
 }
 
+func TestFormatDeploymentHistory(t *testing.T) {
+	output := FormatDeploymentHistory([]api.DeploymentRecord{
+		{
+			Initiator:  "bob",
+			Branch:     "master",
+			CommitHash: "abcdefghijk",
+			Success:    true,
+			Duration:   time.Minute,
+		},
+		{
+			Initiator: "master",
+			Branch:    "dev",
+			Error:     "build failed",
+		},
+		{
+			Initiator:  "alice",
+			Branch:     "master",
+			CommitHash: "1234567890",
+			Release:    "v1.2.3",
+			Success:    true,
+		},
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+	assert.Contains(t, output, "abcdefg (master) by bob in 1m0s")
+	assert.NotContains(t, output, "abcdefgh")
+	assert.Contains(t, output, "[FAILED]")
+	assert.Contains(t, output, "build failed")
+	assert.Contains(t, output, "1234567 v1.2.3 (master) by alice")
+}
+
+func TestFormatDeploymentHistoryEmpty(t *testing.T) {
+	output := FormatDeploymentHistory([]api.DeploymentRecord{})
+	assert.Contains(t, output, msgNoHistory)
+}
+
+func TestFormatEvent(t *testing.T) {
+	output := FormatEvent(api.Event{
+		Type:       api.EventTypeDeploy,
+		Action:     api.EventDeployFailed,
+		Initiator:  "bob",
+		CommitHash: "abcdefghijk",
+		Error:      "build failed",
+	})
+	assert.Contains(t, output, "[deploy] deployment by bob failed at abcdefg: build failed")
+
+	output = FormatEvent(api.Event{
+		Type:      api.EventTypeContainer,
+		Action:    "health_status: unhealthy",
+		Container: "web",
+	})
+	assert.Contains(t, output, "[container] web: health_status: unhealthy")
+}
+
+func TestFormatDeploymentPreview(t *testing.T) {
+	output := FormatDeploymentPreview(api.DeploymentPreview{
+		Branch:        "master",
+		CurrentCommit: "abcdefghijk",
+		TargetCommit:  "1234567890",
+		NewCommits:    []string{"1234567 Fix everything"},
+		ConfigChanges: []string{"environment variables changed"},
+		Containers:    []string{"/web"},
+	})
+	assert.Contains(t, output, "abcdefg to 1234567")
+	assert.Contains(t, output, "Fix everything")
+	assert.Contains(t, output, "environment variables changed")
+	assert.Contains(t, output, "/web")
+
+	output = FormatDeploymentPreview(api.DeploymentPreview{
+		Branch:        "master",
+		CurrentCommit: "abcdefghijk",
+		TargetCommit:  "abcdefghijk",
+	})
+	assert.Contains(t, output, "No new commits")
+	assert.Contains(t, output, "No configuration changes")
+}
+
+func TestFormatFetchResult(t *testing.T) {
+	output := FormatFetchResult(api.DeploymentPreview{
+		Branch:        "master",
+		CurrentCommit: "abcdefghijk",
+		TargetCommit:  "1234567890",
+		NewCommits:    []string{"1234567 Fix everything"},
+	})
+	assert.Contains(t, output, "new commits since abcdefg")
+	assert.Contains(t, output, "Fix everything")
+
+	output = FormatFetchResult(api.DeploymentPreview{
+		Branch:        "master",
+		CurrentCommit: "abcdefghijk",
+		TargetCommit:  "abcdefghijk",
+	})
+	assert.Contains(t, output, "up to date")
+}
+
 func TestFormatRemoteDetails(t *testing.T) {
 	client := &cfg.RemoteVPS{
 		Name:   "bob",
@@ -0,0 +1,153 @@
+package provisioncmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/cmd/printutil"
+	"github.com/ubclaunchpad/inertia/local"
+)
+
+func (root *ProvisionCmd) attachDestroyCmd() {
+	const flagKeepKey = "keep-key"
+	var destroy = &cobra.Command{
+		Use:   "destroy [remote]",
+		Short: "[BETA] Terminate a provisioned remote's instance and remove the remote",
+		Long: `[BETA] Terminates the instance of a remote provisioned with 'inertia provision'
+and removes the remote from your Inertia configuration.
+
+If the remote's key pair was generated when it was provisioned, the key pair is
+deleted and its private key is removed from your machine, unless --keep-key is
+set. Key pairs that were reused from elsewhere are always kept, as is the
+remote's security group.
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var config = root.config
+			remote, found := config.GetRemote(args[0])
+			if !found {
+				printutil.Fatal("remote not found")
+			}
+			if remote.Resources == nil || remote.Resources.Provider != "ec2" {
+				printutil.Fatal("only remotes provisioned on ec2 can be destroyed")
+			}
+			var keepKey, _ = cmd.Flags().GetBool(flagKeepKey)
+
+			fmt.Printf("WARNING: This will terminate instance %s of remote '%s' at %s.\n",
+				remote.Resources.InstanceID, remote.Name, remote.IP)
+			println("This is irreversible. Continue? (y/n)")
+			var response string
+			if _, err := fmt.Scanln(&response); err != nil || response != "y" {
+				printutil.Fatal("aborting")
+			}
+
+			prov, err := newEC2Provisioner(cmd, remote.User)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if !keepKey && remote.Resources.ExistingKeyPair {
+				fmt.Printf("Key pair %s was not generated by Inertia and will be kept\n",
+					remote.Resources.KeyPairName)
+			} else if !keepKey && keyInUse(config.Remotes, remote.Name, remote.PEM) {
+				// Remotes sharing this key would be locked out
+				fmt.Printf("Key %s is used by another remote and will be kept\n", remote.PEM)
+				keepKey = true
+			}
+			if err = prov.Destroy(remote, keepKey); err != nil {
+				printutil.Fatal(err)
+			}
+
+			config.RemoveRemote(remote.Name)
+			if err = config.Write(root.cfgPath); err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Printf("Remote '%s' has been destroyed\n", remote.Name)
+		},
+	}
+	destroy.Flags().Bool(flagKeepKey, false,
+		"keep the remote's key pair and private key")
+	addEC2CredentialFlags(destroy)
+	root.AddCommand(destroy)
+}
+
+// keyInUse checks if any remote other than the named one uses the key at path
+func keyInUse(remotes map[string]*cfg.RemoteVPS, name, path string) bool {
+	for _, r := range remotes {
+		if r.Name != name && r.PEM == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (root *ProvisionCmd) attachKeysCmd() {
+	const (
+		flagPrune  = "prune"
+		flagKeep   = "keep"
+		flagKeyDir = "key-directory"
+	)
+	var keys = &cobra.Command{
+		Use:   "keys",
+		Short: "List and clean up SSH keys generated by 'inertia provision'",
+		Long: `Lists the SSH keys generated for this project by 'inertia provision', most
+recently generated first, along with the remotes that use them.
+
+Use --prune to remove all but the most recent --keep keys that are not used by
+any remote in your Inertia configuration. Keys are only removed from your
+machine - their key pairs remain registered with your provider.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var prune, _ = cmd.Flags().GetBool(flagPrune)
+			var keep, _ = cmd.Flags().GetInt(flagKeep)
+			if keep < 0 {
+				printutil.Fatal("--keep must not be negative")
+			}
+
+			var users = map[string]string{}
+			var inUse = map[string]bool{}
+			for _, r := range root.config.Remotes {
+				users[r.PEM] = r.Name
+				inUse[r.PEM] = true
+			}
+			var dir, _ = cmd.Flags().GetString(flagKeyDir)
+			if dir == "" {
+				dir = local.GetKeyDirectory()
+			}
+			var prefix = root.config.Project + "_"
+			if prune {
+				removed, err := local.PruneGeneratedKeys(dir, prefix, keep, inUse)
+				for _, path := range removed {
+					fmt.Printf("Removed %s\n", path)
+				}
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("%d keys removed\n", len(removed))
+				return
+			}
+
+			generated, err := local.ListGeneratedKeys(dir, prefix)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if len(generated) == 0 {
+				fmt.Println("No generated keys found in " + dir)
+				return
+			}
+			for _, k := range generated {
+				var usedBy = "unused"
+				if name, ok := users[k.Path]; ok {
+					usedBy = "used by " + name
+				}
+				fmt.Printf("%s (%s, %s)\n", k.Path, k.Created.Format("2006-01-02 15:04"), usedBy)
+			}
+		},
+	}
+	keys.Flags().Bool(flagPrune, false, "remove unused keys")
+	keys.Flags().Int(flagKeep, 3, "number of unused keys to keep when pruning")
+	keys.Flags().String(flagKeyDir, "",
+		"directory generated keys were saved in (default ~/.ssh)")
+	root.AddCommand(keys)
+}
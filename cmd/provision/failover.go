@@ -0,0 +1,185 @@
+package provisioncmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/client"
+	"github.com/ubclaunchpad/inertia/cmd/printutil"
+	"github.com/ubclaunchpad/inertia/local"
+	"github.com/ubclaunchpad/inertia/provision"
+)
+
+func (root *ProvisionCmd) attachFailoverCmd() {
+	const (
+		flagImage    = "image"
+		flagInterval = "interval"
+	)
+	var failover = &cobra.Command{
+		Use:   "failover [remote]",
+		Short: "[BETA] Replace a remote's spot instance when it is interrupted",
+		Long: `[BETA] Watches a remote provisioned on spot capacity with 'inertia provision ec2 --spot'
+for interruption notices, and moves its deployment to a replacement instance
+when one is received.
+
+Spot instances are given two minutes' notice before they are interrupted. Once
+the remote's daemon reports a notice, the remote's deploy key, secrets, and
+deployment database are copied while it is still reachable, and a replacement
+spot instance is created from the remote's image - on-demand capacity is used
+instead if no spot capacity is available. The deployed commit is then deployed
+to the replacement, and once it is verified to be running with healthy
+containers, the remote's Elastic IP address and the DNS record set with
+--dns-zone and --dns-record, if any, are moved to the replacement and the
+remote is updated to point to it.
+
+The interrupted instance shuts its project down shortly before it is
+reclaimed, so the project is unavailable until the replacement is deployed.
+This command keeps watching each spot replacement in turn until it is stopped,
+so run it somewhere other than the remote, such as a CI runner or another
+server.
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var config = root.config
+			remote, found := config.GetRemote(args[0])
+			if !found {
+				printutil.Fatal("remote not found")
+			}
+			if remote.Resources == nil || remote.Resources.Provider != "ec2" ||
+				!remote.Resources.Spot {
+				printutil.Fatal("only remotes provisioned on ec2 spot capacity can fail over")
+			}
+			var image, _ = cmd.Flags().GetString(flagImage)
+			if image == "" {
+				image = remote.Resources.ImageID
+			}
+			if image == "" {
+				printutil.Fatalf("remote has no recorded image - set one with --%s", flagImage)
+			}
+			var interval, _ = cmd.Flags().GetDuration(flagInterval)
+			var dns = getDNSRecord(cmd)
+			var passphrase = os.Getenv(local.EnvSSHPassphrase)
+
+			prov, err := newEC2Provisioner(cmd, remote.User)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer prov.WaitForTraces()
+
+			for {
+				current, found := client.NewClient(remote.Name, passphrase, config, os.Stdout)
+				if !found {
+					printutil.Fatal("remote not found")
+				}
+				var replacement *cfg.RemoteVPS
+				var watcher = &spotFailover{
+					interval: interval,
+					status: func() (*api.DeploymentStatus, error) {
+						return getDeploymentStatus(current)
+					},
+					snapshot: func(status *api.DeploymentStatus) (*deploymentSnapshot, error) {
+						return snapshotDeployment(current, status)
+					},
+					replace: func(snapshot *deploymentSnapshot) error {
+						var err error
+						replacement, err = createFailoverReplacement(prov, config, remote, image)
+						if err != nil {
+							return err
+						}
+						if err = restoreDeployment(snapshot, replacement, config, passphrase); err != nil {
+							if err := prov.TerminateInstance(replacement.Resources.Region,
+								replacement.Resources.InstanceID); err != nil {
+								fmt.Printf("Failed to terminate replacement instance %s: %s\n",
+									replacement.Resources.InstanceID, err.Error())
+							}
+							return err
+						}
+
+						// The interrupted instance is about to be reclaimed, so
+						// the replacement is kept even if addresses can't move
+						if err = moveAddresses(prov, remote, replacement, dns); err != nil {
+							fmt.Printf("[WARNING] %s - allocation %s must be associated manually\n",
+								err.Error(), remote.Resources.ElasticIPAllocationID)
+						}
+						return nil
+					},
+				}
+
+				fmt.Printf("Watching remote '%s' for spot interruption notices...\n", remote.Name)
+				if err = watcher.run(); err != nil {
+					printutil.Fatalf("failover failed: %s", err.Error())
+				}
+				if err = switchRemote(prov, config, root.cfgPath, remote, replacement,
+					false, dns); err != nil {
+					printutil.Fatal(err)
+				}
+				if !replacement.Resources.Spot {
+					fmt.Println("Replacement runs on demand and will not be interrupted")
+					return
+				}
+				remote = replacement
+			}
+		},
+	}
+	failover.Flags().String(flagImage, "",
+		"image to create replacement instances from (default the remote's image)")
+	failover.Flags().Duration(flagInterval, 5*time.Second,
+		"how often to check the remote for interruption notices")
+	addDNSFlags(failover)
+	addEC2CredentialFlags(failover)
+	root.AddCommand(failover)
+}
+
+// spotFailover waits for a remote's spot instance to be scheduled for
+// interruption, then moves its deployment to a replacement instance
+type spotFailover struct {
+	interval time.Duration
+
+	// status retrieves the status of the remote's deployment
+	status func() (*api.DeploymentStatus, error)
+
+	// snapshot captures the remote's deployment while it is reachable
+	snapshot func(*api.DeploymentStatus) (*deploymentSnapshot, error)
+
+	// replace moves the captured deployment to a replacement instance
+	replace func(*deploymentSnapshot) error
+}
+
+// run blocks until the remote reports a scheduled interruption, then moves its
+// deployment to a replacement. Errors retrieving the remote's status are
+// reported and retried, since they are likely to be temporary.
+func (f *spotFailover) run() error {
+	for {
+		status, err := f.status()
+		if err != nil {
+			fmt.Printf("Failed to get status of remote: %s\n", err.Error())
+		} else if status.SpotInterruption != nil {
+			fmt.Printf("Spot instance will be interrupted at %s - failing over...\n",
+				status.SpotInterruption.Format(time.RFC3339))
+			snapshot, err := f.snapshot(status)
+			if err != nil {
+				return err
+			}
+			return f.replace(snapshot)
+		}
+		time.Sleep(f.interval)
+	}
+}
+
+// createFailoverReplacement creates a spot instance to replace remote,
+// falling back to on-demand capacity if the spot request fails, since spot
+// capacity is often scarce when instances are being interrupted
+func createFailoverReplacement(prov *provision.EC2Provisioner, config *cfg.Config,
+	remote *cfg.RemoteVPS, image string) (*cfg.RemoteVPS, error) {
+	replacement, err := createReplacement(prov, config, remote, image, true)
+	if err == nil {
+		return replacement, nil
+	}
+	fmt.Printf("Failed to create spot replacement: %s\n", err.Error())
+	fmt.Println("Falling back to on-demand capacity...")
+	return createReplacement(prov, config, remote, image, false)
+}
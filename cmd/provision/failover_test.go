@@ -0,0 +1,74 @@
+package provisioncmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestSpotFailover(t *testing.T) {
+	var (
+		interruption = time.Date(2017, 9, 18, 8, 22, 0, 0, time.UTC)
+		statuses     = []*api.DeploymentStatus{
+			{CommitHash: "abcde"},
+			nil,
+			{CommitHash: "abcde", SpotInterruption: &interruption},
+		}
+		polls    int
+		replaced *deploymentSnapshot
+	)
+	var f = &spotFailover{
+		interval: time.Millisecond,
+		status: func() (*api.DeploymentStatus, error) {
+			var status = statuses[polls]
+			polls++
+			if status == nil {
+				return nil, errors.New("connection refused")
+			}
+			return status, nil
+		},
+		snapshot: func(status *api.DeploymentStatus) (*deploymentSnapshot, error) {
+			return &deploymentSnapshot{status: status, backup: []byte("db")}, nil
+		},
+		replace: func(snapshot *deploymentSnapshot) error {
+			replaced = snapshot
+			return nil
+		},
+	}
+
+	// The interruption should be followed through to a replacement, after
+	// status errors are retried
+	assert.Nil(t, f.run())
+	assert.Equal(t, 3, polls)
+	if assert.NotNil(t, replaced) {
+		assert.Equal(t, "abcde", replaced.status.CommitHash)
+		assert.Equal(t, []byte("db"), replaced.backup)
+	}
+}
+
+func TestSpotFailover_SnapshotFailed(t *testing.T) {
+	var (
+		interruption = time.Now()
+		replaced     bool
+	)
+	var f = &spotFailover{
+		interval: time.Millisecond,
+		status: func() (*api.DeploymentStatus, error) {
+			return &api.DeploymentStatus{SpotInterruption: &interruption}, nil
+		},
+		snapshot: func(status *api.DeploymentStatus) (*deploymentSnapshot, error) {
+			return nil, errors.New("remote has no active deployment to move")
+		},
+		replace: func(snapshot *deploymentSnapshot) error {
+			replaced = true
+			return nil
+		},
+	}
+
+	// No replacement should be created without a snapshot to restore
+	assert.NotNil(t, f.run())
+	assert.False(t, replaced)
+}
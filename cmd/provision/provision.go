@@ -2,7 +2,10 @@ package provisioncmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/ubclaunchpad/inertia/cfg"
@@ -25,6 +28,32 @@ type ProvisionCmd struct {
 const (
 	flagDaemonPort = "daemon.port"
 	flagPorts      = "ports"
+
+	// EC2 credential and endpoint flags
+	flagFIPS        = "fips"
+	flagEndpoint    = "endpoint"
+	flagUser        = "user"
+	flagFromEnv     = "from-env"
+	flagFromProfile = "from-profile"
+	flagProfilePath = "profile.path"
+	flagProfileUser = "profile.user"
+	flagOTLP        = "otlp-endpoint"
+	flagMaxAttempts = "max-attempts"
+
+	// Image listing flags
+	flagImageOwner = "image-owner"
+	flagImageName  = "image-name"
+	flagImageLimit = "image-limit"
+
+	// DNS record flags
+	flagDNSZone   = "dns-zone"
+	flagDNSRecord = "dns-record"
+
+	// SSH connection flags
+	flagSSHTimeout         = "ssh.timeout"
+	flagSSHRetries         = "ssh.retries"
+	flagSSHHostKeyChecking = "ssh.host-key-checking"
+	flagSSHKnownHosts      = "ssh.known-hosts-file"
 )
 
 // AttachProvisionCmd attaches the 'provision' subcommands to the given parent
@@ -47,10 +76,14 @@ func AttachProvisionCmd(inertia *inertiacmd.Cmd) {
 		},
 	}
 	prov.PersistentFlags().StringP(flagDaemonPort, "d", "4303", "daemon port")
-	prov.PersistentFlags().StringArrayP(flagPorts, "p", []string{}, "ports your project uses")
+	prov.PersistentFlags().StringArrayP(flagPorts, "p", []string{}, "ports or port ranges your project uses, optionally suffixed with '/udp', or 'icmp'")
 
 	// add children
 	prov.attachEcsCmd()
+	prov.attachRefreshCmd()
+	prov.attachFailoverCmd()
+	prov.attachDestroyCmd()
+	prov.attachKeysCmd()
 
 	// add to parent
 	inertia.AddCommand(prov.Command)
@@ -58,12 +91,29 @@ func AttachProvisionCmd(inertia *inertiacmd.Cmd) {
 
 func (root *ProvisionCmd) attachEcsCmd() {
 	const (
-		flagType        = "type"
-		flagUser        = "user"
-		flagFromEnv     = "from-env"
-		flagFromProfile = "from-profile"
-		flagProfilePath = "profile.path"
-		flagProfileUser = "profile.user"
+		flagType       = "type"
+		flagTenancy    = "tenancy"
+		flagPublicKeys = "public-key"
+		flagPPK        = "ppk"
+		flagTerraform  = "terraform"
+		flagUserData   = "user-data"
+		flagSSHPort    = "ssh-port"
+		flagAllowCIDR  = "allowed-cidr"
+		flagTimeout    = "timeout"
+		flagSpot       = "spot"
+		flagSpotPrice  = "spot-price"
+		flagRecover    = "auto-recover"
+		flagDiskSize   = "disk-size"
+		flagDiskType   = "disk-type"
+		flagKeyPair    = "key-pair"
+		flagKeyPath    = "key-path"
+		flagKeyDir     = "key-directory"
+		flagProfile    = "iam-instance-profile"
+		flagElasticIP  = "elastic-ip"
+		flagVPC        = "vpc"
+		flagSubnet     = "subnet"
+		flagPublicIP   = "public-ip"
+		flagTag        = "tag"
 	)
 	var provEC2 = &cobra.Command{
 		Use:   "ec2 [name]",
@@ -76,7 +126,32 @@ your project uses - for example:
 
 	inertia provision ec2 my_ec2_instance -p 8000
 
+Port ranges, UDP ports, and ICMP can also be exposed - for example:
+
+	inertia provision ec2 my_ec2_instance -p 8000 -p 10000-20000/udp -p icmp
+
 This ensures that your project ports are properly exposed and externally accessible.
+
+The IDs and ARNs of the created instance, security group, and key pair are
+saved with the remote in your Inertia configuration. Use the '--terraform' flag
+to also generate a script that imports them into Terraform state.
+
+Use the '--user-data' flag to run a shell script or cloud-config when the
+instance first boots. It is rendered as a Go template with variables such as
+{{.ProjectName}}, {{.Region}}, and {{.Ports}} - see the provisioning
+documentation for the full list.
+
+Use the '--ssh-port' flag if the instance's image runs sshd on a port other
+than 22 - only that port is opened for SSH. The '--ssh.*' flags configure SSH
+connections to the instance, including the check that its SSH server is ready,
+and are saved with the remote. Any key already known for the instance's
+address belonged to a previous host, so it is replaced unless
+'--ssh.host-key-checking=yes' is set.
+
+Use the '--allowed-cidr' flag to only allow access to the SSH and daemon ports
+from the given IPv4 or IPv6 ranges - for example, your office or VPN. Webhooks
+from your Git host will not reach the daemon unless they come from an allowed
+range, so consider setting 'poll-interval-minutes' in your configuration.
 `,
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
@@ -85,13 +160,55 @@ This ensures that your project ports are properly exposed and externally accessi
 				printutil.Fatal("remote with name already exists")
 			}
 
-			// Load flags for credentials
-			var fromEnv, _ = cmd.Flags().GetBool(flagFromEnv)
-			var withProfile, _ = cmd.Flags().GetBool(flagFromProfile)
-
 			// Load flags for setup configuration
 			var user, _ = cmd.Flags().GetString(flagUser)
 			var instanceType, _ = cmd.Flags().GetString(flagType)
+			var tenancy, _ = cmd.Flags().GetString(flagTenancy)
+			var savePPK, _ = cmd.Flags().GetBool(flagPPK)
+			var terraformPath, _ = cmd.Flags().GetString(flagTerraform)
+			var publicKeyPaths, _ = cmd.Flags().GetStringArray(flagPublicKeys)
+			var userDataPath, _ = cmd.Flags().GetString(flagUserData)
+			var sshPort, _ = cmd.Flags().GetInt64(flagSSHPort)
+			var allowedCIDRs, _ = cmd.Flags().GetStringArray(flagAllowCIDR)
+			var timeout, _ = cmd.Flags().GetDuration(flagTimeout)
+			var spot, _ = cmd.Flags().GetBool(flagSpot)
+			var spotPrice, _ = cmd.Flags().GetString(flagSpotPrice)
+			var autoRecover, _ = cmd.Flags().GetBool(flagRecover)
+			var diskSize, _ = cmd.Flags().GetInt64(flagDiskSize)
+			var diskType, _ = cmd.Flags().GetString(flagDiskType)
+			var keyPair, _ = cmd.Flags().GetString(flagKeyPair)
+			var keyPath, _ = cmd.Flags().GetString(flagKeyPath)
+			var keyDirectory, _ = cmd.Flags().GetString(flagKeyDir)
+			var instanceProfile, _ = cmd.Flags().GetString(flagProfile)
+			var elasticIP, _ = cmd.Flags().GetBool(flagElasticIP)
+			var vpc, _ = cmd.Flags().GetString(flagVPC)
+			var subnet, _ = cmd.Flags().GetString(flagSubnet)
+			var publicIP, _ = cmd.Flags().GetBool(flagPublicIP)
+			var tagPairs, _ = cmd.Flags().GetStringArray(flagTag)
+			var tags = make(map[string]string, len(tagPairs))
+			for _, pair := range tagPairs {
+				var kv = strings.SplitN(pair, "=", 2)
+				if len(kv) != 2 {
+					printutil.Fatalf("invalid tag '%s' - must be in the form key=value", pair)
+				}
+				tags[kv[0]] = kv[1]
+			}
+			var userDataTemplate string
+			if userDataPath != "" {
+				tmpl, err := ioutil.ReadFile(userDataPath)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				userDataTemplate = string(tmpl)
+			}
+			var publicKeys = make([]string, len(publicKeyPaths))
+			for i, p := range publicKeyPaths {
+				key, err := ioutil.ReadFile(p)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				publicKeys[i] = string(key)
+			}
 			var stringProjectPorts, _ = cmd.Flags().GetStringArray(flagPorts)
 			if stringProjectPorts == nil || len(stringProjectPorts) == 0 {
 				fmt.Print("[WARNING] no project ports provided - this means that no ports" +
@@ -100,46 +217,29 @@ This ensures that your project ports are properly exposed and externally accessi
 			}
 
 			// Create VPS instance
-			var prov *provision.EC2Provisioner
-			var err error
-			if fromEnv {
-				prov, err = provision.NewEC2ProvisionerFromEnv(user, os.Stdout)
-				if err != nil {
-					printutil.Fatal(err)
-				}
-			} else if withProfile {
-				var profileUser, _ = cmd.Flags().GetString(flagProfileUser)
-				var profilePath, _ = cmd.Flags().GetString(flagProfilePath)
-				prov, err = provision.NewEC2ProvisionerFromProfile(
-					user, profileUser, profilePath, os.Stdout)
-				if err != nil {
-					printutil.Fatal(err)
-				}
-			} else {
-				keyID, key, err := inpututil.EnterEC2CredentialsWalkthrough(os.Stdin)
-				if err != nil {
-					printutil.Fatal(err)
-				}
-				prov, err = provision.NewEC2Provisioner(user, keyID, key, os.Stdout)
-				if err != nil {
-					printutil.Fatal(err)
-				}
+			prov, err := newEC2Provisioner(cmd, user)
+			if err != nil {
+				printutil.Fatal(err)
 			}
+			defer prov.WaitForTraces()
 
 			// Report connected user
 			fmt.Printf("Executing commands as user '%s'\n", prov.GetUser())
 
-			// Prompt for region
-			println("See https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/using-regions-availability-zones.html#concepts-available-regions for a list of available regions.")
-			print("Please enter a region: ")
-			var region string
-			if _, err = fmt.Fscanln(os.Stdin, &region); err != nil {
+			// List region options and prompt for input
+			fmt.Println("Loading regions...")
+			regions, err := prov.ListRegions()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			region, err := inpututil.ChooseFromListWalkthrough(os.Stdin, "region", regions)
+			if err != nil {
 				printutil.Fatal(err)
 			}
 
 			// List image options and prompt for input
 			fmt.Printf("Loading images for region '%s'...\n", region)
-			images, err := prov.ListImageOptions(region)
+			images, err := listImageOptions(cmd, prov, region)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -150,29 +250,61 @@ This ensures that your project ports are properly exposed and externally accessi
 
 			// Gather input
 			fmt.Printf("Creating %s instance in %s from image %s...\n", instanceType, region, image)
-			var ports = []int64{}
+			var ports = []provision.PortRange{}
 			for _, portString := range stringProjectPorts {
-				p, err := common.ParseInt64(portString)
-				if err == nil {
-					ports = append(ports, p)
-				} else {
-					fmt.Printf("invalid port %s", portString)
+				r, err := provision.ParsePortRange(portString)
+				if err != nil {
+					printutil.Fatal(err)
 				}
+				ports = append(ports, r)
 			}
 
 			// Create remote instance
 			var port, _ = cmd.Flags().GetString(flagDaemonPort)
 			var portDaemon, _ = common.ParseInt64(port)
-			remote, err := prov.CreateInstance(provision.EC2CreateInstanceOptions{
+			var opts = provision.EC2CreateInstanceOptions{
 				Name:        args[0],
 				ProjectName: config.Project,
-				Ports:       ports,
+				PortRanges:  ports,
 				DaemonPort:  portDaemon,
+				SSHPort:     sshPort,
+				SSH:         getSSHOptions(cmd),
+
+				AllowedCIDRs: allowedCIDRs,
 
 				ImageID:      image,
 				InstanceType: instanceType,
 				Region:       region,
-			})
+				DiskSizeGB:   diskSize,
+				DiskType:     diskType,
+				VPCID:        vpc,
+				SubnetID:     subnet,
+				Tenancy:      tenancy,
+				Spot:         spot,
+				SpotPrice:    spotPrice,
+				AutoRecover:  autoRecover,
+
+				IAMInstanceProfile: instanceProfile,
+				AssignElasticIP:    elasticIP,
+				AssociatePublicIP:  publicIP,
+				Tags:               tags,
+
+				AdditionalPublicKeys: publicKeys,
+				SavePPK:              savePPK,
+				KeyPairName:          keyPair,
+				KeyPath:              keyPath,
+				KeyDirectory:         keyDirectory,
+				UserDataTemplate:     userDataTemplate,
+
+				Timeout: timeout,
+			}
+
+			// Check permissions before any resources are created
+			fmt.Println("Verifying permissions...")
+			if err = prov.VerifyPermissions(opts); err != nil {
+				printutil.Fatal(err)
+			}
+			remote, err := prov.CreateInstance(opts)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -185,6 +317,18 @@ This ensures that your project ports are properly exposed and externally accessi
 			config.AddRemote(remote)
 			config.Write(root.cfgPath)
 
+			// Generate script to import created resources into Terraform
+			if terraformPath != "" {
+				script, err := provision.TerraformImportScript(remote)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				if err = ioutil.WriteFile(terraformPath, script, 0700); err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("Terraform import script saved to %s\n", terraformPath)
+			}
+
 			// Create inertia client
 			inertia, found := client.NewClient(args[0], os.Getenv(local.EnvSSHPassphrase), config, os.Stdout)
 			if !found {
@@ -203,16 +347,186 @@ This ensures that your project ports are properly exposed and externally accessi
 	}
 	provEC2.Flags().StringP(flagType, "t",
 		"t2.micro", "ec2 instance type to instantiate")
+	provEC2.Flags().String(flagTenancy, "",
+		"ec2 instance tenancy - one of 'default', 'dedicated', or 'host'")
+	provEC2.Flags().StringArray(flagPublicKeys, nil,
+		"path to an additional public key to authorize for ssh access (can be repeated)")
+	provEC2.Flags().Bool(flagPPK, false,
+		"also save the generated private key in PuTTY's format (.ppk)")
+	provEC2.Flags().String(flagKeyPair, "",
+		"name of an existing key pair to use instead of generating one - requires --key-path")
+	provEC2.Flags().String(flagKeyPath, "",
+		"path of the private key of the key pair given by --key-pair")
+	provEC2.Flags().String(flagKeyDir, "",
+		"directory to save the generated key in (default ~/.ssh)")
+	provEC2.Flags().String(flagTerraform, "",
+		"path to save a script that imports the created resources into terraform state")
+	provEC2.Flags().String(flagUserData, "",
+		"path to a user data template to run when the instance first boots")
+	provEC2.Flags().Int64(flagSSHPort, 22,
+		"port the instance accepts ssh connections on")
+	provEC2.Flags().StringArray(flagAllowCIDR, nil,
+		"CIDR range to allow ssh and daemon access from, such as 203.0.113.0/24 (can be repeated)")
+	provEC2.Flags().Int64(flagDiskSize, 0,
+		"size of the instance's root volume in GB (default size required by the image)")
+	provEC2.Flags().String(flagDiskType, "",
+		"type of the instance's root volume, either gp2 or gp3 (default type used by the image)")
+	provEC2.Flags().Bool(flagSpot, false,
+		"run the instance on cheaper spot capacity, which can be interrupted by aws")
+	provEC2.Flags().String(flagSpotPrice, "",
+		"maximum hourly price in USD to pay for spot capacity (default on-demand price)")
+	provEC2.Flags().String(flagProfile, "",
+		"name or ARN of an existing IAM instance profile to attach to the instance")
+	provEC2.Flags().String(flagVPC, "",
+		"id of the vpc the subnet given by --subnet belongs to, checked before provisioning")
+	provEC2.Flags().String(flagSubnet, "",
+		"id of an existing subnet to launch the instance into (default subnet of the default vpc)")
+	provEC2.Flags().Bool(flagPublicIP, false,
+		"associate a public ip address with the instance even if its subnet does not assign them")
+	provEC2.Flags().StringArray(flagTag, nil,
+		"tag to apply to the created resources, such as team=platform (can be repeated)")
+	provEC2.Flags().Bool(flagElasticIP, false,
+		"assign an elastic ip address to the instance, so that its address does not change")
+	provEC2.Flags().Bool(flagRecover, false,
+		"create a cloudwatch alarm that recovers the instance if its hardware fails")
+	provEC2.Flags().Duration(flagTimeout, 10*time.Minute,
+		"how long to wait for the instance to start and accept ssh connections")
 	provEC2.Flags().StringP(flagUser, "u",
 		"ec2-user", "ec2 instance user to execute commands as")
-	provEC2.Flags().Bool(flagFromEnv, false,
+	addImageFlags(provEC2)
+	addSSHFlags(provEC2)
+	addEC2CredentialFlags(provEC2)
+
+	root.AddCommand(provEC2)
+}
+
+// addEC2CredentialFlags adds the flags read by newEC2Provisioner to cmd
+func addEC2CredentialFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(flagFIPS, false,
+		"use FIPS 140-2 validated ec2 endpoints")
+	cmd.Flags().String(flagEndpoint, "",
+		"ec2 endpoint to use instead of the default regional endpoint")
+	cmd.Flags().Bool(flagFromEnv, false,
 		"load ec2 credentials from environment - requires AWS_ACCESS_KEY_ID, AWS_ACCESS_KEY to be set")
-	provEC2.Flags().Bool(flagFromProfile, false,
+	cmd.Flags().Bool(flagFromProfile, false,
 		"load ec2 credentials from profile")
-	provEC2.Flags().String(flagProfilePath, "~/.aws/config",
+	cmd.Flags().String(flagProfilePath, "~/.aws/config",
 		"path to aws profile configuration file")
-	provEC2.Flags().String(flagProfileUser, "default",
+	cmd.Flags().String(flagProfileUser, "default",
 		"user profile for aws credentials file")
+	cmd.Flags().String(flagOTLP, "",
+		"OpenTelemetry collector to export traces of instance creation to, such as http://localhost:4318")
+	cmd.Flags().Int(flagMaxAttempts, provision.DefaultMaxAttempts,
+		"number of times to attempt ec2 requests that fail due to throttling or outages")
+}
 
-	root.AddCommand(provEC2)
+// addImageFlags adds the flags read by listImageOptions to cmd
+func addImageFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray(flagImageOwner, nil,
+		"account ID or alias of the owner of the images to choose from, such as 099720109477 for "+
+			"Canonical (can be repeated, default amazon)")
+	cmd.Flags().String(flagImageName, "",
+		"name pattern of the images to choose from, such as 'ubuntu/images/*' - requires --image-owner")
+	cmd.Flags().Int(flagImageLimit, provision.DefaultImageLimit,
+		"number of most recent images to choose from (0 lists all images)")
+}
+
+// addSSHFlags adds the flags read by getSSHOptions to cmd
+func addSSHFlags(cmd *cobra.Command) {
+	cmd.Flags().Int(flagSSHTimeout, 0,
+		"seconds to wait for ssh connections to be established (default 15)")
+	cmd.Flags().Int(flagSSHRetries, 0,
+		"number of extra attempts to make when ssh connections fail")
+	cmd.Flags().String(flagSSHHostKeyChecking, "",
+		"how host keys are verified - one of 'yes', 'accept-new', or 'no' (default 'accept-new')")
+	cmd.Flags().String(flagSSHKnownHosts, "",
+		"known hosts file to verify host keys with (default ~/.ssh/known_hosts)")
+}
+
+// getSSHOptions reads the SSH options set by the flags added by addSSHFlags,
+// returning nil if none are set, and exiting if they are invalid
+func getSSHOptions(cmd *cobra.Command) *cfg.SSHOptions {
+	var opts = &cfg.SSHOptions{}
+	opts.Timeout, _ = cmd.Flags().GetInt(flagSSHTimeout)
+	opts.Retries, _ = cmd.Flags().GetInt(flagSSHRetries)
+	opts.HostKeyChecking, _ = cmd.Flags().GetString(flagSSHHostKeyChecking)
+	opts.KnownHostsFile, _ = cmd.Flags().GetString(flagSSHKnownHosts)
+	switch opts.HostKeyChecking {
+	case "", cfg.HostKeyCheckingStrict, cfg.HostKeyCheckingAcceptNew, cfg.HostKeyCheckingOff:
+	default:
+		printutil.Fatalf("invalid --%s '%s'", flagSSHHostKeyChecking, opts.HostKeyChecking)
+	}
+	if *opts == (cfg.SSHOptions{}) {
+		return nil
+	}
+	return opts
+}
+
+// listImageOptions lists the images in region to choose from, selected by the
+// flags added by addImageFlags
+func listImageOptions(cmd *cobra.Command, prov *provision.EC2Provisioner,
+	region string) ([]string, error) {
+	var owners, _ = cmd.Flags().GetStringArray(flagImageOwner)
+	var name, _ = cmd.Flags().GetString(flagImageName)
+	var limit, _ = cmd.Flags().GetInt(flagImageLimit)
+	return prov.ListImageOptions(region, provision.ImageFilter{
+		Owners:      owners,
+		NamePattern: name,
+	}, limit)
+}
+
+// newEC2Provisioner creates an EC2 provisioner that executes commands on
+// instances as user, with credentials and endpoints configured by the flags
+// added by addEC2CredentialFlags. Credentials are prompted for if neither the
+// environment nor a profile is used, and are checked before the provisioner is
+// returned.
+func newEC2Provisioner(cmd *cobra.Command, user string) (*provision.EC2Provisioner, error) {
+	var fromEnv, _ = cmd.Flags().GetBool(flagFromEnv)
+	var withProfile, _ = cmd.Flags().GetBool(flagFromProfile)
+
+	var prov *provision.EC2Provisioner
+	var err error
+	if fromEnv {
+		prov, err = provision.NewEC2ProvisionerFromEnv(user, os.Stdout)
+	} else if withProfile {
+		var profileUser, _ = cmd.Flags().GetString(flagProfileUser)
+		var profilePath, _ = cmd.Flags().GetString(flagProfilePath)
+		prov, err = provision.NewEC2ProvisionerFromProfile(
+			user, profileUser, profilePath, os.Stdout)
+	} else {
+		var keyID, key string
+		if keyID, key, err = inpututil.EnterEC2CredentialsWalkthrough(os.Stdin); err != nil {
+			return nil, err
+		}
+		prov, err = provision.NewEC2Provisioner(user, keyID, key, os.Stdout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure endpoints
+	var fips, _ = cmd.Flags().GetBool(flagFIPS)
+	var endpoint, _ = cmd.Flags().GetString(flagEndpoint)
+	if err = prov.WithFIPS(fips); err != nil {
+		return nil, err
+	}
+	if err = prov.WithEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	// Configure retries
+	var maxAttempts, _ = cmd.Flags().GetInt(flagMaxAttempts)
+	if err = prov.WithMaxAttempts(maxAttempts); err != nil {
+		return nil, err
+	}
+
+	// Check credentials before anything is done with them
+	if err = prov.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Configure tracing
+	var otlpEndpoint, _ = cmd.Flags().GetString(flagOTLP)
+	prov.WithTracer(otlpEndpoint)
+	return prov, nil
 }
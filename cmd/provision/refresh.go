@@ -0,0 +1,464 @@
+package provisioncmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/client"
+	"github.com/ubclaunchpad/inertia/cmd/inpututil"
+	"github.com/ubclaunchpad/inertia/cmd/printutil"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/local"
+	"github.com/ubclaunchpad/inertia/provision"
+)
+
+func (root *ProvisionCmd) attachRefreshCmd() {
+	const (
+		flagImage   = "image"
+		flagKeepOld = "keep-old"
+	)
+	var refresh = &cobra.Command{
+		Use:   "refresh [remote]",
+		Short: "[BETA] Replace a provisioned remote's instance with a new one",
+		Long: `[BETA] Replaces the instance of a remote provisioned with 'inertia provision'
+with a new instance, for example to pick up operating system and security
+updates from a newer image.
+
+The new instance reuses the remote's key pair, security group, and instance
+type. Its deploy key, secrets, and deployment database are copied from the
+current instance, so the repository's deploy key does not need to be updated.
+The current commit is deployed to the new instance, and once it is verified to
+be running with healthy containers, the remote is updated to point to the new instance and the old
+instance is terminated. If anything fails before then, the new instance is
+terminated and the remote is left unchanged.
+
+The new instance has a different address, so DNS records and webhook URLs
+pointing at the old instance must be updated afterwards - unless the remote has
+an Elastic IP address, which is moved to the new instance. A DNS record in a
+Route 53 hosted zone can be moved to the new instance with --dns-zone and
+--dns-record.
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var config = root.config
+			remote, found := config.GetRemote(args[0])
+			if !found {
+				printutil.Fatal("remote not found")
+			}
+			if remote.Resources == nil || remote.Resources.Provider != "ec2" {
+				printutil.Fatal("only remotes provisioned on ec2 can be refreshed")
+			}
+			var image, _ = cmd.Flags().GetString(flagImage)
+			var keepOld, _ = cmd.Flags().GetBool(flagKeepOld)
+			var dns = getDNSRecord(cmd)
+			var passphrase = os.Getenv(local.EnvSSHPassphrase)
+
+			// Check the current deployment before creating any resources
+			current, found := client.NewClient(remote.Name, passphrase, config, os.Stdout)
+			if !found {
+				printutil.Fatal("remote not found")
+			}
+			before, err := getDeploymentStatus(current)
+			if err != nil {
+				printutil.Fatalf("failed to get status of current remote: %s", err.Error())
+			}
+			if before.CommitHash == "" {
+				printutil.Fatal("remote has no active deployment to move")
+			}
+
+			prov, err := newEC2Provisioner(cmd, remote.User)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer prov.WaitForTraces()
+			var resources = remote.Resources
+			if image == "" {
+				fmt.Printf("Loading images for region '%s'...\n", resources.Region)
+				images, err := listImageOptions(cmd, prov, resources.Region)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				if image, err = inpututil.ChooseFromListWalkthrough(os.Stdin, "image", images); err != nil {
+					printutil.Fatal(err)
+				}
+			}
+
+			// Create the replacement instance
+			replacement, err := createReplacement(prov, config, remote, image, resources.Spot)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if err = moveDeployment(current, replacement, config, passphrase, before); err != nil {
+				fmt.Printf("Failed to move deployment: %s\n", err.Error())
+				if err := prov.TerminateInstance(resources.Region, replacement.Resources.InstanceID); err != nil {
+					printutil.Fatalf("failed to terminate replacement instance %s: %s",
+						replacement.Resources.InstanceID, err.Error())
+				}
+				printutil.Fatal("remote left unchanged")
+			}
+
+			// Move the remote's addresses to the replacement
+			if err = moveAddresses(prov, remote, replacement, dns); err != nil {
+				fmt.Println(err.Error())
+				if err := prov.TerminateInstance(resources.Region, replacement.Resources.InstanceID); err != nil {
+					printutil.Fatalf("failed to terminate replacement instance %s: %s",
+						replacement.Resources.InstanceID, err.Error())
+				}
+				printutil.Fatal("remote left unchanged")
+			}
+
+			// Point the remote at the replacement
+			if err = switchRemote(prov, config, root.cfgPath, remote, replacement,
+				keepOld, dns); err != nil {
+				printutil.Fatal(err)
+			}
+		},
+	}
+	refresh.Flags().String(flagImage, "",
+		"image to create the replacement instance from - prompted for if not set")
+	refresh.Flags().Bool(flagKeepOld, false,
+		"keep the old instance running instead of terminating it")
+	addImageFlags(refresh)
+	addDNSFlags(refresh)
+	addEC2CredentialFlags(refresh)
+	root.AddCommand(refresh)
+}
+
+// dnsRecord identifies a DNS record in a Route 53 hosted zone that points at
+// a remote's instance - it is empty if no record is managed
+type dnsRecord struct {
+	zoneID string
+	name   string
+}
+
+// addDNSFlags adds the flags read by getDNSRecord to cmd
+func addDNSFlags(cmd *cobra.Command) {
+	cmd.Flags().String(flagDNSZone, "",
+		"ID of the route 53 hosted zone of the DNS record to point at the new instance")
+	cmd.Flags().String(flagDNSRecord, "",
+		"name of the DNS record, such as app.example.com, to point at the new instance")
+}
+
+// getDNSRecord reads the DNS record to move from the flags added by
+// addDNSFlags, exiting if only one of them is set
+func getDNSRecord(cmd *cobra.Command) dnsRecord {
+	var zoneID, _ = cmd.Flags().GetString(flagDNSZone)
+	var name, _ = cmd.Flags().GetString(flagDNSRecord)
+	if (zoneID == "") != (name == "") {
+		printutil.Fatalf("--%s and --%s must be set together", flagDNSZone, flagDNSRecord)
+	}
+	return dnsRecord{zoneID: zoneID, name: name}
+}
+
+// createReplacement creates a new instance for remote from the given image,
+// on spot capacity if requested, reusing the remote's key pair, security
+// group, and instance type
+func createReplacement(prov *provision.EC2Provisioner, config *cfg.Config,
+	remote *cfg.RemoteVPS, image string, spot bool) (*cfg.RemoteVPS, error) {
+	var resources = remote.Resources
+	var daemonPort, _ = common.ParseInt64(remote.Daemon.Port)
+	var sshPort, _ = common.ParseInt64(remote.SSHPort)
+	var spotPrice string
+	if spot {
+		spotPrice = resources.SpotPrice
+	}
+	fmt.Printf("Creating replacement %s instance from image %s...\n",
+		resources.InstanceType, image)
+	replacement, err := prov.CreateInstance(provision.EC2CreateInstanceOptions{
+		Name:        remote.Name,
+		ProjectName: config.Project,
+		DaemonPort:  daemonPort,
+		SSHPort:     sshPort,
+		SSH:         remote.SSH,
+
+		ImageID:      image,
+		InstanceType: resources.InstanceType,
+		Region:       resources.Region,
+
+		KeyPairName:     resources.KeyPairName,
+		KeyPath:         remote.PEM,
+		SecurityGroupID: resources.SecurityGroupID,
+		SubnetID:        resources.SubnetID,
+
+		// The deployment is moved to the replacement over its public
+		// address, which subnets other than the default may not assign
+		AssociatePublicIP: resources.SubnetID != "",
+
+		Spot:      spot,
+		SpotPrice: spotPrice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	replacement.Branch = remote.Branch
+	replacement.Daemon.WebHookSecret = remote.Daemon.WebHookSecret
+	replacement.Resources.ExistingKeyPair = resources.ExistingKeyPair
+	return replacement, nil
+}
+
+// moveAddresses moves remote's Elastic IP address, if it has one, to the
+// replacement, and then points the given DNS record, if set, at the
+// replacement. Failing to update the DNS record is reported but not returned,
+// since the replacement is reachable by then.
+func moveAddresses(prov *provision.EC2Provisioner, remote, replacement *cfg.RemoteVPS,
+	dns dnsRecord) error {
+	var resources = remote.Resources
+	if allocationID := resources.ElasticIPAllocationID; allocationID != "" {
+		if err := prov.AssociateElasticIP(resources.Region, allocationID,
+			replacement.Resources.InstanceID); err != nil {
+			return fmt.Errorf("Failed to move elastic IP address: %s", err.Error())
+		}
+		replacement.IP = remote.IP
+		replacement.Resources.ElasticIPAllocationID = allocationID
+
+		// The address's known host key belonged to the remote's old instance
+		forgetHostKey(replacement)
+	}
+	if dns.name != "" {
+		if err := prov.PointDNSRecord(dns.zoneID, dns.name, replacement.IP); err != nil {
+			fmt.Printf("[WARNING] %s - update it to point to %s\n", err.Error(), replacement.IP)
+		}
+	}
+	return nil
+}
+
+// forgetHostKey removes the known host key of the given remote's address after
+// the address is moved to it from another instance, so that the remote's own
+// key is accepted when it is next connected to. Keys are left in place if
+// they are checked strictly, and the remote must then be added to the known
+// hosts file manually.
+func forgetHostKey(remote *cfg.RemoteVPS) {
+	var file = local.GetKnownHostsFile(remote.SSH)
+	switch local.GetHostKeyChecking(remote.SSH) {
+	case cfg.HostKeyCheckingStrict:
+		fmt.Printf("[WARNING] Host key of %s has changed - update it in %s\n", remote.IP, file)
+	case cfg.HostKeyCheckingAcceptNew:
+		if err := local.ForgetHostKey(file, remote.IP, remote.SSHPort); err != nil {
+			fmt.Printf("[WARNING] Failed to remove old host key of %s from %s: %s\n",
+				remote.IP, file, err.Error())
+		}
+	}
+}
+
+// switchRemote points remote at the replacement in the configuration at
+// cfgPath, and terminates the remote's old instance unless keepOld is set
+func switchRemote(prov *provision.EC2Provisioner, config *cfg.Config, cfgPath string,
+	remote, replacement *cfg.RemoteVPS, keepOld bool, dns dnsRecord) error {
+	var old = *remote
+	config.RemoveRemote(remote.Name)
+	config.AddRemote(replacement)
+	if err := config.Write(cfgPath); err != nil {
+		return err
+	}
+	fmt.Printf("Remote '%s' now points to %s\n", replacement.Name, replacement.IP)
+
+	var movedIP = old.Resources.ElasticIPAllocationID != ""
+	if keepOld && movedIP {
+		fmt.Printf("Old instance %s was kept\n", old.Resources.InstanceID)
+	} else if keepOld {
+		fmt.Printf("Old instance %s at %s was kept\n", old.Resources.InstanceID, old.IP)
+	} else if err := prov.TerminateInstance(old.Resources.Region, old.Resources.InstanceID); err != nil {
+		return fmt.Errorf("failed to terminate old instance %s: %s",
+			old.Resources.InstanceID, err.Error())
+	}
+	if !movedIP && dns.name == "" {
+		fmt.Println("Update any DNS records and webhook URLs that point to " + old.IP)
+	} else if !movedIP {
+		fmt.Println("Update any webhook URLs that point to " + old.IP)
+	}
+	return nil
+}
+
+// deploymentSnapshot holds what is needed to move a remote's deployment to
+// another instance, captured while the remote is still reachable
+type deploymentSnapshot struct {
+	status *api.DeploymentStatus
+	keys   client.HostKeys
+	backup []byte
+}
+
+// snapshotDeployment captures the keys and deployment database of the current
+// remote, whose deployment has the given status
+func snapshotDeployment(current *client.Client,
+	status *api.DeploymentStatus) (*deploymentSnapshot, error) {
+	if status.CommitHash == "" {
+		return nil, errors.New("remote has no active deployment to move")
+	}
+	fmt.Println("Copying keys from current remote...")
+	keys, err := current.ReadHostKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Copying deployment database from current remote...")
+	resp, err := current.Backup()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to back up current remote: (status code %d) %s",
+			resp.StatusCode, body)
+	}
+	backup, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up current remote: %s", err.Error())
+	}
+	return &deploymentSnapshot{status: status, keys: keys, backup: backup}, nil
+}
+
+// moveDeployment sets up Inertia on the given replacement remote with the keys
+// and deployment database of the current remote, deploys the current commit
+// to it, and checks that the deployment is running and healthy
+func moveDeployment(current *client.Client, replacement *cfg.RemoteVPS,
+	config *cfg.Config, passphrase string, before *api.DeploymentStatus) error {
+	snapshot, err := snapshotDeployment(current, before)
+	if err != nil {
+		return err
+	}
+	return restoreDeployment(snapshot, replacement, config, passphrase)
+}
+
+// restoreDeployment sets up Inertia on the given replacement remote with the
+// keys and deployment database of the snapshot, deploys the snapshot's commit
+// to it, and checks that the deployment is running and healthy
+func restoreDeployment(snapshot *deploymentSnapshot, replacement *cfg.RemoteVPS,
+	config *cfg.Config, passphrase string) error {
+	// Create a client for the replacement without changing the remote yet
+	var staging = *config
+	staging.Remotes = map[string]*cfg.RemoteVPS{replacement.Name: replacement}
+	next, _ := client.NewClient(replacement.Name, passphrase, &staging, os.Stdout)
+
+	if err := next.WriteHostKeys(snapshot.keys); err != nil {
+		return err
+	}
+	fmt.Printf("Initializing Inertia daemon at %s...\n", replacement.IP)
+	if err := next.BootstrapRemote(config.Project); err != nil {
+		return err
+	}
+
+	restore, err := next.Restore(bytes.NewReader(snapshot.backup))
+	if err != nil {
+		return err
+	}
+	defer restore.Body.Close()
+	if restore.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(restore.Body)
+		return fmt.Errorf("failed to restore deployment database: (status code %d) %s",
+			restore.StatusCode, body)
+	}
+
+	// Deploy the commit the current remote is running, rather than the head
+	// of the branch, which may have moved on since
+	fmt.Printf("Deploying commit %s to replacement...\n", snapshot.status.CommitHash)
+	url, err := local.GetRepoRemote("origin")
+	if err != nil {
+		return err
+	}
+	up, err := next.UpCommit(url, snapshot.status.CommitHash, true)
+	if err != nil {
+		return err
+	}
+	defer up.Body.Close()
+	io.Copy(os.Stdout, up.Body)
+	if up.StatusCode != http.StatusOK && up.StatusCode != http.StatusCreated {
+		return fmt.Errorf("deployment failed with status code %d", up.StatusCode)
+	}
+
+	// Verify the deployment is running and healthy
+	fmt.Println("Waiting for project to become healthy on replacement...")
+	return waitForHealthy(func() (*api.DeploymentStatus, error) {
+		return getDeploymentStatus(next)
+	}, snapshot.status, healthTimeout, healthPollInterval)
+}
+
+const (
+	// healthTimeout is how long the containers of a moved deployment are
+	// given to pass their healthchecks
+	healthTimeout = 3 * time.Minute
+
+	// healthPollInterval is how often a moved deployment's health is checked
+	healthPollInterval = 5 * time.Second
+)
+
+// waitForHealthy waits until the deployment reported by status runs the same
+// commit and containers as the deployment described by before, and all of its
+// containers with healthchecks are healthy. An error is returned as soon as a
+// container is unhealthy, or if containers are still starting after timeout.
+func waitForHealthy(status func() (*api.DeploymentStatus, error), before *api.DeploymentStatus,
+	timeout, interval time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	for {
+		after, err := status()
+		if err != nil {
+			return err
+		}
+		if after.CommitHash != before.CommitHash {
+			return fmt.Errorf("replacement deployed commit %s instead of %s",
+				after.CommitHash, before.CommitHash)
+		}
+		if len(after.Containers) == 0 && !after.SitePublished {
+			return errors.New("project is not running on replacement")
+		}
+		var running = make(map[string]bool, len(after.Containers))
+		for _, name := range after.Containers {
+			running[name] = true
+		}
+		for _, name := range before.Containers {
+			if !running[name] {
+				return fmt.Errorf("container %s is not running on replacement", name)
+			}
+		}
+
+		var starting = make([]string, 0, len(after.Health))
+		for name, health := range after.Health {
+			switch health {
+			case "healthy":
+			case "unhealthy":
+				return fmt.Errorf("container %s is unhealthy on replacement", name)
+			default:
+				starting = append(starting, name)
+			}
+		}
+		if len(starting) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			sort.Strings(starting)
+			return fmt.Errorf("containers %s did not become healthy within %s",
+				strings.Join(starting, ", "), timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// getDeploymentStatus retrieves the status of the given client's deployment
+func getDeploymentStatus(c *client.Client) (*api.DeploymentStatus, error) {
+	resp, err := c.Status()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("(status code %d) %s", resp.StatusCode, body)
+	}
+	var status = &api.DeploymentStatus{}
+	if err = json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
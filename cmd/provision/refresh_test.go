@@ -0,0 +1,63 @@
+package provisioncmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestWaitForHealthy(t *testing.T) {
+	var before = &api.DeploymentStatus{CommitHash: "abcde", Containers: []string{"/web", "/db"}}
+	tests := []struct {
+		name     string
+		statuses []*api.DeploymentStatus
+		wantErr  bool
+	}{
+		{"healthy", []*api.DeploymentStatus{
+			{CommitHash: "abcde", Containers: []string{"/web", "/db"},
+				Health: map[string]string{"/web": "starting"}},
+			{CommitHash: "abcde", Containers: []string{"/web", "/db"},
+				Health: map[string]string{"/web": "healthy"}},
+		}, false},
+		{"no healthchecks", []*api.DeploymentStatus{
+			{CommitHash: "abcde", Containers: []string{"/web", "/db"}},
+		}, false},
+		{"wrong commit", []*api.DeploymentStatus{
+			{CommitHash: "fghij", Containers: []string{"/web", "/db"}},
+		}, true},
+		{"missing container", []*api.DeploymentStatus{
+			{CommitHash: "abcde", Containers: []string{"/web"}},
+		}, true},
+		{"unhealthy", []*api.DeploymentStatus{
+			{CommitHash: "abcde", Containers: []string{"/web", "/db"},
+				Health: map[string]string{"/web": "unhealthy"}},
+		}, true},
+		{"never healthy", []*api.DeploymentStatus{
+			{CommitHash: "abcde", Containers: []string{"/web", "/db"},
+				Health: map[string]string{"/web": "starting"}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls int
+			var err = waitForHealthy(func() (*api.DeploymentStatus, error) {
+				if polls >= len(tt.statuses) {
+					// Keep reporting the last status until the timeout
+					return tt.statuses[len(tt.statuses)-1], nil
+				}
+				polls++
+				return tt.statuses[polls-1], nil
+			}, before, 20*time.Millisecond, time.Millisecond)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+
+	// Status errors should be returned
+	var err = waitForHealthy(func() (*api.DeploymentStatus, error) {
+		return nil, errors.New("connection refused")
+	}, before, time.Millisecond, time.Millisecond)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,279 @@
+package common
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// otlpTracesPath is the path OpenTelemetry collectors receive traces on
+const otlpTracesPath = "/v1/traces"
+
+// Tracer exports traces of Inertia's operations to an OpenTelemetry collector
+// using OTLP over HTTP. A nil Tracer does not trace anything, so callers do not
+// need to check whether tracing is enabled.
+type Tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	// out is where failures to export traces are reported, since traces are
+	// exported in the background
+	out     io.Writer
+	pending sync.WaitGroup
+}
+
+// NewTracer creates a tracer that exports traces to the OTLP endpoint, such as
+// "http://collector:4318", on behalf of the given service. Failures to export
+// traces are written to out. Returns nil if no endpoint is given.
+func NewTracer(endpoint, service string, out io.Writer) *Tracer {
+	if endpoint == "" {
+		return nil
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, otlpTracesPath) {
+		endpoint += otlpTracesPath
+	}
+	return &Tracer{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		out:      out,
+	}
+}
+
+// Wait blocks until traces that are being exported in the background have
+// been sent
+func (t *Tracer) Wait() {
+	if t == nil {
+		return
+	}
+	t.pending.Wait()
+}
+
+// Start begins a new trace, and returns its root span
+func (t *Tracer) Start(name string) *Span {
+	if t == nil {
+		return nil
+	}
+	var root = &Span{
+		tracer:  t,
+		traceID: randomHex(16),
+		spanID:  randomHex(8),
+		name:    name,
+		start:   time.Now(),
+	}
+	root.root = root
+	return root
+}
+
+// Span is a timed operation in a trace. Methods on a nil Span do nothing.
+type Span struct {
+	tracer *Tracer
+	root   *Span
+
+	traceID  string
+	spanID   string
+	parentID string
+
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+
+	// mux guards the spans of a trace, which are tracked by its root
+	mux   sync.Mutex
+	spans []*Span
+}
+
+// Child begins a span for a phase of the given span's operation
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	var child = &Span{
+		tracer:   s.tracer,
+		root:     s.root,
+		traceID:  s.traceID,
+		spanID:   randomHex(8),
+		parentID: s.spanID,
+		name:     name,
+		start:    time.Now(),
+	}
+	s.root.mux.Lock()
+	s.root.spans = append(s.root.spans, child)
+	s.root.mux.Unlock()
+	return child
+}
+
+// SetAttribute annotates the span with the given key and value
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.root.mux.Lock()
+	defer s.root.mux.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// End marks the span as done, and as failed if err is not nil. Ending the root
+// span of a trace exports the trace in the background, so that exporting it
+// does not hold up the caller - spans in it that have not ended yet are ended
+// with the root span's error.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.root.mux.Lock()
+	var now = time.Now()
+	if s.end.IsZero() {
+		s.end, s.err = now, err
+	}
+	if s != s.root {
+		s.root.mux.Unlock()
+		return
+	}
+	var spans = append([]*Span{s}, s.spans...)
+	for _, span := range spans {
+		if span.end.IsZero() {
+			span.end, span.err = now, err
+		}
+	}
+	var body, marshalErr = json.Marshal(s.tracer.request(spans))
+	s.root.mux.Unlock()
+	if marshalErr != nil {
+		fmt.Fprintln(s.tracer.out, "failed to export trace: "+marshalErr.Error())
+		return
+	}
+
+	var t = s.tracer
+	t.pending.Add(1)
+	go func() {
+		defer t.pending.Done()
+		if err := t.export(body); err != nil {
+			fmt.Fprintln(t.out, err.Error())
+		}
+	}()
+}
+
+// export sends encoded spans to the tracer's endpoint
+func (t *Tracer) export(body []byte) error {
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export trace: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export trace: collector responded with status code %d",
+			resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP's JSON encoding of traces - only the fields Inertia uses are included
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// request encodes the given spans as an OTLP export request
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	var encoded = make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		var span = otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attributes),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+		}
+		encoded = append(encoded, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{
+			Attributes: otlpAttributes(map[string]string{"service.name": t.service}),
+		},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/ubclaunchpad/inertia"},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+// otlpAttributes encodes the given attributes as OTLP attributes
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	var encoded = make([]otlpAttribute, 0, len(attributes))
+	for k, v := range attributes {
+		encoded = append(encoded, otlpAttribute{Key: k, Value: otlpValue{StringValue: v}})
+	}
+	return encoded
+}
+
+// randomHex generates n random bytes, encoded as hex
+func randomHex(n int) string {
+	var b = make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTracer(t *testing.T) {
+	assert.Nil(t, NewTracer("", "inertia", nil))
+	assert.Equal(t, "http://collector:4318/v1/traces",
+		NewTracer("http://collector:4318/", "inertia", nil).endpoint)
+	assert.Equal(t, "http://collector:4318/v1/traces",
+		NewTracer("http://collector:4318/v1/traces", "inertia", nil).endpoint)
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	var span = tracer.Start("deploy")
+	assert.Nil(t, span)
+
+	// None of these should panic
+	span.SetAttribute("key", "value")
+	span.Child("build").End(nil)
+	span.End(nil)
+	tracer.Wait()
+}
+
+func TestSpan_End(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		buildErr   error
+		wantErr    bool
+		wantStatus int
+	}{
+		{"ok", http.StatusOK, nil, false, otlpStatusOK},
+		{"failed phase", http.StatusOK, errors.New("build failed"), false, otlpStatusError},
+		{"rejected", http.StatusBadRequest, nil, true, otlpStatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received otlpRequest
+			var requests = 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				assert.Equal(t, otlpTracesPath, r.URL.Path)
+				assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			var out bytes.Buffer
+			var tracer = NewTracer(ts.URL, "inertiad", &out)
+			var root = tracer.Start("deploy")
+			root.SetAttribute("inertia.initiator", "bobheadxi")
+			root.Child("fetch").End(nil)
+			root.Child("build").End(tt.buildErr)
+
+			// Unfinished spans end with the trace, which is exported in the
+			// background
+			root.Child("start")
+			assert.Equal(t, 0, requests)
+			root.End(nil)
+			tracer.Wait()
+			assert.Equal(t, 1, requests)
+			assert.Equal(t, tt.wantErr, out.Len() > 0)
+
+			var spans = received.ResourceSpans[0].ScopeSpans[0].Spans
+			assert.Len(t, spans, 4)
+			assert.Equal(t, "deploy", spans[0].Name)
+			assert.Equal(t, "", spans[0].ParentSpanID)
+			assert.Len(t, spans[0].TraceID, 32)
+			assert.Equal(t, "inertia.initiator", spans[0].Attributes[0].Key)
+			for _, span := range spans[1:] {
+				assert.Equal(t, spans[0].TraceID, span.TraceID)
+				assert.Equal(t, spans[0].SpanID, span.ParentSpanID)
+				assert.Len(t, span.SpanID, 16)
+			}
+			assert.Equal(t, "build", spans[2].Name)
+			assert.Equal(t, tt.wantStatus, spans[2].Status.Code)
+			assert.Equal(t, "start", spans[3].Name)
+			assert.NotEqual(t, "", spans[3].EndTimeUnixNano)
+		})
+	}
+}
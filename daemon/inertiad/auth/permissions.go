@@ -141,14 +141,13 @@ func (h *PermissionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if token is valid
+	// Check if token is valid - requests without a valid token are
+	// unauthenticated, while forbidden is reserved for authenticated users
+	// without sufficient permissions
 	claims, err := h.sessions.GetSession(r)
 	if err != nil {
-		if err == errSessionNotFound {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
-		} else {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
@@ -172,6 +171,15 @@ func (h *PermissionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.mux.ServeHTTP(w, r.WithContext(ctx))
 }
 
+// GetRequestUser returns the name of the user that made the given request, or
+// an empty string if the request was not authenticated
+func GetRequestUser(r *http.Request) string {
+	if user, ok := r.Context().Value(ctxUsername).(string); ok {
+		return user
+	}
+	return ""
+}
+
 // AttachPublicHandler attaches given path and handler and makes it publicly available
 func (h *PermissionsHandler) AttachPublicHandler(path string, handler http.Handler) {
 	h.mux.Handle(path, handler)
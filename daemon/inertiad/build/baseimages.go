@@ -0,0 +1,94 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+)
+
+// getProjectImages returns the images referenced by the build file of a
+// project with the given build type - the base images of Dockerfile projects,
+// or the images used by docker-compose services. Static projects have no build
+// file, and so no images.
+func getProjectImages(buildType, buildDirectory, buildFilePath string) ([]string, error) {
+	if strings.ToLower(buildType) == StaticBuild {
+		return nil, nil
+	}
+	var getImages = getComposeImages
+	var buildFile = "docker-compose.yml"
+	if strings.ToLower(buildType) == DockerfileBuild {
+		getImages = getDockerfileBaseImages
+		buildFile = "Dockerfile"
+	}
+	if buildFilePath != "" {
+		buildFile = buildFilePath
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(buildDirectory, buildFile))
+	if err != nil {
+		return nil, err
+	}
+	return getImages(contents), nil
+}
+
+// hasNewerDigest checks if the given upstream digest is missing from the
+// digests of a pulled image, meaning a newer version of the image is
+// available upstream
+func hasNewerDigest(repoDigests []string, upstream string) bool {
+	for _, d := range repoDigests {
+		if strings.HasSuffix(d, "@"+upstream) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateBaseImages pulls newer versions of the images a project is built
+// from, or that its docker-compose services run, if they have been updated
+// upstream since they were pulled, and returns the images that were updated.
+// Images that have not been pulled yet, or that are referenced through
+// variables, are skipped.
+func UpdateBaseImages(ctx context.Context, cli *docker.Client, buildType, buildDirectory,
+	buildFilePath string, out io.Writer) ([]string, error) {
+	images, err := getProjectImages(buildType, buildDirectory, buildFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated = []string{}
+	for _, image := range images {
+		if strings.Contains(image, "$") {
+			continue
+		}
+		local, _, err := cli.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			if docker.IsErrNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		upstream, err := cli.DistributionInspect(ctx, image, "")
+		if err != nil {
+			fmt.Fprintf(out, "Unable to check image %s for updates: %s\n", image, err.Error())
+			continue
+		}
+		if !hasNewerDigest(local.RepoDigests, upstream.Descriptor.Digest.String()) {
+			continue
+		}
+
+		fmt.Fprintf(out, "Pulling updated image %s...\n", image)
+		reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull image '%s': %s", image, err.Error())
+		}
+		io.Copy(ioutil.Discard, reader)
+		reader.Close()
+		updated = append(updated, image)
+	}
+	return updated, nil
+}
@@ -0,0 +1,67 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hasNewerDigest(t *testing.T) {
+	const digest = "sha256:abcdef"
+	tests := []struct {
+		name        string
+		repoDigests []string
+		want        bool
+	}{
+		{"up to date", []string{"alpine@sha256:012345", "alpine@" + digest}, false},
+		{"outdated", []string{"alpine@sha256:012345"}, true},
+		{"no digests", []string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hasNewerDigest(tt.repoDigests, digest))
+		})
+	}
+}
+
+func Test_getProjectImages(t *testing.T) {
+	type args struct {
+		buildType     string
+		buildFilePath string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{"dockerfile", args{DockerfileBuild, ""}, []string{"node:10"}, false},
+		{"custom dockerfile", args{DockerfileBuild, "Dockerfile.prod"}, []string{"node:10-alpine"}, false},
+		{"docker-compose", args{DockerComposeBuild, ""}, []string{"postgres:11"}, false},
+		{"missing build file", args{DockerfileBuild, "Dockerfile.dev"}, nil, true},
+	}
+	dir, err := ioutil.TempDir("", "inertia-images")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for name, contents := range map[string]string{
+		"Dockerfile":         "FROM node:10\n",
+		"Dockerfile.prod":    "FROM node:10-alpine\n",
+		"docker-compose.yml": "services:\n  db:\n    image: postgres:11\n",
+	} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getProjectImages(tt.args.buildType, dir, tt.args.buildFilePath)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
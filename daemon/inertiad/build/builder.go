@@ -7,35 +7,65 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	docker "github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 )
 
+const (
+	// DockerfileBuild is the build type for projects built from a Dockerfile
+	DockerfileBuild = "dockerfile"
+
+	// DockerComposeBuild is the build type for projects built with docker-compose
+	DockerComposeBuild = "docker-compose"
+
+	// StaticBuild is the build type for static sites, which are served by the
+	// daemon instead of being run in containers
+	StaticBuild = "static"
+)
+
+// DefaultNonRootUser is the user project containers are run as if a non-root
+// user is enforced but none is configured - this is the 'nobody' user
+const DefaultNonRootUser = "65534:65534"
+
+// SupportedBuildTypes returns the project build types the builder supports
+func SupportedBuildTypes() []string {
+	return []string{DockerfileBuild, DockerComposeBuild, StaticBuild}
+}
+
 // ContainerBuilder builds projects and returns a callback that can be used to deploy the project.
 // No relation to Bob the Builder, though a Bob did write this.
 type ContainerBuilder interface {
-	Build(string, Config, *docker.Client, io.Writer) (func() error, error)
+	Build(context.Context, string, Config, *docker.Client, io.Writer) (func() error, error)
+	Recreate(string, string, Config, *docker.Client, io.Writer, time.Duration) error
 	GetBuildStageName() string
-	StopContainers(*docker.Client, io.Writer) error
+	GetStaticSiteDirectory() string
+	StopContainers(*docker.Client, io.Writer, time.Duration, ...string) error
 	Prune(*docker.Client, io.Writer) error
-	PruneAll(*docker.Client, io.Writer) error
+	PruneAll(*docker.Client, io.Writer, ...string) error
 }
 
 // ProjectBuilder builds projects and returns a callback that can be used to deploy the project.
 // No relation to Bob the Builder, though a Bob did write this.
-type ProjectBuilder func(Config, *docker.Client, io.Writer) (func() error, error)
+type ProjectBuilder func(context.Context, Config, *docker.Client, io.Writer) (func() error, error)
 
 // Builder manages build tools and executes builds
 type Builder struct {
 	buildStageName       string
 	dockerComposeVersion string
+	buildxCacheDirectory string
+	staticSiteDirectory  string
 	stopper              containers.ContainerStopper
 
 	builders map[string]ProjectBuilder
@@ -46,11 +76,14 @@ func NewBuilder(conf cfg.Config, stopper containers.ContainerStopper) *Builder {
 	b := &Builder{
 		buildStageName:       "build",
 		dockerComposeVersion: conf.DockerComposeVersion,
+		buildxCacheDirectory: path.Join(conf.DataDirectory, "buildx-cache"),
+		staticSiteDirectory:  path.Join(conf.DataDirectory, StaticSiteDirectory),
 		stopper:              stopper,
 	}
 	b.builders = map[string]ProjectBuilder{
-		"dockerfile":     b.dockerBuild,
-		"docker-compose": b.dockerCompose,
+		DockerfileBuild:    b.dockerBuild,
+		DockerComposeBuild: b.dockerCompose,
+		StaticBuild:        b.staticBuild,
 	}
 	return b
 }
@@ -59,19 +92,35 @@ func NewBuilder(conf cfg.Config, stopper containers.ContainerStopper) *Builder {
 // build projects
 func (b *Builder) GetBuildStageName() string { return b.buildStageName }
 
-// StopContainers stops containers and cleans up assets
-func (b *Builder) StopContainers(docker *docker.Client, out io.Writer) error {
-	return b.stopper(docker, out)
+// GetStaticSiteDirectory returns the directory the sites of static projects
+// are published to
+func (b *Builder) GetStaticSiteDirectory() string { return b.staticSiteDirectory }
+
+// StopContainers stops containers and cleans up assets, giving containers the
+// given timeout to stop before they are killed. Containers with the given
+// names are left running.
+func (b *Builder) StopContainers(docker *docker.Client, out io.Writer, timeout time.Duration,
+	keep ...string) error {
+	return b.stopper(docker, out, timeout, keep...)
 }
 
-// Prune cleans up Dokcer assets
+// Prune cleans up Docker assets, including project networks that are no
+// longer in use
 func (b *Builder) Prune(docker *docker.Client, out io.Writer) error {
+	if err := pruneNetworks(context.Background(), docker); err != nil {
+		fmt.Fprintln(out, "failed to remove project networks: "+err.Error())
+	}
 	return containers.Prune(docker)
 }
 
-// PruneAll forcibly removes Docker assets
-func (b *Builder) PruneAll(docker *docker.Client, out io.Writer) error {
-	return containers.PruneAll(docker, b.dockerComposeVersion)
+// PruneAll forcibly removes Docker assets, except images with repo tags
+// containing any of the given exceptions. Project networks that are no longer
+// in use are removed as well.
+func (b *Builder) PruneAll(docker *docker.Client, out io.Writer, exceptions ...string) error {
+	if err := pruneNetworks(context.Background(), docker); err != nil {
+		fmt.Fprintln(out, "failed to remove project networks: "+err.Error())
+	}
+	return containers.PruneAll(docker, append(exceptions, b.dockerComposeVersion, buildxImage)...)
 }
 
 // Config contains parameters required for builds to execute
@@ -82,10 +131,96 @@ type Config struct {
 	BuildDirectory string
 
 	EnvValues []string
+
+	// ContainerUser is the user (name or UID[:GID]) to run project containers
+	// as. It only applies to Dockerfile builds - docker-compose projects
+	// should set 'user' on each service in their docker-compose file.
+	ContainerUser string
+
+	// EnforceNonRootUser runs Dockerfile project containers as
+	// DefaultNonRootUser if neither ContainerUser nor the image's Dockerfile
+	// specify a user
+	EnforceNonRootUser bool
+
+	// InitJob, if set, is run to completion before the project is started
+	InitJob *api.InitJob
+
+	// Labels are additional labels to apply to project containers, keyed by
+	// service name - for Dockerfile projects, the service name is the
+	// project name
+	Labels map[string]map[string]string
+
+	// Resources configures resource reservations for project containers,
+	// keyed by service name like Labels
+	Resources map[string]api.Resources
+
+	// Networking configures host name resolution for project containers,
+	// keyed by service name like Labels
+	Networking map[string]api.Networking
+
+	// Healthchecks override the healthchecks of project containers' images,
+	// keyed by service name like Labels
+	Healthchecks map[string]api.Healthcheck
+
+	// Commands override the entrypoints and commands of project containers'
+	// images, keyed by service name like Labels
+	Commands map[string]api.CommandOverride
+
+	// Readiness configures checks that project containers must pass after
+	// they are started, keyed by service name like Labels
+	Readiness map[string]api.Readiness
+
+	// Started, if set, is called once project containers are running, before
+	// waiting for them to pass their readiness checks
+	Started func()
+
+	// Buildx builds Dockerfile projects with buildx and a persistent build
+	// cache instead of the classic builder, if set
+	Buildx *api.Buildx
+
+	// DependsOn declares containers that Dockerfile projects wait for before
+	// they are started, if set
+	DependsOn *api.DependsOn
+
+	// Static configures how static projects are built and which of their
+	// directories is served
+	Static *api.Static
+
+	// Platform is the platform, such as "linux/arm64", to build Dockerfile
+	// projects for - the host's platform is used if it is not set.
+	// docker-compose projects should set 'platform' on each service in their
+	// docker-compose file instead.
+	Platform string
+
+	// BuildTarget is the stage of a multi-stage Dockerfile to build - the
+	// last stage is built if it is not set. docker-compose projects should
+	// set 'target' in the build configuration of each service instead.
+	BuildTarget string
+
+	// BuildArgs are values for ARG instructions in the Dockerfile of
+	// Dockerfile projects, keyed by name. Changing a value invalidates the
+	// build cache from the first instruction that uses it onwards.
+	BuildArgs map[string]string
+
+	// VerifyImagesKey is a cosign public key - if it is set, the images a
+	// project is built from must be signed by the owner of this key
+	VerifyImagesKey string
+
+	// SkipBuild starts the project from its existing images instead of
+	// building them, for example when rolling back to a retained deployment
+	SkipBuild bool
+
+	// pinnedImages maps the images referenced by the project's build file to
+	// references to the digests that were verified, if any
+	pinnedImages map[string]string
 }
 
-// Build executes build and deploy
-func (b *Builder) Build(buildType string, d Config,
+// Build executes build and deploy. If ctx is cancelled before the build
+// completes, the build is aborted and its build containers are killed. The
+// returned deploy callback only uses ctx to stop waiting for the project's
+// dependencies and readiness checks, so ctx should not be cancelled until the
+// callback returns unless the deployment is cancelled.
+func (b *Builder) Build(ctx context.Context, buildType string, d Config,
 	cli *docker.Client, out io.Writer) (func() error, error) {
 	// Use the appropriate build method
 	builder, found := b.builders[strings.ToLower(buildType)]
@@ -96,9 +231,23 @@ func (b *Builder) Build(buildType string, d Config,
 		builder = b.dockerCompose
 	}
 
+	// Kill build containers if the build is cancelled, since they are waited
+	// on independently of ctx
+	var done = make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			for _, name := range []string{b.buildStageName, BuildxContainerName} {
+				cli.ContainerKill(context.Background(), name, "SIGKILL")
+			}
+		case <-done:
+		}
+	}()
+
 	// Build project
 	reportDeployInit(buildType, d.Name, out)
-	deploy, err := builder(d, cli, out)
+	deploy, err := builder(ctx, d, cli, out)
 	if err != nil {
 		return func() error { return nil }, err
 	}
@@ -107,6 +256,74 @@ func (b *Builder) Build(buildType string, d Config,
 	return deploy, nil
 }
 
+// RecreateContainerName is the name of the container used to recreate
+// docker-compose services
+const RecreateContainerName = "docker-compose-recreate"
+
+// Recreate stops and removes the container of a single service, then creates
+// and starts it again from its existing image with the given configuration,
+// leaving other services untouched. The service's container is given timeout
+// to stop before it is killed. For Dockerfile projects, the only service is
+// the project itself.
+func (b *Builder) Recreate(buildType, service string, d Config,
+	cli *docker.Client, out io.Writer, timeout time.Duration) error {
+	var ctx = context.Background()
+
+	// Init jobs are only run as part of full deployments
+	d.InitJob = nil
+	d.SkipBuild = true
+
+	if buildType == StaticBuild {
+		return errors.New("static projects have no services to recreate")
+	}
+	if buildType == DockerfileBuild {
+		if service != d.Name {
+			return fmt.Errorf("service '%s' not found - the only service of "+
+				"Dockerfile projects is the project, '%s'", service, d.Name)
+		}
+		fmt.Fprintf(out, "Stopping %s...\n", service)
+		if err := cli.ContainerStop(ctx, d.Name, &timeout); err != nil {
+			return err
+		}
+		if err := cli.ContainerRemove(ctx, d.Name, types.ContainerRemoveOptions{}); err != nil {
+			return err
+		}
+		deploy, err := b.dockerBuild(ctx, d, cli, out)
+		if err != nil {
+			return err
+		}
+		return deploy()
+	}
+
+	// Let docker-compose stop and recreate only the requested service
+	dockercomposeFilePath := "docker-compose.yml"
+	if d.BuildFilePath != "" {
+		dockercomposeFilePath = d.BuildFilePath
+	}
+	composeFiles, composeHostConfig, err := composeSetup(d, dockercomposeFilePath)
+	if err != nil {
+		return err
+	}
+	cli.ContainerRemove(ctx, RecreateContainerName, types.ContainerRemoveOptions{Force: true})
+	resp, err := cli.ContainerCreate(
+		ctx, &container.Config{
+			Image:      b.dockerComposeVersion,
+			WorkingDir: "/build",
+			Cmd: append(composeFiles,
+				"up", "-d", "--no-deps", "--no-build", "--force-recreate",
+				"--timeout", strconv.Itoa(int(timeout.Seconds())),
+				service),
+			Env: d.EnvValues,
+		},
+		composeHostConfig, nil, RecreateContainerName,
+	)
+	if err != nil {
+		return err
+	}
+	reportProjectStartup(service, out)
+	return containers.StartAndWait(cli, resp.ID, out)
+}
+
 // dockerCompose builds and runs project using docker-compose -
 // the following code performs the bash equivalent of:
 //
@@ -121,51 +338,116 @@ func (b *Builder) Build(buildType string, d Config,
 // separate from the daemon and the user's project, and is the
 // second container to require access to the docker socket.
 // See https://cloud.google.com/community/tutorials/docker-compose-on-container-optimized-os
-func (b *Builder) dockerCompose(d Config, cli *docker.Client,
+func (b *Builder) dockerCompose(ctx context.Context, d Config, cli *docker.Client,
 	out io.Writer) (func() error, error) {
 	fmt.Fprintln(out, "Setting up docker-compose...")
-	ctx := context.Background()
+	if d.ContainerUser != "" {
+		fmt.Fprintln(out, "Container user is ignored for docker-compose projects - "+
+			"set 'user' on your services in your docker-compose file instead")
+	}
+	if d.Platform != "" {
+		fmt.Fprintln(out, "Platform is ignored for docker-compose projects - "+
+			"set 'platform' on your services in your docker-compose file instead")
+	}
+	if d.BuildTarget != "" {
+		fmt.Fprintln(out, "Build target is ignored for docker-compose projects - "+
+			"set 'target' in the build configuration of your services instead")
+	}
+	if d.Buildx != nil {
+		fmt.Fprintln(out, "Buildx is ignored for docker-compose projects")
+	}
+	if d.DependsOn != nil {
+		fmt.Fprintln(out, "Dependencies are ignored for docker-compose projects - "+
+			"set 'depends_on' on your services in your docker-compose file instead")
+	}
+
+	if d.InitJob != nil && d.InitJob.Service == "" {
+		return nil, errors.New("init job for docker-compose projects requires a service")
+	}
+	if err := validateServiceReadiness(d.Readiness); err != nil {
+		return nil, err
+	}
 
 	dockercomposeFilePath := "docker-compose.yml"
 	if d.BuildFilePath != "" {
 		dockercomposeFilePath = d.BuildFilePath
 	}
 
+	if !d.SkipBuild {
+		// Services are started from the verified digests of their images
+		pinned, err := verifyProjectImages(ctx, cli, d, dockercomposeFilePath,
+			getComposeImages, out)
+		if err != nil {
+			return nil, err
+		}
+		d.pinnedImages = pinned
+		if err := b.dockerComposeBuild(ctx, d, cli, dockercomposeFilePath, out); err != nil {
+			return nil, err
+		}
+	}
+
+	// Set up docker-compose up
+	reportProjectContainerCreateBegin(d.Name, out)
+	composeFiles, composeHostConfig, err := composeSetup(d, dockercomposeFilePath)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := cli.ContainerCreate(
 		ctx, &container.Config{
 			Image:      b.dockerComposeVersion,
 			WorkingDir: "/build",
-			Cmd: []string{
-				"-p", d.Name,
-				"-f", dockercomposeFilePath,
-				"build",
-			},
-			Env: d.EnvValues,
+			Cmd:        append(composeFiles, "up"),
+			Env:        d.EnvValues,
 		},
-		&container.HostConfig{
-			AutoRemove: true,
-			Binds: []string{
-				getTrueDirectory(d.BuildDirectory) + ":/build",
-				"/var/run/docker.sock:/var/run/docker.sock",
-			},
-		}, nil, b.buildStageName,
+		composeHostConfig, nil, "docker-compose",
 	)
 	if err != nil {
 		return nil, err
 	}
 	if len(resp.Warnings) > 0 {
-		fmt.Fprintln(out, "Warnings encountered on docker-compose build.")
 		warnings := strings.Join(resp.Warnings, "\n")
 		return nil, errors.New(warnings)
 	}
+	reportProjectContainerCreateComplete(d.Name, out)
 
-	// Start container to build project
-	reportProjectBuildBegin(d.Name, out)
-	if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
-		return nil, err
-	}
-	reportProjectBuildComplete(d.Name, out)
+	return func() error {
+		var runCtx = context.Background()
+		if d.InitJob != nil {
+			// Run the job service through docker-compose, so that it has
+			// access to the project's networks and dependencies
+			var cmd = append(append(composeFiles,
+				"run", "--rm", d.InitJob.Service,
+			), d.InitJob.Command...)
+			if err := runInitJob(runCtx, cli, "docker-compose-init", &container.Config{
+				Image:      b.dockerComposeVersion,
+				WorkingDir: "/build",
+				Cmd:        cmd,
+				Env:        d.EnvValues,
+			}, &container.HostConfig{Binds: composeHostConfig.Binds}, out); err != nil {
+				return err
+			}
+		}
+		if err := b.run(runCtx, cli, d.Name, resp.ID, out); err != nil {
+			return err
+		}
+		if d.Started != nil {
+			d.Started()
+		}
+		if len(d.Readiness) == 0 {
+			return nil
+		}
+
+		// Waiting for readiness stops if the deployment is cancelled
+		return waitForReadiness(ctx, cli, d.Readiness, func(service string) (string, error) {
+			return findComposeContainer(cli, d.Name, service)
+		}, out)
+	}, nil
+}
 
+// composeSetup returns the docker-compose arguments that select the project's
+// docker-compose files, and the host configuration of docker-compose
+// containers that use them
+func composeSetup(d Config, dockercomposeFilePath string) ([]string, *container.HostConfig, error) {
 	// @TODO allow configuration
 	var (
 		dockerComposeRelFilePath = "docker-compose.yml"
@@ -173,51 +455,73 @@ func (b *Builder) dockerCompose(d Config, cli *docker.Client,
 			getTrueDirectory(d.BuildDirectory), dockerComposeRelFilePath,
 		)
 	)
+	var composeHostConfig = &container.HostConfig{
+		AutoRemove: true,
+		Binds: []string{
+			dockerComposeFilePath + ":/build/docker-compose.yml",
+			"/var/run/docker.sock:/var/run/docker.sock",
+		},
+	}
+	var composeFiles = []string{"-p", d.Name, "-f", dockercomposeFilePath}
 
-	// Set up docker-compose up
-	reportProjectContainerCreateBegin(d.Name, out)
-	resp, err = cli.ContainerCreate(
+	// Apply labels and configured service options through an override file
+	if err := writeComposeOverride(d.BuildDirectory, dockercomposeFilePath, d); err != nil {
+		return nil, nil, fmt.Errorf("failed to apply service configuration: %s", err.Error())
+	}
+	composeHostConfig.Binds = append(composeHostConfig.Binds,
+		path.Join(getTrueDirectory(d.BuildDirectory), composeOverrideFile)+
+			":/build/"+composeOverrideFile)
+	composeFiles = append(composeFiles, "-f", composeOverrideFile)
+	return composeFiles, composeHostConfig, nil
+}
+
+// dockerComposeBuild builds the images of the project's docker-compose
+// services using the given docker-compose file
+func (b *Builder) dockerComposeBuild(ctx context.Context, d Config, cli *docker.Client,
+	dockercomposeFilePath string, out io.Writer) error {
+	resp, err := cli.ContainerCreate(
 		ctx, &container.Config{
 			Image:      b.dockerComposeVersion,
 			WorkingDir: "/build",
 			Cmd: []string{
 				"-p", d.Name,
 				"-f", dockercomposeFilePath,
-				"up",
+				"build",
 			},
 			Env: d.EnvValues,
 		},
 		&container.HostConfig{
 			AutoRemove: true,
 			Binds: []string{
-				dockerComposeFilePath + ":/build/docker-compose.yml",
+				getTrueDirectory(d.BuildDirectory) + ":/build",
 				"/var/run/docker.sock:/var/run/docker.sock",
 			},
-		}, nil, "docker-compose",
+		}, nil, b.buildStageName,
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if len(resp.Warnings) > 0 {
+		fmt.Fprintln(out, "Warnings encountered on docker-compose build.")
 		warnings := strings.Join(resp.Warnings, "\n")
-		return nil, errors.New(warnings)
+		return errors.New(warnings)
 	}
-	reportProjectContainerCreateComplete(d.Name, out)
 
-	return func() error { return b.run(ctx, cli, d.Name, resp.ID, out) }, nil
+	// Start container to build project
+	reportProjectBuildBegin(d.Name, out)
+	if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
+		return err
+	}
+	reportProjectBuildComplete(d.Name, out)
+	return nil
 }
 
 // dockerBuild builds project from Dockerfile, and returns a callback function to deploy it
-func (b *Builder) dockerBuild(d Config, cli *docker.Client,
+func (b *Builder) dockerBuild(ctx context.Context, d Config, cli *docker.Client,
 	out io.Writer) (func() error, error) {
-	var (
-		ctx      = context.Background()
-		buildCtx = bytes.NewBuffer(nil)
-	)
-
-	// Create build context
-	if err := buildTar(d.BuildDirectory, buildCtx); err != nil {
-		return nil, err
+	var buildCtx = bytes.NewBuffer(nil)
+	if d.InitJob != nil && len(d.InitJob.Command) == 0 {
+		return nil, errors.New("init job for Dockerfile projects requires a command")
 	}
 
 	// @TODO: support configuration
@@ -226,45 +530,158 @@ func (b *Builder) dockerBuild(d Config, cli *docker.Client,
 		dockerFilePath = d.BuildFilePath
 	}
 
+	// Validate configuration before building so that mistakes fail fast
+	labels, err := getContainerLabels(d.Name, d.Name, d.Labels[d.Name])
+	if err != nil {
+		return nil, err
+	}
+	resources, err := getContainerResources(d.Resources[d.Name])
+	if err != nil {
+		return nil, err
+	}
+	var networking = d.Networking[d.Name]
+	if err := validateNetworking(networking); err != nil {
+		return nil, err
+	}
+	readiness, checkReadiness := d.Readiness[d.Name]
+	if checkReadiness {
+		if err := validateReadiness(readiness); err != nil {
+			return nil, err
+		}
+	}
+	healthcheck, err := getContainerHealthcheck(d.Healthchecks[d.Name])
+	if err != nil {
+		return nil, err
+	}
+
+	// Target the host's platform unless one is configured, so that images
+	// for other architectures aren't silently pulled and run under emulation
+	var hostPlatform string
+	if info, err := cli.Info(ctx); err == nil {
+		hostPlatform = getHostPlatform(info.OSType, info.Architecture)
+	}
+	var platform = d.Platform
+	if platform == "" {
+		platform = hostPlatform
+	} else if err := validatePlatform(platform); err != nil {
+		return nil, err
+	}
+
 	// Build image
 	reportProjectBuildBegin(d.Name, out)
 	imageName := "inertia-build/" + d.Name
-	buildResp, err := cli.ImageBuild(
-		ctx, buildCtx, types.ImageBuildOptions{
-			Tags:           []string{imageName},
-			Remove:         true,
-			Dockerfile:     dockerFilePath,
-			SuppressOutput: false,
-		},
-	)
-	if err != nil {
-		return nil, err
+	if !d.SkipBuild {
+		// Build from the verified digests of base images, so that the images
+		// used are the ones that were verified even if their tags have moved
+		pinned, err := verifyProjectImages(ctx, cli, d, dockerFilePath,
+			getDockerfileBaseImages, out)
+		if err != nil {
+			return nil, err
+		}
+		if len(pinned) > 0 {
+			if dockerFilePath, err = writePinnedDockerfile(d.BuildDirectory,
+				dockerFilePath, pinned); err != nil {
+				return nil, fmt.Errorf("failed to pin verified images: %s", err.Error())
+			}
+		}
+
+		if d.Buildx != nil {
+			if err := b.buildxBuild(ctx, cli, d, dockerFilePath, imageName,
+				platform, out); err != nil {
+				return nil, err
+			}
+		} else {
+			// Create build context, leaving out excluded files
+			ignore, ignoreFile, err := readContextIgnore(d.BuildDirectory)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read build context exclusions: %s", err.Error())
+			}
+			if ignore != nil {
+				fmt.Fprintf(out, "Excluding files in %s from build context\n", ignoreFile)
+				ignore.keep = []string{path.Clean(dockerFilePath), ".dockerignore"}
+			}
+			if err := buildTar(d.BuildDirectory, ignore, buildCtx); err != nil {
+				return nil, err
+			}
+			buildResp, err := cli.ImageBuild(
+				ctx, buildCtx, types.ImageBuildOptions{
+					Tags:           []string{imageName},
+					Remove:         true,
+					Dockerfile:     dockerFilePath,
+					SuppressOutput: false,
+					Platform:       platform,
+					Target:         d.BuildTarget,
+					BuildArgs:      getBuildArgs(d.BuildArgs),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			stop := make(chan struct{})
+			log.FlushRoutine(out, buildResp.Body, stop)
+			close(stop)
+			buildResp.Body.Close()
+		}
 	}
-	stop := make(chan struct{})
-	log.FlushRoutine(out, buildResp.Body, stop)
-	close(stop)
-	buildResp.Body.Close()
 	// Get image details - this will check if image build was successful
 	image, _, err := cli.ImageInspectWithRaw(ctx, imageName)
 	if err != nil {
 		return nil, fmt.Errorf("image build failed: %s", err.Error())
 	}
+	if imagePlatform := getHostPlatform(image.Os, image.Architecture); hostPlatform != "" &&
+		imagePlatform != "" && imagePlatform != hostPlatform {
+		fmt.Fprintf(out, "[WARNING] Image platform %s does not match host platform %s - "+
+			"the project will run under emulation, if at all\n", imagePlatform, hostPlatform)
+	}
 	portMap := nat.PortMap{}
 	for p := range image.Config.ExposedPorts {
 		portMap[p] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: p.Port()}}
 	}
 	reportProjectBuildComplete(d.Name, out)
 
+	// Determine which user to run the container as
+	user := getContainerUser(d, image.Config.User)
+	if user != "" {
+		fmt.Fprintf(out, "Container will run as user %s\n", user)
+	}
+
+	// Aliases are only supported on user-defined networks, so attach the
+	// container to one if any are configured
+	var networkMode container.NetworkMode
+	var endpoints *network.NetworkingConfig
+	if len(networking.Aliases) > 0 {
+		var networkName = d.Name + "_default"
+		if err := ensureNetwork(ctx, cli, networkName, d.Name); err != nil {
+			return nil, fmt.Errorf("failed to create network %s: %s", networkName, err.Error())
+		}
+		networkMode = container.NetworkMode(networkName)
+		endpoints = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				networkName: {Aliases: networking.Aliases},
+			},
+		}
+	}
+
 	// Create container from image
 	reportProjectContainerCreateBegin(d.Name, out)
+	var command = d.Commands[d.Name]
 	containerResp, err := cli.ContainerCreate(
 		ctx, &container.Config{
-			Image: imageName,
-			Env:   d.EnvValues,
+			Image:       imageName,
+			Env:         d.EnvValues,
+			User:        user,
+			Labels:      labels,
+			Healthcheck: healthcheck,
+			Entrypoint:  command.Entrypoint,
+			Cmd:         command.Command,
 		},
 		&container.HostConfig{
 			PortBindings: portMap,
-		}, nil, d.Name)
+			Resources:    resources,
+			ExtraHosts:   networking.ExtraHosts,
+			DNS:          networking.DNS,
+			NetworkMode:  networkMode,
+		}, endpoints, d.Name)
 	if err != nil {
 		if strings.Contains(err.Error(), "No such image") {
 			return nil, errors.New("Image build was unsuccessful")
@@ -277,7 +694,96 @@ func (b *Builder) dockerBuild(d Config, cli *docker.Client,
 	}
 	reportProjectContainerCreateComplete(d.Name, out)
 
-	return func() error { return b.run(ctx, cli, d.Name, containerResp.ID, out) }, nil
+	return func() error {
+		// Waiting for dependencies and readiness stops if the deployment is
+		// cancelled, but starting the project is not affected by ctx
+		if d.DependsOn != nil {
+			if err := waitForDependencies(ctx, cli, *d.DependsOn, out); err != nil {
+				return err
+			}
+		}
+		var runCtx = context.Background()
+		if d.InitJob != nil {
+			if err := runInitJob(runCtx, cli, d.Name+"-init", &container.Config{
+				Image: imageName,
+				Env:   d.EnvValues,
+				User:  user,
+				Cmd:   d.InitJob.Command,
+			}, &container.HostConfig{
+				ExtraHosts: networking.ExtraHosts,
+				DNS:        networking.DNS,
+			}, out); err != nil {
+				return err
+			}
+		}
+		if err := b.run(runCtx, cli, d.Name, containerResp.ID, out); err != nil {
+			return err
+		}
+		if d.Started != nil {
+			d.Started()
+		}
+		if !checkReadiness {
+			return nil
+		}
+		return waitForReadiness(ctx, cli, map[string]api.Readiness{d.Name: readiness},
+			func(string) (string, error) { return containerResp.ID, nil }, out)
+	}, nil
+}
+
+// ensureNetwork creates a bridge network with the given name for the given
+// project if it does not exist yet
+func ensureNetwork(ctx context.Context, cli *docker.Client, name, project string) error {
+	if _, err := cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{}); err == nil {
+		return nil
+	} else if !docker.IsErrNotFound(err) {
+		return err
+	}
+	_, err := cli.NetworkCreate(ctx, name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels:         map[string]string{LabelProject: project},
+	})
+	return err
+}
+
+// pruneNetworks removes the networks created for projects by ensureNetwork
+// that are not used by any containers
+func pruneNetworks(ctx context.Context, cli *docker.Client) error {
+	_, err := cli.NetworksPrune(ctx, filters.NewArgs(
+		filters.KeyValuePair{Key: "label", Value: LabelProject}))
+	return err
+}
+
+// runInitJob creates a container with the given name and configuration, and
+// runs it to completion. An error is returned if the container exits with a
+// non-zero status. The container is removed once it exits.
+func runInitJob(ctx context.Context, cli *docker.Client, name string,
+	conf *container.Config, hostConf *container.HostConfig, out io.Writer) error {
+	// Clear out job containers left over from previous deployments
+	if err := cli.ContainerRemove(ctx, name, types.ContainerRemoveOptions{
+		Force: true,
+	}); err != nil && !docker.IsErrNotFound(err) {
+		return fmt.Errorf("failed to remove previous init job: %s", err.Error())
+	}
+
+	resp, err := cli.ContainerCreate(ctx, conf, hostConf, nil, name)
+	if err != nil {
+		return fmt.Errorf("failed to create init job: %s", err.Error())
+	}
+	defer func() {
+		if err := cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{
+			Force: true,
+		}); err != nil {
+			fmt.Fprintf(out, "Failed to remove init job %s: %s\n", name, err.Error())
+		}
+	}()
+
+	reportInitJobBegin(name, out)
+	if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
+		return fmt.Errorf("init job failed: %s", err.Error())
+	}
+	reportInitJobComplete(name, out)
+	return nil
 }
 
 // run starts project and tracks all active project containers and pipes an error
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	docker "github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
@@ -29,7 +30,7 @@ const (
 )
 
 // killTestContainers is a helper for tests - it implements project.ContainerStopper
-func killTestContainers(cli *docker.Client, w io.Writer) error {
+func killTestContainers(cli *docker.Client, w io.Writer, timeout time.Duration, keep ...string) error {
 	ctx := context.Background()
 	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
 	if err != nil {
@@ -96,7 +97,7 @@ func TestBuilder_Build(t *testing.T) {
 			)
 
 			// Run build
-			deploy, err := b.Build(tt.args.buildType, Config{
+			deploy, err := b.Build(context.Background(), tt.args.buildType, Config{
 				Name:           testProjectName,
 				BuildFilePath:  tt.args.buildFilePath,
 				BuildDirectory: testProjectDir,
@@ -147,10 +148,45 @@ func TestBuilder_Build(t *testing.T) {
 			assert.True(t, foundP, "project container should be active")
 
 			// clean up
-			err = killTestContainers(cli, nil)
+			err = killTestContainers(cli, nil, 0)
 			assert.Nil(t, err)
 			cli.ContainersPrune(context.Background(), filters.Args{})
 			time.Sleep(5 * time.Second)
 		})
 	}
 }
+
+func Test_runInitJob(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		cmd     []string
+		wantErr bool
+	}{
+		{"job succeeds", []string{"true"}, false},
+		{"job fails", []string{"false"}, true},
+	}
+
+	cli, err := containers.NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx = context.Background()
+			var name = "test_init_job"
+			err := runInitJob(ctx, cli, name, &container.Config{
+				Image: "alpine",
+				Cmd:   tt.cmd,
+			}, &container.HostConfig{}, os.Stdout)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			// The job should always be removed
+			_, err = cli.ContainerInspect(ctx, name)
+			assert.True(t, docker.IsErrNotFound(err))
+		})
+	}
+}
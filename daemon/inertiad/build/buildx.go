@@ -0,0 +1,135 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+const (
+	// buildxImage is the image used to run buildx, which includes the docker
+	// CLI and the buildx plugin
+	buildxImage = "docker:24.0-cli"
+
+	// buildxBuilder is the name of the persistent buildx builder that
+	// Dockerfile projects are built with, so that its cache is kept between
+	// builds
+	buildxBuilder = "inertia"
+
+	// BuildxContainerName is the name of the container buildx is run in
+	BuildxContainerName = "inertia-buildx"
+
+	// Buildx cache modes
+	buildxCacheLocal    = "local"
+	buildxCacheRegistry = "registry"
+	buildxCacheNone     = "none"
+)
+
+// buildxScript creates the persistent builder if it does not exist yet, then
+// builds with the given arguments. Local cache is exported to a new directory
+// that replaces the previous cache once the build succeeds, since the local
+// cache exporter never removes stale entries.
+const buildxScript = `set -e
+docker buildx inspect ` + buildxBuilder + ` >/dev/null 2>&1 ||
+	docker buildx create --name ` + buildxBuilder + ` --driver docker-container >/dev/null
+docker buildx build --builder ` + buildxBuilder + ` "$@"
+if [ -d /cache/next ]; then
+	rm -rf /cache/current
+	mv /cache/next /cache/current
+fi`
+
+// getBuildxArgs returns the arguments to 'docker buildx build' to build the
+// given target stage of a Dockerfile into the image with the given name, and
+// load it into the host's image store
+func getBuildxArgs(opts api.Buildx, dockerfile, imageName, platform, target string,
+	buildArgs map[string]string) ([]string, error) {
+	var args = []string{"--load", "--tag", imageName, "--file", dockerfile}
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	if target != "" {
+		args = append(args, "--target", target)
+	}
+	var names = make([]string, 0, len(buildArgs))
+	for name := range buildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--build-arg", name+"="+buildArgs[name])
+	}
+	switch opts.Cache {
+	case "", buildxCacheLocal:
+		args = append(args,
+			"--cache-from", "type=local,src=/cache/current",
+			"--cache-to", "type=local,dest=/cache/next,mode=max")
+	case buildxCacheRegistry:
+		if opts.CacheRef == "" {
+			return nil, errors.New("buildx registry cache requires a cache reference")
+		}
+		args = append(args,
+			"--cache-from", "type=registry,ref="+opts.CacheRef,
+			"--cache-to", "type=registry,ref="+opts.CacheRef+",mode=max")
+	case buildxCacheNone:
+	default:
+		return nil, fmt.Errorf("invalid buildx cache '%s' - must be one of '%s', '%s', or '%s'",
+			opts.Cache, buildxCacheLocal, buildxCacheRegistry, buildxCacheNone)
+	}
+	return append(args, "."), nil
+}
+
+// buildxBuild builds a Dockerfile project with buildx, using a persistent
+// builder and the configured cache
+func (b *Builder) buildxBuild(ctx context.Context, cli *docker.Client, d Config,
+	dockerfile, imageName, platform string, out io.Writer) error {
+	args, err := getBuildxArgs(*d.Buildx, dockerfile, imageName, platform, d.BuildTarget,
+		d.BuildArgs)
+	if err != nil {
+		return err
+	}
+
+	reader, err := cli.ImagePull(ctx, buildxImage, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to download buildx: %s", err.Error())
+	}
+	io.Copy(ioutil.Discard, reader)
+	reader.Close()
+
+	var binds = []string{
+		getTrueDirectory(d.BuildDirectory) + ":/build",
+		"/var/run/docker.sock:/var/run/docker.sock",
+		getTrueDirectory(path.Join(b.buildxCacheDirectory, d.Name)) + ":/cache",
+	}
+	if d.Buildx.Cache == buildxCacheRegistry {
+		// Use the remote's registry credentials to push the cache
+		binds = append(binds, getTrueDirectory("/app/host/.docker")+":/root/.docker:ro")
+	}
+
+	cli.ContainerRemove(ctx, BuildxContainerName, types.ContainerRemoveOptions{Force: true})
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      buildxImage,
+		WorkingDir: "/build",
+		Entrypoint: []string{"sh", "-c", buildxScript, "buildx"},
+		Cmd:        args,
+	}, &container.HostConfig{
+		AutoRemove: true,
+		Binds:      binds,
+	}, nil, BuildxContainerName)
+	if err != nil {
+		return err
+	}
+	if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
+		return fmt.Errorf("buildx build failed: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func Test_getBuildxArgs(t *testing.T) {
+	type args struct {
+		opts      api.Buildx
+		platform  string
+		target    string
+		buildArgs map[string]string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		contains []string
+		wantErr  bool
+	}{
+		{"default cache", args{api.Buildx{}, "", "", nil},
+			[]string{"type=local,src=/cache/current", "type=local,dest=/cache/next,mode=max"}, false},
+		{"platform", args{api.Buildx{Cache: "none"}, "linux/arm64", "", nil},
+			[]string{"--platform", "linux/arm64"}, false},
+		{"target", args{api.Buildx{Cache: "none"}, "", "production", nil},
+			[]string{"--target", "production"}, false},
+		{"build args", args{api.Buildx{Cache: "none"}, "", "", map[string]string{"VERSION": "1.2.3", "PROFILE": "a b"}},
+			[]string{"--build-arg", "VERSION=1.2.3", "PROFILE=a b"}, false},
+		{"registry cache", args{api.Buildx{Cache: "registry", CacheRef: "reg.io/app:cache"}, "", "", nil},
+			[]string{"type=registry,ref=reg.io/app:cache", "type=registry,ref=reg.io/app:cache,mode=max"}, false},
+		{"registry cache without ref", args{api.Buildx{Cache: "registry"}, "", "", nil}, nil, true},
+		{"invalid cache", args{api.Buildx{Cache: "s3"}, "", "", nil}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getBuildxArgs(tt.args.opts, "Dockerfile", "inertia-build/wow",
+				tt.args.platform, tt.args.target, tt.args.buildArgs)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, []string{"--load", "--tag", "inertia-build/wow", "--file", "Dockerfile"}, got[:5])
+			assert.Equal(t, ".", got[len(got)-1])
+			for _, c := range tt.contains {
+				assert.Contains(t, got, c)
+			}
+			if tt.args.opts.Cache == "none" {
+				assert.NotContains(t, got, "--cache-from")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// defaultDependencyTimeout is how long dependencies are waited for if no
+	// timeout is configured
+	defaultDependencyTimeout = 60 * time.Second
+
+	// dependencyPollInterval is how often dependencies are checked
+	dependencyPollInterval = time.Second
+)
+
+// waitForDependencies waits for each of the given dependencies to be ready, in
+// the order they are declared, returning an error if any of them is not ready
+// before the timeout or if ctx is cancelled. Dependencies that exist but have
+// stopped, such as containers shut down with the project, are started first,
+// so that each is only started once those declared before it are ready.
+func waitForDependencies(ctx context.Context, cli *docker.Client, deps api.DependsOn,
+	out io.Writer) error {
+	var timeout = time.Duration(deps.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultDependencyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, name := range deps.Containers {
+		fmt.Fprintf(out, "Waiting for dependency %s...\n", name)
+		var status string
+		var started bool
+		for {
+			info, err := cli.ContainerInspect(ctx, name)
+			var ready bool
+			switch {
+			case ctx.Err() != nil:
+				// Keep the last status, for the error below
+			case err != nil:
+				status = "not found"
+			default:
+				ready, status = dependencyReady(info, deps.WaitHealthy)
+			}
+			if !ready && !started && dependencyStopped(info) {
+				fmt.Fprintf(out, "Starting dependency %s...\n", name)
+				if err := cli.ContainerStart(ctx, info.ID, types.ContainerStartOptions{}); err != nil {
+					return fmt.Errorf("failed to start dependency %s: %s", name, err.Error())
+				}
+				started = true
+				continue
+			}
+			if ready {
+				fmt.Fprintf(out, "Dependency %s is %s\n", name, status)
+				break
+			}
+			select {
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return fmt.Errorf("dependency %s was not ready within %s (%s)",
+						name, timeout, status)
+				}
+				return ctx.Err()
+			case <-time.After(dependencyPollInterval):
+			}
+		}
+	}
+	return nil
+}
+
+// dependencyStopped checks if the given container exists, but is not running
+// and is not being restarted by its restart policy
+func dependencyStopped(info types.ContainerJSON) bool {
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return false
+	}
+	return !info.State.Running && !info.State.Restarting && !info.State.Dead
+}
+
+// dependencyReady checks if the given container is ready to be depended on,
+// and describes its status. Containers are ready once they are running, and
+// if waitHealthy is set, containers with healthchecks must also be healthy.
+func dependencyReady(info types.ContainerJSON, waitHealthy bool) (bool, string) {
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return false, "unknown"
+	}
+	if !info.State.Running {
+		return false, info.State.Status
+	}
+	if waitHealthy && info.State.Health != nil {
+		return info.State.Health.Status == types.Healthy,
+			info.State.Health.Status
+	}
+	return true, "running"
+}
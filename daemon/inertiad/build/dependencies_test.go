@@ -0,0 +1,111 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func Test_dependencyReady(t *testing.T) {
+	var container = func(state *types.ContainerState) types.ContainerJSON {
+		return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: state}}
+	}
+	type args struct {
+		info        types.ContainerJSON
+		waitHealthy bool
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantReady  bool
+		wantStatus string
+	}{
+		{"no state", args{types.ContainerJSON{}, false}, false, "unknown"},
+		{"exited", args{container(&types.ContainerState{Status: "exited"}), false},
+			false, "exited"},
+		{"running", args{container(&types.ContainerState{Status: "running", Running: true}), false},
+			true, "running"},
+		{"starting, health not required", args{container(&types.ContainerState{
+			Running: true, Health: &types.Health{Status: types.Starting}}), false},
+			true, "running"},
+		{"starting", args{container(&types.ContainerState{
+			Running: true, Health: &types.Health{Status: types.Starting}}), true},
+			false, types.Starting},
+		{"healthy", args{container(&types.ContainerState{
+			Running: true, Health: &types.Health{Status: types.Healthy}}), true},
+			true, types.Healthy},
+		{"no healthcheck", args{container(&types.ContainerState{Running: true}), true},
+			true, "running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, status := dependencyReady(tt.args.info, tt.args.waitHealthy)
+			assert.Equal(t, tt.wantReady, ready)
+			assert.Equal(t, tt.wantStatus, status)
+		})
+	}
+}
+
+// newFakeDockerClient returns a Docker client for the API served by handler
+func newFakeDockerClient(t *testing.T, handler http.HandlerFunc) (*docker.Client, func()) {
+	ts := httptest.NewServer(handler)
+	cli, err := docker.NewClient("tcp://"+ts.Listener.Addr().String(), "1.37", nil, nil)
+	assert.Nil(t, err)
+	return cli, ts.Close
+}
+
+func Test_waitForDependencies(t *testing.T) {
+	var started bool
+	cli, closeServer := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/abcdef/start"):
+			started = true
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/containers/postgres/json"):
+			var state = &types.ContainerState{Status: "exited"}
+			if started {
+				state = &types.ContainerState{Status: "running", Running: true}
+			}
+			json.NewEncoder(w).Encode(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "abcdef", State: state},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer closeServer()
+
+	// Stopped dependencies should be started, then waited for
+	var out bytes.Buffer
+	assert.Nil(t, waitForDependencies(context.Background(), cli,
+		api.DependsOn{Containers: []string{"postgres"}, Timeout: 5}, &out))
+	assert.True(t, started)
+	assert.Contains(t, out.String(), "Starting dependency postgres")
+}
+
+func Test_waitForDependenciesCancelled(t *testing.T) {
+	cli, closeServer := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer closeServer()
+
+	// Waiting should stop as soon as the deployment is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	var start = time.Now()
+	var err = waitForDependencies(ctx, cli,
+		api.DependsOn{Containers: []string{"postgres"}, Timeout: 60}, ioutil.Discard)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
@@ -0,0 +1,153 @@
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are the files in a build context that exclusions are read from,
+// in order of precedence - only the first one that exists is used, so an
+// .inertiaignore replaces the project's .dockerignore entirely
+var ignoreFiles = []string{".inertiaignore", ".dockerignore"}
+
+// ignorePattern is a single build context exclusion
+type ignorePattern struct {
+	elements []string
+	negate   bool
+}
+
+// contextIgnore excludes files from a build context, using the format of
+// .dockerignore files
+type contextIgnore struct {
+	patterns []ignorePattern
+	negated  bool
+
+	// keep lists files that are always included, such as the Dockerfile
+	keep []string
+}
+
+// readContextIgnore reads the exclusions for the build context at dir from
+// the first ignore file that exists, and returns the name of the file used.
+// Returns nil if there is no ignore file.
+func readContextIgnore(dir string) (*contextIgnore, string, error) {
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		ignore, err := parseContextIgnore(f)
+		return ignore, name, err
+	}
+	return nil, "", nil
+}
+
+// parseContextIgnore parses exclusions from lines of patterns. Blank lines and
+// lines starting with '#' are ignored, and patterns starting with '!' include
+// files excluded by earlier patterns.
+func parseContextIgnore(r io.Reader) (*contextIgnore, error) {
+	var ignore = &contextIgnore{patterns: []ignorePattern{}}
+	var scanner = bufio.NewScanner(r)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var negate = strings.HasPrefix(line, "!")
+		if negate {
+			line = strings.TrimSpace(line[1:])
+		}
+		line = strings.TrimPrefix(path.Clean(filepath.ToSlash(line)), "/")
+		if line == "." || line == "" {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", line, err.Error())
+		}
+		ignore.patterns = append(ignore.patterns, ignorePattern{
+			elements: strings.Split(line, "/"),
+			negate:   negate,
+		})
+		ignore.negated = ignore.negated || negate
+	}
+	return ignore, scanner.Err()
+}
+
+// matchPattern checks if pattern matches the given slash-separated path.
+// Each element of the pattern is matched against a path element using
+// path.Match, except for '**', which matches any number of directories.
+func matchPattern(pattern, rel []string) bool {
+	if len(pattern) == 0 {
+		return len(rel) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(rel); i++ {
+			if matchPattern(pattern[1:], rel[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(rel) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], rel[0]); !ok {
+		return false
+	}
+	return matchPattern(pattern[1:], rel[1:])
+}
+
+// excludes checks if the file at the given path, relative to the build
+// context, is excluded. Files within an excluded directory are excluded, and
+// the last pattern that matches a file takes precedence.
+func (ig *contextIgnore) excludes(rel string) bool {
+	if ig == nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, k := range ig.keep {
+		if rel == k {
+			return false
+		}
+	}
+	var excluded = false
+	for _, p := range ig.patterns {
+		if matchesPathOrParent(p.elements, rel) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// skips checks if everything within the given excluded directory can be
+// skipped, which is only the case if no files within it could be included
+func (ig *contextIgnore) skips(rel string) bool {
+	if ig.negated {
+		return false
+	}
+	var prefix = filepath.ToSlash(rel) + "/"
+	for _, k := range ig.keep {
+		if strings.HasPrefix(k, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPathOrParent checks if pattern matches the given slash-separated path
+// or any of its parent directories
+func matchesPathOrParent(pattern []string, rel string) bool {
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if matchPattern(pattern, strings.Split(p, "/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextIgnore_excludes(t *testing.T) {
+	type args struct {
+		ignore string
+		keep   []string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		excluded []string
+		included []string
+	}{
+		{"no patterns", args{"", nil},
+			nil, []string{"main.go", "node_modules/a.js"}},
+		{"comments and blank lines", args{"# node_modules\n\n", nil},
+			nil, []string{"node_modules/a.js"}},
+		{"directory", args{"node_modules", nil},
+			[]string{"node_modules", "node_modules/a/b.js"}, []string{"web/node_modules/a.js", "main.go"}},
+		{"leading slash", args{"/node_modules/", nil},
+			[]string{"node_modules/a.js"}, []string{"main.go"}},
+		{"wildcard", args{"*.log", nil},
+			[]string{"debug.log"}, []string{"logs/debug.log", "main.go"}},
+		{"double star", args{"**/*.log", nil},
+			[]string{"debug.log", "logs/a/debug.log"}, []string{"main.go"}},
+		{"character class", args{"[a-c].txt\nlog?", nil},
+			[]string{"b.txt", "log1"}, []string{"d.txt", "log10"}},
+		{"double star directory", args{"docs/**", nil},
+			[]string{"docs/a/b.md"}, []string{"main.go"}},
+		{"negation", args{"docs\n!docs/README.md", nil},
+			[]string{"docs/index.md"}, []string{"docs/README.md"}},
+		{"kept files", args{"*\n", []string{"Dockerfile"}},
+			[]string{"main.go"}, []string{"Dockerfile"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignore, err := parseContextIgnore(strings.NewReader(tt.args.ignore))
+			assert.Nil(t, err)
+			ignore.keep = tt.args.keep
+			for _, f := range tt.excluded {
+				assert.True(t, ignore.excludes(f), f)
+			}
+			for _, f := range tt.included {
+				assert.False(t, ignore.excludes(f), f)
+			}
+		})
+	}
+}
+
+func TestParseContextIgnoreInvalid(t *testing.T) {
+	_, err := parseContextIgnore(strings.NewReader("[a-"))
+	assert.NotNil(t, err)
+}
+
+func TestReadContextIgnore(t *testing.T) {
+	type args struct {
+		files map[string]string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantFile string
+		wantNil  bool
+	}{
+		{"none", args{map[string]string{}}, "", true},
+		{"dockerignore", args{map[string]string{".dockerignore": "a"}}, ".dockerignore", false},
+		{"inertiaignore takes precedence", args{map[string]string{
+			".dockerignore":  "a",
+			".inertiaignore": "b",
+		}}, ".inertiaignore", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-ignore")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			for name, contents := range tt.args.files {
+				assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+			}
+
+			ignore, file, err := readContextIgnore(dir)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantFile, file)
+			assert.Equal(t, tt.wantNil, ignore == nil)
+		})
+	}
+}
+
+func Test_buildTarIgnore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-tar")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"Dockerfile", "main.go", "node_modules/a.js", "docs/README.md", "docs/index.md"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644))
+	}
+
+	ignore, err := parseContextIgnore(strings.NewReader("node_modules\ndocs\n!docs/README.md\nDockerfile"))
+	assert.Nil(t, err)
+	ignore.keep = []string{"Dockerfile"}
+	var buf = bytes.NewBuffer(nil)
+	assert.Nil(t, buildTar(dir, ignore, buf))
+
+	gzr, err := gzip.NewReader(buf)
+	assert.Nil(t, err)
+	var tr = tar.NewReader(gzr)
+	var files = []string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, filepath.ToSlash(header.Name))
+		}
+	}
+	sort.Strings(files)
+	assert.Equal(t, []string{"Dockerfile", "docs/README.md", "main.go"}, files)
+}
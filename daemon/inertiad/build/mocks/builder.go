@@ -2,21 +2,24 @@
 package mocks
 
 import (
+	context "context"
 	io "io"
 	sync "sync"
+	time "time"
 
 	client "github.com/docker/docker/client"
 	build "github.com/ubclaunchpad/inertia/daemon/inertiad/build"
 )
 
 type FakeContainerBuilder struct {
-	BuildStub        func(string, build.Config, *client.Client, io.Writer) (func() error, error)
+	BuildStub        func(context.Context, string, build.Config, *client.Client, io.Writer) (func() error, error)
 	buildMutex       sync.RWMutex
 	buildArgsForCall []struct {
-		arg1 string
-		arg2 build.Config
-		arg3 *client.Client
-		arg4 io.Writer
+		arg1 context.Context
+		arg2 string
+		arg3 build.Config
+		arg4 *client.Client
+		arg5 io.Writer
 	}
 	buildReturns struct {
 		result1 func() error
@@ -36,6 +39,16 @@ type FakeContainerBuilder struct {
 	getBuildStageNameReturnsOnCall map[int]struct {
 		result1 string
 	}
+	GetStaticSiteDirectoryStub        func() string
+	getStaticSiteDirectoryMutex       sync.RWMutex
+	getStaticSiteDirectoryArgsForCall []struct {
+	}
+	getStaticSiteDirectoryReturns struct {
+		result1 string
+	}
+	getStaticSiteDirectoryReturnsOnCall map[int]struct {
+		result1 string
+	}
 	PruneStub        func(*client.Client, io.Writer) error
 	pruneMutex       sync.RWMutex
 	pruneArgsForCall []struct {
@@ -48,11 +61,12 @@ type FakeContainerBuilder struct {
 	pruneReturnsOnCall map[int]struct {
 		result1 error
 	}
-	PruneAllStub        func(*client.Client, io.Writer) error
+	PruneAllStub        func(*client.Client, io.Writer, ...string) error
 	pruneAllMutex       sync.RWMutex
 	pruneAllArgsForCall []struct {
 		arg1 *client.Client
 		arg2 io.Writer
+		arg3 []string
 	}
 	pruneAllReturns struct {
 		result1 error
@@ -60,11 +74,29 @@ type FakeContainerBuilder struct {
 	pruneAllReturnsOnCall map[int]struct {
 		result1 error
 	}
-	StopContainersStub        func(*client.Client, io.Writer) error
+	RecreateStub        func(string, string, build.Config, *client.Client, io.Writer, time.Duration) error
+	recreateMutex       sync.RWMutex
+	recreateArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 build.Config
+		arg4 *client.Client
+		arg5 io.Writer
+		arg6 time.Duration
+	}
+	recreateReturns struct {
+		result1 error
+	}
+	recreateReturnsOnCall map[int]struct {
+		result1 error
+	}
+	StopContainersStub        func(*client.Client, io.Writer, time.Duration, ...string) error
 	stopContainersMutex       sync.RWMutex
 	stopContainersArgsForCall []struct {
 		arg1 *client.Client
 		arg2 io.Writer
+		arg3 time.Duration
+		arg4 []string
 	}
 	stopContainersReturns struct {
 		result1 error
@@ -76,19 +108,20 @@ type FakeContainerBuilder struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeContainerBuilder) Build(arg1 string, arg2 build.Config, arg3 *client.Client, arg4 io.Writer) (func() error, error) {
+func (fake *FakeContainerBuilder) Build(arg1 context.Context, arg2 string, arg3 build.Config, arg4 *client.Client, arg5 io.Writer) (func() error, error) {
 	fake.buildMutex.Lock()
 	ret, specificReturn := fake.buildReturnsOnCall[len(fake.buildArgsForCall)]
 	fake.buildArgsForCall = append(fake.buildArgsForCall, struct {
-		arg1 string
-		arg2 build.Config
-		arg3 *client.Client
-		arg4 io.Writer
-	}{arg1, arg2, arg3, arg4})
-	fake.recordInvocation("Build", []interface{}{arg1, arg2, arg3, arg4})
+		arg1 context.Context
+		arg2 string
+		arg3 build.Config
+		arg4 *client.Client
+		arg5 io.Writer
+	}{arg1, arg2, arg3, arg4, arg5})
+	fake.recordInvocation("Build", []interface{}{arg1, arg2, arg3, arg4, arg5})
 	fake.buildMutex.Unlock()
 	if fake.BuildStub != nil {
-		return fake.BuildStub(arg1, arg2, arg3, arg4)
+		return fake.BuildStub(arg1, arg2, arg3, arg4, arg5)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -103,17 +136,17 @@ func (fake *FakeContainerBuilder) BuildCallCount() int {
 	return len(fake.buildArgsForCall)
 }
 
-func (fake *FakeContainerBuilder) BuildCalls(stub func(string, build.Config, *client.Client, io.Writer) (func() error, error)) {
+func (fake *FakeContainerBuilder) BuildCalls(stub func(context.Context, string, build.Config, *client.Client, io.Writer) (func() error, error)) {
 	fake.buildMutex.Lock()
 	defer fake.buildMutex.Unlock()
 	fake.BuildStub = stub
 }
 
-func (fake *FakeContainerBuilder) BuildArgsForCall(i int) (string, build.Config, *client.Client, io.Writer) {
+func (fake *FakeContainerBuilder) BuildArgsForCall(i int) (context.Context, string, build.Config, *client.Client, io.Writer) {
 	fake.buildMutex.RLock()
 	defer fake.buildMutex.RUnlock()
 	argsForCall := fake.buildArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
 }
 
 func (fake *FakeContainerBuilder) BuildReturns(result1 func() error, result2 error) {
@@ -162,6 +195,8 @@ func (fake *FakeContainerBuilder) GetBuildStageName() string {
 func (fake *FakeContainerBuilder) GetBuildStageNameCallCount() int {
 	fake.getBuildStageNameMutex.RLock()
 	defer fake.getBuildStageNameMutex.RUnlock()
+	fake.getStaticSiteDirectoryMutex.RLock()
+	defer fake.getStaticSiteDirectoryMutex.RUnlock()
 	return len(fake.getBuildStageNameArgsForCall)
 }
 
@@ -194,6 +229,58 @@ func (fake *FakeContainerBuilder) GetBuildStageNameReturnsOnCall(i int, result1
 	}{result1}
 }
 
+func (fake *FakeContainerBuilder) GetStaticSiteDirectory() string {
+	fake.getStaticSiteDirectoryMutex.Lock()
+	ret, specificReturn := fake.getStaticSiteDirectoryReturnsOnCall[len(fake.getStaticSiteDirectoryArgsForCall)]
+	fake.getStaticSiteDirectoryArgsForCall = append(fake.getStaticSiteDirectoryArgsForCall, struct {
+	}{})
+	fake.recordInvocation("GetStaticSiteDirectory", []interface{}{})
+	fake.getStaticSiteDirectoryMutex.Unlock()
+	if fake.GetStaticSiteDirectoryStub != nil {
+		return fake.GetStaticSiteDirectoryStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.getStaticSiteDirectoryReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerBuilder) GetStaticSiteDirectoryCallCount() int {
+	fake.getStaticSiteDirectoryMutex.RLock()
+	defer fake.getStaticSiteDirectoryMutex.RUnlock()
+	return len(fake.getStaticSiteDirectoryArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) GetStaticSiteDirectoryCalls(stub func() string) {
+	fake.getStaticSiteDirectoryMutex.Lock()
+	defer fake.getStaticSiteDirectoryMutex.Unlock()
+	fake.GetStaticSiteDirectoryStub = stub
+}
+
+func (fake *FakeContainerBuilder) GetStaticSiteDirectoryReturns(result1 string) {
+	fake.getStaticSiteDirectoryMutex.Lock()
+	defer fake.getStaticSiteDirectoryMutex.Unlock()
+	fake.GetStaticSiteDirectoryStub = nil
+	fake.getStaticSiteDirectoryReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) GetStaticSiteDirectoryReturnsOnCall(i int, result1 string) {
+	fake.getStaticSiteDirectoryMutex.Lock()
+	defer fake.getStaticSiteDirectoryMutex.Unlock()
+	fake.GetStaticSiteDirectoryStub = nil
+	if fake.getStaticSiteDirectoryReturnsOnCall == nil {
+		fake.getStaticSiteDirectoryReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.getStaticSiteDirectoryReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeContainerBuilder) Prune(arg1 *client.Client, arg2 io.Writer) error {
 	fake.pruneMutex.Lock()
 	ret, specificReturn := fake.pruneReturnsOnCall[len(fake.pruneArgsForCall)]
@@ -255,17 +342,18 @@ func (fake *FakeContainerBuilder) PruneReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeContainerBuilder) PruneAll(arg1 *client.Client, arg2 io.Writer) error {
+func (fake *FakeContainerBuilder) PruneAll(arg1 *client.Client, arg2 io.Writer, arg3 ...string) error {
 	fake.pruneAllMutex.Lock()
 	ret, specificReturn := fake.pruneAllReturnsOnCall[len(fake.pruneAllArgsForCall)]
 	fake.pruneAllArgsForCall = append(fake.pruneAllArgsForCall, struct {
 		arg1 *client.Client
 		arg2 io.Writer
-	}{arg1, arg2})
-	fake.recordInvocation("PruneAll", []interface{}{arg1, arg2})
+		arg3 []string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("PruneAll", []interface{}{arg1, arg2, arg3})
 	fake.pruneAllMutex.Unlock()
 	if fake.PruneAllStub != nil {
-		return fake.PruneAllStub(arg1, arg2)
+		return fake.PruneAllStub(arg1, arg2, arg3...)
 	}
 	if specificReturn {
 		return ret.result1
@@ -280,17 +368,17 @@ func (fake *FakeContainerBuilder) PruneAllCallCount() int {
 	return len(fake.pruneAllArgsForCall)
 }
 
-func (fake *FakeContainerBuilder) PruneAllCalls(stub func(*client.Client, io.Writer) error) {
+func (fake *FakeContainerBuilder) PruneAllCalls(stub func(*client.Client, io.Writer, ...string) error) {
 	fake.pruneAllMutex.Lock()
 	defer fake.pruneAllMutex.Unlock()
 	fake.PruneAllStub = stub
 }
 
-func (fake *FakeContainerBuilder) PruneAllArgsForCall(i int) (*client.Client, io.Writer) {
+func (fake *FakeContainerBuilder) PruneAllArgsForCall(i int) (*client.Client, io.Writer, []string) {
 	fake.pruneAllMutex.RLock()
 	defer fake.pruneAllMutex.RUnlock()
 	argsForCall := fake.pruneAllArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeContainerBuilder) PruneAllReturns(result1 error) {
@@ -316,17 +404,84 @@ func (fake *FakeContainerBuilder) PruneAllReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeContainerBuilder) StopContainers(arg1 *client.Client, arg2 io.Writer) error {
+func (fake *FakeContainerBuilder) Recreate(arg1 string, arg2 string, arg3 build.Config, arg4 *client.Client, arg5 io.Writer, arg6 time.Duration) error {
+	fake.recreateMutex.Lock()
+	ret, specificReturn := fake.recreateReturnsOnCall[len(fake.recreateArgsForCall)]
+	fake.recreateArgsForCall = append(fake.recreateArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 build.Config
+		arg4 *client.Client
+		arg5 io.Writer
+		arg6 time.Duration
+	}{arg1, arg2, arg3, arg4, arg5, arg6})
+	fake.recordInvocation("Recreate", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6})
+	fake.recreateMutex.Unlock()
+	if fake.RecreateStub != nil {
+		return fake.RecreateStub(arg1, arg2, arg3, arg4, arg5, arg6)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.recreateReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerBuilder) RecreateCallCount() int {
+	fake.recreateMutex.RLock()
+	defer fake.recreateMutex.RUnlock()
+	return len(fake.recreateArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) RecreateCalls(stub func(string, string, build.Config, *client.Client, io.Writer, time.Duration) error) {
+	fake.recreateMutex.Lock()
+	defer fake.recreateMutex.Unlock()
+	fake.RecreateStub = stub
+}
+
+func (fake *FakeContainerBuilder) RecreateArgsForCall(i int) (string, string, build.Config, *client.Client, io.Writer, time.Duration) {
+	fake.recreateMutex.RLock()
+	defer fake.recreateMutex.RUnlock()
+	argsForCall := fake.recreateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6
+}
+
+func (fake *FakeContainerBuilder) RecreateReturns(result1 error) {
+	fake.recreateMutex.Lock()
+	defer fake.recreateMutex.Unlock()
+	fake.RecreateStub = nil
+	fake.recreateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) RecreateReturnsOnCall(i int, result1 error) {
+	fake.recreateMutex.Lock()
+	defer fake.recreateMutex.Unlock()
+	fake.RecreateStub = nil
+	if fake.recreateReturnsOnCall == nil {
+		fake.recreateReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.recreateReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) StopContainers(arg1 *client.Client, arg2 io.Writer, arg3 time.Duration, arg4 ...string) error {
 	fake.stopContainersMutex.Lock()
 	ret, specificReturn := fake.stopContainersReturnsOnCall[len(fake.stopContainersArgsForCall)]
 	fake.stopContainersArgsForCall = append(fake.stopContainersArgsForCall, struct {
 		arg1 *client.Client
 		arg2 io.Writer
-	}{arg1, arg2})
-	fake.recordInvocation("StopContainers", []interface{}{arg1, arg2})
+		arg3 time.Duration
+		arg4 []string
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("StopContainers", []interface{}{arg1, arg2, arg3, arg4})
 	fake.stopContainersMutex.Unlock()
 	if fake.StopContainersStub != nil {
-		return fake.StopContainersStub(arg1, arg2)
+		return fake.StopContainersStub(arg1, arg2, arg3, arg4...)
 	}
 	if specificReturn {
 		return ret.result1
@@ -341,17 +496,17 @@ func (fake *FakeContainerBuilder) StopContainersCallCount() int {
 	return len(fake.stopContainersArgsForCall)
 }
 
-func (fake *FakeContainerBuilder) StopContainersCalls(stub func(*client.Client, io.Writer) error) {
+func (fake *FakeContainerBuilder) StopContainersCalls(stub func(*client.Client, io.Writer, time.Duration, ...string) error) {
 	fake.stopContainersMutex.Lock()
 	defer fake.stopContainersMutex.Unlock()
 	fake.StopContainersStub = stub
 }
 
-func (fake *FakeContainerBuilder) StopContainersArgsForCall(i int) (*client.Client, io.Writer) {
+func (fake *FakeContainerBuilder) StopContainersArgsForCall(i int) (*client.Client, io.Writer, time.Duration, []string) {
 	fake.stopContainersMutex.RLock()
 	defer fake.stopContainersMutex.RUnlock()
 	argsForCall := fake.stopContainersArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeContainerBuilder) StopContainersReturns(result1 error) {
@@ -384,10 +539,14 @@ func (fake *FakeContainerBuilder) Invocations() map[string][][]interface{} {
 	defer fake.buildMutex.RUnlock()
 	fake.getBuildStageNameMutex.RLock()
 	defer fake.getBuildStageNameMutex.RUnlock()
+	fake.getStaticSiteDirectoryMutex.RLock()
+	defer fake.getStaticSiteDirectoryMutex.RUnlock()
 	fake.pruneMutex.RLock()
 	defer fake.pruneMutex.RUnlock()
 	fake.pruneAllMutex.RLock()
 	defer fake.pruneAllMutex.RUnlock()
+	fake.recreateMutex.RLock()
+	defer fake.recreateMutex.RUnlock()
 	fake.stopContainersMutex.RLock()
 	defer fake.stopContainersMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
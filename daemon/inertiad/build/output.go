@@ -28,3 +28,11 @@ func reportProjectContainerCreateComplete(name string, out io.Writer) {
 func reportProjectStartup(name string, out io.Writer) {
 	fmt.Fprintf(out, "Starting up %s...\n", name)
 }
+
+func reportInitJobBegin(name string, out io.Writer) {
+	fmt.Fprintf(out, "Running init job %s...\n", name)
+}
+
+func reportInitJobComplete(name string, out io.Writer) {
+	fmt.Fprintf(out, "Init job %s completed successfully\n", name)
+}
@@ -0,0 +1,240 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// defaultReadinessTimeout is how long services are waited for if no
+	// timeout is configured
+	defaultReadinessTimeout = 60 * time.Second
+
+	// readinessPollInterval is how often readiness checks are retried
+	readinessPollInterval = time.Second
+
+	// readinessImage is the image used to check if ports are open, which is
+	// run in the network namespace of the checked container so that the
+	// container does not need any tools of its own
+	readinessImage = "busybox:1.36"
+
+	// readinessScript retries until the port given as its first argument
+	// accepts connections
+	readinessScript = `until nc -z -w 1 127.0.0.1 "$0"; do sleep 1; done`
+)
+
+// validateReadiness checks that exactly one readiness check is configured
+func validateReadiness(r api.Readiness) error {
+	if r.Port != 0 && len(r.Command) > 0 {
+		return errors.New("readiness check must be either a port or a command, not both")
+	}
+	if r.Port == 0 && len(r.Command) == 0 {
+		return errors.New("readiness check requires a port or a command")
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return fmt.Errorf("invalid readiness port %d", r.Port)
+	}
+	if r.Timeout < 0 {
+		return errors.New("readiness timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateServiceReadiness validates the readiness checks of each service
+func validateServiceReadiness(readiness map[string]api.Readiness) error {
+	for service, r := range readiness {
+		if err := validateReadiness(r); err != nil {
+			return fmt.Errorf("service '%s': %s", service, err.Error())
+		}
+	}
+	return nil
+}
+
+// waitForReadiness runs the readiness check of each configured service, in
+// order of service name, until it passes. find returns the ID of a service's
+// container, and is retried until the container exists. An error is returned
+// if a service is not ready before its timeout, and waiting stops with ctx's
+// error if ctx is cancelled.
+func waitForReadiness(ctx context.Context, cli *docker.Client,
+	readiness map[string]api.Readiness,
+	find func(service string) (string, error), out io.Writer) error {
+	var services = make([]string, 0, len(readiness))
+	for service := range readiness {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	for _, service := range services {
+		var r = readiness[service]
+		var timeout = time.Duration(r.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = defaultReadinessTimeout
+		}
+		serviceCtx, cancel := context.WithTimeout(ctx, timeout)
+		fmt.Fprintf(out, "Waiting for %s to be ready...\n", service)
+		err := waitForService(serviceCtx, cli, r, func() (string, error) { return find(service) })
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("%s was not ready within %s: %s", service, timeout, err.Error())
+		}
+		fmt.Fprintf(out, "%s is ready\n", service)
+	}
+	return nil
+}
+
+// waitForService waits for the service's container to exist, and then for
+// its readiness check to pass
+func waitForService(ctx context.Context, cli *docker.Client, r api.Readiness,
+	find func() (string, error)) error {
+	var id string
+	for {
+		var err error
+		if id, err = find(); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(readinessPollInterval):
+		}
+	}
+	if r.Port > 0 {
+		return waitForPort(ctx, cli, id, r.Port)
+	}
+	return waitForCommand(ctx, cli, id, r.Command)
+}
+
+// waitForPort waits for the given port to accept connections in the given
+// container's network namespace
+func waitForPort(ctx context.Context, cli *docker.Client, id string, port int) error {
+	reader, err := cli.ImagePull(ctx, readinessImage, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to download port check: %s", err.Error())
+	}
+	io.Copy(ioutil.Discard, reader)
+	reader.Close()
+
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image: readinessImage,
+		Cmd:   []string{"sh", "-c", readinessScript, strconv.Itoa(port)},
+	}, &container.HostConfig{
+		NetworkMode: container.NetworkMode("container:" + id),
+	}, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create port check: %s", err.Error())
+	}
+	defer cli.ContainerRemove(context.Background(), resp.ID,
+		types.ContainerRemoveOptions{Force: true})
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("failed to start port check: %s", err.Error())
+	}
+
+	statusCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if ctx.Err() != nil {
+			return fmt.Errorf("port %d is not accepting connections", port)
+		}
+		return err
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("port check exited with status %d", status.StatusCode)
+		}
+		return nil
+	}
+}
+
+// waitForCommand runs the given command in the given container until it
+// exits successfully
+func waitForCommand(ctx context.Context, cli *docker.Client, id string, cmd []string) error {
+	var lastErr = errors.New("command did not succeed")
+	for {
+		if err := execCommand(ctx, cli, id, cmd); err == nil {
+			return nil
+		} else if ctx.Err() == nil {
+			lastErr = err
+		}
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
+
+// execCommand runs the given command in the given container, and returns an
+// error if it does not exit successfully
+func execCommand(ctx context.Context, cli *docker.Client, id string, cmd []string) error {
+	exec, err := cli.ContainerExecCreate(ctx, id, types.ExecConfig{Cmd: cmd})
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerExecStart(ctx, exec.ID, types.ExecStartCheck{}); err != nil {
+		return err
+	}
+	for {
+		info, err := cli.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return err
+		}
+		if !info.Running {
+			if info.ExitCode != 0 {
+				return fmt.Errorf("%s exited with status %d", cmd[0], info.ExitCode)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// composeProjectName returns the name docker-compose uses for the project
+// with the given name, which only contains lowercase letters, digits, dashes,
+// and underscores
+func composeProjectName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return -1
+		}
+	}, name)
+}
+
+// findComposeContainer returns the ID of a running container of the given
+// docker-compose project's service
+func findComposeContainer(cli *docker.Client, project, service string) (string, error) {
+	var args = filters.NewArgs()
+	args.Add("label", "com.docker.compose.project="+composeProjectName(project))
+	args.Add("label", "com.docker.compose.service="+service)
+	list, err := cli.ContainerList(context.Background(), types.ContainerListOptions{Filters: args})
+	if err != nil {
+		return "", err
+	}
+	if len(list) == 0 {
+		return "", fmt.Errorf("no running container found for service %s", service)
+	}
+	return list[0].ID, nil
+}
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func Test_validateReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		readiness api.Readiness
+		wantErr   bool
+	}{
+		{"port", api.Readiness{Port: 5432}, false},
+		{"command", api.Readiness{Command: []string{"pg_isready"}, Timeout: 30}, false},
+		{"no check", api.Readiness{Timeout: 30}, true},
+		{"port and command", api.Readiness{Port: 5432, Command: []string{"pg_isready"}}, true},
+		{"invalid port", api.Readiness{Port: 70000}, true},
+		{"negative port", api.Readiness{Port: -1}, true},
+		{"negative timeout", api.Readiness{Port: 5432, Timeout: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validateReadiness(tt.readiness) != nil)
+		})
+	}
+}
+
+func Test_validateServiceReadiness(t *testing.T) {
+	assert.Nil(t, validateServiceReadiness(nil))
+	assert.Nil(t, validateServiceReadiness(map[string]api.Readiness{
+		"db": {Port: 5432},
+	}))
+	err := validateServiceReadiness(map[string]api.Readiness{
+		"db": {Port: 5432},
+		"mq": {},
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "service 'mq'")
+}
+
+func Test_composeProjectName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"inertia", "inertia"},
+		{"My_Project-2", "my_project-2"},
+		{"my.project app", "myprojectapp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, composeProjectName(tt.name))
+		})
+	}
+}
+
+func Test_waitForReadinessCancelled(t *testing.T) {
+	cli, closeServer := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer closeServer()
+
+	// Waiting should stop as soon as the deployment is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	var start = time.Now()
+	var err = waitForReadiness(ctx, cli,
+		map[string]api.Readiness{"web": {Port: 8080, Timeout: 60}},
+		func(string) (string, error) { return "", errors.New("no container") },
+		ioutil.Discard)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
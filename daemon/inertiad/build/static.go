@@ -0,0 +1,188 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+const (
+	// StaticSiteDirectory is the directory, in the daemon's data directory,
+	// that the output of static builds is published to
+	StaticSiteDirectory = "site"
+
+	// defaultStaticImage is the image static build commands are run in if
+	// none is configured
+	defaultStaticImage = "node:lts-alpine"
+
+	// defaultStaticOutputDirectory is the directory, relative to the
+	// project, that static builds are published from if none is configured
+	defaultStaticOutputDirectory = "public"
+)
+
+// staticBuild runs a static site's build command, if it has one, in a one-shot
+// container. The returned deploy callback publishes the build's output
+// directory to be served by the daemon - no project containers are started.
+func (b *Builder) staticBuild(ctx context.Context, d Config, cli *docker.Client,
+	out io.Writer) (func() error, error) {
+	var static = d.Static
+	if static == nil {
+		static = &api.Static{}
+	}
+	var outputName = static.OutputDirectory
+	if outputName == "" {
+		outputName = defaultStaticOutputDirectory
+	}
+	outputDirectory, err := getStaticOutputDirectory(d.BuildDirectory, outputName)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.InitJob != nil {
+		fmt.Fprintln(out, "Init jobs are ignored for static projects")
+	}
+	if d.Buildx != nil {
+		fmt.Fprintln(out, "Buildx is ignored for static projects")
+	}
+	if d.DependsOn != nil {
+		fmt.Fprintln(out, "Dependencies are ignored for static projects")
+	}
+	if d.VerifyImagesKey != "" {
+		fmt.Fprintln(out, "Image verification is ignored for static projects")
+	}
+
+	reportProjectBuildBegin(d.Name, out)
+	if static.BuildCommand != "" && !d.SkipBuild {
+		var image = static.Image
+		if image == "" {
+			image = defaultStaticImage
+		}
+		reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %s", image, err.Error())
+		}
+		io.Copy(ioutil.Discard, reader)
+		reader.Close()
+
+		cli.ContainerRemove(ctx, b.buildStageName, types.ContainerRemoveOptions{Force: true})
+		resp, err := cli.ContainerCreate(ctx, &container.Config{
+			Image:      image,
+			WorkingDir: "/build",
+			Cmd:        []string{"sh", "-c", static.BuildCommand},
+			Env:        d.EnvValues,
+		}, &container.HostConfig{
+			AutoRemove: true,
+			Binds:      []string{getTrueDirectory(d.BuildDirectory) + ":/build"},
+		}, nil, b.buildStageName)
+		if err != nil {
+			return nil, err
+		}
+		if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
+			return nil, fmt.Errorf("build command failed: %s", err.Error())
+		}
+	}
+	if info, err := os.Stat(outputDirectory); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("output directory '%s' not found - check your build command "+
+			"and output directory", outputName)
+	}
+	reportProjectBuildComplete(d.Name, out)
+
+	return func() error {
+		reportProjectContainerCreateBegin(d.Name, out)
+		if err := publishStaticSite(outputDirectory, b.staticSiteDirectory); err != nil {
+			return fmt.Errorf("failed to publish site: %s", err.Error())
+		}
+		fmt.Fprintf(out, "Site published to /%s/\n", StaticSiteDirectory)
+		return nil
+	}, nil
+}
+
+// getStaticOutputDirectory returns the path to a static build's output
+// directory, which must be inside the project
+func getStaticOutputDirectory(projectDirectory, outputDirectory string) (string, error) {
+	var clean = path.Clean(outputDirectory)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid output directory '%s' - must be a directory in "+
+			"your project", outputDirectory)
+	}
+	if clean == "." || clean == ".git" || strings.HasPrefix(clean, ".git/") {
+		return "", fmt.Errorf("invalid output directory '%s' - must not include your "+
+			"repository", outputDirectory)
+	}
+	return path.Join(projectDirectory, clean), nil
+}
+
+// publishStaticSite replaces the contents of site with a copy of src. The copy
+// is made beside site and swapped in, so that a partially copied site is never
+// served.
+func publishStaticSite(src, site string) error {
+	site = filepath.Clean(site)
+	var staged, old = site + ".new", site + ".old"
+	os.RemoveAll(staged)
+	os.RemoveAll(old)
+	if err := copyDirectory(src, staged); err != nil {
+		os.RemoveAll(staged)
+		return err
+	}
+	if err := os.Rename(site, old); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(staged)
+		return err
+	}
+	if err := os.Rename(staged, site); err != nil {
+		os.Rename(old, site)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// copyDirectory recursively copies the regular files and directories in src
+// to dst - symlinks and other special files are skipped
+func copyDirectory(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		var target = filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+// copyFile copies the file at src to dst with the given permissions
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
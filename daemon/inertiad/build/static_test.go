@@ -0,0 +1,75 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getStaticOutputDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{"directory", "public", "/project/public", false},
+		{"nested directory", "./docs/_build/", "/project/docs/_build", false},
+		{"project", ".", "", true},
+		{"repository", ".git", "", true},
+		{"repository contents", ".git/objects", "", true},
+		{"outside project", "../data", "", true},
+		{"absolute", "/etc", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getStaticOutputDirectory("/project", tt.output)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_publishStaticSite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-static")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var site = filepath.Join(dir, "site")
+
+	var write = func(name, contents string) {
+		var p = filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Nil(t, ioutil.WriteFile(p, []byte(contents), 0644))
+	}
+	var read = func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(site, name))
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+
+	// First publish creates the site
+	write("v1/index.html", "v1")
+	write("v1/css/main.css", "body {}")
+	assert.Nil(t, publishStaticSite(filepath.Join(dir, "v1"), site))
+	assert.Equal(t, "v1", read("index.html"))
+	assert.Equal(t, "body {}", read("css/main.css"))
+
+	// Later publishes replace it entirely
+	write("v2/index.html", "v2")
+	assert.Nil(t, publishStaticSite(filepath.Join(dir, "v2"), site))
+	assert.Equal(t, "v2", read("index.html"))
+	assert.Equal(t, "", read("css/main.css"))
+	for _, leftover := range []string{"site.new", "site.old"} {
+		_, err := os.Stat(filepath.Join(dir, leftover))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Failed publishes leave the site untouched
+	assert.NotNil(t, publishStaticSite(filepath.Join(dir, "missing"), site))
+	assert.Equal(t, "v2", read("index.html"))
+}
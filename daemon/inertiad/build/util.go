@@ -3,13 +3,58 @@ package build
 import (
 	"archive/tar"
 	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// ReservedLabelPrefix is the prefix of container labels managed by Inertia,
+	// which cannot be set by users
+	ReservedLabelPrefix = "sh.ubclaunchpad.inertia."
+
+	// LabelProject and LabelService are set on all project containers
+	LabelProject = ReservedLabelPrefix + "project"
+	LabelService = ReservedLabelPrefix + "service"
+
+	// composeOverrideFile is the docker-compose override file used to apply
+	// labels and service options to docker-compose services
+	composeOverrideFile = "docker-compose.inertia.yml"
 )
 
+var (
+	composeVersion = regexp.MustCompile(`(?m)^version:\s*["']?([0-9.]+)`)
+	composeRoot    = regexp.MustCompile(`(?m)^services:`)
+	composeKey     = regexp.MustCompile(`^["']?([a-zA-Z0-9._-]+)["']?\s*:\s*(.*)$`)
+	networkAlias   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_.-]*[a-zA-Z0-9])?$`)
+)
+
+// platformArchitectures maps the architectures Docker reports for hosts to
+// the architectures used in platforms, such as "linux/arm64"
+var platformArchitectures = map[string]string{
+	"x86_64":  "amd64",
+	"amd64":   "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"armv7l":  "arm/v7",
+	"armv6l":  "arm/v6",
+	"i386":    "386",
+	"i686":    "386",
+}
+
 // getTrueDirectory converts given filepath to host-based filepath if applicable
 // - Docker commands are sent to the mounted Docker socket and hence are
 // executed on the host, using the host's filepaths, which means Docker client
@@ -18,11 +63,452 @@ func getTrueDirectory(path string) string {
 	return strings.Replace(path, "/app/host", os.Getenv("HOME"), 1)
 }
 
+// getContainerUser returns the user a project container should be run as,
+// given the user declared by the project image. Returns an empty string if
+// the image's user should be used.
+func getContainerUser(d Config, imageUser string) string {
+	if d.ContainerUser != "" {
+		return d.ContainerUser
+	}
+	if imageUser == "" && d.EnforceNonRootUser {
+		return DefaultNonRootUser
+	}
+	return ""
+}
+
+// getBuildArgs returns the given build arguments in the form expected by the
+// Docker API, or nil if there are none
+func getBuildArgs(args map[string]string) map[string]*string {
+	if len(args) == 0 {
+		return nil
+	}
+	var buildArgs = make(map[string]*string, len(args))
+	for name, value := range args {
+		value := value
+		buildArgs[name] = &value
+	}
+	return buildArgs
+}
+
+// getHostPlatform returns the platform, such as "linux/amd64", of a host with
+// the given OS and architecture as reported by Docker. Returns an empty string
+// if the architecture is not recognized.
+func getHostPlatform(osType, arch string) string {
+	if platformArch, ok := platformArchitectures[arch]; ok && osType != "" {
+		return osType + "/" + platformArch
+	}
+	return ""
+}
+
+// validatePlatform checks that the given platform is of the form
+// "os/arch[/variant]"
+func validatePlatform(platform string) error {
+	var parts = strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid platform '%s' - must be of the form 'os/arch', such as 'linux/amd64'", platform)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return fmt.Errorf("invalid platform '%s' - must be of the form 'os/arch', such as 'linux/amd64'", platform)
+		}
+	}
+	return nil
+}
+
+// getContainerLabels returns the labels to apply to the container of the given
+// project service, which are the user's configured labels merged with
+// Inertia's management labels. Returns an error if the configured labels use
+// the reserved prefix.
+func getContainerLabels(project, service string, labels map[string]string) (map[string]string, error) {
+	var merged = map[string]string{
+		LabelProject: project,
+		LabelService: service,
+	}
+	for k, v := range labels {
+		if strings.HasPrefix(k, ReservedLabelPrefix) {
+			return nil, fmt.Errorf("label '%s' on service '%s' uses the reserved prefix '%s'",
+				k, service, ReservedLabelPrefix)
+		}
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// getContainerResources returns the Docker resource configuration for the
+// given resource options
+func getContainerResources(r api.Resources) (container.Resources, error) {
+	var resources container.Resources
+	if r.MemoryReservation != "" {
+		bytes, err := units.RAMInBytes(r.MemoryReservation)
+		if err != nil || bytes < 0 {
+			return resources, fmt.Errorf("invalid memory reservation '%s'", r.MemoryReservation)
+		}
+		resources.MemoryReservation = bytes
+	}
+	if r.CPUShares != 0 {
+		// Docker requires a minimum of 2 shares
+		if r.CPUShares < 2 {
+			return resources, fmt.Errorf("invalid CPU shares %d - must be at least 2", r.CPUShares)
+		}
+		resources.CPUShares = r.CPUShares
+	}
+	return resources, nil
+}
+
+// validateNetworking checks that extra hosts are of the form "hostname:IP",
+// that DNS servers are IP addresses, and that aliases are valid host names
+func validateNetworking(n api.Networking) error {
+	for _, host := range n.ExtraHosts {
+		// IPv6 addresses contain colons, so split on the first one
+		var parts = strings.SplitN(host, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+			return fmt.Errorf("invalid extra host '%s' - must be of the form 'hostname:IP'", host)
+		}
+	}
+	for _, server := range n.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid DNS server '%s' - must be an IP address", server)
+		}
+	}
+	for _, alias := range n.Aliases {
+		if !networkAlias.MatchString(alias) {
+			return fmt.Errorf("invalid alias '%s' - must be a host name", alias)
+		}
+	}
+	return nil
+}
+
+// getContainerHealthcheck returns the Docker healthcheck configuration for
+// the given healthcheck override, or nil if no override is configured so that
+// the image's healthcheck is used. Tests that are not in Docker's exec form
+// are run with the container's shell.
+func getContainerHealthcheck(h api.Healthcheck) (*container.HealthConfig, error) {
+	if len(h.Test) == 0 && h.Interval == "" && h.Timeout == "" &&
+		h.StartPeriod == "" && h.Retries == 0 {
+		return nil, nil
+	}
+	var health = &container.HealthConfig{Test: getHealthcheckTest(h.Test)}
+	if len(health.Test) > 0 && health.Test[0] == "NONE" {
+		if len(health.Test) > 1 {
+			return nil, errors.New("healthcheck test 'NONE' cannot have arguments")
+		}
+		return health, nil
+	}
+	for _, d := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"interval", h.Interval, &health.Interval},
+		{"timeout", h.Timeout, &health.Timeout},
+		{"start period", h.StartPeriod, &health.StartPeriod},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration < time.Millisecond {
+			return nil, fmt.Errorf("invalid healthcheck %s '%s' - must be a duration "+
+				"of at least 1ms, such as '30s'", d.name, d.value)
+		}
+		*d.duration = duration
+	}
+	if h.Retries < 0 {
+		return nil, fmt.Errorf("invalid healthcheck retries %d - cannot be negative", h.Retries)
+	}
+	health.Retries = h.Retries
+	return health, nil
+}
+
+// getHealthcheckTest returns the given healthcheck test in Docker's exec form
+func getHealthcheckTest(test []string) []string {
+	if len(test) == 0 {
+		return nil
+	}
+	switch test[0] {
+	case "NONE", "CMD", "CMD-SHELL":
+		return test
+	default:
+		return []string{"CMD-SHELL", strings.Join(test, " ")}
+	}
+}
+
+// composeVersionSupports indicates whether a docker-compose file with the
+// given format version supports options introduced in the given minor
+// versions of format versions 2 and 3. Files without a version that are not
+// legacy files use the Compose Specification, which supports every option.
+func composeVersionSupports(version string, legacy bool, minV2, minV3 int) bool {
+	if legacy {
+		return false
+	}
+	if version == "" {
+		return true
+	}
+	var parts = strings.SplitN(version, ".", 3)
+	var minor int
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	switch parts[0] {
+	case "2":
+		return minor >= minV2
+	case "3":
+		return minor >= minV3
+	default:
+		major, _ := strconv.Atoi(parts[0])
+		return major > 3
+	}
+}
+
+// isLegacyCompose indicates whether the given docker-compose file uses the
+// legacy format, which has no version and declares services at the top level
+func isLegacyCompose(compose []byte) bool {
+	return !composeVersion.Match(compose) && !composeRoot.Match(compose)
+}
+
+// composeService is a service declared in a docker-compose file
+type composeService struct {
+	name string
+
+	// image is the service's image, and build indicates whether the service
+	// is built - in which case image is the name of the image it is built as
+	image string
+	build bool
+}
+
+// getComposeServices returns the services declared in the given
+// docker-compose file, which are the top-level keys of legacy files without a
+// version, or the keys of the top-level 'services' mapping otherwise. Only
+// block-style mappings are recognized.
+func getComposeServices(compose []byte, legacy bool) []composeService {
+	var (
+		services   = []composeService{}
+		inServices = false
+
+		// nameIndent is the indentation of service names, and propIndent the
+		// indentation of the current service's options
+		nameIndent = -1
+		propIndent = -1
+	)
+	for _, line := range strings.Split(string(compose), "\n") {
+		var trimmed = strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		var depth = len(line) - len(strings.TrimLeft(line, " \t"))
+		var match = composeKey.FindStringSubmatch(trimmed)
+		if depth == 0 {
+			if !legacy {
+				inServices = match != nil && match[1] == "services"
+				nameIndent, propIndent = -1, -1
+				continue
+			}
+			inServices, nameIndent = true, 0
+		}
+		if !inServices {
+			continue
+		}
+		if nameIndent == -1 {
+			nameIndent = depth
+		}
+		switch {
+		case depth == nameIndent && match != nil:
+			services = append(services, composeService{name: match[1]})
+			propIndent = -1
+		case depth > nameIndent && len(services) > 0:
+			if propIndent == -1 {
+				propIndent = depth
+			}
+			if depth != propIndent || match == nil {
+				continue
+			}
+			var service = &services[len(services)-1]
+			switch match[1] {
+			case "image":
+				service.image = getComposeValue(match[2])
+			case "build":
+				service.build = true
+			}
+		}
+	}
+	return services
+}
+
+// getComposeValue returns the given scalar docker-compose value without
+// quotes or trailing comments
+func getComposeValue(value string) string {
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.Trim(strings.TrimSpace(value), `"'`)
+}
+
+// describeComposeVersion describes the format version of a docker-compose
+// file for error messages
+func describeComposeVersion(version string, legacy bool) string {
+	if legacy {
+		return "the legacy format"
+	}
+	return fmt.Sprintf("version '%s'", version)
+}
+
+// writeComposeOverride writes a docker-compose override file to dir that
+// applies Inertia's labels and verified image digests to every service, along
+// with the configured labels, resources, networking, and healthcheck options,
+// using the same file format version as the given docker-compose file. JSON is
+// used since it is valid YAML.
+func writeComposeOverride(dir, composeFile string, d Config) error {
+	compose, err := ioutil.ReadFile(filepath.Join(dir, composeFile))
+	if err != nil {
+		return err
+	}
+	var version string
+	if match := composeVersion.FindSubmatch(compose); match != nil {
+		version = string(match[1])
+	}
+	var legacy = isLegacyCompose(compose)
+
+	var services = make(map[string]map[string]interface{})
+	var service = func(name string) map[string]interface{} {
+		if _, ok := services[name]; !ok {
+			services[name] = make(map[string]interface{})
+		}
+		return services[name]
+	}
+	for _, s := range getComposeServices(compose, legacy) {
+		service(s.name)["labels"] = map[string]string{
+			LabelProject: d.Name,
+			LabelService: s.name,
+		}
+		if pinned, ok := d.pinnedImages[s.image]; ok && !s.build {
+			service(s.name)["image"] = pinned
+		}
+	}
+	for name, l := range d.Labels {
+		merged, err := getContainerLabels(d.Name, name, l)
+		if err != nil {
+			return err
+		}
+		service(name)["labels"] = merged
+	}
+	for name, r := range d.Resources {
+		// Reservations are only supported by version 2 of the file format -
+		// version 3 only supports them when deploying to a swarm
+		if !strings.HasPrefix(version, "2") {
+			return fmt.Errorf("resource reservations require docker-compose file "+
+				"format version 2, but %s uses version '%s'", composeFile, version)
+		}
+		resources, err := getContainerResources(r)
+		if err != nil {
+			return fmt.Errorf("service '%s': %s", name, err.Error())
+		}
+		if resources.MemoryReservation > 0 {
+			service(name)["mem_reservation"] = resources.MemoryReservation
+		}
+		if resources.CPUShares > 0 {
+			service(name)["cpu_shares"] = resources.CPUShares
+		}
+	}
+	for name, n := range d.Networking {
+		if err := validateNetworking(n); err != nil {
+			return fmt.Errorf("service '%s': %s", name, err.Error())
+		}
+		if len(n.ExtraHosts) > 0 {
+			service(name)["extra_hosts"] = n.ExtraHosts
+		}
+		if len(n.DNS) > 0 {
+			service(name)["dns"] = n.DNS
+		}
+		if len(n.Aliases) > 0 {
+			// Legacy files don't support networks
+			if legacy {
+				return fmt.Errorf("network aliases require docker-compose file "+
+					"format version 2 or later, but %s uses the legacy format", composeFile)
+			}
+			service(name)["networks"] = map[string]interface{}{
+				"default": map[string]interface{}{"aliases": n.Aliases},
+			}
+		}
+	}
+
+	for name, h := range d.Healthchecks {
+		health, err := getContainerHealthcheck(h)
+		if err != nil {
+			return fmt.Errorf("service '%s': %s", name, err.Error())
+		}
+		if health == nil {
+			continue
+		}
+
+		// Healthchecks were added in format versions 2.1 and 3.0, and start
+		// periods in versions 2.3 and 3.4
+		if !composeVersionSupports(version, legacy, 1, 0) {
+			return fmt.Errorf("healthchecks require docker-compose file format "+
+				"version 2.1 or later, but %s uses %s", composeFile,
+				describeComposeVersion(version, legacy))
+		}
+		if h.StartPeriod != "" && !composeVersionSupports(version, legacy, 3, 4) {
+			return fmt.Errorf("healthcheck start periods require docker-compose file "+
+				"format version 2.3 or 3.4 or later, but %s uses %s", composeFile,
+				describeComposeVersion(version, legacy))
+		}
+
+		var healthcheck = map[string]interface{}{}
+		if len(health.Test) > 0 && health.Test[0] == "NONE" {
+			healthcheck["disable"] = true
+		} else {
+			// Durations are passed through as configured, since they have
+			// been validated and docker-compose accepts the same format
+			if len(health.Test) > 0 {
+				healthcheck["test"] = health.Test
+			}
+			if h.Interval != "" {
+				healthcheck["interval"] = h.Interval
+			}
+			if h.Timeout != "" {
+				healthcheck["timeout"] = h.Timeout
+			}
+			if h.StartPeriod != "" {
+				healthcheck["start_period"] = h.StartPeriod
+			}
+			if h.Retries > 0 {
+				healthcheck["retries"] = h.Retries
+			}
+		}
+		service(name)["healthcheck"] = healthcheck
+	}
+	for name, c := range d.Commands {
+		if len(c.Entrypoint) > 0 {
+			service(name)["entrypoint"] = c.Entrypoint
+		}
+		if len(c.Command) > 0 {
+			service(name)["command"] = c.Command
+		}
+	}
+
+	// Services are declared at the top level of legacy files
+	var override interface{} = services
+	if !legacy {
+		var root = map[string]interface{}{"services": services}
+		if version != "" {
+			root["version"] = version
+		}
+		override = root
+	}
+
+	data, err := json.MarshalIndent(override, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, composeOverrideFile), data, 0644)
+}
+
 // buildTar takes a source and variable writers and walks 'source' writing each file
 // found to the tar writer; the purpose for accepting multiple writers is to allow
 // for multiple outputs (for example a file, or md5 hash)
 // Sourced from https://gist.github.com/sdomino/e6bc0c98f87843bc26bb#file-targz-go
-func buildTar(dir string, outputs ...io.Writer) error {
+// Files excluded by ignore, if provided, are left out.
+func buildTar(dir string, ignore *contextIgnore, outputs ...io.Writer) error {
 
 	// ensure the src actually exists before trying to tar it
 	if _, err := os.Stat(dir); err != nil {
@@ -43,6 +529,14 @@ func buildTar(dir string, outputs ...io.Writer) error {
 			return err
 		}
 
+		// skip excluded files
+		if rel, err := filepath.Rel(dir, file); err == nil && rel != "." && ignore.excludes(rel) {
+			if fi.IsDir() && ignore.skips(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// create a new dir/file header
 		header, err := tar.FileInfoHeader(fi, fi.Name())
 		if err != nil {
@@ -0,0 +1,393 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func Test_getContainerUser(t *testing.T) {
+	type args struct {
+		d         Config
+		imageUser string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"use image user", args{Config{}, ""}, ""},
+		{"use image user when enforcing", args{Config{EnforceNonRootUser: true}, "app"}, ""},
+		{"enforce non-root", args{Config{EnforceNonRootUser: true}, ""}, DefaultNonRootUser},
+		{"configured user", args{Config{ContainerUser: "1000:1000"}, "app"}, "1000:1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getContainerUser(tt.args.d, tt.args.imageUser))
+		})
+	}
+}
+
+func Test_getBuildArgs(t *testing.T) {
+	assert.Nil(t, getBuildArgs(nil))
+
+	var args = getBuildArgs(map[string]string{"VERSION": "1.2.3", "PROFILE": ""})
+	assert.Len(t, args, 2)
+	assert.Equal(t, "1.2.3", *args["VERSION"])
+	assert.Equal(t, "", *args["PROFILE"])
+}
+
+func Test_getContainerLabels(t *testing.T) {
+	type args struct {
+		service string
+		labels  map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{"management labels only", args{"web", nil},
+			map[string]string{LabelProject: "wow", LabelService: "web"}, false},
+		{"merged labels", args{"web", map[string]string{"team": "launchpad"}},
+			map[string]string{LabelProject: "wow", LabelService: "web", "team": "launchpad"}, false},
+		{"reserved label", args{"web", map[string]string{LabelProject: "other"}},
+			nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getContainerLabels("wow", tt.args.service, tt.args.labels)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getContainerResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources api.Resources
+		want      container.Resources
+		wantErr   bool
+	}{
+		{"none", api.Resources{}, container.Resources{}, false},
+		{"reservations", api.Resources{MemoryReservation: "256m", CPUShares: 512},
+			container.Resources{MemoryReservation: 256 * 1024 * 1024, CPUShares: 512}, false},
+		{"invalid memory", api.Resources{MemoryReservation: "lots"}, container.Resources{}, true},
+		{"invalid shares", api.Resources{CPUShares: 1}, container.Resources{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getContainerResources(tt.resources)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_getHostPlatform(t *testing.T) {
+	tests := []struct {
+		name   string
+		osType string
+		arch   string
+		want   string
+	}{
+		{"amd64", "linux", "x86_64", "linux/amd64"},
+		{"arm64", "linux", "aarch64", "linux/arm64"},
+		{"arm variant", "linux", "armv7l", "linux/arm/v7"},
+		{"unknown architecture", "linux", "mips", ""},
+		{"unknown os", "", "x86_64", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getHostPlatform(tt.osType, tt.arch))
+		})
+	}
+}
+
+func Test_validatePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		wantErr  bool
+	}{
+		{"linux/amd64", false},
+		{"linux/arm/v7", false},
+		{"amd64", true},
+		{"linux/", true},
+		{"linux/arm/v7/extra", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validatePlatform(tt.platform) != nil)
+		})
+	}
+}
+
+func Test_validateNetworking(t *testing.T) {
+	tests := []struct {
+		name       string
+		networking api.Networking
+		wantErr    bool
+	}{
+		{"none", api.Networking{}, false},
+		{"valid", api.Networking{
+			ExtraHosts: []string{"db.internal:10.0.0.5", "ipv6.internal:::1"},
+			DNS:        []string{"1.1.1.1", "2001:4860:4860::8888"},
+		}, false},
+		{"missing IP", api.Networking{ExtraHosts: []string{"db.internal"}}, true},
+		{"missing hostname", api.Networking{ExtraHosts: []string{":10.0.0.5"}}, true},
+		{"invalid IP", api.Networking{ExtraHosts: []string{"db.internal:db"}}, true},
+		{"invalid DNS server", api.Networking{DNS: []string{"dns.google"}}, true},
+		{"aliases", api.Networking{Aliases: []string{"db", "cache.internal", "my_redis"}}, false},
+		{"invalid alias", api.Networking{Aliases: []string{"db:5432"}}, true},
+		{"empty alias", api.Networking{Aliases: []string{""}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validateNetworking(tt.networking) != nil)
+		})
+	}
+}
+
+func Test_getContainerHealthcheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		healthcheck api.Healthcheck
+		want        *container.HealthConfig
+		wantErr     bool
+	}{
+		{"none", api.Healthcheck{}, nil, false},
+		{"exec form", api.Healthcheck{Test: []string{"CMD", "curl", "-f", "localhost"}},
+			&container.HealthConfig{Test: []string{"CMD", "curl", "-f", "localhost"}}, false},
+		{"shell command", api.Healthcheck{Test: []string{"curl -f localhost || exit 1"}},
+			&container.HealthConfig{Test: []string{"CMD-SHELL", "curl -f localhost || exit 1"}}, false},
+		{"options only", api.Healthcheck{Interval: "5s", Timeout: "1s", StartPeriod: "1m", Retries: 5},
+			&container.HealthConfig{Interval: 5 * time.Second, Timeout: time.Second,
+				StartPeriod: time.Minute, Retries: 5}, false},
+		{"disabled", api.Healthcheck{Test: []string{"NONE"}, Interval: "5s"},
+			&container.HealthConfig{Test: []string{"NONE"}}, false},
+		{"disabled with arguments", api.Healthcheck{Test: []string{"NONE", "curl"}}, nil, true},
+		{"invalid interval", api.Healthcheck{Interval: "often"}, nil, true},
+		{"interval too short", api.Healthcheck{Interval: "1us"}, nil, true},
+		{"negative retries", api.Healthcheck{Retries: -1}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getContainerHealthcheck(tt.healthcheck)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getComposeServices(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		legacy  bool
+		want    []composeService
+	}{
+		{"versioned", "version: '3'\nservices:\n  web:\n    build: .\n    ports:\n      - 80:80\n" +
+			"  # comment\n  db:\n    image: \"postgres:11\" # pinned\nvolumes:\n  data:\n", false,
+			[]composeService{{name: "web", build: true}, {name: "db", image: "postgres:11"}}},
+		{"quoted", "version: '3'\nservices:\n    \"web\":\n        image: nginx\n", false,
+			[]composeService{{name: "web", image: "nginx"}}},
+		{"nested image", "version: '3'\nservices:\n  web:\n    build:\n      context: .\n" +
+			"    labels:\n      image: not-an-image\n", false,
+			[]composeService{{name: "web", build: true}}},
+		{"legacy", "web:\n  build: .\n  image: web:latest\ndb:\n  image: postgres\n", true,
+			[]composeService{{name: "web", image: "web:latest", build: true}, {name: "db", image: "postgres"}}},
+		{"no services", "version: '3'\nvolumes:\n  data:\n", false, []composeService{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getComposeServices([]byte(tt.compose), tt.legacy))
+		})
+	}
+}
+
+func Test_writeComposeOverride(t *testing.T) {
+	type args struct {
+		compose    string
+		resources  map[string]api.Resources
+		networking map[string]api.Networking
+		health     map[string]api.Healthcheck
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"versioned", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil, nil, nil},
+			`"version": "3"`, false},
+		{"legacy", args{"web:\n  build: .\n", nil, nil, nil},
+			`"web": {`, false},
+		{"resources", args{"version: \"2.4\"\nservices:\n  web:\n    build: .\n",
+			map[string]api.Resources{"web": {MemoryReservation: "1k", CPUShares: 512}}, nil, nil},
+			`"mem_reservation": 1024`, false},
+		{"resources unsupported", args{"version: '3'\nservices:\n  web:\n    build: .\n",
+			map[string]api.Resources{"web": {CPUShares: 512}}, nil, nil},
+			"", true},
+		{"networking", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil,
+			map[string]api.Networking{"web": {ExtraHosts: []string{"db.internal:10.0.0.5"}}}, nil},
+			`"db.internal:10.0.0.5"`, false},
+		{"aliases", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil,
+			map[string]api.Networking{"web": {Aliases: []string{"api"}}}, nil},
+			`"aliases": [`, false},
+		{"aliases unsupported", args{"web:\n  build: .\n", nil,
+			map[string]api.Networking{"web": {Aliases: []string{"api"}}}, nil},
+			"", true},
+		{"invalid networking", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil,
+			map[string]api.Networking{"web": {DNS: []string{"dns.google"}}}, nil},
+			"", true},
+		{"healthcheck", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {Test: []string{"curl -f localhost"}, Interval: "5s"}}},
+			`"CMD-SHELL"`, false},
+		{"healthcheck disabled", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {Test: []string{"NONE"}}}},
+			`"disable": true`, false},
+		{"invalid healthcheck", args{"version: '3'\nservices:\n  web:\n    build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {Interval: "often"}}},
+			"", true},
+		{"healthcheck unsupported", args{"version: '2'\nservices:\n  web:\n    build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {Interval: "5s"}}},
+			"", true},
+		{"healthcheck legacy", args{"web:\n  build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {Interval: "5s"}}},
+			"", true},
+		{"healthcheck start period", args{"version: '3.4'\nservices:\n  web:\n    build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {StartPeriod: "1m"}}},
+			`"start_period": "1m"`, false},
+		{"healthcheck start period unsupported", args{"version: '3.3'\nservices:\n  web:\n    build: .\n", nil, nil,
+			map[string]api.Healthcheck{"web": {StartPeriod: "1m"}}},
+			"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-override")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			err = ioutil.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(tt.args.compose), 0644)
+			assert.Nil(t, err)
+
+			err = writeComposeOverride(dir, "docker-compose.yml", Config{
+				Name:         "wow",
+				Labels:       map[string]map[string]string{"web": {"team": "launchpad"}},
+				Resources:    tt.args.resources,
+				Networking:   tt.args.networking,
+				Healthchecks: tt.args.health,
+			})
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			override, err := ioutil.ReadFile(filepath.Join(dir, composeOverrideFile))
+			assert.Nil(t, err)
+			assert.Contains(t, string(override), tt.want)
+			assert.Contains(t, string(override), `"team": "launchpad"`)
+		})
+	}
+}
+
+func Test_composeVersionSupports(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		legacy  bool
+		want    bool
+	}{
+		{"legacy", "", true, false},
+		{"compose specification", "", false, true},
+		{"version 2", "2", false, false},
+		{"version 2.3", "2.3", false, true},
+		{"version 3", "3", false, false},
+		{"version 3.4", "3.4", false, true},
+		{"version 3.10", "3.10", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, composeVersionSupports(tt.version, tt.legacy, 3, 4))
+		})
+	}
+}
+
+func Test_writeComposeOverrideCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands map[string]api.CommandOverride
+		want     []string
+		wantNot  []string
+	}{
+		{"command", map[string]api.CommandOverride{
+			"worker": {Command: []string{"bundle", "exec", "sidekiq"}},
+		}, []string{`"worker": {`, `"command": [`, `"sidekiq"`}, []string{`"entrypoint"`}},
+		{"entrypoint and command", map[string]api.CommandOverride{
+			"web": {Entrypoint: []string{"/bin/sh", "-c"}, Command: []string{"./serve"}},
+		}, []string{`"entrypoint": [`, `"/bin/sh"`, `"command": [`}, nil},
+		{"empty override", map[string]api.CommandOverride{"web": {}},
+			nil, []string{`"entrypoint"`, `"command"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-override")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			err = ioutil.WriteFile(filepath.Join(dir, "docker-compose.yml"),
+				[]byte("version: '3'\nservices:\n  web:\n    build: .\n  worker:\n    build: .\n"), 0644)
+			assert.Nil(t, err)
+
+			err = writeComposeOverride(dir, "docker-compose.yml", Config{
+				Name:     "wow",
+				Commands: tt.commands,
+			})
+			assert.Nil(t, err)
+			override, err := ioutil.ReadFile(filepath.Join(dir, composeOverrideFile))
+			assert.Nil(t, err)
+
+			// All services should be labelled, even without configured labels
+			assert.Contains(t, string(override), `"`+LabelService+`": "web"`)
+			assert.Contains(t, string(override), `"`+LabelService+`": "worker"`)
+			for _, want := range tt.want {
+				assert.Contains(t, string(override), want)
+			}
+			for _, wantNot := range tt.wantNot {
+				assert.NotContains(t, string(override), wantNot)
+			}
+		})
+	}
+}
+
+func Test_writeComposeOverridePinnedImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-override")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "docker-compose.yml"),
+		[]byte("version: '3'\nservices:\n  web:\n    build: .\n    image: web:latest\n"+
+			"  db:\n    image: postgres:11\n"), 0644)
+	assert.Nil(t, err)
+
+	err = writeComposeOverride(dir, "docker-compose.yml", Config{
+		Name: "wow",
+		pinnedImages: map[string]string{
+			"postgres:11": "postgres@sha256:abc",
+			"web:latest":  "web@sha256:def",
+		},
+	})
+	assert.Nil(t, err)
+	override, err := ioutil.ReadFile(filepath.Join(dir, composeOverrideFile))
+	assert.Nil(t, err)
+
+	// Only services that are not built should use verified digests
+	assert.Contains(t, string(override), `"image": "postgres@sha256:abc"`)
+	assert.NotContains(t, string(override), "web@sha256:def")
+}
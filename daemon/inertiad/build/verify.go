@@ -0,0 +1,185 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+const (
+	// cosignImage is the image used to verify image signatures
+	cosignImage = "gcr.io/projectsigstore/cosign:v1.13.1"
+
+	// cosignKeyEnv is the environment variable the public key used to verify
+	// image signatures is passed to cosign through
+	cosignKeyEnv = "INERTIA_COSIGN_PUBLIC_KEY"
+
+	// pinnedDockerfile is the name of the copy of a project's Dockerfile that
+	// is built from the verified digests of its base images
+	pinnedDockerfile = "Dockerfile.inertia-pinned"
+)
+
+var dockerfileFrom = regexp.MustCompile(`(?im)^\s*FROM\s+(?:--platform=\S+\s+)?(\S+)(?:\s+AS\s+(\S+))?`)
+
+// getDockerfileBaseImages returns the images the given Dockerfile's stages
+// are built from, excluding earlier stages and the empty 'scratch' image
+func getDockerfileBaseImages(dockerfile []byte) []string {
+	var (
+		images = []string{}
+		stages = map[string]bool{"scratch": true}
+	)
+	for _, match := range dockerfileFrom.FindAllSubmatch(dockerfile, -1) {
+		var image = string(match[1])
+		if !stages[strings.ToLower(image)] {
+			images = append(images, image)
+		}
+		if len(match[2]) > 0 {
+			stages[strings.ToLower(string(match[2]))] = true
+		}
+	}
+	return images
+}
+
+// getComposeImages returns the images used by services in the given
+// docker-compose file, excluding those of services that are built
+func getComposeImages(compose []byte) []string {
+	var images = []string{}
+	for _, service := range getComposeServices(compose, isLegacyCompose(compose)) {
+		if service.image != "" && !service.build {
+			images = append(images, service.image)
+		}
+	}
+	return images
+}
+
+// pinImage returns a reference to the digest the given image currently
+// refers to in its registry, so that the image that is verified is the image
+// that is used even if its tag is moved in the meantime. References that
+// already include a digest are returned unchanged.
+func pinImage(ctx context.Context, cli *docker.Client, image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference '%s': %s", image, err.Error())
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return image, nil
+	}
+	inspect, err := cli.DistributionInspect(ctx, named.String(), "")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve digest of image '%s': %s", image, err.Error())
+	}
+	return reference.FamiliarName(named) + "@" + inspect.Descriptor.Digest.String(), nil
+}
+
+// pinDockerfile replaces the base images of the given Dockerfile's stages
+// with the pinned references they map to
+func pinDockerfile(dockerfile []byte, pinned map[string]string) []byte {
+	return dockerfileFrom.ReplaceAllFunc(dockerfile, func(from []byte) []byte {
+		var match = dockerfileFrom.FindSubmatchIndex(from)
+		ref, ok := pinned[string(from[match[2]:match[3]])]
+		if !ok {
+			return from
+		}
+		var replaced = append([]byte{}, from[:match[2]]...)
+		replaced = append(replaced, ref...)
+		return append(replaced, from[match[3]:]...)
+	})
+}
+
+// writePinnedDockerfile writes a copy of the given Dockerfile that is built
+// from the pinned references of its base images next to it, and returns its
+// path relative to dir
+func writePinnedDockerfile(dir, dockerfile string, pinned map[string]string) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, dockerfile))
+	if err != nil {
+		return "", err
+	}
+	var pinnedPath = path.Join(path.Dir(dockerfile), pinnedDockerfile)
+	if err := ioutil.WriteFile(filepath.Join(dir, pinnedPath),
+		pinDockerfile(contents, pinned), 0644); err != nil {
+		return "", err
+	}
+	return pinnedPath, nil
+}
+
+// verifyImages checks that each of the given images is signed by the owner of
+// the given public key, using cosign, and returns references to the digests
+// that were verified, keyed by image. Images are referenced as they appear in
+// the project's build files, so references that depend on build arguments or
+// environment variables cannot be verified and are rejected.
+func verifyImages(ctx context.Context, cli *docker.Client, images []string,
+	publicKey string, out io.Writer) (map[string]string, error) {
+	var pinned = make(map[string]string, len(images))
+	if len(images) == 0 {
+		return pinned, nil
+	}
+	for _, image := range images {
+		if strings.Contains(image, "$") {
+			return nil, fmt.Errorf("unable to verify signature of image '%s' - "+
+				"images referenced through variables cannot be verified", image)
+		}
+	}
+
+	reader, err := cli.ImagePull(ctx, cosignImage, types.ImagePullOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image verification tool: %s", err.Error())
+	}
+	io.Copy(ioutil.Discard, reader)
+	reader.Close()
+
+	for _, image := range images {
+		if _, ok := pinned[image]; ok {
+			continue
+		}
+		ref, err := pinImage(ctx, cli, image)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "Verifying signature of image %s...\n", ref)
+		var name = "inertia-verify-image"
+		cli.ContainerRemove(ctx, name, types.ContainerRemoveOptions{Force: true})
+		resp, err := cli.ContainerCreate(ctx, &container.Config{
+			Image: cosignImage,
+			Cmd:   []string{"verify", "--key", "env://" + cosignKeyEnv, ref},
+			Env:   []string{cosignKeyEnv + "=" + publicKey},
+		}, &container.HostConfig{}, nil, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify signature of image '%s': %s", image, err.Error())
+		}
+		err = containers.StartAndWait(cli, resp.ID, out)
+		cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			return nil, fmt.Errorf("image '%s' is not signed by the configured key: %s",
+				image, err.Error())
+		}
+		pinned[image] = ref
+	}
+	fmt.Fprintln(out, "All image signatures verified")
+	return pinned, nil
+}
+
+// verifyProjectImages verifies the signatures of images referenced by the
+// given build file, if a public key to verify them with is configured, and
+// returns references to the digests that were verified, keyed by image
+func verifyProjectImages(ctx context.Context, cli *docker.Client, d Config,
+	buildFile string, getImages func([]byte) []string, out io.Writer) (map[string]string, error) {
+	if d.VerifyImagesKey == "" {
+		return nil, nil
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(d.BuildDirectory, buildFile))
+	if err != nil {
+		return nil, err
+	}
+	return verifyImages(ctx, cli, getImages(contents), d.VerifyImagesKey, out)
+}
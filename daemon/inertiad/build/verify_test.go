@@ -0,0 +1,94 @@
+package build
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getDockerfileBaseImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		want       []string
+	}{
+		{"single stage", "FROM node:10\nRUN npm install\n", []string{"node:10"}},
+		{"multi stage",
+			"FROM golang:1.11 AS build\nRUN go build\nfrom build as test\n" +
+				"FROM --platform=linux/amd64 alpine:3.9\nCOPY --from=build /app /app\n",
+			[]string{"golang:1.11", "alpine:3.9"}},
+		{"scratch", "FROM scratch\nCOPY app /\n", []string{}},
+		{"variable", "ARG BASE=node\nFROM ${BASE}\n", []string{"${BASE}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getDockerfileBaseImages([]byte(tt.dockerfile)))
+		})
+	}
+}
+
+func Test_getComposeImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		want    []string
+	}{
+		{"build only", "version: '3'\nservices:\n  web:\n    build: .\n", []string{}},
+		{"images",
+			"version: '3'\nservices:\n  web:\n    build: .\n  db:\n    image: \"postgres:11\"\n" +
+				"  cache:\n    image: redis # latest\n",
+			[]string{"postgres:11", "redis"}},
+		{"built images", "version: '3'\nservices:\n  web:\n    build: .\n    image: web:latest\n",
+			[]string{}},
+		{"legacy", "db:\n  image: postgres:11\n", []string{"postgres:11"}},
+		{"no version", "services:\n  db:\n    image: postgres:11\n", []string{"postgres:11"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getComposeImages([]byte(tt.compose)))
+		})
+	}
+}
+
+func Test_pinImage(t *testing.T) {
+	// References with digests should be used as they are, without a client
+	var digest = "alpine@sha256:769fddc7cc2f0a1c35abb2f91432e8beecf83916c421420e6a6da9f8975464b6"
+	pinned, err := pinImage(context.Background(), nil, digest)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, pinned)
+
+	_, err = pinImage(context.Background(), nil, "Invalid:Reference:")
+	assert.NotNil(t, err)
+}
+
+func Test_pinDockerfile(t *testing.T) {
+	var dockerfile = "FROM golang:1.11 AS build\nRUN go build\nFROM build AS test\n" +
+		"FROM --platform=linux/amd64 alpine:3.9\nCOPY --from=build /app /app\n"
+	var pinned = pinDockerfile([]byte(dockerfile), map[string]string{
+		"golang:1.11": "golang@sha256:abc",
+		"alpine:3.9":  "alpine@sha256:def",
+	})
+	assert.Equal(t, "FROM golang@sha256:abc AS build\nRUN go build\nFROM build AS test\n"+
+		"FROM --platform=linux/amd64 alpine@sha256:def\nCOPY --from=build /app /app\n", string(pinned))
+}
+
+func Test_writePinnedDockerfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-pinned")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "docker"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "docker", "Dockerfile"),
+		[]byte("FROM node:10\n"), 0644))
+
+	pinnedPath, err := writePinnedDockerfile(dir, "docker/Dockerfile",
+		map[string]string{"node:10": "node@sha256:abc"})
+	assert.Nil(t, err)
+	assert.Equal(t, "docker/"+pinnedDockerfile, pinnedPath)
+	contents, err := ioutil.ReadFile(filepath.Join(dir, pinnedPath))
+	assert.Nil(t, err)
+	assert.Equal(t, "FROM node@sha256:abc\n", string(contents))
+}
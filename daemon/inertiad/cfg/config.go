@@ -1,6 +1,46 @@
 package cfg
 
-import "os"
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// SettingsFile is the name of the file in the data directory that daemon
+	// settings can be set in, overriding the environment. Changes to it are
+	// applied when the daemon's configuration is reloaded.
+	SettingsFile = "daemon.env"
+
+	// DefaultMaxLogStreams is the default limit on concurrent log streams
+	DefaultMaxLogStreams = 10
+
+	// DefaultMinFreeDiskMB is the default amount of free disk space required
+	// to start a deployment
+	DefaultMinFreeDiskMB = 512
+
+	// DefaultDeployOutputBuffer is the default number of writes of deployment
+	// output buffered for each client
+	DefaultDeployOutputBuffer = 1000
+
+	// DefaultMaxDeployRecords and DefaultMaxDeployRecordAgeDays are the
+	// default limits on how much deployment history is kept
+	DefaultMaxDeployRecords       = 1000
+	DefaultMaxDeployRecordAgeDays = 180
+
+	// DefaultMaxConcurrentBuilds is the default limit on concurrent builds
+	DefaultMaxConcurrentBuilds = 1
+
+	// DefaultMaxPayloadSizeMB is the default limit on the size of webhook
+	// payloads, deployment requests, and restored database backups, which
+	// matches GitHub's limit on webhook payloads
+	DefaultMaxPayloadSizeMB = 25
+)
 
 // Config provides basic daemon configuration
 type Config struct {
@@ -12,15 +52,140 @@ type Config struct {
 	// Build tools
 	DockerComposeVersion string // "docker/compose:1.21.0"
 
+	// Limits
+	MaxLogStreams       int // 10
+	MinFreeDiskMB       int // 512
+	MaxConcurrentBuilds int // 1
+	MaxPayloadSizeMB    int // 25
+
+	// Deployment history retention - older records, and the saved output of
+	// older deployments, are periodically removed
+	MaxDeployRecords       int // 1000
+	MaxDeployRecordAgeDays int // 180
+
+	// Deployment output buffering for slow clients - the overflow policy is
+	// either "drop-oldest" or "detach"
+	DeployOutputBuffer   int    // 1000
+	DeployOutputOverflow string // "drop-oldest"
+
+	// OTLPEndpoint is the OpenTelemetry collector that traces of deployments
+	// are exported to - deployments are not traced if it is empty
+	OTLPEndpoint string
+
 	WebhookSecret string
+
+	// WatchPaths restricts push webhooks that trigger deployments to those
+	// that change files matching these paths
+	WatchPaths []string
+
+	// BaseImageCheckHours is how often the project's base images are checked
+	// for updates, which trigger a redeploy - never if it is zero
+	BaseImageCheckHours int
+
+	// PollIntervalMinutes is how often the deployed branch is fetched for new
+	// commits, which trigger a deploy - never if it is zero
+	PollIntervalMinutes int
+
+	// Alerts configures resource usage alerts - usage is not monitored if it
+	// is nil
+	Alerts *api.Alerts
 }
 
 // New creates a new daemon configuration from environment values
 func New() *Config {
+	return newConfig(os.Getenv)
+}
+
+// SettingsPath returns the path of the daemon settings file, which is kept in
+// the data directory
+func SettingsPath() string {
+	return path.Join(os.Getenv("INERTIA_DATA_DIR"), SettingsFile)
+}
+
+// Load creates a new daemon configuration from environment values, overridden
+// by any values set in the settings file at the given path. The settings file
+// contains KEY=VALUE lines using the same keys as the environment, and is
+// ignored if it does not exist.
+func Load(settingsPath string) (*Config, error) {
+	settings, err := readSettings(settingsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read daemon settings: %s", err.Error())
+	}
+	return newConfig(func(key string) string {
+		if value, ok := settings[key]; ok {
+			return value
+		}
+		return os.Getenv(key)
+	}), nil
+}
+
+// newConfig creates a daemon configuration from values retrieved by getenv
+func newConfig(getenv func(string) string) *Config {
+	var getInt = func(key string, fallback int) int {
+		return parsePositiveInt(getenv(key), fallback)
+	}
 	return &Config{
-		SecretsDirectory:     os.Getenv("INERTIA_SECRETS_DIR"),
-		DataDirectory:        os.Getenv("INERTIA_DATA_DIR"),
-		DockerComposeVersion: os.Getenv("INERTIA_DOCKERCOMPOSE"),
-		ProjectDirectory:     os.Getenv("INERTIA_PROJECT_DIR"),
+		SecretsDirectory:       getenv("INERTIA_SECRETS_DIR"),
+		DataDirectory:          getenv("INERTIA_DATA_DIR"),
+		DockerComposeVersion:   getenv("INERTIA_DOCKERCOMPOSE"),
+		ProjectDirectory:       getenv("INERTIA_PROJECT_DIR"),
+		MaxLogStreams:          getInt("INERTIA_MAX_LOG_STREAMS", DefaultMaxLogStreams),
+		MinFreeDiskMB:          getInt("INERTIA_MIN_FREE_DISK_MB", DefaultMinFreeDiskMB),
+		MaxConcurrentBuilds:    getInt("INERTIA_MAX_CONCURRENT_BUILDS", DefaultMaxConcurrentBuilds),
+		MaxPayloadSizeMB:       getInt("INERTIA_MAX_PAYLOAD_SIZE_MB", DefaultMaxPayloadSizeMB),
+		MaxDeployRecords:       getInt("INERTIA_MAX_DEPLOY_RECORDS", DefaultMaxDeployRecords),
+		MaxDeployRecordAgeDays: getInt("INERTIA_MAX_DEPLOY_RECORD_AGE_DAYS", DefaultMaxDeployRecordAgeDays),
+		DeployOutputBuffer:     getInt("INERTIA_DEPLOY_OUTPUT_BUFFER", DefaultDeployOutputBuffer),
+		DeployOutputOverflow:   getenv("INERTIA_DEPLOY_OUTPUT_OVERFLOW"),
+		OTLPEndpoint:           getenv("INERTIA_OTLP_ENDPOINT"),
+	}
+}
+
+// readSettings reads KEY=VALUE lines from the settings file at the given
+// path. Blank lines and lines starting with '#' are ignored.
+func readSettings(settingsPath string) (map[string]string, error) {
+	var settings = map[string]string{}
+	if settingsPath == "" {
+		return settings, nil
+	}
+	file, err := os.Open(settingsPath)
+	if os.IsNotExist(err) {
+		return settings, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var scanner = bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var setting = strings.TrimSpace(scanner.Text())
+		if setting == "" || strings.HasPrefix(setting, "#") {
+			continue
+		}
+		var parts = strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		settings[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return settings, scanner.Err()
+}
+
+// MaxPayloadSize returns the maximum size, in bytes, of webhook payloads,
+// deployment requests, and restored database backups
+func (c *Config) MaxPayloadSize() int64 {
+	if c.MaxPayloadSizeMB < 1 {
+		return DefaultMaxPayloadSizeMB << 20
+	}
+	return int64(c.MaxPayloadSizeMB) << 20
+}
+
+// parsePositiveInt parses the given positive integer, returning fallback if
+// it is not set or invalid
+func parsePositiveInt(s string, fallback int) int {
+	value, err := strconv.Atoi(s)
+	if err != nil || value < 1 {
+		return fallback
 	}
+	return value
 }
@@ -1,7 +1,9 @@
 package cfg
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,4 +13,41 @@ func TestNew(t *testing.T) {
 	os.Setenv("INERTIA_PROJECT_DIR", "/user/project")
 	cfg := New()
 	assert.Equal(t, "/user/project", cfg.ProjectDirectory)
+	assert.Equal(t, DefaultMaxLogStreams, cfg.MaxLogStreams)
+	assert.Equal(t, DefaultMinFreeDiskMB, cfg.MinFreeDiskMB)
+	assert.Equal(t, DefaultMaxDeployRecords, cfg.MaxDeployRecords)
+	assert.Equal(t, DefaultMaxDeployRecordAgeDays, cfg.MaxDeployRecordAgeDays)
+	assert.Equal(t, int64(DefaultMaxPayloadSizeMB<<20), cfg.MaxPayloadSize())
+
+	os.Setenv("INERTIA_MAX_LOG_STREAMS", "3")
+	cfg = New()
+	assert.Equal(t, 3, cfg.MaxLogStreams)
+	os.Unsetenv("INERTIA_MAX_LOG_STREAMS")
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-settings")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// A missing settings file should be ignored
+	cfg, err := Load(filepath.Join(dir, SettingsFile))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultMaxConcurrentBuilds, cfg.MaxConcurrentBuilds)
+
+	// Settings should override the environment
+	os.Setenv("INERTIA_MAX_LOG_STREAMS", "3")
+	defer os.Unsetenv("INERTIA_MAX_LOG_STREAMS")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, SettingsFile), []byte(
+		"# limits\n\nINERTIA_MAX_LOG_STREAMS = 5\nINERTIA_MAX_CONCURRENT_BUILDS=2\n"), 0644))
+	cfg, err = Load(filepath.Join(dir, SettingsFile))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, cfg.MaxLogStreams)
+	assert.Equal(t, 2, cfg.MaxConcurrentBuilds)
+	assert.Equal(t, DefaultMinFreeDiskMB, cfg.MinFreeDiskMB)
+
+	// Malformed settings should be rejected
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, SettingsFile), []byte("oops\n"), 0644))
+	_, err = Load(filepath.Join(dir, SettingsFile))
+	assert.NotNil(t, err)
 }
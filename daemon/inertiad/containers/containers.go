@@ -1,12 +1,15 @@
 package containers
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -27,20 +30,157 @@ type LogOptions struct {
 	Stream       bool
 	Detailed     bool
 	NoTimestamps bool
-	Entries      int
+
+	// Entries is the number of most recent entries to retrieve - all entries
+	// are retrieved if it is not set. When streaming, these entries are
+	// retrieved before new entries are followed.
+	Entries int
+
+	// NoStdout and NoStderr exclude the respective output streams
+	NoStdout bool
+	NoStderr bool
+
+	// StripANSI removes ANSI escape sequences, such as color codes, from
+	// retrieved logs
+	StripANSI bool
+
+	// Since is a timestamp (RFC3339Nano) - only logs at or after it are
+	// retrieved. If Entries is not set, all logs since this time are retrieved,
+	// otherwise only the last Entries of them are.
+	Since string
 }
 
 // ContainerLogs get logs ;)
 func ContainerLogs(docker *docker.Client, opts LogOptions) (io.ReadCloser, error) {
 	ctx := context.Background()
-	return docker.ContainerLogs(ctx, opts.Container, types.ContainerLogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
+	logs, err := docker.ContainerLogs(ctx, opts.Container, containerLogsOptions(opts))
+	if err != nil || !opts.StripANSI {
+		return logs, err
+	}
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(StripANSI(logs, pw)) }()
+	return &strippedLogs{PipeReader: pr, logs: logs}, nil
+}
+
+// strippedLogs are logs with ANSI escape sequences removed. Closing them also
+// closes the original logs.
+type strippedLogs struct {
+	*io.PipeReader
+	logs io.Closer
+}
+
+// Close stops reading logs
+func (s *strippedLogs) Close() error {
+	s.logs.Close()
+	return s.PipeReader.Close()
+}
+
+// containerLogsOptions converts opts into options for the Docker client. The
+// same number of entries is tailed whether or not logs are followed.
+func containerLogsOptions(opts LogOptions) types.ContainerLogsOptions {
+	var tail = "all"
+	if opts.Entries > 0 {
+		tail = strconv.Itoa(opts.Entries)
+	}
+	return types.ContainerLogsOptions{
+		ShowStdout: !opts.NoStdout,
+		ShowStderr: !opts.NoStderr,
 		Follow:     opts.Stream,
 		Timestamps: !opts.NoTimestamps,
 		Details:    opts.Detailed,
-		Tail:       strconv.Itoa(opts.Entries),
-	})
+		Since:      opts.Since,
+		Tail:       tail,
+	}
+}
+
+// NextLogCursor returns a cursor that can be provided as LogOptions.Since to
+// retrieve only logs written after the given timestamped logs. If no
+// timestamps are found, the given fallback cursor is returned.
+func NextLogCursor(logs []byte, fallback string) string {
+	// Logs from non-TTY containers are multiplexed into frames, whose headers
+	// can contain newlines and frames can split lines, so the headers are
+	// removed before the logs are split into lines
+	if len(logs) >= 8 && isStreamHeader(logs[:8]) {
+		var demuxed = new(bytes.Buffer)
+		StripStreamHeaders(bytes.NewReader(logs), demuxed)
+		logs = demuxed.Bytes()
+	}
+
+	var lines = bytes.Split(bytes.TrimRight(logs, "\n"), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var fields = bytes.SplitN(lines[i], []byte(" "), 2)
+		if t, err := time.Parse(time.RFC3339Nano, string(fields[0])); err == nil {
+			// Docker includes logs written at exactly the 'since' time
+			return t.Add(time.Nanosecond).Format(time.RFC3339Nano)
+		}
+	}
+	return fallback
+}
+
+// ParseLogSince converts since, either a timestamp (RFC3339) or a duration
+// before now such as "10m", into a timestamp that can be provided as
+// LogOptions.Since
+func ParseLogSince(since string, now time.Time) (string, error) {
+	if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil || d < 0 {
+		return "", fmt.Errorf("'%s' is neither a timestamp nor a positive duration", since)
+	}
+	return now.Add(-d).UTC().Format(time.RFC3339Nano), nil
+}
+
+// DemuxLogs separates logs multiplexed by Docker into stdout and stderr, and
+// writes each line to out labelled with the stream it came from. Logs from
+// containers that use a TTY, as indicated by tty, are not multiplexed since
+// both streams are written to the TTY, so they are copied to out unchanged.
+func DemuxLogs(logs io.Reader, out io.Writer, tty bool) error {
+	if tty {
+		_, err := io.Copy(out, logs)
+		return err
+	}
+	var header = make([]byte, 8)
+	for {
+		// Each frame is prefixed by a header indicating its stream and size
+		if _, err := io.ReadFull(logs, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var label string
+		switch header[0] {
+		case 1:
+			label = "stdout"
+		case 2:
+			label = "stderr"
+		default:
+			return fmt.Errorf("unrecognized log stream %d", header[0])
+		}
+
+		var frame = make([]byte, binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(logs, frame); err != nil {
+			return err
+		}
+		for _, line := range bytes.SplitAfter(frame, []byte("\n")) {
+			if len(line) > 0 {
+				if _, err := fmt.Fprintf(out, "[%s] %s", label, line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// UsesTTY checks if the given container was created with a TTY, in which case
+// its logs are not multiplexed
+func UsesTTY(cli *docker.Client, container string) (bool, error) {
+	info, err := cli.ContainerInspect(context.Background(), container)
+	if err != nil {
+		return false, err
+	}
+	return info.Config != nil && info.Config.Tty, nil
 }
 
 // StreamContainerLogs streams logs from given container ID. Best used as a
@@ -80,11 +220,42 @@ func GetActiveContainers(docker *docker.Client) ([]types.Container, error) {
 	return containers, nil
 }
 
+// DockerRootDir returns the host directory in which Docker stores images and
+// containers. The daemon container mounts it at the same path, so the disk
+// usage of the filesystem containing it can be checked.
+func DockerRootDir(cli *docker.Client) (string, error) {
+	info, err := cli.Info(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return info.DockerRootDir, nil
+}
+
+// DefaultStopTimeout is how long containers are given to stop gracefully
+// before they are killed if no timeout is configured
+const DefaultStopTimeout = 10 * time.Second
+
+// maxConcurrentStops is the number of containers that are stopped at once
+const maxConcurrentStops = 10
+
+// IsInfrastructureContainer indicates whether the container with the given
+// name is run by Inertia itself rather than by the project - the daemon, or
+// the buildx builder, which holds the build cache. Names may be given with or
+// without Docker's leading slash.
+func IsInfrastructureContainer(name string) bool {
+	name = strings.TrimPrefix(name, "/")
+	return name == "inertia-daemon" || strings.HasPrefix(name, "buildx_buildkit_")
+}
+
 // ContainerStopper is a function interface
-type ContainerStopper func(*docker.Client, io.Writer) error
+type ContainerStopper func(*docker.Client, io.Writer, time.Duration, ...string) error
 
-// StopActiveContainers kills all active project containers (ie not including daemon)
-func StopActiveContainers(docker *docker.Client, out io.Writer) error {
+// StopActiveContainers kills all active project containers (ie not including
+// daemon), except those with the given names, which are left running.
+// Containers are stopped concurrently, and those that do not stop within the
+// given timeout after being signalled are force-killed.
+func StopActiveContainers(docker *docker.Client, out io.Writer, timeout time.Duration,
+	keep ...string) error {
 	fmt.Fprintln(out, "Shutting down active containers...")
 	ctx := context.Background()
 	containers, err := docker.ContainerList(ctx, types.ContainerListOptions{})
@@ -92,21 +263,97 @@ func StopActiveContainers(docker *docker.Client, out io.Writer) error {
 		return err
 	}
 
-	// Gracefully take down all containers except the daemon
+	// Gracefully take down all containers except Inertia's own and those
+	// that should be kept
+	var kept = make(map[string]bool, len(keep))
+	for _, name := range keep {
+		kept[strings.TrimPrefix(name, "/")] = true
+	}
+	var stopping = make([]types.Container, 0, len(containers))
 	for _, container := range containers {
-		if container.Names[0] != "/inertia-daemon" {
-			fmt.Fprintln(out, "Stopping "+container.Names[0]+"...")
-			timeout := 10 * time.Second
-			if err := docker.ContainerStop(ctx, container.ID, &timeout); err != nil {
-				return err
-			}
+		if !IsInfrastructureContainer(container.Names[0]) &&
+			!kept[strings.TrimPrefix(container.Names[0], "/")] {
+			stopping = append(stopping, container)
+		}
+	}
 
-			// Archive container
-			docker.ContainerRename(
-				ctx, container.ID, fmt.Sprintf("%s-%d", container.Names[0], time.Now().Unix()))
+	var mux sync.Mutex
+	var printf = func(format string, args ...interface{}) {
+		mux.Lock()
+		fmt.Fprintf(out, format, args...)
+		mux.Unlock()
+	}
+	var errs = forEachConcurrently(len(stopping), maxConcurrentStops, func(i int) error {
+		var container = stopping[i]
+		printf("Stopping %s...\n", container.Names[0])
+		if err := docker.ContainerStop(ctx, container.ID, &timeout); err != nil {
+			return fmt.Errorf("failed to stop %s: %s", container.Names[0], err.Error())
+		}
+		if wasForceKilled(ctx, docker, container.ID) {
+			printf("%s did not stop within %s and was force-killed\n",
+				container.Names[0], timeout)
 		}
+
+		// Archive container
+		docker.ContainerRename(
+			ctx, container.ID, fmt.Sprintf("%s-%d", container.Names[0], time.Now().Unix()))
+		return nil
+	})
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		var messages = make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		return errors.New(strings.Join(messages, "; "))
 	}
-	return nil
+}
+
+// forEachConcurrently calls fn with each index up to n, running at most the
+// given number of calls at once, and returns the errors of calls that failed
+func forEachConcurrently(n, workers int, fn func(i int) error) []error {
+	var (
+		errs    = []error{}
+		mux     sync.Mutex
+		wg      sync.WaitGroup
+		indices = make(chan int)
+	)
+	if workers > n {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if err := fn(i); err != nil {
+					mux.Lock()
+					errs = append(errs, err)
+					mux.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return errs
+}
+
+// wasForceKilled checks if the given stopped container exited due to SIGKILL
+// without running out of memory
+func wasForceKilled(ctx context.Context, docker *docker.Client, id string) bool {
+	info, err := docker.ContainerInspect(ctx, id)
+	if err != nil || info.State == nil {
+		return false
+	}
+	return info.State.ExitCode == 137 && !info.State.OOMKilled
 }
 
 // Prune clears up unused Docker assets.
@@ -125,7 +372,8 @@ func Prune(docker *docker.Client) error {
 	return nil
 }
 
-// PruneAll forcibly removes all images except given exceptions (repo tag names)
+// PruneAll forcibly removes all images except given exceptions - images with
+// any repo tag containing an exception are kept
 func PruneAll(docker *docker.Client, exceptions ...string) error {
 	args := filters.NewArgs()
 	ctx := context.Background()
@@ -140,9 +388,11 @@ func PruneAll(docker *docker.Client, exceptions ...string) error {
 	}
 	for _, i := range list {
 		delete := true
-		for _, e := range exceptions {
-			if strings.Contains(i.RepoTags[0], e) {
-				delete = false
+		for _, t := range i.RepoTags {
+			for _, e := range exceptions {
+				if strings.Contains(t, e) {
+					delete = false
+				}
 			}
 		}
 		if delete {
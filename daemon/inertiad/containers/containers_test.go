@@ -1,9 +1,13 @@
 package containers
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -77,6 +81,39 @@ func TestGetActiveContainers(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestDockerRootDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	cli, err := NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	root, err := DockerRootDir(cli)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, root)
+}
+
+func TestIsInfrastructureContainer(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"/inertia-daemon", true},
+		{"inertia-daemon", true},
+		{"/buildx_buildkit_inertia0", true},
+		{"buildx_buildkit_inertia0", true},
+		{"/project_web_1", false},
+		{"/inertia-daemon-1505723123", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsInfrastructureContainer(tt.name))
+		})
+	}
+}
+
 func TestPrune(t *testing.T) {
 	cli, err := NewDockerClient()
 	assert.Nil(t, err)
@@ -107,3 +144,168 @@ func TestPruneAll(t *testing.T) {
 	}
 	assert.True(t, found)
 }
+
+func TestNextLogCursor(t *testing.T) {
+	frame := func(content string) []byte {
+		var header = []byte{1, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+		return append(header, []byte(content)...)
+	}
+	// A frame of 2565 bytes has a newline in its header
+	long := "2019-01-02T15:04:06.000000001Z " + strings.Repeat("x", 2533) + "\n"
+	tests := []struct {
+		name string
+		logs []byte
+		want string
+	}{
+		{"no logs", []byte{}, "fallback"},
+		{"no timestamps", []byte("hello\nworld\n"), "fallback"},
+		{"timestamped logs", []byte(
+			"2019-01-02T15:04:05.000000001Z hello\n2019-01-02T15:04:06.000000001Z world\n"),
+			"2019-01-02T15:04:06.000000002Z"},
+		{"multiplexed logs", frame("2019-01-02T15:04:05.000000001Z hello\n"),
+			"2019-01-02T15:04:05.000000002Z"},
+		{"newline in frame header", append(frame("2019-01-02T15:04:05.000000001Z hello\n"),
+			frame(long)...),
+			"2019-01-02T15:04:06.000000002Z"},
+		{"line split across frames", append(frame("2019-01-02T15:04:05.000000001Z hel"),
+			frame("lo\n")...),
+			"2019-01-02T15:04:05.000000002Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NextLogCursor(tt.logs, "fallback"))
+		})
+	}
+}
+
+func TestDemuxLogs(t *testing.T) {
+	frame := func(stream byte, content string) []byte {
+		return append([]byte{stream, 0, 0, 0, 0, 0, 0, byte(len(content))}, []byte(content)...)
+	}
+	tests := []struct {
+		name    string
+		logs    []byte
+		tty     bool
+		want    string
+		wantErr bool
+	}{
+		{"no logs", []byte{}, false, "", false},
+		{"labelled streams", append(frame(1, "hello\n"), frame(2, "uh oh\nbad\n")...), false,
+			"[stdout] hello\n[stderr] uh oh\n[stderr] bad\n", false},
+		{"unknown stream", frame(5, "hello\n"), false, "", true},
+		{"truncated frame", frame(1, "hello\n")[:10], false, "", true},
+		{"tty", []byte("hello\r\nuh oh\r\n"), true, "hello\r\nuh oh\r\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = &strings.Builder{}
+			err := DemuxLogs(bytes.NewReader(tt.logs), out, tt.tty)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestForEachConcurrently(t *testing.T) {
+	type args struct {
+		n       int
+		workers int
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantErrs int
+	}{
+		{"none", args{0, 10}, 0},
+		{"fewer items than workers", args{3, 10}, 1},
+		{"more items than workers", args{25, 4}, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mux     sync.Mutex
+				running int
+				maxRun  int
+				called  = make(map[int]bool)
+			)
+			errs := forEachConcurrently(tt.args.n, tt.args.workers, func(i int) error {
+				mux.Lock()
+				called[i] = true
+				running++
+				if running > maxRun {
+					maxRun = running
+				}
+				mux.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mux.Lock()
+				running--
+				mux.Unlock()
+				if i%3 == 0 {
+					return errors.New("failed")
+				}
+				return nil
+			})
+			assert.Len(t, called, tt.args.n)
+			assert.True(t, maxRun <= tt.args.workers)
+			assert.Len(t, errs, tt.wantErrs)
+		})
+	}
+}
+
+func TestParseLogSince(t *testing.T) {
+	var now = time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		since   string
+		want    string
+		wantErr bool
+	}{
+		{"timestamp", "2019-01-02T14:00:00Z", "2019-01-02T14:00:00Z", false},
+		{"timestamp with offset", "2019-01-02T06:00:00-08:00", "2019-01-02T14:00:00Z", false},
+		{"duration", "10m", "2019-01-02T14:54:05Z", false},
+		{"negative duration", "-10m", "", true},
+		{"invalid", "yesterday", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLogSince(tt.since, now)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContainerLogsOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LogOptions
+		want types.ContainerLogsOptions
+	}{
+		{"entries", LogOptions{Container: "web", Entries: 100},
+			types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true,
+				Tail: "100"}},
+		{"streamed entries", LogOptions{Container: "web", Entries: 100, Stream: true},
+			types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true,
+				Follow: true, Tail: "100"}},
+		{"all entries", LogOptions{Container: "web"},
+			types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true,
+				Tail: "all"}},
+		{"all streamed entries", LogOptions{Container: "web", Stream: true},
+			types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true,
+				Follow: true, Tail: "all"}},
+		{"entries since", LogOptions{Container: "web", Entries: 10, Since: "2019-01-02T15:04:05Z",
+			NoStderr: true, Stream: true},
+			types.ContainerLogsOptions{ShowStdout: true, Timestamps: true, Follow: true,
+				Since: "2019-01-02T15:04:05Z", Tail: "10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, containerLogsOptions(tt.opts))
+		})
+	}
+}
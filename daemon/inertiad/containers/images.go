@@ -0,0 +1,115 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+)
+
+// composeProjectChars matches characters docker-compose strips from project
+// names when naming images
+var composeProjectChars = regexp.MustCompile(`[^-_a-z0-9]`)
+
+// splitRepoTag separates an image reference into its repository and tag
+func splitRepoTag(repoTag string) (repo, tag string) {
+	// Registry hosts may include a port, so only a colon after the last
+	// slash separates the tag
+	var i = strings.LastIndex(repoTag, ":")
+	if i < 0 || i < strings.LastIndex(repoTag, "/") {
+		return repoTag, "latest"
+	}
+	return repoTag[:i], repoTag[i+1:]
+}
+
+// isProjectImage checks if the given image repository was built for the given
+// project, either from a Dockerfile or by docker-compose
+func isProjectImage(repo, project string) bool {
+	if repo == "inertia-build/"+project {
+		return true
+	}
+	var composeProject = composeProjectChars.ReplaceAllString(strings.ToLower(project), "")
+	return composeProject != "" && strings.HasPrefix(repo, composeProject+"_")
+}
+
+// projectImageTags lists the references of the given project's images
+func projectImageTags(cli *docker.Client, project string) ([]string, error) {
+	list, err := cli.ImageList(context.Background(), types.ImageListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var tags = []string{}
+	for _, i := range list {
+		for _, t := range i.RepoTags {
+			if repo, _ := splitRepoTag(t); isProjectImage(repo, project) {
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// TagProjectImages tags the most recently built images of the given project
+// with the given tag, so that they are kept when the project is rebuilt
+func TagProjectImages(cli *docker.Client, project, tag string) error {
+	tags, err := projectImageTags(cli, project)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if repo, current := splitRepoTag(t); current == "latest" {
+			if err := cli.ImageTag(context.Background(), t, repo+":"+tag); err != nil {
+				return fmt.Errorf("failed to tag image %s: %s", t, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveProjectImageTags removes all tags of the given project's images other
+// than 'latest' and the given tags to keep. Images left without tags are
+// removed by the next prune.
+func RemoveProjectImageTags(cli *docker.Client, project string, keep []string) error {
+	tags, err := projectImageTags(cli, project)
+	if err != nil {
+		return err
+	}
+	var kept = map[string]bool{"latest": true}
+	for _, k := range keep {
+		kept[k] = true
+	}
+	for _, t := range tags {
+		if _, tag := splitRepoTag(t); !kept[tag] {
+			if _, err := cli.ImageRemove(context.Background(), t, types.ImageRemoveOptions{}); err != nil {
+				return fmt.Errorf("failed to remove image tag %s: %s", t, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// RestoreProjectImages tags the given project's images with the given tag as
+// 'latest', so that they are used instead of the most recent build. Returns
+// an error if the project has no images with the given tag.
+func RestoreProjectImages(cli *docker.Client, project, tag string) error {
+	tags, err := projectImageTags(cli, project)
+	if err != nil {
+		return err
+	}
+	var restored = 0
+	for _, t := range tags {
+		if repo, current := splitRepoTag(t); current == tag {
+			if err := cli.ImageTag(context.Background(), t, repo+":latest"); err != nil {
+				return fmt.Errorf("failed to restore image %s: %s", t, err.Error())
+			}
+			restored++
+		}
+	}
+	if restored == 0 {
+		return fmt.Errorf("no images found for '%s'", tag)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package containers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitRepoTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoTag  string
+		wantRepo string
+		wantTag  string
+	}{
+		{"tagged", "inertia-build/project:abc123", "inertia-build/project", "abc123"},
+		{"untagged", "project_web", "project_web", "latest"},
+		{"registry port", "localhost:5000/project", "localhost:5000/project", "latest"},
+		{"registry port and tag", "localhost:5000/project:v1", "localhost:5000/project", "v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, tag := splitRepoTag(tt.repoTag)
+			assert.Equal(t, tt.wantRepo, repo)
+			assert.Equal(t, tt.wantTag, tag)
+		})
+	}
+}
+
+func TestIsProjectImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		repo    string
+		project string
+		want    bool
+	}{
+		{"dockerfile image", "inertia-build/my-project", "my-project", true},
+		{"compose image", "myproject_web", "My.Project", true},
+		{"other project", "inertia-build/other", "my-project", false},
+		{"other compose project", "other_web", "my-project", false},
+		{"base image", "node", "node", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isProjectImage(tt.repo, tt.project))
+		})
+	}
+}
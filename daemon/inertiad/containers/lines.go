@@ -0,0 +1,155 @@
+package containers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+// TruncatedLogLineMarker is appended to log lines cut short by TruncateLogLines
+const TruncatedLogLineMarker = "…(truncated)"
+
+// streamLabels are the prefixes DemuxLogs labels lines with
+var streamLabels = [][]byte{[]byte("[stdout] "), []byte("[stderr] ")}
+
+// ansiEscapes matches ANSI escape sequences, such as those that set colors -
+// control sequences, operating system commands, and two-character escapes
+var ansiEscapes = regexp.MustCompile(
+	`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-_]`)
+
+// StripANSI copies logs to out without ANSI escape sequences, such as colour
+// codes, which are only meaningful to terminals. Logs may be multiplexed by
+// Docker, or separated into labelled lines by DemuxLogs.
+func StripANSI(logs io.Reader, out io.Writer) error {
+	return transformLogLines(logs, out, func(line []byte) []byte {
+		return ansiEscapes.ReplaceAll(line, nil)
+	})
+}
+
+// TruncateLogLines copies logs to out, cutting lines longer than max bytes
+// short and marking them with TruncatedLogLineMarker. Logs may be multiplexed
+// by Docker, or separated into labelled lines by DemuxLogs. Lines are not
+// truncated if max is 0.
+func TruncateLogLines(logs io.Reader, out io.Writer, max int) error {
+	if max <= 0 {
+		_, err := io.Copy(out, logs)
+		return err
+	}
+	return transformLogLines(logs, out, func(line []byte) []byte {
+		return truncateLine(line, max)
+	})
+}
+
+// FilterLogsSince copies the lines of logs with timestamps at or after since
+// to out, for logs that were saved rather than fetched from Docker. Lines
+// without timestamps are kept along with the line before them. Logs may be
+// multiplexed by Docker, or separated into labelled lines by DemuxLogs.
+func FilterLogsSince(logs io.Reader, out io.Writer, since time.Time) error {
+	var keep = true
+	return transformLogLines(logs, out, func(line []byte) []byte {
+		if _, t, _, ok := parseLineTimestamp(line); ok {
+			keep = !t.Before(since)
+		}
+		if !keep {
+			return nil
+		}
+		return line
+	})
+}
+
+// truncateLine cuts the given line down to at most max bytes, excluding its
+// line ending, without splitting a UTF-8 encoded character
+func truncateLine(line []byte, max int) []byte {
+	var content = bytes.TrimSuffix(line, []byte("\n"))
+	if len(content) <= max {
+		return line
+	}
+	var end = max
+	for end > 0 && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	var truncated = append([]byte{}, content[:end]...)
+	truncated = append(truncated, TruncatedLogLineMarker...)
+	if len(content) < len(line) {
+		truncated = append(truncated, '\n')
+	}
+	return truncated
+}
+
+// transformLogLines copies logs to out, applying transform to each line. Logs
+// may be multiplexed by Docker, in which case frame headers are adjusted to
+// the transformed sizes of their frames, or separated into labelled lines by
+// DemuxLogs.
+func transformLogLines(logs io.Reader, out io.Writer, transform func([]byte) []byte) error {
+	var reader = bufio.NewReader(logs)
+	header, err := reader.Peek(8)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	if isStreamHeader(header) {
+		return transformFrames(reader, out, transform)
+	}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := out.Write(transform(line)); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// transformFrames applies transform to the lines in multiplexed logs,
+// adjusting each frame's header to its new size
+func transformFrames(logs io.Reader, out io.Writer, transform func([]byte) []byte) error {
+	var header = make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(logs, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var frame = make([]byte, binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(logs, frame); err != nil {
+			return err
+		}
+
+		var transformed = new(bytes.Buffer)
+		for _, line := range bytes.SplitAfter(frame, []byte("\n")) {
+			transformed.Write(transform(line))
+		}
+		if transformed.Len() == 0 {
+			continue
+		}
+		binary.BigEndian.PutUint32(header[4:], uint32(transformed.Len()))
+		if _, err := out.Write(header); err != nil {
+			return err
+		}
+		if _, err := out.Write(transformed.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// IsMultiplexed checks if the given logs are multiplexed by Docker, for logs
+// whose container can no longer be inspected. Logs of containers that use a TTY
+// are not multiplexed.
+func IsMultiplexed(logs []byte) bool {
+	return len(logs) >= 8 && isStreamHeader(logs[:8])
+}
+
+// isStreamHeader checks if the given bytes are the header of a frame of
+// multiplexed logs
+func isStreamHeader(b []byte) bool {
+	return len(b) == 8 && b[0] <= 2 && b[1] == 0 && b[2] == 0 && b[3] == 0
+}
@@ -0,0 +1,165 @@
+package containers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateLogLines(t *testing.T) {
+	tests := []struct {
+		name string
+		logs string
+		max  int
+		want string
+	}{
+		{"no limit",
+			"hello world\n", 0,
+			"hello world\n"},
+		{"short lines",
+			"hello\nworld\n", 5,
+			"hello\nworld\n"},
+		{"long line",
+			"hello world\nhi\n", 5,
+			"hello" + TruncatedLogLineMarker + "\nhi\n"},
+		{"long line without newline",
+			"hello world", 5,
+			"hello" + TruncatedLogLineMarker},
+		{"multi-byte characters",
+			"héllo\n", 2,
+			"h" + TruncatedLogLineMarker + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = new(bytes.Buffer)
+			assert.Nil(t, TruncateLogLines(bytes.NewBufferString(tt.logs), out, tt.max))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{"no escapes",
+			"hello world\n",
+			"hello world\n"},
+		{"colors",
+			"\x1b[32mok\x1b[0m done\n\x1b[1;31merror\x1b[m\n",
+			"ok done\nerror\n"},
+		{"cursor movement",
+			"50%\x1b[2K\x1b[1G100%\n",
+			"50%100%\n"},
+		{"window title",
+			"\x1b]0;building\x07step 1\n",
+			"step 1\n"},
+		{"labelled lines",
+			"[stderr] \x1b[33mwarning\x1b[0m\n",
+			"[stderr] warning\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = new(bytes.Buffer)
+			assert.Nil(t, StripANSI(bytes.NewBufferString(tt.logs), out))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestStripANSIMultiplexed(t *testing.T) {
+	var frame = func(stream byte, content string) []byte {
+		var header = []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+		return append(header, content...)
+	}
+	var logs = append(
+		frame(1, "\x1b[32mok\x1b[0m\n"),
+		frame(2, "oops\n")...)
+
+	var out = new(bytes.Buffer)
+	assert.Nil(t, StripANSI(bytes.NewReader(logs), out))
+
+	// Frame sizes should match the stripped lines
+	var demuxed = new(bytes.Buffer)
+	assert.Nil(t, DemuxLogs(out, demuxed, false))
+	assert.Equal(t, "[stdout] ok\n[stderr] oops\n", demuxed.String())
+}
+
+func TestTruncateLogLinesMultiplexed(t *testing.T) {
+	var frame = func(stream byte, content string) []byte {
+		var header = []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+		return append(header, content...)
+	}
+	var logs = append(
+		frame(1, "hello world\n"),
+		frame(2, "oops\n")...)
+
+	var out = new(bytes.Buffer)
+	assert.Nil(t, TruncateLogLines(bytes.NewReader(logs), out, 5))
+
+	// Frame sizes should match the truncated lines
+	var demuxed = new(bytes.Buffer)
+	assert.Nil(t, DemuxLogs(out, demuxed, false))
+	assert.Equal(t,
+		"[stdout] hello"+TruncatedLogLineMarker+"\n[stderr] oops\n",
+		demuxed.String())
+}
+
+func TestFilterLogsSince(t *testing.T) {
+	var since, _ = time.Parse(time.RFC3339Nano, "2019-01-01T00:00:10Z")
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{"all later",
+			"2019-01-01T00:00:10Z a\n2019-01-01T00:00:11Z b\n",
+			"2019-01-01T00:00:10Z a\n2019-01-01T00:00:11Z b\n"},
+		{"some earlier",
+			"2019-01-01T00:00:09Z a\n2019-01-01T00:00:11Z b\n",
+			"2019-01-01T00:00:11Z b\n"},
+		{"continued lines",
+			"2019-01-01T00:00:09Z a\ncontinued\n2019-01-01T00:00:11Z b\ncontinued\n",
+			"2019-01-01T00:00:11Z b\ncontinued\n"},
+		{"labelled lines",
+			"[stdout] 2019-01-01T00:00:09Z a\n[stderr] 2019-01-01T00:00:11Z b\n",
+			"[stderr] 2019-01-01T00:00:11Z b\n"},
+		{"no timestamps",
+			"a\nb\n",
+			"a\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = new(bytes.Buffer)
+			assert.Nil(t, FilterLogsSince(bytes.NewBufferString(tt.logs), out, since))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestFilterLogsSinceMultiplexed(t *testing.T) {
+	var frame = func(stream byte, content string) []byte {
+		var header = []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+		return append(header, content...)
+	}
+	var logs = append(
+		frame(1, "2019-01-01T00:00:09Z a\n"),
+		frame(2, "2019-01-01T00:00:11Z b\n")...)
+
+	var since, _ = time.Parse(time.RFC3339Nano, "2019-01-01T00:00:10Z")
+	var out = new(bytes.Buffer)
+	assert.Nil(t, FilterLogsSince(bytes.NewReader(logs), out, since))
+
+	// Frames left empty should be dropped
+	var demuxed = new(bytes.Buffer)
+	assert.Nil(t, DemuxLogs(out, demuxed, false))
+	assert.Equal(t, "[stderr] 2019-01-01T00:00:11Z b\n", demuxed.String())
+}
@@ -0,0 +1,151 @@
+package containers
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// NamedLogs are the logs of the container with the given name
+type NamedLogs struct {
+	Name string
+	Logs io.Reader
+}
+
+// prefix returns the label lines from the container are prefixed with
+func (n NamedLogs) prefix() []byte {
+	return []byte("[" + n.Name + "] ")
+}
+
+// StripStreamHeaders copies logs to out without the headers of frames of logs
+// multiplexed by Docker, so that lines of logs from different containers can
+// be interleaved. Logs that are not multiplexed are copied as is.
+func StripStreamHeaders(logs io.Reader, out io.Writer) error {
+	var reader = bufio.NewReader(logs)
+	header, err := reader.Peek(8)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	if !isStreamHeader(header) {
+		_, err := io.Copy(out, reader)
+		return err
+	}
+	header = make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if _, err := io.CopyN(out, reader, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+}
+
+// mergedLine is a line of logs being merged with the logs of other containers
+type mergedLine struct {
+	text []byte
+	time time.Time
+}
+
+// MergeLogs writes the lines of each of the given logs to out in order of
+// their timestamps, prefixed with the name of the container they came from.
+// Logs must not be multiplexed. Lines without a timestamp stay after the line
+// before them.
+func MergeLogs(logs []NamedLogs, out io.Writer) error {
+	var lines = make([][]mergedLine, len(logs))
+	for i, l := range logs {
+		var reader = bufio.NewReader(l.Logs)
+		var last time.Time
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				if _, t, _, ok := parseLineTimestamp(line); ok {
+					last = t
+				}
+				lines[i] = append(lines[i], mergedLine{
+					text: append(l.prefix(), line...),
+					time: last,
+				})
+			}
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Repeatedly write the earliest of the next lines of each container - the
+	// lines of each container are already in order
+	for {
+		var next = -1
+		for i := range lines {
+			if len(lines[i]) > 0 && (next < 0 || lines[i][0].time.Before(lines[next][0].time)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+		if _, err := out.Write(lines[next][0].text); err != nil {
+			return err
+		}
+		lines[next] = lines[next][1:]
+	}
+}
+
+// FanInLogs returns a reader of the lines of each of the given logs as they
+// are written, prefixed with the name of the container they came from, for
+// following the logs of several containers at once. Logs must not be
+// multiplexed. The reader ends once all logs end, or with the first error
+// reading any of them. It is closed once ctx is done, so that lines are no
+// longer read from logs once the reader is no longer used.
+func FanInLogs(ctx context.Context, logs []NamedLogs) io.Reader {
+	var (
+		pr, pw = io.Pipe()
+		wg     sync.WaitGroup
+		mux    sync.Mutex
+	)
+	go func() {
+		<-ctx.Done()
+		pr.Close()
+	}()
+	for _, l := range logs {
+		wg.Add(1)
+		go func(l NamedLogs) {
+			defer wg.Done()
+			var reader = bufio.NewReader(l.Logs)
+			for {
+				line, err := reader.ReadBytes('\n')
+				if len(line) > 0 {
+					mux.Lock()
+					_, werr := pw.Write(append(l.prefix(), line...))
+					mux.Unlock()
+					if werr != nil {
+						return
+					}
+				}
+				if err == io.EOF {
+					return
+				} else if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+		}(l)
+	}
+	go func() {
+		wg.Wait()
+		pw.Close()
+	}()
+	return pr
+}
@@ -0,0 +1,125 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripStreamHeaders(t *testing.T) {
+	frame := func(stream byte, content string) []byte {
+		return append([]byte{stream, 0, 0, 0, 0, 0, 0, byte(len(content))}, []byte(content)...)
+	}
+	tests := []struct {
+		name    string
+		logs    []byte
+		want    string
+		wantErr bool
+	}{
+		{"no logs", []byte{}, "", false},
+		{"plain lines", []byte("hello\nworld\n"), "hello\nworld\n", false},
+		{"multiplexed lines", append(frame(1, "hello\n"), frame(2, "uh oh\n")...),
+			"hello\nuh oh\n", false},
+		{"truncated frame", frame(1, "hello\n")[:10], "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = &strings.Builder{}
+			err := StripStreamHeaders(bytes.NewReader(tt.logs), out)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestMergeLogs(t *testing.T) {
+	var out = &strings.Builder{}
+	err := MergeLogs([]NamedLogs{
+		{Name: "web", Logs: strings.NewReader(
+			"2019-01-02T15:04:05Z starting\n" +
+				"2019-01-02T15:04:07Z request\n" +
+				"  continued\n")},
+		{Name: "db", Logs: strings.NewReader(
+			"[stderr] 2019-01-02T07:04:06-08:00 ready\n" +
+				"2019-01-02T15:04:08Z query")},
+		{Name: "empty", Logs: strings.NewReader("")},
+	}, out)
+	assert.Nil(t, err)
+	assert.Equal(t, "[web] 2019-01-02T15:04:05Z starting\n"+
+		"[db] [stderr] 2019-01-02T07:04:06-08:00 ready\n"+
+		"[web] 2019-01-02T15:04:07Z request\n"+
+		"[web]   continued\n"+
+		"[db] 2019-01-02T15:04:08Z query", out.String())
+
+	err = MergeLogs([]NamedLogs{
+		{Name: "web", Logs: io.MultiReader(strings.NewReader("hello\n"),
+			&errReader{errors.New("oops")})},
+	}, ioutil.Discard)
+	assert.NotNil(t, err)
+}
+
+func TestFanInLogs(t *testing.T) {
+	out, err := ioutil.ReadAll(FanInLogs(context.Background(), []NamedLogs{
+		{Name: "web", Logs: strings.NewReader("hello\nworld\n")},
+		{Name: "db", Logs: strings.NewReader("ready\n")},
+	}))
+	assert.Nil(t, err)
+
+	// Lines of different containers may be interleaved in any order
+	var lines = strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	assert.Equal(t, []string{"[web] hello", "[web] world"},
+		filterPrefix(lines, "[web] "))
+	sort.Strings(lines)
+	assert.Equal(t, []string{"[db] ready", "[web] hello", "[web] world"}, lines)
+
+	_, err = ioutil.ReadAll(FanInLogs(context.Background(), []NamedLogs{
+		{Name: "web", Logs: strings.NewReader("hello\n")},
+		{Name: "db", Logs: &errReader{errors.New("oops")}},
+	}))
+	assert.NotNil(t, err)
+}
+
+func TestFanInLogsDone(t *testing.T) {
+	var before = runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	var reader = FanInLogs(ctx, []NamedLogs{
+		{Name: "web", Logs: strings.NewReader("hello\nworld\n")},
+		{Name: "db", Logs: strings.NewReader("ready\n")},
+	})
+
+	// Nothing reads the logs, so writing them blocks until the context is done
+	cancel()
+	for start := time.Now(); runtime.NumGoroutine() > before; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("logs are still being read after the context is done")
+		}
+	}
+	_, err := reader.Read(make([]byte, 1))
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+// errReader is a reader that always fails with err
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func filterPrefix(lines []string, prefix string) []string {
+	var filtered []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			filtered = append(filtered, line)
+		}
+	}
+	return filtered
+}
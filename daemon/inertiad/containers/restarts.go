@@ -0,0 +1,160 @@
+package containers
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+)
+
+const (
+	// restartPollInterval is how often a container that has exited is checked
+	// for a restart while following its logs
+	restartPollInterval = 500 * time.Millisecond
+
+	// restartWaitTimeout is how long to wait for an exited container to be
+	// restarted before its logs stop being followed
+	restartWaitTimeout = 5 * time.Minute
+)
+
+// FollowContainerLogs streams logs of a container like ContainerLogs, but
+// re-attaches to the container if it is restarted, for example by its restart
+// policy after crashing. Restarts are noted in the stream. The stream ends
+// when the container exits and is not restarted.
+func FollowContainerLogs(docker *docker.Client, opts LogOptions) (io.ReadCloser, error) {
+	info, err := docker.ContainerInspect(context.Background(), opts.Container)
+	if err != nil {
+		return nil, err
+	}
+	opts.Stream = true
+	logs, err := ContainerLogs(docker, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Notes are written to stderr unless only stdout is requested
+	var noteStream byte = 2
+	if opts.NoStderr {
+		noteStream = 1
+	}
+	var tty = info.Config != nil && info.Config.Tty
+
+	pr, pw := io.Pipe()
+	var follower = &logFollower{PipeReader: pr, logs: logs}
+	go func() {
+		var restarts = info.RestartCount
+		for {
+			_, err := io.Copy(pw, logs)
+			logs.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			// Wait for the container to come back up, if it will
+			state, err := docker.ContainerInspect(context.Background(), info.ID)
+			if err != nil || state.State == nil || !state.State.Restarting {
+				pw.Close()
+				return
+			}
+			if _, err := pw.Write(logNote(fmt.Sprintf(
+				"container exited with code %d, waiting for it to restart",
+				state.State.ExitCode), tty, noteStream)); err != nil {
+				return
+			}
+			restarted, ok := waitForRestart(docker, info.ID, restarts)
+			if !ok {
+				pw.Close()
+				return
+			}
+			restarts = restarted.RestartCount
+			if _, err := pw.Write(logNote(fmt.Sprintf(
+				"container restarted (restart %d), following logs of new instance",
+				restarts), tty, noteStream)); err != nil {
+				return
+			}
+
+			// Only retrieve logs written since the restart
+			opts.Since = restarted.State.StartedAt
+			opts.Entries = 0
+			if logs, err = ContainerLogs(docker, opts); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if !follower.setLogs(logs) {
+				logs.Close()
+				return
+			}
+		}
+	}()
+	return follower, nil
+}
+
+// logFollower is the reader returned by FollowContainerLogs. Closing it also
+// closes the logs currently being followed.
+type logFollower struct {
+	*io.PipeReader
+
+	mux    sync.Mutex
+	logs   io.Closer
+	closed bool
+}
+
+// setLogs sets the logs currently being followed, returning false if the
+// follower has already been closed
+func (f *logFollower) setLogs(logs io.Closer) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.logs = logs
+	return !f.closed
+}
+
+// Close stops following logs
+func (f *logFollower) Close() error {
+	f.mux.Lock()
+	f.closed = true
+	f.logs.Close()
+	f.mux.Unlock()
+	return f.PipeReader.Close()
+}
+
+// waitForRestart waits for the given container to be running again after
+// more than the given number of restarts, returning false if it stops
+// restarting or is not restarted in time
+func waitForRestart(docker *docker.Client, id string,
+	restarts int) (types.ContainerJSON, bool) {
+	var deadline = time.Now().Add(restartWaitTimeout)
+	for time.Now().Before(deadline) {
+		info, err := docker.ContainerInspect(context.Background(), id)
+		if err != nil || info.State == nil {
+			return info, false
+		}
+		if info.State.Running && info.RestartCount > restarts {
+			return info, true
+		}
+		if !info.State.Running && !info.State.Restarting {
+			return info, false
+		}
+		time.Sleep(restartPollInterval)
+	}
+	return types.ContainerJSON{}, false
+}
+
+// logNote formats a timestamped note from Inertia to include in a container's
+// logs. Unless the container has a TTY, its logs are multiplexed, so the note
+// is framed to appear on the given stream (1 for stdout, 2 for stderr).
+func logNote(note string, tty bool, stream byte) []byte {
+	var line = []byte(fmt.Sprintf("%s [inertia] %s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), note))
+	if tty {
+		return line
+	}
+	var header = []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+	return append(header, line...)
+}
@@ -0,0 +1,41 @@
+package containers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogNote(t *testing.T) {
+	tests := []struct {
+		name   string
+		tty    bool
+		stream byte
+		label  string
+	}{
+		{"stderr", false, 2, "[stderr] "},
+		{"stdout", false, 1, "[stdout] "},
+		{"tty", true, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var note = logNote("container restarted", tt.tty, tt.stream)
+			var line = string(note)
+			if !tt.tty {
+				var demuxed = new(bytes.Buffer)
+				assert.Nil(t, DemuxLogs(bytes.NewReader(note), demuxed, false))
+				line = demuxed.String()
+			}
+			assert.True(t, strings.HasPrefix(line, tt.label))
+			assert.True(t, strings.HasSuffix(line, " [inertia] container restarted\n"))
+
+			// Notes should be timestamped like other log lines
+			var timestamp = strings.SplitN(strings.TrimPrefix(line, tt.label), " ", 2)[0]
+			_, err := time.Parse(time.RFC3339Nano, timestamp)
+			assert.Nil(t, err)
+		})
+	}
+}
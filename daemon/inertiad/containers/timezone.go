@@ -0,0 +1,50 @@
+package containers
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// ConvertLogTimezone copies logs to out, converting the timestamp at the start
+// of each line to the given location. Logs may be multiplexed by Docker, or
+// separated into labelled lines by DemuxLogs. Lines without a timestamp are
+// copied as is.
+func ConvertLogTimezone(logs io.Reader, out io.Writer, loc *time.Location) error {
+	return transformLogLines(logs, out, func(line []byte) []byte {
+		return convertLine(line, loc)
+	})
+}
+
+// convertLine converts the timestamp at the start of the given line, after
+// its stream label if it has one
+func convertLine(line []byte, loc *time.Location) []byte {
+	label, t, rest, ok := parseLineTimestamp(line)
+	if !ok {
+		return line
+	}
+	var converted = append([]byte{}, label...)
+	converted = append(converted, t.In(loc).Format(time.RFC3339Nano)...)
+	return append(converted, rest...)
+}
+
+// parseLineTimestamp parses the timestamp at the start of the given line, after
+// its stream label if it has one, and returns the label and the rest of the
+// line following the timestamp
+func parseLineTimestamp(line []byte) (label []byte, t time.Time, rest []byte, ok bool) {
+	for _, l := range streamLabels {
+		if bytes.HasPrefix(line, l) {
+			label = l
+		}
+	}
+	rest = line[len(label):]
+	var i = bytes.IndexByte(rest, ' ')
+	if i < 0 {
+		return nil, time.Time{}, nil, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(rest[:i]))
+	if err != nil {
+		return nil, time.Time{}, nil, false
+	}
+	return label, t, rest[i:], true
+}
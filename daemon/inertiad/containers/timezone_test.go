@@ -0,0 +1,57 @@
+package containers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertLogTimezone(t *testing.T) {
+	loc := time.FixedZone("PST", -8*60*60)
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{"plain lines",
+			"2019-01-02T15:04:05.123Z hello\n2019-01-02T16:00:00Z world\n",
+			"2019-01-02T07:04:05.123-08:00 hello\n2019-01-02T08:00:00-08:00 world\n"},
+		{"labelled lines",
+			"[stdout] 2019-01-02T15:04:05Z hello\n[stderr] 2019-01-02T15:04:06Z oops\n",
+			"[stdout] 2019-01-02T07:04:05-08:00 hello\n[stderr] 2019-01-02T07:04:06-08:00 oops\n"},
+		{"lines without timestamps",
+			"hello world\nno newline",
+			"hello world\nno newline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = new(bytes.Buffer)
+			assert.Nil(t, ConvertLogTimezone(bytes.NewBufferString(tt.logs), out, loc))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestConvertLogTimezoneMultiplexed(t *testing.T) {
+	var frame = func(stream byte, content string) []byte {
+		var header = []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+		return append(header, content...)
+	}
+	var logs = append(
+		frame(1, "2019-01-02T15:04:05Z hello\n"),
+		frame(2, "2019-01-02T15:04:06Z oops\n")...)
+
+	var out = new(bytes.Buffer)
+	assert.Nil(t, ConvertLogTimezone(bytes.NewReader(logs), out, time.FixedZone("JST", 9*60*60)))
+
+	// Frame sizes should match the converted lines
+	var demuxed = new(bytes.Buffer)
+	assert.Nil(t, DemuxLogs(out, demuxed, false))
+	assert.Equal(t,
+		"[stdout] 2019-01-03T00:04:05+09:00 hello\n[stderr] 2019-01-03T00:04:06+09:00 oops\n",
+		demuxed.String())
+}
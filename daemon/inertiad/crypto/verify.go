@@ -28,6 +28,43 @@ func ValidateSignature(signature string, payload, secretKey []byte) error {
 	return nil
 }
 
+// SignatureWriter computes the HMAC of a payload as it is written, so that a
+// payload's signature can be validated without holding it in memory
+type SignatureWriter struct {
+	mac        hash.Hash
+	messageMAC []byte
+	err        error
+}
+
+// NewSignatureWriter creates a writer that validates the payload written to it
+// against the given HMAC signature
+func NewSignatureWriter(signature string, secretKey []byte) *SignatureWriter {
+	messageMAC, hashFunc, err := messageMAC(signature)
+	if err != nil {
+		return &SignatureWriter{err: err}
+	}
+	return &SignatureWriter{mac: hmac.New(hashFunc, secretKey), messageMAC: messageMAC}
+}
+
+// Write adds p to the payload
+func (w *SignatureWriter) Write(p []byte) (int, error) {
+	if w.mac == nil {
+		return len(p), nil
+	}
+	return w.mac.Write(p)
+}
+
+// Validate checks the signature of everything written so far
+func (w *SignatureWriter) Validate() error {
+	if w.err != nil {
+		return w.err
+	}
+	if !hmac.Equal(w.messageMAC, w.mac.Sum(nil)) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
 // checkMAC reports whether messageMAC is a valid HMAC tag for message.
 func checkMAC(message, messageMAC, key []byte, hashFunc func() hash.Hash) bool {
 	mac := hmac.New(hashFunc, key)
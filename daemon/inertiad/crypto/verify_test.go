@@ -31,3 +31,32 @@ func TestValidateSignature(t *testing.T) {
 		})
 	}
 }
+
+func TestSignatureWriter(t *testing.T) {
+	type args struct {
+		signature string
+		secretKey []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"ok", args{testSignature, testKey}, false},
+		{"missing sig", args{"", testKey}, true},
+		{"unknown hash", args{"md5=126f2c80", testKey}, true},
+		{"incorrect sig", args{testSignature, []byte("ohno")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w = NewSignatureWriter(tt.args.signature, tt.args.secretKey)
+			// Write the payload in pieces, as a streamed payload would be
+			for _, b := range testPayload {
+				w.Write([]byte{b})
+			}
+			if err := w.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
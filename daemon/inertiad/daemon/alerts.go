@@ -0,0 +1,272 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+const (
+	// alertsPollInterval is how often resource usage is sampled - sustained
+	// CPU usage is measured in samples, so this should stay at a minute
+	alertsPollInterval = time.Minute
+
+	// defaultAlertCPUMinutes is how long CPU usage must stay above its
+	// threshold before an alert is sent if it is not configured
+	defaultAlertCPUMinutes = 5
+
+	// resource names used in alerts
+	alertResourceMemory = "memory"
+	alertResourceCPU    = "cpu"
+	alertResourceDisk   = "disk"
+)
+
+// alertMonitor tracks which thresholds have been crossed, so that alerts are
+// only sent when usage crosses a threshold and once it recovers, rather than
+// on every sample
+type alertMonitor struct {
+	firing map[string]bool
+
+	// over counts consecutive samples above a threshold
+	over map[string]int
+
+	// seen tracks which checks were made in the current round of samples
+	seen map[string]bool
+}
+
+func newAlertMonitor() *alertMonitor {
+	return &alertMonitor{
+		firing: make(map[string]bool),
+		over:   make(map[string]int),
+		seen:   make(map[string]bool),
+	}
+}
+
+// check compares a sample of a resource's usage against its threshold, and
+// returns an alert if usage has stayed above the threshold for the given
+// number of consecutive samples, or if usage has recovered after an alert.
+// Returns nil otherwise, or if the threshold is not set.
+func (m *alertMonitor) check(resource, container string, value, threshold float64,
+	samples int) *api.Alert {
+	if threshold <= 0 {
+		return nil
+	}
+	var key = resource + "/" + container
+	m.seen[key] = true
+
+	if value < threshold {
+		m.over[key] = 0
+		if !m.firing[key] {
+			return nil
+		}
+		delete(m.firing, key)
+		return newAlert(resource, container, value, threshold, true)
+	}
+
+	m.over[key]++
+	if m.firing[key] || m.over[key] < samples {
+		return nil
+	}
+	m.firing[key] = true
+	return newAlert(resource, container, value, threshold, false)
+}
+
+// sweep forgets checks that were not made since the last sweep, such as those
+// of containers that have since been removed
+func (m *alertMonitor) sweep() {
+	for key := range m.over {
+		if !m.seen[key] {
+			delete(m.over, key)
+			delete(m.firing, key)
+		}
+	}
+	m.seen = make(map[string]bool)
+}
+
+// newAlert creates an alert with a description of the given usage
+func newAlert(resource, container string, value, threshold float64, resolved bool) *api.Alert {
+	var subject = "Disk usage"
+	if container != "" {
+		var name = "Memory"
+		if resource == alertResourceCPU {
+			name = "CPU"
+		}
+		subject = fmt.Sprintf("%s usage of container %s", name, container)
+	}
+	var text = fmt.Sprintf("[inertia] %s is %.1f%%, above the alert threshold of %.1f%%",
+		subject, value, threshold)
+	if resolved {
+		text = fmt.Sprintf("[inertia] %s is back to %.1f%%, below the alert threshold of %.1f%%",
+			subject, value, threshold)
+	}
+	return &api.Alert{
+		Text:      text,
+		Resource:  resource,
+		Container: container,
+		Value:     value,
+		Threshold: threshold,
+		Resolved:  resolved,
+		Time:      time.Now(),
+	}
+}
+
+// memoryPercent calculates a container's memory usage as a percentage of its
+// limit, excluding the page cache like 'docker stats' does
+func memoryPercent(stats types.StatsJSON) float64 {
+	if stats.MemoryStats.Limit == 0 {
+		return 0
+	}
+	var usage = float64(stats.MemoryStats.Usage) - float64(stats.MemoryStats.Stats["cache"])
+	if usage < 0 {
+		usage = 0
+	}
+	return usage / float64(stats.MemoryStats.Limit) * 100
+}
+
+// cpuPercent calculates a container's CPU usage since its previous sample as
+// a percentage of the host's total CPU capacity
+func cpuPercent(stats types.StatsJSON) float64 {
+	var cpuDelta = float64(stats.CPUStats.CPUUsage.TotalUsage) -
+		float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	var systemDelta = float64(stats.CPUStats.SystemUsage) -
+		float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * 100
+}
+
+// diskPercent calculates the usage of the filesystem containing the given
+// path as a percentage of the space available to users, like 'df' does
+func diskPercent(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	var used = float64(stat.Blocks - stat.Bfree)
+	var total = used + float64(stat.Bavail)
+	if total == 0 {
+		return 0, nil
+	}
+	return used / total * 100, nil
+}
+
+// dockerDiskPercent calculates the usage of the filesystem that Docker stores
+// images and containers on, which is what fills up as deployments accumulate
+func (s *Server) dockerDiskPercent() (float64, error) {
+	root, err := containers.DockerRootDir(s.docker)
+	if err != nil {
+		return 0, err
+	}
+	return diskPercent(root)
+}
+
+// watchResourceUsage samples the resource usage of project containers and
+// the host's disk, and posts alerts to the configured webhook when usage
+// crosses a threshold. Nothing is sampled while alerts are not configured.
+// Best used as a goroutine.
+func (s *Server) watchResourceUsage(poll time.Duration) {
+	var monitor = newAlertMonitor()
+	var client = &http.Client{Timeout: 10 * time.Second}
+	for {
+		time.Sleep(poll)
+
+		// Alerts are read on each run, since deployments can change them
+		var conf = s.config().Alerts
+		if conf == nil || conf.WebhookURL == "" {
+			monitor = newAlertMonitor()
+			continue
+		}
+		for _, alert := range s.sampleResourceUsage(monitor, conf) {
+			if err := postAlert(client, conf.WebhookURL, alert); err != nil {
+				println("Failed to send alert: " + err.Error())
+			}
+		}
+	}
+}
+
+// sampleResourceUsage checks current resource usage against the configured
+// thresholds, and returns alerts for thresholds that were crossed
+func (s *Server) sampleResourceUsage(monitor *alertMonitor, conf *api.Alerts) []*api.Alert {
+	var alerts = []*api.Alert{}
+	defer monitor.sweep()
+
+	if conf.DiskPercent > 0 {
+		if usage, err := s.dockerDiskPercent(); err != nil {
+			println("Unable to check disk usage: " + err.Error())
+		} else if alert := monitor.check(alertResourceDisk, "", usage,
+			conf.DiskPercent, 1); alert != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	if conf.MemoryPercent <= 0 && conf.CPUPercent <= 0 {
+		return alerts
+	}
+	var cpuSamples = conf.CPUMinutes
+	if cpuSamples <= 0 {
+		cpuSamples = defaultAlertCPUMinutes
+	}
+	var ctx = context.Background()
+	containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.KeyValuePair{Key: "label", Value: build.LabelProject}),
+	})
+	if err != nil {
+		println("Unable to list containers for alerts: " + err.Error())
+		return alerts
+	}
+	for _, c := range containers {
+		var name = c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		resp, err := s.docker.ContainerStats(ctx, c.ID, false)
+		if err != nil {
+			continue
+		}
+		var stats types.StatsJSON
+		err = json.NewDecoder(resp.Body).Decode(&stats)
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, alert := range []*api.Alert{
+			monitor.check(alertResourceMemory, name, memoryPercent(stats), conf.MemoryPercent, 1),
+			monitor.check(alertResourceCPU, name, cpuPercent(stats), conf.CPUPercent, cpuSamples),
+		} {
+			if alert != nil {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts
+}
+
+// postAlert sends an alert to the given webhook as JSON
+func postAlert(client *http.Client, url string, alert *api.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
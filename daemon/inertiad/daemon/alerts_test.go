@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestAlertMonitor_check(t *testing.T) {
+	type sample struct {
+		value     float64
+		wantAlert bool
+		resolved  bool
+	}
+	tests := []struct {
+		name      string
+		threshold float64
+		samples   int
+		values    []sample
+	}{
+		{"no threshold", 0, 1, []sample{{100, false, false}}},
+		{"below threshold", 80, 1, []sample{{50, false, false}, {79, false, false}}},
+		{"crossed once", 80, 1, []sample{
+			{50, false, false}, {90, true, false}, {95, false, false},
+		}},
+		{"resolved", 80, 1, []sample{
+			{90, true, false}, {50, false, true}, {40, false, false},
+		}},
+		{"sustained", 80, 3, []sample{
+			{90, false, false}, {90, false, false}, {90, true, false}, {90, false, false},
+		}},
+		{"not sustained", 80, 3, []sample{
+			{90, false, false}, {90, false, false}, {50, false, false}, {90, false, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m = newAlertMonitor()
+			for i, s := range tt.values {
+				alert := m.check(alertResourceCPU, "web", s.value, tt.threshold, tt.samples)
+				m.sweep()
+				if !s.wantAlert && !s.resolved {
+					assert.Nil(t, alert, "sample %d", i)
+					continue
+				}
+				if assert.NotNil(t, alert, "sample %d", i) {
+					assert.Equal(t, s.resolved, alert.Resolved)
+					assert.Equal(t, "web", alert.Container)
+					assert.Equal(t, s.value, alert.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestAlertMonitor_sweep(t *testing.T) {
+	var m = newAlertMonitor()
+	assert.NotNil(t, m.check(alertResourceMemory, "web", 90, 80, 1))
+	m.sweep()
+
+	// Container went away, so its alert is forgotten
+	m.sweep()
+	assert.NotNil(t, m.check(alertResourceMemory, "web", 90, 80, 1))
+}
+
+func TestMemoryPercent(t *testing.T) {
+	var stats types.StatsJSON
+	assert.Equal(t, float64(0), memoryPercent(stats))
+
+	stats.MemoryStats = types.MemoryStats{
+		Usage: 600,
+		Limit: 1000,
+		Stats: map[string]uint64{"cache": 100},
+	}
+	assert.Equal(t, float64(50), memoryPercent(stats))
+}
+
+func TestCPUPercent(t *testing.T) {
+	var stats types.StatsJSON
+	assert.Equal(t, float64(0), cpuPercent(stats))
+
+	stats.PreCPUStats.CPUUsage.TotalUsage = 100
+	stats.PreCPUStats.SystemUsage = 1000
+	stats.CPUStats.CPUUsage.TotalUsage = 300
+	stats.CPUStats.SystemUsage = 2000
+	assert.Equal(t, float64(20), cpuPercent(stats))
+}
+
+func TestDiskPercent(t *testing.T) {
+	usage, err := diskPercent("/")
+	assert.Nil(t, err)
+	assert.True(t, usage >= 0 && usage <= 100)
+
+	_, err = diskPercent("/does/not/exist")
+	assert.NotNil(t, err)
+}
+
+func TestPostAlert(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"rejected", http.StatusBadRequest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received api.Alert
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			var alert = newAlert(alertResourceDisk, "", 95, 90, false)
+			err := postAlert(ts.Client(), ts.URL, alert)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, alert.Text, received.Text)
+			assert.Contains(t, received.Text, "Disk usage is 95.0%")
+		})
+	}
+}
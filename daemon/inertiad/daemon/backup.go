@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+)
+
+// backupHandler exports the deployment database on GET requests, and restores
+// it from the request body on POST requests
+func (s *Server) backupHandler(w http.ResponseWriter, r *http.Request) {
+	logger := log.NewLogger(log.LoggerOptions{
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+	})
+
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		logger.WriteErr("no deployment data manager found", http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="project.db"`)
+		w.WriteHeader(http.StatusOK)
+		if _, err := manager.Backup(w); err != nil {
+			logger.Println("failed to write database backup: " + err.Error())
+		}
+
+	case http.MethodPost:
+		var state = s.config()
+		var body = http.MaxBytesReader(w, r.Body, state.MaxPayloadSize())
+		defer body.Close()
+		if err := manager.Restore(body); err != nil {
+			logger.WriteErr("failed to restore database: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.WriteSuccess("Deployment database restored", http.StatusOK)
+	}
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+const (
+	// baseImagePollInterval is how often the daemon checks whether it is time
+	// to check the project's base images for updates
+	baseImagePollInterval = 10 * time.Minute
+
+	// baseImageUpdateInitiator is recorded as the initiator of deployments
+	// triggered by base image updates
+	baseImageUpdateInitiator = "base image update"
+)
+
+// watchBaseImages periodically checks the project's base images for updates
+// if the project is configured to, and redeploys the project when any are
+// updated. Best used as a goroutine.
+func (s *Server) watchBaseImages(poll time.Duration) {
+	var lastCheck = time.Now()
+	for {
+		time.Sleep(poll)
+
+		// The interval is read on each run, since deployments can change it
+		var interval = time.Duration(s.config().BaseImageCheckHours) * time.Hour
+		if interval <= 0 || time.Since(lastCheck) < interval {
+			continue
+		}
+		lastCheck = time.Now()
+		s.redeployOnBaseImageUpdates(os.Stdout)
+	}
+}
+
+// redeployOnBaseImageUpdates pulls updates to the base images of the running
+// project, and rebuilds and redeploys the current commit if there are any
+func (s *Server) redeployOnBaseImageUpdates(out io.Writer) {
+	// Only running projects are kept up to date
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" ||
+		len(status.Containers) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, "Checking base images for updates...")
+	updated, err := s.deployment.UpdateBaseImages(s.docker, out)
+	if err != nil {
+		fmt.Fprintln(out, "Failed to check base images for updates: "+err.Error())
+		return
+	}
+	if len(updated) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "Base images updated (%s) - redeploying project\n",
+		strings.Join(updated, ", "))
+
+	s.runDeployment(deployAttempt{
+		initiator: baseImageUpdateInitiator,
+		out:       out,
+		opts:      project.DeployOptions{SkipUpdate: true},
+	})
+}
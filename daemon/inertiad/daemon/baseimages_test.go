@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestRedeployOnBaseImageUpdates(t *testing.T) {
+	type args struct {
+		status    api.DeploymentStatus
+		updated   []string
+		updateErr error
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantChecked bool
+		wantDeploy  bool
+	}{
+		{"no deployment", args{api.DeploymentStatus{}, []string{"alpine"}, nil}, false, false},
+		{"project stopped", args{api.DeploymentStatus{CommitHash: "abcdefg"},
+			[]string{"alpine"}, nil}, false, false},
+		{"check failed", args{api.DeploymentStatus{CommitHash: "abcdefg", Containers: []string{"web"}},
+			nil, errors.New("registry unavailable")}, true, false},
+		{"no updates", args{api.DeploymentStatus{CommitHash: "abcdefg", Containers: []string{"web"}},
+			[]string{}, nil}, true, false},
+		{"updated", args{api.DeploymentStatus{CommitHash: "abcdefg", Containers: []string{"web"}},
+			[]string{"alpine"}, nil}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return tt.args.status, nil
+				},
+				UpdateBaseImagesStub: func(*docker.Client, io.Writer) ([]string, error) {
+					return tt.args.updated, tt.args.updateErr
+				},
+				DeployStub: func(*docker.Client, io.Writer, project.DeployOptions) (func() error, error) {
+					return func() error { return nil }, nil
+				},
+			}
+			var s = &Server{deployment: fake, builds: newBuildQueue(1)}
+			events, unsubscribe := s.events.subscribe()
+			defer unsubscribe()
+
+			s.redeployOnBaseImageUpdates(ioutil.Discard)
+			assert.Equal(t, tt.wantChecked, fake.UpdateBaseImagesCallCount() == 1)
+			if !tt.wantDeploy {
+				assert.Equal(t, 0, fake.DeployCallCount())
+				assert.Len(t, events, 0)
+				return
+			}
+			assert.Equal(t, 1, fake.DeployCallCount())
+			_, _, opts := fake.DeployArgsForCall(0)
+			assert.True(t, opts.SkipUpdate)
+
+			var started, finished = <-events, <-events
+			assert.Equal(t, api.EventDeployStarted, started.Action)
+			assert.Equal(t, baseImageUpdateInitiator, started.Initiator)
+			assert.Equal(t, api.EventDeploySucceeded, finished.Action)
+		})
+	}
+}
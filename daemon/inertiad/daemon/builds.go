@@ -0,0 +1,112 @@
+package daemon
+
+import "sync"
+
+// buildQueue limits the number of concurrent builds. Builds that exceed the
+// limit wait for a slot, and are started in the order they were requested.
+type buildQueue struct {
+	mux     sync.Mutex
+	limit   int
+	active  int
+	waiting []chan struct{}
+}
+
+// newBuildQueue creates a queue that allows the given number of concurrent
+// builds
+func newBuildQueue(limit int) *buildQueue {
+	if limit < 1 {
+		limit = 1
+	}
+	return &buildQueue{limit: limit}
+}
+
+// acquire blocks until a build slot is available, and returns a function that
+// must be called to release the slot once the build is complete. If the build
+// has to wait, onQueued is called with its position in the queue. Builds are
+// not limited if the queue is nil.
+func (q *buildQueue) acquire(onQueued func(position int)) (release func()) {
+	if q == nil {
+		return func() {}
+	}
+	q.mux.Lock()
+	if q.active < q.limit && len(q.waiting) == 0 {
+		q.active++
+		q.mux.Unlock()
+		return q.release
+	}
+	var ready = make(chan struct{})
+	q.waiting = append(q.waiting, ready)
+	var position = len(q.waiting)
+	q.mux.Unlock()
+
+	if onQueued != nil {
+		onQueued(position)
+	}
+	<-ready
+	return q.release
+}
+
+// exclusive waits for active builds to complete, and then holds every build
+// slot until the returned function is called, so that no builds run in the
+// meantime. Callers must not hold any other slots, and must not call exclusive
+// concurrently.
+func (q *buildQueue) exclusive(onQueued func(position int)) (release func()) {
+	if q == nil {
+		return func() {}
+	}
+	q.mux.Lock()
+	var releases = make([]func(), q.limit)
+	q.mux.Unlock()
+	for i := range releases {
+		releases[i] = q.acquire(onQueued)
+	}
+	return func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+// setLimit changes the number of concurrent builds allowed. Queued builds are
+// started if the limit is raised, while active builds are left to complete if
+// it is lowered.
+func (q *buildQueue) setLimit(limit int) {
+	if q == nil {
+		return
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.limit = limit
+	for q.active < q.limit && len(q.waiting) > 0 {
+		q.active++
+		close(q.waiting[0])
+		q.waiting = q.waiting[1:]
+	}
+}
+
+// release hands the caller's build slot to the next queued build, if there is
+// one and the limit has not been lowered below the number of active builds
+func (q *buildQueue) release() {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if len(q.waiting) > 0 && q.active <= q.limit {
+		// The slot stays active and is handed over to the next build
+		close(q.waiting[0])
+		q.waiting = q.waiting[1:]
+		return
+	}
+	q.active--
+}
+
+// status returns the number of active and queued builds
+func (q *buildQueue) status() (active, queued int) {
+	if q == nil {
+		return 0, 0
+	}
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return q.active, len(q.waiting)
+}
@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildQueue(t *testing.T) {
+	var q = newBuildQueue(1)
+	var release = q.acquire(func(int) { assert.Fail(t, "first build should not be queued") })
+	active, queued := q.status()
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 0, queued)
+
+	// Queue up two more builds, which should start in order
+	var (
+		positions = make(chan int, 2)
+		started   = make(chan int, 2)
+	)
+	for i := 1; i <= 2; i++ {
+		go func(i int) {
+			var done = q.acquire(func(position int) { positions <- position })
+			started <- i
+			done()
+		}(i)
+		assert.Equal(t, i, <-positions)
+	}
+	active, queued = q.status()
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 2, queued)
+
+	select {
+	case <-started:
+		assert.Fail(t, "queued build started before a slot was released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	assert.Equal(t, 1, <-started)
+	assert.Equal(t, 2, <-started)
+
+	// All slots should be released
+	time.Sleep(10 * time.Millisecond)
+	active, queued = q.status()
+	assert.Equal(t, 0, active)
+	assert.Equal(t, 0, queued)
+}
+
+func TestBuildQueueNil(t *testing.T) {
+	var q *buildQueue
+	q.acquire(nil)()
+	active, queued := q.status()
+	assert.Zero(t, active)
+	assert.Zero(t, queued)
+}
+
+func TestBuildQueueSetLimit(t *testing.T) {
+	var q = newBuildQueue(1)
+	var release = q.acquire(nil)
+	var started = make(chan struct{})
+	go func() {
+		q.acquire(func(int) {})()
+		close(started)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_, queued := q.status()
+	assert.Equal(t, 1, queued)
+
+	// Raising the limit should start the queued build
+	q.setLimit(2)
+	<-started
+
+	// Lowering the limit should not hand released slots over beyond it
+	var second = q.acquire(nil)
+	q.setLimit(1)
+	var queuedDone = make(chan struct{})
+	go func() {
+		q.acquire(func(int) {})()
+		close(queuedDone)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	active, queued := q.status()
+	assert.Equal(t, 2, active)
+	assert.Equal(t, 1, queued)
+	release()
+	active, queued = q.status()
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 1, queued)
+	second()
+	<-queuedDone
+}
+
+func TestBuildQueueExclusive(t *testing.T) {
+	var q = newBuildQueue(2)
+	var release = q.acquire(nil)
+
+	var acquired = make(chan func())
+	go func() { acquired <- q.exclusive(nil) }()
+	select {
+	case <-acquired:
+		assert.Fail(t, "exclusive access granted while a build was active")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	var releaseAll = <-acquired
+	active, _ := q.status()
+	assert.Equal(t, 2, active)
+
+	releaseAll()
+	active, _ = q.status()
+	assert.Equal(t, 0, active)
+}
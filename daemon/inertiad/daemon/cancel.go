@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// activeDeploys tracks deployments that can still be cancelled. The zero
+// value is ready to use.
+type activeDeploys struct {
+	mux     sync.Mutex
+	next    int
+	cancels map[int]context.CancelFunc
+}
+
+// start registers a new cancellable deployment, and returns its context and
+// a function that must be called once it can no longer be cancelled
+func (a *activeDeploys) start() (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.cancels == nil {
+		a.cancels = make(map[int]context.CancelFunc)
+	}
+	var id = a.next
+	a.next++
+	a.cancels[id] = cancel
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			a.mux.Lock()
+			delete(a.cancels, id)
+			a.mux.Unlock()
+			cancel()
+		})
+	}
+}
+
+// cancelAll cancels all registered deployments, and returns how many were
+// cancelled
+func (a *activeDeploys) cancelAll() int {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	var cancelled = len(a.cancels)
+	for id, cancel := range a.cancels {
+		cancel()
+		delete(a.cancels, id)
+	}
+	return cancelled
+}
+
+// cancelOnDisconnect calls cancel if the client of the given request
+// disconnects before the returned function is called
+func cancelOnDisconnect(r *http.Request, cancel func()) (stop func()) {
+	var stopped = make(chan struct{})
+	go func() {
+		select {
+		case <-r.Context().Done():
+			// The request also ends once the handler returns, which may be
+			// noticed before stop is
+			select {
+			case <-stopped:
+			default:
+				cancel()
+			}
+		case <-stopped:
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(stopped) }) }
+}
+
+// cancelHandler cancels deployments that are in progress or waiting for a
+// build slot. Cancelled deployments restore the previous deployment.
+func (s *Server) cancelHandler(w http.ResponseWriter, r *http.Request) {
+	var cancelled = s.deploys.cancelAll()
+	if cancelled == 0 {
+		http.Error(w, "no deployment in progress", http.StatusPreconditionFailed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if cancelled == 1 {
+		fmt.Fprintln(w, "Deployment cancelled")
+	} else {
+		fmt.Fprintf(w, "%d deployments cancelled\n", cancelled)
+	}
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveDeploys(t *testing.T) {
+	var deploys activeDeploys
+	assert.Equal(t, 0, deploys.cancelAll())
+
+	first, firstDone := deploys.start()
+	second, secondDone := deploys.start()
+	defer secondDone()
+
+	// Finished deployments can no longer be cancelled
+	firstDone()
+	firstDone()
+	assert.NotNil(t, first.Err())
+	assert.Nil(t, second.Err())
+
+	assert.Equal(t, 1, deploys.cancelAll())
+	assert.NotNil(t, second.Err())
+	assert.Equal(t, 0, deploys.cancelAll())
+}
+
+func TestCancelOnDisconnect(t *testing.T) {
+	// Deployments should be cancelled if the client disconnects
+	ctx, disconnect := context.WithCancel(context.Background())
+	var req = httptest.NewRequest("POST", "/up", nil).WithContext(ctx)
+	var cancelled = make(chan struct{})
+	cancelOnDisconnect(req, func() { close(cancelled) })
+	disconnect()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("deployment was not cancelled")
+	}
+
+	// Deployments should not be cancelled once they are no longer watched
+	ctx, disconnect = context.WithCancel(context.Background())
+	req = httptest.NewRequest("POST", "/up", nil).WithContext(ctx)
+	var stop = cancelOnDisconnect(req, func() { t.Error("deployment was cancelled") })
+	stop()
+	stop()
+	disconnect()
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestCancelHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		active   int
+		wantCode int
+		wantBody string
+	}{
+		{"no deployment", 0, http.StatusPreconditionFailed, "no deployment in progress\n"},
+		{"one deployment", 1, http.StatusOK, "Deployment cancelled\n"},
+		{"queued deployments", 2, http.StatusOK, "2 deployments cancelled\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Server{}
+			for i := 0; i < tt.active; i++ {
+				ctx, done := s.deploys.start()
+				defer done()
+				defer func() { assert.NotNil(t, ctx.Err()) }()
+			}
+
+			req, err := http.NewRequest("POST", "/cancel", nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.cancelHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Equal(t, tt.wantBody, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clientConns tracks the connections of the daemon's clients by remote
+// address, so that writes to stalled clients can be interrupted. The zero
+// value is ready to use.
+type clientConns struct {
+	mux   sync.Mutex
+	conns map[string]net.Conn
+}
+
+// track records changes in connection state - it should be used as the
+// http.Server's ConnState hook
+func (c *clientConns) track(conn net.Conn, state http.ConnState) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	switch state {
+	case http.StateNew:
+		if c.conns == nil {
+			c.conns = make(map[string]net.Conn)
+		}
+		c.conns[conn.RemoteAddr().String()] = conn
+	case http.StateHijacked, http.StateClosed:
+		delete(c.conns, conn.RemoteAddr().String())
+	}
+}
+
+// interrupt returns a function that causes blocked writes to the connection
+// of the given request to fail, by setting a write deadline that has already
+// passed. The connection cannot be written to afterwards.
+func (c *clientConns) interrupt(r *http.Request) func() {
+	return func() {
+		c.mux.Lock()
+		var conn = c.conns[r.RemoteAddr]
+		c.mux.Unlock()
+		if conn != nil {
+			conn.SetWriteDeadline(time.Now())
+		}
+	}
+}
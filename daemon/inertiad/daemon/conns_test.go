@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientConnsInterrupt(t *testing.T) {
+	var conns clientConns
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conns.track(server, http.StateNew)
+
+	// A write to a client that never reads should fail once interrupted
+	var written = make(chan error)
+	go func() {
+		_, err := server.Write([]byte("stuck"))
+		written <- err
+	}()
+	conns.interrupt(&http.Request{RemoteAddr: server.RemoteAddr().String()})()
+	select {
+	case err := <-written:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("write was not interrupted")
+	}
+
+	// Closed connections should no longer be tracked
+	conns.track(server, http.StateClosed)
+	assert.Empty(t, conns.conns)
+	conns.interrupt(&http.Request{RemoteAddr: server.RemoteAddr().String()})()
+}
@@ -5,11 +5,14 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	docker "github.com/docker/docker/client"
 	"github.com/gorilla/websocket"
+	"github.com/ubclaunchpad/inertia/common"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/auth"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
@@ -21,10 +24,40 @@ type Server struct {
 	version string
 
 	deployment project.Deployer
-	state      cfg.Config
+
+	// state is the daemon's configuration - it is changed by reloads and
+	// deployments, so it must be accessed through config and updateConfig
+	state    cfg.Config
+	stateMux sync.RWMutex
 
 	docker    *docker.Client
 	websocket *websocket.Upgrader
+
+	// logStreams limits the number of concurrent log streams
+	logStreams *streamLimit
+
+	// builds limits the number of concurrent builds
+	builds *buildQueue
+
+	// reloadMux prevents configuration reloads from running concurrently
+	reloadMux sync.Mutex
+
+	// deploys tracks deployments that can be cancelled
+	deploys activeDeploys
+
+	// events broadcasts deployment events to event stream clients
+	events eventFeed
+
+	// conns tracks client connections, so that stalled clients can be cut off
+	conns clientConns
+
+	// tracer exports traces of deployments - it is nil if tracing is disabled
+	tracer *common.Tracer
+
+	// spotInterruption is when the instance is scheduled to be interrupted,
+	// if it is a spot instance that has received an interruption notice
+	spotInterruption *time.Time
+	spotMux          sync.Mutex
 }
 
 // New instantiates a new Inertiad server
@@ -48,14 +81,31 @@ func New(version string, state cfg.Config, deployment project.Deployer) (*Server
 		websocket: &websocket.Upgrader{
 			HandshakeTimeout: 5 * time.Second,
 		},
+		logStreams: newStreamLimit(state.MaxLogStreams),
+		builds:     newBuildQueue(state.MaxConcurrentBuilds),
+		tracer:     common.NewTracer(state.OTLPEndpoint, "inertiad", os.Stdout),
 	}, nil
 }
 
+// config returns a copy of the daemon's current configuration
+func (s *Server) config() cfg.Config {
+	s.stateMux.RLock()
+	defer s.stateMux.RUnlock()
+	return s.state
+}
+
+// updateConfig applies the given changes to the daemon's configuration
+func (s *Server) updateConfig(update func(state *cfg.Config)) {
+	s.stateMux.Lock()
+	defer s.stateMux.Unlock()
+	update(&s.state)
+}
+
 // Run starts the server
 func (s *Server) Run(host, port string) error {
 	var (
 		err    error
-		sslDir = path.Join(s.state.SecretsDirectory, "ssl")
+		sslDir = path.Join(s.config().SecretsDirectory, "ssl")
 		cert   = path.Join(sslDir, "daemon.cert")
 		key    = path.Join(sslDir, "daemon.key")
 	)
@@ -93,10 +143,28 @@ func (s *Server) Run(host, port string) error {
 		}
 	}()
 
+	// Watch for spot instance interruptions
+	go s.watchSpotInterruption(ec2MetadataURL, 5*time.Second)
+
+	// Keep base images up to date if configured to
+	go s.watchBaseImages(baseImagePollInterval)
+
+	// Deploy new commits if configured to poll for them
+	go s.watchRepository(pollCheckInterval)
+
+	// Send alerts when resource usage crosses configured thresholds
+	go s.watchResourceUsage(alertsPollInterval)
+
+	// Clean up old deployment history
+	go s.pruneDeploymentRecords(deployRecordPruneInterval)
+
+	// Reload configuration when asked to
+	go s.reloadConfigOnHangup()
+
 	// Set up endpoints
 	var (
 		webPrefix        = "/web/"
-		userDatabasePath = path.Join(s.state.DataDirectory, "users.db")
+		userDatabasePath = path.Join(s.config().DataDirectory, "users.db")
 	)
 	handler, err := auth.NewPermissionsHandler(
 		userDatabasePath, host, 120)
@@ -106,29 +174,101 @@ func (s *Server) Run(host, port string) error {
 	defer handler.Close()
 	println("Permissions manager successfully created")
 
+	s.attachHandlers(handler, webPrefix)
+
+	// Serve daemon on port
+	println("Serving daemon on port " + port)
+	var server = &http.Server{
+		Addr:      ":" + port,
+		Handler:   handler,
+		ConnState: s.conns.track,
+	}
+	return server.ListenAndServeTLS(cert, key)
+}
+
+// Close releases server assets
+func (s *Server) Close() {
+	s.deployment.Down(s.docker, os.Stdout)
+	s.docker.Close()
+}
+
+// siteFileSystem serves published static sites. Directories without an
+// index.html are not served, so that the files in sites are not listed.
+type siteFileSystem struct{ http.FileSystem }
+
+func (fs siteFileSystem) Open(name string) (http.File, error) {
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := fs.FileSystem.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
+
+// attachHandlers registers the daemon's endpoints on the given handler. All
+// endpoints other than the webhook, Inertia Web, static sites, and the root
+// status check require a valid access token.
+func (s *Server) attachHandlers(handler *auth.PermissionsHandler, webPrefix string) {
 	// Inertia web
 	handler.AttachPublicHandler(
 		webPrefix,
 		http.StripPrefix(webPrefix, http.FileServer(http.Dir("/daemon/inertia-web"))))
 
+	// Static sites, published by projects with the static build type
+	var sitePrefix = "/" + build.StaticSiteDirectory + "/"
+	handler.AttachPublicHandler(
+		sitePrefix,
+		http.StripPrefix(sitePrefix, http.FileServer(siteFileSystem{http.Dir(
+			path.Join(s.config().DataDirectory, build.StaticSiteDirectory))})))
+
 	// GitHub webhook endpoint
 	handler.AttachPublicHandlerFunc("/webhook", s.webhookHandler)
 
 	// API endpoints
 	handler.AttachUserRestrictedHandlerFunc("/status",
 		s.statusHandler, http.MethodGet)
+	handler.AttachUserRestrictedHandlerFunc("/info",
+		s.infoHandler, http.MethodGet)
 	handler.AttachUserRestrictedHandlerFunc("/logs",
 		s.logHandler, http.MethodGet)
+	handler.AttachUserRestrictedHandlerFunc("/history",
+		s.historyHandler, http.MethodGet)
+	handler.AttachUserRestrictedHandlerFunc("/events",
+		s.eventsHandler, http.MethodGet)
+	handler.AttachUserRestrictedHandlerFunc("/fetch",
+		s.fetchHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/up",
 		s.upHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/cancel",
+		s.cancelHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/rollback",
+		s.rollbackHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/recreate",
+		s.recreateHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/down",
 		s.downHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/reset",
 		s.resetHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/env",
 		s.envHandler, http.MethodGet, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/backup",
+		s.backupHandler, http.MethodGet, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/prune",
 		s.pruneHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/config/reload",
+		s.reloadConfigHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/token",
 		tokenHandler, http.MethodGet)
 
@@ -136,18 +276,4 @@ func (s *Server) Run(host, port string) error {
 	handler.AttachPublicHandlerFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
-
-	// Serve daemon on port
-	println("Serving daemon on port " + port)
-	return http.ListenAndServeTLS(
-		":"+port,
-		cert,
-		key,
-		handler)
-}
-
-// Close releases server assets
-func (s *Server) Close() {
-	s.deployment.Down(s.docker, os.Stdout)
-	s.docker.Close()
 }
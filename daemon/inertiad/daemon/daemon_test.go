@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/auth"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestAttachHandlersRequireAuth(t *testing.T) {
+	dir := "./test_handlers"
+	assert.Nil(t, os.Mkdir(dir, os.ModePerm))
+	defer os.RemoveAll(dir)
+
+	handler, err := auth.NewPermissionsHandler(
+		path.Join(dir, "users.db"), "127.0.0.1", 3000, crypto.GetFakeAPIKey)
+	assert.Nil(t, err)
+	defer handler.Close()
+	var fake = &mocks.FakeDeployer{}
+	var s = &Server{deployment: fake}
+	s.attachHandlers(handler, "/web/")
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/status"},
+		{"GET", "/info"},
+		{"GET", "/logs"},
+		{"GET", "/history"},
+		{"GET", "/events"},
+		{"POST", "/fetch"},
+		{"POST", "/up"},
+		{"POST", "/cancel"},
+		{"POST", "/rollback"},
+		{"POST", "/config/reload"},
+		{"POST", "/recreate"},
+		{"POST", "/down"},
+		{"POST", "/reset"},
+		{"GET", "/env"},
+		{"GET", "/backup"},
+		{"POST", "/prune"},
+		{"GET", "/token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			for _, token := range []string{"", "Bearer badtoken"} {
+				req, err := http.NewRequest(tt.method, tt.path, nil)
+				assert.Nil(t, err)
+				if token != "" {
+					req.Header.Set("Authorization", token)
+				}
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Empty(t, fake.Invocations())
+			}
+		})
+	}
+
+	// The webhook is authenticated by its signature instead
+	req, err := http.NewRequest("POST", "/webhook", http.NoBody)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.NotEqual(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestSiteFileSystem(t *testing.T) {
+	dir := "./test_site"
+	assert.Nil(t, os.MkdirAll(path.Join(dir, "docs"), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(path.Join(dir, "assets"), os.ModePerm))
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "index.html"), []byte("home"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "docs", "index.html"), []byte("docs"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "assets", "app.js"), []byte("app"), 0644))
+	var handler = http.FileServer(siteFileSystem{http.Dir(dir)})
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/", http.StatusOK, "home"},
+		{"/docs/", http.StatusOK, "docs"},
+		{"/assets/app.js", http.StatusOK, "app"},
+		{"/assets/", http.StatusNotFound, ""},
+		{"/missing.html", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, recorder.Body.String())
+			} else {
+				assert.NotContains(t, recorder.Body.String(), "app.js")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+// deployAttempt describes a deployment of the project, run with
+// Server.runDeployment
+type deployAttempt struct {
+	// initiator is who or what started the deployment
+	initiator string
+
+	// out is where output is written in addition to the deployment's log,
+	// and opts are the options to deploy with
+	out  io.Writer
+	opts project.DeployOptions
+
+	// output, if set, returns where output is written instead of out, given
+	// the deployment's log, which the output should include
+	output func(deployLog io.Writer) io.Writer
+
+	// options, if set, returns the options to deploy with instead of opts,
+	// and is called once a build slot is held - the deployment is stopped if
+	// it returns an error
+	options func(ctx context.Context, trace *common.Span) (project.DeployOptions, error)
+
+	// starting, if set, is called once the project is built, with a function
+	// that cancels the deployment. The function it returns is called once the
+	// project has started.
+	starting func(cancel func()) (started func())
+
+	// finish, if set, is called with the outcome of the deployment before its
+	// log is closed - failures are written to the output otherwise
+	finish func(out io.Writer, err error)
+}
+
+// runDeployment builds and deploys the project as described by attempt,
+// saving its output to a deployment log and recording its outcome. The
+// deployment can be cancelled until the project has started.
+func (s *Server) runDeployment(attempt deployAttempt) (err error) {
+	var started = time.Now()
+	var deployLog = s.createDeployLog(started)
+	defer deployLog.Close()
+	var out io.Writer
+	if attempt.output != nil {
+		out = attempt.output(deployLog)
+	} else {
+		out = io.MultiWriter(attempt.out, deployLog)
+	}
+
+	// Report the outcome of this deployment attempt once it completes
+	s.events.publishDeployStarted(attempt.initiator)
+	var trace = s.startDeployTrace(attempt.initiator)
+	defer func() {
+		if attempt.finish != nil {
+			attempt.finish(out, err)
+		} else if err != nil {
+			fmt.Fprintln(out, "Deploy failed: "+err.Error())
+		}
+		s.recordDeployment(attempt.initiator, started, trace, err)
+	}()
+
+	ctx, done := s.deploys.start()
+	defer done()
+
+	// Wait for a build slot before preparing the deployment, so that
+	// deployments that are still running are not affected by it
+	var release = s.builds.acquire(func(position int) {
+		fmt.Fprintf(out, "Waiting for a build slot - position %d in queue\n", position)
+	})
+	defer release()
+	if ctx.Err() != nil {
+		return project.ErrDeployCancelled
+	}
+	var opts = attempt.opts
+	if attempt.options != nil {
+		if opts, err = attempt.options(ctx, trace); err != nil {
+			return err
+		}
+	}
+	opts.Context = ctx
+	opts.Trace = trace
+	opts.MinFreeDiskMB = s.config().MinFreeDiskMB
+
+	deploy, err := s.deployment.Deploy(s.docker, out, opts)
+	if err != nil {
+		return err
+	}
+	if attempt.starting != nil {
+		defer attempt.starting(done)()
+	}
+	return deploy()
+}
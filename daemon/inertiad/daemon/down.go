@@ -14,7 +14,8 @@ const (
 
 // downHandler tries to take the deployment offline
 func (s *Server) downHandler(w http.ResponseWriter, r *http.Request) {
-	if status, _ := s.deployment.GetStatus(s.docker); len(status.Containers) == 0 {
+	if status, _ := s.deployment.GetStatus(s.docker); len(status.Containers) == 0 &&
+		!status.SitePublished {
 		http.Error(w, msgNoDeployment, http.StatusPreconditionFailed)
 		return
 	}
@@ -34,3 +34,22 @@ func TestDownHandlerNoDeployment(t *testing.T) {
 	assert.Equal(t, recorder.Code, http.StatusPreconditionFailed)
 	assert.Contains(t, recorder.Body.String(), msgNoDeployment)
 }
+
+func TestDownHandlerSitePublished(t *testing.T) {
+	var fake = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{
+				Containers:    []string{},
+				SitePublished: true,
+			}, nil
+		},
+	}
+	var s = &Server{deployment: fake}
+
+	req, err := http.NewRequest("POST", "/down", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.downHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, fake.DownCallCount())
+}
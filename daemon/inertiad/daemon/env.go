@@ -40,6 +40,7 @@ func envPostHandler(s *Server, w http.ResponseWriter, r *http.Request) {
 	}
 	if envReq.Name == "" {
 		logger.WriteErr("no variable name provided", http.StatusBadRequest)
+		return
 	}
 
 	manager, found := s.deployment.GetDataManager()
@@ -52,9 +53,7 @@ func envPostHandler(s *Server, w http.ResponseWriter, r *http.Request) {
 	if envReq.Remove {
 		err = manager.RemoveEnvVariables(envReq.Name)
 	} else {
-		err = manager.AddEnvVariable(
-			envReq.Name, envReq.Value, envReq.Encrypt,
-		)
+		err = manager.AddEnvVariable(envReq.Name, envReq.Value)
 	}
 	if err != nil {
 		logger.WriteErr(err.Error(), http.StatusInternalServerError)
@@ -77,9 +76,11 @@ func envGetHandler(s *Server, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Values are never sent back in plain text
 	values, err := manager.GetEnvVariables(false)
 	if err != nil {
 		logger.WriteErr(err.Error(), http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,179 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gorilla/websocket"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
+)
+
+// eventSubscriberBuffer is the number of events buffered for each events feed
+// subscriber - events are dropped for subscribers that fall further behind
+const eventSubscriberBuffer = 100
+
+// containerEventActions are the Docker container events reported by the
+// events feed
+var containerEventActions = []string{
+	"create", "start", "restart", "stop", "die", "kill", "oom", "destroy", "health_status",
+}
+
+// eventFeed broadcasts deployment events to subscribers. The zero value is
+// ready to use.
+type eventFeed struct {
+	mux         sync.Mutex
+	subscribers map[chan api.Event]struct{}
+}
+
+// subscribe registers a new subscriber, and returns its events and a function
+// that must be called once it no longer needs them
+func (f *eventFeed) subscribe() (<-chan api.Event, func()) {
+	var ch = make(chan api.Event, eventSubscriberBuffer)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan api.Event]struct{})
+	}
+	f.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mux.Lock()
+			delete(f.subscribers, ch)
+			f.mux.Unlock()
+		})
+	}
+}
+
+// publish sends the given event to all subscribers without blocking, so a
+// slow subscriber misses events rather than holding up deployments
+func (f *eventFeed) publish(event api.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// publishDeployStarted reports the start of a deployment by initiator
+func (f *eventFeed) publishDeployStarted(initiator string) {
+	f.publish(api.Event{
+		Type:      api.EventTypeDeploy,
+		Action:    api.EventDeployStarted,
+		Initiator: initiator,
+	})
+}
+
+// publishDeployFinished reports the outcome of a deployment by initiator
+func (f *eventFeed) publishDeployFinished(initiator, commit string, deployErr error) {
+	var event = api.Event{
+		Type:       api.EventTypeDeploy,
+		Action:     api.EventDeploySucceeded,
+		Initiator:  initiator,
+		CommitHash: commit,
+	}
+	if deployErr != nil {
+		event.Action = api.EventDeployFailed
+		event.Error = deployErr.Error()
+	}
+	f.publish(event)
+}
+
+// containerEvent converts a Docker event into an events feed event
+func containerEvent(message events.Message) api.Event {
+	var name = strings.TrimPrefix(message.Actor.Attributes["name"], "/")
+	if name == "" && len(message.ID) > 11 {
+		name = message.ID[:11]
+	}
+	var event = api.Event{
+		Type:      api.EventTypeContainer,
+		Action:    message.Action,
+		Container: name,
+		Time:      time.Unix(0, message.TimeNano),
+	}
+	if message.TimeNano == 0 {
+		event.Time = time.Unix(message.Time, 0)
+	}
+	return event
+}
+
+// eventsHandler streams deployment events, and state changes of project
+// containers, as JSON messages over a websocket. Event streams count towards
+// the limit on concurrent log streams.
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, "events can only be streamed over a websocket", http.StatusBadRequest)
+		return
+	}
+	if !s.acquireLogStream() {
+		s.rejectLogStream(w, r)
+		return
+	}
+	defer s.releaseLogStream()
+
+	socket, err := s.websocket.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer socket.Close()
+
+	// Stop streaming once the client goes away - clients are not expected to
+	// send anything, so reads only end when the connection is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := socket.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deployEvents, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	var args = filters.NewArgs(
+		filters.KeyValuePair{Key: "type", Value: "container"},
+		filters.KeyValuePair{Key: "label", Value: build.LabelProject})
+	for _, action := range containerEventActions {
+		args.Add("event", action)
+	}
+	dockerEvents, dockerErrs := s.docker.Events(ctx, types.EventsOptions{Filters: args})
+
+	for {
+		var event api.Event
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-dockerErrs:
+			if err != nil && ctx.Err() == nil {
+				socket.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseInternalServerErr,
+						"stream ended: "+err.Error()),
+					time.Now().Add(time.Second))
+			}
+			return
+		case message := <-dockerEvents:
+			event = containerEvent(message)
+		case event = <-deployEvents:
+		}
+		if err := socket.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
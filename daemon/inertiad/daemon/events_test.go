@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestEventFeed(t *testing.T) {
+	var feed eventFeed
+
+	// Events published without subscribers are dropped
+	feed.publishDeployStarted("bob")
+
+	first, unsubscribeFirst := feed.subscribe()
+	second, unsubscribeSecond := feed.subscribe()
+	defer unsubscribeSecond()
+
+	feed.publishDeployFinished("bob", "1234", nil)
+	for _, ch := range []<-chan api.Event{first, second} {
+		var event = <-ch
+		assert.Equal(t, api.EventTypeDeploy, event.Type)
+		assert.Equal(t, api.EventDeploySucceeded, event.Action)
+		assert.Equal(t, "bob", event.Initiator)
+		assert.Equal(t, "1234", event.CommitHash)
+		assert.False(t, event.Time.IsZero())
+	}
+
+	// Unsubscribed clients no longer receive events
+	unsubscribeFirst()
+	unsubscribeFirst()
+	feed.publishDeployFinished("bob", "1234", errors.New("build failed"))
+	var event = <-second
+	assert.Equal(t, api.EventDeployFailed, event.Action)
+	assert.Equal(t, "build failed", event.Error)
+	assert.Len(t, first, 0)
+
+	// Slow subscribers miss events rather than blocking
+	for i := 0; i < eventSubscriberBuffer+1; i++ {
+		feed.publishDeployStarted("bob")
+	}
+	assert.Len(t, second, eventSubscriberBuffer)
+}
+
+func TestContainerEvent(t *testing.T) {
+	var now = time.Now()
+	tests := []struct {
+		name    string
+		message events.Message
+		want    api.Event
+	}{
+		{"named container",
+			events.Message{
+				ID:       "0123456789abcdef",
+				Action:   "die",
+				Actor:    events.Actor{Attributes: map[string]string{"name": "/web"}},
+				TimeNano: now.UnixNano(),
+			},
+			api.Event{Type: api.EventTypeContainer, Action: "die", Container: "web",
+				Time: time.Unix(0, now.UnixNano())}},
+		{"unnamed container",
+			events.Message{
+				ID:     "0123456789abcdef",
+				Action: "health_status: healthy",
+				Time:   now.Unix(),
+			},
+			api.Event{Type: api.EventTypeContainer, Action: "health_status: healthy",
+				Container: "0123456789a", Time: time.Unix(now.Unix(), 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, containerEvent(tt.message))
+		})
+	}
+}
+
+func TestEventsHandlerRequiresWebSocket(t *testing.T) {
+	var s = &Server{}
+	req, err := http.NewRequest("GET", "/events", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.eventsHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+// fetchHandler fetches the latest commits on the deployed branch without
+// building or deploying them, and reports how they differ from the deployed
+// commit
+func (s *Server) fetchHandler(w http.ResponseWriter, r *http.Request) {
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
+		http.Error(w, msgNoDeployment, http.StatusPreconditionFailed)
+		return
+	}
+
+	// An empty configuration fetches the currently deployed branch
+	preview, err := s.deployment.Preview(s.docker, project.DeploymentConfig{})
+	if err != nil {
+		http.Error(w, "failed to fetch repository: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	preview.ConfigChanges = []string{}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(preview)
+}
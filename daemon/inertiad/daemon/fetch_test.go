@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestFetchHandler(t *testing.T) {
+	type args struct {
+		status api.DeploymentStatus
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantCode int
+	}{
+		{"no deployment", args{api.DeploymentStatus{}}, http.StatusPreconditionFailed},
+		{"new commits", args{api.DeploymentStatus{CommitHash: "abcdefg"}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return tt.args.status, nil
+				},
+				PreviewStub: func(*docker.Client, project.DeploymentConfig) (api.DeploymentPreview, error) {
+					return api.DeploymentPreview{
+						Branch:        "master",
+						CurrentCommit: "abcdefg",
+						TargetCommit:  "hijklmn",
+						NewCommits:    []string{"hijklmn wow"},
+					}, nil
+				},
+			}
+			var s = &Server{deployment: fake}
+
+			req, err := http.NewRequest("POST", "/fetch", nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.fetchHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.wantCode == http.StatusOK {
+				var preview api.DeploymentPreview
+				assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&preview))
+				assert.Equal(t, "hijklmn", preview.TargetCommit)
+				assert.Len(t, preview.NewCommits, 1)
+				assert.Equal(t, 1, fake.PreviewCallCount())
+			} else {
+				assert.Equal(t, 0, fake.PreviewCallCount())
+			}
+		})
+	}
+}
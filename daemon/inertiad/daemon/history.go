@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+)
+
+// historyHandler returns a page of past deployment attempts, most recent first
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		params  = r.URL.Query()
+		entries = 10
+		offset  = 0
+		err     error
+	)
+	if entriesParam := params.Get(api.Entries); entriesParam != "" {
+		if entries, err = strconv.Atoi(entriesParam); err != nil || entries < 0 {
+			http.Error(w, "invalid number of entries", http.StatusBadRequest)
+			return
+		}
+	}
+	if offsetParam := params.Get(api.Offset); offsetParam != "" {
+		if offset, err = strconv.Atoi(offsetParam); err != nil || offset < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		http.Error(w, "no deployment data manager found", http.StatusPreconditionFailed)
+		return
+	}
+
+	records, err := manager.GetDeploymentRecords(offset, entries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(records)
+}
+
+// deployLogsDirectory is the directory in the data directory that the output
+// of each deployment attempt is saved to
+const deployLogsDirectory = "deploy-logs"
+
+// deployLogTimeFormat names deployment logs after the time deployments began,
+// such that their names sort in the order deployments began
+const deployLogTimeFormat = "20060102T150405.000000000Z"
+
+// createDeployLog creates the file the output of a deployment attempt that
+// began at the given time is saved to. Output is discarded if the file cannot
+// be created, or if there is no data directory. Saved logs are removed by
+// pruneDeploymentRecords.
+func (s *Server) createDeployLog(started time.Time) io.WriteCloser {
+	var dataDir = s.config().DataDirectory
+	if dataDir == "" {
+		return nopWriteCloser{ioutil.Discard}
+	}
+	var dir = filepath.Join(dataDir, deployLogsDirectory)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		println("Failed to save deployment output: " + err.Error())
+		return nopWriteCloser{ioutil.Discard}
+	}
+	file, err := os.OpenFile(
+		filepath.Join(dir, started.UTC().Format(deployLogTimeFormat)+".log"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		println("Failed to save deployment output: " + err.Error())
+		return nopWriteCloser{ioutil.Discard}
+	}
+	return file
+}
+
+// nopWriteCloser is an io.Writer with a Close method that does nothing
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// startDeployTrace begins a trace of a deployment attempt by the given
+// initiator, which is ended by recordDeployment
+func (s *Server) startDeployTrace(initiator string) *common.Span {
+	var trace = s.tracer.Start("deploy")
+	trace.SetAttribute("inertia.initiator", initiator)
+	return trace
+}
+
+// recordDeployment saves the outcome of a deployment attempt that began at
+// the given time to the deployment history, reports it to event streams, and
+// exports its trace
+func (s *Server) recordDeployment(initiator string, started time.Time, trace *common.Span,
+	deployErr error) {
+	var status, _ = s.deployment.GetStatus(s.docker)
+	s.events.publishDeployFinished(initiator, status.CommitHash, deployErr)
+
+	trace.SetAttribute("inertia.branch", status.Branch)
+	trace.SetAttribute("inertia.commit", status.CommitHash)
+	trace.End(deployErr)
+
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		return
+	}
+
+	var record = api.DeploymentRecord{
+		Initiator:  initiator,
+		Branch:     status.Branch,
+		CommitHash: status.CommitHash,
+		Release:    status.Release,
+		Success:    deployErr == nil,
+		StartedAt:  started,
+		Duration:   time.Since(started),
+	}
+	if deployErr != nil {
+		record.Error = deployErr.Error()
+	}
+	if err := manager.AddDeploymentRecord(record); err != nil {
+		println("failed to record deployment: " + err.Error())
+	}
+}
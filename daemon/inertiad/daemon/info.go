@@ -0,0 +1,29 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
+)
+
+// infoHandler returns the versions of the daemon and of the host's Docker
+// Engine, as well as the build types the daemon supports
+func (s *Server) infoHandler(w http.ResponseWriter, r *http.Request) {
+	version, err := s.docker.ServerVersion(context.Background())
+	if err != nil {
+		http.Error(w, "unable to reach Docker: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&api.DaemonInfo{
+		InertiaVersion:   s.version,
+		DockerVersion:    version.Version,
+		DockerAPIVersion: version.APIVersion,
+		BuildTypes:       build.SupportedBuildTypes(),
+	})
+}
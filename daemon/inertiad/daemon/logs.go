@@ -2,17 +2,28 @@ package daemon
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	docker "github.com/docker/docker/client"
+	"github.com/gorilla/websocket"
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 )
 
+const (
+	// logStreamRetrySeconds is the suggested delay before retrying a log
+	// stream request that was rejected due to the stream limit
+	logStreamRetrySeconds = 10
+)
+
 // logHandler handles requests for container logs
 func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 	var (
@@ -20,9 +31,11 @@ func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 		err    error
 	)
 
-	// Get container name and stream from request query params
+	// Get container names and stream from request query params - the logs of
+	// several containers can be requested at once, either as a comma-separated
+	// list or by repeating the parameter, and are merged
 	params := r.URL.Query()
-	container := params.Get(api.Container)
+	names := logContainerNames(params[api.Container])
 	streamParam := params.Get(api.Stream)
 	if streamParam != "" {
 		s, err := strconv.ParseBool(streamParam)
@@ -36,23 +49,117 @@ func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 		stream = false
 	}
 
-	// Determine number of entries to fetch
+	// Send logs as a file to download if requested - logs are then copied to
+	// the response as they are read, rather than buffered
+	download, _ := strconv.ParseBool(params.Get(api.Download))
+	if download && stream {
+		http.Error(w, "logs cannot be both streamed and downloaded", http.StatusBadRequest)
+		return
+	}
+	if download && len(names) > 1 {
+		http.Error(w, "logs can only be downloaded one container at a time",
+			http.StatusBadRequest)
+		return
+	}
+
+	// Determine number of entries to fetch - when streaming, these are sent
+	// before new entries are followed
 	entriesParam := params.Get(api.Entries)
 	var entries int
 	if entriesParam != "" {
-		if entries, err = strconv.Atoi(entriesParam); err != nil {
+		if entries, err = strconv.Atoi(entriesParam); err != nil || entries < 0 {
 			http.Error(w, "invalid number of entries", http.StatusBadRequest)
 			return
 		}
 	}
-	if entries == 0 {
+
+	// Fetch only logs after the given cursor if one is provided - if so, all
+	// logs after the cursor are fetched unless entries is also set
+	cursor := params.Get(api.Cursor)
+	if cursor != "" {
+		if _, err := time.Parse(time.RFC3339Nano, cursor); err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Fetch only logs since the given time, which works like a cursor - if
+	// both are provided, whichever is later applies
+	since := cursor
+	if sinceParam := params.Get(api.Since); sinceParam != "" {
+		parsed, err := containers.ParseLogSince(sinceParam, time.Now())
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if since == "" || laterTimestamp(parsed, since) {
+			since = parsed
+		}
+	}
+	if since == "" && entries == 0 && !download {
 		entries = 500
 	}
 
+	// Determine which output streams to fetch - if streams are requested,
+	// each line is labelled with the stream it came from
+	streams := params.Get(api.LogStreams)
+	switch streams {
+	case "", api.LogStreamsBoth, api.LogStreamsStdout, api.LogStreamsStderr:
+	default:
+		http.Error(w, "invalid streams - must be one of stdout, stderr, or both",
+			http.StatusBadRequest)
+		return
+	}
+
+	// Determine the timezone to display timestamps in - Docker reports
+	// timestamps in UTC, so they only need converting for other timezones
+	loc, err := time.LoadLocation(params.Get(api.Timezone))
+	if err != nil {
+		http.Error(w, "invalid timezone: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if loc == time.UTC {
+		loc = nil
+	}
+
+	// Remove ANSI escape sequences, such as color codes, if requested
+	plain, _ := strconv.ParseBool(params.Get(api.Plain))
+
+	// Determine the length beyond which to truncate lines, if any
+	var maxLineLength int
+	if maxParam := params.Get(api.MaxLineLength); maxParam != "" {
+		if maxLineLength, err = strconv.Atoi(maxParam); err != nil || maxLineLength < 0 {
+			http.Error(w, "invalid maximum line length", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Serve saved logs of the previous deployment's container if requested
+	if previous, _ := strconv.ParseBool(params.Get(api.Previous)); previous {
+		if stream {
+			http.Error(w, "logs of previous containers cannot be streamed",
+				http.StatusBadRequest)
+			return
+		}
+		if len(names) > 1 {
+			http.Error(w, "logs of previous containers can only be fetched one container at a time",
+				http.StatusBadRequest)
+			return
+		}
+		s.previousLogHandler(w, names[0], since, streams, download, plain, loc, maxLineLength)
+		return
+	}
+
 	// Upgrade to websocket connection if required, otherwise just set up a
 	// standard logger
 	var logger *log.DaemonLogger
 	if stream {
+		if !s.acquireLogStream() {
+			s.rejectLogStream(w, r)
+			return
+		}
+		defer s.releaseLogStream()
+
 		socket, err := s.websocket.Upgrade(w, r, nil)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -70,20 +177,50 @@ func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	logs, err := containers.ContainerLogs(s.docker, containers.LogOptions{
-		Container: container,
+	var opts = containers.LogOptions{
 		Stream:    stream,
 		Entries:   entries,
-	})
-	if err != nil {
-		if docker.IsErrNotFound(err) {
-			logger.WriteErr(err.Error(), http.StatusNotFound)
+		Since:     since,
+		NoStdout:  streams == api.LogStreamsStderr,
+		NoStderr:  streams == api.LogStreamsStdout,
+		StripANSI: plain,
+	}
+	var logs = make([]io.ReadCloser, 0, len(names))
+	var ttys = make([]bool, len(names))
+	defer func() {
+		for _, l := range logs {
+			l.Close()
+		}
+	}()
+	for i, name := range names {
+		opts.Container = name
+		var l io.ReadCloser
+		if stream {
+			// Keep following logs if the container is restarted, so that crash
+			// loops can be diagnosed
+			l, err = containers.FollowContainerLogs(s.docker, opts)
 		} else {
-			logger.WriteErr(err.Error(), http.StatusInternalServerError)
+			l, err = containers.ContainerLogs(s.docker, opts)
+		}
+		if err != nil {
+			if docker.IsErrNotFound(err) {
+				logger.WriteErr(err.Error(), http.StatusNotFound)
+			} else {
+				logger.WriteErr(err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		logs = append(logs, l)
+
+		// Logs of containers that use a TTY can't be separated into streams
+		if streams != "" {
+			if ttys[i], err = containers.UsesTTY(s.docker, name); err != nil {
+				logger.WriteErr(err.Error(), http.StatusInternalServerError)
+				return
+			}
 		}
-		return
 	}
-	defer logs.Close()
+	var merged = len(logs) > 1
 
 	if stream {
 		var stop = make(chan struct{})
@@ -91,14 +228,318 @@ func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			logger.WriteErr(err.Error(), http.StatusInternalServerError)
 		}
-		log.FlushRoutine(socket, logs, stop)
-		defer logger.Close()
+		var reader io.Reader
+		if merged {
+			var named = make([]containers.NamedLogs, len(logs))
+			for i, l := range logs {
+				named[i] = containers.NamedLogs{
+					Name: strings.TrimPrefix(names[i], "/"),
+					Logs: formatLogs(r.Context(), l, streams, ttys[i], true, loc, maxLineLength),
+				}
+			}
+			reader = containers.FanInLogs(r.Context(), named)
+		} else {
+			reader = formatLogs(r.Context(), logs[0], streams, ttys[0], false, loc, maxLineLength)
+		}
 		defer close(stop)
+
+		// Let the client know why the stream ended - logs end when the
+		// container stops without being restarted
+		if err := log.FlushRoutine(socket, reader, stop); err != nil {
+			logger.Close(log.CloseOpts{
+				Message:    "stream ended: " + err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			})
+		} else {
+			logger.Close(log.CloseOpts{
+				Message:    api.MsgLogStreamContainerStopped,
+				StatusCode: http.StatusOK,
+			})
+		}
+	} else if download {
+		setLogDownloadHeaders(w, names[0])
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(w, formatLogs(r.Context(), logs[0], streams, ttys[0], true, loc,
+			maxLineLength)); err != nil {
+			logger.Println("failed to send logs for download: " + err.Error())
+		}
+	} else if merged {
+		// The cursor for merged logs is that of the container with the most
+		// recent logs
+		var named = make([]containers.NamedLogs, len(logs))
+		var latest = since
+		for i, l := range logs {
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(l)
+			if next := containers.NextLogCursor(buf.Bytes(), since); laterTimestamp(next, latest) {
+				latest = next
+			}
+			named[i] = containers.NamedLogs{
+				Name: strings.TrimPrefix(names[i], "/"),
+				Logs: formatLogs(r.Context(), buf, streams, ttys[i], true, loc, maxLineLength),
+			}
+		}
+		var buf = new(bytes.Buffer)
+		if err := containers.MergeLogs(named, buf); err != nil {
+			http.Error(w, "unable to merge logs: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(api.HeaderLogCursor, latest)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		w.Write(log.SanitizeUTF8(buf.Bytes()))
 	} else {
 		buf := new(bytes.Buffer)
-		buf.ReadFrom(logs)
+		buf.ReadFrom(logs[0])
+		w.Header().Set(api.HeaderLogCursor, containers.NextLogCursor(buf.Bytes(), cursor))
+		if streams != "" {
+			var demuxed = new(bytes.Buffer)
+			if err := containers.DemuxLogs(buf, demuxed, ttys[0]); err != nil {
+				http.Error(w, "unable to separate log streams: "+err.Error(),
+					http.StatusInternalServerError)
+				return
+			}
+			buf = demuxed
+		}
+		if loc != nil {
+			var converted = new(bytes.Buffer)
+			if err := containers.ConvertLogTimezone(buf, converted, loc); err != nil {
+				http.Error(w, "unable to convert log timestamps: "+err.Error(),
+					http.StatusInternalServerError)
+				return
+			}
+			buf = converted
+		}
+		if maxLineLength > 0 {
+			var truncated = new(bytes.Buffer)
+			if err := containers.TruncateLogLines(buf, truncated, maxLineLength); err != nil {
+				http.Error(w, "unable to truncate log lines: "+err.Error(),
+					http.StatusInternalServerError)
+				return
+			}
+			buf = truncated
+		}
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, buf.String())
+		w.Write(log.SanitizeUTF8(buf.Bytes()))
+	}
+}
+
+// logContainerNames returns the names of the containers listed in the given
+// values of the container query parameter, each of which may be a
+// comma-separated list. A single empty name is returned if none are listed.
+func logContainerNames(values []string) []string {
+	var names []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return []string{""}
+	}
+	return names
+}
+
+// formatLogs returns a reader of the given logs with each line labelled with
+// its output stream if streams is set and the logs are not from a container
+// that uses a TTY, as indicated by tty, with timestamps converted to loc if it
+// is not nil, and with lines truncated to maxLineLength if it is set. If
+// stripHeaders is set, logs are no longer multiplexed, so that they can be
+// merged with the logs of other containers or saved to a file. The logs are
+// no longer read once ctx is done.
+func formatLogs(ctx context.Context, logs io.Reader, streams string, tty, stripHeaders bool,
+	loc *time.Location, maxLineLength int) io.Reader {
+	var reader = logs
+	if streams != "" {
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(containers.DemuxLogs(logs, pw, tty)) }()
+		closeOnDone(ctx, pr)
+		reader = pr
+	} else if stripHeaders {
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(containers.StripStreamHeaders(logs, pw)) }()
+		closeOnDone(ctx, pr)
+		reader = pr
+	}
+	if loc != nil {
+		var source = reader
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(containers.ConvertLogTimezone(source, pw, loc)) }()
+		closeOnDone(ctx, pr)
+		reader = pr
+	}
+	if maxLineLength > 0 {
+		var source = reader
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(containers.TruncateLogLines(source, pw, maxLineLength))
+		}()
+		closeOnDone(ctx, pr)
+		reader = pr
+	}
+	return reader
+}
+
+// closeOnDone closes the given pipe reader once ctx is done, so that the
+// goroutine writing to it stops once the logs are no longer read
+func closeOnDone(ctx context.Context, pr *io.PipeReader) {
+	go func() {
+		<-ctx.Done()
+		pr.Close()
+	}()
+}
+
+// setLogDownloadHeaders sets the headers of a response with logs of the given
+// container, so that they are saved as a file rather than displayed
+func setLogDownloadHeaders(w http.ResponseWriter, container string) {
+	var name = strings.TrimPrefix(container, "/")
+	if name == "" {
+		name = "container"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".log"))
+}
+
+// laterTimestamp checks if RFC3339Nano timestamp a is after b
+func laterTimestamp(a, b string) bool {
+	ta, _ := time.Parse(time.RFC3339Nano, a)
+	tb, _ := time.Parse(time.RFC3339Nano, b)
+	return ta.After(tb)
+}
+
+// previousLogHandler responds with the saved logs of the given container from
+// before it was last replaced or stopped, limited to those since the given
+// timestamp if it is set, with timestamps converted to loc if it is not nil,
+// lines truncated to maxLineLength if it is set, and ANSI escape sequences
+// removed if plain is set, as a file if download is set
+func (s *Server) previousLogHandler(w http.ResponseWriter, container, since, streams string,
+	download, plain bool, loc *time.Location, maxLineLength int) {
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		http.Error(w, "no deployment data manager found", http.StatusPreconditionFailed)
+		return
+	}
+	logs, err := manager.GetPreviousLogs(strings.TrimPrefix(container, "/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if logs == nil {
+		http.Error(w, "no logs saved for previous container "+container, http.StatusNotFound)
+		return
+	}
+
+	var buf = bytes.NewBuffer(logs)
+
+	// The previous container can't be inspected, so check its logs instead
+	var tty = !containers.IsMultiplexed(logs)
+	if since != "" {
+		t, _ := time.Parse(time.RFC3339Nano, since)
+		var filtered = new(bytes.Buffer)
+		if err := containers.FilterLogsSince(buf, filtered, t); err != nil {
+			http.Error(w, "unable to filter logs: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		buf = filtered
+	}
+	if streams != "" {
+		var demuxed = new(bytes.Buffer)
+		if err := containers.DemuxLogs(buf, demuxed, tty); err != nil {
+			http.Error(w, "unable to separate log streams: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		buf = demuxed
+
+		// Saved logs include both streams, so filter out unwanted lines
+		if streams != api.LogStreamsBoth && !tty {
+			var filtered = new(bytes.Buffer)
+			var label = []byte("[" + streams + "] ")
+			for _, line := range bytes.SplitAfter(demuxed.Bytes(), []byte("\n")) {
+				if bytes.HasPrefix(line, label) {
+					filtered.Write(line)
+				}
+			}
+			buf = filtered
+		}
+	} else if download {
+		var stripped = new(bytes.Buffer)
+		if err := containers.StripStreamHeaders(buf, stripped); err != nil {
+			http.Error(w, "unable to read log streams: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		buf = stripped
+	}
+	if plain {
+		var stripped = new(bytes.Buffer)
+		if err := containers.StripANSI(buf, stripped); err != nil {
+			http.Error(w, "unable to remove escape sequences: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		buf = stripped
+	}
+	if loc != nil {
+		var converted = new(bytes.Buffer)
+		if err := containers.ConvertLogTimezone(buf, converted, loc); err != nil {
+			http.Error(w, "unable to convert log timestamps: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		buf = converted
+	}
+	if maxLineLength > 0 {
+		var truncated = new(bytes.Buffer)
+		if err := containers.TruncateLogLines(buf, truncated, maxLineLength); err != nil {
+			http.Error(w, "unable to truncate log lines: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		buf = truncated
+	}
+	if download {
+		setLogDownloadHeaders(w, container)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+		return
 	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	w.Write(log.SanitizeUTF8(buf.Bytes()))
+}
+
+// acquireLogStream reserves a slot for a log stream, returning false if the
+// limit on concurrent log streams has been reached. Streams are not limited
+// if no limit is configured.
+func (s *Server) acquireLogStream() bool {
+	return s.logStreams.acquire()
+}
+
+// rejectLogStream turns away a log stream request made after the limit on
+// concurrent log streams has been reached. Websocket clients are told why the
+// stream was closed, while other clients receive a 503.
+func (s *Server) rejectLogStream(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		if socket, err := s.websocket.Upgrade(w, r, nil); err == nil {
+			socket.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater,
+					api.MsgLogStreamLimitReached),
+				time.Now().Add(time.Second))
+			socket.Close()
+			return
+		}
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(logStreamRetrySeconds))
+	http.Error(w, "too many active log streams - try again later",
+		http.StatusServiceUnavailable)
+}
+
+// releaseLogStream frees a slot reserved by acquireLogStream
+func (s *Server) releaseLogStream() {
+	s.logStreams.release()
 }
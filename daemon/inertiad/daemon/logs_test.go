@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestLogHandlerStreamLimit(t *testing.T) {
+	var s = &Server{logStreams: newStreamLimit(1)}
+
+	// Occupy the only available stream
+	assert.True(t, s.acquireLogStream())
+	assert.False(t, s.acquireLogStream())
+
+	req, err := http.NewRequest("GET", "/logs?"+api.Stream+"=true", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	// Streams should be available once released
+	s.releaseLogStream()
+	assert.True(t, s.acquireLogStream())
+}
+
+func TestLogHandlerStreamLimitWebSocket(t *testing.T) {
+	var s = &Server{
+		websocket:  &websocket.Upgrader{},
+		logStreams: newStreamLimit(1),
+	}
+	assert.True(t, s.acquireLogStream())
+
+	ts := httptest.NewServer(http.HandlerFunc(s.logHandler))
+	defer ts.Close()
+
+	// Websocket clients should be told why the stream was closed
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/logs?" + api.Stream + "=true"
+	socket, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Nil(t, err)
+	defer socket.Close()
+	_, _, err = socket.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater))
+	assert.Contains(t, err.Error(), api.MsgLogStreamLimitReached)
+}
+
+func TestLogHandlerInvalidTimezone(t *testing.T) {
+	var s = &Server{}
+	req, err := http.NewRequest("GET", "/logs?"+api.Timezone+"=Not/AZone", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "invalid timezone")
+}
+
+func TestLogHandlerInvalidMaxLineLength(t *testing.T) {
+	var s = &Server{}
+	for _, length := range []string{"-1", "long"} {
+		req, err := http.NewRequest("GET", "/logs?"+api.MaxLineLength+"="+length, nil)
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "invalid maximum line length")
+	}
+}
+
+func TestLogHandlerInvalidSince(t *testing.T) {
+	var s = &Server{}
+	for _, since := range []string{"yesterday", "-10m"} {
+		req, err := http.NewRequest("GET", "/logs?"+api.Since+"="+since, nil)
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "invalid since")
+	}
+}
+
+func TestLaterTimestamp(t *testing.T) {
+	assert.True(t, laterTimestamp("2019-01-02T15:04:06Z", "2019-01-02T15:04:05.5Z"))
+	assert.False(t, laterTimestamp("2019-01-02T15:04:05Z", "2019-01-02T15:04:05.5Z"))
+}
+
+func TestLogContainerNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{"none", nil, []string{""}},
+		{"one", []string{"/web"}, []string{"/web"}},
+		{"comma-separated", []string{"web, db,"}, []string{"web", "db"}},
+		{"repeated", []string{"web", "db,cache"}, []string{"web", "db", "cache"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, logContainerNames(tt.values))
+		})
+	}
+}
+
+func TestLogHandlerPreviousMultipleContainers(t *testing.T) {
+	var s = &Server{}
+	req, err := http.NewRequest("GET",
+		"/logs?"+api.Container+"=web,db&"+api.Previous+"=true", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "one container at a time")
+}
+
+func TestLogHandlerInvalidEntries(t *testing.T) {
+	var s = &Server{}
+	for _, entries := range []string{"-1", "many"} {
+		req, err := http.NewRequest("GET", "/logs?"+api.Entries+"="+entries, nil)
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "invalid number of entries")
+	}
+}
+
+func TestLogHandlerInvalidDownload(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"streamed", api.Download + "=true&" + api.Stream + "=true", "both streamed and downloaded"},
+		{"multiple containers", api.Download + "=true&" + api.Container + "=web,db",
+			"one container at a time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Server{}
+			req, err := http.NewRequest("GET", "/logs?"+tt.query, nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.logHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestSetLogDownloadHeaders(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	setLogDownloadHeaders(recorder, "/web")
+	assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="web.log"`, recorder.Header().Get("Content-Disposition"))
+}
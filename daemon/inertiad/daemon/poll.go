@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+const (
+	// pollCheckInterval is how often the daemon checks whether it is time to
+	// poll the project's repository for new commits
+	pollCheckInterval = 15 * time.Second
+
+	// pollInitiator is recorded as the initiator of deployments triggered by
+	// polling the project's repository
+	pollInitiator = "poll"
+)
+
+// watchRepository periodically fetches the deployed branch if the project is
+// configured to poll for changes, and deploys new commits when there are any.
+// This allows continuous deployment where webhooks cannot reach the daemon.
+// Best used as a goroutine.
+func (s *Server) watchRepository(poll time.Duration) {
+	var lastPoll = time.Now()
+	for {
+		time.Sleep(poll)
+
+		// The interval is read on each run, since deployments can change it
+		var interval = time.Duration(s.config().PollIntervalMinutes) * time.Minute
+		if interval <= 0 || time.Since(lastPoll) < interval {
+			continue
+		}
+		lastPoll = time.Now()
+		s.deployNewCommits(os.Stdout)
+	}
+}
+
+// deployNewCommits fetches the deployed branch, and deploys it if its latest
+// commit is not the one that is currently deployed. Projects that are not
+// running, such as those shut down with 'inertia down', are left stopped.
+func (s *Server) deployNewCommits(out io.Writer) {
+	// Ignore polls if repository not set up yet or the project is stopped
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" ||
+		(len(status.Containers) == 0 && !status.SitePublished) {
+		return
+	}
+
+	// An empty configuration fetches the currently deployed branch
+	preview, err := s.deployment.Preview(s.docker, project.DeploymentConfig{})
+	if err != nil {
+		fmt.Fprintln(out, "Failed to poll repository: "+err.Error())
+		return
+	}
+	if preview.TargetCommit == "" || preview.TargetCommit == preview.CurrentCommit {
+		return
+	}
+	fmt.Fprintf(out, "Found new commit %s on branch %s - deploying project\n",
+		preview.TargetCommit, preview.Branch)
+	s.runDeployment(deployAttempt{
+		initiator: pollInitiator,
+		out:       out,
+	})
+}
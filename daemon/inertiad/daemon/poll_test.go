@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestDeployNewCommits(t *testing.T) {
+	var running = api.DeploymentStatus{CommitHash: "abcdefg", Containers: []string{"/web"}}
+	type args struct {
+		status     api.DeploymentStatus
+		preview    api.DeploymentPreview
+		previewErr error
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantFetched bool
+		wantDeploy  bool
+		deployErr   error
+	}{
+		{"no deployment", args{api.DeploymentStatus{},
+			api.DeploymentPreview{CurrentCommit: "abcdefg", TargetCommit: "hijklmn"}, nil}, false, false, nil},
+		{"project stopped", args{api.DeploymentStatus{CommitHash: "abcdefg"},
+			api.DeploymentPreview{CurrentCommit: "abcdefg", TargetCommit: "hijklmn"}, nil}, false, false, nil},
+		{"fetch failed", args{running,
+			api.DeploymentPreview{}, errors.New("remote unavailable")}, true, false, nil},
+		{"up to date", args{running,
+			api.DeploymentPreview{CurrentCommit: "abcdefg", TargetCommit: "abcdefg"}, nil}, true, false, nil},
+		{"new commit", args{running,
+			api.DeploymentPreview{CurrentCommit: "abcdefg", TargetCommit: "hijklmn"}, nil}, true, true, nil},
+		{"site published", args{api.DeploymentStatus{CommitHash: "abcdefg", SitePublished: true},
+			api.DeploymentPreview{CurrentCommit: "abcdefg", TargetCommit: "hijklmn"}, nil}, true, true, nil},
+		{"deploy failed", args{running,
+			api.DeploymentPreview{CurrentCommit: "abcdefg", TargetCommit: "hijklmn"}, nil}, true, true,
+			errors.New("build failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return tt.args.status, nil
+				},
+				PreviewStub: func(*docker.Client, project.DeploymentConfig) (api.DeploymentPreview, error) {
+					return tt.args.preview, tt.args.previewErr
+				},
+				DeployStub: func(*docker.Client, io.Writer, project.DeployOptions) (func() error, error) {
+					return func() error { return tt.deployErr }, nil
+				},
+			}
+			var s = &Server{deployment: fake, builds: newBuildQueue(1)}
+			events, unsubscribe := s.events.subscribe()
+			defer unsubscribe()
+
+			s.deployNewCommits(ioutil.Discard)
+			assert.Equal(t, tt.wantFetched, fake.PreviewCallCount() == 1)
+			if !tt.wantDeploy {
+				assert.Equal(t, 0, fake.DeployCallCount())
+				assert.Len(t, events, 0)
+				return
+			}
+			assert.Equal(t, 1, fake.DeployCallCount())
+			_, _, opts := fake.DeployArgsForCall(0)
+			assert.False(t, opts.SkipUpdate)
+			assert.NotNil(t, opts.Context)
+
+			var started, finished = <-events, <-events
+			assert.Equal(t, api.EventDeployStarted, started.Action)
+			assert.Equal(t, pollInitiator, started.Initiator)
+			if tt.deployErr != nil {
+				assert.Equal(t, api.EventDeployFailed, finished.Action)
+			} else {
+				assert.Equal(t, api.EventDeploySucceeded, finished.Action)
+			}
+		})
+	}
+}
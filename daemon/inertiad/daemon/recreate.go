@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+// recreateHandler recreates the container of a single service with the
+// current configuration, without redeploying the rest of the project
+func (s *Server) recreateHandler(w http.ResponseWriter, r *http.Request) {
+	var recreateReq api.RecreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&recreateReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if recreateReq.Service == "" {
+		http.Error(w, "no service provided", http.StatusBadRequest)
+		return
+	}
+
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
+		http.Error(w, msgNoDeployment, http.StatusPreconditionFailed)
+		return
+	}
+
+	var state = s.config()
+	logger := log.NewLogger(log.LoggerOptions{
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+		HTTPStream: recreateReq.Stream,
+
+		// Buffer output so that slow clients don't hold up the recreation
+		BufferSize:     state.DeployOutputBuffer,
+		BufferOverflow: log.OverflowPolicy(state.DeployOutputOverflow),
+		Interrupt:      s.conns.interrupt(r),
+	})
+	defer logger.Close()
+
+	// Allow the recreation to be cancelled until the service is stopped
+	ctx, done := s.deploys.start()
+	defer done()
+
+	// Wait for a build slot, so that the service is not recreated while
+	// another deployment is replacing containers
+	var release = s.builds.acquire(func(position int) {
+		fmt.Fprintf(logger, "Waiting for a build slot - position %d in queue\n", position)
+	})
+	defer release()
+	if ctx.Err() != nil {
+		logger.WriteErr(project.ErrDeployCancelled.Error(), http.StatusConflict)
+		return
+	}
+	done()
+
+	if err := s.deployment.Recreate(s.docker, logger, recreateReq.Service); err != nil {
+		logger.WriteErr(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.WriteSuccess("Service "+recreateReq.Service+" recreated!", http.StatusCreated)
+}
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestRecreateHandler(t *testing.T) {
+	type args struct {
+		service     string
+		status      api.DeploymentStatus
+		recreateErr error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantCode int
+	}{
+		{"no service", args{"", api.DeploymentStatus{CommitHash: "abcdefg"}, nil},
+			http.StatusBadRequest},
+		{"no deployment", args{"web", api.DeploymentStatus{}, nil},
+			http.StatusPreconditionFailed},
+		{"recreate failed", args{"web", api.DeploymentStatus{CommitHash: "abcdefg"},
+			errors.New("service not found")}, http.StatusInternalServerError},
+		{"recreated", args{"web", api.DeploymentStatus{CommitHash: "abcdefg"}, nil},
+			http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return tt.args.status, nil
+				},
+				RecreateStub: func(*docker.Client, io.Writer, string) error {
+					return tt.args.recreateErr
+				},
+			}
+			var s = &Server{deployment: fake}
+
+			body, err := json.Marshal(api.RecreateRequest{Service: tt.args.service})
+			assert.Nil(t, err)
+			req, err := http.NewRequest("POST", "/recreate", bytes.NewReader(body))
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.recreateHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.wantCode == http.StatusCreated {
+				_, _, service := fake.RecreateArgsForCall(0)
+				assert.Equal(t, "web", service)
+			}
+		})
+	}
+}
+
+func TestRecreateHandlerCancelledWhileQueued(t *testing.T) {
+	var fake = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{CommitHash: "abcdefg"}, nil
+		},
+	}
+	var s = &Server{deployment: fake, builds: newBuildQueue(1)}
+
+	// Occupy the only build slot so that the recreation is queued
+	var release = s.builds.acquire(nil)
+	var recorder = httptest.NewRecorder()
+	var handled = make(chan struct{})
+	go func() {
+		body, _ := json.Marshal(api.RecreateRequest{Service: "web"})
+		req, _ := http.NewRequest("POST", "/recreate", bytes.NewReader(body))
+		http.HandlerFunc(s.recreateHandler).ServeHTTP(recorder, req)
+		close(handled)
+	}()
+	for {
+		if _, queued := s.builds.status(); queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The service should not be recreated once the request is cancelled
+	assert.Equal(t, 1, s.deploys.cancelAll())
+	release()
+	<-handled
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, 0, fake.RecreateCallCount())
+}
@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+)
+
+// reloadConfigHandler re-reads the daemon's configuration and applies the
+// settings that can be changed while the daemon is running
+func (s *Server) reloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := s.reloadConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// reloadConfigOnHangup reloads the daemon's configuration whenever the daemon
+// receives SIGHUP. Best used as a goroutine.
+func (s *Server) reloadConfigOnHangup() {
+	var hangup = make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		result, err := s.reloadConfig()
+		if err != nil {
+			println("Failed to reload configuration: " + err.Error())
+			continue
+		}
+		fmt.Printf("Configuration reloaded - applied: [%s], requires restart: [%s]\n",
+			strings.Join(result.Applied, ", "), strings.Join(result.RequiresRestart, ", "))
+	}
+}
+
+// reloadConfig re-reads the daemon's configuration from the environment and
+// the settings file, and applies changed settings that can be applied live.
+// Active builds are waited for, and new builds are held until the reload is
+// complete, so that no deployment sees a partially applied configuration.
+func (s *Server) reloadConfig() (*api.ConfigReload, error) {
+	next, err := cfg.Load(path.Join(s.config().DataDirectory, cfg.SettingsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	s.reloadMux.Lock()
+	defer s.reloadMux.Unlock()
+	var release = s.builds.exclusive(nil)
+
+	var result = diffConfig(s.config(), *next)
+	s.updateConfig(func(state *cfg.Config) {
+		state.MinFreeDiskMB = next.MinFreeDiskMB
+		state.MaxPayloadSizeMB = next.MaxPayloadSizeMB
+		state.MaxDeployRecords = next.MaxDeployRecords
+		state.MaxDeployRecordAgeDays = next.MaxDeployRecordAgeDays
+		state.DeployOutputBuffer = next.DeployOutputBuffer
+		state.DeployOutputOverflow = next.DeployOutputOverflow
+		state.MaxConcurrentBuilds = next.MaxConcurrentBuilds
+		state.MaxLogStreams = next.MaxLogStreams
+	})
+
+	release()
+	s.builds.setLimit(next.MaxConcurrentBuilds)
+	s.logStreams.setLimit(next.MaxLogStreams)
+	return result, nil
+}
+
+// diffConfig reports which settings differ between the current and next
+// daemon configuration, and whether each can be applied live
+func diffConfig(current, next cfg.Config) *api.ConfigReload {
+	var result = &api.ConfigReload{Applied: []string{}, RequiresRestart: []string{}}
+	for _, setting := range []struct {
+		name    string
+		changed bool
+		live    bool
+	}{
+		{"INERTIA_MIN_FREE_DISK_MB", current.MinFreeDiskMB != next.MinFreeDiskMB, true},
+		{"INERTIA_MAX_CONCURRENT_BUILDS", current.MaxConcurrentBuilds != next.MaxConcurrentBuilds, true},
+		{"INERTIA_MAX_LOG_STREAMS", current.MaxLogStreams != next.MaxLogStreams, true},
+		{"INERTIA_MAX_PAYLOAD_SIZE_MB", current.MaxPayloadSizeMB != next.MaxPayloadSizeMB, true},
+		{"INERTIA_MAX_DEPLOY_RECORDS", current.MaxDeployRecords != next.MaxDeployRecords, true},
+		{"INERTIA_MAX_DEPLOY_RECORD_AGE_DAYS",
+			current.MaxDeployRecordAgeDays != next.MaxDeployRecordAgeDays, true},
+		{"INERTIA_DEPLOY_OUTPUT_BUFFER", current.DeployOutputBuffer != next.DeployOutputBuffer, true},
+		{"INERTIA_DEPLOY_OUTPUT_OVERFLOW", current.DeployOutputOverflow != next.DeployOutputOverflow, true},
+
+		// The remaining settings are used to set up the daemon
+		{"INERTIA_SECRETS_DIR", current.SecretsDirectory != next.SecretsDirectory, false},
+		{"INERTIA_DATA_DIR", current.DataDirectory != next.DataDirectory, false},
+		{"INERTIA_PROJECT_DIR", current.ProjectDirectory != next.ProjectDirectory, false},
+		{"INERTIA_DOCKERCOMPOSE", current.DockerComposeVersion != next.DockerComposeVersion, false},
+		{"INERTIA_OTLP_ENDPOINT", current.OTLPEndpoint != next.OTLPEndpoint, false},
+	} {
+		if !setting.changed {
+			continue
+		}
+		if setting.live {
+			result.Applied = append(result.Applied, setting.name)
+		} else {
+			result.RequiresRestart = append(result.RequiresRestart, setting.name)
+		}
+	}
+	return result
+}
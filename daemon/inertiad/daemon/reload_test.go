@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+)
+
+func TestDiffConfig(t *testing.T) {
+	var current = cfg.Config{MaxConcurrentBuilds: 1, MaxLogStreams: 10, DataDirectory: "/data"}
+	tests := []struct {
+		name string
+		next cfg.Config
+		want *api.ConfigReload
+	}{
+		{"no changes", current,
+			&api.ConfigReload{Applied: []string{}, RequiresRestart: []string{}}},
+		{"live change", cfg.Config{MaxConcurrentBuilds: 2, MaxLogStreams: 10, DataDirectory: "/data"},
+			&api.ConfigReload{Applied: []string{"INERTIA_MAX_CONCURRENT_BUILDS"}, RequiresRestart: []string{}}},
+		{"restart required", cfg.Config{MaxConcurrentBuilds: 1, MaxLogStreams: 5, DataDirectory: "/other"},
+			&api.ConfigReload{Applied: []string{"INERTIA_MAX_LOG_STREAMS"},
+				RequiresRestart: []string{"INERTIA_DATA_DIR"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, diffConfig(current, tt.next))
+		})
+	}
+}
+
+func TestReloadConfigHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-reload")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, cfg.SettingsFile), []byte(
+		"# daemon settings\n"+
+			"INERTIA_DATA_DIR="+dir+"\n"+
+			"INERTIA_MAX_CONCURRENT_BUILDS=3\n"+
+			"INERTIA_MAX_LOG_STREAMS=2\n"), 0644))
+
+	var state = *cfg.New()
+	state.DataDirectory = dir
+	var s = &Server{
+		state:      state,
+		builds:     newBuildQueue(state.MaxConcurrentBuilds),
+		logStreams: newStreamLimit(state.MaxLogStreams),
+	}
+
+	req, err := http.NewRequest("POST", "/config/reload", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.reloadConfigHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var result api.ConfigReload
+	assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&result))
+	assert.Equal(t, []string{"INERTIA_MAX_CONCURRENT_BUILDS", "INERTIA_MAX_LOG_STREAMS"},
+		result.Applied)
+	assert.Equal(t, []string{}, result.RequiresRestart)
+
+	assert.Equal(t, 3, s.config().MaxConcurrentBuilds)
+	assert.Equal(t, 2, s.config().MaxLogStreams)
+	assert.Equal(t, 3, s.builds.limit)
+	assert.Equal(t, 2, s.logStreams.limit)
+}
+
+func TestReloadConfigConcurrentReads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-reload")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, cfg.SettingsFile), []byte(
+		"INERTIA_DATA_DIR="+dir+"\n"+
+			"INERTIA_DEPLOY_OUTPUT_BUFFER=4096\n"), 0644))
+
+	var state = *cfg.New()
+	state.DataDirectory = dir
+	var s = &Server{state: state, builds: newBuildQueue(state.MaxConcurrentBuilds)}
+
+	// Settings should be safe to read while they are reloaded - run with -race
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.updateConfig(func(next *cfg.Config) { next.WebhookSecret = "secret" })
+			_ = s.config().DeployOutputBuffer
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := s.reloadConfig()
+		assert.Nil(t, err)
+	}
+	<-done
+	assert.Equal(t, 4096, s.config().DeployOutputBuffer)
+	assert.Equal(t, "secret", s.config().WebhookSecret)
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// deployRecordPruneInterval is how often old deployment records are
+	// removed
+	deployRecordPruneInterval = time.Hour
+)
+
+// pruneDeploymentRecords periodically removes deployment records and saved
+// deployment logs beyond the configured retention limits, so that deployment
+// history does not grow without bound. Best used as a goroutine.
+func (s *Server) pruneDeploymentRecords(interval time.Duration) {
+	for {
+		// Limits are read on each run, since they can be reloaded
+		var state = s.config()
+		var maxAge = time.Duration(state.MaxDeployRecordAgeDays) * 24 * time.Hour
+		if manager, found := s.deployment.GetDataManager(); found {
+			removed, err := manager.PruneDeploymentRecords(state.MaxDeployRecords, maxAge)
+			if err != nil {
+				println("Failed to prune deployment records: " + err.Error())
+			} else if removed > 0 {
+				fmt.Printf("Removed %d old deployment records\n", removed)
+			}
+		}
+		if state.DataDirectory != "" {
+			removed, err := pruneDeployLogs(
+				filepath.Join(state.DataDirectory, deployLogsDirectory),
+				state.MaxDeployRecords, maxAge)
+			if err != nil {
+				println("Failed to prune deployment logs: " + err.Error())
+			} else if removed > 0 {
+				fmt.Printf("Removed %d old deployment logs\n", removed)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// pruneDeployLogs removes all but the maxCount most recent deployment logs in
+// dir, as well as logs last written to more than maxAge ago. Limits of 0 are
+// ignored. The number of logs removed is returned.
+func pruneDeployLogs(dir string, maxCount int, maxAge time.Duration) (int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var logs = files[:0]
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".log") {
+			logs = append(logs, f)
+		}
+	}
+
+	// Logs are named after the time their deployments began, so sorting them
+	// by name puts the most recent deployments first
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Name() > logs[j].Name() })
+	var (
+		cutoff  = time.Now().Add(-maxAge)
+		removed = 0
+	)
+	for i, f := range logs {
+		if (maxCount > 0 && i >= maxCount) || (maxAge > 0 && f.ModTime().Before(cutoff)) {
+			if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
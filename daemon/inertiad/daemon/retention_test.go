@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+)
+
+func TestCreateDeployLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-deploy-logs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var s = &Server{state: cfg.Config{DataDirectory: dir}}
+	var started = time.Date(2019, 3, 2, 8, 22, 0, 0, time.UTC)
+	var deployLog = s.createDeployLog(started)
+	fmt.Fprintln(deployLog, "Building project...")
+	assert.Nil(t, deployLog.Close())
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, deployLogsDirectory,
+		"20190302T082200.000000000Z.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Building project...\n", string(contents))
+}
+
+func TestPruneDeployLogs(t *testing.T) {
+	type args struct {
+		maxCount int
+		maxAge   time.Duration
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{"no limits", args{0, 0}, []string{"1.log", "2.log", "3.log"}},
+		{"max count", args{2, 0}, []string{"2.log", "3.log"}},
+		{"max age", args{0, 36 * time.Hour}, []string{"2.log", "3.log"}},
+		{"both", args{2, 72 * time.Hour}, []string{"2.log", "3.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-deploy-logs")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			// Logs are written a day apart, with the most recent last
+			for i := 1; i <= 3; i++ {
+				var name = filepath.Join(dir, fmt.Sprintf("%d.log", i))
+				assert.Nil(t, ioutil.WriteFile(name, []byte("output\n"), 0600))
+				var modified = time.Now().Add(-time.Duration(3-i) * 24 * time.Hour)
+				assert.Nil(t, os.Chtimes(name, modified, modified))
+			}
+			assert.Nil(t, os.Mkdir(filepath.Join(dir, "other"), os.ModePerm))
+
+			removed, err := pruneDeployLogs(dir, tt.args.maxCount, tt.args.maxAge)
+			assert.Nil(t, err)
+			assert.Equal(t, 3-len(tt.want), removed)
+			var remaining = []string{}
+			files, err := ioutil.ReadDir(dir)
+			assert.Nil(t, err)
+			for _, f := range files {
+				if !f.IsDir() {
+					remaining = append(remaining, f.Name())
+				}
+			}
+			assert.Equal(t, tt.want, remaining)
+		})
+	}
+
+	// Missing directories have nothing to prune
+	removed, err := pruneDeployLogs("./does-not-exist", 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, removed)
+}
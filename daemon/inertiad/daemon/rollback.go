@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/auth"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+// rollbackHandler redeploys a retained deployment without rebuilding it
+func (s *Server) rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	var rollbackReq api.RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&rollbackReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
+		http.Error(w, msgNoDeployment, http.StatusPreconditionFailed)
+		return
+	}
+
+	var started = time.Now()
+	var deployLog = s.createDeployLog(started)
+	defer deployLog.Close()
+	var state = s.config()
+	logger := log.NewLogger(log.LoggerOptions{
+		Stdout:     io.MultiWriter(os.Stdout, deployLog),
+		HTTPWriter: w,
+		HTTPStream: rollbackReq.Stream,
+
+		// Buffer output so that slow clients don't hold up the deployment
+		BufferSize:     state.DeployOutputBuffer,
+		BufferOverflow: log.OverflowPolicy(state.DeployOutputOverflow),
+		Interrupt:      s.conns.interrupt(r),
+	})
+	defer logger.Close()
+
+	// Record the outcome of the rollback like any other deployment
+	var err error
+	s.events.publishDeployStarted(auth.GetRequestUser(r))
+	var trace = s.startDeployTrace(auth.GetRequestUser(r))
+	defer func() { s.recordDeployment(auth.GetRequestUser(r), started, trace, err) }()
+
+	// Allow the rollback to be cancelled until the project is started
+	ctx, done := s.deploys.start()
+	defer done()
+
+	// Wait for a build slot, so that the rollback does not replace containers
+	// while another deployment is building
+	var release = s.builds.acquire(func(position int) {
+		fmt.Fprintf(logger, "Waiting for a build slot - position %d in queue\n", position)
+	})
+	defer release()
+	if ctx.Err() != nil {
+		err = project.ErrDeployCancelled
+		logger.WriteErr(err.Error(), http.StatusConflict)
+		return
+	}
+
+	deploy, err := s.deployment.Rollback(s.docker, logger, rollbackReq.Commit)
+	done()
+	if err != nil {
+		logger.WriteErr(err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err = deploy(); err != nil {
+		logger.WriteErr(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.WriteSuccess("Project rolled back!", http.StatusCreated)
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestRollbackHandler(t *testing.T) {
+	type args struct {
+		status      api.DeploymentStatus
+		rollbackErr error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantCode int
+	}{
+		{"no deployment", args{api.DeploymentStatus{}, nil}, http.StatusPreconditionFailed},
+		{"nothing to roll back to", args{api.DeploymentStatus{CommitHash: "abcdefg"},
+			errors.New("no earlier deployment is retained")}, http.StatusPreconditionFailed},
+		{"rolled back", args{api.DeploymentStatus{CommitHash: "abcdefg"}, nil}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fake = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return tt.args.status, nil
+				},
+				RollbackStub: func(*docker.Client, io.Writer, string) (func() error, error) {
+					return func() error { return nil }, tt.args.rollbackErr
+				},
+			}
+			var s = &Server{deployment: fake}
+
+			body, err := json.Marshal(api.RollbackRequest{Commit: "hijklmn"})
+			assert.Nil(t, err)
+			req, err := http.NewRequest("POST", "/rollback", bytes.NewReader(body))
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.rollbackHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.args.status.CommitHash != "" {
+				assert.Equal(t, 1, fake.RollbackCallCount())
+				_, _, commit := fake.RollbackArgsForCall(0)
+				assert.Equal(t, "hijklmn", commit)
+			}
+		})
+	}
+}
+
+func TestRollbackHandlerCancelledWhileQueued(t *testing.T) {
+	var fake = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{CommitHash: "abcdefg"}, nil
+		},
+	}
+	var s = &Server{deployment: fake, builds: newBuildQueue(1)}
+
+	// Occupy the only build slot so that the rollback is queued
+	var release = s.builds.acquire(nil)
+	var recorder = httptest.NewRecorder()
+	var handled = make(chan struct{})
+	go func() {
+		body, _ := json.Marshal(api.RollbackRequest{Commit: "hijklmn"})
+		req, _ := http.NewRequest("POST", "/rollback", bytes.NewReader(body))
+		http.HandlerFunc(s.rollbackHandler).ServeHTTP(recorder, req)
+		close(handled)
+	}()
+	for {
+		if _, queued := s.builds.status(); queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The rollback should not proceed once it is cancelled
+	assert.Equal(t, 1, s.deploys.cancelAll())
+	release()
+	<-handled
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, 0, fake.RollbackCallCount())
+}
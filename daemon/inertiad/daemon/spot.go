@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// ec2MetadataURL is the address of the EC2 instance metadata service - see
+	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/spot-interruptions.html
+	ec2MetadataURL = "http://169.254.169.254/latest/meta-data"
+
+	// spotShutdownMargin is how long before a scheduled interruption the
+	// project is shut down, leaving the rest of the notice period for a
+	// replacement instance to take over the deployment
+	spotShutdownMargin = 20 * time.Second
+)
+
+// spotInstanceAction is the interruption notice provided by the EC2 instance
+// metadata service shortly before a spot instance is reclaimed
+type spotInstanceAction struct {
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+}
+
+// watchSpotInterruption polls the instance metadata service at metadataURL
+// for spot instance interruption notices. Once one is received, the scheduled
+// interruption is reported in the deployment status and the events feed, so
+// that 'inertia provision failover' can replace the instance, and the
+// deployment is gracefully shut down shortly before the interruption. It
+// returns immediately if the daemon is not running on a spot instance. Best
+// used as a goroutine.
+func (s *Server) watchSpotInterruption(metadataURL string, interval time.Duration) {
+	var client = &http.Client{Timeout: 2 * time.Second}
+
+	// Only spot instances receive interruption notices
+	lifecycle, err := getMetadata(client, metadataURL+"/instance-life-cycle")
+	if err != nil || lifecycle != "spot" {
+		return
+	}
+	println("Spot instance detected - watching for interruption notices")
+
+	for {
+		time.Sleep(interval)
+
+		// The instance action is not found until an interruption is scheduled
+		notice, err := getMetadata(client, metadataURL+"/spot/instance-action")
+		if err != nil || notice == "" {
+			continue
+		}
+		var action spotInstanceAction
+		if err := json.Unmarshal([]byte(notice), &action); err != nil {
+			println("Unable to read spot interruption notice: " + err.Error())
+			continue
+		}
+
+		fmt.Printf("Spot instance interruption scheduled: instance will %s at %s\n",
+			action.Action, action.Time.Format(time.RFC3339))
+		s.setSpotInterruption(action.Time)
+		s.events.publish(api.Event{
+			Type:   api.EventTypeSpot,
+			Action: api.EventSpotInterruption,
+		})
+
+		// Keep serving while a replacement is prepared
+		time.Sleep(time.Until(action.Time.Add(-spotShutdownMargin)))
+		println("Shutting down project before interruption...")
+		if err := s.deployment.Down(s.docker, os.Stdout); err != nil {
+			println("Failed to shut down project: " + err.Error())
+		}
+		return
+	}
+}
+
+// setSpotInterruption records when the instance is scheduled to be interrupted
+func (s *Server) setSpotInterruption(at time.Time) {
+	s.spotMux.Lock()
+	s.spotInterruption = &at
+	s.spotMux.Unlock()
+}
+
+// getSpotInterruption returns when the instance is scheduled to be
+// interrupted, or nil if no interruption notice has been received
+func (s *Server) getSpotInterruption() *time.Time {
+	s.spotMux.Lock()
+	defer s.spotMux.Unlock()
+	return s.spotInterruption
+}
+
+// getMetadata retrieves the instance metadata at url, returning an empty
+// string if it is not found
+func getMetadata(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected metadata response status %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
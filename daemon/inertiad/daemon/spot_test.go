@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestWatchSpotInterruption(t *testing.T) {
+	type args struct {
+		lifecycle string
+		action    string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantDown bool
+	}{
+		{"not a spot instance", args{"normal", ""}, false},
+		{"interruption scheduled", args{
+			"spot", `{"action": "terminate", "time": "2017-09-18T08:22:00Z"}`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/instance-life-cycle":
+					w.Write([]byte(tt.args.lifecycle))
+				case "/spot/instance-action":
+					w.Write([]byte(tt.args.action))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer ts.Close()
+
+			var fake = &mocks.FakeDeployer{
+				DownStub: func(*docker.Client, io.Writer) error { return nil },
+			}
+			var s = &Server{deployment: fake}
+			events, unsubscribe := s.events.subscribe()
+			defer unsubscribe()
+
+			s.watchSpotInterruption(ts.URL, time.Millisecond)
+			assert.Equal(t, tt.wantDown, fake.DownCallCount() == 1)
+			if tt.wantDown {
+				// The interruption should be reported for failover
+				assert.NotNil(t, s.getSpotInterruption())
+				assert.Equal(t, "2017-09-18T08:22:00Z", s.getSpotInterruption().Format(time.RFC3339))
+				event := <-events
+				assert.Equal(t, api.EventTypeSpot, event.Type)
+				assert.Equal(t, api.EventSpotInterruption, event.Action)
+			} else {
+				assert.Nil(t, s.getSpotInterruption())
+			}
+		})
+	}
+}
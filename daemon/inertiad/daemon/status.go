@@ -24,6 +24,8 @@ func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 			InertiaVersion: s.version,
 			Containers:     make([]string, 0),
 		}
+		status.ActiveBuilds, status.QueuedBuilds = s.builds.status()
+		status.SpotInterruption = s.getSpotInterruption()
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(status)
@@ -35,6 +37,8 @@ func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	status.InertiaVersion = s.version
+	status.ActiveBuilds, status.QueuedBuilds = s.builds.status()
+	status.SpotInterruption = s.getSpotInterruption()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,54 @@
+package daemon
+
+import "sync"
+
+// streamLimit limits the number of concurrent log streams. Unlike builds,
+// streams that exceed the limit are turned away rather than queued.
+type streamLimit struct {
+	mux    sync.Mutex
+	limit  int
+	active int
+}
+
+// newStreamLimit creates a limit that allows the given number of concurrent
+// streams
+func newStreamLimit(limit int) *streamLimit {
+	return &streamLimit{limit: limit}
+}
+
+// acquire reserves a stream slot, returning false if the limit has been
+// reached. Streams are not limited if the limit is nil.
+func (l *streamLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.active >= l.limit {
+		return false
+	}
+	l.active++
+	return true
+}
+
+// release frees a slot reserved by acquire
+func (l *streamLimit) release() {
+	if l == nil {
+		return
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.active--
+}
+
+// setLimit changes the number of concurrent streams allowed. Active streams
+// are left open if it is lowered, but new streams are turned away until enough
+// of them have ended.
+func (l *streamLimit) setLimit(limit int) {
+	if l == nil {
+		return
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.limit = limit
+}
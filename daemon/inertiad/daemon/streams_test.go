@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamLimit(t *testing.T) {
+	var l = newStreamLimit(1)
+	assert.True(t, l.acquire())
+	assert.False(t, l.acquire())
+
+	// Raising the limit should allow more streams right away
+	l.setLimit(2)
+	assert.True(t, l.acquire())
+	assert.False(t, l.acquire())
+
+	// Lowering the limit should leave active streams open, and turn away new
+	// streams until enough have ended
+	l.setLimit(1)
+	l.release()
+	assert.False(t, l.acquire())
+	l.release()
+	assert.True(t, l.acquire())
+}
+
+func TestStreamLimitNil(t *testing.T) {
+	var l *streamLimit
+	assert.True(t, l.acquire())
+	l.release()
+	l.setLimit(1)
+}
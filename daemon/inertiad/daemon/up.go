@@ -1,12 +1,19 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/auth"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
@@ -14,85 +21,239 @@ import (
 
 // upHandler tries to bring the deployment online
 func (s *Server) upHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusLengthRequired)
-		return
-	}
-	defer r.Body.Close()
 	var upReq api.UpRequest
-	if err = json.Unmarshal(body, &upReq); err != nil {
+	var state = s.config()
+	var body = http.MaxBytesReader(w, r.Body, state.MaxPayloadSize())
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(&upReq); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	var gitOpts = upReq.GitOptions
+	if name, ok := invalidName(upReq.Env); ok {
+		http.Error(w, fmt.Sprintf("invalid environment variable name %q", name),
+			http.StatusBadRequest)
+		return
+	}
+	if name, ok := invalidName(upReq.BuildArgs); ok {
+		http.Error(w, fmt.Sprintf("invalid build argument name %q", name),
+			http.StatusBadRequest)
+		return
+	}
 
-	// apply configuration updates
-	s.state.WebhookSecret = upReq.WebHookSecret
-	s.deployment.SetConfig(project.DeploymentConfig{
-		ProjectName:   upReq.Project,
-		BuildType:     upReq.BuildType,
-		BuildFilePath: upReq.BuildFilePath,
-		RemoteURL:     gitOpts.RemoteURL,
-		Branch:        gitOpts.Branch,
-	})
+	var conf = project.DeploymentConfig{
+		ProjectName:        upReq.Project,
+		BuildType:          upReq.BuildType,
+		BuildFilePath:      upReq.BuildFilePath,
+		RemoteURL:          gitOpts.RemoteURL,
+		Branch:             gitOpts.Branch,
+		PemFilePath:        crypto.DaemonGithubKeyLocation,
+		ContainerUser:      upReq.ContainerUser,
+		EnforceNonRootUser: upReq.EnforceNonRootUser,
+		Platform:           upReq.Platform,
+		BuildTarget:        upReq.BuildTarget,
+		BuildArgs:          upReq.BuildArgs,
+		InitJob:            upReq.InitJob,
+		Labels:             upReq.Labels,
+		Resources:          upReq.Resources,
+		Networking:         upReq.Networking,
+		Healthchecks:       upReq.Healthchecks,
+		Commands:           upReq.Commands,
+		Readiness:          upReq.Readiness,
+		Buildx:             upReq.Buildx,
+		DependsOn:          upReq.DependsOn,
+		Static:             upReq.Static,
+		CleanBuild:         upReq.CleanBuild,
+		CleanExclude:       upReq.CleanExclude,
+		RetainedDeploys:    upReq.RetainedDeploys,
+		StopTimeout:        time.Duration(upReq.StopTimeout) * time.Second,
+		VerifyImagesKey:    upReq.VerifyImagesKey,
+		Env:                upReq.Env,
+	}
+
+	// Report what would change without deploying if requested
+	if upReq.DryRun {
+		s.previewDeployment(w, conf)
+		return
+	}
+
+	var (
+		logger         *log.DaemonLogger
+		manager        *project.DeploymentDataManager
+		deployedConfig project.DeployedConfig
+		found          bool
+	)
+	s.runDeployment(deployAttempt{
+		initiator: auth.GetRequestUser(r),
+
+		// Configure logger, saving output to the deployment's log
+		output: func(deployLog io.Writer) io.Writer {
+			logger = log.NewLogger(log.LoggerOptions{
+				Stdout:     io.MultiWriter(os.Stdout, deployLog),
+				HTTPWriter: w,
+				HTTPStream: upReq.Stream,
+
+				// Buffer output so that slow clients don't hold up the deployment
+				BufferSize:     state.DeployOutputBuffer,
+				BufferOverflow: log.OverflowPolicy(state.DeployOutputOverflow),
+				Interrupt:      s.conns.interrupt(r),
+			})
+			return logger
+		},
+
+		options: func(_ context.Context, trace *common.Span) (project.DeployOptions, error) {
+			// Apply configuration updates
+			s.updateConfig(func(next *cfg.Config) {
+				next.WebhookSecret = upReq.WebHookSecret
+				next.WatchPaths = upReq.WatchPaths
+				next.BaseImageCheckHours = upReq.BaseImageCheckHours
+				next.PollIntervalMinutes = upReq.PollIntervalMinutes
+				next.Alerts = upReq.Alerts
+			})
+			s.deployment.SetConfig(conf)
+
+			// Check for existing git repository, clone if no git repository
+			// exists. A repository may exist without any project containers
+			// running, such as when a previous build failed, in which case the
+			// project is rebuilt from the existing repository.
+			var skipUpdate = false
+			var status, _ = s.deployment.GetStatus(s.docker)
+			switch {
+			case status.CommitHash == "":
+				logger.Println("No deployment detected")
+				var clone = trace.Child("clone")
+				var err = s.deployment.Initialize(conf, logger)
+				clone.End(err)
+				if err != nil {
+					return project.DeployOptions{}, preconditionError{err}
+				}
+
+				// Project was just pulled! No need to update again.
+				skipUpdate = true
+			case len(status.Containers) == 0 && !status.BuildContainerActive &&
+				!status.SitePublished:
+				logger.Println("Project is not running - rebuilding from existing repository")
+			}
+
+			// Check for matching remotes
+			if err := s.deployment.CompareRemotes(gitOpts.RemoteURL); err != nil {
+				return project.DeployOptions{}, preconditionError{err}
+			}
+
+			// Report configuration changes - these are applied by this
+			// deployment even if the deployed commit has not changed
+			if manager, found = s.deployment.GetDataManager(); found {
+				env, _ := manager.GetEnvVariables(true)
+				deployedConfig = project.NewDeployedConfig(conf, env)
+				reportConfigChanges(manager, deployedConfig, logger)
+			}
+			return project.DeployOptions{
+				SkipUpdate: skipUpdate,
+				Release:    upReq.Release,
+				Commit:     gitOpts.Commit,
+			}, nil
+		},
+
+		// Stop waiting for the project to be ready if the client disconnects
+		starting: func(cancel func()) func() {
+			return cancelOnDisconnect(r, cancel)
+		},
 
-	// Configure logger
-	logger := log.NewLogger(log.LoggerOptions{
-		Stdout:     os.Stdout,
-		HTTPWriter: w,
-		HTTPStream: upReq.Stream,
+		finish: func(_ io.Writer, err error) {
+			defer logger.Close()
+			if err != nil {
+				logger.WriteErr(err.Error(), deployErrorStatus(err))
+				return
+			}
+			if found {
+				if err := manager.SetDeployedConfig(deployedConfig); err != nil {
+					logger.Println("Failed to save deployed configuration: " + err.Error())
+				}
+			}
+			logger.WriteSuccess("Project startup initiated!", http.StatusCreated)
+		},
 	})
-	defer logger.Close()
-
-	// Check for existing git repository, clone if no git repository exists.
-	var skipUpdate = false
-	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
-		logger.Println("No deployment detected")
-		if err = s.deployment.Initialize(
-			project.DeploymentConfig{
-				ProjectName:   upReq.Project,
-				BuildType:     upReq.BuildType,
-				BuildFilePath: upReq.BuildFilePath,
-				RemoteURL:     gitOpts.RemoteURL,
-				Branch:        gitOpts.Branch,
-				PemFilePath:   crypto.DaemonGithubKeyLocation,
-			},
-			logger,
-		); err != nil {
-			logger.WriteErr(err.Error(), http.StatusPreconditionFailed)
-			return
-		}
+}
 
-		// Project was just pulled! No need to update again.
-		skipUpdate = true
+// preconditionError is returned when the project's repository is not in a
+// state that can be deployed
+type preconditionError struct{ error }
+
+// deployErrorStatus returns the HTTP status code that reports the given
+// deployment failure
+func deployErrorStatus(err error) int {
+	switch err.(type) {
+	case preconditionError:
+		return http.StatusPreconditionFailed
+	case *project.InsufficientDiskSpaceError:
+		return http.StatusInsufficientStorage
+	}
+	if err == project.ErrDeployCancelled {
+		return http.StatusConflict
 	}
+	return http.StatusInternalServerError
+}
 
-	// Check for matching remotes
-	if err = s.deployment.CompareRemotes(gitOpts.RemoteURL); err != nil {
-		logger.WriteErr(err.Error(), http.StatusPreconditionFailed)
+// previewDeployment responds with a summary of the changes deploying the
+// given configuration would make
+func (s *Server) previewDeployment(w http.ResponseWriter, conf project.DeploymentConfig) {
+	preview, err := s.deployment.Preview(s.docker, conf)
+	if err != nil {
+		http.Error(w, "failed to preview deployment: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Change deployment parameters if necessary
-	s.deployment.SetConfig(project.DeploymentConfig{
-		ProjectName: upReq.Project,
-		Branch:      gitOpts.Branch,
-	})
+	// Compare against the configuration of the previous deployment
+	preview.ConfigChanges = []string{}
+	if manager, found := s.deployment.GetDataManager(); found {
+		previous, err := manager.GetDeployedConfig()
+		if err != nil {
+			http.Error(w, "failed to retrieve previous configuration: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		if previous != nil {
+			env, _ := manager.GetEnvVariables(true)
+			preview.ConfigChanges = project.NewDeployedConfig(conf, env).Changes(*previous)
+		}
+	}
 
-	// Deploy project
-	deploy, err := s.deployment.Deploy(s.docker, logger, project.DeployOptions{
-		SkipUpdate: skipUpdate,
-	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(preview)
+}
+
+// reportConfigChanges writes the differences between the given configuration
+// and the configuration of the previous deployment to out
+func reportConfigChanges(manager *project.DeploymentDataManager,
+	current project.DeployedConfig, out io.Writer) {
+	previous, err := manager.GetDeployedConfig()
 	if err != nil {
-		logger.WriteErr(err.Error(), http.StatusInternalServerError)
+		fmt.Fprintln(out, "Unable to retrieve previous configuration: "+err.Error())
+		return
+	}
+	if previous == nil {
 		return
 	}
 
-	if err = deploy(); err != nil {
-		logger.WriteErr(err.Error(), http.StatusInternalServerError)
+	var changes = current.Changes(*previous)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No configuration changes since the last deployment")
 		return
 	}
+	fmt.Fprintln(out, "Configuration changes since the last deployment will be applied:")
+	for _, change := range changes {
+		fmt.Fprintln(out, " - "+change)
+	}
+}
 
-	logger.WriteSuccess("Project startup initiated!", http.StatusCreated)
+// invalidName returns the first name of the given variables that is empty or
+// contains '=' or whitespace, if there is one
+func invalidName(vars map[string]string) (string, bool) {
+	for name := range vars {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return name, true
+		}
+	}
+	return "", false
 }
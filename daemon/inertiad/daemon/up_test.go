@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestUpHandlerWaitsForBuildSlot(t *testing.T) {
+	var fake = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{CommitHash: "abcdefg"}, nil
+		},
+		DeployStub: func(*docker.Client, io.Writer, project.DeployOptions) (func() error, error) {
+			return func() error { return nil }, nil
+		},
+	}
+	var s = &Server{deployment: fake, builds: newBuildQueue(1)}
+
+	// Occupy the only build slot so that the deployment is queued
+	var release = s.builds.acquire(nil)
+	var recorder = httptest.NewRecorder()
+	var handled = make(chan struct{})
+	go func() {
+		body, _ := json.Marshal(api.UpRequest{Project: "wow"})
+		req, _ := http.NewRequest("POST", "/up", bytes.NewReader(body))
+		http.HandlerFunc(s.upHandler).ServeHTTP(recorder, req)
+		close(handled)
+	}()
+	for {
+		if _, queued := s.builds.status(); queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Configuration should only be applied once the deployment has a slot
+	assert.Equal(t, 0, fake.SetConfigCallCount())
+	release()
+	<-handled
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, 1, fake.SetConfigCallCount())
+	assert.Equal(t, 1, fake.DeployCallCount())
+}
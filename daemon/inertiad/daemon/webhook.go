@@ -9,41 +9,48 @@ import (
 
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/common"
-	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/webhook"
 )
 
+const (
+	msgNoRelevantChanges = "no relevant changes"
+)
+
 // webhookHandler receives and parses Git-based webhooks
 // Supported vendors: Github, Gitlab, Bitbucket
 // Supported events: push
 func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
-	// read
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
+	// check type
+	host, event := webhook.Type(r.Header)
+	var state = s.config()
+	if state.WebhookSecret == "" {
+		println("warning: no webhook secret is set up yet! set one in inertia.toml and run inertia [remote] up")
+	}
+
+	// retrieve payload, verifying it as it is read so that large payloads
+	// are never held in memory in full
+	var body = http.MaxBytesReader(w, r.Body, state.MaxPayloadSize())
+	defer body.Close()
+	reader, verify := webhook.NewVerifier(host, state.WebhookSecret, r.Header, body)
+	payload, parseErr := webhook.Parse(host, event, r.Header, reader)
+
+	// read the rest of the payload, so that all of it is verified
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
 		msg := "unable to read payload: " + err.Error()
 		http.Error(w, msg, http.StatusBadRequest)
 		println(msg)
 		return
 	}
 
-	// check type
-	host, event := webhook.Type(r.Header)
-
 	// ensure validity
-	if s.state.WebhookSecret == "" {
-		println("warning: no webhook secret is set up yet! set one in inertia.toml and run inertia [remote] up")
-	}
-	if err := webhook.Verify(host, s.state.WebhookSecret, r.Header, body); err != nil {
+	if err := verify(); err != nil {
 		msg := "unable to verify payload: " + err.Error()
 		http.Error(w, msg, http.StatusBadRequest)
 		println(msg)
 		return
 	}
-
-	// retrieve payload
-	payload, err := webhook.Parse(host, event, r.Header, body)
-	if err != nil {
-		msg := "unable to parse payload: " + err.Error()
+	if parseErr != nil {
+		msg := "unable to parse payload: " + parseErr.Error()
 		http.Error(w, msg, http.StatusBadRequest)
 		println(msg)
 		return
@@ -52,6 +59,11 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	// process event
 	switch event := payload.GetEventType(); event {
 	case webhook.PushEvent:
+		if !webhook.ChangesMatch(payload.GetChangedFiles(), state.WatchPaths) {
+			fmt.Fprint(w, msgNoRelevantChanges)
+			fmt.Printf("Ignoring %s push event: %s\n", payload.GetSource(), msgNoRelevantChanges)
+			return
+		}
 		fmt.Fprint(w, api.MsgDaemonOK)
 		processPushEvent(s, payload, os.Stdout)
 	// case webhook.PullEvent:
@@ -103,13 +115,8 @@ func processPushEvent(s *Server, p webhook.Payload, out io.Writer) {
 	// If branches match, deploy
 	fmt.Fprintf(out, "Accepting event: event branch %s matches deployed branch %s\n",
 		branch, s.deployment.GetBranch())
-	deploy, err := s.deployment.Deploy(s.docker, os.Stdout, project.DeployOptions{})
-	if err != nil {
-		fmt.Fprintln(out, "Build failed: "+err.Error())
-		return
-	}
-
-	if err = deploy(); err != nil {
-		fmt.Fprintln(out, "Deploy failed: "+err.Error())
-	}
+	s.runDeployment(deployAttempt{
+		initiator: p.GetSource() + " webhook",
+		out:       out,
+	})
 }
@@ -0,0 +1,126 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+)
+
+// CleanRepository removes all files in the repository's working tree that are
+// not tracked, including ignored files - the equivalent of 'git clean -fdx'.
+// Paths matching an exclude pattern, and everything within them, are kept.
+// Patterns are matched against each path relative to the repository root, as
+// well as against the path's base name.
+func CleanRepository(repo *gogit.Repository, opts RepoOptions, exclude []string, out io.Writer) error {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("failed to read repository index: %s", err.Error())
+	}
+
+	// Track tracked files, and directories containing tracked files
+	var (
+		tracked     = make(map[string]bool, len(idx.Entries))
+		trackedDirs = make(map[string]bool)
+	)
+	for _, e := range idx.Entries {
+		var name = filepath.FromSlash(e.Name)
+		tracked[name] = true
+		for dir := filepath.Dir(name); dir != "."; dir = filepath.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	fmt.Fprintln(out, "Cleaning untracked files from repository...")
+	var (
+		root = filepath.Clean(opts.Directory)
+
+		// untracked directories that might contain excluded files are
+		// cleaned file by file, then removed afterwards if empty
+		emptied = []string{}
+	)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if rel == ".git" || isExcluded(rel, exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if trackedDirs[rel] {
+				return nil
+			}
+			if mayContainExcluded(rel, exclude) {
+				emptied = append(emptied, path)
+				return nil
+			}
+			fmt.Fprintf(out, "Removing %s%c\n", rel, filepath.Separator)
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if !tracked[rel] {
+			fmt.Fprintf(out, "Removing %s\n", rel)
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove innermost directories first - directories that still contain
+	// excluded files are kept
+	for i := len(emptied) - 1; i >= 0; i-- {
+		os.Remove(emptied[i])
+	}
+	return nil
+}
+
+// mayContainExcluded checks if files within the given relative directory
+// could match any of the given patterns
+func mayContainExcluded(rel string, patterns []string) bool {
+	var prefix = filepath.ToSlash(rel) + "/"
+	for _, p := range patterns {
+		p = strings.TrimPrefix(p, "./")
+		// Patterns without a directory can match base names anywhere
+		if !strings.Contains(strings.TrimSuffix(p, "/"), "/") || strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded checks if the given relative path matches any of the given
+// patterns, either in full or by its base name
+func isExcluded(rel string, patterns []string) bool {
+	var slashed = filepath.ToSlash(rel)
+	for _, p := range patterns {
+		p = strings.TrimSuffix(strings.TrimPrefix(p, "./"), "/")
+		if p == "" {
+			continue
+		}
+		if match, _ := filepath.Match(p, slashed); match {
+			return true
+		}
+		if match, _ := filepath.Match(p, filepath.Base(rel)); match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	git "gopkg.in/src-d/go-git.v4"
+)
+
+func TestCleanRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-clean")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	repo, err := git.PlainInit(dir, false)
+	assert.Nil(t, err)
+
+	// Set up tracked and untracked files
+	var files = []string{
+		"tracked.txt", "src/tracked.go",
+		"untracked.txt", "src/generated.go", "build/out.bin",
+		".env", "data/db/keep.db", "cache/tmp/.env",
+	}
+	for _, f := range files {
+		var path = filepath.Join(dir, f)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(f), 0644))
+	}
+	tree, err := repo.Worktree()
+	assert.Nil(t, err)
+	_, err = tree.Add("tracked.txt")
+	assert.Nil(t, err)
+	_, err = tree.Add("src/tracked.go")
+	assert.Nil(t, err)
+
+	err = CleanRepository(repo, RepoOptions{Directory: dir}, []string{".env", "data/db"}, ioutil.Discard)
+	assert.Nil(t, err)
+
+	var exists = func(f string) bool {
+		_, err := os.Stat(filepath.Join(dir, f))
+		return err == nil
+	}
+	for _, f := range []string{"tracked.txt", "src/tracked.go", ".env", "data/db/keep.db", "cache/tmp/.env", ".git"} {
+		assert.True(t, exists(f), f)
+	}
+	for _, f := range []string{"untracked.txt", "src/generated.go", "build"} {
+		assert.False(t, exists(f), f)
+	}
+}
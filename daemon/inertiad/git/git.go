@@ -6,9 +6,11 @@ import (
 	"io"
 	"strings"
 
+	"github.com/ubclaunchpad/inertia/common"
 	gogit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
@@ -49,6 +51,29 @@ func InitializeRepository(remoteURL string, opts RepoOptions, w io.Writer) (*gog
 	return repo, nil
 }
 
+// OpenRepository opens an existing project repository in the given
+// directory, such as one left behind by a failed first deployment. It returns
+// an error if there is no repository, if it has not been set up with the given
+// remote, or if nothing has been checked out yet.
+func OpenRepository(remoteURL string, opts RepoOptions) (*gogit.Repository, error) {
+	repo, err := gogit.PlainOpen(opts.Directory)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return nil, err
+	}
+	var urls = remote.Config().URLs
+	if len(urls) == 0 || common.GetSSHRemoteURL(urls[0]) != common.GetSSHRemoteURL(remoteURL) {
+		return nil, errors.New("existing repository has a different remote")
+	}
+	if _, err = repo.Head(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
 // clone wraps gogit.PlainClone() and returns a more helpful error message
 // if the given error is an authentication-related error.
 func clone(remoteURL string, opts RepoOptions, out io.Writer) (*gogit.Repository, error) {
@@ -123,3 +148,40 @@ func UpdateRepository(repo *gogit.Repository, opts RepoOptions, out io.Writer) e
 	})
 	return SimplifyGitErr(err)
 }
+
+// FetchBranchHead fetches the given branch from origin without modifying any
+// local branches or the working tree, and returns the commit at its head
+func FetchBranchHead(repo *gogit.Repository, opts RepoOptions) (*object.Commit, error) {
+	var remoteRef = plumbing.ReferenceName("refs/remotes/origin/" + opts.Branch)
+	err := repo.Fetch(&gogit.FetchOptions{
+		RemoteName: "origin",
+		Auth:       opts.Auth,
+		RefSpecs: []config.RefSpec{
+			config.RefSpec(fmt.Sprintf("+refs/heads/%s:%s", opts.Branch, remoteRef)),
+		},
+		Force: true,
+	})
+	if err = SimplifyGitErr(err); err != nil {
+		return nil, err
+	}
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find branch '%s': %s", opts.Branch, err.Error())
+	}
+	return repo.CommitObject(ref.Hash())
+}
+
+// CheckoutCommit checks out the given commit, detaching the working tree from
+// its branch until the next update
+func CheckoutCommit(repo *gogit.Repository, hash string, out io.Writer) error {
+	tree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Checking out commit '%s'...\n", hash)
+	return tree.Checkout(&gogit.CheckoutOptions{
+		Hash:  plumbing.NewHash(hash),
+		Force: true,
+	})
+}
@@ -1,11 +1,16 @@
 package git
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 const (
@@ -48,3 +53,47 @@ func TestUpdateRepositoryIntegration(t *testing.T) {
 	err = UpdateRepository(repo, RepoOptions{Branch: "dev"}, os.Stdout)
 	assert.Nil(t, err)
 }
+
+func TestOpenRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-open")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var opts = RepoOptions{Directory: dir}
+
+	// No repository
+	_, err = OpenRepository(inertiaDeployTest, opts)
+	assert.NotNil(t, err)
+
+	// No remote
+	repo, err := git.PlainInit(dir, false)
+	assert.Nil(t, err)
+	_, err = OpenRepository(inertiaDeployTest, opts)
+	assert.NotNil(t, err)
+
+	// Nothing checked out
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"git@github.com:ubclaunchpad/inertia-deploy-test.git"},
+	})
+	assert.Nil(t, err)
+	_, err = OpenRepository(inertiaDeployTest, opts)
+	assert.NotNil(t, err)
+
+	// Remotes are compared regardless of protocol
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("hi"), 0644))
+	tree, err := repo.Worktree()
+	assert.Nil(t, err)
+	_, err = tree.Add("README.md")
+	assert.Nil(t, err)
+	_, err = tree.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "bob", Email: "bob@inertia.com", When: time.Now()},
+	})
+	assert.Nil(t, err)
+	opened, err := OpenRepository(inertiaDeployTest, opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, opened)
+
+	// Different remote
+	_, err = OpenRepository("https://github.com/ubclaunchpad/inertia.git", opts)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy determines what a BufferedWriter does when its buffer is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest discards the oldest buffered output to make room for
+	// new output
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+
+	// OverflowDetach stops writing to the underlying writer altogether
+	OverflowDetach OverflowPolicy = "detach"
+)
+
+// DefaultFlushTimeout is how long BufferedWriter.Close waits for buffered
+// output to be written before giving up
+const DefaultFlushTimeout = 10 * time.Second
+
+// ErrFlushTimeout is returned by BufferedWriter.Close if buffered output could
+// not be written in time
+var ErrFlushTimeout = errors.New("timed out waiting for buffered output to be written")
+
+// BufferedWriter is an io.Writer that queues writes to be written to an
+// underlying writer in the background, so that a slow writer, such as a
+// stalled client, never blocks the caller. Writes never fail - when the
+// buffer is full, output is handled according to the writer's
+// OverflowPolicy.
+type BufferedWriter struct {
+	w         io.Writer
+	policy    OverflowPolicy
+	timeout   time.Duration
+	interrupt func()
+
+	queue chan []byte
+	done  chan struct{}
+
+	mux      sync.Mutex
+	closed   bool
+	detached bool
+	dropped  int
+}
+
+// NewBufferedWriter creates a BufferedWriter that buffers up to size writes
+// to w, and starts writing them in the background. OverflowDropOldest is used
+// if policy is unrecognized. If set, interrupt must cause a blocked write to w
+// to return - it is used to stop writing if output cannot be flushed in time.
+func NewBufferedWriter(w io.Writer, size int, policy OverflowPolicy, interrupt func()) *BufferedWriter {
+	if size < 1 {
+		size = 1
+	}
+	b := &BufferedWriter{
+		w:         w,
+		policy:    policy,
+		timeout:   DefaultFlushTimeout,
+		interrupt: interrupt,
+		queue:     make(chan []byte, size),
+		done:      make(chan struct{}),
+	}
+	go b.drain()
+	return b
+}
+
+func (b *BufferedWriter) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed || b.detached {
+		return len(p), nil
+	}
+
+	// Copy since callers may reuse p
+	var line = append([]byte{}, p...)
+	select {
+	case b.queue <- line:
+		return len(p), nil
+	default:
+	}
+
+	if b.policy == OverflowDetach {
+		b.detached = true
+		return len(p), nil
+	}
+
+	// Make room by discarding the oldest buffered output - the drain routine
+	// may have made room in the meantime, so don't block on either operation
+	select {
+	case <-b.queue:
+		b.dropped++
+	default:
+	}
+	select {
+	case b.queue <- line:
+	default:
+		b.dropped++
+	}
+	return len(p), nil
+}
+
+// Detached indicates whether the writer has stopped writing to its underlying
+// writer because it was too slow
+func (b *BufferedWriter) Detached() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.detached
+}
+
+// Close stops accepting writes and waits for buffered output to be written.
+// If this does not happen within the flush timeout, the writer is detached,
+// any blocked write is interrupted, and ErrFlushTimeout is returned once the
+// underlying writer is no longer in use. Without an interrupt, the underlying
+// writer may still be in use by a blocked write, and should not be used.
+func (b *BufferedWriter) Close() error {
+	b.mux.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mux.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-time.After(b.timeout):
+		b.mux.Lock()
+		b.detached = true
+		b.mux.Unlock()
+		if b.interrupt != nil {
+			b.interrupt()
+			<-b.done
+		}
+		return ErrFlushTimeout
+	}
+}
+
+// drain writes queued output to the underlying writer until the writer is
+// closed, flushing after each write if possible
+func (b *BufferedWriter) drain() {
+	defer close(b.done)
+	for line := range b.queue {
+		b.mux.Lock()
+		var detached, dropped = b.detached, b.dropped
+		b.dropped = 0
+		b.mux.Unlock()
+		if detached {
+			continue
+		}
+
+		if dropped > 0 {
+			fmt.Fprintf(b.w, "[%d writes of output dropped - client is reading too slowly]\n", dropped)
+		}
+		b.w.Write(line)
+		if f, ok := b.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
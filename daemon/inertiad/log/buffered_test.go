@@ -0,0 +1,112 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedWriter blocks writes until its gate is opened
+type gatedWriter struct {
+	gate chan struct{}
+	mux  sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	<-g.gate
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.buf.Write(p)
+}
+
+func (g *gatedWriter) String() string {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.buf.String()
+}
+
+func TestBufferedWriter(t *testing.T) {
+	var out = &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	var b = NewBufferedWriter(out, 10, OverflowDropOldest, nil)
+	b.Write([]byte("hello\n"))
+	b.Write([]byte("world\n"))
+	assert.Nil(t, b.Close())
+	assert.Equal(t, "hello\nworld\n", out.String())
+
+	// Writes after close are discarded
+	n, err := b.Write([]byte("bye\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "hello\nworld\n", out.String())
+}
+
+func TestBufferedWriterOverflow(t *testing.T) {
+	type args struct {
+		policy OverflowPolicy
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantLatest bool
+	}{
+		{"drop oldest", args{OverflowDropOldest}, true},
+		{"detach", args{OverflowDetach}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = &gatedWriter{gate: make(chan struct{})}
+			var b = NewBufferedWriter(out, 2, tt.args.policy, nil)
+
+			// Writes should not block even though the writer is stalled
+			var done = make(chan struct{})
+			go func() {
+				for i := 0; i < 100; i++ {
+					b.Write([]byte("line\n"))
+				}
+				b.Write([]byte("latest\n"))
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("writes blocked on stalled writer")
+			}
+			assert.Equal(t, tt.args.policy == OverflowDetach, b.Detached())
+
+			close(out.gate)
+			assert.Nil(t, b.Close())
+			assert.Equal(t, tt.wantLatest, bytes.Contains([]byte(out.String()), []byte("latest")))
+		})
+	}
+}
+
+func TestBufferedWriterCloseTimeout(t *testing.T) {
+	var out = &gatedWriter{gate: make(chan struct{})}
+	defer close(out.gate)
+	var b = NewBufferedWriter(out, 2, OverflowDropOldest, nil)
+	b.timeout = 10 * time.Millisecond
+	b.Write([]byte("stuck\n"))
+	assert.Equal(t, ErrFlushTimeout, b.Close())
+	assert.True(t, b.Detached())
+}
+
+func TestBufferedWriterCloseTimeoutInterrupt(t *testing.T) {
+	var out = &gatedWriter{gate: make(chan struct{})}
+	var b = NewBufferedWriter(out, 2, OverflowDropOldest, func() { close(out.gate) })
+	b.timeout = 10 * time.Millisecond
+	b.Write([]byte("stuck\n"))
+	assert.Equal(t, ErrFlushTimeout, b.Close())
+	assert.True(t, b.Detached())
+
+	// The blocked write should be finished once Close returns
+	select {
+	case <-b.done:
+	default:
+		t.Fatal("writer still in use after close")
+	}
+}
@@ -16,6 +16,8 @@ type DaemonLogger struct {
 	httpWriter http.ResponseWriter
 	httpStream bool
 	socket     SocketWriter
+	buffer     *BufferedWriter
+	detached   bool
 	io.Writer
 }
 
@@ -25,31 +27,52 @@ type LoggerOptions struct {
 	Socket     SocketWriter
 	HTTPWriter http.ResponseWriter
 	HTTPStream bool
+
+	// BufferSize, if set, buffers up to this many writes to the HTTP stream or
+	// websocket, so that slow clients do not block writes to the logger.
+	// BufferOverflow determines how writes are handled if the buffer is full.
+	BufferSize     int
+	BufferOverflow OverflowPolicy
+
+	// Interrupt, if set, causes blocked writes to the client to return, so
+	// that writes to a buffer that cannot be flushed do not outlive the logger
+	Interrupt func()
 }
 
 // NewLogger creates a new logger
 func NewLogger(opts LoggerOptions) *DaemonLogger {
-	var w io.Writer
+	var (
+		w      io.Writer
+		client io.Writer
+	)
 	if !opts.HTTPStream {
 		// Attempt to create a writer with websocket
 		if opts.Socket != nil {
-			w = &MultiWriter{
-				writers: []io.Writer{opts.Stdout, NewWebSocketTextWriter(opts.Socket)},
-			}
-		} else {
-			w = opts.Stdout
+			client = NewWebSocketTextWriter(opts.Socket)
 		}
 	} else {
 		// Attempt to create a writer with HTTPWriter
+		client = opts.HTTPWriter
+	}
+
+	var buffer *BufferedWriter
+	if client != nil && opts.BufferSize > 0 {
+		buffer = NewBufferedWriter(client, opts.BufferSize, opts.BufferOverflow, opts.Interrupt)
+		client = buffer
+	}
+	if client != nil {
 		w = &MultiWriter{
-			writers: []io.Writer{opts.Stdout, opts.HTTPWriter},
+			writers: []io.Writer{opts.Stdout, client},
 		}
+	} else {
+		w = opts.Stdout
 	}
 
 	return &DaemonLogger{
 		httpWriter: opts.HTTPWriter,
 		httpStream: opts.HTTPStream,
 		socket:     opts.Socket,
+		buffer:     buffer,
 		Writer:     w,
 	}
 }
@@ -70,6 +93,9 @@ func (l *DaemonLogger) Println(a interface{}) {
 // WriteErr directs message and status to http.Error when appropriate
 func (l *DaemonLogger) WriteErr(msg string, status int) {
 	fmt.Fprintf(l.Writer, "[ERROR %s] %s\n", strconv.Itoa(status), msg)
+	if !l.flush() {
+		return
+	}
 	if l.socket == nil {
 		http.Error(l.httpWriter, msg, status)
 	} else {
@@ -80,6 +106,9 @@ func (l *DaemonLogger) WriteErr(msg string, status int) {
 // WriteSuccess directs status to Header and sets content type when appropriate
 func (l *DaemonLogger) WriteSuccess(msg string, status int) {
 	fmt.Fprintf(l.Writer, "[SUCCESS %s] %s\n", strconv.Itoa(status), msg)
+	if !l.flush() {
+		return
+	}
 	if l.socket == nil && !l.httpStream {
 		l.httpWriter.Header().Set("Content-Type", "text/html")
 		l.httpWriter.WriteHeader(status)
@@ -95,8 +124,30 @@ type CloseOpts struct {
 	StatusCode int
 }
 
+// flush waits for buffered output to be written, and returns false if the
+// client is too slow for it to be safe to write to it any further
+func (l *DaemonLogger) flush() bool {
+	if l.detached {
+		return false
+	}
+	if l.buffer == nil {
+		return true
+	}
+	var err = l.buffer.Close()
+	l.buffer = nil
+	if err != nil {
+		l.detached = true
+		fmt.Fprintln(l.Writer, "Client stopped receiving output: "+err.Error())
+		return false
+	}
+	return true
+}
+
 // Close shuts down the logger
 func (l *DaemonLogger) Close(opts ...CloseOpts) error {
+	if !l.flush() {
+		return ErrFlushTimeout
+	}
 	if l.socket != nil && !l.httpStream {
 		if opts != nil && len(opts) > 0 {
 			return l.socket.CloseHandler()(
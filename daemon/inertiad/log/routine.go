@@ -7,27 +7,31 @@ import (
 )
 
 // FlushRoutine continuously writes everything in given ReadCloser
-// to an io.Writer. Use this as a goroutine.
-func FlushRoutine(w io.Writer, rc io.Reader, stop chan struct{}) {
+// to an io.Writer. Use this as a goroutine. It returns nil once the reader is
+// exhausted or the routine is stopped, or the read error that ended it.
+func FlushRoutine(w io.Writer, rc io.Reader, stop chan struct{}) error {
 	reader := bufio.NewReader(rc)
-ROUTINE:
 	for {
 		select {
 		case <-stop:
 			WriteAndFlush(w, reader)
-			break ROUTINE
+			return nil
 		default:
 			// Read from pipe then write to ResponseWriter and flush it,
 			// sending the copied content to the client.
 			err := WriteAndFlush(w, reader)
-			if err != nil {
-				break ROUTINE
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
 			}
 		}
 	}
 }
 
-// WriteAndFlush reads from buffer, writes to writer, and flushes if possible
+// WriteAndFlush reads from buffer, writes to writer, and flushes if possible.
+// Invalid UTF-8 is replaced, so that a single bad byte in a project's output
+// does not break clients that require valid UTF-8.
 func WriteAndFlush(w io.Writer, reader *bufio.Reader) error {
 	line, err := reader.ReadBytes('\n')
 	if err != nil {
@@ -35,7 +39,7 @@ func WriteAndFlush(w io.Writer, reader *bufio.Reader) error {
 	}
 
 	// Write to writer, and flush as well if it is a flusher
-	w.Write(line)
+	w.Write(SanitizeUTF8(line))
 	if f, ok := w.(http.Flusher); ok {
 		f.Flush()
 	}
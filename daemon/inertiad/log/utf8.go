@@ -0,0 +1,24 @@
+package log
+
+import "unicode/utf8"
+
+// SanitizeUTF8 returns p with each byte that is not part of a valid UTF-8
+// sequence replaced by the Unicode replacement character, so that output from
+// projects can be sent where valid UTF-8 is required, such as websocket text
+// messages. p is returned as is if it is already valid.
+func SanitizeUTF8(p []byte) []byte {
+	if utf8.Valid(p) {
+		return p
+	}
+	var sanitized = make([]byte, 0, len(p)+2*utf8.UTFMax)
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		if r == utf8.RuneError && size == 1 {
+			sanitized = append(sanitized, string(utf8.RuneError)...)
+		} else {
+			sanitized = append(sanitized, p[:size]...)
+		}
+		p = p[size:]
+	}
+	return sanitized
+}
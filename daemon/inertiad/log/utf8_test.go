@@ -0,0 +1,37 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		p    []byte
+		want string
+	}{
+		{"empty", []byte{}, ""},
+		{"ascii", []byte("hello world\n"), "hello world\n"},
+		{"multibyte", []byte("héllo 世界\n"), "héllo 世界\n"},
+		{"replacement character", []byte("bad �\n"), "bad �\n"},
+		{"invalid byte", []byte("bad \xff byte\n"), "bad � byte\n"},
+		{"latin-1", []byte("caf\xe9\n"), "caf�\n"},
+		{"truncated sequence", []byte("\xe4\xb8 ok"), "�� ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(SanitizeUTF8(tt.p)))
+		})
+	}
+}
+
+func TestWebSocketTextWriterSanitizesUTF8(t *testing.T) {
+	var socket = &mockSocketWriter{}
+	var writer = NewWebSocketTextWriter(socket)
+	n, err := writer.Write([]byte("bad \xff byte\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 11, n)
+	assert.Equal(t, "bad � byte\n", socket.String())
+}
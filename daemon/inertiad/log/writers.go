@@ -20,7 +20,12 @@ type WebSocketWriter struct {
 }
 
 func (w *WebSocketWriter) Write(p []byte) (int, error) {
-	return len(p), w.socketWriter.WriteMessage(w.messageType, p)
+	// Text messages must be valid UTF-8, or clients may close the connection
+	var message = p
+	if w.messageType == websocket.TextMessage {
+		message = SanitizeUTF8(p)
+	}
+	return len(p), w.socketWriter.WriteMessage(w.messageType, message)
 }
 
 // Close closes the socket writer's websocket.
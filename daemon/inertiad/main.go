@@ -29,7 +29,11 @@ Example:
     inertia daemon run 0.0.0.0 -p 8081`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		var conf = cfg.New()
+		conf, err := cfg.Load(cfg.SettingsPath())
+		if err != nil {
+			println(err.Error())
+			return
+		}
 
 		// Set up deployment
 		var projectDatabasePath = path.Join(conf.DataDirectory, "project.db")
@@ -1,20 +1,41 @@
 package project
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 	bolt "go.etcd.io/bbolt"
 )
 
 var (
 	// database buckets
-	envVariableBucket = []byte("envVariables")
+	envVariableBucket       = []byte("envVariables")
+	deploymentHistoryBucket = []byte("deploymentHistory")
+	deployedConfigBucket    = []byte("deployedConfig")
+	previousLogsBucket      = []byte("previousLogs")
+	retainedDeploysBucket   = []byte("retainedDeploys")
+	releasesBucket          = []byte("releases")
+
+	// dataBuckets lists all buckets used by the DeploymentDataManager
+	dataBuckets = [][]byte{
+		envVariableBucket, deploymentHistoryBucket, deployedConfigBucket,
+		previousLogsBucket, retainedDeploysBucket, releasesBucket,
+	}
+
+	// database keys
+	deployedConfigKey = []byte("current")
+	initJobKey        = []byte("initJob")
 )
 
 // DeploymentDataManager stores persistent deployment configuration
@@ -49,40 +70,45 @@ func NewDataManager(dbPath string, keyPath string) (*DeploymentDataManager, erro
 		return nil, fmt.Errorf("failed to open database at '%s': %s", dbPath, err.Error())
 	}
 	if err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(envVariableBucket)
-		return err
+		for _, bucket := range dataBuckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
 	}); err != nil {
 		return nil, fmt.Errorf("failed to instantiate database: %s", err.Error())
 	}
 
-	return &DeploymentDataManager{
+	var manager = &DeploymentDataManager{
 		db,
 		key,
-	}, nil
+	}
+
+	// Variables used to be stored in plain text unless requested otherwise
+	if err := manager.encryptEnvVariables(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt environment variables: %s", err.Error())
+	}
+	return manager, nil
 }
 
 // AddEnvVariable adds a new environment variable that will be applied
-// to all project containers
-func (c *DeploymentDataManager) AddEnvVariable(name, value string,
-	encrypt bool) error {
+// to all project containers. Values are always encrypted at rest.
+func (c *DeploymentDataManager) AddEnvVariable(name, value string) error {
 	if len(name) == 0 || len(value) == 0 {
 		return errors.New("invalid env configuration")
 	}
 
-	valueBytes := []byte(value)
-	if encrypt {
-		encrypted, err := crypto.Encrypt(c.symmetricKey, valueBytes)
-		if err != nil {
-			return err
-		}
-		valueBytes = encrypted
+	encrypted, err := crypto.Encrypt(c.symmetricKey, []byte(value))
+	if err != nil {
+		return err
 	}
 
 	return c.db.Update(func(tx *bolt.Tx) error {
 		vars := tx.Bucket(envVariableBucket)
 		bytes, err := json.Marshal(envVariable{
-			Value:     valueBytes,
-			Encrypted: encrypt,
+			Value:     encrypted,
+			Encrypted: true,
 		})
 		if err != nil {
 			return err
@@ -91,6 +117,43 @@ func (c *DeploymentDataManager) AddEnvVariable(name, value string,
 	})
 }
 
+// encryptEnvVariables encrypts all environment variables stored in plain text
+func (c *DeploymentDataManager) encryptEnvVariables() error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		var vars = tx.Bucket(envVariableBucket)
+		var encrypted = map[string][]byte{}
+		if err := vars.ForEach(func(name, variableBytes []byte) error {
+			var variable = &envVariable{}
+			if err := json.Unmarshal(variableBytes, variable); err != nil {
+				return err
+			}
+			if variable.Encrypted {
+				return nil
+			}
+			value, err := crypto.Encrypt(c.symmetricKey, variable.Value)
+			if err != nil {
+				return err
+			}
+			bytes, err := json.Marshal(envVariable{Value: value, Encrypted: true})
+			if err != nil {
+				return err
+			}
+			encrypted[string(name)] = bytes
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// Buckets must not be modified while iterating over them
+		for name, bytes := range encrypted {
+			if err := vars.Put([]byte(name), bytes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // RemoveEnvVariables removes previously set env variables
 func (c *DeploymentDataManager) RemoveEnvVariables(names ...string) error {
 	return c.db.Update(func(tx *bolt.Tx) error {
@@ -104,7 +167,8 @@ func (c *DeploymentDataManager) RemoveEnvVariables(names ...string) error {
 	})
 }
 
-// GetEnvVariables retrieves all stored environment variables
+// GetEnvVariables retrieves all stored environment variables. Values are
+// redacted unless decrypt is set.
 func (c *DeploymentDataManager) GetEnvVariables(decrypt bool) ([]string, error) {
 	var envs = []string{}
 	var faulty = []string{}
@@ -117,10 +181,10 @@ func (c *DeploymentDataManager) GetEnvVariables(decrypt bool) ([]string, error)
 			}
 
 			var nameString = string(name)
-			if !variable.Encrypted {
-				envs = append(envs, nameString+"="+string(variable.Value))
-			} else if !decrypt {
+			if !decrypt {
 				envs = append(envs, nameString+"=[ENCRYPTED]")
+			} else if !variable.Encrypted {
+				envs = append(envs, nameString+"="+string(variable.Value))
 			} else {
 				decrypted, err := crypto.Decrypt(c.symmetricKey, variable.Value)
 				if err != nil {
@@ -138,12 +202,423 @@ func (c *DeploymentDataManager) GetEnvVariables(decrypt bool) ([]string, error)
 	return envs, err
 }
 
-func (c *DeploymentDataManager) destroy() error {
+// AddDeploymentRecord saves a record of a deployment attempt
+func (c *DeploymentDataManager) AddDeploymentRecord(record api.DeploymentRecord) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		var history = tx.Bucket(deploymentHistoryBucket)
+		id, err := history.NextSequence()
+		if err != nil {
+			return err
+		}
+		bytes, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return history.Put(sequenceKey(id), bytes)
+	})
+}
+
+// GetDeploymentRecords retrieves deployment records, most recent first. The
+// first offset records are skipped, and at most limit records are returned -
+// a limit of 0 returns all remaining records.
+func (c *DeploymentDataManager) GetDeploymentRecords(offset, limit int) ([]api.DeploymentRecord, error) {
+	var records = []api.DeploymentRecord{}
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var (
+			cursor  = tx.Bucket(deploymentHistoryBucket).Cursor()
+			skipped = 0
+		)
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if limit > 0 && len(records) == limit {
+				break
+			}
+			var record api.DeploymentRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// PruneDeploymentRecords removes all but the maxCount most recent deployment
+// records, as well as records of deployments started more than maxAge ago.
+// Limits of 0 are ignored. The number of records removed is returned.
+func (c *DeploymentDataManager) PruneDeploymentRecords(maxCount int,
+	maxAge time.Duration) (int, error) {
+	var removed = 0
+	var err = c.db.Update(func(tx *bolt.Tx) error {
+		var (
+			history = tx.Bucket(deploymentHistoryBucket)
+			cursor  = history.Cursor()
+			cutoff  = time.Now().Add(-maxAge)
+			expired = [][]byte{}
+			kept    = 0
+		)
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var record api.DeploymentRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if (maxCount > 0 && kept >= maxCount) ||
+				(maxAge > 0 && record.StartedAt.Before(cutoff)) {
+				// Keys are only valid for the life of the transaction
+				expired = append(expired, append([]byte{}, k...))
+			} else {
+				kept++
+			}
+		}
+
+		// Buckets must not be modified while iterating over them
+		for _, k := range expired {
+			if err := history.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	return removed, err
+}
+
+// SetDeployedConfig saves the configuration of the most recent deployment
+func (c *DeploymentDataManager) SetDeployedConfig(conf DeployedConfig) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bytes, err := json.Marshal(conf)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(deployedConfigBucket).Put(deployedConfigKey, bytes)
+	})
+}
+
+// GetDeployedConfig retrieves the configuration of the most recent
+// deployment, or nil if there has not been one
+func (c *DeploymentDataManager) GetDeployedConfig() (*DeployedConfig, error) {
+	var conf *DeployedConfig
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var bytes = tx.Bucket(deployedConfigBucket).Get(deployedConfigKey)
+		if bytes == nil {
+			return nil
+		}
+		conf = &DeployedConfig{}
+		return json.Unmarshal(bytes, conf)
+	})
+	return conf, err
+}
+
+// SetInitJobRun records the key of the most recent init job that completed,
+// as created by newInitJobKey
+func (c *DeploymentDataManager) SetInitJobRun(key string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(deployedConfigBucket).Put(initJobKey, []byte(key))
+	})
+}
+
+// GetInitJobRun retrieves the key of the most recent init job that completed,
+// or an empty string if none has
+func (c *DeploymentDataManager) GetInitJobRun() (string, error) {
+	var key string
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		key = string(tx.Bucket(deployedConfigBucket).Get(initJobKey))
+		return nil
+	})
+	return key, err
+}
+
+// SetPreviousLogs replaces the saved logs of previously deployed containers
+// with the given logs, keyed by container name
+func (c *DeploymentDataManager) SetPreviousLogs(logs map[string][]byte) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(previousLogsBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(previousLogsBucket)
+		if err != nil {
+			return err
+		}
+		for name, l := range logs {
+			if err := bucket.Put([]byte(name), l); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPreviousLogs retrieves the saved logs of the previously deployed
+// container with the given name, or nil if there are none
+func (c *DeploymentDataManager) GetPreviousLogs(name string) ([]byte, error) {
+	var logs []byte
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		if l := tx.Bucket(previousLogsBucket).Get([]byte(name)); l != nil {
+			// Values are only valid for the life of the transaction
+			logs = append([]byte{}, l...)
+		}
+		return nil
+	})
+	return logs, err
+}
+
+// RetainDeploy records the given commit as the most recently deployed, and
+// forgets all but the limit most recent deployments. The retained commits are
+// returned, most recent first.
+func (c *DeploymentDataManager) RetainDeploy(commit string, limit int) ([]string, error) {
+	var retained = []string{}
+	var err = c.db.Update(func(tx *bolt.Tx) error {
+		var bucket = tx.Bucket(retainedDeploysBucket)
+
+		// Redeployed commits move to the front
+		if err := deleteMatching(bucket, func(v []byte) bool {
+			return string(v) == commit
+		}); err != nil {
+			return err
+		}
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(sequenceKey(id), []byte(commit)); err != nil {
+			return err
+		}
+
+		// Forget older deployments beyond the limit
+		var cursor = bucket.Cursor()
+		for k, v := cursor.Last(); k != nil && len(retained) < limit; k, v = cursor.Prev() {
+			retained = append(retained, string(v))
+		}
+		var keep = make(map[string]bool, len(retained))
+		for _, r := range retained {
+			keep[r] = true
+		}
+		return deleteMatching(bucket, func(v []byte) bool { return !keep[string(v)] })
+	})
+	return retained, err
+}
+
+// GetRetainedDeploys retrieves the commits of retained deployments, most
+// recent first
+func (c *DeploymentDataManager) GetRetainedDeploys() ([]string, error) {
+	var retained = []string{}
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var cursor = tx.Bucket(retainedDeploysBucket).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			retained = append(retained, string(v))
+		}
+		return nil
+	})
+	return retained, err
+}
+
+// SetRelease gives the given commit a release name. Names are unique - a name
+// that was previously given to another commit is moved to this one.
+func (c *DeploymentDataManager) SetRelease(name, commit string) error {
+	if name == "" || commit == "" {
+		return errors.New("invalid release")
+	}
 	return c.db.Update(func(tx *bolt.Tx) error {
-		if err := tx.DeleteBucket(envVariableBucket); err != nil {
+		bytes, err := json.Marshal(release{Commit: commit, Created: time.Now()})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(releasesBucket).Put([]byte(name), bytes)
+	})
+}
+
+// GetReleaseCommit retrieves the commit with the given release name, or an
+// empty string if no commit has been given the name
+func (c *DeploymentDataManager) GetReleaseCommit(name string) (string, error) {
+	var commit string
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var bytes = tx.Bucket(releasesBucket).Get([]byte(name))
+		if bytes == nil {
+			return nil
+		}
+		var r release
+		if err := json.Unmarshal(bytes, &r); err != nil {
 			return err
 		}
-		_, err := tx.CreateBucket(envVariableBucket)
+		commit = r.Commit
+		return nil
+	})
+	return commit, err
+}
+
+// GetReleaseName retrieves the release name most recently given to the given
+// commit, or an empty string if it has not been given one
+func (c *DeploymentDataManager) GetReleaseName(commit string) (string, error) {
+	var (
+		name   string
+		latest time.Time
+	)
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(releasesBucket).ForEach(func(k, v []byte) error {
+			var r release
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if r.Commit == commit && !r.Created.Before(latest) {
+				name, latest = string(k), r.Created
+			}
+			return nil
+		})
+	})
+	return name, err
+}
+
+// Backup writes a consistent snapshot of the deployment database to w,
+// preceded by the key that environment variables are encrypted with, so that
+// they can be recovered by restoring the backup to any daemon. Backups must be
+// kept as secret as the environment variables themselves.
+func (c *DeploymentDataManager) Backup(w io.Writer) (int64, error) {
+	written, err := w.Write(c.symmetricKey)
+	if err != nil {
+		return int64(written), err
+	}
+	var total = int64(written)
+	err = c.db.View(func(tx *bolt.Tx) error {
+		written, err := tx.WriteTo(w)
+		total += written
+		return err
+	})
+	return total, err
+}
+
+// Restore replaces the contents of the deployment database with the contents
+// of the database backup read from r, as created by Backup. Environment
+// variables are re-encrypted with this daemon's key. The restore is applied
+// in a single transaction, so the database is left untouched if it fails.
+func (c *DeploymentDataManager) Restore(r io.Reader) error {
+	var key = make([]byte, crypto.SymmetricKeyLength)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return fmt.Errorf("invalid backup: failed to read key: %s", err.Error())
+	}
+
+	// Write backup to a temporary file so that it can be opened
+	tmp, err := ioutil.TempFile(filepath.Dir(c.db.Path()), "restore")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
 		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	backup, err := bolt.Open(tmp.Name(), 0600, &bolt.Options{
+		ReadOnly: true,
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid backup: %s", err.Error())
+	}
+	defer backup.Close()
+
+	return backup.View(func(src *bolt.Tx) error {
+		return c.db.Update(func(dst *bolt.Tx) error {
+			for _, bucket := range dataBuckets {
+				if err := dst.DeleteBucket(bucket); err != nil {
+					return err
+				}
+				restored, err := dst.CreateBucket(bucket)
+				if err != nil {
+					return err
+				}
+
+				// Buckets missing from the backup are left empty
+				var original = src.Bucket(bucket)
+				if original == nil {
+					continue
+				}
+				if err := restored.SetSequence(original.Sequence()); err != nil {
+					return err
+				}
+				var isEnv = bytes.Equal(bucket, envVariableBucket)
+				if err := original.ForEach(func(k, v []byte) error {
+					if isEnv {
+						if v, err = c.reencryptEnvVariable(v, key); err != nil {
+							return fmt.Errorf("failed to restore environment variable '%s': %s",
+								k, err.Error())
+						}
+					}
+					return restored.Put(k, v)
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// reencryptEnvVariable returns the given stored environment variable, which is
+// encrypted with key if it is encrypted at all, encrypted with this daemon's key
+func (c *DeploymentDataManager) reencryptEnvVariable(variableBytes, key []byte) ([]byte, error) {
+	var variable = &envVariable{}
+	if err := json.Unmarshal(variableBytes, variable); err != nil {
+		return nil, err
+	}
+	var value = variable.Value
+	if variable.Encrypted {
+		decrypted, err := crypto.Decrypt(key, variable.Value)
+		if err != nil {
+			return nil, err
+		}
+		value = decrypted
+	}
+	encrypted, err := crypto.Encrypt(c.symmetricKey, value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envVariable{Value: encrypted, Encrypted: true})
+}
+
+func (c *DeploymentDataManager) destroy() error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range dataBuckets {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
+
+// deleteMatching deletes all entries in the given bucket whose values match.
+// Keys are collected before deleting, since deleting while iterating can skip
+// entries.
+func deleteMatching(bucket *bolt.Bucket, match func(v []byte) bool) error {
+	var keys = [][]byte{}
+	bucket.ForEach(func(k, v []byte) error {
+		if match(v) {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		return nil
+	})
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sequenceKey encodes given sequence number as a big-endian key, so that keys
+// sort in the order they were created
+func sequenceKey(id uint64) []byte {
+	var key = make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
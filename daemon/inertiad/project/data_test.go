@@ -1,18 +1,22 @@
 package project
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestDataManager_EnvVariableOperations(t *testing.T) {
 	type args struct {
-		name    string
-		value   string
-		encrypt bool
+		name  string
+		value string
 	}
 	tests := []struct {
 		name    string
@@ -20,10 +24,9 @@ func TestDataManager_EnvVariableOperations(t *testing.T) {
 		decrypt bool
 		wantErr bool
 	}{
-		{"invalid env", args{"", "", true}, true, true},
-		{"no encrypt", args{"myvar1", "mysekret", false}, true, false},
-		{"encrypt", args{"myvar2", "myothersekret", true}, true, false},
-		{"no decrypt", args{"myvar", "asdfasdf", true}, true, false},
+		{"invalid env", args{"", ""}, true, true},
+		{"decrypt", args{"myvar2", "myothersekret"}, true, false},
+		{"no decrypt", args{"myvar", "asdfasdf"}, false, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -37,7 +40,7 @@ func TestDataManager_EnvVariableOperations(t *testing.T) {
 			assert.Nil(t, err)
 
 			// Add
-			err = c.AddEnvVariable(tt.args.name, tt.args.value, tt.args.encrypt)
+			err = c.AddEnvVariable(tt.args.name, tt.args.value)
 			assert.Equal(t, tt.wantErr, (err != nil))
 
 			// Retrieve
@@ -65,6 +68,83 @@ func TestDataManager_EnvVariableOperations(t *testing.T) {
 	}
 }
 
+func TestDataManager_EncryptsPlaintextEnvVariables(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Store a variable in plain text, as older daemons could
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		bytes, err := json.Marshal(envVariable{Value: []byte("mysekret")})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(envVariableBucket).Put([]byte("myvar"), bytes)
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, c.db.Close())
+
+	// Reopening should encrypt it
+	c, err = NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	defer c.db.Close()
+	err = c.db.View(func(tx *bolt.Tx) error {
+		var variable = &envVariable{}
+		if err := json.Unmarshal(tx.Bucket(envVariableBucket).Get([]byte("myvar")), variable); err != nil {
+			return err
+		}
+		assert.True(t, variable.Encrypted)
+		assert.NotEqual(t, []byte("mysekret"), variable.Value)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	vars, err := c.GetEnvVariables(false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"myvar=[ENCRYPTED]"}, vars)
+	vars, err = c.GetEnvVariables(true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"myvar=mysekret"}, vars)
+}
+
+func TestDataManager_DeploymentRecordOperations(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Add
+	for _, hash := range []string{"abc", "def", "ghi"} {
+		err = c.AddDeploymentRecord(api.DeploymentRecord{CommitHash: hash, Success: true})
+		assert.Nil(t, err)
+	}
+
+	// Retrieve all, most recent first
+	records, err := c.GetDeploymentRecords(0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, "ghi", records[0].CommitHash)
+	assert.Equal(t, "abc", records[2].CommitHash)
+
+	// Retrieve a page
+	records, err = c.GetDeploymentRecords(1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "def", records[0].CommitHash)
+
+	// Retrieve past the end
+	records, err = c.GetDeploymentRecords(5, 1)
+	assert.Nil(t, err)
+	assert.Zero(t, len(records))
+}
+
 func TestDataManager_destroy(t *testing.T) {
 	dir := "./test_config"
 	err := os.Mkdir(dir, os.ModePerm)
@@ -79,7 +159,360 @@ func TestDataManager_destroy(t *testing.T) {
 	err = c.destroy()
 	assert.Nil(t, err)
 
-	// Check if bucket is still usable
+	// Check if buckets are still usable
 	_, err = c.GetEnvVariables(false)
 	assert.Nil(t, err)
+	_, err = c.GetDeploymentRecords(0, 0)
+	assert.Nil(t, err)
+}
+
+func TestDataManager_PruneDeploymentRecords(t *testing.T) {
+	type args struct {
+		maxCount int
+		maxAge   time.Duration
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantRemoved int
+		wantKept    []string
+	}{
+		{"no limits", args{0, 0}, 0, []string{"new", "recent", "old"}},
+		{"max count", args{2, 0}, 1, []string{"new", "recent"}},
+		{"max age", args{0, 24 * time.Hour}, 1, []string{"new", "recent"}},
+		{"both", args{1, 24 * time.Hour}, 2, []string{"new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := "./test_config"
+			err := os.Mkdir(dir, os.ModePerm)
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+			assert.Nil(t, err)
+			for _, r := range []api.DeploymentRecord{
+				{Branch: "old", StartedAt: time.Now().Add(-48 * time.Hour)},
+				{Branch: "recent", StartedAt: time.Now().Add(-time.Hour)},
+				{Branch: "new", StartedAt: time.Now()},
+			} {
+				assert.Nil(t, c.AddDeploymentRecord(r))
+			}
+
+			removed, err := c.PruneDeploymentRecords(tt.args.maxCount, tt.args.maxAge)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRemoved, removed)
+			records, err := c.GetDeploymentRecords(0, 0)
+			assert.Nil(t, err)
+			var kept = []string{}
+			for _, r := range records {
+				kept = append(kept, r.Branch)
+			}
+			assert.Equal(t, tt.wantKept, kept)
+		})
+	}
+}
+
+func TestDataManager_BackupAndRestore(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	err = c.AddEnvVariable("myvar", "mysekret")
+	assert.Nil(t, err)
+	err = c.AddDeploymentRecord(api.DeploymentRecord{Branch: "master"})
+	assert.Nil(t, err)
+
+	// Back up
+	var backup bytes.Buffer
+	written, err := c.Backup(&backup)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(backup.Len()), written)
+
+	// Change state, then restore
+	err = c.RemoveEnvVariables("myvar")
+	assert.Nil(t, err)
+	err = c.AddEnvVariable("othervar", "value")
+	assert.Nil(t, err)
+	err = c.Restore(&backup)
+	assert.Nil(t, err)
+
+	vars, err := c.GetEnvVariables(true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"myvar=mysekret"}, vars)
+	records, err := c.GetDeploymentRecords(0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	// Invalid backups should not affect the database
+	err = c.Restore(bytes.NewBufferString("not a database"))
+	assert.NotNil(t, err)
+	vars, err = c.GetEnvVariables(true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"myvar=mysekret"}, vars)
+}
+
+func TestDataManager_RestoreWithDifferentKey(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	err = c.AddEnvVariable("myvar", "mysekret")
+	assert.Nil(t, err)
+	var backup bytes.Buffer
+	_, err = c.Backup(&backup)
+	assert.Nil(t, err)
+
+	// Restore onto another daemon, which has its own key
+	restored, err := NewDataManager(path.Join(dir, "restored.db"), path.Join(dir, "restored-key"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, c.symmetricKey, restored.symmetricKey)
+	err = restored.Restore(&backup)
+	assert.Nil(t, err)
+
+	vars, err := restored.GetEnvVariables(true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"myvar=mysekret"}, vars)
+}
+
+func TestDataManager_DeployedConfigOperations(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Nothing deployed yet
+	conf, err := c.GetDeployedConfig()
+	assert.Nil(t, err)
+	assert.Nil(t, conf)
+
+	// Save and retrieve
+	deployed := NewDeployedConfig(DeploymentConfig{
+		ProjectName: "wow",
+		BuildType:   "dockerfile",
+		Branch:      "master",
+	}, []string{"A=B"})
+	err = c.SetDeployedConfig(deployed)
+	assert.Nil(t, err)
+	conf, err = c.GetDeployedConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, deployed, *conf)
+}
+
+func TestDataManager_PreviousLogsOperations(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Nothing saved yet
+	logs, err := c.GetPreviousLogs("web")
+	assert.Nil(t, err)
+	assert.Nil(t, logs)
+
+	// Save and retrieve
+	err = c.SetPreviousLogs(map[string][]byte{"web": []byte("hello\n")})
+	assert.Nil(t, err)
+	logs, err = c.GetPreviousLogs("web")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(logs))
+
+	// Saving again replaces previously saved logs
+	err = c.SetPreviousLogs(map[string][]byte{"db": []byte("ready\n")})
+	assert.Nil(t, err)
+	logs, err = c.GetPreviousLogs("web")
+	assert.Nil(t, err)
+	assert.Nil(t, logs)
+	logs, err = c.GetPreviousLogs("db")
+	assert.Nil(t, err)
+	assert.Equal(t, "ready\n", string(logs))
+}
+
+func TestDataManager_RetainedDeploysOperations(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Nothing retained yet
+	retained, err := c.GetRetainedDeploys()
+	assert.Nil(t, err)
+	assert.Len(t, retained, 0)
+
+	// Only the most recent deployments are retained
+	for _, commit := range []string{"a", "b", "c"} {
+		_, err = c.RetainDeploy(commit, 2)
+		assert.Nil(t, err)
+	}
+	retained, err = c.GetRetainedDeploys()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "b"}, retained)
+
+	// Redeploying a retained commit makes it the most recent
+	retained, err = c.RetainDeploy("b", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c"}, retained)
+	retained, err = c.GetRetainedDeploys()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c"}, retained)
+}
+
+func TestDataManager_ReleaseOperations(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Invalid releases
+	assert.NotNil(t, c.SetRelease("", "a"))
+	assert.NotNil(t, c.SetRelease("v1", ""))
+
+	// No releases yet
+	commit, err := c.GetReleaseCommit("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "", commit)
+	name, err := c.GetReleaseName("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "", name)
+
+	// Name commits
+	assert.Nil(t, c.SetRelease("v1", "a"))
+	assert.Nil(t, c.SetRelease("v2", "b"))
+	commit, err = c.GetReleaseCommit("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", commit)
+	name, err = c.GetReleaseName("b")
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", name)
+
+	// The most recent name of a commit is used
+	assert.Nil(t, c.SetRelease("v0-hotfix", "a"))
+	name, err = c.GetReleaseName("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "v0-hotfix", name)
+	commit, err = c.GetReleaseCommit("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", commit)
+
+	// Names move to the commit they were last given to
+	assert.Nil(t, c.SetRelease("v2", "c"))
+	commit, err = c.GetReleaseCommit("v2")
+	assert.Nil(t, err)
+	assert.Equal(t, "c", commit)
+	name, err = c.GetReleaseName("b")
+	assert.Nil(t, err)
+	assert.Equal(t, "", name)
+}
+
+func TestDeployedConfig_Changes(t *testing.T) {
+	var (
+		base = DeploymentConfig{ProjectName: "wow", BuildType: "dockerfile", Branch: "master"}
+		prev = NewDeployedConfig(base, []string{"A=B", "C=D"})
+	)
+
+	// Env order should not matter
+	assert.Empty(t, NewDeployedConfig(base, []string{"C=D", "A=B"}).Changes(prev))
+
+	changed := base
+	changed.BuildType = "docker-compose"
+	changes := NewDeployedConfig(changed, []string{"A=B"}).Changes(prev)
+	assert.Equal(t, 2, len(changes))
+	assert.Contains(t, changes[0], "build type")
+	assert.Contains(t, changes[1], "environment variables")
+}
+
+func TestDeployedConfig_ChangesWithRequestEnv(t *testing.T) {
+	var (
+		base = DeploymentConfig{ProjectName: "wow", Env: map[string]string{"A": "B"}}
+		prev = NewDeployedConfig(base, []string{"C=D"})
+	)
+
+	// Variables from the request are combined with saved variables
+	assert.Empty(t, NewDeployedConfig(DeploymentConfig{ProjectName: "wow"},
+		[]string{"A=B", "C=D"}).Changes(prev))
+
+	changed := base
+	changed.Env = map[string]string{"A": "E"}
+	changes := NewDeployedConfig(changed, []string{"C=D"}).Changes(prev)
+	assert.Equal(t, []string{"environment variables changed"}, changes)
+}
+
+func TestDeployedConfig_ChangesInSettings(t *testing.T) {
+	var (
+		base = DeploymentConfig{
+			ProjectName: "wow",
+			BuildArgs:   map[string]string{"TOKEN": "secret", "A": "B"},
+			Resources:   map[string]api.Resources{"web": {CPUShares: 512}},
+		}
+		prev = NewDeployedConfig(base, nil)
+	)
+
+	// Secret values should not be stored
+	saved, err := json.Marshal(prev)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(saved), "secret")
+
+	// Map order should not matter
+	same := base
+	same.BuildArgs = map[string]string{"A": "B", "TOKEN": "secret"}
+	assert.Empty(t, NewDeployedConfig(same, nil).Changes(prev))
+
+	changed := base
+	changed.BuildArgs = map[string]string{"TOKEN": "rotated", "A": "B"}
+	changed.Resources = map[string]api.Resources{"web": {CPUShares: 1024}}
+	changed.Platform = "linux/arm64"
+	changes := NewDeployedConfig(changed, nil).Changes(prev)
+	assert.Equal(t, []string{
+		"build args changed",
+		"platform changed",
+		"resources changed",
+	}, changes)
+
+	// Configurations saved without settings only compare recorded fields
+	prev.Settings = nil
+	assert.Empty(t, NewDeployedConfig(changed, nil).Changes(prev))
+}
+
+func TestMergeEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       []string
+		overrides map[string]string
+		want      []string
+	}{
+		{"none", nil, nil, []string{}},
+		{"saved only", []string{"A=B"}, nil, []string{"A=B"}},
+		{"overrides only", nil, map[string]string{"B": "2", "A": "1=1"},
+			[]string{"A=1=1", "B=2"}},
+		{"overridden", []string{"A=B", "C=D", "AB=E"}, map[string]string{"A": "Z"},
+			[]string{"C=D", "AB=E", "A=Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mergeEnv(tt.env, tt.overrides))
+		})
+	}
 }
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -23,6 +25,10 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 )
 
+// ErrDeployCancelled is returned by Deploy if the deployment is cancelled
+// before the project is started
+var ErrDeployCancelled = errors.New("deployment cancelled")
+
 // Deployer manages the deployed user project
 type Deployer interface {
 	Deploy(*docker.Client, io.Writer, DeployOptions) (func() error, error)
@@ -30,7 +36,11 @@ type Deployer interface {
 	Down(*docker.Client, io.Writer) error
 	Destroy(*docker.Client, io.Writer) error
 	Prune(*docker.Client, io.Writer) error
+	Rollback(*docker.Client, io.Writer, string) (func() error, error)
+	Recreate(*docker.Client, io.Writer, string) error
+	UpdateBaseImages(*docker.Client, io.Writer) ([]string, error)
 	GetStatus(*docker.Client) (api.DeploymentStatus, error)
+	Preview(*docker.Client, DeploymentConfig) (api.DeploymentPreview, error)
 
 	SetConfig(DeploymentConfig)
 	GetBranch() string
@@ -51,8 +61,40 @@ type Deployment struct {
 	buildType     string
 	buildFilePath string
 
+	containerUser      string
+	enforceNonRootUser bool
+	platform           string
+	buildTarget        string
+	buildArgs          map[string]string
+	initJob            *api.InitJob
+	labels             map[string]map[string]string
+	resources          map[string]api.Resources
+	networking         map[string]api.Networking
+	healthchecks       map[string]api.Healthcheck
+	commands           map[string]api.CommandOverride
+	readiness          map[string]api.Readiness
+	buildx             *api.Buildx
+	dependsOn          *api.DependsOn
+	static             *api.Static
+
+	cleanBuild   bool
+	cleanExclude []string
+
+	retainedDeploys int
+
+	stopTimeout time.Duration
+
+	verifyImagesKey string
+
+	env map[string]string
+
 	builder build.ContainerBuilder
 
+	// expectedStops are the names of containers, or docker-compose services,
+	// that are expected to stop while the project is active
+	expectedStops    map[string]bool
+	expectedStopsMux sync.Mutex
+
 	repo *gogit.Repository
 	auth ssh.AuthMethod
 	mux  sync.Mutex
@@ -68,6 +110,44 @@ type DeploymentConfig struct {
 	RemoteURL     string
 	Branch        string
 	PemFilePath   string
+
+	ContainerUser      string
+	EnforceNonRootUser bool
+	Platform           string
+	BuildTarget        string
+	BuildArgs          map[string]string
+	InitJob            *api.InitJob
+	Labels             map[string]map[string]string
+	Resources          map[string]api.Resources
+	Networking         map[string]api.Networking
+	Healthchecks       map[string]api.Healthcheck
+	Commands           map[string]api.CommandOverride
+	Readiness          map[string]api.Readiness
+	Buildx             *api.Buildx
+	DependsOn          *api.DependsOn
+	Static             *api.Static
+
+	// CleanBuild removes untracked and ignored files, except those matching
+	// CleanExclude, from the project directory before each build
+	CleanBuild   bool
+	CleanExclude []string
+
+	// RetainedDeploys is the number of recent deployments whose images are
+	// kept for rollback - images are not retained if it is 0
+	RetainedDeploys int
+
+	// StopTimeout is how long project containers are given to stop before
+	// they are killed - containers.DefaultStopTimeout is used if it is 0
+	StopTimeout time.Duration
+
+	// VerifyImagesKey is a cosign public key that the images the project is
+	// built from must be signed with - signatures are not verified if empty
+	VerifyImagesKey string
+
+	// Env are environment variables, keyed by name, that project containers
+	// are started with - these take precedence over saved variables of the
+	// same name. They are kept in memory only.
+	Env map[string]string
 }
 
 // NewDeployment creates a new deployment
@@ -92,7 +172,8 @@ func NewDeployment(
 	}, nil
 }
 
-// Initialize sets up deployment repository
+// Initialize sets up deployment repository, reusing an existing clone of the
+// same remote if there is one
 func (d *Deployment) Initialize(cfg DeploymentConfig, out io.Writer) error {
 	if cfg.RemoteURL == "" {
 		return errors.New("remote URL is required for first setup")
@@ -110,20 +191,36 @@ func (d *Deployment) Initialize(cfg DeploymentConfig, out io.Writer) error {
 		return err
 	}
 
+	var opts = git.RepoOptions{
+		Directory: d.directory,
+		Branch:    cfg.Branch,
+		Auth:      d.auth,
+	}
+
+	// Reuse the repository of a previous attempt to deploy the same project,
+	// such as one whose first build failed, instead of cloning it again
+	if repo, err := git.OpenRepository(cfg.RemoteURL, opts); err == nil {
+		fmt.Fprintln(out, "Existing project repository found - updating it")
+		if err = git.UpdateRepository(repo, opts, out); err == nil {
+			d.repo = repo
+			return nil
+		}
+		fmt.Fprintln(out, "Failed to update existing repository, cloning it again: "+err.Error())
+	}
+
 	// Remove existing git repo if there is one
 	os.RemoveAll(filepath.Join(d.directory, ".git"))
 
 	// Initialize repository
-	d.repo, err = git.InitializeRepository(cfg.RemoteURL, git.RepoOptions{
-		Directory: d.directory,
-		Branch:    cfg.Branch,
-		Auth:      d.auth,
-	}, out)
+	d.repo, err = git.InitializeRepository(cfg.RemoteURL, opts, out)
 	return err
 }
 
-// SetConfig updates the deployment's configuration. Only supports
-// ProjectName, Branch, and BuildType for now.
+// SetConfig updates the deployment's configuration. Empty project and build
+// values are ignored, while container, platform, build target, init job, label, resource,
+// networking, healthcheck, command, readiness, buildx, dependency, cleanup,
+// retention, stop timeout, and image verification options are always
+// overwritten.
 func (d *Deployment) SetConfig(cfg DeploymentConfig) {
 	if cfg.ProjectName != "" {
 		d.project = cfg.ProjectName
@@ -137,11 +234,69 @@ func (d *Deployment) SetConfig(cfg DeploymentConfig) {
 	if cfg.BuildFilePath != "" {
 		d.buildFilePath = cfg.BuildFilePath
 	}
+	d.containerUser = cfg.ContainerUser
+	d.enforceNonRootUser = cfg.EnforceNonRootUser
+	d.platform = cfg.Platform
+	d.buildTarget = cfg.BuildTarget
+	d.buildArgs = cfg.BuildArgs
+	d.initJob = cfg.InitJob
+	d.labels = cfg.Labels
+	d.resources = cfg.Resources
+	d.networking = cfg.Networking
+	d.healthchecks = cfg.Healthchecks
+	d.commands = cfg.Commands
+	d.readiness = cfg.Readiness
+	d.buildx = cfg.Buildx
+	d.dependsOn = cfg.DependsOn
+	d.static = cfg.Static
+	d.cleanBuild = cfg.CleanBuild
+	d.cleanExclude = cfg.CleanExclude
+	d.retainedDeploys = cfg.RetainedDeploys
+	d.stopTimeout = cfg.StopTimeout
+	d.verifyImagesKey = cfg.VerifyImagesKey
+	d.env = cfg.Env
+}
+
+// getStopTimeout returns how long containers are given to stop gracefully
+func (d *Deployment) getStopTimeout() time.Duration {
+	if d.stopTimeout <= 0 {
+		return containers.DefaultStopTimeout
+	}
+	return d.stopTimeout
+}
+
+// stopContainers stops the project's containers, leaving the containers the
+// project depends on running since they are not part of the project
+func (d *Deployment) stopContainers(cli *docker.Client, out io.Writer) error {
+	var keep []string
+	if d.dependsOn != nil {
+		keep = d.dependsOn.Containers
+	}
+	return d.builder.StopContainers(cli, out, d.getStopTimeout(), keep...)
 }
 
 // DeployOptions is used to configure how the deployment handles the deploy
 type DeployOptions struct {
 	SkipUpdate bool
+
+	// MinFreeDiskMB aborts the deployment before any containers are stopped
+	// if less than this many megabytes of disk space are free for Docker
+	MinFreeDiskMB int
+
+	// Context cancels the deployment if it is done before the project is
+	// started, in which case the previous deployment is restored. The
+	// deployment cannot be cancelled if it is nil.
+	Context context.Context
+
+	// Release names the deployed commit once it is successfully deployed, so
+	// that it can be rolled back to by name
+	Release string
+
+	// Commit, if set, is deployed instead of the head of the branch
+	Commit string
+
+	// Trace, if set, records the duration of each phase of the deployment
+	Trace *common.Span
 }
 
 // Deploy will update, build, and deploy the project
@@ -154,23 +309,77 @@ func (d *Deployment) Deploy(
 	defer d.mux.Unlock()
 	fmt.Println(out, "Preparing to deploy project")
 
+	var ctx = opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Err() != nil {
+		return func() error { return nil }, ErrDeployCancelled
+	}
+
+	// Note the deployed commit, so that it can be restored if the deployment
+	// is cancelled
+	var previous string
+	if d.repo != nil {
+		if head, err := d.repo.Head(); err == nil {
+			previous = head.Hash().String()
+		}
+	}
+
 	// Update repository
 	if !opts.SkipUpdate {
-		if err := git.UpdateRepository(d.repo, git.RepoOptions{
+		var fetch = opts.Trace.Child("fetch")
+		err := git.UpdateRepository(d.repo, git.RepoOptions{
 			Directory: d.directory,
 			Branch:    d.branch,
 			Auth:      d.auth,
-		}, out); err != nil {
+		}, out)
+		fetch.End(err)
+		if err != nil {
 			return func() error { return nil }, err
 		}
 	}
 
+	// Deploy the requested commit instead of the head of the branch
+	if opts.Commit != "" {
+		if d.repo == nil {
+			return func() error { return nil }, errors.New("no repository to check out")
+		}
+		if err := git.CheckoutCommit(d.repo, opts.Commit, out); err != nil {
+			return func() error { return nil }, fmt.Errorf(
+				"failed to check out commit %s: %s", opts.Commit, err.Error())
+		}
+	}
+
+	// Remove untracked files so that the build starts from a clean checkout
+	if d.cleanBuild {
+		if err := git.CleanRepository(d.repo, git.RepoOptions{
+			Directory: d.directory,
+		}, d.cleanExclude, out); err != nil {
+			return func() error { return nil }, err
+		}
+	}
+
+	// Preserve the logs of project containers, including those that have
+	// exited, before they are removed
+	d.savePreviousLogs(cli, out)
+
 	// Clean up
 	d.builder.Prune(cli, out)
 
+	// Fail early if the build is likely to run out of disk space
+	if err := checkDockerDiskSpace(cli, opts.MinFreeDiskMB); err != nil {
+		return func() error { return nil }, err
+	}
+	if ctx.Err() != nil {
+		return func() error { return nil }, d.restoreCancelled(cli, out, previous, false)
+	}
+
 	// Kill active project containers if there are any
+	var stop = opts.Trace.Child("stop")
 	d.active = false
-	err := d.builder.StopContainers(cli, out)
+	err := d.stopContainers(cli, out)
+	stop.End(err)
 	if err != nil {
 		return func() error { return nil }, err
 	}
@@ -181,20 +390,361 @@ func (d *Deployment) Deploy(
 		fmt.Fprintln(out, err.Error())
 		fmt.Fprintln(out, "Continuing...")
 	}
+	var initJob string
+	if d.repo != nil {
+		if head, err := d.repo.Head(); err == nil {
+			initJob = d.pendingInitJob(conf, head.Hash().String(), out)
+		}
+	}
+
+	// Record the time project containers take to pass their readiness checks
+	// separately from the time they take to start
+	var phase *common.Span
+	conf.Started = func() {
+		phase.End(nil)
+		phase = opts.Trace.Child("healthy")
+	}
 
 	// Build project
-	deploy, err := d.builder.Build(strings.ToLower(d.buildType), *conf, cli, out)
+	var build = opts.Trace.Child("build")
+	deploy, err := d.builder.Build(ctx, strings.ToLower(d.buildType), *conf, cli, out)
+	build.End(err)
+	if ctx.Err() != nil {
+		return func() error { return nil }, d.restoreCancelled(cli, out, previous, true)
+	}
 	if err != nil {
 		return func() error { return nil }, err
 	}
 
+	// Keep this deployment's images so that it can be rolled back to
+	if d.retainedDeploys > 0 {
+		d.retainImages(cli, out)
+	}
+
 	// Deploy
+	return func() error {
+		d.active = true
+		phase = opts.Trace.Child("start")
+		if err := deploy(); err != nil {
+			phase.End(err)
+			if ctx.Err() != nil {
+				return ErrDeployCancelled
+			}
+			return err
+		}
+		phase.End(nil)
+		d.nameRelease(opts.Release, out)
+		if initJob != "" {
+			if err := d.dataManager.SetInitJobRun(initJob); err != nil {
+				fmt.Fprintln(out, "Failed to record init job: "+err.Error())
+			}
+		}
+		return nil
+	}, nil
+}
+
+// pendingInitJob checks if the init job in conf has already completed for the
+// given commit and configuration, in which case it is removed from conf. The
+// key to record once the job completes is returned, or an empty string if
+// there is no job to run.
+func (d *Deployment) pendingInitJob(conf *build.Config, commit string, out io.Writer) string {
+	if conf.InitJob == nil || d.dataManager == nil {
+		return ""
+	}
+	var key = newInitJobKey(commit, conf.InitJob, conf.EnvValues)
+	if last, err := d.dataManager.GetInitJobRun(); err == nil && last == key {
+		fmt.Fprintln(out, "Init job already completed for this commit and configuration - skipping")
+		conf.InitJob = nil
+		return ""
+	}
+	return key
+}
+
+// nameRelease gives the deployed commit the given release name, if there is
+// one
+func (d *Deployment) nameRelease(name string, out io.Writer) {
+	if name == "" || d.repo == nil || d.dataManager == nil {
+		return
+	}
+	head, err := d.repo.Head()
+	if err == nil {
+		err = d.dataManager.SetRelease(name, head.Hash().String())
+	}
+	if err != nil {
+		fmt.Fprintln(out, "Failed to name release: "+err.Error())
+		return
+	}
+	fmt.Fprintf(out, "Deployed release %s\n", name)
+}
+
+// retainImages tags the project's newly built images with the deployed
+// commit, and untags images of deployments that are no longer retained so
+// that they can be pruned
+func (d *Deployment) retainImages(cli *docker.Client, out io.Writer) {
+	if d.repo == nil || d.dataManager == nil {
+		return
+	}
+	head, err := d.repo.Head()
+	if err != nil {
+		fmt.Fprintln(out, "Failed to retain deployment images: "+err.Error())
+		return
+	}
+	var commit = head.Hash().String()
+	if err := containers.TagProjectImages(cli, d.project, commit); err != nil {
+		fmt.Fprintln(out, "Failed to retain deployment images: "+err.Error())
+		return
+	}
+	retained, err := d.dataManager.RetainDeploy(commit, d.retainedDeploys)
+	if err != nil {
+		fmt.Fprintln(out, "Failed to retain deployment images: "+err.Error())
+		return
+	}
+	if err := containers.RemoveProjectImageTags(cli, d.project, retained); err != nil {
+		fmt.Fprintln(out, "Failed to remove images of old deployments: "+err.Error())
+	}
+}
+
+// Rollback redeploys a retained deployment from its images, without
+// rebuilding. The given commit may be a release name or an abbreviated commit
+// - if it is empty, the deployment retained before the current one is used.
+func (d *Deployment) Rollback(
+	cli *docker.Client,
+	out io.Writer,
+	commit string,
+) (func() error, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if d.repo == nil || d.dataManager == nil {
+		return func() error { return nil }, errors.New("no deployment to roll back")
+	}
+	head, err := d.repo.Head()
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	retained, err := d.dataManager.GetRetainedDeploys()
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	if commit != "" {
+		released, err := d.dataManager.GetReleaseCommit(commit)
+		if err != nil {
+			return func() error { return nil }, err
+		}
+		if released != "" {
+			fmt.Fprintf(out, "Release %s is commit %s\n", commit, released)
+			commit = released
+		}
+	}
+	target, err := findRetainedDeploy(retained, head.Hash().String(), commit)
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	fmt.Fprintf(out, "Rolling back to %s\n", target)
+	return d.deployRetained(cli, out, target)
+}
+
+// deployRetained restores the project files and images of the retained
+// deployment of the given commit, and replaces the active project containers
+// with containers started from the restored images
+func (d *Deployment) deployRetained(
+	cli *docker.Client,
+	out io.Writer,
+	target string,
+) (func() error, error) {
+	// Restore the project files and images of the retained deployment
+	if err := git.CheckoutCommit(d.repo, target, out); err != nil {
+		return func() error { return nil }, err
+	}
+	if err := containers.RestoreProjectImages(cli, d.project, target); err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Kill active project containers if there are any, preserving their logs
+	d.savePreviousLogs(cli, out)
+	d.active = false
+	if err := d.stopContainers(cli, out); err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Get config
+	conf, err := d.GetBuildConfiguration()
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		fmt.Fprintln(out, "Continuing...")
+	}
+	conf.SkipBuild = true
+
+	// Start project from the restored images
+	deploy, err := d.builder.Build(context.Background(),
+		strings.ToLower(d.buildType), *conf, cli, out)
+	if err != nil {
+		return func() error { return nil }, err
+	}
 	return func() error {
 		d.active = true
 		return deploy()
 	}, nil
 }
 
+// restoreCancelled restores the project files of the given previously
+// deployed commit after a deployment is cancelled. If the project's containers
+// were already stopped, containers left behind by the cancelled deployment are
+// removed, and the previous deployment is restarted if it was retained.
+func (d *Deployment) restoreCancelled(
+	cli *docker.Client,
+	out io.Writer,
+	previous string,
+	stopped bool,
+) error {
+	fmt.Fprintln(out, "Deployment cancelled - restoring previous deployment")
+	if previous == "" {
+		if stopped {
+			d.stopContainers(cli, out)
+		}
+		fmt.Fprintln(out, "No previous deployment to restore")
+		return ErrDeployCancelled
+	}
+	if !stopped {
+		if err := git.CheckoutCommit(d.repo, previous, out); err != nil {
+			fmt.Fprintln(out, "Failed to restore project files: "+err.Error())
+		}
+		return ErrDeployCancelled
+	}
+
+	var isRetained = false
+	if d.dataManager != nil {
+		retained, _ := d.dataManager.GetRetainedDeploys()
+		for _, r := range retained {
+			isRetained = isRetained || r == previous
+		}
+	}
+	if !isRetained {
+		d.stopContainers(cli, out)
+		if err := git.CheckoutCommit(d.repo, previous, out); err != nil {
+			fmt.Fprintln(out, "Failed to restore project files: "+err.Error())
+		}
+		fmt.Fprintln(out, "The previous deployment was not retained and cannot be restarted - "+
+			"deploy again to bring the project back online")
+		return ErrDeployCancelled
+	}
+	deploy, err := d.deployRetained(cli, out, previous)
+	if err == nil {
+		err = deploy()
+	}
+	if err != nil {
+		fmt.Fprintln(out, "Failed to restart previous deployment: "+err.Error())
+	}
+	return ErrDeployCancelled
+}
+
+// findRetainedDeploy returns the full commit of the retained deployment
+// matching the given commit, which may be abbreviated. If commit is empty,
+// the deployment retained before the current commit is returned.
+func findRetainedDeploy(retained []string, current, commit string) (string, error) {
+	if commit == "" {
+		for i, r := range retained {
+			if r != current {
+				continue
+			}
+			if i+1 < len(retained) {
+				return retained[i+1], nil
+			}
+			return "", errors.New("no earlier deployment is retained")
+		}
+		return "", errors.New("the current deployment is not retained - specify a commit to roll back to")
+	}
+
+	var matches = []string{}
+	for _, r := range retained {
+		if strings.HasPrefix(r, commit) {
+			matches = append(matches, r)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no retained deployment matches commit '%s'", commit)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("commit '%s' matches multiple retained deployments", commit)
+	}
+}
+
+// Recreate stops the container of the given service and recreates it from its
+// existing image with the current configuration, leaving other services
+// running
+func (d *Deployment) Recreate(cli *docker.Client, out io.Writer, service string) (err error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	conf, err := d.GetBuildConfiguration()
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		fmt.Fprintln(out, "Continuing...")
+	}
+
+	// The service and the container used to recreate it are expected to stop
+	// while the rest of the project keeps running - if recreation fails, they
+	// may not have stopped, so stop expecting them to
+	d.expectStops(service, build.RecreateContainerName)
+	defer func() {
+		if err != nil {
+			d.unexpectStops(service, build.RecreateContainerName)
+		}
+	}()
+	err = d.builder.Recreate(strings.ToLower(d.buildType), service, *conf, cli, out,
+		d.getStopTimeout())
+	return err
+}
+
+// UpdateBaseImages pulls newer versions of the images the project is built
+// from, or runs, if they have been updated upstream, and returns the updated
+// images. The project must be redeployed to use them.
+func (d *Deployment) UpdateBaseImages(cli *docker.Client, out io.Writer) ([]string, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return build.UpdateBaseImages(context.Background(), cli, d.buildType, d.directory,
+		d.buildFilePath, out)
+}
+
+// expectStops marks the given containers or services as expected to stop
+func (d *Deployment) expectStops(names ...string) {
+	d.expectedStopsMux.Lock()
+	defer d.expectedStopsMux.Unlock()
+	if d.expectedStops == nil {
+		d.expectedStops = make(map[string]bool)
+	}
+	for _, name := range names {
+		d.expectedStops[name] = true
+	}
+}
+
+// unexpectStops stops expecting the given containers or services to stop
+func (d *Deployment) unexpectStops(names ...string) {
+	d.expectedStopsMux.Lock()
+	defer d.expectedStopsMux.Unlock()
+	for _, name := range names {
+		delete(d.expectedStops, name)
+	}
+}
+
+// isExpectedStop checks if the container with the given event attributes was
+// expected to stop, and if so, stops expecting it to
+func (d *Deployment) isExpectedStop(attributes map[string]string) bool {
+	d.expectedStopsMux.Lock()
+	defer d.expectedStopsMux.Unlock()
+	for _, name := range []string{
+		attributes["name"], attributes["com.docker.compose.service"],
+	} {
+		if name != "" && d.expectedStops[name] {
+			delete(d.expectedStops, name)
+			return true
+		}
+	}
+	return false
+}
+
 // Down shuts down the deployment
 func (d *Deployment) Down(cli *docker.Client, out io.Writer) error {
 	d.mux.Lock()
@@ -204,15 +754,21 @@ func (d *Deployment) Down(cli *docker.Client, out io.Writer) error {
 	// everything anyway in case the docker-compose image is still
 	// active
 	d.active = false
+
+	// Stop serving the site of static projects, which have no containers
+	var unpublished = d.unpublishSite(out)
 	_, err := containers.GetActiveContainers(cli)
-	if err != nil {
-		killErr := d.builder.StopContainers(cli, out)
+	if err == containers.ErrNoContainers && unpublished {
+		return nil
+	} else if err != nil {
+		killErr := d.stopContainers(cli, out)
 		if killErr != nil {
 			println(err)
 		}
 		return err
 	}
-	err = d.builder.StopContainers(cli, out)
+	d.savePreviousLogs(cli, out)
+	err = d.stopContainers(cli, out)
 	if err != nil {
 		return err
 	}
@@ -222,9 +778,47 @@ func (d *Deployment) Down(cli *docker.Client, out io.Writer) error {
 	return nil
 }
 
-// Prune clears unused Docker assets
+// Prune clears unused Docker assets, except images of retained deployments,
+// and the site of static projects if the project is not deployed
 func (d *Deployment) Prune(cli *docker.Client, out io.Writer) error {
-	return d.builder.PruneAll(cli, out)
+	if !d.active {
+		d.unpublishSite(out)
+	}
+	var exceptions = []string{}
+	if d.dataManager != nil {
+		retained, err := d.dataManager.GetRetainedDeploys()
+		if err != nil {
+			return err
+		}
+		for _, commit := range retained {
+			exceptions = append(exceptions, ":"+commit)
+		}
+	}
+	return d.builder.PruneAll(cli, out, exceptions...)
+}
+
+// sitePublished checks if the site of a static project is being served
+func (d *Deployment) sitePublished() bool {
+	var site = d.builder.GetStaticSiteDirectory()
+	if site == "" {
+		return false
+	}
+	_, err := os.Stat(site)
+	return err == nil
+}
+
+// unpublishSite stops serving the site of static projects by removing it, and
+// reports whether there was a site to remove
+func (d *Deployment) unpublishSite(out io.Writer) bool {
+	if !d.sitePublished() {
+		return false
+	}
+	if err := os.RemoveAll(d.builder.GetStaticSiteDirectory()); err != nil {
+		fmt.Fprintln(out, "Failed to unpublish site: "+err.Error())
+		return false
+	}
+	fmt.Fprintln(out, "Site unpublished")
+	return true
 }
 
 // Destroy shuts down the deployment and removes the repository
@@ -246,7 +840,6 @@ func (d *Deployment) GetStatus(cli *docker.Client) (api.DeploymentStatus, error)
 		activeContainers     = make([]string, 0)
 		buildContainerActive = false
 		ignore               = map[string]bool{
-			"/inertia-daemon":                   true,
 			"/" + d.builder.GetBuildStageName(): true,
 		}
 	)
@@ -271,9 +864,18 @@ func (d *Deployment) GetStatus(cli *docker.Client) (api.DeploymentStatus, error)
 	if err != nil && err != containers.ErrNoContainers {
 		return api.DeploymentStatus{Containers: activeContainers}, err
 	}
+	var health = make(map[string]string)
 	for _, container := range c {
+		if containers.IsInfrastructureContainer(container.Names[0]) {
+			continue
+		}
 		if !ignore[container.Names[0]] {
 			activeContainers = append(activeContainers, container.Names[0])
+			info, err := cli.ContainerInspect(context.Background(), container.ID)
+			if err == nil && info.ContainerJSONBase != nil && info.State != nil &&
+				info.State.Health != nil {
+				health[container.Names[0]] = info.State.Health.Status
+			}
 		} else {
 			if container.Names[0] == "/docker-compose" {
 				buildContainerActive = true
@@ -281,16 +883,133 @@ func (d *Deployment) GetStatus(cli *docker.Client) (api.DeploymentStatus, error)
 		}
 	}
 
+	var release string
+	if d.dataManager != nil {
+		release, _ = d.dataManager.GetReleaseName(head.Hash().String())
+	}
+
 	return api.DeploymentStatus{
 		Branch:               strings.TrimSpace(head.Name().Short()),
 		CommitHash:           strings.TrimSpace(head.Hash().String()),
 		CommitMessage:        strings.TrimSpace(commit.Message),
+		Release:              release,
 		BuildType:            strings.TrimSpace(d.buildType),
 		Containers:           activeContainers,
 		BuildContainerActive: buildContainerActive,
+		Health:               health,
+		SitePublished:        d.sitePublished(),
 	}, nil
 }
 
+// previousLogEntries is the number of log entries saved from each project
+// container before it is replaced
+const previousLogEntries = 1000
+
+// savePreviousLogs saves the most recent logs of project containers, including
+// those that have exited, so that they remain available after the containers
+// are removed
+func (d *Deployment) savePreviousLogs(cli *docker.Client, out io.Writer) {
+	if d.dataManager == nil {
+		return
+	}
+	list, err := cli.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	if err != nil {
+		return
+	}
+
+	var (
+		logs   = map[string][]byte{}
+		ignore = map[string]bool{
+			"/docker-compose":                   true,
+			"/" + d.builder.GetBuildStageName(): true,
+		}
+	)
+	for _, c := range list {
+		if ignore[c.Names[0]] || containers.IsInfrastructureContainer(c.Names[0]) {
+			continue
+		}
+		rc, err := containers.ContainerLogs(cli, containers.LogOptions{
+			Container: c.ID,
+			Entries:   previousLogEntries,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "Failed to save logs of %s: %s\n", c.Names[0], err.Error())
+			continue
+		}
+		l, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			fmt.Fprintf(out, "Failed to save logs of %s: %s\n", c.Names[0], err.Error())
+			continue
+		}
+		logs[strings.TrimPrefix(c.Names[0], "/")] = l
+	}
+	if len(logs) == 0 {
+		return
+	}
+	if err := d.dataManager.SetPreviousLogs(logs); err != nil {
+		fmt.Fprintln(out, "Failed to save logs of previous containers: "+err.Error())
+	}
+}
+
+// maxPreviewCommits is the maximum number of new commits listed in a preview
+const maxPreviewCommits = 20
+
+// Preview compares the deployment against the given configuration, and
+// returns a summary of what deploying it would change. The given branch is
+// fetched, but the checked out branch and active containers are unaffected.
+func (d *Deployment) Preview(cli *docker.Client, cfg DeploymentConfig) (api.DeploymentPreview, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	var branch = cfg.Branch
+	if branch == "" {
+		branch = d.branch
+	}
+	status, err := d.GetStatus(cli)
+	if err != nil {
+		return api.DeploymentPreview{}, err
+	}
+	var preview = api.DeploymentPreview{
+		Branch:        branch,
+		CurrentCommit: status.CommitHash,
+		NewCommits:    []string{},
+		Containers:    status.Containers,
+	}
+
+	// The project has not been cloned yet, so there is nothing to compare to
+	if d.repo == nil {
+		return preview, nil
+	}
+
+	target, err := git.FetchBranchHead(d.repo, git.RepoOptions{
+		Directory: d.directory,
+		Branch:    branch,
+		Auth:      d.auth,
+	})
+	if err != nil {
+		return preview, err
+	}
+	preview.TargetCommit = target.Hash.String()
+
+	// List commits that are not yet deployed
+	commits, err := d.repo.Log(&gogit.LogOptions{From: target.Hash})
+	if err != nil {
+		return preview, err
+	}
+	defer commits.Close()
+	for len(preview.NewCommits) < maxPreviewCommits {
+		commit, err := commits.Next()
+		if err != nil || commit.Hash.String() == status.CommitHash {
+			break
+		}
+		preview.NewCommits = append(preview.NewCommits, fmt.Sprintf("%s %s",
+			commit.Hash.String()[:7], strings.Split(strings.TrimSpace(commit.Message), "\n")[0]))
+	}
+
+	return preview, nil
+}
+
 // GetBranch returns the currently deployed branch
 func (d *Deployment) GetBranch() string {
 	return d.branch
@@ -323,19 +1042,37 @@ func (d *Deployment) GetDataManager() (manager *DeploymentDataManager, found boo
 }
 
 // GetBuildConfiguration returns the build used to build this project. Returns
-// config without env values if error.
+// config without saved env values if error.
 func (d *Deployment) GetBuildConfiguration() (*build.Config, error) {
 	conf := &build.Config{
 		Name:           d.project,
 		BuildFilePath:  d.buildFilePath,
 		BuildDirectory: d.directory,
+
+		ContainerUser:      d.containerUser,
+		EnforceNonRootUser: d.enforceNonRootUser,
+		Platform:           d.platform,
+		BuildTarget:        d.buildTarget,
+		BuildArgs:          d.buildArgs,
+		InitJob:            d.initJob,
+		Labels:             d.labels,
+		Resources:          d.resources,
+		Networking:         d.networking,
+		Healthchecks:       d.healthchecks,
+		Commands:           d.commands,
+		Readiness:          d.readiness,
+		Buildx:             d.buildx,
+		DependsOn:          d.dependsOn,
+		Static:             d.static,
+		VerifyImagesKey:    d.verifyImagesKey,
+		EnvValues:          mergeEnv(nil, d.env),
 	}
 	if d.dataManager != nil {
 		env, err := d.dataManager.GetEnvVariables(true)
 		if err != nil {
 			return conf, err
 		}
-		conf.EnvValues = env
+		conf.EnvValues = mergeEnv(env, d.env)
 	} else {
 		return conf, errors.New("no data manager")
 	}
@@ -375,11 +1112,16 @@ func (d *Deployment) Watch(client *docker.Client) (<-chan string, <-chan error)
 					logsCh <- fmt.Sprintf("container %s has stopped", status.ID[:11])
 				}
 
+				// Inertia's own containers are not part of the project
+				if containers.IsInfrastructureContainer(status.Actor.Attributes["name"]) ||
+					d.isExpectedStop(status.Actor.Attributes) {
+					continue
+				}
 				if d.active {
 					// Shut down all containers if one stops while project is active
 					d.active = false
 					logsCh <- "container stoppage was unexpected, project is active"
-					err := containers.StopActiveContainers(client, os.Stdout)
+					err := d.stopContainers(client, os.Stdout)
 					if err != nil {
 						logsCh <- ("error shutting down other active containers: " + err.Error())
 					}
@@ -1,12 +1,23 @@
 package project
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
 	"testing"
+	"time"
 
 	docker "github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/build/mocks"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	gogit "gopkg.in/src-d/go-git.v4"
@@ -15,7 +26,7 @@ import (
 func newDefaultFakeBuilder(builder func() error, stopper func() error) *mocks.FakeContainerBuilder {
 	var fakeBuilder = &mocks.FakeContainerBuilder{
 		PruneStub:    func(*docker.Client, io.Writer) error { return stopper() },
-		PruneAllStub: func(*docker.Client, io.Writer) error { return stopper() },
+		PruneAllStub: func(*docker.Client, io.Writer, ...string) error { return stopper() },
 	}
 	fakeBuilder.GetBuildStageNameReturns("build")
 	fakeBuilder.BuildReturns(builder, nil)
@@ -37,6 +48,60 @@ func TestSetConfig(t *testing.T) {
 	assert.Equal(t, "/robertcompose.yml", deployment.buildFilePath)
 }
 
+func TestGetStopTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		stopTimeout time.Duration
+		want        time.Duration
+	}{
+		{"default", 0, containers.DefaultStopTimeout},
+		{"configured", 30 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d = &Deployment{}
+			d.SetConfig(DeploymentConfig{StopTimeout: tt.stopTimeout})
+			assert.Equal(t, tt.want, d.getStopTimeout())
+		})
+	}
+}
+
+func TestIsExpectedStop(t *testing.T) {
+	var d = &Deployment{}
+	d.expectStops("web", "docker-compose-recreate")
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		want       bool
+	}{
+		{"unexpected", map[string]string{"name": "project_db_1",
+			"com.docker.compose.service": "db"}, false},
+		{"compose service", map[string]string{"name": "project_web_1",
+			"com.docker.compose.service": "web"}, true},
+		{"compose service already stopped", map[string]string{"name": "project_web_1",
+			"com.docker.compose.service": "web"}, false},
+		{"container", map[string]string{"name": "docker-compose-recreate"}, true},
+		{"no attributes", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, d.isExpectedStop(tt.attributes))
+		})
+	}
+}
+
+func TestRecreateFailed(t *testing.T) {
+	var fakeBuilder = &mocks.FakeContainerBuilder{}
+	fakeBuilder.RecreateReturns(errors.New("service not found"))
+	var d = &Deployment{builder: fakeBuilder, buildType: "docker-compose"}
+
+	// Containers that were never stopped should not be expected to stop
+	assert.NotNil(t, d.Recreate(nil, ioutil.Discard, "web"))
+	assert.False(t, d.isExpectedStop(map[string]string{"com.docker.compose.service": "web"}))
+	assert.False(t, d.isExpectedStop(map[string]string{"name": "docker-compose-recreate"}))
+}
+
 func TestDeployMock(t *testing.T) {
 	var (
 		buildCalled = false
@@ -70,6 +135,126 @@ func TestDeployMock(t *testing.T) {
 	assert.Equal(t, true, stopCalled)
 }
 
+func TestDeployTraced(t *testing.T) {
+	var spans []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct{ Name string }
+				}
+			}
+		}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		for _, s := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+			spans = append(spans, s.Name)
+		}
+	}))
+	defer ts.Close()
+
+	var fakeBuilder = newDefaultFakeBuilder(nil, func() error { return nil })
+	fakeBuilder.BuildStub = func(_ context.Context, _ string, conf build.Config,
+		_ *docker.Client, _ io.Writer) (func() error, error) {
+		return func() error {
+			conf.Started()
+			return nil
+		}, nil
+	}
+	var d = Deployment{
+		directory: "./test/",
+		buildType: "test",
+		builder:   fakeBuilder,
+	}
+	cli, err := containers.NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	var tracer = common.NewTracer(ts.URL, "inertiad", os.Stdout)
+	var trace = tracer.Start("deploy")
+	deploy, err := d.Deploy(cli, os.Stdout, DeployOptions{SkipUpdate: true, Trace: trace})
+	assert.Nil(t, err)
+	assert.Nil(t, deploy())
+	trace.End(nil)
+	tracer.Wait()
+	assert.Equal(t, []string{"deploy", "stop", "build", "start", "healthy"}, spans)
+}
+
+func TestDeployCancelled(t *testing.T) {
+	var fakeBuilder = newDefaultFakeBuilder(nil, func() error { return nil })
+	var d = Deployment{
+		directory: "./test/",
+		buildType: "test",
+		builder:   fakeBuilder,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := d.Deploy(nil, os.Stdout, DeployOptions{SkipUpdate: true, Context: ctx})
+	assert.Equal(t, ErrDeployCancelled, err)
+	assert.Equal(t, 0, fakeBuilder.BuildCallCount())
+	assert.Equal(t, 0, fakeBuilder.StopContainersCallCount())
+}
+
+func TestPendingInitJob(t *testing.T) {
+	dir := "./test_init_job"
+	assert.Nil(t, os.Mkdir(dir, os.ModePerm))
+	defer os.RemoveAll(dir)
+	manager, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	var d = Deployment{dataManager: manager}
+
+	var newConf = func(command string) *build.Config {
+		return &build.Config{
+			InitJob:   &api.InitJob{Command: []string{command}},
+			EnvValues: []string{"A=B"},
+		}
+	}
+
+	// The job should run for the first deployment of a commit
+	conf := newConf("migrate")
+	key := d.pendingInitJob(conf, "abcde", os.Stdout)
+	assert.NotEmpty(t, key)
+	assert.NotNil(t, conf.InitJob)
+	assert.Nil(t, manager.SetInitJobRun(key))
+
+	// The job should not run again for the same commit and configuration
+	conf = newConf("migrate")
+	assert.Empty(t, d.pendingInitJob(conf, "abcde", os.Stdout))
+	assert.Nil(t, conf.InitJob)
+
+	// The job should run again for new commits or configuration
+	conf = newConf("migrate")
+	assert.NotEmpty(t, d.pendingInitJob(conf, "fghij", os.Stdout))
+	assert.NotNil(t, conf.InitJob)
+	conf = newConf("seed")
+	assert.NotEmpty(t, d.pendingInitJob(conf, "abcde", os.Stdout))
+	assert.NotNil(t, conf.InitJob)
+
+	// Deployments without a job have nothing to record
+	assert.Empty(t, d.pendingInitJob(&build.Config{}, "abcde", os.Stdout))
+}
+
+func TestUnpublishSite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-site")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var site = path.Join(dir, "site")
+	assert.Nil(t, os.Mkdir(site, os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(path.Join(site, "index.html"), []byte("home"), 0644))
+
+	var fakeBuilder = newDefaultFakeBuilder(nil, func() error { return nil })
+	fakeBuilder.GetStaticSiteDirectoryReturns(site)
+	var d = Deployment{builder: fakeBuilder}
+	assert.True(t, d.sitePublished())
+	assert.True(t, d.unpublishSite(ioutil.Discard))
+	assert.False(t, d.sitePublished())
+	_, err = os.Stat(site)
+	assert.True(t, os.IsNotExist(err))
+
+	// Nothing to unpublish
+	assert.False(t, d.unpublishSite(ioutil.Discard))
+}
+
 func TestDownIntegration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -153,3 +338,34 @@ func TestDeployment_CompareRemotes(t *testing.T) {
 		})
 	}
 }
+
+func TestFindRetainedDeploy(t *testing.T) {
+	var retained = []string{"ccc333", "bbb222", "abc111", "abd000"}
+	tests := []struct {
+		name    string
+		current string
+		commit  string
+		want    string
+		wantErr bool
+	}{
+		{"previous deployment", "ccc333", "", "bbb222", false},
+		{"previous of rolled back deployment", "bbb222", "", "abc111", false},
+		{"no earlier deployment", "abd000", "", "", true},
+		{"current not retained", "ddd444", "", "", true},
+		{"full commit", "ccc333", "abc111", "abc111", false},
+		{"abbreviated commit", "ccc333", "bb", "bbb222", false},
+		{"ambiguous commit", "ccc333", "ab", "", true},
+		{"unknown commit", "ccc333", "fff", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findRetainedDeploy(retained, tt.current, tt.commit)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package project
+
+import (
+	"fmt"
+	"syscall"
+
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+// InsufficientDiskSpaceError indicates that there is not enough free disk
+// space to safely deploy the project
+type InsufficientDiskSpaceError struct {
+	FreeMB     uint64
+	RequiredMB int
+}
+
+func (e *InsufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("insufficient disk space: %d MB free, but at least %d MB "+
+		"is required - try 'inertia [remote] prune' to free up space",
+		e.FreeMB, e.RequiredMB)
+}
+
+// freeDiskSpace returns the number of bytes available on the filesystem
+// containing the given path
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// checkDiskSpace returns an InsufficientDiskSpaceError if less than minMB
+// megabytes are free on the filesystem containing the given path. A minimum
+// of 0 disables the check.
+func checkDiskSpace(path string, minMB int) error {
+	if minMB <= 0 {
+		return nil
+	}
+	free, err := freeDiskSpace(path)
+	if err != nil {
+		return fmt.Errorf("failed to check free disk space: %s", err.Error())
+	}
+	if freeMB := free / (1024 * 1024); freeMB < uint64(minMB) {
+		return &InsufficientDiskSpaceError{FreeMB: freeMB, RequiredMB: minMB}
+	}
+	return nil
+}
+
+// checkDockerDiskSpace returns an InsufficientDiskSpaceError if less than
+// minMB megabytes are free on the filesystem that Docker stores images and
+// containers on, which is where builds consume the most space. A minimum of 0
+// disables the check.
+func checkDockerDiskSpace(cli *docker.Client, minMB int) error {
+	if minMB <= 0 {
+		return nil
+	}
+	root, err := containers.DockerRootDir(cli)
+	if err != nil {
+		return fmt.Errorf("failed to check free disk space: %s", err.Error())
+	}
+	return checkDiskSpace(root, minMB)
+}
@@ -0,0 +1,39 @@
+package project
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	type args struct {
+		path  string
+		minMB int
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantErr      bool
+		insufficient bool
+	}{
+		{"disabled", args{"./does-not-exist", 0}, false, false},
+		{"enough space", args{".", 1}, false, false},
+		{"not enough space", args{".", math.MaxInt32}, true, true},
+		{"invalid path", args{"./does-not-exist", 1}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDiskSpace(tt.args.path, tt.args.minMB)
+			assert.Equal(t, tt.wantErr, err != nil)
+			_, insufficient := err.(*InsufficientDiskSpaceError)
+			assert.Equal(t, tt.insufficient, insufficient)
+		})
+	}
+}
+
+func TestCheckDockerDiskSpace(t *testing.T) {
+	// Docker should not be queried if the check is disabled
+	assert.Nil(t, checkDockerDiskSpace(nil, 0))
+}
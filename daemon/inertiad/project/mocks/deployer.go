@@ -108,6 +108,20 @@ type FakeDeployer struct {
 	initializeReturnsOnCall map[int]struct {
 		result1 error
 	}
+	PreviewStub        func(*client.Client, project.DeploymentConfig) (api.DeploymentPreview, error)
+	previewMutex       sync.RWMutex
+	previewArgsForCall []struct {
+		arg1 *client.Client
+		arg2 project.DeploymentConfig
+	}
+	previewReturns struct {
+		result1 api.DeploymentPreview
+		result2 error
+	}
+	previewReturnsOnCall map[int]struct {
+		result1 api.DeploymentPreview
+		result2 error
+	}
 	PruneStub        func(*client.Client, io.Writer) error
 	pruneMutex       sync.RWMutex
 	pruneArgsForCall []struct {
@@ -120,11 +134,53 @@ type FakeDeployer struct {
 	pruneReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RecreateStub        func(*client.Client, io.Writer, string) error
+	recreateMutex       sync.RWMutex
+	recreateArgsForCall []struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 string
+	}
+	recreateReturns struct {
+		result1 error
+	}
+	recreateReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RollbackStub        func(*client.Client, io.Writer, string) (func() error, error)
+	rollbackMutex       sync.RWMutex
+	rollbackArgsForCall []struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 string
+	}
+	rollbackReturns struct {
+		result1 func() error
+		result2 error
+	}
+	rollbackReturnsOnCall map[int]struct {
+		result1 func() error
+		result2 error
+	}
 	SetConfigStub        func(project.DeploymentConfig)
 	setConfigMutex       sync.RWMutex
 	setConfigArgsForCall []struct {
 		arg1 project.DeploymentConfig
 	}
+	UpdateBaseImagesStub        func(*client.Client, io.Writer) ([]string, error)
+	updateBaseImagesMutex       sync.RWMutex
+	updateBaseImagesArgsForCall []struct {
+		arg1 *client.Client
+		arg2 io.Writer
+	}
+	updateBaseImagesReturns struct {
+		result1 []string
+		result2 error
+	}
+	updateBaseImagesReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	WatchStub        func(*client.Client) (<-chan string, <-chan error)
 	watchMutex       sync.RWMutex
 	watchArgsForCall []struct {
@@ -620,6 +676,70 @@ func (fake *FakeDeployer) InitializeReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeDeployer) Preview(arg1 *client.Client, arg2 project.DeploymentConfig) (api.DeploymentPreview, error) {
+	fake.previewMutex.Lock()
+	ret, specificReturn := fake.previewReturnsOnCall[len(fake.previewArgsForCall)]
+	fake.previewArgsForCall = append(fake.previewArgsForCall, struct {
+		arg1 *client.Client
+		arg2 project.DeploymentConfig
+	}{arg1, arg2})
+	fake.recordInvocation("Preview", []interface{}{arg1, arg2})
+	fake.previewMutex.Unlock()
+	if fake.PreviewStub != nil {
+		return fake.PreviewStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.previewReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) PreviewCallCount() int {
+	fake.previewMutex.RLock()
+	defer fake.previewMutex.RUnlock()
+	return len(fake.previewArgsForCall)
+}
+
+func (fake *FakeDeployer) PreviewCalls(stub func(*client.Client, project.DeploymentConfig) (api.DeploymentPreview, error)) {
+	fake.previewMutex.Lock()
+	defer fake.previewMutex.Unlock()
+	fake.PreviewStub = stub
+}
+
+func (fake *FakeDeployer) PreviewArgsForCall(i int) (*client.Client, project.DeploymentConfig) {
+	fake.previewMutex.RLock()
+	defer fake.previewMutex.RUnlock()
+	argsForCall := fake.previewArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeDeployer) PreviewReturns(result1 api.DeploymentPreview, result2 error) {
+	fake.previewMutex.Lock()
+	defer fake.previewMutex.Unlock()
+	fake.PreviewStub = nil
+	fake.previewReturns = struct {
+		result1 api.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PreviewReturnsOnCall(i int, result1 api.DeploymentPreview, result2 error) {
+	fake.previewMutex.Lock()
+	defer fake.previewMutex.Unlock()
+	fake.PreviewStub = nil
+	if fake.previewReturnsOnCall == nil {
+		fake.previewReturnsOnCall = make(map[int]struct {
+			result1 api.DeploymentPreview
+			result2 error
+		})
+	}
+	fake.previewReturnsOnCall[i] = struct {
+		result1 api.DeploymentPreview
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeDeployer) Prune(arg1 *client.Client, arg2 io.Writer) error {
 	fake.pruneMutex.Lock()
 	ret, specificReturn := fake.pruneReturnsOnCall[len(fake.pruneArgsForCall)]
@@ -681,6 +801,133 @@ func (fake *FakeDeployer) PruneReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeDeployer) Recreate(arg1 *client.Client, arg2 io.Writer, arg3 string) error {
+	fake.recreateMutex.Lock()
+	ret, specificReturn := fake.recreateReturnsOnCall[len(fake.recreateArgsForCall)]
+	fake.recreateArgsForCall = append(fake.recreateArgsForCall, struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Recreate", []interface{}{arg1, arg2, arg3})
+	fake.recreateMutex.Unlock()
+	if fake.RecreateStub != nil {
+		return fake.RecreateStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.recreateReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeDeployer) RecreateCallCount() int {
+	fake.recreateMutex.RLock()
+	defer fake.recreateMutex.RUnlock()
+	return len(fake.recreateArgsForCall)
+}
+
+func (fake *FakeDeployer) RecreateCalls(stub func(*client.Client, io.Writer, string) error) {
+	fake.recreateMutex.Lock()
+	defer fake.recreateMutex.Unlock()
+	fake.RecreateStub = stub
+}
+
+func (fake *FakeDeployer) RecreateArgsForCall(i int) (*client.Client, io.Writer, string) {
+	fake.recreateMutex.RLock()
+	defer fake.recreateMutex.RUnlock()
+	argsForCall := fake.recreateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeDeployer) RecreateReturns(result1 error) {
+	fake.recreateMutex.Lock()
+	defer fake.recreateMutex.Unlock()
+	fake.RecreateStub = nil
+	fake.recreateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDeployer) RecreateReturnsOnCall(i int, result1 error) {
+	fake.recreateMutex.Lock()
+	defer fake.recreateMutex.Unlock()
+	fake.RecreateStub = nil
+	if fake.recreateReturnsOnCall == nil {
+		fake.recreateReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.recreateReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDeployer) Rollback(arg1 *client.Client, arg2 io.Writer, arg3 string) (func() error, error) {
+	fake.rollbackMutex.Lock()
+	ret, specificReturn := fake.rollbackReturnsOnCall[len(fake.rollbackArgsForCall)]
+	fake.rollbackArgsForCall = append(fake.rollbackArgsForCall, struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Rollback", []interface{}{arg1, arg2, arg3})
+	fake.rollbackMutex.Unlock()
+	if fake.RollbackStub != nil {
+		return fake.RollbackStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.rollbackReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) RollbackCallCount() int {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	return len(fake.rollbackArgsForCall)
+}
+
+func (fake *FakeDeployer) RollbackCalls(stub func(*client.Client, io.Writer, string) (func() error, error)) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = stub
+}
+
+func (fake *FakeDeployer) RollbackArgsForCall(i int) (*client.Client, io.Writer, string) {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	argsForCall := fake.rollbackArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeDeployer) RollbackReturns(result1 func() error, result2 error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = nil
+	fake.rollbackReturns = struct {
+		result1 func() error
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) RollbackReturnsOnCall(i int, result1 func() error, result2 error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = nil
+	if fake.rollbackReturnsOnCall == nil {
+		fake.rollbackReturnsOnCall = make(map[int]struct {
+			result1 func() error
+			result2 error
+		})
+	}
+	fake.rollbackReturnsOnCall[i] = struct {
+		result1 func() error
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeDeployer) SetConfig(arg1 project.DeploymentConfig) {
 	fake.setConfigMutex.Lock()
 	fake.setConfigArgsForCall = append(fake.setConfigArgsForCall, struct {
@@ -712,6 +959,70 @@ func (fake *FakeDeployer) SetConfigArgsForCall(i int) project.DeploymentConfig {
 	return argsForCall.arg1
 }
 
+func (fake *FakeDeployer) UpdateBaseImages(arg1 *client.Client, arg2 io.Writer) ([]string, error) {
+	fake.updateBaseImagesMutex.Lock()
+	ret, specificReturn := fake.updateBaseImagesReturnsOnCall[len(fake.updateBaseImagesArgsForCall)]
+	fake.updateBaseImagesArgsForCall = append(fake.updateBaseImagesArgsForCall, struct {
+		arg1 *client.Client
+		arg2 io.Writer
+	}{arg1, arg2})
+	fake.recordInvocation("UpdateBaseImages", []interface{}{arg1, arg2})
+	fake.updateBaseImagesMutex.Unlock()
+	if fake.UpdateBaseImagesStub != nil {
+		return fake.UpdateBaseImagesStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.updateBaseImagesReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) UpdateBaseImagesCallCount() int {
+	fake.updateBaseImagesMutex.RLock()
+	defer fake.updateBaseImagesMutex.RUnlock()
+	return len(fake.updateBaseImagesArgsForCall)
+}
+
+func (fake *FakeDeployer) UpdateBaseImagesCalls(stub func(*client.Client, io.Writer) ([]string, error)) {
+	fake.updateBaseImagesMutex.Lock()
+	defer fake.updateBaseImagesMutex.Unlock()
+	fake.UpdateBaseImagesStub = stub
+}
+
+func (fake *FakeDeployer) UpdateBaseImagesArgsForCall(i int) (*client.Client, io.Writer) {
+	fake.updateBaseImagesMutex.RLock()
+	defer fake.updateBaseImagesMutex.RUnlock()
+	argsForCall := fake.updateBaseImagesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeDeployer) UpdateBaseImagesReturns(result1 []string, result2 error) {
+	fake.updateBaseImagesMutex.Lock()
+	defer fake.updateBaseImagesMutex.Unlock()
+	fake.UpdateBaseImagesStub = nil
+	fake.updateBaseImagesReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) UpdateBaseImagesReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.updateBaseImagesMutex.Lock()
+	defer fake.updateBaseImagesMutex.Unlock()
+	fake.UpdateBaseImagesStub = nil
+	if fake.updateBaseImagesReturnsOnCall == nil {
+		fake.updateBaseImagesReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.updateBaseImagesReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeDeployer) Watch(arg1 *client.Client) (<-chan string, <-chan error) {
 	fake.watchMutex.Lock()
 	ret, specificReturn := fake.watchReturnsOnCall[len(fake.watchArgsForCall)]
@@ -794,10 +1105,18 @@ func (fake *FakeDeployer) Invocations() map[string][][]interface{} {
 	defer fake.getStatusMutex.RUnlock()
 	fake.initializeMutex.RLock()
 	defer fake.initializeMutex.RUnlock()
+	fake.previewMutex.RLock()
+	defer fake.previewMutex.RUnlock()
 	fake.pruneMutex.RLock()
 	defer fake.pruneMutex.RUnlock()
+	fake.recreateMutex.RLock()
+	defer fake.recreateMutex.RUnlock()
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
 	fake.setConfigMutex.RLock()
 	defer fake.setConfigMutex.RUnlock()
+	fake.updateBaseImagesMutex.RLock()
+	defer fake.updateBaseImagesMutex.RUnlock()
 	fake.watchMutex.RLock()
 	defer fake.watchMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
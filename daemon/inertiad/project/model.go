@@ -1,7 +1,171 @@
 package project
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
 type envVariable struct {
 	Name      string
 	Value     []byte
 	Encrypted bool
 }
+
+// release is a name given to a deployed commit
+type release struct {
+	Commit  string    `json:"commit"`
+	Created time.Time `json:"created"`
+}
+
+// DeployedConfig records the configuration a deployment was made with
+type DeployedConfig struct {
+	ProjectName   string `json:"project"`
+	BuildType     string `json:"build_type"`
+	BuildFilePath string `json:"build_file_path"`
+	Branch        string `json:"branch"`
+
+	// EnvHash is a hash of the deployment's environment variables, so that
+	// changes can be detected without storing values in plaintext
+	EnvHash string `json:"env_hash"`
+
+	// Settings are hashes of each remaining deployment setting, keyed by
+	// setting name, so that values such as build arguments are not stored
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// recordedFields are the DeploymentConfig fields that DeployedConfig stores
+// outside of Settings
+var recordedFields = map[string]bool{
+	"ProjectName":   true,
+	"BuildType":     true,
+	"BuildFilePath": true,
+	"Branch":        true,
+	"Env":           true,
+}
+
+// NewDeployedConfig creates a record of given configuration and saved
+// environment variables, which are combined with those in the configuration
+func NewDeployedConfig(cfg DeploymentConfig, env []string) DeployedConfig {
+	var sorted = mergeEnv(env, cfg.Env)
+	sort.Strings(sorted)
+	var sum = sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return DeployedConfig{
+		ProjectName:   cfg.ProjectName,
+		BuildType:     cfg.BuildType,
+		BuildFilePath: cfg.BuildFilePath,
+		Branch:        cfg.Branch,
+		EnvHash:       hex.EncodeToString(sum[:]),
+		Settings:      hashSettings(cfg),
+	}
+}
+
+// hashSettings hashes every DeploymentConfig field that is not otherwise
+// recorded, keyed by a readable name for the field
+func hashSettings(cfg DeploymentConfig) map[string]string {
+	var (
+		settings = map[string]string{}
+		v        = reflect.ValueOf(cfg)
+	)
+	for i := 0; i < v.NumField(); i++ {
+		var field = v.Type().Field(i)
+		if recordedFields[field.Name] {
+			continue
+		}
+		// maps are encoded with sorted keys, so equal settings hash equally
+		encoded, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			encoded = []byte(fmt.Sprintf("%v", v.Field(i).Interface()))
+		}
+		var sum = sha256.Sum256(encoded)
+		settings[settingName(field.Name)] = hex.EncodeToString(sum[:])
+	}
+	return settings
+}
+
+// settingName converts a field name such as "BuildArgs" to "build args"
+func settingName(field string) string {
+	var name strings.Builder
+	for i, r := range field {
+		if i > 0 && unicode.IsUpper(r) {
+			name.WriteRune(' ')
+		}
+		name.WriteRune(unicode.ToLower(r))
+	}
+	return name.String()
+}
+
+// newInitJobKey identifies a run of the given init job for the given commit
+// with the given environment variables, so that the job is only run again
+// once any of them change
+func newInitJobKey(commit string, job *api.InitJob, env []string) string {
+	var sorted = append([]string{}, env...)
+	sort.Strings(sorted)
+	encoded, _ := json.Marshal(job)
+	var sum = sha256.Sum256([]byte(commit + "\n" + string(encoded) + "\n" +
+		strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// mergeEnv combines environment variables in the form "NAME=value" with the
+// given variables keyed by name, which replace variables of the same name
+func mergeEnv(env []string, overrides map[string]string) []string {
+	var merged = make([]string, 0, len(env)+len(overrides))
+	for _, v := range env {
+		if _, found := overrides[strings.SplitN(v, "=", 2)[0]]; !found {
+			merged = append(merged, v)
+		}
+	}
+	var names = make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged = append(merged, name+"="+overrides[name])
+	}
+	return merged
+}
+
+// Changes describes each difference between this configuration and the given
+// previous configuration
+func (c DeployedConfig) Changes(previous DeployedConfig) []string {
+	var changes = []string{}
+	var compare = func(name, prev, curr string) {
+		if prev != curr {
+			changes = append(changes, fmt.Sprintf("%s changed from '%s' to '%s'", name, prev, curr))
+		}
+	}
+	compare("project name", previous.ProjectName, c.ProjectName)
+	compare("build type", previous.BuildType, c.BuildType)
+	compare("build file path", previous.BuildFilePath, c.BuildFilePath)
+	compare("branch", previous.Branch, c.Branch)
+	if previous.EnvHash != c.EnvHash {
+		changes = append(changes, "environment variables changed")
+	}
+
+	// configurations recorded before settings were tracked have no settings
+	// to compare against
+	if previous.Settings == nil {
+		return changes
+	}
+	var names = make([]string, 0, len(c.Settings))
+	for name := range c.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prev, found := previous.Settings[name]; found && prev != c.Settings[name] {
+			changes = append(changes, name+" changed")
+		}
+	}
+	return changes
+}
@@ -64,3 +64,9 @@ func (b bitbucketPushEvent) GetGitURL() string {
 func (b bitbucketPushEvent) GetSSHURL() string {
 	return "git@bitbucket.org:" + b.fullName + ".git"
 }
+
+// GetChangedFiles returns nil - Bitbucket push payloads do not include the
+// files changed by the push
+func (b bitbucketPushEvent) GetChangedFiles() []string {
+	return nil
+}
@@ -8,6 +8,7 @@ type githubPushEvent struct {
 	name      string
 	gitURL    string
 	sshURL    string
+	changed   []string
 }
 
 func parseGithubPushEvent(rawJSON map[string]interface{}) githubPushEvent {
@@ -21,12 +22,28 @@ func parseGithubPushEvent(rawJSON map[string]interface{}) githubPushEvent {
 	gitURL := repo["clone_url"].(string)
 	sshURL := repo["ssh_url"].(string)
 
+	// Extract changed files - the head commit is included in the list of
+	// commits except for some new branches, so fall back to it
+	commits, _ := rawJSON["commits"].([]interface{})
+	if len(commits) == 0 {
+		if head, ok := rawJSON["head_commit"]; ok && head != nil {
+			commits = []interface{}{head}
+		}
+	}
+	changed := parseChangedFiles(commits)
+
+	// Pushes with more commits than are included may have changed other files
+	if len(commits) >= maxPushCommits {
+		changed = nil
+	}
+
 	return githubPushEvent{
 		eventType: PushEvent,
 		ref:       ref,
 		name:      name,
 		gitURL:    gitURL,
 		sshURL:    sshURL,
+		changed:   changed,
 	}
 }
 
@@ -59,3 +76,8 @@ func (g githubPushEvent) GetGitURL() string {
 func (g githubPushEvent) GetSSHURL() string {
 	return g.sshURL
 }
+
+// GetChangedFiles returns the files changed by the pushed commits
+func (g githubPushEvent) GetChangedFiles() []string {
+	return g.changed
+}
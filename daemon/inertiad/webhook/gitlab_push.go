@@ -8,6 +8,7 @@ type gitlabPushEvent struct {
 	name      string
 	gitURL    string
 	sshURL    string
+	changed   []string
 }
 
 func parseGitlabPushEvent(rawJSON map[string]interface{}) gitlabPushEvent {
@@ -19,12 +20,23 @@ func parseGitlabPushEvent(rawJSON map[string]interface{}) gitlabPushEvent {
 	gitURL := repo["git_http_url"].(string)
 	sshURL := repo["git_ssh_url"].(string)
 
+	commits, _ := rawJSON["commits"].([]interface{})
+	changed := parseChangedFiles(commits)
+
+	// Pushes with more commits than are included may have changed other files
+	if total, ok := rawJSON["total_commits_count"].(float64); ok && int(total) > len(commits) {
+		changed = nil
+	} else if !ok && len(commits) >= maxPushCommits {
+		changed = nil
+	}
+
 	return gitlabPushEvent{
 		eventType: PushEvent,
 		ref:       ref,
 		name:      name,
 		gitURL:    gitURL,
 		sshURL:    sshURL,
+		changed:   changed,
 	}
 }
 
@@ -57,3 +69,8 @@ func (g gitlabPushEvent) GetGitURL() string {
 func (g gitlabPushEvent) GetSSHURL() string {
 	return g.sshURL
 }
+
+// GetChangedFiles returns the files changed by the pushed commits
+func (g gitlabPushEvent) GetChangedFiles() []string {
+	return g.changed
+}
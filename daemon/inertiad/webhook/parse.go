@@ -3,6 +3,8 @@ package webhook
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
 )
@@ -27,21 +29,34 @@ type Payload interface {
 	GetRef() string
 	GetGitURL() string
 	GetSSHURL() string
+
+	// GetChangedFiles returns the paths of files added, modified, or removed
+	// by the event, or nil if the host does not provide them
+	GetChangedFiles() []string
 }
 
-// Parse takes in a webhook request and parses it into one of the supported types
-func Parse(host, eventHeader string, h http.Header, body []byte) (Payload, error) {
+// Parse takes in a webhook request body and parses it into one of the
+// supported types. The body is decoded as it is read, so only the decoded
+// payload is held in memory.
+func Parse(host, eventHeader string, h http.Header, body io.Reader) (payload Payload, err error) {
 	// todo: more content-types
 	if h.Get("content-type") != "application/json" {
 		return nil, errors.New("Webhook Content-Type must be JSON")
 	}
 
 	// Decode request body to raw JSON
-	var raw interface{}
-	if err := json.Unmarshal(body, &raw); err != nil {
+	var rawJSON map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&rawJSON); err != nil {
 		return nil, err
 	}
-	rawJSON := raw.(map[string]interface{})
+
+	// Payloads are parsed before their signatures are verified, so payloads
+	// missing expected fields must be rejected instead of crashing the parser
+	defer func() {
+		if r := recover(); r != nil {
+			payload, err = nil, fmt.Errorf("malformed %s payload: %v", host, r)
+		}
+	}()
 
 	// Parse into one of supported types
 	switch host {
@@ -2,7 +2,7 @@ package webhook
 
 import (
 	"bytes"
-	"io/ioutil"
+	"encoding/json"
 	"net/http"
 	"testing"
 
@@ -23,10 +23,13 @@ func TestTypeAndParse(t *testing.T) {
 		reqBody     []byte
 		eventHeader string
 		eventValue  string
+		changed     []string
 	}{
-		{GitHub, githubPushRawJSON, "x-github-event", GithubPushHeader},
-		{GitLab, gitlabPushRawJSON, "x-gitlab-event", GitlabPushHeader},
-		{BitBucket, bitbucketPushRawJSON, "x-event-key", BitbucketPushHeader},
+		{GitHub, githubPushRawJSON, "x-github-event", GithubPushHeader,
+			[]string{"README.md"}},
+		{GitLab, gitlabPushRawJSON, "x-gitlab-event", GitlabPushHeader,
+			[]string{"README.md", "README.md", "README.md"}},
+		{BitBucket, bitbucketPushRawJSON, "x-event-key", BitbucketPushHeader, nil},
 	}
 	for _, tc := range testCases {
 		req := getMockRequest("/webhook", tc.reqBody)
@@ -40,19 +43,75 @@ func TestTypeAndParse(t *testing.T) {
 		// Parse type
 		host, event := Type(req.Header)
 
-		// Read
-		body, err := ioutil.ReadAll(req.Body)
-		assert.Nil(t, err)
-
 		// Parse payload
-		payload, err := Parse(host, event, req.Header, body)
+		payload, err := Parse(host, event, req.Header, req.Body)
 		assert.Nil(t, err)
 
 		assert.Equal(t, tc.source, payload.GetSource())
 		assert.Equal(t, "push", payload.GetEventType())
 		assert.Equal(t, "inertia-deploy-test", payload.GetRepoName())
 		assert.Equal(t, "refs/heads/master", payload.GetRef())
+		assert.Equal(t, tc.changed, payload.GetChangedFiles())
+	}
+}
+
+func TestParseTruncatedPush(t *testing.T) {
+	var commits = make([]map[string]interface{}, 0, maxPushCommits)
+	for i := 0; i < maxPushCommits; i++ {
+		commits = append(commits, map[string]interface{}{"modified": []string{"README.md"}})
+	}
+	var repo = map[string]interface{}{
+		"name": "inertia", "clone_url": "", "ssh_url": "",
+		"git_http_url": "", "git_ssh_url": "",
 	}
+	tests := []struct {
+		name        string
+		eventHeader string
+		eventValue  string
+		payload     map[string]interface{}
+		wantChanged bool
+	}{
+		{"github with all commits", "x-github-event", GithubPushHeader, map[string]interface{}{
+			"ref": "refs/heads/master", "repository": repo, "commits": commits[:1],
+		}, true},
+		{"github with too many commits", "x-github-event", GithubPushHeader, map[string]interface{}{
+			"ref": "refs/heads/master", "repository": repo, "commits": commits,
+		}, false},
+		{"gitlab with all commits", "x-gitlab-event", GitlabPushHeader, map[string]interface{}{
+			"ref": "refs/heads/master", "repository": repo, "commits": commits,
+			"total_commits_count": maxPushCommits,
+		}, true},
+		{"gitlab with too many commits", "x-gitlab-event", GitlabPushHeader, map[string]interface{}{
+			"ref": "refs/heads/master", "repository": repo, "commits": commits,
+			"total_commits_count": 35,
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.payload)
+			assert.Nil(t, err)
+			req := getMockRequest("/webhook", body)
+			req.Header.Add(tt.eventHeader, tt.eventValue)
+
+			// Changed files should be unknown if any may be missing
+			host, event := Type(req.Header)
+			payload, err := Parse(host, event, req.Header, req.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantChanged, payload.GetChangedFiles() != nil)
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	req := getMockRequest("/webhook", []byte(`{"yo":true}`))
+	req.Header.Add("x-github-event", GithubPushHeader)
+
+	// Payloads missing expected fields should be rejected
+	host, event := Type(req.Header)
+	payload, err := Parse(host, event, req.Header, req.Body)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "malformed github payload")
+	assert.Nil(t, payload)
 }
 
 func TestParseDocker(t *testing.T) {
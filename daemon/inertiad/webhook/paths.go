@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"path"
+	"strings"
+)
+
+// maxPushCommits is the most commits GitHub and GitLab include in push events -
+// the changed files of larger pushes are unknown
+const maxPushCommits = 20
+
+// parseChangedFiles collects the added, modified, and removed files from the
+// given raw JSON commits, as provided by GitHub and GitLab push events
+func parseChangedFiles(commits []interface{}) []string {
+	var changed = []string{}
+	for _, c := range commits {
+		commit, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"added", "modified", "removed"} {
+			files, _ := commit[key].([]interface{})
+			for _, f := range files {
+				if file, ok := f.(string); ok {
+					changed = append(changed, file)
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// ChangesMatch checks if any of the changed files match any of the given
+// paths. A path matches a file if it is a parent directory of the file or if
+// it is a glob pattern that matches the file. If either the changed files
+// (for example, if a webhook did not provide them) or the paths are nil, all
+// changes are considered relevant.
+func ChangesMatch(changed []string, paths []string) bool {
+	if changed == nil || len(paths) == 0 {
+		return true
+	}
+	for _, file := range changed {
+		for _, p := range paths {
+			p = strings.TrimPrefix(path.Clean(p), "./")
+			if p == "." || file == p || strings.HasPrefix(file, p+"/") {
+				return true
+			}
+			if matched, _ := path.Match(p, file); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
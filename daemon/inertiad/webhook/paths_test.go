@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangesMatch(t *testing.T) {
+	type args struct {
+		changed []string
+		paths   []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"no paths", args{[]string{"README.md"}, nil}, true},
+		{"no changes provided", args{nil, []string{"api"}}, true},
+		{"no changes", args{[]string{}, []string{"api"}}, false},
+		{"exact file", args{[]string{"api/main.go"}, []string{"api/main.go"}}, true},
+		{"directory", args{[]string{"api/cmd/main.go"}, []string{"api"}}, true},
+		{"directory with slash", args{[]string{"api/main.go"}, []string{"./api/"}}, true},
+		{"directory prefix only", args{[]string{"apiserver/main.go"}, []string{"api"}}, false},
+		{"glob", args{[]string{"web/index.js"}, []string{"web/*.js"}}, true},
+		{"unrelated", args{[]string{"README.md", "docs/intro.md"}, []string{"api", "web/*.js"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ChangesMatch(tt.args.changed, tt.args.paths))
+		})
+	}
+}
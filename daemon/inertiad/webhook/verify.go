@@ -1,7 +1,10 @@
 package webhook
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
@@ -16,28 +19,38 @@ const (
 // Verify ensures the payload's integrity and returns and error if anything
 // doesn't match up
 func Verify(host, key string, h http.Header, body []byte) (err error) {
+	reader, verify := NewVerifier(host, key, h, bytes.NewReader(body))
+	io.Copy(ioutil.Discard, reader)
+	return verify()
+}
+
+// NewVerifier wraps body in a reader that checks the payload's integrity as it
+// is read, so that large payloads need not be held in memory. Once body has
+// been read completely, verify returns an error if anything doesn't match up.
+func NewVerifier(host, key string, h http.Header, body io.Reader) (reader io.Reader, verify func() error) {
 	switch host {
 	case BitBucket:
 		// Bitbucket server has HMAC verification (same as GitHub), but not
 		// the standard Bitbucket, it seems.
 		if h.Get(xHubSignatureHeader) == "" {
-			return nil // assume the event is valid
+			// assume the event is valid
+			return body, func() error { return nil }
 		}
 		fallthrough // use same validation as GitHub
 	case GitHub:
 		// https://developer.github.com/webhooks/securing/
-		return crypto.ValidateSignature(
-			h.Get(xHubSignatureHeader),
-			body,
-			[]byte(key))
+		var signature = crypto.NewSignatureWriter(h.Get(xHubSignatureHeader), []byte(key))
+		return io.TeeReader(body, signature), signature.Validate
 	case GitLab:
 		// https://docs.gitlab.com/ee/user/project/integrations/webhooks.html#secret-token
 		token := h.Get(gitlabTokenHeader)
-		if token != key {
-			return errors.New("invalid webhook token")
+		return body, func() error {
+			if token != key {
+				return errors.New("invalid webhook token")
+			}
+			return nil
 		}
-		return nil
 	default:
-		return errors.New("unsupported type")
+		return body, func() error { return errors.New("unsupported type") }
 	}
 }
@@ -0,0 +1,101 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// generatedKeyMarker is part of the name of every key pair generated when
+// provisioning a remote, followed by the time the key was generated
+const generatedKeyMarker = "_inertia_key_"
+
+// GeneratedKey is a private key saved when provisioning a remote
+type GeneratedKey struct {
+	Path    string
+	Created time.Time
+}
+
+// IsGeneratedKey checks if the key pair or key file with the given name was
+// generated when provisioning a remote
+func IsGeneratedKey(name string) bool {
+	var marker = strings.LastIndex(name, generatedKeyMarker)
+	if marker < 0 {
+		return false
+	}
+	_, err := strconv.ParseInt(name[marker+len(generatedKeyMarker):], 10, 64)
+	return err == nil
+}
+
+// GetKeyDirectory returns the directory generated keys are saved in
+func GetKeyDirectory() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh")
+}
+
+// ListGeneratedKeys returns the keys in dir that were generated when
+// provisioning remotes, optionally only those whose names start with prefix,
+// most recently generated first
+func ListGeneratedKeys(dir, prefix string) ([]GeneratedKey, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys = []GeneratedKey{}
+	for _, f := range files {
+		// PuTTY copies and other files named after keys are not keys
+		var name = f.Name()
+		if f.IsDir() || !IsGeneratedKey(name) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		var marker = strings.LastIndex(name, generatedKeyMarker)
+		nanos, _ := strconv.ParseInt(name[marker+len(generatedKeyMarker):], 10, 64)
+		keys = append(keys, GeneratedKey{
+			Path:    filepath.Join(dir, name),
+			Created: time.Unix(0, nanos),
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.After(keys[j].Created) })
+	return keys, nil
+}
+
+// RemoveKey deletes the private key at path, along with its PuTTY copy if
+// there is one. Keys that do not exist are ignored.
+func RemoveKey(path string) error {
+	for _, p := range []string{path, path + ".ppk"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneGeneratedKeys removes all but the keep most recently generated keys
+// in dir whose names start with prefix, and returns the paths of removed
+// keys. Keys in inUse, keyed by path, are never removed and do not count
+// towards keep.
+func PruneGeneratedKeys(dir, prefix string, keep int, inUse map[string]bool) ([]string, error) {
+	keys, err := ListGeneratedKeys(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var removed = []string{}
+	var kept = 0
+	for _, k := range keys {
+		if inUse[k.Path] {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if err := RemoveKey(k.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, k.Path)
+	}
+	return removed, nil
+}
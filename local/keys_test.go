@@ -0,0 +1,116 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKeys(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600))
+	}
+}
+
+func TestIsGeneratedKey(t *testing.T) {
+	assert.True(t, IsGeneratedKey("app_prod_ubuntu_inertia_key_1560000000"))
+	assert.False(t, IsGeneratedKey("app_prod_ubuntu_inertia_key_1560000000.ppk"))
+	assert.False(t, IsGeneratedKey("my-key"))
+}
+
+func TestListGeneratedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeKeys(t, dir,
+		"app_prod_ubuntu_inertia_key_100",
+		"app_prod_ubuntu_inertia_key_100.ppk",
+		"app_dev_ubuntu_inertia_key_300",
+		"other_prod_ubuntu_inertia_key_200",
+		"id_rsa", "known_hosts")
+
+	keys, err := ListGeneratedKeys(dir, "")
+	assert.Nil(t, err)
+	var paths = []string{}
+	for _, k := range keys {
+		paths = append(paths, filepath.Base(k.Path))
+	}
+	assert.Equal(t, []string{
+		"app_dev_ubuntu_inertia_key_300",
+		"other_prod_ubuntu_inertia_key_200",
+		"app_prod_ubuntu_inertia_key_100",
+	}, paths)
+
+	keys, err = ListGeneratedKeys(dir, "other_")
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+
+	_, err = ListGeneratedKeys(filepath.Join(dir, "missing"), "")
+	assert.NotNil(t, err)
+}
+
+func TestRemoveKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeKeys(t, dir, "key", "key.ppk", "other")
+
+	assert.Nil(t, RemoveKey(filepath.Join(dir, "key")))
+	assert.Nil(t, RemoveKey(filepath.Join(dir, "missing")))
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "other", files[0].Name())
+}
+
+func TestPruneGeneratedKeys(t *testing.T) {
+	type args struct {
+		keep  int
+		inUse []string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantRemoved []string
+	}{
+		{"keep all", args{5, nil}, []string{}},
+		{"keep newest", args{1, nil}, []string{
+			"app_b_ubuntu_inertia_key_200", "app_a_ubuntu_inertia_key_100"}},
+		{"keep none", args{0, nil}, []string{
+			"app_c_ubuntu_inertia_key_300", "app_b_ubuntu_inertia_key_200",
+			"app_a_ubuntu_inertia_key_100"}},
+		{"keys in use", args{1, []string{"app_c_ubuntu_inertia_key_300"}}, []string{
+			"app_a_ubuntu_inertia_key_100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-keys")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			writeKeys(t, dir,
+				"app_a_ubuntu_inertia_key_100",
+				"app_b_ubuntu_inertia_key_200",
+				"app_c_ubuntu_inertia_key_300",
+				"other_a_ubuntu_inertia_key_50")
+
+			var inUse = map[string]bool{}
+			for _, k := range tt.args.inUse {
+				inUse[filepath.Join(dir, k)] = true
+			}
+			removed, err := PruneGeneratedKeys(dir, "app_", tt.args.keep, inUse)
+			assert.Nil(t, err)
+			var names = []string{}
+			for _, r := range removed {
+				names = append(names, filepath.Base(r))
+			}
+			assert.Equal(t, tt.wantRemoved, names)
+
+			// Keys of other projects are left alone
+			_, err = os.Stat(filepath.Join(dir, "other_a_ubuntu_inertia_key_50"))
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/cfg"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultSSHTimeout is how long to wait for SSH connections to be established
+// if no timeout is configured
+const DefaultSSHTimeout = 15 * time.Second
+
+// GetSSHTimeout returns how long to wait for SSH connections to be
+// established with the given SSH options
+func GetSSHTimeout(opts *cfg.SSHOptions) time.Duration {
+	if opts != nil && opts.Timeout > 0 {
+		return time.Duration(opts.Timeout) * time.Second
+	}
+	return DefaultSSHTimeout
+}
+
+// GetKnownHostsFile returns the known hosts file used with the given SSH
+// options - ~/.ssh/known_hosts unless another file is configured
+func GetKnownHostsFile(opts *cfg.SSHOptions) string {
+	if opts != nil && opts.KnownHostsFile != "" {
+		return opts.KnownHostsFile
+	}
+	return filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+}
+
+// GetHostKeyChecking returns the host key checking mode used with the given
+// SSH options - cfg.HostKeyCheckingAcceptNew unless another is configured
+func GetHostKeyChecking(opts *cfg.SSHOptions) string {
+	if opts != nil && opts.HostKeyChecking != "" {
+		return opts.HostKeyChecking
+	}
+	return cfg.HostKeyCheckingAcceptNew
+}
+
+// HostKeyCallback returns a callback that verifies host keys against the given
+// known hosts file, using the given host key checking mode. With
+// cfg.HostKeyCheckingAcceptNew, the keys of hosts that are not in the file
+// are added to it.
+func HostKeyCallback(mode, knownHostsFile string) (ssh.HostKeyCallback, error) {
+	switch mode {
+	case cfg.HostKeyCheckingOff:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case cfg.HostKeyCheckingStrict, cfg.HostKeyCheckingAcceptNew:
+	default:
+		return nil, fmt.Errorf("invalid host key checking mode '%s'", mode)
+	}
+
+	if _, err := os.Stat(knownHostsFile); os.IsNotExist(err) {
+		if mode == cfg.HostKeyCheckingStrict {
+			return nil, fmt.Errorf("known hosts file %s does not exist", knownHostsFile)
+		}
+		if err := os.MkdirAll(filepath.Dir(knownHostsFile), 0700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(knownHostsFile, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		// The file is read for every connection, since keys may have been
+		// added or removed since the last one
+		verify, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			return err
+		}
+		err = verify(hostname, remote, key)
+		keyErr, isKeyErr := err.(*knownhosts.KeyError)
+		if !isKeyErr {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key for %s does not match the key in %s - "+
+				"if the remote was replaced, remove its old key from the file: %s",
+				hostname, knownHostsFile, err.Error())
+		}
+		if mode == cfg.HostKeyCheckingStrict {
+			return fmt.Errorf("host key for %s is not in %s", hostname, knownHostsFile)
+		}
+
+		// Trust new hosts on first use
+		f, err := os.OpenFile(knownHostsFile, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}, nil
+}
+
+// ForgetHostKey removes the keys of the host at the given address and port
+// from the given known hosts file, including hashed entries, so that the key
+// of a new host at that address is accepted
+func ForgetHostKey(knownHostsFile, host, port string) error {
+	contents, err := ioutil.ReadFile(knownHostsFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var address = knownhosts.Normalize(net.JoinHostPort(host, port))
+	var kept bytes.Buffer
+	var removed bool
+	var scanner = bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		var line = scanner.Text()
+		var fields = strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") ||
+			strings.HasPrefix(fields[0], "@") {
+			kept.WriteString(line + "\n")
+			continue
+		}
+
+		// Entries can list several hosts, of which only the address is removed
+		var hosts = []string{}
+		for _, h := range strings.Split(fields[0], ",") {
+			if matchesKnownHost(h, address) {
+				removed = true
+			} else {
+				hosts = append(hosts, h)
+			}
+		}
+		if len(hosts) > 0 {
+			kept.WriteString(strings.Replace(line, fields[0], strings.Join(hosts, ","), 1) + "\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !removed {
+		return nil
+	}
+	return ioutil.WriteFile(knownHostsFile, kept.Bytes(), 0600)
+}
+
+// matchesKnownHost checks if a host listed in a known hosts entry is the given
+// normalized address, either in plain text or hashed
+func matchesKnownHost(host, address string) bool {
+	if !strings.HasPrefix(host, "|1|") {
+		return host == address
+	}
+	var parts = strings.Split(host[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	var mac = hmac.New(sha1.New, salt)
+	mac.Write([]byte(address))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
@@ -0,0 +1,107 @@
+package local
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	assert.Nil(t, err)
+	return key
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	var addr = &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+	var key, otherKey = newHostKey(t), newHostKey(t)
+
+	type args struct {
+		mode  string
+		known bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantErr   bool
+		wantKnown bool
+	}{
+		{"off", args{cfg.HostKeyCheckingOff, false}, false, false},
+		{"invalid", args{"maybe", false}, true, false},
+		{"strict without known hosts", args{cfg.HostKeyCheckingStrict, false}, true, false},
+		{"strict with known host", args{cfg.HostKeyCheckingStrict, true}, false, true},
+		{"accept new host", args{cfg.HostKeyCheckingAcceptNew, false}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-known-hosts")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			var file = filepath.Join(dir, ".ssh", "known_hosts")
+			if tt.args.known {
+				assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0700))
+				assert.Nil(t, ioutil.WriteFile(file, []byte(knownhosts.Line(
+					[]string{knownhosts.Normalize(addr.String())}, key)+"\n"), 0600))
+			}
+
+			callback, err := HostKeyCallback(tt.args.mode, file)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Nil(t, callback(addr.String(), addr, key))
+
+			if tt.wantKnown {
+				// Keys that change are always rejected
+				assert.NotNil(t, callback(addr.String(), addr, otherKey))
+				contents, err := ioutil.ReadFile(file)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, strings.Count(string(contents), "\n"))
+			}
+		})
+	}
+}
+
+func TestForgetHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-known-hosts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var file = filepath.Join(dir, "known_hosts")
+
+	// The address is removed from plain, shared, and hashed entries
+	var key = newHostKey(t)
+	var lines = []string{
+		"# comment",
+		knownhosts.Line([]string{"1.2.3.4"}, key),
+		knownhosts.Line([]string{"[1.2.3.4]:2222", "example.com"}, key),
+		knownhosts.Line([]string{knownhosts.HashHostname("1.2.3.4")}, key),
+		knownhosts.Line([]string{"5.6.7.8"}, key),
+	}
+	assert.Nil(t, ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	assert.Nil(t, ForgetHostKey(file, "1.2.3.4", "22"))
+	assert.Nil(t, ForgetHostKey(file, "1.2.3.4", "2222"))
+
+	contents, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"# comment",
+		knownhosts.Line([]string{"example.com"}, key),
+		knownhosts.Line([]string{"5.6.7.8"}, key),
+	}, "\n")+"\n", string(contents))
+
+	// Missing files have no keys to forget
+	assert.Nil(t, ForgetHostKey(filepath.Join(dir, "missing"), "1.2.3.4", "22"))
+}
@@ -0,0 +1,103 @@
+package local
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ppkMACKey is the key used to derive the MAC key of unencrypted PuTTY
+// private key files
+const ppkMACKey = "putty-private-key-file-mac-key"
+
+// ConvertKeyToPPK converts the given unencrypted PEM-encoded RSA private key
+// to PuTTY's private key file format (version 2), for use with PuTTY and other
+// Windows SSH clients
+func ConvertKeyToPPK(pemKey []byte, comment string) ([]byte, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("no PEM data found in key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("only RSA keys can be converted: %s", err.Error())
+	}
+	if len(key.Primes) != 2 {
+		return nil, errors.New("only RSA keys with two primes can be converted")
+	}
+	key.Precompute()
+
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		algorithm  = publicKey.Type()
+		encryption = "none"
+		public     = publicKey.Marshal()
+		private    = ssh.Marshal(struct {
+			D    *big.Int
+			P    *big.Int
+			Q    *big.Int
+			IQMP *big.Int
+		}{key.D, key.Primes[0], key.Primes[1], key.Precomputed.Qinv})
+	)
+
+	// The MAC covers each field, prefixed by its length
+	var macData = new(bytes.Buffer)
+	for _, field := range [][]byte{
+		[]byte(algorithm), []byte(encryption), []byte(comment), public, private,
+	} {
+		binary.Write(macData, binary.BigEndian, uint32(len(field)))
+		macData.Write(field)
+	}
+	var macKey = sha1.Sum([]byte(ppkMACKey))
+	var mac = hmac.New(sha1.New, macKey[:])
+	mac.Write(macData.Bytes())
+
+	var ppk = new(bytes.Buffer)
+	fmt.Fprintf(ppk, "PuTTY-User-Key-File-2: %s\n", algorithm)
+	fmt.Fprintf(ppk, "Encryption: %s\n", encryption)
+	fmt.Fprintf(ppk, "Comment: %s\n", comment)
+	writePPKLines(ppk, "Public-Lines", public)
+	writePPKLines(ppk, "Private-Lines", private)
+	fmt.Fprintf(ppk, "Private-MAC: %s\n", hex.EncodeToString(mac.Sum(nil)))
+	return ppk.Bytes(), nil
+}
+
+// writePPKLines writes the given data to a PuTTY private key file as base64,
+// wrapped at 64 characters and preceded by a line count
+func writePPKLines(ppk *bytes.Buffer, name string, data []byte) {
+	var encoded = base64.StdEncoding.EncodeToString(data)
+	var lines = []string{}
+	for len(encoded) > 64 {
+		lines = append(lines, encoded[:64])
+		encoded = encoded[64:]
+	}
+	lines = append(lines, encoded)
+	fmt.Fprintf(ppk, "%s: %d\n", name, len(lines))
+	for _, line := range lines {
+		fmt.Fprintln(ppk, line)
+	}
+}
+
+// SaveKeyAsPPK converts the given PEM-encoded RSA private key to PuTTY's
+// format and writes it to path, readable only by the current user
+func SaveKeyAsPPK(keyMaterial, path, comment string) error {
+	ppk, err := ConvertKeyToPPK([]byte(keyMaterial), comment)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, ppk, 0400)
+}
@@ -0,0 +1,50 @@
+package local
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestConvertKeyToPPK(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	var pemKey = pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	ppk, err := ConvertKeyToPPK(pemKey, "my_inertia_key")
+	assert.Nil(t, err)
+	var lines = strings.Split(string(ppk), "\n")
+	assert.Equal(t, "PuTTY-User-Key-File-2: ssh-rsa", lines[0])
+	assert.Equal(t, "Encryption: none", lines[1])
+	assert.Equal(t, "Comment: my_inertia_key", lines[2])
+	assert.True(t, strings.HasPrefix(lines[len(lines)-2], "Private-MAC: "))
+
+	// Public lines should contain the key's public key
+	var public = new(bytes.Buffer)
+	for _, line := range lines[4:] {
+		if strings.HasPrefix(line, "Private-Lines") {
+			break
+		}
+		public.WriteString(line)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(public.String())
+	assert.Nil(t, err)
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, publicKey.Marshal(), decoded)
+
+	// Only PEM-encoded RSA keys are supported
+	_, err = ConvertKeyToPPK([]byte("not a key"), "")
+	assert.NotNil(t, err)
+}
@@ -84,7 +84,16 @@ func GetProjectConfigFromDisk(relPath string) (*cfg.Config, string, error) {
 	return &cfg, configFilePath, err
 }
 
-// SaveKey writes a key to given path
+// SaveKey writes a key to given path, creating its directory if needed. The
+// key is only readable and writable by the current user.
 func SaveKey(keyMaterial string, path string) error {
-	return ioutil.WriteFile(path, []byte(keyMaterial), 0400)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(keyMaterial), 0600); err != nil {
+		return err
+	}
+
+	// WriteFile only sets permissions on new files, and is subject to umask
+	return os.Chmod(path, 0600)
 }
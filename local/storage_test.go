@@ -92,19 +92,27 @@ K/i/OQKBgQD3rq8dDSVYaLcSFwg9RfRKF+Ahtml86lm4FrfZlLEfwb6TaR/Unsh0
 XF56ZdrKh0nbOW/125RSc8STCv5klDGnBCD56Qzbin9+W6j1TWyJFMdNeaxjWK+U
 lq07qdr3cY+O1F4otlDitNuhLE88dtGJM5lEyumokiH1yXwhbBtZ4w==
 -----END RSA PRIVATE KEY-----`
-	cwd, _ := os.Getwd()
-	testKeyPath := path.Join(cwd, "test_key_save")
+	dir, err := ioutil.TempDir("", "inertia-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	testKeyPath := path.Join(dir, "keys", "test_key_save")
 
-	// Write
-	err := SaveKey(keyMaterial, testKeyPath)
+	// Write, creating the missing key directory
+	err = SaveKey(keyMaterial, testKeyPath)
+	assert.Nil(t, err)
+	info, err := os.Stat(path.Join(dir, "keys"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	info, err = os.Stat(testKeyPath)
 	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
 
 	// Read
 	bytes, err := ioutil.ReadFile(testKeyPath)
 	assert.Nil(t, err)
 	assert.Equal(t, keyMaterial, string(bytes))
 
-	// Test config remove
-	err = os.Remove(testKeyPath)
+	// Overwrite
+	err = SaveKey(keyMaterial, testKeyPath)
 	assert.Nil(t, err)
 }
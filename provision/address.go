@@ -0,0 +1,62 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// assignElasticIP allocates an Elastic IP address, recording its allocation in
+// created, and associates it with the instance with the given ID. Returns the
+// allocated address.
+func (p *EC2Provisioner) assignElasticIP(instanceID string,
+	created *createdResources) (string, error) {
+	fmt.Fprintln(p.out, "Allocating elastic IP address...")
+	allocation, err := p.client.AllocateAddress(&ec2.AllocateAddressInput{
+		Domain: aws.String(ec2.DomainTypeVpc),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate elastic IP address: %s", err.Error())
+	}
+	created.elasticIP = aws.StringValue(allocation.AllocationId)
+
+	if err = p.associateElasticIP(created.elasticIP, instanceID); err != nil {
+		return "", err
+	}
+	return aws.StringValue(allocation.PublicIp), nil
+}
+
+// AssociateElasticIP associates the Elastic IP address with the given
+// allocation ID in region with the instance with the given ID, moving it from
+// any instance it is currently associated with
+func (p *EC2Provisioner) AssociateElasticIP(region, allocationID, instanceID string) error {
+	if err := p.WithRegion(region); err != nil {
+		return err
+	}
+	return p.associateElasticIP(allocationID, instanceID)
+}
+
+func (p *EC2Provisioner) associateElasticIP(allocationID, instanceID string) error {
+	fmt.Fprintf(p.out, "Associating elastic IP address %s with instance %s...\n",
+		allocationID, instanceID)
+	if _, err := p.client.AssociateAddress(&ec2.AssociateAddressInput{
+		AllocationId:       aws.String(allocationID),
+		InstanceId:         aws.String(instanceID),
+		AllowReassociation: aws.Bool(true),
+	}); err != nil {
+		return fmt.Errorf("failed to associate elastic IP address %s: %s",
+			allocationID, err.Error())
+	}
+	return nil
+}
+
+// releaseElasticIP releases the Elastic IP address with the given allocation
+// ID, which must not be associated with an instance
+func (p *EC2Provisioner) releaseElasticIP(allocationID string) error {
+	fmt.Fprintf(p.out, "Releasing elastic IP address %s...\n", allocationID)
+	_, err := p.client.ReleaseAddress(&ec2.ReleaseAddressInput{
+		AllocationId: aws.String(allocationID),
+	})
+	return err
+}
@@ -0,0 +1,57 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// invalidCredentialsCodes are the codes of errors returned for requests made
+// with credentials that are missing, malformed, or no longer valid
+var invalidCredentialsCodes = map[string]bool{
+	"NoCredentialProviders":       true,
+	"EnvAccessKeyNotFound":        true,
+	"EnvSecretNotFound":           true,
+	"SharedCredsLoad":             true,
+	"SharedCredsAccessKey":        true,
+	"InvalidClientTokenId":        true,
+	"SignatureDoesNotMatch":       true,
+	"IncompleteSignature":         true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"UnrecognizedClientException": true,
+}
+
+// Validate checks that the provisioner's credentials are accepted by AWS, so
+// that bad credentials are reported before any resources are created. The
+// check is made in the current region, or in us-east-1 if no region is set,
+// and requires no permissions.
+func (p *EC2Provisioner) Validate() error {
+	var region = p.region
+	if region == "" {
+		region = defaultRegion
+	}
+	var client = sts.New(p.session, &aws.Config{
+		Credentials: p.creds,
+		Region:      aws.String(region),
+	})
+	return credentialsError(p.retry("GetCallerIdentity", func() error {
+		_, err := client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		return err
+	}))
+}
+
+// credentialsError explains err, returned by a request to check credentials,
+// if it was caused by invalid credentials
+func credentialsError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if aerr, ok := err.(awserr.Error); ok && invalidCredentialsCodes[aerr.Code()] {
+		return fmt.Errorf("invalid AWS credentials - check your access key ID and secret access key: %s",
+			aerr.Message())
+	}
+	return fmt.Errorf("failed to validate AWS credentials: %s", err.Error())
+}
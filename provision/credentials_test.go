@@ -0,0 +1,41 @@
+package provision
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialsError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantErr    bool
+		wantPrefix string
+	}{
+		{"valid", nil, false, ""},
+		{"invalid key ID",
+			awserr.New("InvalidClientTokenId", "The security token included in the request is invalid.", nil),
+			true, "invalid AWS credentials"},
+		{"wrong secret",
+			awserr.New("SignatureDoesNotMatch", "The request signature we calculated does not match.", nil),
+			true, "invalid AWS credentials"},
+		{"no credentials",
+			awserr.New("NoCredentialProviders", "no valid providers in chain", nil),
+			true, "invalid AWS credentials"},
+		{"network error", errors.New("dial tcp: i/o timeout"),
+			true, "failed to validate AWS credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := credentialsError(tt.err)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				assert.True(t, strings.HasPrefix(err.Error(), tt.wantPrefix), err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/local"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// EnvDOToken is the environment variable NewDOProvisionerFromEnv reads the
+	// DigitalOcean API token from, which is also used by doctl
+	EnvDOToken = "DIGITALOCEAN_ACCESS_TOKEN"
+
+	// dropletActive is the status of droplets that have started
+	dropletActive = "active"
+
+	// dropletPollInterval is how often the status of new droplets is checked
+	dropletPollInterval = 3 * time.Second
+)
+
+// DOProvisioner creates DigitalOcean droplets
+type DOProvisioner struct {
+	out    io.Writer
+	user   string
+	client *godo.Client
+}
+
+// NewDOProvisioner creates a client to interact with DigitalOcean using the
+// given API token
+func NewDOProvisioner(user, token string, out ...io.Writer) (*DOProvisioner, error) {
+	prov := &DOProvisioner{}
+	return prov, prov.init(user, token, out)
+}
+
+// NewDOProvisionerFromEnv creates a client to interact with DigitalOcean using
+// the API token in the DIGITALOCEAN_ACCESS_TOKEN environment variable
+func NewDOProvisionerFromEnv(user string, out ...io.Writer) (*DOProvisioner, error) {
+	var token = os.Getenv(EnvDOToken)
+	if token == "" {
+		return nil, fmt.Errorf("no DigitalOcean API token found in %s", EnvDOToken)
+	}
+	return NewDOProvisioner(user, token, out...)
+}
+
+// GetUser returns the user droplets are accessed as
+func (p *DOProvisioner) GetUser() string { return p.user }
+
+// ListImageOptions lists the slugs of the distribution images available to
+// droplets in region, such as "ubuntu-18-04-x64"
+func (p *DOProvisioner) ListImageOptions(region string) ([]string, error) {
+	var (
+		images = []godo.Image{}
+		opts   = &godo.ListOptions{PerPage: 200}
+	)
+	for {
+		page, resp, err := p.client.Images.ListDistribution(context.Background(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images: %s", err.Error())
+		}
+		images = append(images, page...)
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			break
+		}
+		current, err := resp.Links.CurrentPage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images: %s", err.Error())
+		}
+		opts.Page = current + 1
+	}
+	return doImageOptions(images, region), nil
+}
+
+// doImageOptions returns the sorted slugs of the given images that are
+// available in region. Images without slugs are skipped.
+func doImageOptions(images []godo.Image, region string) []string {
+	var options = []string{}
+	for _, image := range images {
+		if image.Slug == "" {
+			continue
+		}
+		for _, r := range image.Regions {
+			if r == region {
+				options = append(options, image.Slug)
+				break
+			}
+		}
+	}
+	sort.Strings(options)
+	return options
+}
+
+// DOCreateInstanceOptions defines parameters with which to create a
+// DigitalOcean droplet
+type DOCreateInstanceOptions struct {
+	Name        string
+	ProjectName string
+	Ports       []int64
+	DaemonPort  int64
+
+	// PortRanges are exposed in addition to Ports
+	PortRanges []PortRange
+
+	// ImageID is the slug of the image the droplet boots from, such as
+	// "ubuntu-18-04-x64". Docker is installed when the droplet first boots if
+	// the image does not include it.
+	ImageID string
+	Size    string
+	Region  string
+
+	// KeyDirectory is the directory the generated key is saved in, created if
+	// it does not exist. Keys are saved in ~/.ssh if it is unset.
+	KeyDirectory string
+
+	// FallbackWebhookSecret is used as the remote's webhook secret if a random
+	// secret cannot be generated. Provisioning fails instead if it is unset.
+	FallbackWebhookSecret string
+
+	// SSH configures SSH connections to the instance, including the check
+	// that its SSH server is ready - defaults are used if it is not set
+	SSH *cfg.SSHOptions
+
+	// Timeout is how long the droplet is given to start and accept SSH
+	// connections, 10 minutes if unset. If it elapses, the droplet and the
+	// resources created for it are removed.
+	Timeout time.Duration
+}
+
+// CreateInstance creates a DigitalOcean droplet with given properties
+func (p *DOProvisioner) CreateInstance(opts DOCreateInstanceOptions) (*cfg.RemoteVPS, error) {
+	var created doCreatedResources
+	remote, err := p.createInstance(opts, &created)
+	if err != nil {
+		p.cleanUp(created)
+	}
+	return remote, err
+}
+
+func (p *DOProvisioner) createInstance(opts DOCreateInstanceOptions,
+	created *doCreatedResources) (*cfg.RemoteVPS, error) {
+	// Check requested options before creating any resources
+	if opts.Region == "" || opts.ImageID == "" || opts.Size == "" {
+		return nil, errors.New("a region, image, and size are required to create droplets")
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultCreateTimeout
+	} else if opts.Timeout < 0 {
+		return nil, errors.New("timeout cannot be negative")
+	}
+	webhookSecret, err := generateWebhookSecret(opts.FallbackWebhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	var ports = []PortRange{
+		{From: defaultSSHPort, To: defaultSSHPort, Protocol: "tcp"},
+		{From: opts.DaemonPort, To: opts.DaemonPort, Protocol: "tcp"},
+	}
+	for _, port := range opts.Ports {
+		ports = append(ports, PortRange{From: port, To: port, Protocol: "tcp"})
+	}
+	for _, r := range opts.PortRanges {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		ports = append(ports, r)
+	}
+	var ctx = context.Background()
+
+	// Generate authentication, and upload the public key so that droplets can
+	// be created with it
+	var keyName = generatedKeyName(opts.ProjectName, opts.Name, p.user)
+	fmt.Fprintf(p.out, "Generating key %s...\n", keyName)
+	privateKey, publicKey, err := generateKeyPair(keyName)
+	if err != nil {
+		return nil, err
+	}
+	var keyDirectory = opts.KeyDirectory
+	if keyDirectory == "" {
+		keyDirectory = local.GetKeyDirectory()
+	}
+	var keyPath = filepath.Join(keyDirectory, keyName)
+	fmt.Fprintf(p.out, "Saving key to %s...\n", keyPath)
+	if err = local.SaveKey(privateKey, keyPath); err != nil {
+		return nil, err
+	}
+	created.keyPath = keyPath
+	key, _, err := p.client.Keys.Create(ctx, &godo.KeyCreateRequest{
+		Name:      keyName,
+		PublicKey: publicKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload key: %s", err.Error())
+	}
+	created.keyID = key.ID
+
+	// Start up droplet. The timeout is shared by the waits for the droplet
+	// and SSH connections.
+	fmt.Fprintln(p.out, "Creating droplet...")
+	var deadline = time.Now().Add(opts.Timeout)
+	droplet, _, err := p.client.Droplets.Create(ctx, &godo.DropletCreateRequest{
+		Name:     resourceName(opts.ProjectName, opts.Name),
+		Region:   opts.Region,
+		Size:     opts.Size,
+		Image:    godo.DropletCreateImage{Slug: opts.ImageID},
+		SSHKeys:  []godo.DropletCreateSSHKey{{ID: key.ID}},
+		UserData: dockerInstallScript,
+		Tags:     []string{"inertia"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create droplet: %s", err.Error())
+	}
+	created.dropletID = droplet.ID
+
+	// Create firewall for network configuration
+	fmt.Fprintln(p.out, "Creating firewall...")
+	firewall, _, err := p.client.Firewalls.Create(ctx, &godo.FirewallRequest{
+		Name:          resourceName("inertia", opts.ProjectName, opts.Name, strconv.Itoa(droplet.ID)),
+		InboundRules:  inboundRules(ports),
+		OutboundRules: outboundRules(),
+		DropletIDs:    []int{droplet.ID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create firewall: %s", err.Error())
+	}
+	created.firewallID = firewall.ID
+
+	// Wait until droplet is running
+	fmt.Fprintln(p.out, "Checking status of requested droplet...")
+	if droplet, err = p.waitForDroplet(droplet.ID, time.Until(deadline)); err != nil {
+		return nil, err
+	}
+	address, err := droplet.PublicIPv4()
+	if err != nil || address == "" {
+		return nil, fmt.Errorf("unable to find public IP address for droplet %d", droplet.ID)
+	}
+
+	// Poll for SSH server to accept connections
+	fmt.Fprintln(p.out, "Waiting for ports to open...")
+	if err = waitForSSH(p.out, p.user, address, defaultSSHPort, opts.SSH, 3*time.Second,
+		time.Until(deadline)); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(p.out, "Webhook secret: '%s'\n", webhookSecret)
+
+	// Return remote configuration
+	return &cfg.RemoteVPS{
+		Name:    opts.Name,
+		IP:      address,
+		User:    p.user,
+		PEM:     keyPath,
+		SSHPort: strconv.Itoa(defaultSSHPort),
+		SSH:     opts.SSH,
+		Daemon: &cfg.DaemonConfig{
+			Port:          strconv.FormatInt(opts.DaemonPort, 10),
+			WebHookSecret: webhookSecret,
+		},
+		Resources: &cfg.ProvisionedResources{
+			Provider: "digitalocean",
+			Region:   opts.Region,
+
+			InstanceID:   strconv.Itoa(droplet.ID),
+			ImageID:      opts.ImageID,
+			InstanceType: opts.Size,
+
+			SecurityGroupID: firewall.ID,
+			KeyPairName:     keyName,
+		},
+	}, nil
+}
+
+// DestroyInstance deletes the droplet with the given ID and the firewall with
+// the given ID created for it. If keyPath is set, the droplet's generated key
+// is deleted from DigitalOcean and its private key at keyPath is removed.
+func (p *DOProvisioner) DestroyInstance(dropletID int, firewallID, keyPath string) error {
+	var ctx = context.Background()
+	fmt.Fprintf(p.out, "Deleting droplet %d...\n", dropletID)
+	if _, err := p.client.Droplets.Delete(ctx, dropletID); err != nil {
+		return err
+	}
+	if firewallID != "" {
+		fmt.Fprintf(p.out, "Deleting firewall %s...\n", firewallID)
+		if _, err := p.client.Firewalls.Delete(ctx, firewallID); err != nil {
+			return err
+		}
+	}
+	if keyPath == "" {
+		return nil
+	}
+
+	// Keys are identified by the fingerprint of their public key, which can be
+	// derived from the private key
+	privateKey, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("invalid key %s: %s", keyPath, err.Error())
+	}
+	var fingerprint = ssh.FingerprintLegacyMD5(signer.PublicKey())
+	fmt.Fprintf(p.out, "Deleting key %s...\n", fingerprint)
+	if _, err := p.client.Keys.DeleteByFingerprint(ctx, fingerprint); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Removing key %s...\n", keyPath)
+	return local.RemoveKey(keyPath)
+}
+
+// doCreatedResources are the resources created while provisioning a droplet,
+// which are removed if provisioning fails part way through
+type doCreatedResources struct {
+	dropletID  int
+	firewallID string
+	keyID      int
+	keyPath    string
+}
+
+// cleanUp removes the given resources, left behind by a failed attempt to
+// provision a droplet. Failures to remove resources are reported, but do not
+// stop the remaining resources from being removed.
+func (p *DOProvisioner) cleanUp(created doCreatedResources) {
+	if created.dropletID == 0 && created.firewallID == "" && created.keyID == 0 &&
+		created.keyPath == "" {
+		return
+	}
+	fmt.Fprintln(p.out, "Removing resources created for the droplet...")
+	var ctx = context.Background()
+	if created.dropletID != 0 {
+		fmt.Fprintf(p.out, "Deleting droplet %d...\n", created.dropletID)
+		if _, err := p.client.Droplets.Delete(ctx, created.dropletID); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete droplet %d: %s\n", created.dropletID, err.Error())
+		}
+	}
+	if created.firewallID != "" {
+		fmt.Fprintf(p.out, "Deleting firewall %s...\n", created.firewallID)
+		if _, err := p.client.Firewalls.Delete(ctx, created.firewallID); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete firewall %s: %s\n", created.firewallID, err.Error())
+		}
+	}
+	if created.keyID != 0 {
+		fmt.Fprintf(p.out, "Deleting key %d...\n", created.keyID)
+		if _, err := p.client.Keys.DeleteByID(ctx, created.keyID); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete key %d: %s\n", created.keyID, err.Error())
+		}
+	}
+	if created.keyPath != "" {
+		if err := local.RemoveKey(created.keyPath); err != nil {
+			fmt.Fprintf(p.out, "Failed to remove key %s: %s\n", created.keyPath, err.Error())
+		}
+	}
+}
+
+// waitForDroplet blocks until the droplet with the given ID is active, and
+// returns it. An error is returned if it is not active within timeout.
+func (p *DOProvisioner) waitForDroplet(dropletID int, timeout time.Duration) (*godo.Droplet, error) {
+	var deadline = time.Now().Add(timeout)
+	for {
+		droplet, _, err := p.client.Droplets.Get(context.Background(), dropletID)
+		if err != nil {
+			return nil, err
+		}
+		if droplet.Status == dropletActive {
+			return droplet, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for droplet %d to start",
+				timeout.Round(time.Second), dropletID)
+		}
+		time.Sleep(dropletPollInterval)
+	}
+}
+
+// inboundRules creates firewall rules that allow traffic to the given ports
+// from anywhere
+func inboundRules(ports []PortRange) []godo.InboundRule {
+	var rules = make([]godo.InboundRule, len(ports))
+	for i, r := range ports {
+		var spec = strconv.FormatInt(r.From, 10)
+		if r.isICMP() {
+			spec = ""
+		} else if r.To != r.From {
+			spec = fmt.Sprintf("%d-%d", r.From, r.To)
+		}
+		rules[i] = godo.InboundRule{
+			Protocol:  r.protocol(),
+			PortRange: spec,
+			Sources:   &godo.Sources{Addresses: []string{"0.0.0.0/0", "::/0"}},
+		}
+	}
+	return rules
+}
+
+// outboundRules creates firewall rules that allow all outgoing traffic, which
+// DigitalOcean firewalls block unless it is allowed explicitly
+func outboundRules() []godo.OutboundRule {
+	var destinations = &godo.Destinations{Addresses: []string{"0.0.0.0/0", "::/0"}}
+	return []godo.OutboundRule{
+		{Protocol: "tcp", PortRange: "all", Destinations: destinations},
+		{Protocol: "udp", PortRange: "all", Destinations: destinations},
+		{Protocol: "icmp", Destinations: destinations},
+	}
+}
+
+func (p *DOProvisioner) init(user, token string, out []io.Writer) error {
+	if len(out) > 0 {
+		p.out = out[0]
+	} else {
+		p.out = common.DevNull{}
+	}
+	p.user = user
+	if token == "" {
+		return errors.New("a DigitalOcean API token is required")
+	}
+
+	// Set up DigitalOcean client
+	p.client = godo.NewClient(oauth2.NewClient(context.Background(),
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+	return nil
+}
@@ -0,0 +1,59 @@
+package provision
+
+import (
+	"os"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDOProvisioner(t *testing.T) {
+	prov, err := NewDOProvisioner("root", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, "root", prov.GetUser())
+
+	_, err = NewDOProvisioner("root", "")
+	assert.NotNil(t, err)
+}
+
+func TestNewDOProvisionerFromEnv(t *testing.T) {
+	var original = os.Getenv(EnvDOToken)
+	defer os.Setenv(EnvDOToken, original)
+
+	os.Setenv(EnvDOToken, "")
+	_, err := NewDOProvisionerFromEnv("root")
+	assert.NotNil(t, err)
+
+	os.Setenv(EnvDOToken, "token")
+	prov, err := NewDOProvisionerFromEnv("root")
+	assert.Nil(t, err)
+	assert.Equal(t, "root", prov.GetUser())
+}
+
+func TestDOImageOptions(t *testing.T) {
+	var images = []godo.Image{
+		{Slug: "ubuntu-18-04-x64", Regions: []string{"nyc1", "sfo2"}},
+		{Slug: "debian-9-x64", Regions: []string{"sfo2"}},
+		{Slug: "centos-7-x64", Regions: []string{"nyc1"}},
+		{Name: "Unnamed", Regions: []string{"sfo2"}},
+	}
+	assert.Equal(t, []string{}, doImageOptions(nil, "sfo2"))
+	assert.Equal(t, []string{"debian-9-x64", "ubuntu-18-04-x64"}, doImageOptions(images, "sfo2"))
+	assert.Equal(t, []string{}, doImageOptions(images, "tor1"))
+}
+
+func TestInboundRules(t *testing.T) {
+	var anywhere = &godo.Sources{Addresses: []string{"0.0.0.0/0", "::/0"}}
+	assert.Equal(t, []godo.InboundRule{
+		{Protocol: "tcp", PortRange: "22", Sources: anywhere},
+		{Protocol: "tcp", PortRange: "4303", Sources: anywhere},
+		{Protocol: "udp", PortRange: "10000-20000", Sources: anywhere},
+		{Protocol: "icmp", Sources: anywhere},
+	}, inboundRules([]PortRange{
+		{From: 22, To: 22, Protocol: "tcp"},
+		{From: 4303, To: 4303},
+		{From: 10000, To: 20000, Protocol: "udp"},
+		{Protocol: "icmp"},
+	}))
+}
@@ -0,0 +1,76 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// Volume types that can be selected for root volumes
+	volumeTypeGP2 = "gp2"
+	volumeTypeGP3 = "gp3"
+
+	// maxDiskSizeGB is the largest size of gp2 and gp3 volumes
+	maxDiskSizeGB = 16384
+)
+
+// getImage returns the image with the given ID
+func (p *EC2Provisioner) getImage(imageID string) (*ec2.Image, error) {
+	var result *ec2.DescribeImagesOutput
+	if err := p.retry("DescribeImages", func() (err error) {
+		result, err = p.client.DescribeImages(&ec2.DescribeImagesInput{
+			ImageIds: []*string{aws.String(imageID)},
+		})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if len(result.Images) == 0 {
+		return nil, fmt.Errorf("image %s not found", imageID)
+	}
+	return result.Images[0], nil
+}
+
+// rootVolumeMapping returns a block device mapping that overrides the size, in
+// GB, and the type of the given image's root volume. The image's defaults are
+// kept for either if it is unset. The volume is deleted when the instance is
+// terminated.
+func rootVolumeMapping(image *ec2.Image, sizeGB int64, volumeType string) (*ec2.BlockDeviceMapping, error) {
+	switch volumeType {
+	case "", volumeTypeGP2, volumeTypeGP3:
+	default:
+		return nil, fmt.Errorf("invalid disk type '%s' - must be one of '%s' or '%s'",
+			volumeType, volumeTypeGP2, volumeTypeGP3)
+	}
+	if sizeGB < 0 || sizeGB > maxDiskSizeGB {
+		return nil, fmt.Errorf("invalid disk size %d GB - must be at most %d GB",
+			sizeGB, maxDiskSizeGB)
+	}
+
+	var root *ec2.BlockDeviceMapping
+	for _, mapping := range image.BlockDeviceMappings {
+		if aws.StringValue(mapping.DeviceName) == aws.StringValue(image.RootDeviceName) &&
+			mapping.Ebs != nil {
+			root = mapping
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("image %s does not have an EBS root volume, so its disk cannot be configured",
+			aws.StringValue(image.ImageId))
+	}
+	if minimum := aws.Int64Value(root.Ebs.VolumeSize); sizeGB > 0 && sizeGB < minimum {
+		return nil, fmt.Errorf("disk size %d GB is smaller than the %d GB required by image %s",
+			sizeGB, minimum, aws.StringValue(image.ImageId))
+	}
+
+	var ebs = &ec2.EbsBlockDevice{DeleteOnTermination: aws.Bool(true)}
+	if sizeGB > 0 {
+		ebs.VolumeSize = aws.Int64(sizeGB)
+	}
+	if volumeType != "" {
+		ebs.VolumeType = aws.String(volumeType)
+	}
+	return &ec2.BlockDeviceMapping{DeviceName: root.DeviceName, Ebs: ebs}, nil
+}
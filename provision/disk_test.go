@@ -0,0 +1,63 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootVolumeMapping(t *testing.T) {
+	var image = &ec2.Image{
+		ImageId:        aws.String("ami-123"),
+		RootDeviceName: aws.String("/dev/xvda"),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+			{DeviceName: aws.String("/dev/sdb"), VirtualName: aws.String("ephemeral0")},
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{
+				SnapshotId: aws.String("snap-123"),
+				VolumeSize: aws.Int64(8),
+				VolumeType: aws.String(volumeTypeGP2),
+			}},
+		},
+	}
+	type args struct {
+		image      *ec2.Image
+		sizeGB     int64
+		volumeType string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *ec2.EbsBlockDevice
+		wantErr bool
+	}{
+		{"size only", args{image, 30, ""},
+			&ec2.EbsBlockDevice{DeleteOnTermination: aws.Bool(true), VolumeSize: aws.Int64(30)}, false},
+		{"type only", args{image, 0, volumeTypeGP3},
+			&ec2.EbsBlockDevice{DeleteOnTermination: aws.Bool(true), VolumeType: aws.String(volumeTypeGP3)}, false},
+		{"size and type", args{image, 8, volumeTypeGP3},
+			&ec2.EbsBlockDevice{DeleteOnTermination: aws.Bool(true), VolumeSize: aws.Int64(8),
+				VolumeType: aws.String(volumeTypeGP3)}, false},
+		{"smaller than image", args{image, 4, ""}, nil, true},
+		{"negative size", args{image, -1, ""}, nil, true},
+		{"too large", args{image, maxDiskSizeGB + 1, ""}, nil, true},
+		{"invalid type", args{image, 30, "io1"}, nil, true},
+		{"no ebs root", args{&ec2.Image{
+			ImageId:        aws.String("ami-456"),
+			RootDeviceName: aws.String("/dev/sda1"),
+		}, 30, ""}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rootVolumeMapping(tt.args.image, tt.args.sizeGB, tt.args.volumeType)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, aws.String("/dev/xvda"), got.DeviceName)
+			assert.Equal(t, tt.want, got.Ebs)
+		})
+	}
+}
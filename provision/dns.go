@@ -0,0 +1,58 @@
+package provision
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// dnsRecordTTL is the TTL, in seconds, of DNS records pointed at instances,
+// kept short so that clients follow the record when the instance is replaced
+const dnsRecordTTL = 60
+
+// dnsRecordChange returns the change that points the DNS record with the given
+// name at address - an A record if address is an IP address, or a CNAME record
+// if it is a host name
+func dnsRecordChange(name, address string) *route53.Change {
+	var recordType = route53.RRTypeCname
+	if ip := net.ParseIP(address); ip != nil && ip.To4() != nil {
+		recordType = route53.RRTypeA
+	} else if ip != nil {
+		recordType = route53.RRTypeAaaa
+	}
+	return &route53.Change{
+		Action: aws.String(route53.ChangeActionUpsert),
+		ResourceRecordSet: &route53.ResourceRecordSet{
+			Name:            aws.String(strings.TrimSuffix(name, ".") + "."),
+			Type:            aws.String(recordType),
+			TTL:             aws.Int64(dnsRecordTTL),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(address)}},
+		},
+	}
+}
+
+// PointDNSRecord creates or updates the DNS record with the given name in the
+// Route 53 hosted zone with the given ID so that it points at address, for
+// example to move a domain to a replacement instance
+func (p *EC2Provisioner) PointDNSRecord(zoneID, name, address string) error {
+	var client = route53.New(p.session, &aws.Config{
+		Credentials: p.creds,
+	})
+	fmt.Fprintf(p.out, "Pointing DNS record %s at %s...\n", name, address)
+	if err := p.retry("ChangeResourceRecordSets", func() error {
+		_, err := client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneID),
+			ChangeBatch: &route53.ChangeBatch{
+				Comment: aws.String("Updated by Inertia"),
+				Changes: []*route53.Change{dnsRecordChange(name, address)},
+			},
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to update DNS record %s: %s", name, err.Error())
+	}
+	return nil
+}
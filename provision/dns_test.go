@@ -0,0 +1,31 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSRecordChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		wantType string
+	}{
+		{"ipv4", "203.0.113.10", route53.RRTypeA},
+		{"ipv6", "2001:db8::1", route53.RRTypeAaaa},
+		{"host name", "ec2-203-0-113-10.compute-1.amazonaws.com", route53.RRTypeCname},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := dnsRecordChange("app.example.com", tt.address)
+			assert.Equal(t, route53.ChangeActionUpsert, aws.StringValue(change.Action))
+			assert.Equal(t, tt.wantType, aws.StringValue(change.ResourceRecordSet.Type))
+			assert.Equal(t, "app.example.com.", aws.StringValue(change.ResourceRecordSet.Name))
+			assert.Equal(t, tt.address,
+				aws.StringValue(change.ResourceRecordSet.ResourceRecords[0].Value))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -9,28 +10,88 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/ubclaunchpad/inertia/cfg"
 	"github.com/ubclaunchpad/inertia/common"
 	"github.com/ubclaunchpad/inertia/local"
+	"golang.org/x/crypto/ssh"
 )
 
 const (
 	// Code returned by AWS when EC2 instance is successfully created
 	codeEC2InstanceStarted = 16
+
+	// defaultSSHPort is the port instances are expected to accept SSH
+	// connections on if no other port is configured
+	defaultSSHPort = 22
+
+	// defaultRegion is the region requests that can be made in any region are
+	// made in if no other region is set
+	defaultRegion = "us-east-1"
+
+	// describeImagesPageSize is how many images are requested in each page
+	// of DescribeImages results, which is the most AWS allows
+	describeImagesPageSize = 1000
+
+	// defaultCreateTimeout is how long instances are given to start and
+	// accept SSH connections if no other timeout is configured
+	defaultCreateTimeout = 10 * time.Minute
+
+	// Value of the "Purpose" tag applied to Inertia-managed resources
+	inertiaPurposeTag = "Inertia Continuous Deployment"
+
+	// Error codes returned by AWS for dry run requests that would have
+	// succeeded, and for requests that are not permitted
+	codeDryRunOperation       = "DryRunOperation"
+	codeUnauthorizedOperation = "UnauthorizedOperation"
+
+	// Error code returned by AWS for key pairs that do not exist
+	codeKeyPairNotFound = "InvalidKeyPair.NotFound"
+
+	// Error code returned by AWS for security groups that already exist
+	codeSecurityGroupDuplicate = "InvalidGroup.Duplicate"
+
+	// Error code returned by AWS for snapshots that back an image
+	codeSnapshotInUse = "InvalidSnapshot.InUse"
 )
 
+// fipsRegions are the commercial regions with FIPS 140-2 validated EC2
+// endpoints - all GovCloud endpoints are FIPS validated
+var fipsRegions = []string{"us-east-1", "us-east-2", "us-west-1", "us-west-2", "ca-central-1"}
+
 // EC2Provisioner creates Amazon EC2 instances
 type EC2Provisioner struct {
 	out     io.Writer
 	user    string
 	session *session.Session
-	client  *ec2.EC2
+	creds   *credentials.Credentials
+	client  ec2iface.EC2API
+
+	// region is the region requests are made in, if one has been set
+	region   string
+	endpoint string
+	fips     bool
+
+	// maxAttempts is how many times requests that fail with transient errors
+	// are attempted - DefaultMaxAttempts if unset
+	maxAttempts int
+
+	// tracer exports traces of provisioning - it is nil if tracing is disabled
+	tracer *common.Tracer
+
+	// pollers wait for instances to start, keyed by region, so that instances
+	// created concurrently in the same region are polled together
+	pollers    map[string]*instancePoller
+	pollersMux sync.Mutex
 }
 
 // NewEC2Provisioner creates a client to interact with Amazon EC2 using the
@@ -57,71 +118,153 @@ func NewEC2ProvisionerFromProfile(user, profile, path string, out ...io.Writer)
 // GetUser returns the user attached to given credentials
 func (p *EC2Provisioner) GetUser() string { return p.user }
 
-// ListImageOptions lists available Amazon images for your given region
-func (p *EC2Provisioner) ListImageOptions(region string) ([]string, error) {
+// ListRegions lists the names of the regions available to the provisioner's
+// credentials, sorted alphabetically. The request is made in the current
+// region, or in us-east-1 if no region is set.
+func (p *EC2Provisioner) ListRegions() ([]string, error) {
+	if p.region == "" {
+		if err := p.WithRegion(defaultRegion); err != nil {
+			return nil, err
+		}
+	}
+	result, err := p.client.DescribeRegions(&ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, err
+	}
+	return regionNames(result.Regions), nil
+}
+
+// regionNames returns the names of the given regions, sorted alphabetically
+func regionNames(regions []*ec2.Region) []string {
+	var names = make([]string, 0, len(regions))
+	for _, region := range regions {
+		if region.RegionName != nil {
+			names = append(names, *region.RegionName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ImageFilter selects the images listed by ListImageOptions
+type ImageFilter struct {
+	// Owners are the IDs or aliases of the accounts that own the images, such
+	// as "amazon" or Canonical's "099720109477". They are required unless the
+	// filter is empty.
+	Owners []string
+
+	// NamePattern matches the names of the images, such as "ubuntu/images/*",
+	// with "*" matching any characters. Images with any name are listed if it
+	// is unset.
+	NamePattern string
+}
+
+var (
+	// AmazonLinuxImages selects Amazon Linux images, and is used by
+	// ListImageOptions if no filter is given
+	AmazonLinuxImages = ImageFilter{Owners: []string{"amazon"}, NamePattern: "amzn*"}
+
+	// UbuntuImages selects Ubuntu images published by Canonical
+	UbuntuImages = ImageFilter{Owners: []string{"099720109477"}, NamePattern: "ubuntu/images/*"}
+)
+
+// DefaultImageLimit is the number of most recent images usually offered when
+// choosing an image
+const DefaultImageLimit = 10
+
+// ListImageOptions lists available images matching filter for your given
+// region, most recent first. Amazon Linux images are listed if filter is empty.
+// At most limit images are listed, or all of them if limit is 0.
+func (p *EC2Provisioner) ListImageOptions(region string, filter ImageFilter,
+	limit int) ([]string, error) {
+	if len(filter.Owners) == 0 {
+		if filter.NamePattern != "" {
+			return nil, errors.New("image owners are required to filter images by name")
+		}
+		filter = AmazonLinuxImages
+	}
+
 	// Set requested region
-	p.WithRegion(region)
+	if err := p.WithRegion(region); err != nil {
+		return nil, err
+	}
 
 	// Query for easily supported images
-	output, err := p.client.DescribeImages(&ec2.DescribeImagesInput{
-		Owners: []*string{aws.String("amazon")},
-		Filters: []*ec2.Filter{
-			{
-				// Only display Amazon for ease of use
-				Name:   aws.String("name"),
-				Values: []*string{aws.String("amzn*")},
-			},
-			{
-				// Docker needs machine to run properly
-				Name:   aws.String("image-type"),
-				Values: []*string{aws.String("machine")},
-			},
-			{
-				// No funny business
-				Name:   aws.String("architecture"),
-				Values: []*string{aws.String("x86_64")},
-			},
-			{
-				// Most standard instances only support EBS
-				Name:   aws.String("root-device-type"),
-				Values: []*string{aws.String("ebs")},
-			},
-			{
-				// Paravirtual images don't work - see #500
-				Name:   aws.String("virtualization-type"),
-				Values: []*string{aws.String("hvm")},
-			},
+	var filters = []*ec2.Filter{
+		{
+			// Docker needs machine to run properly
+			Name:   aws.String("image-type"),
+			Values: []*string{aws.String("machine")},
 		},
+		{
+			// No funny business
+			Name:   aws.String("architecture"),
+			Values: []*string{aws.String("x86_64")},
+		},
+		{
+			// Most standard instances only support EBS
+			Name:   aws.String("root-device-type"),
+			Values: []*string{aws.String("ebs")},
+		},
+		{
+			// Paravirtual images don't work - see #500
+			Name:   aws.String("virtualization-type"),
+			Values: []*string{aws.String("hvm")},
+		},
+	}
+	if filter.NamePattern != "" {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String("name"),
+			Values: []*string{aws.String(filter.NamePattern)},
+		})
+	}
+	images, err := p.describeImages(&ec2.DescribeImagesInput{
+		Owners:  aws.StringSlice(filter.Owners),
+		Filters: filters,
 	})
 	if err != nil {
 		return nil, err
 	}
+	return imageOptions(images, limit), nil
+}
+
+// describeImages lists the images matching input, retrieving every page of
+// results
+func (p *EC2Provisioner) describeImages(input *ec2.DescribeImagesInput) ([]*ec2.Image, error) {
+	input.MaxResults = aws.Int64(describeImagesPageSize)
+	var images []*ec2.Image
+	err := p.retry("DescribeImages", func() error {
+		images = nil
+		return p.client.DescribeImagesPages(input,
+			func(page *ec2.DescribeImagesOutput, lastPage bool) bool {
+				images = append(images, page.Images...)
+				return true
+			})
+	})
+	return images, err
+}
 
+// imageOptions formats up to limit of the given images for printing, or all of
+// them if limit is 0, most recent first
+func imageOptions(images []*ec2.Image, limit int) []string {
 	// Sort by date
-	sort.Slice(output.Images, func(i, j int) bool {
-		iCreated := common.ParseDate(*output.Images[i].CreationDate)
-		if iCreated == nil {
-			return false
-		}
-		jCreated := common.ParseDate(*output.Images[j].CreationDate)
-		if jCreated == nil {
-			return true
-		}
-		return iCreated.After(*jCreated)
+	sort.Slice(images, func(i, j int) bool {
+		return newerThan(images[i].CreationDate, images[j].CreationDate)
 	})
 
 	// Format image names for printing
-	images := []string{}
-	for _, image := range output.Images {
-		if len(images) == 10 {
+	options := []string{}
+	for _, image := range images {
+		if limit > 0 && len(options) == limit {
 			break
 		}
 		// Ignore nameless images
 		if image.Name != nil {
-			images = append(images, fmt.Sprintf("%s (%s)", *image.ImageId, *image.Description))
+			options = append(options, fmt.Sprintf("%s (%s)",
+				aws.StringValue(image.ImageId), aws.StringValue(image.Description)))
 		}
 	}
-	return images, nil
+	return options
 }
 
 // EC2CreateInstanceOptions defines parameters with which to create an EC2 instance
@@ -131,217 +274,1323 @@ type EC2CreateInstanceOptions struct {
 	Ports       []int64
 	DaemonPort  int64
 
+	// SSHPort is the port the instance accepts SSH connections on, 22 if
+	// unset. The image or user data must configure the SSH server to listen
+	// on it.
+	SSHPort int64
+
+	// SSH configures SSH connections to the instance, including the check
+	// that its SSH server is ready - defaults are used if it is not set
+	SSH *cfg.SSHOptions
+
+	// PortRanges are exposed in addition to Ports, with a single security
+	// group rule for each range
+	PortRanges []PortRange
+
 	ImageID      string
 	InstanceType string
 	Region       string
+
+	// DiskSizeGB is the size of the instance's root volume in GB, which must
+	// be at least the size required by the image. DiskType is the type of the
+	// root volume - one of "gp2" or "gp3". The image's defaults, often an 8 GB
+	// volume, are used for either if it is unset.
+	DiskSizeGB int64
+	DiskType   string
+
+	// SubnetID is an existing subnet to launch the instance into, and VPCID
+	// is the VPC it belongs to, which the security group created for the
+	// instance is created in. The VPC is inferred from the subnet if unset.
+	// The default subnet of the default VPC is used if neither is set.
+	SubnetID string
+	VPCID    string
+
+	// AssociatePublicIP associates a public IP address with the instance even
+	// if its subnet does not assign them by default. Instances need a public
+	// address unless an Elastic IP address is assigned.
+	AssociatePublicIP bool
+
+	// Tenancy is the tenancy of the instance - one of "default", "dedicated",
+	// or "host". Shared tenancy is used if empty.
+	Tenancy string
+
+	// SecurityGroupDescription describes the security group created for the
+	// instance. PortDescriptions describes the rule for each project port,
+	// keyed by the first port of each range. Descriptions including the
+	// project name and ports are used if unset.
+	SecurityGroupDescription string
+	PortDescriptions         map[int64]string
+
+	// AdditionalPublicKeys are authorized for SSH access to the instance in
+	// addition to the generated key pair, in authorized_keys format
+	AdditionalPublicKeys []string
+
+	// SavePPK also saves the generated key pair's private key in PuTTY's
+	// format, alongside the PEM-encoded key
+	SavePPK bool
+
+	// UserDataTemplate is a template of user data, such as a shell script or
+	// cloud-config, to run when the instance first boots. It is rendered with
+	// UserDataVariables.
+	UserDataTemplate string
+
+	// KeyPairName and KeyPath are an existing key pair in the region and the
+	// path of its saved private key, used instead of generating and saving a
+	// new key pair. Both must be set to reuse a key pair.
+	KeyPairName string
+	KeyPath     string
+
+	// KeyDirectory is the directory generated keys are saved in, created if
+	// it does not exist. Keys are saved in ~/.ssh if it is unset.
+	KeyDirectory string
+
+	// SecurityGroupID is an existing security group used instead of creating
+	// one. Ports are not exposed on it, since it should already have the
+	// required rules.
+	SecurityGroupID string
+
+	// AllowedCIDRs restricts access to the SSH and daemon ports to the given
+	// IPv4 and IPv6 CIDR ranges, such as "203.0.113.0/24". They are open to
+	// all addresses if unset. Project ports are always open to all addresses.
+	AllowedCIDRs []string
+
+	// FallbackWebhookSecret is used as the remote's webhook secret if a random
+	// secret cannot be generated. Provisioning fails instead if it is unset.
+	FallbackWebhookSecret string
+
+	// Spot requests spot capacity for the instance instead of launching it
+	// on demand. SpotPrice is the maximum hourly price to pay, in USD, such as
+	// "0.05" - the on-demand price is used if it is unset.
+	Spot      bool
+	SpotPrice string
+
+	// AutoRecover creates a CloudWatch alarm that recovers the instance onto
+	// new hardware if the AWS systems it runs on fail. Recovered instances
+	// keep their ID, IP addresses, and EBS volumes. It cannot be used with
+	// spot instances.
+	AutoRecover bool
+
+	// IAMInstanceProfile is the name or ARN of an existing IAM instance
+	// profile to attach to the instance, granting it the permissions of the
+	// profile's role, such as access to private S3 buckets. No profile is
+	// attached if it is unset.
+	IAMInstanceProfile string
+
+	// Tags are applied, along with the Name, Purpose, and Project tags set by
+	// Inertia, to the instance, its volumes, its security group, and its
+	// Elastic IP address if they are created for it, and to generated key
+	// pairs. The Name tag defaults to the name of the instance, while the
+	// Purpose and Project tags cannot be overridden.
+	Tags map[string]string
+
+	// AssignElasticIP allocates an Elastic IP address for the instance, so
+	// that its address does not change when it is stopped and started. The
+	// address is released when the instance is destroyed.
+	AssignElasticIP bool
+
+	// Timeout is how long the instance is given to start and accept SSH
+	// connections, 10 minutes if unset. If it elapses, the instance and the
+	// resources created for it are removed.
+	Timeout time.Duration
+}
+
+// VerifyPermissions checks that the provisioner's credentials are permitted
+// to perform the operations required to create an instance with the given
+// options. Dry runs and policy simulations are used, so no resources are
+// created.
+func (p *EC2Provisioner) VerifyPermissions(opts EC2CreateInstanceOptions) error {
+	if err := p.WithRegion(opts.Region); err != nil {
+		return err
+	}
+
+	var missing = []string{}
+	var verify = func(action string, err error) error {
+		permitted, err := checkDryRun(err)
+		if err != nil {
+			return fmt.Errorf("failed to verify permission for %s: %s", action, err.Error())
+		}
+		if !permitted {
+			missing = append(missing, "ec2:"+action)
+		}
+		return nil
+	}
+
+	var err error
+	if opts.KeyPairName == "" {
+		_, err = p.client.CreateKeyPair(&ec2.CreateKeyPairInput{
+			DryRun:  aws.Bool(true),
+			KeyName: aws.String(opts.Name + "_inertia_verify"),
+		})
+		if err = verify("CreateKeyPair", err); err != nil {
+			return err
+		}
+	}
+
+	_, err = p.client.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		DryRun:      aws.Bool(true),
+		GroupName:   aws.String(opts.ProjectName + "-" + opts.Name),
+		Description: aws.String("Permission check"),
+	})
+	if err = verify("CreateSecurityGroup", err); err != nil {
+		return err
+	}
+
+	// The security group used does not exist yet, so only check for
+	// authorization errors
+	_, err = p.client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+		DryRun:     aws.Bool(true),
+		GroupName:  aws.String("default"),
+		IpProtocol: aws.String("tcp"),
+		CidrIp:     aws.String("0.0.0.0/0"),
+		FromPort:   aws.Int64(opts.DaemonPort),
+		ToPort:     aws.Int64(opts.DaemonPort),
+	})
+	if permitted, err := checkDryRun(err); !permitted && err == nil {
+		missing = append(missing, "ec2:AuthorizeSecurityGroupIngress")
+	}
+
+	_, err = p.client.DescribeInstanceTypeOfferings(&ec2.DescribeInstanceTypeOfferingsInput{
+		DryRun: aws.Bool(true),
+	})
+	if err = verify("DescribeInstanceTypeOfferings", err); err != nil {
+		return err
+	}
+
+	if opts.Spot {
+		_, err = p.client.RequestSpotInstances(&ec2.RequestSpotInstancesInput{
+			DryRun:        aws.Bool(true),
+			InstanceCount: aws.Int64(1),
+			LaunchSpecification: &ec2.RequestSpotLaunchSpecification{
+				ImageId:      aws.String(opts.ImageID),
+				InstanceType: aws.String(opts.InstanceType),
+			},
+		})
+		if err = verify("RequestSpotInstances", err); err != nil {
+			return err
+		}
+	} else {
+		_, err = p.client.RunInstances(&ec2.RunInstancesInput{
+			DryRun:       aws.Bool(true),
+			ImageId:      aws.String(opts.ImageID),
+			InstanceType: aws.String(opts.InstanceType),
+			MinCount:     aws.Int64(1),
+			MaxCount:     aws.Int64(1),
+		})
+		if err = verify("RunInstances", err); err != nil {
+			return err
+		}
+	}
+
+	if opts.AssignElasticIP {
+		_, err = p.client.AllocateAddress(&ec2.AllocateAddressInput{
+			DryRun: aws.Bool(true),
+			Domain: aws.String(ec2.DomainTypeVpc),
+		})
+		if err = verify("AllocateAddress", err); err != nil {
+			return err
+		}
+	}
+
+	// Recovery alarms are created with CloudWatch, which has no dry runs -
+	// checking its permissions requires permission to simulate policies, so
+	// failures to check are only reported
+	if opts.AutoRecover {
+		actions, err := p.missingRecoveryPermissions(opts.Region)
+		if err != nil {
+			fmt.Fprintf(p.out, "Unable to verify permissions for recovery alarms: %s\n", err.Error())
+		}
+		missing = append(missing, actions...)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("credentials for user '%s' are missing required permissions: %s",
+			p.user, strings.Join(missing, ", "))
+	}
+	return nil
 }
 
 // CreateInstance creates an EC2 instance with given properties
 func (p *EC2Provisioner) CreateInstance(opts EC2CreateInstanceOptions) (*cfg.RemoteVPS, error) {
-	// Set requested region
-	p.WithRegion(opts.Region)
+	var trace = p.tracer.Start("provision")
+	trace.SetAttribute("inertia.project", opts.ProjectName)
+	trace.SetAttribute("inertia.remote", opts.Name)
+	trace.SetAttribute("ec2.region", opts.Region)
+	trace.SetAttribute("ec2.instance_type", opts.InstanceType)
+	var created = createdResources{region: opts.Region}
+	remote, err := p.createInstance(opts, trace, &created)
+	if err != nil {
+		p.cleanUp(created)
+	}
+	trace.End(err)
+	return remote, err
+}
 
-	// Generate authentication
-	var keyName = fmt.Sprintf("%s_%s_inertia_key_%d", opts.Name, p.user, time.Now().UnixNano())
-	fmt.Printf("Generating key pair %s...\n", keyName)
-	keyResp, err := p.client.CreateKeyPair(&ec2.CreateKeyPairInput{
-		KeyName: aws.String(keyName),
-	})
+// createInstance creates an EC2 instance, recording each phase in the given
+// trace and each resource it creates in created. Phases that fail are ended by
+// the trace.
+func (p *EC2Provisioner) createInstance(opts EC2CreateInstanceOptions,
+	trace *common.Span, created *createdResources) (*cfg.RemoteVPS, error) {
+	// Check requested options before creating any resources
+	if err := validateTenancy(opts.Tenancy, opts.InstanceType); err != nil {
+		return nil, err
+	}
+	if opts.SSHPort == 0 {
+		opts.SSHPort = defaultSSHPort
+	}
+	if err := validateSSHPort(opts.SSHPort); err != nil {
+		return nil, err
+	}
+	if opts.Spot {
+		if opts.Tenancy == "host" {
+			return nil, errors.New("spot instances cannot be run on dedicated hosts")
+		}
+		if err := validateSpotPrice(opts.SpotPrice); err != nil {
+			return nil, err
+		}
+	} else if opts.SpotPrice != "" {
+		return nil, errors.New("a spot price can only be set for spot instances")
+	}
+	if opts.AutoRecover && opts.Spot {
+		return nil, errors.New("spot instances cannot be recovered automatically")
+	}
+	if opts.VPCID != "" && opts.SubnetID == "" {
+		return nil, errors.New("a subnet is required to launch instances into a VPC")
+	}
+	if (opts.KeyPairName == "") != (opts.KeyPath == "") {
+		return nil, errors.New("both a key pair name and the path of its private key are " +
+			"required to reuse a key pair")
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultCreateTimeout
+	} else if opts.Timeout < 0 {
+		return nil, errors.New("timeout cannot be negative")
+	}
+	tags, err := resourceTags(opts.Name, opts.ProjectName, opts.Tags)
 	if err != nil {
 		return nil, err
 	}
-
-	// Save key
-	keyPath := filepath.Join(os.Getenv("HOME"), ".ssh", *keyResp.KeyName)
-	fmt.Printf("Saving key to %s...\n", keyPath)
-	if err = local.SaveKey(*keyResp.KeyMaterial, keyPath); err != nil {
+	inertiaSources, err := parseIngressSources(opts.AllowedCIDRs)
+	if err != nil {
 		return nil, err
 	}
-
-	// Create security group for network configuration
-	group, err := p.client.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
-		GroupName: aws.String(
-			fmt.Sprintf("%s-%s-%d", opts.ProjectName, opts.Name, time.Now().UnixNano()),
-		),
-		Description: aws.String(
-			fmt.Sprintf("Rules for project %s on %s", opts.ProjectName, opts.Name),
-		),
-	})
+	webhookSecret, err := generateWebhookSecret(opts.FallbackWebhookSecret)
 	if err != nil {
 		return nil, err
 	}
+	var ports = make([]PortRange, 0, len(opts.Ports)+len(opts.PortRanges))
+	for _, port := range opts.Ports {
+		ports = append(ports, PortRange{From: port, To: port, Protocol: "tcp"})
+	}
+	for _, r := range opts.PortRanges {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		ports = append(ports, r)
+	}
+	keysScript, err := authorizedKeysScript(p.user, opts.AdditionalPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	var customUserData string
+	if opts.UserDataTemplate != "" {
+		if customUserData, err = RenderUserData(opts.UserDataTemplate, UserDataVariables{
+			ProjectName: opts.ProjectName,
+			Name:        opts.Name,
+			Region:      opts.Region,
+			User:        p.user,
+			Ports:       ports,
+			DaemonPort:  opts.DaemonPort,
+			WebhookPath: "/webhook",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	var userData *string
+	if combined := combineUserData(keysScript, customUserData); combined != "" {
+		userData = aws.String(base64.StdEncoding.EncodeToString([]byte(combined)))
+	}
 
-	// Set rules for ports
-	if err = p.exposePorts(*group.GroupId, opts.DaemonPort, opts.Ports); err != nil {
+	// Set requested region
+	if err = p.WithRegion(opts.Region); err != nil {
 		return nil, err
 	}
+	if opts.InstanceType != "" {
+		if err = p.checkInstanceType(opts.Region, opts.InstanceType); err != nil {
+			return nil, err
+		}
+	}
 
-	// Start up instance
-	runResp, err := p.client.RunInstances(&ec2.RunInstancesInput{
-		ImageId:      aws.String(opts.ImageID),
-		InstanceType: aws.String(opts.InstanceType),
-		MinCount:     aws.Int64(1),
-		MaxCount:     aws.Int64(1),
+	// Configure the root volume if requested, which requires the image's
+	// root device
+	var blockDevices []*ec2.BlockDeviceMapping
+	if opts.DiskSizeGB != 0 || opts.DiskType != "" {
+		image, err := p.getImage(opts.ImageID)
+		if err != nil {
+			return nil, err
+		}
+		root, err := rootVolumeMapping(image, opts.DiskSizeGB, opts.DiskType)
+		if err != nil {
+			return nil, err
+		}
+		blockDevices = []*ec2.BlockDeviceMapping{root}
+	}
 
-		// Security options
-		KeyName:          keyResp.KeyName,
-		SecurityGroupIds: []*string{group.GroupId},
-	})
-	if err != nil {
-		return nil, err
+	// Check that the requested subnet belongs to the requested VPC
+	var vpcID *string
+	if opts.SubnetID != "" {
+		subnet, err := p.getSubnet(opts.SubnetID)
+		if err != nil {
+			return nil, err
+		}
+		id, err := subnetVPC(subnet, opts.VPCID)
+		if err != nil {
+			return nil, err
+		}
+		vpcID = aws.String(id)
 	}
 
-	// Check response validity
-	if runResp.Instances == nil || len(runResp.Instances) == 0 {
-		return nil, errors.New("Unable to start instances: " + runResp.String())
+	// Look up the requested instance profile, so that launches do not fail
+	// after other resources have been created
+	var instanceProfile *ec2.IamInstanceProfileSpecification
+	if opts.IAMInstanceProfile != "" {
+		if instanceProfile, err = p.resolveInstanceProfile(opts.Region, opts.IAMInstanceProfile); err != nil {
+			return nil, err
+		}
 	}
 
-	// Loop until intance is running
-	fmt.Fprintln(p.out, "Checking status of requested instance...")
-	var instance ec2.Instance
-	for {
-		// Wait briefly between checks
-		time.Sleep(3 * time.Second)
+	// Generate authentication, unless an existing key pair is reused. The key
+	// pair is named after the project and tagged like the instance, so that
+	// keys left behind by removed instances can be identified.
+	var keyPhase = trace.Child("key pair")
+	var keyName, keyPath = opts.KeyPairName, opts.KeyPath
+	if keyName != "" {
+		if _, err = os.Stat(keyPath); err != nil {
+			return nil, fmt.Errorf("failed to find key for key pair %s: %s", keyName, err.Error())
+		}
+		if err = p.checkKeyPair(keyName); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(p.out, "Using existing key pair %s...\n", keyName)
+	} else {
+		keyName = generatedKeyName(opts.ProjectName, opts.Name, p.user)
+		fmt.Fprintf(p.out, "Generating key pair %s...\n", keyName)
+		var keyResp *ec2.CreateKeyPairOutput
+		if err = p.retry("CreateKeyPair", func() (err error) {
+			keyResp, err = p.client.CreateKeyPair(&ec2.CreateKeyPairInput{
+				KeyName: aws.String(keyName),
+			})
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		created.keyName = keyName
+		p.tagResources(tags, aws.StringValue(keyResp.KeyPairId))
 
-		// Request instance status
-		result, err := p.client.DescribeInstances(&ec2.DescribeInstancesInput{
-			InstanceIds: []*string{runResp.Instances[0].InstanceId},
-		})
-		if err != nil {
+		// Save key
+		var keyDirectory = opts.KeyDirectory
+		if keyDirectory == "" {
+			keyDirectory = local.GetKeyDirectory()
+		}
+		keyPath = filepath.Join(keyDirectory, *keyResp.KeyName)
+		created.keyPath = keyPath
+		fmt.Fprintf(p.out, "Saving key to %s...\n", keyPath)
+		if err = local.SaveKey(*keyResp.KeyMaterial, keyPath); err != nil {
 			return nil, err
 		}
+		if opts.SavePPK {
+			fmt.Fprintf(p.out, "Saving PuTTY key to %s.ppk...\n", keyPath)
+			if err = local.SaveKeyAsPPK(*keyResp.KeyMaterial, keyPath+".ppk", keyName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	keyPhase.End(nil)
 
-		// Check if reservations are present
-		if result.Reservations == nil || len(result.Reservations) == 0 ||
-			len(result.Reservations[0].Instances) == 0 {
-			// A reservation corresponds to a command to start instances
-			// If nothing is here... we gotta keep waiting
-			fmt.Fprintln(p.out, "No reservations found yet.")
-			continue
+	// Create security group for network configuration, unless an existing
+	// group is reused
+	var groupPhase = trace.Child("security group")
+	var groupID = opts.SecurityGroupID
+	if groupID != "" {
+		fmt.Fprintf(p.out, "Using existing security group %s...\n", groupID)
+	} else {
+		var groupDescription = opts.SecurityGroupDescription
+		if groupDescription == "" {
+			groupDescription = fmt.Sprintf("Rules for project %s on %s", opts.ProjectName, opts.Name)
+		}
+		var groupInput = &ec2.CreateSecurityGroupInput{
+			GroupName: aws.String(
+				fmt.Sprintf("%s-%s-%d", opts.ProjectName, opts.Name, time.Now().UnixNano()),
+			),
+			Description: aws.String(groupDescription),
+			VpcId:       vpcID,
+		}
+		// An attempt that failed may still have created the group, in which
+		// case later attempts find that it already exists
+		var attempts int
+		if err = p.retry("CreateSecurityGroup", func() error {
+			attempts++
+			group, err := p.client.CreateSecurityGroup(groupInput)
+			if aerr, ok := err.(awserr.Error); ok && attempts > 1 &&
+				aerr.Code() == codeSecurityGroupDuplicate {
+				groupID, err = p.getSecurityGroupID(*groupInput.GroupName, vpcID)
+				return err
+			} else if err != nil {
+				return err
+			}
+			groupID = aws.StringValue(group.GroupId)
+			return nil
+		}); err != nil {
+			return nil, err
 		}
+		created.groupID = groupID
+		p.tagResources(tags, groupID)
 
-		// Get status
-		s := result.Reservations[0].Instances[0].State
-		if s == nil {
-			fmt.Println(p.out, "Status unknown.")
-			continue
+		// Set rules for ports
+		if err = p.exposePorts(groupID, opts.SSHPort, opts.DaemonPort, ports, inertiaSources,
+			func(r PortRange) string {
+				return portDescription(opts.ProjectName, r, opts.PortDescriptions)
+			}); err != nil {
+			return nil, err
 		}
+	}
+	groupPhase.End(nil)
 
-		// Code 16 means instance has started, and we can continue!
-		if s.Code != nil && *s.Code == codeEC2InstanceStarted {
-			fmt.Fprintln(p.out, "Instance is running!")
-			instance = *result.Reservations[0].Instances[0]
-			break
+	// Start up instance, on spot capacity if requested. The timeout is shared
+	// by the waits for the spot request, the instance, and SSH connections.
+	var launchPhase = trace.Child("launch instance")
+	var deadline = time.Now().Add(opts.Timeout)
+	var network = newInstanceNetwork(opts.SubnetID, groupID, opts.AssociatePublicIP)
+	if opts.Spot {
+		var placement *ec2.SpotPlacement
+		if opts.Tenancy != "" {
+			placement = &ec2.SpotPlacement{Tenancy: aws.String(opts.Tenancy)}
+		}
+		if created.instanceID, err = p.launchSpotInstance(opts, &ec2.RequestSpotLaunchSpecification{
+			ImageId:             aws.String(opts.ImageID),
+			InstanceType:        aws.String(opts.InstanceType),
+			BlockDeviceMappings: blockDevices,
+			IamInstanceProfile:  instanceProfile,
+			Placement:           placement,
+			UserData:            userData,
+			KeyName:             aws.String(keyName),
+			SubnetId:            network.subnetID,
+			SecurityGroupIds:    network.groupIDs,
+			NetworkInterfaces:   network.interfaces,
+		}, created, time.Until(deadline)); err != nil {
+			return nil, err
+		}
+	} else {
+		var placement *ec2.Placement
+		if opts.Tenancy != "" {
+			placement = &ec2.Placement{Tenancy: aws.String(opts.Tenancy)}
+		}
+		// The client token makes retried requests idempotent, so that an
+		// attempt that failed after launching an instance cannot launch another
+		clientToken, err := generateRandomString()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate client token: %s", err.Error())
 		}
+		var runInput = &ec2.RunInstancesInput{
+			ClientToken:  aws.String(clientToken),
+			ImageId:      aws.String(opts.ImageID),
+			InstanceType: aws.String(opts.InstanceType),
+			MinCount:     aws.Int64(1),
+			MaxCount:     aws.Int64(1),
 
-		// Otherwise, keep polling
-		if s.Name != nil {
-			fmt.Fprintln(p.out, "Instance status: "+*s.Name)
-		} else {
-			fmt.Fprintln(p.out, "Instance status: "+s.String())
+			// Storage options
+			BlockDeviceMappings: blockDevices,
+
+			// Placement and network options
+			Placement:         placement,
+			SubnetId:          network.subnetID,
+			NetworkInterfaces: network.interfaces,
+
+			// Startup script
+			UserData: userData,
+
+			// Security options
+			KeyName:            aws.String(keyName),
+			SecurityGroupIds:   network.groupIDs,
+			IamInstanceProfile: instanceProfile,
+		}
+		var runResp *ec2.Reservation
+		if err = p.retry("RunInstances", func() (err error) {
+			runResp, err = p.client.RunInstances(runInput)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+
+		// Check response validity
+		if runResp.Instances == nil || len(runResp.Instances) == 0 {
+			return nil, errors.New("Unable to start instances: " + runResp.String())
 		}
-		continue
+		created.instanceID = aws.StringValue(runResp.Instances[0].InstanceId)
 	}
+	launchPhase.End(nil)
 
-	// Check instance validity
-	if instance.PublicDnsName == nil {
+	// Wait until instance is running
+	var waitPhase = trace.Child("wait for instance")
+	fmt.Fprintln(p.out, "Checking status of requested instance...")
+	instance, account, err := p.waitForInstance(opts.Region, created.instanceID,
+		time.Until(deadline))
+	if err != nil {
+		return nil, err
+	}
+
+	// Check instance validity - instances in subnets that do not assign
+	// public addresses can only be reached through an elastic IP address
+	if aws.StringValue(instance.PublicDnsName) == "" && !opts.AssignElasticIP {
 		return nil, errors.New("Unable to find public IP address for instance: " + instance.String())
 	}
+	waitPhase.End(nil)
+	trace.SetAttribute("ec2.instance_id", aws.StringValue(instance.InstanceId))
 
-	// Set tags
-	if _, err = p.client.CreateTags(&ec2.CreateTagsInput{
-		Resources: []*string{instance.InstanceId},
-		Tags: []*ec2.Tag{
-			{
-				Key:   aws.String("Name"),
-				Value: aws.String(opts.Name),
-			},
-			{
-				Key:   aws.String("Purpose"),
-				Value: aws.String("Inertia Continuous Deployment"),
-			},
-		},
-	}); err != nil {
-		fmt.Fprintln(p.out, "Failed to set tags: "+err.Error())
+	// Set tags on the instance and its volumes
+	var tagged = []string{created.instanceID}
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.VolumeId != nil {
+			tagged = append(tagged, *mapping.Ebs.VolumeId)
+		}
 	}
+	p.tagResources(tags, tagged...)
 
-	// Poll for SSH port to open
-	fmt.Fprintln(p.out, "Waiting for ports to open...")
-	for {
-		time.Sleep(3 * time.Second)
-		fmt.Fprintln(p.out, "Checking ports...")
-		if conn, err := net.Dial("tcp", *instance.PublicDnsName+":22"); err == nil {
-			fmt.Fprintln(p.out, "Connection established!")
-			conn.Close()
-			break
+	// Recover the instance if its hardware fails, if requested
+	var recoveryAlarm string
+	if opts.AutoRecover {
+		var alarmPhase = trace.Child("recovery alarm")
+		if recoveryAlarm, err = p.createRecoveryAlarm(opts.Region, created.instanceID); err != nil {
+			return nil, err
 		}
+		created.recoveryAlarm = recoveryAlarm
+		alarmPhase.End(nil)
 	}
 
-	// Generate webhook secret
-	webhookSecret, err := common.GenerateRandomString()
-	if err != nil {
-		fmt.Fprintln(p.out, err.Error())
-		fmt.Fprintln(p.out, "Using default secret 'inertia'")
-		webhookSecret = "interia"
-	} else {
-		fmt.Fprintf(p.out, "Generated webhook secret: '%s'\n", webhookSecret)
+	// Give the instance a static address, if requested
+	var address = aws.StringValue(instance.PublicDnsName)
+	if opts.AssignElasticIP {
+		var addressPhase = trace.Child("elastic ip")
+		if address, err = p.assignElasticIP(created.instanceID, created); err != nil {
+			return nil, err
+		}
+		p.tagResources(tags, created.elasticIP)
+		addressPhase.End(nil)
 	}
 
+	// Poll for SSH server to accept connections
+	fmt.Fprintln(p.out, "Waiting for ports to open...")
+	var sshPhase = trace.Child("wait for ssh")
+	if err = waitForSSH(p.out, p.user, address, opts.SSHPort, opts.SSH, 3*time.Second,
+		time.Until(deadline)); err != nil {
+		return nil, err
+	}
+	sshPhase.End(nil)
+
+	fmt.Fprintf(p.out, "Webhook secret: '%s'\n", webhookSecret)
+
 	// Return remote configuration
 	return &cfg.RemoteVPS{
 		Name:    opts.Name,
-		IP:      *instance.PublicDnsName,
+		IP:      address,
 		User:    p.user,
 		PEM:     keyPath,
-		SSHPort: "22",
+		SSHPort: strconv.FormatInt(opts.SSHPort, 10),
+		SSH:     opts.SSH,
 		Daemon: &cfg.DaemonConfig{
 			Port:          strconv.FormatInt(opts.DaemonPort, 10),
 			WebHookSecret: webhookSecret,
 		},
+		Resources: &cfg.ProvisionedResources{
+			Provider: "ec2",
+			Region:   opts.Region,
+			Account:  account,
+
+			InstanceID:   *instance.InstanceId,
+			InstanceARN:  ec2ARN(opts.Region, account, "instance/"+*instance.InstanceId),
+			ImageID:      opts.ImageID,
+			InstanceType: opts.InstanceType,
+
+			SecurityGroupID:  groupID,
+			SecurityGroupARN: ec2ARN(opts.Region, account, "security-group/"+groupID),
+			SubnetID:         opts.SubnetID,
+
+			KeyPairName:     keyName,
+			ExistingKeyPair: opts.KeyPairName != "",
+
+			Spot:      opts.Spot,
+			SpotPrice: opts.SpotPrice,
+
+			RecoveryAlarmName:     recoveryAlarm,
+			ElasticIPAllocationID: created.elasticIP,
+		},
 	}, nil
 }
 
-// WithRegion assigns a region to the client
-func (p *EC2Provisioner) WithRegion(region string) {
-	p.client.Config.WithRegion(region)
-	p.client = ec2.New(p.session, &p.client.Config)
+// TerminateInstance terminates the instance with the given ID in region, and
+// waits for it to shut down. Its key pair and security group are kept, since
+// they may be shared with other instances.
+func (p *EC2Provisioner) TerminateInstance(region, instanceID string) error {
+	if err := p.WithRegion(region); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Terminating instance %s...\n", instanceID)
+	if _, err := p.client.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	}); err != nil {
+		return err
+	}
+	return p.client.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	})
+}
+
+// DestroyInstance terminates the instance with the given ID in region, and
+// deletes its recovery alarm and releases its Elastic IP address with the given
+// allocation ID if it has them. If its key pair was generated when the instance
+// was provisioned, the key pair is deleted and its private key at keyPath is
+// removed. Key pairs that were not generated for the instance are kept, as is
+// its security group.
+func (p *EC2Provisioner) DestroyInstance(region, instanceID, recoveryAlarm, elasticIP,
+	keyPairName, keyPath string) error {
+	if err := p.TerminateInstance(region, instanceID); err != nil {
+		return err
+	}
+	if elasticIP != "" {
+		if err := p.releaseElasticIP(elasticIP); err != nil {
+			return err
+		}
+	}
+	if recoveryAlarm != "" {
+		if err := p.deleteRecoveryAlarm(region, recoveryAlarm); err != nil {
+			return err
+		}
+	}
+	if !local.IsGeneratedKey(keyPairName) {
+		return nil
+	}
+	fmt.Fprintf(p.out, "Deleting key pair %s...\n", keyPairName)
+	if _, err := p.client.DeleteKeyPair(&ec2.DeleteKeyPairInput{
+		KeyName: aws.String(keyPairName),
+	}); err != nil {
+		return err
+	}
+	if keyPath == "" {
+		return nil
+	}
+	fmt.Fprintf(p.out, "Removing key %s...\n", keyPath)
+	return local.RemoveKey(keyPath)
+}
+
+// createdResources are the resources created while provisioning an instance,
+// which are removed if provisioning fails part way through. Resources that
+// were reused rather than created are not included.
+type createdResources struct {
+	region        string
+	spotRequestID string
+	instanceID    string
+	recoveryAlarm string
+	elasticIP     string
+	groupID       string
+	keyName       string
+	keyPath       string
+}
+
+// cleanUp removes the given resources, left behind by a failed attempt to
+// provision an instance. Failures to remove resources are reported, but do not
+// stop the remaining resources from being removed.
+func (p *EC2Provisioner) cleanUp(created createdResources) {
+	if created.spotRequestID == "" && created.instanceID == "" && created.recoveryAlarm == "" &&
+		created.elasticIP == "" && created.groupID == "" && created.keyName == "" {
+		return
+	}
+	fmt.Fprintln(p.out, "Removing resources created for the instance...")
+
+	// Cancel spot requests first, so that they are not fulfilled by another
+	// instance once the current one is terminated
+	if created.spotRequestID != "" {
+		fmt.Fprintf(p.out, "Cancelling spot request %s...\n", created.spotRequestID)
+		if _, err := p.client.CancelSpotInstanceRequests(&ec2.CancelSpotInstanceRequestsInput{
+			SpotInstanceRequestIds: []*string{aws.String(created.spotRequestID)},
+		}); err != nil {
+			fmt.Fprintf(p.out, "Failed to cancel spot request %s: %s\n",
+				created.spotRequestID, err.Error())
+		}
+	}
+
+	if created.recoveryAlarm != "" {
+		if err := p.deleteRecoveryAlarm(created.region, created.recoveryAlarm); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete recovery alarm %s: %s\n",
+				created.recoveryAlarm, err.Error())
+		}
+	}
+
+	// The instance must be terminated before its elastic IP address can be
+	// released and its security group can be deleted
+	if created.instanceID != "" {
+		if err := p.TerminateInstance(created.region, created.instanceID); err != nil {
+			fmt.Fprintf(p.out, "Failed to terminate instance %s: %s\n",
+				created.instanceID, err.Error())
+		}
+	}
+	if created.elasticIP != "" {
+		if err := p.releaseElasticIP(created.elasticIP); err != nil {
+			fmt.Fprintf(p.out, "Failed to release elastic IP address %s: %s\n",
+				created.elasticIP, err.Error())
+		}
+	}
+	if created.groupID != "" {
+		fmt.Fprintf(p.out, "Deleting security group %s...\n", created.groupID)
+		if _, err := p.client.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+			GroupId: aws.String(created.groupID),
+		}); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete security group %s: %s\n",
+				created.groupID, err.Error())
+		}
+	}
+	if created.keyName != "" {
+		fmt.Fprintf(p.out, "Deleting key pair %s...\n", created.keyName)
+		if _, err := p.client.DeleteKeyPair(&ec2.DeleteKeyPairInput{
+			KeyName: aws.String(created.keyName),
+		}); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete key pair %s: %s\n",
+				created.keyName, err.Error())
+		}
+		if created.keyPath != "" {
+			if err := local.RemoveKey(created.keyPath); err != nil {
+				fmt.Fprintf(p.out, "Failed to remove key %s: %s\n",
+					created.keyPath, err.Error())
+			}
+		}
+	}
+}
+
+// checkKeyPair returns an error if the key pair with the given name does not
+// exist in the provisioner's region
+func (p *EC2Provisioner) checkKeyPair(name string) error {
+	var err = p.retry("DescribeKeyPairs", func() error {
+		_, err := p.client.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
+			KeyNames: []*string{aws.String(name)},
+		})
+		return err
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == codeKeyPairNotFound {
+		return fmt.Errorf("key pair %s does not exist in region %s", name, p.region)
+	}
+	return err
+}
+
+// ec2ARN returns the ARN of the given EC2 resource, such as "instance/i-123",
+// owned by account in region
+func ec2ARN(region, account, resource string) string {
+	return fmt.Sprintf("arn:%s:ec2:%s:%s:%s", awsPartition(region), region, account, resource)
+}
+
+// awsPartition returns the partition, used in ARNs, that region belongs to
+func awsPartition(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "aws-cn"
+	} else if strings.HasPrefix(region, "us-gov-") {
+		return "aws-us-gov"
+	}
+	return "aws"
+}
+
+// CleanupOldSnapshots deletes all but the keepLast most recent Inertia-tagged
+// AMIs and EBS snapshots owned by the current account. AMIs still in use by
+// instances, and snapshots backing AMIs that are kept, are not deleted.
+func (p *EC2Provisioner) CleanupOldSnapshots(keepLast int) error {
+	if keepLast < 0 {
+		return errors.New("number of snapshots to keep cannot be negative")
+	}
+	var inertiaFilter = &ec2.Filter{
+		Name:   aws.String("tag:Purpose"),
+		Values: []*string{aws.String(inertiaPurposeTag)},
+	}
+
+	// Deregister old images that are not in use
+	images, err := p.describeImages(&ec2.DescribeImagesInput{
+		Owners:  []*string{aws.String("self")},
+		Filters: []*ec2.Filter{inertiaFilter},
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return newerThan(images[i].CreationDate, images[j].CreationDate)
+	})
+	var keptSnapshots = map[string]bool{}
+	for i, image := range images {
+		if i >= keepLast {
+			inUse, err := p.imageInUse(*image.ImageId)
+			if err != nil {
+				return err
+			}
+			if !inUse {
+				fmt.Fprintf(p.out, "Deregistering image %s...\n", *image.ImageId)
+				if _, err = p.client.DeregisterImage(&ec2.DeregisterImageInput{
+					ImageId: image.ImageId,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Fprintf(p.out, "[WARNING] image %s is in use by an instance - skipping\n",
+				*image.ImageId)
+		}
+
+		// Snapshots backing images that are kept cannot be deleted
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				keptSnapshots[*mapping.Ebs.SnapshotId] = true
+			}
+		}
+	}
+
+	// Delete old snapshots - accounts can have more snapshots than are
+	// returned in one page
+	var snapshots []*ec2.Snapshot
+	if err = p.client.DescribeSnapshotsPages(&ec2.DescribeSnapshotsInput{
+		OwnerIds: []*string{aws.String("self")},
+		Filters:  []*ec2.Filter{inertiaFilter},
+	}, func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
+		snapshots = append(snapshots, page.Snapshots...)
+		return true
+	}); err != nil {
+		return err
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		var iStart, jStart = snapshots[i].StartTime, snapshots[j].StartTime
+		return iStart != nil && (jStart == nil || iStart.After(*jStart))
+	})
+	for i, snapshot := range snapshots {
+		if i < keepLast || keptSnapshots[*snapshot.SnapshotId] {
+			continue
+		}
+		fmt.Fprintf(p.out, "Deleting snapshot %s...\n", *snapshot.SnapshotId)
+		if _, err = p.client.DeleteSnapshot(&ec2.DeleteSnapshotInput{
+			SnapshotId: snapshot.SnapshotId,
+		}); err != nil {
+			// Snapshots can back images that are not tagged by Inertia
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == codeSnapshotInUse {
+				fmt.Fprintf(p.out, "[WARNING] snapshot %s is in use - skipping: %s\n",
+					*snapshot.SnapshotId, aerr.Message())
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithRegion assigns a region to the client, and selects the appropriate
+// endpoint for the region
+func (p *EC2Provisioner) WithRegion(region string) error {
+	endpoint, err := ec2Endpoint(region, p.endpoint, p.fips)
+	if err != nil {
+		return err
+	}
+	var config = &aws.Config{
+		Credentials: p.creds,
+		Region:      aws.String(region),
+	}
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+	p.client = newEC2Client(p.session, config)
+	p.region = region
+	return nil
+}
+
+// WithTracer exports traces of instance creation to the OpenTelemetry
+// collector at the given OTLP endpoint. An empty endpoint disables tracing.
+func (p *EC2Provisioner) WithTracer(endpoint string) {
+	p.tracer = common.NewTracer(endpoint, "inertia-provision", p.out)
+}
+
+// WaitForTraces blocks until traces of instance creation, which are exported
+// in the background, have been sent
+func (p *EC2Provisioner) WaitForTraces() { p.tracer.Wait() }
+
+// WithFIPS toggles the use of FIPS 140-2 validated endpoints, which are only
+// available in some regions
+func (p *EC2Provisioner) WithFIPS(enabled bool) error {
+	p.fips = enabled
+	return p.reapplyRegion()
+}
+
+// WithEndpoint sets an EC2 endpoint to use in all regions instead of the
+// default regional endpoints. An empty endpoint restores the defaults.
+func (p *EC2Provisioner) WithEndpoint(endpoint string) error {
+	p.endpoint = endpoint
+	return p.reapplyRegion()
+}
+
+// waitForInstance blocks until the instance with the given ID in region is
+// running, and returns it along with the ID of the account that owns it. An
+// error is returned if the instance is not running within timeout.
+func (p *EC2Provisioner) waitForInstance(region, instanceID string,
+	timeout time.Duration) (*ec2.Instance, string, error) {
+	p.pollersMux.Lock()
+	if p.pollers == nil {
+		p.pollers = make(map[string]*instancePoller)
+	}
+	poller, found := p.pollers[region]
+	if !found {
+		poller = newInstancePoller(func(input *ec2.DescribeInstancesInput) (
+			output *ec2.DescribeInstancesOutput, err error) {
+			err = p.retry("DescribeInstances", func() (err error) {
+				output, err = p.client.DescribeInstances(input)
+				return err
+			})
+			return output, err
+		}, instancePollInterval, p.out)
+		p.pollers[region] = poller
+	}
+	p.pollersMux.Unlock()
+	return poller.wait(instanceID, timeout)
+}
+
+// reapplyRegion updates the client's endpoint for its current region, if one
+// has been set
+func (p *EC2Provisioner) reapplyRegion() error {
+	// Pollers use the client they were created with, so discard them to use
+	// the new endpoint
+	p.pollersMux.Lock()
+	p.pollers = nil
+	p.pollersMux.Unlock()
+	if p.region != "" {
+		return p.WithRegion(p.region)
+	}
+	return nil
+}
+
+// newEC2Client creates the clients used to make EC2 requests in a region.
+// Stubbed out for testing.
+var newEC2Client = defaultNewEC2Client
+
+func defaultNewEC2Client(sess *session.Session, config *aws.Config) ec2iface.EC2API {
+	return ec2.New(sess, config)
+}
+
+// dialTCP opens TCP connections, giving up after the given timeout. Stubbed
+// out for testing.
+var dialTCP = net.DialTimeout
+
+// generateRandomString generates webhook secrets. Stubbed out for testing.
+var generateRandomString = common.GenerateRandomString
+
+// waitForPort blocks until a connection can be made to the given port of
+// host, checking at the given interval and reporting progress to out. An error
+// is returned if no connection can be made within timeout.
+func waitForPort(out io.Writer, host string, port int64, interval,
+	timeout time.Duration) error {
+	var address = net.JoinHostPort(host, strconv.FormatInt(port, 10))
+	var deadline = time.Now().Add(timeout)
+	for {
+		time.Sleep(interval)
+
+		// Connection attempts are bounded by the time remaining, so that an
+		// unresponsive host cannot hold up provisioning past the deadline
+		var remaining = time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("timed out after %s waiting for %s to accept connections",
+				timeout.Round(time.Second), address)
+		}
+		fmt.Fprintln(out, "Checking ports...")
+		if conn, err := dialTCP("tcp", address, remaining); err == nil {
+			fmt.Fprintln(out, "Connection established!")
+			conn.Close()
+			return nil
+		}
+	}
+}
+
+// dialSSH opens SSH connections. Stubbed out for testing.
+var dialSSH = ssh.Dial
+
+// waitForSSH blocks until the SSH server of a newly created instance at the
+// given host and port accepts connections and presents a host key that is
+// accepted under the given SSH options, checking at the given interval and
+// reporting progress to out. Keys known for the address belonged to a previous
+// host, so they are forgotten first unless host keys are checked strictly.
+// Failed connections are retried as configured in opts, and an error is
+// returned if the server is not ready within timeout.
+func waitForSSH(out io.Writer, user, host string, port int64, opts *cfg.SSHOptions,
+	interval, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	if err := waitForPort(out, host, port, interval, timeout); err != nil {
+		return err
+	}
+
+	var address = net.JoinHostPort(host, strconv.FormatInt(port, 10))
+	var mode, knownHostsFile = local.GetHostKeyChecking(opts), local.GetKnownHostsFile(opts)
+	if mode == cfg.HostKeyCheckingAcceptNew {
+		if err := local.ForgetHostKey(knownHostsFile, host, strconv.FormatInt(port, 10)); err != nil {
+			return fmt.Errorf("failed to remove old host key for %s: %s", address, err.Error())
+		}
+	}
+	verify, err := local.HostKeyCallback(mode, knownHostsFile)
+	if err != nil {
+		return err
+	}
+	var retries int
+	if opts != nil {
+		retries = opts.Retries
+	}
+
+	// No credentials are offered - the server is ready once its host key has
+	// been accepted, which happens before authentication
+	var verified bool
+	var keyErr error
+	var config = &ssh.ClientConfig{
+		User: user,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			keyErr = verify(hostname, remote, key)
+			verified = keyErr == nil
+			return keyErr
+		},
+	}
+	for attempt := 0; ; attempt++ {
+		var remaining = time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("timed out after %s waiting for SSH server at %s to be ready",
+				timeout.Round(time.Second), address)
+		}
+		config.Timeout = local.GetSSHTimeout(opts)
+		if remaining < config.Timeout {
+			config.Timeout = remaining
+		}
+		fmt.Fprintln(out, "Checking SSH server...")
+		if conn, err := dialSSH("tcp", address, config); err == nil {
+			conn.Close()
+		} else if keyErr != nil {
+			return fmt.Errorf("failed to verify host key of %s: %s", address, keyErr.Error())
+		} else if !verified && (attempt >= retries || time.Now().After(deadline)) {
+			return fmt.Errorf("SSH server at %s is not ready: %s", address, err.Error())
+		}
+		if verified {
+			fmt.Fprintln(out, "SSH server is ready!")
+			return nil
+		}
+		time.Sleep(interval)
+	}
 }
 
 // exposePorts updates the security rules of given security group to expose
-// given ports
-func (p *EC2Provisioner) exposePorts(securityGroupID string, daemonPort int64, ports []int64) error {
+// given ports, using describe to generate descriptions for project port rules
+func (p *EC2Provisioner) exposePorts(securityGroupID string, sshPort, daemonPort int64,
+	ports []PortRange, inertiaSources ingressSources, describe func(PortRange) string) error {
+	var input = &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(securityGroupID),
+		IpPermissions: portPermissions(sshPort, daemonPort, ports, inertiaSources, describe),
+	}
+	return p.retry("AuthorizeSecurityGroupIngress", func() error {
+		_, err := p.client.AuthorizeSecurityGroupIngress(input)
+		return err
+	})
+}
+
+// portPermissions creates security group rules that allow traffic to the SSH
+// and daemon ports from inertiaSources, and to project ports from anywhere
+func portPermissions(sshPort, daemonPort int64, ports []PortRange,
+	inertiaSources ingressSources, describe func(PortRange) string) []*ec2.IpPermission {
 	// Create Inertia rules
-	portRules := []*ec2.IpPermission{{
-		FromPort:   aws.Int64(int64(22)),
-		ToPort:     aws.Int64(int64(22)),
-		IpProtocol: aws.String("tcp"),
-		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0"), Description: aws.String("Inertia SSH port")}},
-		Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0"), Description: aws.String("Inertia SSH port")}},
-	}, {
-		FromPort:   aws.Int64(daemonPort),
-		ToPort:     aws.Int64(daemonPort),
-		IpProtocol: aws.String("tcp"),
-		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0"), Description: aws.String("Inertia daemon port")}},
-		Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0"), Description: aws.String("Inertia daemon port")}},
-	}}
+	var portRules = []*ec2.IpPermission{
+		inertiaSources.permission(PortRange{From: sshPort, To: sshPort}, "Inertia SSH port"),
+		inertiaSources.permission(PortRange{From: daemonPort, To: daemonPort}, "Inertia daemon port"),
+	}
 
 	// Generate rules for user project
-	for _, port := range ports {
-		portRules = append(portRules, &ec2.IpPermission{
-			FromPort:   aws.Int64(port),
-			ToPort:     aws.Int64(port),
-			IpProtocol: aws.String("tcp"), // todo: allow config
-			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
-			Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}},
-		})
+	for _, r := range ports {
+		portRules = append(portRules, anywhere.permission(r, describe(r)))
 	}
+	return portRules
+}
 
-	// Set rules
-	_, err := p.client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId:       aws.String(securityGroupID),
-		IpPermissions: portRules,
+// authorizedKeysScript validates the given public keys, and returns an
+// instance user data script that authorizes them for SSH access as user. An
+// empty script is returned if there are no keys.
+func authorizedKeysScript(user string, keys []string) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+	var authorized = make([]string, len(keys))
+	for i, key := range keys {
+		key = strings.TrimSpace(key)
+		if strings.ContainsAny(key, "\r\n") {
+			return "", fmt.Errorf("public key %d must be a single line", i+1)
+		}
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+			return "", fmt.Errorf("invalid public key %d: %s", i+1, err.Error())
+		}
+		authorized[i] = key
+	}
+
+	var sshDir = fmt.Sprintf("/home/%s/.ssh", user)
+	return fmt.Sprintf(`#!/bin/bash
+mkdir -p %[1]s
+cat >> %[1]s/authorized_keys <<'INERTIA_KEYS'
+%[2]s
+INERTIA_KEYS
+chown -R %[3]s:%[3]s %[1]s
+chmod 600 %[1]s/authorized_keys
+`, sshDir, strings.Join(authorized, "\n"), user), nil
+}
+
+// portDescription returns the security group rule description for the given
+// project ports - the description in descriptions if there is one, or a
+// default description otherwise
+func portDescription(project string, r PortRange, descriptions map[int64]string) string {
+	if description, ok := descriptions[r.From]; ok && description != "" {
+		return description
+	}
+	if r.isICMP() {
+		return fmt.Sprintf("Project %s ICMP", project)
+	}
+	var ports = fmt.Sprintf("port %d", r.From)
+	if r.To != r.From {
+		ports = fmt.Sprintf("ports %d-%d", r.From, r.To)
+	}
+	if r.protocol() != "tcp" {
+		ports += "/" + r.protocol()
+	}
+	return fmt.Sprintf("Project %s %s", project, ports)
+}
+
+// imageInUse checks if any instance that has not been terminated was launched
+// from the given image
+func (p *EC2Provisioner) imageInUse(imageID string) (bool, error) {
+	result, err := p.client.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("image-id"),
+				Values: []*string{aws.String(imageID)},
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{
+					"pending", "running", "shutting-down", "stopping", "stopped"}),
+			},
+		},
 	})
-	return err
+	if err != nil {
+		return false, err
+	}
+	for _, reservation := range result.Reservations {
+		if len(reservation.Instances) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newerThan checks if date a is more recent than date b, where both are AWS
+// creation dates. Unparseable dates are considered older than any other.
+func newerThan(a, b *string) bool {
+	if a == nil {
+		return false
+	}
+	aCreated := common.ParseDate(*a)
+	if aCreated == nil {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	bCreated := common.ParseDate(*b)
+	if bCreated == nil {
+		return true
+	}
+	return aCreated.After(*bCreated)
+}
+
+// ec2Endpoint returns the EC2 endpoint to use in the given region, or an
+// empty string if the default endpoint for the region should be used
+func ec2Endpoint(region, override string, fips bool) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if !fips {
+		return "", nil
+	}
+	if strings.HasPrefix(region, "us-gov-") {
+		return fmt.Sprintf("https://ec2.%s.amazonaws.com", region), nil
+	}
+	for _, r := range fipsRegions {
+		if r == region {
+			return fmt.Sprintf("https://ec2-fips.%s.amazonaws.com", region), nil
+		}
+	}
+	return "", fmt.Errorf("FIPS endpoints are not available in region '%s' - "+
+		"FIPS endpoints are available in GovCloud and in %s",
+		region, strings.Join(fipsRegions, ", "))
+}
+
+// checkDryRun interprets the result of a dry run request, returning whether
+// the request would have been permitted, or the error if the request was
+// rejected for reasons other than authorization
+func checkDryRun(err error) (permitted bool, other error) {
+	if err == nil {
+		return true, nil
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case codeDryRunOperation:
+			return true, nil
+		case codeUnauthorizedOperation:
+			return false, nil
+		}
+	}
+	return false, err
+}
+
+// generateWebhookSecret generates a random webhook secret. Generation is
+// attempted a second time if it fails, and the given fallback secret is only
+// returned if both attempts fail and a fallback is set.
+func generateWebhookSecret(fallback string) (string, error) {
+	secret, err := generateRandomString()
+	if err != nil {
+		secret, err = generateRandomString()
+	}
+	if err == nil {
+		return secret, nil
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("failed to generate webhook secret: %s", err.Error())
+	}
+	return fallback, nil
+}
+
+// validateSSHPort checks that the given SSH port is within bounds
+func validateSSHPort(port int64) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid SSH port %d - must be between 1 and 65535", port)
+	}
+	return nil
+}
+
+// validateTenancy checks that the given tenancy is valid and supported by the
+// given instance type
+func validateTenancy(tenancy, instanceType string) error {
+	switch tenancy {
+	case "", ec2.TenancyDefault:
+		return nil
+	case ec2.TenancyDedicated, ec2.TenancyHost:
+	default:
+		return fmt.Errorf("invalid tenancy '%s' - must be one of '%s', '%s', or '%s'",
+			tenancy, ec2.TenancyDefault, ec2.TenancyDedicated, ec2.TenancyHost)
+	}
+
+	// Older burstable instance types cannot run on dedicated hardware - see
+	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/dedicated-instance.html
+	var family = strings.SplitN(instanceType, ".", 2)[0]
+	switch family {
+	case "t1", "t2":
+		return fmt.Errorf("instance type '%s' does not support tenancy '%s'",
+			instanceType, tenancy)
+	}
+	return nil
 }
 
 func (p *EC2Provisioner) init(user string, creds *credentials.Credentials, out []io.Writer) error {
@@ -358,8 +1607,10 @@ func (p *EC2Provisioner) init(user string, creds *credentials.Credentials, out [
 	}))
 
 	// Set up EC2 client
-	p.client = ec2.New(p.session, &aws.Config{Credentials: creds})
+	p.creds = creds
+	var client = ec2.New(p.session, &aws.Config{Credentials: creds})
 	// Workaround for a strange bug where client instantiates with "https://ec2..amazonaws.com"
-	p.client.Endpoint = "https://ec2.amazonaws.com"
+	client.Endpoint = "https://ec2.amazonaws.com"
+	p.client = client
 	return nil
 }
@@ -1,25 +1,513 @@
 package provision
 
 import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/common"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestNewEC2Provisioner(t *testing.T) {
 	prov, _ := NewEC2Provisioner("bob", "id", "key")
-	assert.NotNil(t, prov.client.Config.Credentials)
+	assert.NotNil(t, prov.creds)
 	assert.Equal(t, "bob", prov.GetUser())
 }
 
 func TestNewEC2ProvisionerFromEnv(t *testing.T) {
 	prov, _ := NewEC2Provisioner("bob", "id", "key")
-	assert.NotNil(t, prov.client.Config.Credentials)
+	assert.NotNil(t, prov.creds)
 	assert.Equal(t, "bob", prov.GetUser())
 }
 
 func TestNewEC2ProvisionerFromProfile(t *testing.T) {
 	prov, _ := NewEC2ProvisionerFromProfile("bob", "", "../test/aws/credentials")
-	assert.NotNil(t, prov.client.Config.Credentials)
+	assert.NotNil(t, prov.creds)
 	assert.Equal(t, "bob", prov.GetUser())
 }
+
+func TestValidateTenancy(t *testing.T) {
+	type args struct {
+		tenancy      string
+		instanceType string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"unset", args{"", "t2.micro"}, false},
+		{"default", args{"default", "t2.micro"}, false},
+		{"dedicated", args{"dedicated", "m5.large"}, false},
+		{"host", args{"host", "c5.xlarge"}, false},
+		{"unsupported instance type", args{"dedicated", "t2.micro"}, true},
+		{"invalid tenancy", args{"shared", "m5.large"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTenancy(tt.args.tenancy, tt.args.instanceType)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestValidateSSHPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    int64
+		wantErr bool
+	}{
+		{"default", 22, false},
+		{"custom", 2222, false},
+		{"highest", 65535, false},
+		{"negative", -22, true},
+		{"too high", 65536, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validateSSHPort(tt.port) != nil)
+		})
+	}
+}
+
+func TestNewerThan(t *testing.T) {
+	var (
+		older = "2018-01-02T15:04:05.000Z"
+		newer = "2019-01-02T15:04:05.000Z"
+		bad   = "not a date"
+	)
+	type args struct {
+		a *string
+		b *string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"newer", args{&newer, &older}, true},
+		{"older", args{&older, &newer}, false},
+		{"unparseable", args{&bad, &older}, false},
+		{"other unparseable", args{&older, &bad}, true},
+		{"nil", args{nil, &older}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newerThan(tt.args.a, tt.args.b))
+		})
+	}
+}
+
+func TestRegionNames(t *testing.T) {
+	assert.Equal(t, []string{}, regionNames(nil))
+	assert.Equal(t, []string{"ap-south-1", "eu-west-1", "us-west-2"}, regionNames([]*ec2.Region{
+		{RegionName: aws.String("us-west-2")},
+		{RegionName: aws.String("ap-south-1")},
+		{},
+		{RegionName: aws.String("eu-west-1")},
+	}))
+}
+
+func TestImageOptions(t *testing.T) {
+	var image = func(id, name, created string) *ec2.Image {
+		var i = &ec2.Image{
+			ImageId:      aws.String(id),
+			Description:  aws.String(id + " image"),
+			CreationDate: aws.String(created),
+		}
+		if name != "" {
+			i.Name = aws.String(name)
+		}
+		return i
+	}
+	var images = func() []*ec2.Image {
+		return []*ec2.Image{
+			image("ami-1", "amzn-1", "2018-01-02T15:04:05.000Z"),
+			image("ami-2", "", "2020-01-02T15:04:05.000Z"),
+			image("ami-3", "amzn-3", "2019-01-02T15:04:05.000Z"),
+			image("ami-4", "amzn-4", "2017-01-02T15:04:05.000Z"),
+		}
+	}
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"all", 0, []string{"ami-3 (ami-3 image)", "ami-1 (ami-1 image)", "ami-4 (ami-4 image)"}},
+		{"limited", 2, []string{"ami-3 (ami-3 image)", "ami-1 (ami-1 image)"}},
+		{"limit above count", 10, []string{"ami-3 (ami-3 image)", "ami-1 (ami-1 image)", "ami-4 (ami-4 image)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, imageOptions(images(), tt.limit))
+		})
+	}
+}
+
+func TestListImageOptions_nameWithoutOwner(t *testing.T) {
+	var p = &EC2Provisioner{}
+	_, err := p.ListImageOptions("us-east-1", ImageFilter{NamePattern: "ubuntu/images/*"}, 0)
+	assert.EqualError(t, err, "image owners are required to filter images by name")
+}
+
+func TestPortPermissions(t *testing.T) {
+	sources, err := parseIngressSources([]string{"203.0.113.0/24"})
+	assert.Nil(t, err)
+	var rules = portPermissions(2222, 4303, []PortRange{
+		{From: 8080, To: 8080},
+		{From: 27015, To: 27015, Protocol: "udp"},
+		{Protocol: "icmp"},
+	}, sources, func(PortRange) string { return "web" })
+	assert.Len(t, rules, 5)
+
+	// Inertia ports are restricted
+	for _, rule := range rules[:2] {
+		assert.Len(t, rule.IpRanges, 1)
+		assert.Equal(t, "203.0.113.0/24", *rule.IpRanges[0].CidrIp)
+		assert.Len(t, rule.Ipv6Ranges, 0)
+	}
+	assert.Equal(t, int64(2222), *rules[0].FromPort)
+	assert.Equal(t, "Inertia SSH port", *rules[0].IpRanges[0].Description)
+
+	// Project ports are not
+	assert.Equal(t, "0.0.0.0/0", *rules[2].IpRanges[0].CidrIp)
+	assert.Equal(t, "::/0", *rules[2].Ipv6Ranges[0].CidrIpv6)
+	assert.Equal(t, "web", *rules[2].Ipv6Ranges[0].Description)
+	assert.Equal(t, "tcp", *rules[2].IpProtocol)
+
+	// Each project rule uses its own protocol, and ICMP allows all types
+	assert.Equal(t, "udp", *rules[3].IpProtocol)
+	assert.Equal(t, int64(27015), *rules[3].FromPort)
+	assert.Equal(t, "icmp", *rules[4].IpProtocol)
+	assert.Equal(t, int64(-1), *rules[4].FromPort)
+	assert.Equal(t, int64(-1), *rules[4].ToPort)
+}
+
+func TestPortDescription(t *testing.T) {
+	var descriptions = map[int64]string{80: "Public web server"}
+	assert.Equal(t, "Public web server", portDescription("wow", PortRange{80, 80, "tcp"}, descriptions))
+	assert.Equal(t, "Project wow port 8080", portDescription("wow", PortRange{8080, 8080, "tcp"}, descriptions))
+	assert.Equal(t, "Project wow port 8080", portDescription("wow", PortRange{8080, 8080, ""}, nil))
+	assert.Equal(t, "Project wow ports 8000-8100/udp", portDescription("wow", PortRange{8000, 8100, "udp"}, nil))
+	assert.Equal(t, "Project wow ICMP", portDescription("wow", PortRange{Protocol: "icmp"}, nil))
+}
+
+func TestAuthorizedKeysScript(t *testing.T) {
+	const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGbVAQfo/cOVQvxsCkS3Mh0RzaqAy4rKLpn7ZWNU2ZVY bob@example.com"
+
+	// No keys
+	script, err := authorizedKeysScript("ec2-user", nil)
+	assert.Nil(t, err)
+	assert.Empty(t, script)
+
+	// Valid keys
+	script, err = authorizedKeysScript("ec2-user", []string{key + "\n"})
+	assert.Nil(t, err)
+	assert.Contains(t, script, "/home/ec2-user/.ssh/authorized_keys")
+	assert.Contains(t, script, key+"\n")
+
+	// Invalid keys
+	_, err = authorizedKeysScript("ec2-user", []string{"not a key"})
+	assert.NotNil(t, err)
+	_, err = authorizedKeysScript("ec2-user", []string{key + "\nrm -rf /"})
+	assert.NotNil(t, err)
+}
+
+func TestCheckDryRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantPermitted bool
+		wantErr       bool
+	}{
+		{"no error", nil, true, false},
+		{"dry run succeeded", awserr.New(codeDryRunOperation, "", nil), true, false},
+		{"unauthorized", awserr.New(codeUnauthorizedOperation, "", nil), false, false},
+		{"other aws error", awserr.New("InvalidAMIID.NotFound", "", nil), false, true},
+		{"other error", errors.New("oh no"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permitted, err := checkDryRun(tt.err)
+			assert.Equal(t, tt.wantPermitted, permitted)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestEC2Endpoint(t *testing.T) {
+	type args struct {
+		region   string
+		override string
+		fips     bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"default", args{"us-west-2", "", false}, "", false},
+		{"override", args{"us-west-2", "https://ec2.example.com", true}, "https://ec2.example.com", false},
+		{"fips", args{"us-west-2", "", true}, "https://ec2-fips.us-west-2.amazonaws.com", false},
+		{"govcloud fips", args{"us-gov-west-1", "", true}, "https://ec2.us-gov-west-1.amazonaws.com", false},
+		{"fips unavailable", args{"eu-west-1", "", true}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ec2Endpoint(tt.args.region, tt.args.override, tt.args.fips)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEC2ARN(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		want   string
+	}{
+		{"commercial", "us-west-2", "arn:aws:ec2:us-west-2:123:instance/i-1"},
+		{"govcloud", "us-gov-west-1", "arn:aws-us-gov:ec2:us-gov-west-1:123:instance/i-1"},
+		{"china", "cn-north-1", "arn:aws-cn:ec2:cn-north-1:123:instance/i-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ec2ARN(tt.region, "123", "instance/i-1"))
+		})
+	}
+}
+
+func TestEC2ProvisionerWithFIPS(t *testing.T) {
+	prov, _ := NewEC2Provisioner("bob", "id", "key")
+	assert.Nil(t, prov.WithFIPS(true))
+	assert.Nil(t, prov.WithRegion("us-east-1"))
+	assert.Equal(t, "https://ec2-fips.us-east-1.amazonaws.com", prov.client.(*ec2.EC2).Endpoint)
+
+	// Switching regions should switch FIPS endpoints
+	assert.Nil(t, prov.WithRegion("us-west-1"))
+	assert.Equal(t, "https://ec2-fips.us-west-1.amazonaws.com", prov.client.(*ec2.EC2).Endpoint)
+	assert.NotNil(t, prov.WithRegion("eu-west-1"))
+
+	// Disabling FIPS should restore the default endpoint
+	assert.Nil(t, prov.WithFIPS(false))
+	assert.Equal(t, "https://ec2.us-west-1.amazonaws.com", prov.client.(*ec2.EC2).Endpoint)
+}
+
+func TestWaitForPort(t *testing.T) {
+	var dialed = []string{}
+	dialTCP = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		dialed = append(dialed, address)
+		assert.True(t, timeout > 0 && timeout <= time.Minute)
+		if len(dialed) < 2 {
+			return nil, errors.New("connection refused")
+		}
+		conn, remote := net.Pipe()
+		remote.Close()
+		return conn, nil
+	}
+	defer func() { dialTCP = net.DialTimeout }()
+
+	assert.Nil(t, waitForPort(ioutil.Discard, "ec2.amazonaws.com", 2222, time.Millisecond, time.Minute))
+	assert.Equal(t, []string{"ec2.amazonaws.com:2222", "ec2.amazonaws.com:2222"}, dialed)
+}
+
+func TestWaitForPort_timeout(t *testing.T) {
+	// Hosts that do not respond are given no more than the time remaining
+	dialTCP = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		time.Sleep(timeout)
+		return nil, errors.New("i/o timeout")
+	}
+	defer func() { dialTCP = net.DialTimeout }()
+
+	var started = time.Now()
+	var err = waitForPort(ioutil.Discard, "ec2.amazonaws.com", 22, time.Millisecond, 50*time.Millisecond)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "waiting for ec2.amazonaws.com:22 to accept connections")
+	assert.True(t, time.Since(started) < time.Second)
+}
+
+// startSSHServer starts an SSH server with the given host key that rejects
+// all clients, and returns its port
+func startSSHServer(t *testing.T, hostKey ssh.Signer) (port int64, stop func()) {
+	var config = &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("access denied")
+		},
+	}
+	config.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go ssh.NewServerConn(conn, config)
+		}
+	}()
+	return int64(l.Addr().(*net.TCPAddr).Port), func() { l.Close() }
+}
+
+func TestWaitForSSH(t *testing.T) {
+	var newSigner = func() ssh.Signer {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(t, err)
+		signer, err := ssh.NewSignerFromKey(key)
+		assert.Nil(t, err)
+		return signer
+	}
+	var hostKey, staleKey = newSigner(), newSigner()
+	port, stop := startSSHServer(t, hostKey)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "inertia-known-hosts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var file = filepath.Join(dir, "known_hosts")
+	var host = knownhosts.Normalize(net.JoinHostPort("127.0.0.1", strconv.FormatInt(port, 10)))
+	var writeStaleKey = func() {
+		assert.Nil(t, ioutil.WriteFile(file,
+			[]byte(knownhosts.Line([]string{host}, staleKey.PublicKey())+"\n"), 0600))
+	}
+
+	// The key of the address's previous host should be replaced
+	writeStaleKey()
+	var opts = &cfg.SSHOptions{KnownHostsFile: file}
+	assert.Nil(t, waitForSSH(ioutil.Discard, "ec2-user", "127.0.0.1", port, opts,
+		time.Millisecond, time.Minute))
+	contents, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, knownhosts.Line([]string{host}, hostKey.PublicKey())+"\n", string(contents))
+
+	// Strictly checked keys should be kept, and mismatches not retried
+	writeStaleKey()
+	opts = &cfg.SSHOptions{
+		KnownHostsFile:  file,
+		HostKeyChecking: cfg.HostKeyCheckingStrict,
+		Retries:         5,
+	}
+	err = waitForSSH(ioutil.Discard, "ec2-user", "127.0.0.1", port, opts,
+		time.Millisecond, time.Minute)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to verify host key")
+	}
+}
+
+func TestWaitForSSH_retries(t *testing.T) {
+	var attempts int
+	dialSSH = func(network, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+		attempts++
+		return nil, errors.New("connection reset by peer")
+	}
+	defer func() { dialSSH = ssh.Dial }()
+	dialTCP = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		conn, remote := net.Pipe()
+		remote.Close()
+		return conn, nil
+	}
+	defer func() { dialTCP = net.DialTimeout }()
+
+	var opts = &cfg.SSHOptions{HostKeyChecking: cfg.HostKeyCheckingOff, Retries: 2}
+	var err = waitForSSH(ioutil.Discard, "ec2-user", "ec2.amazonaws.com", 22, opts,
+		time.Millisecond, time.Minute)
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestEC2Provisioner_cleanUp(t *testing.T) {
+	var actions []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		var action = r.Form.Get("Action")
+		actions = append(actions, action)
+
+		// Failing to remove one resource should not stop the others from
+		// being removed
+		if action == "DeleteSecurityGroup" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<Response><Errors><Error><Code>DependencyViolation</Code>`+
+				`<Message>in use</Message></Error></Errors><RequestID>1</RequestID></Response>`)
+			return
+		}
+		fmt.Fprintf(w, "<%sResponse></%sResponse>", action, action)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "inertia-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var keyPath = filepath.Join(dir, "inertia-key")
+	assert.Nil(t, ioutil.WriteFile(keyPath, []byte("key"), 0600))
+
+	prov, _ := NewEC2Provisioner("bob", "id", "key")
+	assert.Nil(t, prov.WithEndpoint(ts.URL))
+	assert.Nil(t, prov.WithRegion("us-east-1"))
+	prov.cleanUp(createdResources{
+		region:        "us-east-1",
+		spotRequestID: "sir-1234",
+		groupID:       "sg-1234",
+		keyName:       "inertia-key",
+		keyPath:       keyPath,
+	})
+	assert.Equal(t, []string{"CancelSpotInstanceRequests", "DeleteSecurityGroup", "DeleteKeyPair"},
+		actions)
+	_, err = os.Stat(keyPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// Nothing is requested if nothing was created
+	actions = nil
+	prov.cleanUp(createdResources{region: "us-east-1"})
+	assert.Empty(t, actions)
+}
+
+func TestGenerateWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		fallback string
+		want     string
+		wantErr  bool
+	}{
+		{"generated", 0, "", "random", false},
+		{"generated on second attempt", 1, "", "random", false},
+		{"fallback unused", 1, "configured", "random", false},
+		{"failed", 2, "", "", true},
+		{"fallback", 2, "configured", "configured", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			generateRandomString = func() (string, error) {
+				if calls++; calls <= tt.failures {
+					return "", errors.New("no entropy")
+				}
+				return "random", nil
+			}
+			defer func() { generateRandomString = common.GenerateRandomString }()
+
+			got, err := generateWebhookSecret(tt.fallback)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
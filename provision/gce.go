@@ -0,0 +1,555 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/local"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+)
+
+const (
+	// gceNetwork is the network instances are created in
+	gceNetwork = "global/networks/default"
+
+	// gceOperationInterval is how often the status of operations is checked
+	gceOperationInterval = 2 * time.Second
+
+	// maxGCENameLength is the maximum length of the names of GCE resources,
+	// and of the values of labels
+	maxGCENameLength = 63
+)
+
+// gceImageProjects are the projects with public images listed by
+// ListImageOptions
+var gceImageProjects = []string{"cos-cloud", "debian-cloud", "ubuntu-os-cloud"}
+
+// GCEProvisioner creates Google Compute Engine instances
+type GCEProvisioner struct {
+	out     io.Writer
+	user    string
+	project string
+	service *compute.Service
+}
+
+// NewGCEProvisioner creates a client to interact with Google Compute Engine
+// using the given service account key, in JSON format. Instances are created in
+// the project the service account belongs to.
+func NewGCEProvisioner(user string, key []byte, out ...io.Writer) (*GCEProvisioner, error) {
+	prov := &GCEProvisioner{}
+	return prov, prov.init(user, key, out)
+}
+
+// NewGCEProvisionerFromFile creates a client to interact with Google Compute
+// Engine using the service account key, in JSON format, at the given path
+func NewGCEProvisionerFromFile(user, path string, out ...io.Writer) (*GCEProvisioner, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %s", err.Error())
+	}
+	return NewGCEProvisioner(user, key, out...)
+}
+
+// GetUser returns the user instances are accessed as
+func (p *GCEProvisioner) GetUser() string { return p.user }
+
+// GetProject returns the project instances are created in
+func (p *GCEProvisioner) GetProject() string { return p.project }
+
+// ListImageOptions lists the public images available to instances in the
+// given zone, such as "projects/ubuntu-os-cloud/global/images/ubuntu-1804-bionic-v20190212".
+// Deprecated images are not listed.
+func (p *GCEProvisioner) ListImageOptions(zone string) ([]string, error) {
+	if _, err := p.service.Zones.Get(p.project, zone).Do(); err != nil {
+		return nil, fmt.Errorf("failed to find zone %s: %s", zone, err.Error())
+	}
+
+	var images = []*compute.Image{}
+	for _, project := range gceImageProjects {
+		if err := p.service.Images.List(project).Pages(context.Background(),
+			func(page *compute.ImageList) error {
+				images = append(images, page.Items...)
+				return nil
+			}); err != nil {
+			return nil, fmt.Errorf("failed to list images in project %s: %s", project, err.Error())
+		}
+	}
+	return gceImageOptions(images), nil
+}
+
+// gceImageOptions returns the sorted references to the given images that are
+// not deprecated
+func gceImageOptions(images []*compute.Image) []string {
+	var options = []string{}
+	for _, image := range images {
+		if image.Deprecated != nil && image.Deprecated.State != "" {
+			continue
+		}
+		options = append(options, gceImageReference(image.SelfLink))
+	}
+	sort.Strings(options)
+	return options
+}
+
+// gceImageReference returns the part of an image's URL that can be used to
+// reference it when creating instances, starting at "projects/"
+func gceImageReference(selfLink string) string {
+	if i := strings.Index(selfLink, "projects/"); i >= 0 {
+		return selfLink[i:]
+	}
+	return selfLink
+}
+
+// GCECreateInstanceOptions defines parameters with which to create a GCE
+// instance
+type GCECreateInstanceOptions struct {
+	Name        string
+	ProjectName string
+	Ports       []int64
+	DaemonPort  int64
+
+	// PortRanges are exposed in addition to Ports
+	PortRanges []PortRange
+
+	// ImageID is the image the instance boots from, such as
+	// "projects/ubuntu-os-cloud/global/images/family/ubuntu-1804-lts".
+	// Docker is installed when the instance first boots if the image does not
+	// include it.
+	ImageID     string
+	MachineType string
+	Zone        string
+
+	// DiskSizeGB is the size of the instance's boot disk in GB, which must be
+	// at least the size of the image. The size of the image is used if it is
+	// unset.
+	DiskSizeGB int64
+
+	// KeyDirectory is the directory the generated key is saved in, created if
+	// it does not exist. Keys are saved in ~/.ssh if it is unset.
+	KeyDirectory string
+
+	// FallbackWebhookSecret is used as the remote's webhook secret if a random
+	// secret cannot be generated. Provisioning fails instead if it is unset.
+	FallbackWebhookSecret string
+
+	// SSH configures SSH connections to the instance, including the check
+	// that its SSH server is ready - defaults are used if it is not set
+	SSH *cfg.SSHOptions
+
+	// Timeout is how long the instance is given to start and accept SSH
+	// connections, 10 minutes if unset. If it elapses, the instance and the
+	// resources created for it are removed.
+	Timeout time.Duration
+}
+
+// CreateInstance creates a GCE instance with given properties
+func (p *GCEProvisioner) CreateInstance(opts GCECreateInstanceOptions) (*cfg.RemoteVPS, error) {
+	var created = gceCreatedResources{zone: opts.Zone}
+	remote, err := p.createInstance(opts, &created)
+	if err != nil {
+		p.cleanUp(created)
+	}
+	return remote, err
+}
+
+func (p *GCEProvisioner) createInstance(opts GCECreateInstanceOptions,
+	created *gceCreatedResources) (*cfg.RemoteVPS, error) {
+	// Check requested options before creating any resources
+	if opts.Zone == "" || opts.ImageID == "" || opts.MachineType == "" {
+		return nil, errors.New("a zone, image, and machine type are required to create instances")
+	}
+	if opts.DiskSizeGB < 0 {
+		return nil, errors.New("disk size cannot be negative")
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultCreateTimeout
+	} else if opts.Timeout < 0 {
+		return nil, errors.New("timeout cannot be negative")
+	}
+	webhookSecret, err := generateWebhookSecret(opts.FallbackWebhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	var ports = []PortRange{
+		{From: defaultSSHPort, To: defaultSSHPort, Protocol: "tcp"},
+		{From: opts.DaemonPort, To: opts.DaemonPort, Protocol: "tcp"},
+	}
+	for _, port := range opts.Ports {
+		ports = append(ports, PortRange{From: port, To: port, Protocol: "tcp"})
+	}
+	for _, r := range opts.PortRanges {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		ports = append(ports, r)
+	}
+
+	// The instance and its firewall share a name, and the firewall applies to
+	// instances tagged with it. The timeout is shared by the operations that
+	// create them and the wait for SSH connections.
+	var name = resourceName("inertia", opts.ProjectName, opts.Name,
+		strconv.FormatInt(time.Now().UnixNano(), 10))
+	var deadline = time.Now().Add(opts.Timeout)
+
+	// Generate authentication
+	var keyName = generatedKeyName(opts.ProjectName, opts.Name, p.user)
+	fmt.Fprintf(p.out, "Generating key %s...\n", keyName)
+	privateKey, publicKey, err := generateKeyPair(keyName)
+	if err != nil {
+		return nil, err
+	}
+	var keyDirectory = opts.KeyDirectory
+	if keyDirectory == "" {
+		keyDirectory = local.GetKeyDirectory()
+	}
+	var keyPath = filepath.Join(keyDirectory, keyName)
+	fmt.Fprintf(p.out, "Saving key to %s...\n", keyPath)
+	if err = local.SaveKey(privateKey, keyPath); err != nil {
+		return nil, err
+	}
+	created.keyPath = keyPath
+
+	// Create firewall rules for network configuration
+	fmt.Fprintf(p.out, "Creating firewall %s...\n", name)
+	op, err := p.service.Firewalls.Insert(p.project, &compute.Firewall{
+		Name:         name,
+		Description:  fmt.Sprintf("Rules for project %s on %s", opts.ProjectName, opts.Name),
+		Network:      gceNetwork,
+		Direction:    "INGRESS",
+		SourceRanges: []string{"0.0.0.0/0"},
+		TargetTags:   []string{name},
+		Allowed:      firewallRules(ports),
+	}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create firewall: %s", err.Error())
+	}
+	created.firewall = name
+	if err = p.waitForOperation(op, time.Until(deadline)); err != nil {
+		return nil, fmt.Errorf("failed to create firewall: %s", err.Error())
+	}
+
+	// Start up instance
+	fmt.Fprintf(p.out, "Creating instance %s...\n", name)
+	var sshKeys = p.user + ":" + publicKey
+	var startupScript = dockerInstallScript
+	op, err = p.service.Instances.Insert(p.project, opts.Zone, &compute.Instance{
+		Name:        name,
+		Description: fmt.Sprintf("Inertia remote %s for project %s", opts.Name, opts.ProjectName),
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", opts.Zone, opts.MachineType),
+		Labels: map[string]string{
+			"purpose": "inertia",
+			"project": gceLabelValue(opts.ProjectName),
+		},
+
+		// Storage options
+		Disks: []*compute.AttachedDisk{{
+			Boot:       true,
+			AutoDelete: true,
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				SourceImage: opts.ImageID,
+				DiskSizeGb:  opts.DiskSizeGB,
+			},
+		}},
+
+		// Network options
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			Network: gceNetwork,
+			AccessConfigs: []*compute.AccessConfig{{
+				Name: "External NAT",
+				Type: "ONE_TO_ONE_NAT",
+			}},
+		}},
+		Tags: &compute.Tags{Items: []string{name}},
+
+		// Security options and startup script
+		Metadata: &compute.Metadata{Items: []*compute.MetadataItems{
+			{Key: "ssh-keys", Value: &sshKeys},
+			{Key: "startup-script", Value: &startupScript},
+		}},
+	}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance: %s", err.Error())
+	}
+	created.instance = name
+	fmt.Fprintln(p.out, "Checking status of requested instance...")
+	if err = p.waitForOperation(op, time.Until(deadline)); err != nil {
+		return nil, fmt.Errorf("failed to create instance: %s", err.Error())
+	}
+
+	// Check instance validity
+	instance, err := p.service.Instances.Get(p.project, opts.Zone, name).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find instance %s: %s", name, err.Error())
+	}
+	var address = gceExternalIP(instance)
+	if address == "" {
+		return nil, fmt.Errorf("unable to find external IP address for instance %s", name)
+	}
+
+	// Poll for SSH server to accept connections
+	fmt.Fprintln(p.out, "Waiting for ports to open...")
+	if err = waitForSSH(p.out, p.user, address, defaultSSHPort, opts.SSH, 3*time.Second,
+		time.Until(deadline)); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(p.out, "Webhook secret: '%s'\n", webhookSecret)
+
+	// Return remote configuration
+	return &cfg.RemoteVPS{
+		Name:    opts.Name,
+		IP:      address,
+		User:    p.user,
+		PEM:     keyPath,
+		SSHPort: strconv.Itoa(defaultSSHPort),
+		SSH:     opts.SSH,
+		Daemon: &cfg.DaemonConfig{
+			Port:          strconv.FormatInt(opts.DaemonPort, 10),
+			WebHookSecret: webhookSecret,
+		},
+		Resources: &cfg.ProvisionedResources{
+			Provider: "gce",
+			Region:   opts.Zone,
+			Account:  p.project,
+
+			InstanceID:   name,
+			InstanceARN:  instance.SelfLink,
+			ImageID:      opts.ImageID,
+			InstanceType: opts.MachineType,
+
+			SecurityGroupID: name,
+			KeyPairName:     keyName,
+		},
+	}, nil
+}
+
+// DestroyInstance deletes the instance with the given name in zone and the
+// firewall created for it, and removes its generated private key at keyPath
+// if it is set
+func (p *GCEProvisioner) DestroyInstance(zone, instance, firewall, keyPath string) error {
+	if err := p.deleteInstance(zone, instance); err != nil {
+		return err
+	}
+	if firewall != "" {
+		if err := p.deleteFirewall(firewall); err != nil {
+			return err
+		}
+	}
+	if keyPath == "" {
+		return nil
+	}
+	fmt.Fprintf(p.out, "Removing key %s...\n", keyPath)
+	return local.RemoveKey(keyPath)
+}
+
+// gceCreatedResources are the resources created while provisioning an
+// instance, which are removed if provisioning fails part way through
+type gceCreatedResources struct {
+	zone     string
+	instance string
+	firewall string
+	keyPath  string
+}
+
+// cleanUp removes the given resources, left behind by a failed attempt to
+// provision an instance. Failures to remove resources are reported, but do not
+// stop the remaining resources from being removed.
+func (p *GCEProvisioner) cleanUp(created gceCreatedResources) {
+	if created.instance == "" && created.firewall == "" && created.keyPath == "" {
+		return
+	}
+	fmt.Fprintln(p.out, "Removing resources created for the instance...")
+	if created.instance != "" {
+		if err := p.deleteInstance(created.zone, created.instance); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete instance %s: %s\n", created.instance, err.Error())
+		}
+	}
+	if created.firewall != "" {
+		if err := p.deleteFirewall(created.firewall); err != nil {
+			fmt.Fprintf(p.out, "Failed to delete firewall %s: %s\n", created.firewall, err.Error())
+		}
+	}
+	if created.keyPath != "" {
+		if err := local.RemoveKey(created.keyPath); err != nil {
+			fmt.Fprintf(p.out, "Failed to remove key %s: %s\n", created.keyPath, err.Error())
+		}
+	}
+}
+
+// deleteInstance deletes the instance with the given name in zone, and waits
+// for it to be deleted along with its boot disk
+func (p *GCEProvisioner) deleteInstance(zone, instance string) error {
+	fmt.Fprintf(p.out, "Deleting instance %s...\n", instance)
+	op, err := p.service.Instances.Delete(p.project, zone, instance).Do()
+	if err != nil {
+		return err
+	}
+	return p.waitForOperation(op, defaultCreateTimeout)
+}
+
+// deleteFirewall deletes the firewall with the given name
+func (p *GCEProvisioner) deleteFirewall(firewall string) error {
+	fmt.Fprintf(p.out, "Deleting firewall %s...\n", firewall)
+	op, err := p.service.Firewalls.Delete(p.project, firewall).Do()
+	if err != nil {
+		return err
+	}
+	return p.waitForOperation(op, defaultCreateTimeout)
+}
+
+// waitForOperation blocks until the given zonal or global operation is done,
+// and returns the errors it encountered, if any. An error is returned if it
+// is not done within timeout.
+func (p *GCEProvisioner) waitForOperation(op *compute.Operation, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	var err error
+	for op.Status != "DONE" {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for operation %s",
+				timeout.Round(time.Second), op.Name)
+		}
+		time.Sleep(gceOperationInterval)
+		if op.Zone != "" {
+			op, err = p.service.ZoneOperations.Get(p.project, lastSegment(op.Zone), op.Name).Do()
+		} else {
+			op, err = p.service.GlobalOperations.Get(p.project, op.Name).Do()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return operationError(op)
+}
+
+// operationError returns the errors encountered by the given operation, or
+// nil if it succeeded
+func operationError(op *compute.Operation) error {
+	if op.Error == nil || len(op.Error.Errors) == 0 {
+		return nil
+	}
+	var messages = make([]string, len(op.Error.Errors))
+	for i, e := range op.Error.Errors {
+		messages[i] = fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
+
+// firewallRules creates firewall rules that allow traffic to the given ports,
+// with a rule for each protocol
+func firewallRules(ports []PortRange) []*compute.FirewallAllowed {
+	var (
+		protocols = []string{}
+		byProto   = map[string][]string{}
+	)
+	for _, r := range ports {
+		var protocol = r.protocol()
+		if _, ok := byProto[protocol]; !ok {
+			protocols = append(protocols, protocol)
+			byProto[protocol] = nil
+		}
+		if r.isICMP() {
+			continue
+		}
+		var spec = strconv.FormatInt(r.From, 10)
+		if r.To != r.From {
+			spec = fmt.Sprintf("%d-%d", r.From, r.To)
+		}
+		byProto[protocol] = append(byProto[protocol], spec)
+	}
+	var rules = make([]*compute.FirewallAllowed, len(protocols))
+	for i, protocol := range protocols {
+		rules[i] = &compute.FirewallAllowed{IPProtocol: protocol, Ports: byProto[protocol]}
+	}
+	return rules
+}
+
+// gceExternalIP returns the external IP address of the given instance, or an
+// empty string if it does not have one
+func gceExternalIP(instance *compute.Instance) string {
+	for _, iface := range instance.NetworkInterfaces {
+		for _, config := range iface.AccessConfigs {
+			if config.NatIP != "" {
+				return config.NatIP
+			}
+		}
+	}
+	return ""
+}
+
+// resourceName joins the given parts into a valid name for GCE resources and
+// DigitalOcean droplets, which must start with a lowercase letter, and only
+// contain lowercase letters, digits, and hyphens. The end of the name is kept if it is too long, since it
+// is usually the most specific part.
+func resourceName(parts ...string) string {
+	var name = []rune(strings.ToLower(strings.Join(parts, "-")))
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			name[i] = '-'
+		}
+	}
+	var trimmed = strings.Trim(string(name), "-")
+	if trimmed == "" || trimmed[0] < 'a' || trimmed[0] > 'z' {
+		trimmed = "i-" + trimmed
+	}
+	if len(trimmed) > maxGCENameLength {
+		trimmed = "i-" + strings.TrimLeft(trimmed[len(trimmed)-maxGCENameLength+2:], "-")
+	}
+	return strings.TrimRight(trimmed, "-")
+}
+
+// gceLabelValue converts value into a valid GCE label value, which may only
+// contain lowercase letters, digits, underscores, and hyphens
+func gceLabelValue(value string) string {
+	var label = []rune(strings.ToLower(value))
+	for i, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			label[i] = '-'
+		}
+	}
+	if len(label) > maxGCENameLength {
+		label = label[:maxGCENameLength]
+	}
+	return string(label)
+}
+
+// lastSegment returns the last segment of the given URL, such as the name of
+// the zone in "https://www.googleapis.com/compute/v1/projects/p/zones/us-west1-a"
+func lastSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+func (p *GCEProvisioner) init(user string, key []byte, out []io.Writer) error {
+	if len(out) > 0 {
+		p.out = out[0]
+	} else {
+		p.out = common.DevNull{}
+	}
+	p.user = user
+
+	// Set up credentials from the service account key
+	var ctx = context.Background()
+	creds, err := google.CredentialsFromJSON(ctx, key, compute.ComputeScope)
+	if err != nil {
+		return fmt.Errorf("invalid service account key: %s", err.Error())
+	}
+	if creds.ProjectID == "" {
+		return errors.New("service account key does not specify a project")
+	}
+	p.project = creds.ProjectID
+
+	// Set up GCE client
+	p.service, err = compute.New(oauth2.NewClient(ctx, creds.TokenSource))
+	return err
+}
@@ -0,0 +1,94 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestNewGCEProvisioner_invalidKey(t *testing.T) {
+	_, err := NewGCEProvisioner("ubuntu", []byte("not a key"))
+	assert.NotNil(t, err)
+	_, err = NewGCEProvisioner("ubuntu", []byte(`{"type": "service_account"}`))
+	assert.EqualError(t, err, "service account key does not specify a project")
+}
+
+func TestGCEImageOptions(t *testing.T) {
+	var base = "https://www.googleapis.com/compute/v1/"
+	assert.Equal(t, []string{}, gceImageOptions(nil))
+	assert.Equal(t, []string{
+		"projects/debian-cloud/global/images/debian-9-stretch-v20190124",
+		"projects/ubuntu-os-cloud/global/images/ubuntu-1804-bionic-v20190212",
+	}, gceImageOptions([]*compute.Image{
+		{SelfLink: base + "projects/ubuntu-os-cloud/global/images/ubuntu-1804-bionic-v20190212"},
+		{
+			SelfLink:   base + "projects/ubuntu-os-cloud/global/images/ubuntu-1804-bionic-v20180522",
+			Deprecated: &compute.DeprecationStatus{State: "DEPRECATED"},
+		},
+		{SelfLink: base + "projects/debian-cloud/global/images/debian-9-stretch-v20190124"},
+	}))
+}
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []string
+		want  string
+	}{
+		{"simple", []string{"inertia", "project", "dev", "1"}, "inertia-project-dev-1"},
+		{"invalid characters", []string{"inertia", "My_Project", "dev.server", "1"},
+			"inertia-my-project-dev-server-1"},
+		{"starts with digit", []string{"1", "dev"}, "i-1-dev"},
+		{"too long", []string{"inertia", strings.Repeat("p", 70), "1550000000000000000"},
+			"i-" + strings.Repeat("p", 41) + "-1550000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = resourceName(tt.parts...)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, len(got) <= maxGCENameLength)
+		})
+	}
+}
+
+func TestGCELabelValue(t *testing.T) {
+	assert.Equal(t, "my_project-1", gceLabelValue("My_Project.1"))
+	assert.Equal(t, strings.Repeat("p", maxGCENameLength), gceLabelValue(strings.Repeat("p", 70)))
+}
+
+func TestFirewallRules(t *testing.T) {
+	assert.Equal(t, []*compute.FirewallAllowed{
+		{IPProtocol: "tcp", Ports: []string{"22", "4303", "8080-8090"}},
+		{IPProtocol: "udp", Ports: []string{"10000-20000"}},
+		{IPProtocol: "icmp"},
+	}, firewallRules([]PortRange{
+		{From: 22, To: 22, Protocol: "tcp"},
+		{From: 4303, To: 4303},
+		{From: 10000, To: 20000, Protocol: "udp"},
+		{Protocol: "icmp"},
+		{From: 8080, To: 8090, Protocol: "tcp"},
+	}))
+}
+
+func TestOperationError(t *testing.T) {
+	assert.Nil(t, operationError(&compute.Operation{}))
+	assert.Nil(t, operationError(&compute.Operation{Error: &compute.OperationError{}}))
+	assert.EqualError(t, operationError(&compute.Operation{Error: &compute.OperationError{
+		Errors: []*compute.OperationErrorErrors{
+			{Code: "QUOTA_EXCEEDED", Message: "Quota 'CPUS' exceeded"},
+			{Code: "RESOURCE_NOT_FOUND", Message: "image not found"},
+		},
+	}}), "QUOTA_EXCEEDED: Quota 'CPUS' exceeded, RESOURCE_NOT_FOUND: image not found")
+}
+
+func TestGCEExternalIP(t *testing.T) {
+	assert.Equal(t, "", gceExternalIP(&compute.Instance{}))
+	assert.Equal(t, "203.0.113.1", gceExternalIP(&compute.Instance{
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			NetworkIP:     "10.0.0.2",
+			AccessConfigs: []*compute.AccessConfig{{}, {NatIP: "203.0.113.1"}},
+		}},
+	}))
+}
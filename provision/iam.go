@@ -0,0 +1,52 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// instanceProfileName returns the name of the instance profile identified by
+// profile, which is either its name or its ARN, such as
+// "arn:aws:iam::123456789012:instance-profile/path/name"
+func instanceProfileName(profile string) string {
+	if !strings.HasPrefix(profile, "arn:") {
+		return profile
+	}
+	return profile[strings.LastIndex(profile, "/")+1:]
+}
+
+// resolveInstanceProfile checks that the instance profile identified by
+// profile, either its name or its ARN, exists and has a role for instances to
+// assume, and returns a specification referencing it for launching instances
+// in region
+func (p *EC2Provisioner) resolveInstanceProfile(region,
+	profile string) (*ec2.IamInstanceProfileSpecification, error) {
+	var client = iam.New(p.session, &aws.Config{
+		Credentials: p.creds,
+		Region:      aws.String(region),
+	})
+	result, err := client.GetInstanceProfile(&iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(instanceProfileName(profile)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == iam.ErrCodeNoSuchEntityException {
+			return nil, fmt.Errorf("instance profile %s does not exist", profile)
+		}
+		return nil, fmt.Errorf("failed to find instance profile %s: %s", profile, err.Error())
+	}
+
+	var found = result.InstanceProfile
+	if strings.HasPrefix(profile, "arn:") && aws.StringValue(found.Arn) != profile {
+		return nil, fmt.Errorf("instance profile %s does not exist - found %s instead",
+			profile, aws.StringValue(found.Arn))
+	}
+	if len(found.Roles) == 0 {
+		return nil, fmt.Errorf("instance profile %s has no role for instances to assume", profile)
+	}
+	return &ec2.IamInstanceProfileSpecification{Arn: found.Arn}, nil
+}
@@ -0,0 +1,24 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceProfileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    string
+	}{
+		{"name", "inertia-s3", "inertia-s3"},
+		{"arn", "arn:aws:iam::123456789012:instance-profile/inertia-s3", "inertia-s3"},
+		{"arn with path", "arn:aws:iam::123456789012:instance-profile/team/inertia-s3", "inertia-s3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, instanceProfileName(tt.profile))
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// describeInstanceTypesLimit is the most instance types that can be described
+// by a single DescribeInstanceTypes request
+const describeInstanceTypesLimit = 100
+
+// InstanceTypeFilter selects the instance types listed by ListInstanceTypes.
+// Zero values do not restrict the listed types.
+type InstanceTypeFilter struct {
+	MinVCPUs int64
+	MaxVCPUs int64
+
+	MinMemoryGiB float64
+	MaxMemoryGiB float64
+}
+
+func (f InstanceTypeFilter) matches(t *ec2.InstanceTypeInfo) bool {
+	var vcpus, memoryMiB int64
+	if t.VCpuInfo != nil {
+		vcpus = aws.Int64Value(t.VCpuInfo.DefaultVCpus)
+	}
+	if t.MemoryInfo != nil {
+		memoryMiB = aws.Int64Value(t.MemoryInfo.SizeInMiB)
+	}
+	var memoryGiB = float64(memoryMiB) / 1024
+	return (f.MinVCPUs == 0 || vcpus >= f.MinVCPUs) &&
+		(f.MaxVCPUs == 0 || vcpus <= f.MaxVCPUs) &&
+		(f.MinMemoryGiB == 0 || memoryGiB >= f.MinMemoryGiB) &&
+		(f.MaxMemoryGiB == 0 || memoryGiB <= f.MaxMemoryGiB)
+}
+
+// ListInstanceTypes lists the names of the instance types offered in region
+// that match filter, sorted by name.
+func (p *EC2Provisioner) ListInstanceTypes(region string, filter InstanceTypeFilter) ([]string, error) {
+	if filter.MaxVCPUs != 0 && filter.MaxVCPUs < filter.MinVCPUs {
+		return nil, errors.New("maximum vCPU count must not be less than the minimum")
+	}
+	if filter.MaxMemoryGiB != 0 && filter.MaxMemoryGiB < filter.MinMemoryGiB {
+		return nil, errors.New("maximum memory must not be less than the minimum")
+	}
+	if _, found := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); !found {
+		return nil, fmt.Errorf("unknown region %s", region)
+	}
+	if err := p.WithRegion(region); err != nil {
+		return nil, err
+	}
+
+	offered, err := p.offeredInstanceTypes(region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instance types: %s", err.Error())
+	}
+	var types []*ec2.InstanceTypeInfo
+	for start := 0; start < len(offered); start += describeInstanceTypesLimit {
+		var end = start + describeInstanceTypesLimit
+		if end > len(offered) {
+			end = len(offered)
+		}
+		var described []*ec2.InstanceTypeInfo
+		if err := p.retry("DescribeInstanceTypes", func() error {
+			described = nil
+			return p.client.DescribeInstanceTypesPages(&ec2.DescribeInstanceTypesInput{
+				InstanceTypes: aws.StringSlice(offered[start:end]),
+			}, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
+				described = append(described, page.InstanceTypes...)
+				return true
+			})
+		}); err != nil {
+			return nil, fmt.Errorf("failed to describe instance types: %s", err.Error())
+		}
+		types = append(types, described...)
+	}
+
+	return instanceTypeNames(types, filter), nil
+}
+
+// offeredInstanceTypes lists the names of the instance types offered in the
+// given region, which must be the provisioner's current region. If names are
+// given, only those of them that are offered are listed.
+func (p *EC2Provisioner) offeredInstanceTypes(region string, names ...string) ([]string, error) {
+	var filters = []*ec2.Filter{{
+		Name:   aws.String("location"),
+		Values: aws.StringSlice([]string{region}),
+	}}
+	if len(names) > 0 {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String("instance-type"),
+			Values: aws.StringSlice(names),
+		})
+	}
+
+	var offered []string
+	err := p.retry("DescribeInstanceTypeOfferings", func() error {
+		offered = nil
+		return p.client.DescribeInstanceTypeOfferingsPages(&ec2.DescribeInstanceTypeOfferingsInput{
+			LocationType: aws.String(ec2.LocationTypeRegion),
+			Filters:      filters,
+		}, func(page *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+			for _, offering := range page.InstanceTypeOfferings {
+				offered = append(offered, aws.StringValue(offering.InstanceType))
+			}
+			return true
+		})
+	})
+	return offered, err
+}
+
+// checkInstanceType returns an error if the given instance type is not offered
+// in region, which must be the provisioner's current region, so that typos are
+// caught before any resources are created
+func (p *EC2Provisioner) checkInstanceType(region, instanceType string) error {
+	offered, err := p.offeredInstanceTypes(region, instanceType)
+	if err != nil {
+		return fmt.Errorf("failed to check instance type %s: %s", instanceType, err.Error())
+	}
+	if len(offered) == 0 {
+		return fmt.Errorf("instance type '%s' is not offered in region %s", instanceType, region)
+	}
+	return nil
+}
+
+// instanceTypeNames returns the sorted, unique names of the given instance
+// types that match filter
+func instanceTypeNames(types []*ec2.InstanceTypeInfo, filter InstanceTypeFilter) []string {
+	var (
+		seen  = map[string]bool{}
+		names = []string{}
+	)
+	for _, t := range types {
+		var name = aws.StringValue(t.InstanceType)
+		if name == "" || seen[name] || !filter.matches(t) {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,122 @@
+package provision
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceTypeNames(t *testing.T) {
+	var instanceType = func(name string, vcpus, memoryMiB int64) *ec2.InstanceTypeInfo {
+		return &ec2.InstanceTypeInfo{
+			InstanceType: aws.String(name),
+			VCpuInfo:     &ec2.VCpuInfo{DefaultVCpus: aws.Int64(vcpus)},
+			MemoryInfo:   &ec2.MemoryInfo{SizeInMiB: aws.Int64(memoryMiB)},
+		}
+	}
+	var types = []*ec2.InstanceTypeInfo{
+		instanceType("t2.micro", 1, 1024),
+		instanceType("t2.nano", 1, 512),
+		instanceType("m5.large", 2, 8192),
+		instanceType("t2.micro", 1, 1024),
+		instanceType("x1.32xlarge", 128, 1998848),
+		instanceType("", 1, 1024),
+		{InstanceType: aws.String("t2.unknown")},
+		{},
+	}
+
+	tests := []struct {
+		name   string
+		filter InstanceTypeFilter
+		want   []string
+	}{
+		{"no filter", InstanceTypeFilter{},
+			[]string{"m5.large", "t2.micro", "t2.nano", "t2.unknown", "x1.32xlarge"}},
+		{"min vcpus", InstanceTypeFilter{MinVCPUs: 2},
+			[]string{"m5.large", "x1.32xlarge"}},
+		{"max vcpus", InstanceTypeFilter{MaxVCPUs: 2},
+			[]string{"m5.large", "t2.micro", "t2.nano", "t2.unknown"}},
+		{"min memory", InstanceTypeFilter{MinMemoryGiB: 1},
+			[]string{"m5.large", "t2.micro", "x1.32xlarge"}},
+		{"max memory", InstanceTypeFilter{MaxMemoryGiB: 1},
+			[]string{"t2.micro", "t2.nano", "t2.unknown"}},
+		{"vcpus and memory", InstanceTypeFilter{MaxVCPUs: 1, MinMemoryGiB: 1},
+			[]string{"t2.micro"}},
+		{"no matches", InstanceTypeFilter{MinVCPUs: 256},
+			[]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, instanceTypeNames(types, tt.filter))
+		})
+	}
+}
+
+func TestListInstanceTypes_invalidFilter(t *testing.T) {
+	var p = &EC2Provisioner{}
+	_, err := p.ListInstanceTypes("us-east-1", InstanceTypeFilter{MinVCPUs: 4, MaxVCPUs: 2})
+	assert.EqualError(t, err, "maximum vCPU count must not be less than the minimum")
+	_, err = p.ListInstanceTypes("us-east-1", InstanceTypeFilter{MinMemoryGiB: 4, MaxMemoryGiB: 2})
+	assert.EqualError(t, err, "maximum memory must not be less than the minimum")
+	_, err = p.ListInstanceTypes("mars-north-1", InstanceTypeFilter{})
+	assert.EqualError(t, err, "unknown region mars-north-1")
+}
+
+func TestEC2Provisioner_checkInstanceType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "DescribeInstanceTypeOfferings", r.Form.Get("Action"))
+		assert.Equal(t, "us-gov-west-1", r.Form.Get("Filter.1.Value.1"))
+		fmt.Fprint(w, "<DescribeInstanceTypeOfferingsResponse><instanceTypeOfferingSet>")
+		if r.Form.Get("Filter.2.Value.1") == "t2.micro" {
+			fmt.Fprint(w, "<item><instanceType>t2.micro</instanceType></item>")
+		}
+		fmt.Fprint(w, "</instanceTypeOfferingSet></DescribeInstanceTypeOfferingsResponse>")
+	}))
+	defer ts.Close()
+
+	prov, _ := NewEC2Provisioner("bob", "id", "key")
+	assert.Nil(t, prov.WithEndpoint(ts.URL))
+	assert.Nil(t, prov.WithRegion("us-gov-west-1"))
+	assert.Nil(t, prov.checkInstanceType("us-gov-west-1", "t2.micro"))
+	assert.EqualError(t, prov.checkInstanceType("us-gov-west-1", "t2.mirco"),
+		"instance type 't2.mirco' is not offered in region us-gov-west-1")
+}
+
+func TestEC2Provisioner_ListInstanceTypes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		switch r.Form.Get("Action") {
+		case "DescribeInstanceTypeOfferings":
+			fmt.Fprint(w, "<DescribeInstanceTypeOfferingsResponse><instanceTypeOfferingSet>"+
+				"<item><instanceType>t2.micro</instanceType></item>"+
+				"<item><instanceType>m5.large</instanceType></item>"+
+				"</instanceTypeOfferingSet></DescribeInstanceTypeOfferingsResponse>")
+		case "DescribeInstanceTypes":
+			assert.Equal(t, "t2.micro", r.Form.Get("InstanceType.1"))
+			assert.Equal(t, "m5.large", r.Form.Get("InstanceType.2"))
+			fmt.Fprint(w, "<DescribeInstanceTypesResponse><instanceTypeSet>"+
+				"<item><instanceType>t2.micro</instanceType>"+
+				"<vCpuInfo><defaultVCpus>1</defaultVCpus></vCpuInfo>"+
+				"<memoryInfo><sizeInMiB>1024</sizeInMiB></memoryInfo></item>"+
+				"<item><instanceType>m5.large</instanceType>"+
+				"<vCpuInfo><defaultVCpus>2</defaultVCpus></vCpuInfo>"+
+				"<memoryInfo><sizeInMiB>8192</sizeInMiB></memoryInfo></item>"+
+				"</instanceTypeSet></DescribeInstanceTypesResponse>")
+		default:
+			t.Errorf("unexpected action %s", r.Form.Get("Action"))
+		}
+	}))
+	defer ts.Close()
+
+	prov, _ := NewEC2Provisioner("bob", "id", "key")
+	assert.Nil(t, prov.WithEndpoint(ts.URL))
+	types, err := prov.ListInstanceTypes("cn-north-1", InstanceTypeFilter{MinVCPUs: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"m5.large"}, types)
+}
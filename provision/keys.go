@@ -0,0 +1,43 @@
+package provision
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keyBits is the size of generated RSA keys, which matches the size of keys
+// generated by EC2
+const keyBits = 2048
+
+// generatedKeyName returns a name for a key generated for the instance with the
+// given name in project, which local.IsGeneratedKey recognizes
+func generatedKeyName(project, name, user string) string {
+	return fmt.Sprintf("%s_%s_%s_inertia_key_%d", project, name, user, time.Now().UnixNano())
+}
+
+// generateKeyPair generates an RSA key pair for SSH access to instances,
+// returning the PEM-encoded private key and the public key in authorized_keys
+// format, labelled with comment
+func generateKeyPair(comment string) (privateKey, publicKey string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %s", err.Error())
+	}
+	public, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate public key: %s", err.Error())
+	}
+	privateKey = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	publicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(public))) + " " + comment
+	return privateKey, publicKey, nil
+}
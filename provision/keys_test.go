@@ -0,0 +1,28 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/local"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGeneratedKeyName(t *testing.T) {
+	var name = generatedKeyName("project", "dev", "ubuntu")
+	assert.True(t, strings.HasPrefix(name, "project_dev_ubuntu_inertia_key_"))
+	assert.True(t, local.IsGeneratedKey(name))
+}
+
+func TestGenerateKeyPair(t *testing.T) {
+	privateKey, publicKey, err := generateKeyPair("project_dev_ubuntu_inertia_key_1")
+	assert.Nil(t, err)
+
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	assert.Nil(t, err)
+	public, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	assert.Nil(t, err)
+	assert.Equal(t, "project_dev_ubuntu_inertia_key_1", comment)
+	assert.Equal(t, signer.PublicKey().Marshal(), public.Marshal())
+}
@@ -0,0 +1,102 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// getSubnet returns the subnet with the given ID
+func (p *EC2Provisioner) getSubnet(subnetID string) (*ec2.Subnet, error) {
+	var result *ec2.DescribeSubnetsOutput
+	if err := p.retry("DescribeSubnets", func() (err error) {
+		result, err = p.client.DescribeSubnets(&ec2.DescribeSubnetsInput{
+			SubnetIds: []*string{aws.String(subnetID)},
+		})
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to find subnet %s: %s", subnetID, err.Error())
+	}
+	if len(result.Subnets) == 0 {
+		return nil, fmt.Errorf("subnet %s not found", subnetID)
+	}
+	return result.Subnets[0], nil
+}
+
+// getSecurityGroupID returns the ID of the security group with the given name
+// in the VPC with the given ID, or in any VPC if vpcID is nil
+func (p *EC2Provisioner) getSecurityGroupID(name string, vpcID *string) (string, error) {
+	var filters = []*ec2.Filter{{
+		Name:   aws.String("group-name"),
+		Values: []*string{aws.String(name)},
+	}}
+	if vpcID != nil {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String("vpc-id"),
+			Values: []*string{vpcID},
+		})
+	}
+	var result *ec2.DescribeSecurityGroupsOutput
+	if err := p.retry("DescribeSecurityGroups", func() (err error) {
+		result, err = p.client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+			Filters: filters,
+		})
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to find security group %s: %s", name, err.Error())
+	}
+	if len(result.SecurityGroups) == 0 {
+		return "", fmt.Errorf("security group %s not found", name)
+	}
+	return aws.StringValue(result.SecurityGroups[0].GroupId), nil
+}
+
+// subnetVPC returns the ID of the VPC the given subnet belongs to, or an error
+// if it does not belong to the VPC with the given ID. Any VPC is accepted if
+// vpcID is empty.
+func subnetVPC(subnet *ec2.Subnet, vpcID string) (string, error) {
+	var subnetVPCID = aws.StringValue(subnet.VpcId)
+	if vpcID != "" && subnetVPCID != vpcID {
+		return "", fmt.Errorf("subnet %s belongs to VPC %s, not VPC %s",
+			aws.StringValue(subnet.SubnetId), subnetVPCID, vpcID)
+	}
+	return subnetVPCID, nil
+}
+
+// instanceNetwork is the network configuration instances are launched with
+type instanceNetwork struct {
+	// subnetID and groupIDs are set if the instance's network interface is not
+	// configured explicitly
+	subnetID *string
+	groupIDs []*string
+
+	// interfaces configure the instance's network interface if a public IP
+	// address must be associated with it
+	interfaces []*ec2.InstanceNetworkInterfaceSpecification
+}
+
+// newInstanceNetwork configures instances to be launched into the subnet with
+// the given ID with the security group with the given ID. If subnetID is empty,
+// the default subnet is used. A public IP address is associated with instances
+// if associatePublicIP is set - otherwise, the subnet decides whether they get
+// one.
+func newInstanceNetwork(subnetID, groupID string, associatePublicIP bool) instanceNetwork {
+	var subnet *string
+	if subnetID != "" {
+		subnet = aws.String(subnetID)
+	}
+	if !associatePublicIP {
+		return instanceNetwork{subnetID: subnet, groupIDs: []*string{aws.String(groupID)}}
+	}
+
+	// The subnet and security groups of instances must be set on their
+	// network interface instead if the interface is configured
+	return instanceNetwork{interfaces: []*ec2.InstanceNetworkInterfaceSpecification{{
+		DeviceIndex:              aws.Int64(0),
+		SubnetId:                 subnet,
+		Groups:                   []*string{aws.String(groupID)},
+		AssociatePublicIpAddress: aws.Bool(true),
+		DeleteOnTermination:      aws.Bool(true),
+	}}}
+}
@@ -0,0 +1,56 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubnetVPC(t *testing.T) {
+	var subnet = &ec2.Subnet{SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-1")}
+	tests := []struct {
+		name    string
+		vpcID   string
+		want    string
+		wantErr bool
+	}{
+		{"inferred", "", "vpc-1", false},
+		{"matching", "vpc-1", "vpc-1", false},
+		{"mismatched", "vpc-2", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := subnetVPC(subnet, tt.vpcID)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewInstanceNetwork(t *testing.T) {
+	// Default subnet
+	var network = newInstanceNetwork("", "sg-1", false)
+	assert.Nil(t, network.subnetID)
+	assert.Equal(t, []*string{aws.String("sg-1")}, network.groupIDs)
+	assert.Nil(t, network.interfaces)
+
+	// Requested subnet
+	network = newInstanceNetwork("subnet-1", "sg-1", false)
+	assert.Equal(t, aws.String("subnet-1"), network.subnetID)
+	assert.Equal(t, []*string{aws.String("sg-1")}, network.groupIDs)
+
+	// Public IP addresses must be requested on the network interface
+	network = newInstanceNetwork("subnet-1", "sg-1", true)
+	assert.Nil(t, network.subnetID)
+	assert.Nil(t, network.groupIDs)
+	assert.Len(t, network.interfaces, 1)
+	assert.Equal(t, aws.String("subnet-1"), network.interfaces[0].SubnetId)
+	assert.Equal(t, []*string{aws.String("sg-1")}, network.interfaces[0].Groups)
+	assert.True(t, aws.BoolValue(network.interfaces[0].AssociatePublicIpAddress))
+}
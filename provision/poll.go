@@ -0,0 +1,202 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// instancePollInterval is how often the status of instances that are
+	// starting up is checked
+	instancePollInterval = 3 * time.Second
+
+	// maxInstancesPerPoll is the most instance IDs requested in a single
+	// DescribeInstances call
+	maxInstancesPerPoll = 1000
+
+	// Error code returned by AWS for instances that have just been launched,
+	// but are not visible to DescribeInstances yet
+	codeInstanceNotFound = "InvalidInstanceID.NotFound"
+)
+
+// instanceStatus is the outcome of waiting for an instance to start
+type instanceStatus struct {
+	instance *ec2.Instance
+	owner    string
+	err      error
+}
+
+// instancePoller waits for instances in a region to start. Instances that are
+// waited for concurrently, such as when provisioning many instances at once,
+// share one poll loop, which checks all of them with a single DescribeInstances
+// call at each interval.
+type instancePoller struct {
+	describe func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error)
+	interval time.Duration
+	out      io.Writer
+
+	mux     sync.Mutex
+	waiters map[string][]chan<- instanceStatus
+	polling bool
+}
+
+// newInstancePoller creates a poller that checks instance statuses with the
+// given describe function
+func newInstancePoller(
+	describe func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error),
+	interval time.Duration,
+	out io.Writer,
+) *instancePoller {
+	return &instancePoller{
+		describe: describe,
+		interval: interval,
+		out:      out,
+		waiters:  make(map[string][]chan<- instanceStatus),
+	}
+}
+
+// wait blocks until the instance with the given ID is running, and returns it
+// along with the ID of the account that owns it. An error is returned if the
+// instance is not running within timeout.
+func (p *instancePoller) wait(instanceID string,
+	timeout time.Duration) (*ec2.Instance, string, error) {
+	var result = make(chan instanceStatus, 1)
+	p.mux.Lock()
+	p.waiters[instanceID] = append(p.waiters[instanceID], result)
+	if !p.polling {
+		p.polling = true
+		go p.poll()
+	}
+	p.mux.Unlock()
+
+	select {
+	case status := <-result:
+		return status.instance, status.owner, status.err
+	case <-time.After(timeout):
+		p.cancel(instanceID, result)
+		return nil, "", fmt.Errorf("timed out after %s waiting for instance %s to start",
+			timeout, instanceID)
+	}
+}
+
+// cancel stops reporting the status of the instance with the given ID to the
+// given waiter
+func (p *instancePoller) cancel(instanceID string, waiter chan<- instanceStatus) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	var waiters = p.waiters[instanceID]
+	for i, w := range waiters {
+		if w == waiter {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(p.waiters, instanceID)
+	} else {
+		p.waiters[instanceID] = waiters
+	}
+}
+
+// poll checks the status of all instances being waited for at each interval,
+// until there are none left. Best used as a goroutine.
+func (p *instancePoller) poll() {
+	for {
+		// Wait briefly between checks
+		time.Sleep(p.interval)
+
+		p.mux.Lock()
+		if len(p.waiters) == 0 {
+			p.polling = false
+			p.mux.Unlock()
+			return
+		}
+		var ids = make([]*string, 0, len(p.waiters))
+		for id := range p.waiters {
+			ids = append(ids, aws.String(id))
+		}
+		p.mux.Unlock()
+
+		for start := 0; start < len(ids); start += maxInstancesPerPoll {
+			var end = start + maxInstancesPerPoll
+			if end > len(ids) {
+				end = len(ids)
+			}
+			p.check(ids[start:end])
+		}
+	}
+}
+
+// check requests the status of the given instances, and releases the waiters
+// of instances that are running
+func (p *instancePoller) check(ids []*string) {
+	result, err := p.describe(&ec2.DescribeInstancesInput{InstanceIds: ids})
+	if err != nil {
+		// Newly launched instances can take a moment to become visible, so
+		// keep waiting for them. The error fails the whole request, so check
+		// the instances individually to report those that are visible.
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == codeInstanceNotFound {
+			if len(ids) > 1 {
+				for _, id := range ids {
+					p.check([]*string{id})
+				}
+				return
+			}
+			fmt.Fprintf(p.out, "No reservations found yet for instance %s.\n",
+				aws.StringValue(ids[0]))
+			return
+		}
+		for _, id := range ids {
+			p.release(aws.StringValue(id), instanceStatus{err: err})
+		}
+		return
+	}
+
+	// A reservation corresponds to a command to start instances - instances
+	// that are missing are still being set up, so keep waiting for them
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			var id = aws.StringValue(instance.InstanceId)
+			var s = instance.State
+			if s == nil {
+				fmt.Fprintf(p.out, "Instance %s status unknown.\n", id)
+				continue
+			}
+
+			// Code 16 means instance has started, and we can continue!
+			if s.Code != nil && *s.Code == codeEC2InstanceStarted {
+				fmt.Fprintf(p.out, "Instance %s is running!\n", id)
+				p.release(id, instanceStatus{
+					instance: instance,
+					owner:    aws.StringValue(reservation.OwnerId),
+				})
+				continue
+			}
+
+			// Otherwise, keep polling
+			if s.Name != nil {
+				fmt.Fprintf(p.out, "Instance %s status: %s\n", id, *s.Name)
+			} else {
+				fmt.Fprintf(p.out, "Instance %s status: %s\n", id, s.String())
+			}
+		}
+	}
+}
+
+// release stops waiting for the instance with the given ID, and reports the
+// given status to everything waiting for it
+func (p *instancePoller) release(instanceID string, status instanceStatus) {
+	p.mux.Lock()
+	var waiters = p.waiters[instanceID]
+	delete(p.waiters, instanceID)
+	p.mux.Unlock()
+	for _, waiter := range waiters {
+		waiter <- status
+	}
+}
@@ -0,0 +1,121 @@
+package provision
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/common"
+)
+
+// fakeInstance returns an instance with the given ID and state code
+func fakeInstance(id string, code int64) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId: aws.String(id),
+		State:      &ec2.InstanceState{Code: aws.Int64(code), Name: aws.String("pending")},
+	}
+}
+
+func TestInstancePoller(t *testing.T) {
+	var calls [][]string
+	var mux sync.Mutex
+	var poller = newInstancePoller(func(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		var ids = aws.StringValueSlice(in.InstanceIds)
+		sort.Strings(ids)
+		calls = append(calls, ids)
+		switch len(calls) {
+		case 1:
+			// Newly launched instances may not be listed yet
+			return &ec2.DescribeInstancesOutput{}, nil
+		case 2:
+			return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+				OwnerId:   aws.String("123"),
+				Instances: []*ec2.Instance{fakeInstance("a", 0), fakeInstance("b", codeEC2InstanceStarted)},
+			}}}, nil
+		default:
+			return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+				OwnerId:   aws.String("123"),
+				Instances: []*ec2.Instance{fakeInstance("a", codeEC2InstanceStarted)},
+			}}}, nil
+		}
+	}, 50*time.Millisecond, common.DevNull{})
+
+	// Instances waited for concurrently are checked in the same call
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			instance, owner, err := poller.wait(id, time.Minute)
+			assert.Nil(t, err)
+			assert.Equal(t, id, aws.StringValue(instance.InstanceId))
+			assert.Equal(t, "123", owner)
+		}(id)
+	}
+	wg.Wait()
+	assert.Equal(t, [][]string{{"a", "b"}, {"a", "b"}, {"a"}}, calls)
+
+	// The poll loop stops once nothing is waiting, and restarts on demand
+	time.Sleep(100 * time.Millisecond)
+	poller.mux.Lock()
+	assert.False(t, poller.polling)
+	poller.mux.Unlock()
+	_, _, err := poller.wait("a", time.Minute)
+	assert.Nil(t, err)
+}
+
+func TestInstancePoller_notFound(t *testing.T) {
+	// Requests that include an instance that is not visible yet fail
+	var poller = newInstancePoller(func(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+		for _, id := range aws.StringValueSlice(in.InstanceIds) {
+			if id == "b" {
+				return nil, awserr.New(codeInstanceNotFound, "", nil)
+			}
+		}
+		return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{fakeInstance("a", codeEC2InstanceStarted)},
+		}}}, nil
+	}, 20*time.Millisecond, common.DevNull{})
+
+	// Visible instances are still reported while others are not found
+	var errB = make(chan error, 1)
+	go func() {
+		_, _, err := poller.wait("b", 200*time.Millisecond)
+		errB <- err
+	}()
+	instance, _, err := poller.wait("a", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", aws.StringValue(instance.InstanceId))
+	assert.EqualError(t, <-errB, "timed out after 200ms waiting for instance b to start")
+}
+
+func TestInstancePoller_error(t *testing.T) {
+	var poller = newInstancePoller(func(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+		return nil, errors.New("throttled")
+	}, time.Millisecond, common.DevNull{})
+	_, _, err := poller.wait("a", time.Minute)
+	assert.EqualError(t, err, "throttled")
+}
+
+func TestInstancePoller_timeout(t *testing.T) {
+	var poller = newInstancePoller(func(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+		return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{fakeInstance("a", 0)},
+		}}}, nil
+	}, time.Millisecond, common.DevNull{})
+	_, _, err := poller.wait("a", 20*time.Millisecond)
+	assert.EqualError(t, err, "timed out after 20ms waiting for instance a to start")
+
+	// Instances that timed out are no longer polled
+	poller.mux.Lock()
+	assert.Empty(t, poller.waiters)
+	poller.mux.Unlock()
+}
@@ -0,0 +1,151 @@
+package provision
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// PortRange is a range of ports to expose over a protocol
+type PortRange struct {
+	From int64
+	To   int64
+
+	// Protocol is one of "tcp", "udp", or "icmp" - TCP is used if empty. ICMP
+	// has no ports, so From and To are ignored and all ICMP traffic is allowed.
+	Protocol string
+}
+
+// ParsePortRange parses a port specification of the form "PORT" or
+// "FROM-TO", optionally followed by "/tcp" or "/udp" - for example, "8080" or
+// "10000-20000/udp". The specification "icmp" allows all ICMP traffic.
+func ParsePortRange(spec string) (PortRange, error) {
+	if strings.EqualFold(spec, "icmp") {
+		return PortRange{Protocol: "icmp"}, nil
+	}
+	var r = PortRange{Protocol: "tcp"}
+	var ports = spec
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		ports, r.Protocol = spec[:i], strings.ToLower(spec[i+1:])
+	}
+	if r.isICMP() {
+		return PortRange{}, fmt.Errorf("invalid ports in '%s' - ICMP has no ports, use 'icmp' to allow ICMP traffic", spec)
+	}
+
+	var bounds = strings.SplitN(ports, "-", 2)
+	var err error
+	if r.From, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+		return PortRange{}, fmt.Errorf("invalid port '%s' in '%s'", bounds[0], spec)
+	}
+	r.To = r.From
+	if len(bounds) == 2 {
+		if r.To, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+			return PortRange{}, fmt.Errorf("invalid port '%s' in '%s'", bounds[1], spec)
+		}
+	}
+	return r, r.validate()
+}
+
+// String returns the range in the format accepted by ParsePortRange
+func (r PortRange) String() string {
+	if r.isICMP() {
+		return "icmp"
+	}
+	if r.From == r.To {
+		return fmt.Sprintf("%d/%s", r.From, r.protocol())
+	}
+	return fmt.Sprintf("%d-%d/%s", r.From, r.To, r.protocol())
+}
+
+func (r PortRange) protocol() string {
+	if r.Protocol == "" {
+		return "tcp"
+	}
+	return r.Protocol
+}
+
+func (r PortRange) isICMP() bool { return r.protocol() == "icmp" }
+
+// validate checks that the range is within bounds and uses a supported
+// protocol
+func (r PortRange) validate() error {
+	switch r.protocol() {
+	case "tcp", "udp":
+	case "icmp":
+		return nil
+	default:
+		return fmt.Errorf("unsupported protocol '%s' for ports %s - must be 'tcp', 'udp', or 'icmp'",
+			r.Protocol, r.String())
+	}
+	if r.From < 1 || r.To > 65535 {
+		return fmt.Errorf("ports %s are out of range - ports must be between 1 and 65535",
+			r.String())
+	}
+	if r.From > r.To {
+		return fmt.Errorf("invalid port range %s - first port must not be greater than last",
+			r.String())
+	}
+	return nil
+}
+
+// ingressSources are the IPv4 and IPv6 CIDR ranges that security group rules
+// allow traffic from
+type ingressSources struct {
+	ipv4 []string
+	ipv6 []string
+}
+
+// anywhere allows traffic from all addresses
+var anywhere = ingressSources{ipv4: []string{"0.0.0.0/0"}, ipv6: []string{"::/0"}}
+
+// parseIngressSources validates the given CIDR ranges, and sorts them into
+// IPv4 and IPv6 ranges. Traffic is allowed from anywhere if there are none.
+func parseIngressSources(cidrs []string) (ingressSources, error) {
+	if len(cidrs) == 0 {
+		return anywhere, nil
+	}
+	var sources ingressSources
+	for _, cidr := range cidrs {
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return ingressSources{}, fmt.Errorf("invalid CIDR '%s': %s", cidr, err.Error())
+		}
+		if ip.To4() != nil {
+			sources.ipv4 = append(sources.ipv4, network.String())
+		} else {
+			sources.ipv6 = append(sources.ipv6, network.String())
+		}
+	}
+	return sources, nil
+}
+
+// permission creates a security group rule that allows traffic from the
+// sources to the given ports
+func (s ingressSources) permission(r PortRange, description string) *ec2.IpPermission {
+	var permission = &ec2.IpPermission{
+		FromPort:   aws.Int64(r.From),
+		ToPort:     aws.Int64(r.To),
+		IpProtocol: aws.String(r.protocol()),
+	}
+	if r.isICMP() {
+		// For ICMP, the ports are the ICMP type and code - -1 allows all
+		permission.FromPort, permission.ToPort = aws.Int64(-1), aws.Int64(-1)
+	}
+	for _, cidr := range s.ipv4 {
+		permission.IpRanges = append(permission.IpRanges, &ec2.IpRange{
+			CidrIp:      aws.String(cidr),
+			Description: aws.String(description),
+		})
+	}
+	for _, cidr := range s.ipv6 {
+		permission.Ipv6Ranges = append(permission.Ipv6Ranges, &ec2.Ipv6Range{
+			CidrIpv6:    aws.String(cidr),
+			Description: aws.String(description),
+		})
+	}
+	return permission
+}
@@ -0,0 +1,61 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    PortRange
+		wantErr bool
+	}{
+		{"single port", "8080", PortRange{8080, 8080, "tcp"}, false},
+		{"single udp port", "53/udp", PortRange{53, 53, "udp"}, false},
+		{"range", "8000-8100", PortRange{8000, 8100, "tcp"}, false},
+		{"udp range", "10000-20000/UDP", PortRange{10000, 20000, "udp"}, false},
+		{"invalid port", "http", PortRange{}, true},
+		{"invalid range end", "8000-", PortRange{}, true},
+		{"reversed range", "8100-8000", PortRange{8100, 8000, "tcp"}, true},
+		{"out of bounds", "0-70000", PortRange{0, 70000, "tcp"}, true},
+		{"unsupported protocol", "80/sctp", PortRange{80, 80, "sctp"}, true},
+		{"icmp", "ICMP", PortRange{0, 0, "icmp"}, false},
+		{"icmp with ports", "8/icmp", PortRange{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePortRange(tt.spec)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseIngressSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidrs   []string
+		want    ingressSources
+		wantErr bool
+	}{
+		{"anywhere", nil, anywhere, false},
+		{"ipv4 only", []string{"203.0.113.0/24"},
+			ingressSources{ipv4: []string{"203.0.113.0/24"}}, false},
+		{"mixed", []string{"203.0.113.7/32", "2001:db8::/32"},
+			ingressSources{ipv4: []string{"203.0.113.7/32"}, ipv6: []string{"2001:db8::/32"}}, false},
+		{"normalized", []string{"203.0.113.7/24"},
+			ingressSources{ipv4: []string{"203.0.113.0/24"}}, false},
+		{"address without mask", []string{"203.0.113.7"}, ingressSources{}, true},
+		{"invalid", []string{"office"}, ingressSources{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIngressSources(tt.cidrs)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
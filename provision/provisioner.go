@@ -0,0 +1,197 @@
+package provision
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/cfg"
+)
+
+// Provisioner creates and destroys instances with a provider, so that the
+// provider can be selected at runtime. Each provisioner's own methods, such as
+// CreateInstance, accept options specific to its provider, and are wrapped by
+// these methods.
+type Provisioner interface {
+	// GetUser returns the user instances are accessed as
+	GetUser() string
+
+	// ListImages lists the images available to instances in region - the zone
+	// for GCE - for users to choose from. Each option starts with the ID of
+	// its image.
+	ListImages(region string) ([]string, error)
+
+	// Provision creates an instance with the given options, and returns the
+	// configuration of a remote for it
+	Provision(opts CreateInstanceOptions) (*cfg.RemoteVPS, error)
+
+	// Destroy removes the instance of the given remote, which must have been
+	// provisioned with the same provider, along with the resources created for
+	// it. The remote's generated key is removed as well unless keepKey is set.
+	Destroy(remote *cfg.RemoteVPS, keepKey bool) error
+}
+
+var (
+	_ Provisioner = (*EC2Provisioner)(nil)
+	_ Provisioner = (*GCEProvisioner)(nil)
+	_ Provisioner = (*DOProvisioner)(nil)
+)
+
+// CreateInstanceOptions defines parameters with which to create an instance
+// with any provider
+type CreateInstanceOptions struct {
+	Name        string
+	ProjectName string
+	Ports       []int64
+	PortRanges  []PortRange
+	DaemonPort  int64
+
+	// ImageID is the image the instance boots from, and InstanceType is its
+	// EC2 instance type, GCE machine type, or droplet size. Region is the
+	// region the instance is created in - the zone for GCE.
+	ImageID      string
+	InstanceType string
+	Region       string
+
+	// KeyDirectory is the directory the generated key is saved in, created if
+	// it does not exist. Keys are saved in ~/.ssh if it is unset.
+	KeyDirectory string
+
+	// FallbackWebhookSecret is used as the remote's webhook secret if a random
+	// secret cannot be generated. Provisioning fails instead if it is unset.
+	FallbackWebhookSecret string
+
+	// SSH configures SSH connections to the instance, including the check
+	// that its SSH server is ready - defaults are used if it is not set
+	SSH *cfg.SSHOptions
+
+	// Timeout is how long the instance is given to start and accept SSH
+	// connections, 10 minutes if unset
+	Timeout time.Duration
+}
+
+// ListImages lists the most recent Amazon Linux images in region
+func (p *EC2Provisioner) ListImages(region string) ([]string, error) {
+	return p.ListImageOptions(region, AmazonLinuxImages, DefaultImageLimit)
+}
+
+// Provision creates an EC2 instance with the given options
+func (p *EC2Provisioner) Provision(opts CreateInstanceOptions) (*cfg.RemoteVPS, error) {
+	return p.CreateInstance(EC2CreateInstanceOptions{
+		Name:                  opts.Name,
+		ProjectName:           opts.ProjectName,
+		Ports:                 opts.Ports,
+		PortRanges:            opts.PortRanges,
+		DaemonPort:            opts.DaemonPort,
+		ImageID:               opts.ImageID,
+		InstanceType:          opts.InstanceType,
+		Region:                opts.Region,
+		KeyDirectory:          opts.KeyDirectory,
+		FallbackWebhookSecret: opts.FallbackWebhookSecret,
+		SSH:                   opts.SSH,
+		Timeout:               opts.Timeout,
+	})
+}
+
+// Destroy terminates the EC2 instance of the given remote. Key pairs that were
+// not generated for the remote are always kept.
+func (p *EC2Provisioner) Destroy(remote *cfg.RemoteVPS, keepKey bool) error {
+	if err := checkProvider(remote, "ec2"); err != nil {
+		return err
+	}
+	var keyPairName, keyPath = remote.Resources.KeyPairName, remote.PEM
+	if keepKey || remote.Resources.ExistingKeyPair {
+		keyPairName, keyPath = "", ""
+	}
+	return p.DestroyInstance(remote.Resources.Region, remote.Resources.InstanceID,
+		remote.Resources.RecoveryAlarmName, remote.Resources.ElasticIPAllocationID,
+		keyPairName, keyPath)
+}
+
+// ListImages lists the public images available to instances in the given zone
+func (p *GCEProvisioner) ListImages(zone string) ([]string, error) {
+	return p.ListImageOptions(zone)
+}
+
+// Provision creates a GCE instance with the given options
+func (p *GCEProvisioner) Provision(opts CreateInstanceOptions) (*cfg.RemoteVPS, error) {
+	return p.CreateInstance(GCECreateInstanceOptions{
+		Name:                  opts.Name,
+		ProjectName:           opts.ProjectName,
+		Ports:                 opts.Ports,
+		PortRanges:            opts.PortRanges,
+		DaemonPort:            opts.DaemonPort,
+		ImageID:               opts.ImageID,
+		MachineType:           opts.InstanceType,
+		Zone:                  opts.Region,
+		KeyDirectory:          opts.KeyDirectory,
+		FallbackWebhookSecret: opts.FallbackWebhookSecret,
+		SSH:                   opts.SSH,
+		Timeout:               opts.Timeout,
+	})
+}
+
+// Destroy deletes the GCE instance of the given remote and its firewall
+func (p *GCEProvisioner) Destroy(remote *cfg.RemoteVPS, keepKey bool) error {
+	if err := checkProvider(remote, "gce"); err != nil {
+		return err
+	}
+	var keyPath = remote.PEM
+	if keepKey {
+		keyPath = ""
+	}
+	return p.DestroyInstance(remote.Resources.Region, remote.Resources.InstanceID,
+		remote.Resources.SecurityGroupID, keyPath)
+}
+
+// ListImages lists the distribution images available to droplets in region
+func (p *DOProvisioner) ListImages(region string) ([]string, error) {
+	return p.ListImageOptions(region)
+}
+
+// Provision creates a DigitalOcean droplet with the given options
+func (p *DOProvisioner) Provision(opts CreateInstanceOptions) (*cfg.RemoteVPS, error) {
+	return p.CreateInstance(DOCreateInstanceOptions{
+		Name:                  opts.Name,
+		ProjectName:           opts.ProjectName,
+		Ports:                 opts.Ports,
+		PortRanges:            opts.PortRanges,
+		DaemonPort:            opts.DaemonPort,
+		ImageID:               opts.ImageID,
+		Size:                  opts.InstanceType,
+		Region:                opts.Region,
+		KeyDirectory:          opts.KeyDirectory,
+		FallbackWebhookSecret: opts.FallbackWebhookSecret,
+		SSH:                   opts.SSH,
+		Timeout:               opts.Timeout,
+	})
+}
+
+// Destroy deletes the droplet of the given remote and its firewall
+func (p *DOProvisioner) Destroy(remote *cfg.RemoteVPS, keepKey bool) error {
+	if err := checkProvider(remote, "digitalocean"); err != nil {
+		return err
+	}
+	dropletID, err := strconv.Atoi(remote.Resources.InstanceID)
+	if err != nil {
+		return fmt.Errorf("invalid droplet ID '%s'", remote.Resources.InstanceID)
+	}
+	var keyPath = remote.PEM
+	if keepKey {
+		keyPath = ""
+	}
+	return p.DestroyInstance(dropletID, remote.Resources.SecurityGroupID, keyPath)
+}
+
+// checkProvider returns an error if the given remote was not provisioned with
+// provider
+func checkProvider(remote *cfg.RemoteVPS, provider string) error {
+	if remote.Resources == nil {
+		return fmt.Errorf("remote '%s' was not provisioned by Inertia", remote.Name)
+	}
+	if remote.Resources.Provider != provider {
+		return fmt.Errorf("remote '%s' was provisioned with %s, not %s",
+			remote.Name, remote.Resources.Provider, provider)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/cfg"
+)
+
+func TestCheckProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources *cfg.ProvisionedResources
+		wantErr   string
+	}{
+		{"not provisioned", nil, "remote 'dev' was not provisioned by Inertia"},
+		{"other provider", &cfg.ProvisionedResources{Provider: "gce"},
+			"remote 'dev' was provisioned with gce, not ec2"},
+		{"same provider", &cfg.ProvisionedResources{Provider: "ec2"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err = checkProvider(&cfg.RemoteVPS{Name: "dev", Resources: tt.resources}, "ec2")
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDOProvisionerDestroy_invalidDropletID(t *testing.T) {
+	prov, err := NewDOProvisioner("root", "token")
+	assert.Nil(t, err)
+	err = prov.Destroy(&cfg.RemoteVPS{
+		Name:      "dev",
+		Resources: &cfg.ProvisionedResources{Provider: "digitalocean", InstanceID: "i-123"},
+	}, false)
+	assert.EqualError(t, err, "invalid droplet ID 'i-123'")
+}
@@ -0,0 +1,141 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	// The metric that reports failures of the AWS systems an instance runs
+	// on, such as its host's hardware, which can be fixed by recovering the
+	// instance onto another host
+	recoveryMetricNamespace = "AWS/EC2"
+	recoveryMetricName      = "StatusCheckFailed_System"
+
+	// recoveryEvaluationPeriods is the number of consecutive minutes the
+	// system status check must fail for before an instance is recovered
+	recoveryEvaluationPeriods = 2
+)
+
+// recoveryActions are the CloudWatch actions needed to create recovery alarms,
+// and to delete them once their instances are destroyed
+var recoveryActions = []string{"cloudwatch:PutMetricAlarm", "cloudwatch:DeleteAlarms"}
+
+// recoveryAlarmName returns the name of the CloudWatch alarm that recovers the
+// instance with the given ID
+func recoveryAlarmName(instanceID string) string {
+	return "inertia-recover-" + instanceID
+}
+
+// recoverActionARN returns the ARN of the alarm action that recovers EC2
+// instances in region
+func recoverActionARN(region string) string {
+	return fmt.Sprintf("arn:%s:automate:%s:ec2:recover", awsPartition(region), region)
+}
+
+// cloudWatch returns a CloudWatch client for region that uses the
+// provisioner's credentials
+func (p *EC2Provisioner) cloudWatch(region string) *cloudwatch.CloudWatch {
+	return cloudwatch.New(p.session, &aws.Config{
+		Credentials: p.creds,
+		Region:      aws.String(region),
+	})
+}
+
+// createRecoveryAlarm creates a CloudWatch alarm that recovers the instance
+// with the given ID in region if its system status check fails, and returns
+// the alarm's name. Recovered instances keep their ID, IP addresses, and EBS
+// volumes.
+func (p *EC2Provisioner) createRecoveryAlarm(region, instanceID string) (string, error) {
+	var name = recoveryAlarmName(instanceID)
+	fmt.Fprintf(p.out, "Creating recovery alarm %s...\n", name)
+	if _, err := p.cloudWatch(region).PutMetricAlarm(&cloudwatch.PutMetricAlarmInput{
+		AlarmName:        aws.String(name),
+		AlarmDescription: aws.String("Recovers Inertia instance " + instanceID + " on system failure"),
+		Namespace:        aws.String(recoveryMetricNamespace),
+		MetricName:       aws.String(recoveryMetricName),
+		Dimensions: []*cloudwatch.Dimension{{
+			Name:  aws.String("InstanceId"),
+			Value: aws.String(instanceID),
+		}},
+		Statistic:          aws.String(cloudwatch.StatisticMinimum),
+		Period:             aws.Int64(60),
+		EvaluationPeriods:  aws.Int64(recoveryEvaluationPeriods),
+		Threshold:          aws.Float64(0),
+		ComparisonOperator: aws.String(cloudwatch.ComparisonOperatorGreaterThanThreshold),
+		AlarmActions:       []*string{aws.String(recoverActionARN(region))},
+	}); err != nil {
+		return "", fmt.Errorf("failed to create recovery alarm: %s", err.Error())
+	}
+	return name, nil
+}
+
+// deleteRecoveryAlarm deletes the CloudWatch alarm with the given name in
+// region
+func (p *EC2Provisioner) deleteRecoveryAlarm(region, name string) error {
+	fmt.Fprintf(p.out, "Deleting recovery alarm %s...\n", name)
+	_, err := p.cloudWatch(region).DeleteAlarms(&cloudwatch.DeleteAlarmsInput{
+		AlarmNames: []*string{aws.String(name)},
+	})
+	return err
+}
+
+// principalARN returns the ARN of the IAM user or role that the caller with
+// the given ARN, as reported by GetCallerIdentity, acts as - sessions of
+// assumed roles act as their role. Returns an empty string for callers whose
+// policies cannot be simulated, such as the account's root user.
+func principalARN(callerARN string) string {
+	var parts = strings.SplitN(callerARN, ":", 6)
+	if len(parts) != 6 {
+		return ""
+	}
+	var partition, service, account, resource = parts[1], parts[2], parts[4], parts[5]
+	switch {
+	case service == "iam" && (strings.HasPrefix(resource, "user/") ||
+		strings.HasPrefix(resource, "role/")):
+		return callerARN
+	case service == "sts" && strings.HasPrefix(resource, "assumed-role/"):
+		var role = strings.SplitN(strings.TrimPrefix(resource, "assumed-role/"), "/", 2)[0]
+		return fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, account, role)
+	default:
+		return ""
+	}
+}
+
+// missingRecoveryPermissions returns the actions needed for recovery alarms
+// that the provisioner's credentials are not allowed to perform. CloudWatch
+// does not support dry runs, so the policies of the credentials' user or role
+// are simulated instead.
+func (p *EC2Provisioner) missingRecoveryPermissions(region string) ([]string, error) {
+	var config = &aws.Config{
+		Credentials: p.creds,
+		Region:      aws.String(region),
+	}
+	identity, err := sts.New(p.session, config).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	var principal = principalARN(aws.StringValue(identity.Arn))
+	if principal == "" {
+		return nil, nil
+	}
+	result, err := iam.New(p.session, config).SimulatePrincipalPolicy(&iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     aws.StringSlice(recoveryActions),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var missing = []string{}
+	for _, r := range result.EvaluationResults {
+		if aws.StringValue(r.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+			missing = append(missing, aws.StringValue(r.EvalActionName))
+		}
+	}
+	return missing, nil
+}
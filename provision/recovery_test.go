@@ -0,0 +1,46 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverActionARN(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		want   string
+	}{
+		{"commercial", "us-west-2", "arn:aws:automate:us-west-2:ec2:recover"},
+		{"govcloud", "us-gov-west-1", "arn:aws-us-gov:automate:us-gov-west-1:ec2:recover"},
+		{"china", "cn-north-1", "arn:aws-cn:automate:cn-north-1:ec2:recover"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, recoverActionARN(tt.region))
+		})
+	}
+}
+
+func TestPrincipalARN(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller string
+		want   string
+	}{
+		{"user", "arn:aws:iam::123456789012:user/bob", "arn:aws:iam::123456789012:user/bob"},
+		{"assumed role", "arn:aws:sts::123456789012:assumed-role/deployer/session",
+			"arn:aws:iam::123456789012:role/deployer"},
+		{"govcloud assumed role", "arn:aws-us-gov:sts::123456789012:assumed-role/deployer/session",
+			"arn:aws-us-gov:iam::123456789012:role/deployer"},
+		{"root", "arn:aws:iam::123456789012:root", ""},
+		{"federated user", "arn:aws:sts::123456789012:federated-user/bob", ""},
+		{"invalid", "bob", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, principalARN(tt.caller))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package provision
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// DefaultMaxAttempts is how many times AWS requests that fail with
+	// transient errors are attempted if no other maximum is configured
+	DefaultMaxAttempts = 5
+
+	// Bounds on the delay between attempts, which doubles after each attempt
+	minRetryDelay = time.Second
+	maxRetryDelay = 20 * time.Second
+)
+
+// transientCodes are the codes of errors returned by AWS for requests that
+// failed due to problems on AWS' end, which are not throttling errors
+var transientCodes = map[string]bool{
+	"InternalError":      true,
+	"InternalFailure":    true,
+	"ServiceUnavailable": true,
+	"Unavailable":        true,
+}
+
+// retrySleep waits between attempts. Stubbed out for testing.
+var retrySleep = time.Sleep
+
+// isTransient checks if err is a throttling, network, or server error that
+// is likely to go away if the request is retried
+func isTransient(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	// The SDK considers errors it does not recognize retryable, so it is only
+	// asked about errors from AWS and network errors
+	switch err.(type) {
+	case awserr.Error, *url.Error, net.Error:
+		if request.IsErrorRetryable(err) {
+			return true
+		}
+	}
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() >= 500 {
+		return true
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return transientCodes[aerr.Code()]
+	}
+	return false
+}
+
+// retry calls fn, which makes the named AWS request, until it succeeds or
+// fails with an error that is not transient, waiting longer after each failed
+// attempt. The error of the last attempt is returned once the maximum number of
+// attempts is reached. Requests are already retried briefly by the AWS SDK
+// before they fail, so this rides out longer throttling and outages.
+func (p *EC2Provisioner) retry(operation string, fn func() error) error {
+	var maxAttempts = p.maxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	var delay = minRetryDelay
+	for attempt := 1; ; attempt++ {
+		var err = fn()
+		if err == nil || !isTransient(err) || attempt >= maxAttempts {
+			return err
+		}
+		fmt.Fprintf(p.out, "%s failed (attempt %d of %d), retrying in %s: %s\n",
+			operation, attempt, maxAttempts, delay, err.Error())
+		retrySleep(delay)
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// WithMaxAttempts sets how many times AWS requests made while provisioning
+// instances are attempted if they fail with transient errors, such as
+// throttling errors. Requests are attempted DefaultMaxAttempts times if it is
+// not set.
+func (p *EC2Provisioner) WithMaxAttempts(attempts int) error {
+	if attempts < 1 {
+		return fmt.Errorf("invalid maximum attempts %d - must be at least 1", attempts)
+	}
+	p.maxAttempts = attempts
+	return nil
+}
@@ -0,0 +1,280 @@
+package provision
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingEC2 is an EC2 client whose image requests fail with err the first
+// failures times they are made
+type failingEC2 struct {
+	ec2iface.EC2API
+	err      error
+	failures int
+	calls    int
+}
+
+func (c *failingEC2) DescribeImages(*ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	c.calls++
+	if c.calls <= c.failures {
+		return nil, c.err
+	}
+	return &ec2.DescribeImagesOutput{
+		Images: []*ec2.Image{{ImageId: aws.String("ami-1234")}},
+	}, nil
+}
+
+// DescribeImagesPages fails like DescribeImages, then returns an image in each
+// of two pages
+func (c *failingEC2) DescribeImagesPages(input *ec2.DescribeImagesInput,
+	fn func(*ec2.DescribeImagesOutput, bool) bool) error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	var pages = []*ec2.DescribeImagesOutput{
+		{
+			Images:    []*ec2.Image{{ImageId: aws.String("ami-1234"), Name: aws.String("first")}},
+			NextToken: aws.String("page-2"),
+		},
+		{
+			Images: []*ec2.Image{{ImageId: aws.String("ami-5678"), Name: aws.String("second")}},
+		},
+	}
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+// provisioningEC2 is an EC2 client that fails each request with a throttling
+// error the first time it is made. The security group is created by the
+// request that fails, and instances cannot be launched.
+type provisioningEC2 struct {
+	ec2iface.EC2API
+	calls   map[string]int
+	deleted []string
+}
+
+// call records a call to the named request, returning a throttling error if
+// it is the first
+func (c *provisioningEC2) call(request string) error {
+	c.calls[request]++
+	if c.calls[request] == 1 {
+		return awserr.New("RequestLimitExceeded", "slow down", nil)
+	}
+	return nil
+}
+
+func (c *provisioningEC2) DescribeInstanceTypeOfferingsPages(input *ec2.DescribeInstanceTypeOfferingsInput,
+	fn func(*ec2.DescribeInstanceTypeOfferingsOutput, bool) bool) error {
+	if err := c.call("DescribeInstanceTypeOfferings"); err != nil {
+		return err
+	}
+	fn(&ec2.DescribeInstanceTypeOfferingsOutput{
+		InstanceTypeOfferings: []*ec2.InstanceTypeOffering{{InstanceType: aws.String("t2.micro")}},
+	}, true)
+	return nil
+}
+
+func (c *provisioningEC2) CreateKeyPair(input *ec2.CreateKeyPairInput) (*ec2.CreateKeyPairOutput, error) {
+	if err := c.call("CreateKeyPair"); err != nil {
+		return nil, err
+	}
+	return &ec2.CreateKeyPairOutput{
+		KeyName:     input.KeyName,
+		KeyPairId:   aws.String("key-1234"),
+		KeyMaterial: aws.String("key"),
+	}, nil
+}
+
+func (c *provisioningEC2) CreateTags(*ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (c *provisioningEC2) CreateSecurityGroup(*ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	if err := c.call("CreateSecurityGroup"); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New(codeSecurityGroupDuplicate, "already exists", nil)
+}
+
+func (c *provisioningEC2) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if err := c.call("DescribeSecurityGroups"); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSecurityGroupsOutput{
+		SecurityGroups: []*ec2.SecurityGroup{{GroupId: aws.String("sg-1234")}},
+	}, nil
+}
+
+func (c *provisioningEC2) AuthorizeSecurityGroupIngress(input *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	if err := c.call("AuthorizeSecurityGroupIngress"); err != nil {
+		return nil, err
+	}
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (c *provisioningEC2) RunInstances(*ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	if err := c.call("RunInstances"); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("InsufficientInstanceCapacity", "no capacity", nil)
+}
+
+func (c *provisioningEC2) DeleteSecurityGroup(input *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	c.deleted = append(c.deleted, aws.StringValue(input.GroupId))
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (c *provisioningEC2) DeleteKeyPair(input *ec2.DeleteKeyPairInput) (*ec2.DeleteKeyPairOutput, error) {
+	c.deleted = append(c.deleted, aws.StringValue(input.KeyName))
+	return &ec2.DeleteKeyPairOutput{}, nil
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"throttled", awserr.New("RequestLimitExceeded", "slow down", nil), true},
+		{"unavailable", awserr.New("Unavailable", "try again", nil), true},
+		{"server error", awserr.NewRequestFailure(awserr.New("Unknown", "oops", nil), 503, "id"), true},
+		{"not found", awserr.New("InvalidAMIID.NotFound", "no such image", nil), false},
+		{"unauthorized", awserr.New("UnauthorizedOperation", "denied", nil), false},
+		{"connection refused", &url.Error{Op: "Post", URL: "https://ec2.amazonaws.com",
+			Err: errors.New("dial tcp: connection refused")}, true},
+		{"other error", errors.New("oops"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
+
+func TestEC2Provisioner_retry(t *testing.T) {
+	var delays []time.Duration
+	retrySleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { retrySleep = time.Sleep }()
+
+	var throttled = awserr.New("RequestLimitExceeded", "slow down", nil)
+	tests := []struct {
+		name        string
+		maxAttempts int
+		err         error
+		failures    int
+		wantCalls   int
+		wantDelays  []time.Duration
+		wantErr     bool
+	}{
+		{"succeeds", 0, throttled, 0, 1, nil, false},
+		{"throttled then succeeds", 0, throttled, 3, 4,
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, false},
+		{"throttled until max attempts", 2, throttled, 3, 2,
+			[]time.Duration{time.Second}, true},
+		{"delay capped", 7, throttled, 6, 7,
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second,
+				8 * time.Second, 16 * time.Second, 20 * time.Second}, false},
+		{"not transient", 0, awserr.New("InvalidAMIID.NotFound", "no such image", nil), 3, 1,
+			nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays = nil
+			var client = &failingEC2{err: tt.err, failures: tt.failures}
+			var p = &EC2Provisioner{out: ioutil.Discard}
+			if tt.maxAttempts > 0 {
+				assert.Nil(t, p.WithMaxAttempts(tt.maxAttempts))
+			}
+
+			var output *ec2.DescribeImagesOutput
+			err := p.retry("DescribeImages", func() (err error) {
+				output, err = client.DescribeImages(&ec2.DescribeImagesInput{})
+				return err
+			})
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantCalls, client.calls)
+			assert.Equal(t, tt.wantDelays, delays)
+			if !tt.wantErr {
+				assert.Equal(t, "ami-1234", aws.StringValue(output.Images[0].ImageId))
+			}
+		})
+	}
+}
+
+func TestEC2Provisioner_WithMaxAttempts(t *testing.T) {
+	var p = &EC2Provisioner{}
+	assert.NotNil(t, p.WithMaxAttempts(0))
+	assert.Nil(t, p.WithMaxAttempts(3))
+	assert.Equal(t, 3, p.maxAttempts)
+}
+
+func TestEC2Provisioner_ListImageOptions_retries(t *testing.T) {
+	retrySleep = func(time.Duration) {}
+	defer func() { retrySleep = time.Sleep }()
+	var client = &failingEC2{err: awserr.New("RequestLimitExceeded", "slow down", nil), failures: 2}
+	newEC2Client = func(*session.Session, *aws.Config) ec2iface.EC2API { return client }
+	defer func() { newEC2Client = defaultNewEC2Client }()
+
+	prov, _ := NewEC2Provisioner("bob", "id", "key")
+	options, err := prov.ListImageOptions("us-east-1", ImageFilter{}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, client.calls)
+
+	// Images from every page are listed
+	assert.ElementsMatch(t, []string{"ami-1234 ()", "ami-5678 ()"}, options)
+}
+
+func TestEC2Provisioner_CreateInstance_retries(t *testing.T) {
+	retrySleep = func(time.Duration) {}
+	defer func() { retrySleep = time.Sleep }()
+	var client = &provisioningEC2{calls: map[string]int{}}
+	newEC2Client = func(*session.Session, *aws.Config) ec2iface.EC2API { return client }
+	defer func() { newEC2Client = defaultNewEC2Client }()
+
+	dir, err := ioutil.TempDir("", "inertia-keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	prov, _ := NewEC2Provisioner("bob", "id", "key")
+	_, err = prov.CreateInstance(EC2CreateInstanceOptions{
+		Name:         "staging",
+		ProjectName:  "project",
+		ImageID:      "ami-1234",
+		InstanceType: "t2.micro",
+		Region:       "us-east-1",
+		DaemonPort:   4303,
+		KeyDirectory: dir,
+	})
+	assert.EqualError(t, err, "InsufficientInstanceCapacity: no capacity")
+	assert.Equal(t, map[string]int{
+		"DescribeInstanceTypeOfferings": 2,
+		"CreateKeyPair":                 2,
+		"CreateSecurityGroup":           2,
+		"DescribeSecurityGroups":        2,
+		"AuthorizeSecurityGroupIngress": 2,
+		"RunInstances":                  2,
+	}, client.calls)
+
+	// The security group created by the failed attempt should be removed
+	assert.Len(t, client.deleted, 2)
+	assert.Equal(t, "sg-1234", client.deleted[0])
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,144 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// spotRequestPollInterval is how often the status of spot requests is
+	// checked while waiting for them to be fulfilled
+	spotRequestPollInterval = 3 * time.Second
+
+	// Error code returned by AWS for spot requests that have just been made,
+	// but are not visible to DescribeSpotInstanceRequests yet
+	codeSpotRequestNotFound = "InvalidSpotInstanceRequestID.NotFound"
+)
+
+// spotRequestHolds are the statuses of open spot requests that will not be
+// fulfilled without intervention, such as raising the maximum price or picking
+// another instance type, so they are treated as failures instead of waited on
+var spotRequestHolds = map[string]bool{
+	"capacity-not-available":     true,
+	"capacity-oversubscribed":    true,
+	"price-too-low":              true,
+	"constraint-not-fulfillable": true,
+	"launch-group-constraint":    true,
+	"az-group-constraint":        true,
+	"placement-group-constraint": true,
+}
+
+// launchSpotInstance requests a one-time spot instance with the given launch
+// specification, recording the request in created, and waits up to timeout
+// for the request to be fulfilled. Returns the ID of the launched instance.
+func (p *EC2Provisioner) launchSpotInstance(opts EC2CreateInstanceOptions,
+	spec *ec2.RequestSpotLaunchSpecification, created *createdResources,
+	timeout time.Duration) (string, error) {
+	var price *string
+	if opts.SpotPrice != "" {
+		price = aws.String(opts.SpotPrice)
+	}
+	fmt.Fprintln(p.out, "Requesting spot instance...")
+	resp, err := p.client.RequestSpotInstances(&ec2.RequestSpotInstancesInput{
+		InstanceCount:       aws.Int64(1),
+		Type:                aws.String(ec2.SpotInstanceTypeOneTime),
+		SpotPrice:           price,
+		LaunchSpecification: spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.SpotInstanceRequests) == 0 {
+		return "", errors.New("Unable to request spot instance: " + resp.String())
+	}
+	created.spotRequestID = aws.StringValue(resp.SpotInstanceRequests[0].SpotInstanceRequestId)
+
+	fmt.Fprintln(p.out, "Waiting for spot request to be fulfilled...")
+	return p.waitForSpotRequest(created.spotRequestID, spotRequestPollInterval, timeout)
+}
+
+// waitForSpotRequest blocks until the spot request with the given ID is
+// fulfilled, checking at the given interval, and returns the ID of the
+// instance launched for it. An error is returned if the request fails, is held
+// up by a lack of capacity or a low maximum price, or is not fulfilled within
+// timeout.
+func (p *EC2Provisioner) waitForSpotRequest(requestID string, interval,
+	timeout time.Duration) (string, error) {
+	var deadline = time.Now().Add(timeout)
+	for {
+		time.Sleep(interval)
+
+		result, err := p.client.DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+			SpotInstanceRequestIds: []*string{aws.String(requestID)},
+		})
+		if err != nil {
+			// Newly made requests can take a moment to become visible
+			if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != codeSpotRequestNotFound {
+				return "", err
+			}
+		} else if len(result.SpotInstanceRequests) > 0 {
+			var request = result.SpotInstanceRequests[0]
+			instanceID, fulfilled, err := checkSpotRequest(request)
+			if err != nil {
+				return "", err
+			}
+			if fulfilled {
+				fmt.Fprintf(p.out, "Spot request fulfilled by instance %s!\n", instanceID)
+				return instanceID, nil
+			}
+			if request.Status != nil {
+				fmt.Fprintln(p.out, "Spot request status: "+aws.StringValue(request.Status.Code))
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("timed out after %s waiting for spot request %s to be fulfilled",
+				timeout.Round(time.Second), requestID)
+		}
+	}
+}
+
+// checkSpotRequest returns the ID of the instance launched for the given spot
+// request if it has been fulfilled, or an error if the request has failed or
+// will not be fulfilled without intervention
+func checkSpotRequest(request *ec2.SpotInstanceRequest) (instanceID string, fulfilled bool, err error) {
+	var code, message string
+	if request.Status != nil {
+		code = aws.StringValue(request.Status.Code)
+		message = aws.StringValue(request.Status.Message)
+	}
+	switch aws.StringValue(request.State) {
+	case ec2.SpotInstanceStateActive:
+		if request.InstanceId == nil {
+			return "", false, nil
+		}
+		return *request.InstanceId, true, nil
+	case ec2.SpotInstanceStateOpen:
+		if spotRequestHolds[code] {
+			return "", false, fmt.Errorf("spot request cannot be fulfilled (%s): %s", code, message)
+		}
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("spot request %s (%s): %s",
+			aws.StringValue(request.State), code, message)
+	}
+}
+
+// validateSpotPrice checks that the given maximum spot price, if set, is a
+// positive hourly price
+func validateSpotPrice(price string) error {
+	if price == "" {
+		return nil
+	}
+	if p, err := strconv.ParseFloat(price, 64); err != nil || p <= 0 {
+		return fmt.Errorf("invalid spot price '%s' - must be a positive hourly price in USD, "+
+			"such as '0.05'", price)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSpotRequest(t *testing.T) {
+	var request = func(state, code, instanceID string) *ec2.SpotInstanceRequest {
+		var r = &ec2.SpotInstanceRequest{
+			State:  aws.String(state),
+			Status: &ec2.SpotInstanceStatus{Code: aws.String(code), Message: aws.String("message")},
+		}
+		if instanceID != "" {
+			r.InstanceId = aws.String(instanceID)
+		}
+		return r
+	}
+	tests := []struct {
+		name          string
+		request       *ec2.SpotInstanceRequest
+		wantID        string
+		wantFulfilled bool
+		wantErr       bool
+	}{
+		{"pending", request("open", "pending-evaluation", ""), "", false, false},
+		{"fulfilled", request("active", "fulfilled", "i-123"), "i-123", true, false},
+		{"active without instance", request("active", "fulfilled", ""), "", false, false},
+		{"no capacity", request("open", "capacity-not-available", ""), "", false, true},
+		{"price too low", request("open", "price-too-low", ""), "", false, true},
+		{"failed", request("failed", "bad-parameters", ""), "", false, true},
+		{"cancelled", request("cancelled", "canceled-before-fulfillment", ""), "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, fulfilled, err := checkSpotRequest(tt.request)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, tt.wantFulfilled, fulfilled)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestValidateSpotPrice(t *testing.T) {
+	tests := []struct {
+		price   string
+		wantErr bool
+	}{
+		{"", false},
+		{"0.05", false},
+		{"1", false},
+		{"0", true},
+		{"-0.05", true},
+		{"$0.05", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validateSpotPrice(tt.price) != nil)
+		})
+	}
+}
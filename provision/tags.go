@@ -0,0 +1,73 @@
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// Limits on tags imposed by AWS
+	maxTagsPerResource = 50
+	maxTagKeyLength    = 128
+	maxTagValueLength  = 256
+)
+
+// reservedTags are the tags Inertia uses to identify the resources it manages,
+// which cannot be overridden
+var reservedTags = map[string]bool{"Purpose": true, "Project": true}
+
+// resourceTags returns the tags to apply to the resources created for the
+// instance with the given name in project, merged with the given user-defined
+// tags. The Name tag is set to the name of the instance unless it is defined by
+// the user.
+func resourceTags(name, project string, userTags map[string]string) ([]*ec2.Tag, error) {
+	var merged = map[string]string{
+		"Name":    name,
+		"Purpose": inertiaPurposeTag,
+		"Project": project,
+	}
+	for key, value := range userTags {
+		if reservedTags[key] {
+			return nil, fmt.Errorf("tag '%s' is reserved for use by Inertia", key)
+		}
+		if key == "" || len(key) > maxTagKeyLength || strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return nil, fmt.Errorf("invalid tag key '%s' - must be 1 to %d characters, and cannot "+
+				"start with 'aws:'", key, maxTagKeyLength)
+		}
+		if len(value) > maxTagValueLength {
+			return nil, fmt.Errorf("invalid value for tag '%s' - must be at most %d characters",
+				key, maxTagValueLength)
+		}
+		merged[key] = value
+	}
+	if len(merged) > maxTagsPerResource {
+		return nil, fmt.Errorf("too many tags - resources can have at most %d tags, including "+
+			"the Name, Purpose, and Project tags set by Inertia", maxTagsPerResource)
+	}
+
+	var keys = make([]string, 0, len(merged))
+	for key := range merged {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var tags = make([]*ec2.Tag, len(keys))
+	for i, key := range keys {
+		tags[i] = &ec2.Tag{Key: aws.String(key), Value: aws.String(merged[key])}
+	}
+	return tags, nil
+}
+
+// tagResources applies tags to the resources with the given IDs. Failures are
+// reported, but are not fatal, since the resources are usable without tags.
+func (p *EC2Provisioner) tagResources(tags []*ec2.Tag, ids ...string) {
+	if _, err := p.client.CreateTags(&ec2.CreateTagsInput{
+		Resources: aws.StringSlice(ids),
+		Tags:      tags,
+	}); err != nil {
+		fmt.Fprintln(p.out, "Failed to set tags: "+err.Error())
+	}
+}
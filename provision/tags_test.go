@@ -0,0 +1,53 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceTags(t *testing.T) {
+	var tag = func(key, value string) *ec2.Tag {
+		return &ec2.Tag{Key: aws.String(key), Value: aws.String(value)}
+	}
+	var tooMany = map[string]string{}
+	for i := 0; i < maxTagsPerResource; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	tests := []struct {
+		name     string
+		userTags map[string]string
+		want     []*ec2.Tag
+		wantErr  bool
+	}{
+		{"defaults", nil, []*ec2.Tag{
+			tag("Name", "dev"), tag("Project", "app"), tag("Purpose", inertiaPurposeTag),
+		}, false},
+		{"user tags", map[string]string{"team": "platform", "env": "staging"}, []*ec2.Tag{
+			tag("Name", "dev"), tag("Project", "app"), tag("Purpose", inertiaPurposeTag),
+			tag("env", "staging"), tag("team", "platform"),
+		}, false},
+		{"name overridden", map[string]string{"Name": "app-staging"}, []*ec2.Tag{
+			tag("Name", "app-staging"), tag("Project", "app"), tag("Purpose", inertiaPurposeTag),
+		}, false},
+		{"reserved", map[string]string{"Purpose": "other"}, nil, true},
+		{"aws prefix", map[string]string{"aws:team": "platform"}, nil, true},
+		{"empty key", map[string]string{"": "platform"}, nil, true},
+		{"long value", map[string]string{"team": strings.Repeat("v", maxTagValueLength+1)}, nil, true},
+		{"too many", tooMany, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resourceTags("dev", "app", tt.userTags)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
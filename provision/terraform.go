@@ -0,0 +1,105 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"github.com/ubclaunchpad/inertia/cfg"
+)
+
+// terraformInvalidName matches characters not permitted in Terraform resource
+// names
+var terraformInvalidName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+var terraformImportTemplate = template.Must(template.New("terraform").Parse(`#!/bin/sh
+# Imports the resources created by Inertia for remote '{{.Remote}}' into your
+# Terraform state. Add the following resource blocks to your configuration
+# before running this script, then run 'terraform plan' and fill in any
+# remaining attributes until no changes are planned.
+#
+# resource "aws_instance" "{{.Name}}" {
+#   ami                    = "{{.Resources.ImageID}}"
+#   instance_type          = "{{.Resources.InstanceType}}"
+{{- if .Resources.ExistingKeyPair}}
+#   key_name               = "{{.Resources.KeyPairName}}"
+{{- else}}
+#   key_name               = aws_key_pair.{{.Name}}.key_name
+{{- end}}
+#   vpc_security_group_ids = [aws_security_group.{{.Name}}.id]
+{{- if .Resources.SubnetID}}
+#   subnet_id              = "{{.Resources.SubnetID}}"
+{{- end}}
+# }
+#
+# resource "aws_security_group" "{{.Name}}" {
+# }
+{{- if not .Resources.ExistingKeyPair}}
+#
+# resource "aws_key_pair" "{{.Name}}" {
+#   key_name   = "{{.Resources.KeyPairName}}"
+#   public_key = "" # the public key of {{.Resources.KeyPairName}}
+# }
+{{- end}}
+{{- if .Resources.ElasticIPAllocationID}}
+#
+# resource "aws_eip" "{{.Name}}" {
+#   vpc      = true
+#   instance = aws_instance.{{.Name}}.id
+# }
+{{- end}}
+{{- if .Resources.RecoveryAlarmName}}
+#
+# resource "aws_cloudwatch_metric_alarm" "{{.Name}}" {
+#   alarm_name = "{{.Resources.RecoveryAlarmName}}"
+#   dimensions = { InstanceId = aws_instance.{{.Name}}.id }
+# }
+{{- end}}
+
+set -e
+
+# {{.Resources.InstanceARN}}
+terraform import aws_instance.{{.Name}} {{.Resources.InstanceID}}
+
+# {{.Resources.SecurityGroupARN}}
+terraform import aws_security_group.{{.Name}} {{.Resources.SecurityGroupID}}
+{{- if not .Resources.ExistingKeyPair}}
+
+terraform import aws_key_pair.{{.Name}} {{.Resources.KeyPairName}}
+{{- end}}
+{{- if .Resources.ElasticIPAllocationID}}
+
+terraform import aws_eip.{{.Name}} {{.Resources.ElasticIPAllocationID}}
+{{- end}}
+{{- if .Resources.RecoveryAlarmName}}
+
+terraform import aws_cloudwatch_metric_alarm.{{.Name}} {{.Resources.RecoveryAlarmName}}
+{{- end}}
+`))
+
+// TerraformImportScript generates a shell script, including the Terraform
+// resource blocks to add to a configuration, that imports the resources
+// created for the given remote into Terraform state
+func TerraformImportScript(remote *cfg.RemoteVPS) ([]byte, error) {
+	if remote.Resources == nil {
+		return nil, fmt.Errorf("no provisioned resources recorded for remote '%s'", remote.Name)
+	}
+	if remote.Resources.Provider != "ec2" {
+		return nil, fmt.Errorf("provider '%s' is not supported", remote.Resources.Provider)
+	}
+
+	var script = new(bytes.Buffer)
+	if err := terraformImportTemplate.Execute(script, struct {
+		Remote    string
+		Name      string
+		Resources *cfg.ProvisionedResources
+	}{
+		Remote:    remote.Name,
+		Name:      "inertia_" + terraformInvalidName.ReplaceAllString(remote.Name, "_"),
+		Resources: remote.Resources,
+	}); err != nil {
+		return nil, err
+	}
+	return script.Bytes(), nil
+}
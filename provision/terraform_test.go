@@ -0,0 +1,95 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/cfg"
+)
+
+func TestTerraformImportScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		remote   *cfg.RemoteVPS
+		wantErr  bool
+		contains []string
+		excludes []string
+	}{
+		{"no resources", &cfg.RemoteVPS{Name: "dev"}, true, nil, nil},
+		{"unsupported provider", &cfg.RemoteVPS{
+			Name: "dev", Resources: &cfg.ProvisionedResources{Provider: "gce"}}, true, nil, nil},
+		{"ec2", &cfg.RemoteVPS{
+			Name: "dev.server",
+			Resources: &cfg.ProvisionedResources{
+				Provider:         "ec2",
+				InstanceID:       "i-1234",
+				InstanceARN:      "arn:aws:ec2:us-east-1:123:instance/i-1234",
+				SecurityGroupID:  "sg-1234",
+				SecurityGroupARN: "arn:aws:ec2:us-east-1:123:security-group/sg-1234",
+				KeyPairName:      "project_dev.server_ec2-user_inertia_key_1",
+			},
+		}, false, []string{
+			"terraform import aws_instance.inertia_dev_server i-1234",
+			"terraform import aws_security_group.inertia_dev_server sg-1234",
+			"terraform import aws_key_pair.inertia_dev_server project_dev.server_ec2-user_inertia_key_1",
+			"# arn:aws:ec2:us-east-1:123:instance/i-1234",
+		}, nil},
+		{"ec2 with recovery alarm", &cfg.RemoteVPS{
+			Name: "dev",
+			Resources: &cfg.ProvisionedResources{
+				Provider:          "ec2",
+				InstanceID:        "i-1234",
+				KeyPairName:       "project_dev_ec2-user_inertia_key_1",
+				RecoveryAlarmName: "inertia-recover-i-1234",
+			},
+		}, false, []string{
+			"terraform import aws_key_pair.inertia_dev project_dev_ec2-user_inertia_key_1\n",
+			"terraform import aws_cloudwatch_metric_alarm.inertia_dev inertia-recover-i-1234",
+			`resource "aws_cloudwatch_metric_alarm" "inertia_dev"`,
+		}, nil},
+		{"ec2 with elastic ip", &cfg.RemoteVPS{
+			Name: "dev",
+			Resources: &cfg.ProvisionedResources{
+				Provider:              "ec2",
+				InstanceID:            "i-1234",
+				SubnetID:              "subnet-1234",
+				ElasticIPAllocationID: "eipalloc-1234",
+			},
+		}, false, []string{
+			`subnet_id              = "subnet-1234"`,
+			"terraform import aws_eip.inertia_dev eipalloc-1234",
+			`resource "aws_eip" "inertia_dev"`,
+		}, []string{
+			"aws_cloudwatch_metric_alarm",
+		}},
+		{"ec2 with existing key pair", &cfg.RemoteVPS{
+			Name: "dev",
+			Resources: &cfg.ProvisionedResources{
+				Provider:        "ec2",
+				InstanceID:      "i-1234",
+				KeyPairName:     "team-key",
+				ExistingKeyPair: true,
+			},
+		}, false, []string{
+			`key_name               = "team-key"`,
+		}, []string{
+			"aws_key_pair",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := TerraformImportScript(tt.remote)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			for _, c := range tt.contains {
+				assert.Contains(t, string(script), c)
+			}
+			for _, e := range tt.excludes {
+				assert.NotContains(t, string(script), e)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	// userDataBoundary separates the parts of multipart instance user data
+	userDataBoundary = "==INERTIA_USER_DATA=="
+
+	// dockerInstallScript installs Docker on instances when they first boot,
+	// if their image does not include it
+	dockerInstallScript = `#!/bin/sh
+if ! command -v docker >/dev/null 2>&1; then
+    curl -fsSL https://get.docker.com | sh
+fi
+`
+)
+
+// UserDataVariables are the variables available to instance user data
+// templates
+type UserDataVariables struct {
+	// ProjectName is the name of the project the instance is created for
+	ProjectName string
+
+	// Name is the name of the remote
+	Name string
+
+	// Region is the region the instance is created in
+	Region string
+
+	// User is the user Inertia connects to the instance as
+	User string
+
+	// Ports are the project ports exposed on the instance
+	Ports []PortRange
+
+	// DaemonPort is the port the Inertia daemon listens on, and WebhookPath
+	// is the path it receives webhooks on. The instance's public address is
+	// only known once it is running, so the webhook URL is
+	// https://[public address]:[DaemonPort][WebhookPath].
+	DaemonPort  int64
+	WebhookPath string
+}
+
+// RenderUserData renders the given instance user data template, such as a
+// shell script or cloud-config, with the given variables
+func RenderUserData(userDataTemplate string, vars UserDataVariables) (string, error) {
+	tmpl, err := template.New("user-data").Option("missingkey=error").Parse(userDataTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid user data template: %s", err.Error())
+	}
+	var rendered = new(bytes.Buffer)
+	if err := tmpl.Execute(rendered, vars); err != nil {
+		return "", fmt.Errorf("failed to render user data template: %s", err.Error())
+	}
+	return rendered.String(), nil
+}
+
+// combineUserData combines the given user data scripts and cloud-configs into
+// a single multipart document that cloud-init processes in order. Empty parts
+// are skipped, and a single part is returned as is.
+func combineUserData(parts ...string) string {
+	var nonEmpty = []string{}
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return ""
+	case 1:
+		return nonEmpty[0]
+	}
+
+	var combined = new(bytes.Buffer)
+	fmt.Fprintf(combined, "Content-Type: multipart/mixed; boundary=\"%s\"\n", userDataBoundary)
+	fmt.Fprintln(combined, "MIME-Version: 1.0")
+	for _, part := range nonEmpty {
+		var contentType = "text/x-shellscript"
+		if strings.HasPrefix(part, "#cloud-config") {
+			contentType = "text/cloud-config"
+		}
+		fmt.Fprintf(combined, "\n--%s\n", userDataBoundary)
+		fmt.Fprintf(combined, "Content-Type: %s; charset=\"us-ascii\"\n\n", contentType)
+		fmt.Fprint(combined, part)
+		if !strings.HasSuffix(part, "\n") {
+			fmt.Fprintln(combined)
+		}
+	}
+	fmt.Fprintf(combined, "--%s--\n", userDataBoundary)
+	return combined.String()
+}
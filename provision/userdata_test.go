@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderUserData(t *testing.T) {
+	var vars = UserDataVariables{
+		ProjectName: "wow",
+		Name:        "staging",
+		Region:      "us-east-1",
+		User:        "ec2-user",
+		Ports:       []PortRange{{80, 80, "tcp"}, {8000, 8100, "udp"}},
+		DaemonPort:  4303,
+		WebhookPath: "/webhook",
+	}
+	type args struct {
+		template string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"no variables", args{"#!/bin/bash\necho hi\n"}, "#!/bin/bash\necho hi\n", false},
+		{"project variables",
+			args{"echo {{.ProjectName}} {{.Name}} {{.Region}} {{.User}}"},
+			"echo wow staging us-east-1 ec2-user", false},
+		{"ports",
+			args{"{{range .Ports}}{{.}} {{end}}{{.DaemonPort}}{{.WebhookPath}}"},
+			"80/tcp 8000-8100/udp 4303/webhook", false},
+		{"unknown variable", args{"{{.Nope}}"}, "", true},
+		{"invalid template", args{"{{.ProjectName"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderUserData(tt.args.template, vars)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCombineUserData(t *testing.T) {
+	const (
+		script = "#!/bin/bash\necho hi\n"
+		config = "#cloud-config\npackages:\n  - git"
+	)
+
+	assert.Equal(t, "", combineUserData())
+	assert.Equal(t, "", combineUserData("", " \n"))
+	assert.Equal(t, script, combineUserData("", script))
+
+	var combined = combineUserData(script, config)
+	assert.True(t, strings.HasPrefix(combined, "Content-Type: multipart/mixed"))
+	assert.Contains(t, combined, "Content-Type: text/x-shellscript; charset=\"us-ascii\"\n\n"+script)
+	assert.Contains(t, combined, "Content-Type: text/cloud-config; charset=\"us-ascii\"\n\n"+config+"\n")
+	assert.True(t, strings.Index(combined, script) < strings.Index(combined, config))
+	assert.True(t, strings.HasSuffix(combined, "--"+userDataBoundary+"--\n"))
+}